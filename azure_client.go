@@ -1,11 +1,9 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"strings"
 	"time"
@@ -15,6 +13,9 @@ import (
 type azureClient struct {
 	config     Config
 	httpClient *http.Client
+	requests   *requestTracker
+	models     *modelCache
+	*liveConfig
 }
 
 // newAzureClient creates a new Azure OpenAI client
@@ -42,18 +43,51 @@ func newAzureClient(config Config) (*azureClient, error) {
 		config.DefaultModel = "gpt-35-turbo" // Default Azure deployment name
 	}
 
-	httpClient := &http.Client{
-		Timeout: config.Timeout,
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
 	}
+	httpClient, requests := trackRequests(config, httpClient)
 
 	return &azureClient{
 		config:     config,
 		httpClient: httpClient,
+		requests:   requests,
+		models:     &modelCache{},
+		liveConfig: newLiveConfig(config),
 	}, nil
 }
 
+// current returns a copy of the client with the configuration as of now, so
+// that a call sees one consistent set of defaults
+func (c *azureClient) current() *azureClient {
+	snapshot := *c
+	snapshot.config = c.load()
+	return &snapshot
+}
+
+// WithModel returns a view of the client using model by default
+func (c *azureClient) WithModel(model string) Client {
+	return c.WithDefaults(WithModel(model))
+}
+
+// WithDefaults returns a view of the client with the defaults set by opts
+func (c *azureClient) WithDefaults(opts ...RequestOption) Client {
+	view := c.current()
+	view.config = applyDefaults(view.config, opts)
+	view.httpClient, view.requests = c.requests.view(c.httpClient)
+	view.liveConfig = newLiveConfig(view.config)
+	return view
+}
+
 // Generate sends a request to Azure OpenAI and returns the response
 func (c *azureClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentGenerate(ctx, c.config, c.getModel, request, c.generate)
+}
+
+// generate performs the chat call without instrumentation
+func (c *azureClient) generate(ctx context.Context, request Request) (*Response, error) {
 	startTime := time.Now()
 
 	// Prepare the request payload (same as OpenAI)
@@ -65,32 +99,33 @@ func (c *azureClient) Generate(ctx context.Context, request Request) (*Response,
 	}
 
 	// Azure OpenAI uses a different endpoint format
-	url := c.config.BaseURL + "/chat/completions?api-version=2023-12-01-preview"
+	url := endpointURL(c.config, "/chat/completions") + "?api-version=2023-12-01-preview"
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonPayload))
+	req, err := newJSONRequest(ctx, c.config, url, jsonPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("api-key", c.config.APIKey) // Azure uses api-key header instead of Authorization
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
 
 	// Send request
-	resp, err := c.httpClient.Do(req)
+	resp, err := sendRequest(c.httpClient, c.config, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Azure OpenAI API error %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(c.config, "Azure OpenAI API error", resp, body)
 	}
 
 	// Parse response (same format as OpenAI)
@@ -102,10 +137,14 @@ func (c *azureClient) Generate(ctx context.Context, request Request) (*Response,
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
+		Model string `json:"model"`
 		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			TotalTokens         int `json:"total_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"prompt_tokens_details"`
 		} `json:"usage"`
 	}
 
@@ -114,17 +153,29 @@ func (c *azureClient) Generate(ctx context.Context, request Request) (*Response,
 	}
 
 	if len(apiResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in Azure OpenAI response")
+		return nil, &EmptyResponseError{Reason: "no choices", Body: body}
+	}
+	if emptyCompletion(apiResp.Choices[0].Message.Content, "", apiResp.Choices[0].FinishReason) {
+		return nil, &EmptyResponseError{Reason: "empty content", Body: body}
 	}
 
 	responseTime := time.Since(startTime)
 
 	return &Response{
-		Content:      apiResp.Choices[0].Message.Content,
-		Role:         MessageRole(apiResp.Choices[0].Message.Role),
-		TokensUsed:   apiResp.Usage.TotalTokens,
-		ResponseTime: responseTime,
-		FinishReason: apiResp.Choices[0].FinishReason,
+		Content:    apiResp.Choices[0].Message.Content,
+		Role:       MessageRole(apiResp.Choices[0].Message.Role),
+		TokensUsed: apiResp.Usage.TotalTokens,
+		Usage: Usage{
+			PromptTokens:     apiResp.Usage.PromptTokens,
+			CompletionTokens: apiResp.Usage.CompletionTokens,
+			TotalTokens:      apiResp.Usage.TotalTokens,
+			CachedTokens:     apiResp.Usage.PromptTokensDetails.CachedTokens,
+		},
+		Model:           apiResp.Model,
+		ResponseTime:    responseTime,
+		RequestID:       req.Header.Get(requestIDHeader),
+		FinishReason:    normalizeFinishReason(openAIFinishReasons, apiResp.Choices[0].FinishReason),
+		RawFinishReason: apiResp.Choices[0].FinishReason,
 	}, nil
 }
 
@@ -137,66 +188,246 @@ func (c *azureClient) GenerateWithHistory(ctx context.Context, history ChatHisto
 	return c.Generate(ctx, request)
 }
 
-// Close closes the client
+// Close stops new requests, waits up to Config.CloseTimeout for those in
+// flight and closes the client's idle connections
 func (c *azureClient) Close() error {
-	return nil
+	return c.requests.close(c.config.CloseTimeout)
 }
 
-// GetConfig returns the client configuration
+// GetConfig returns a copy of the client configuration with the API key masked
 func (c *azureClient) GetConfig() Config {
-	return c.config
+	return maskedConfig(c.load())
+}
+
+// GetConfigWithSecrets returns a copy of the client configuration including the API key
+func (c *azureClient) GetConfigWithSecrets() Config {
+	return c.load().Clone()
+}
+
+// CountTokens estimates the prompt tokens of request using Config.Tokenizer
+func (c *azureClient) CountTokens(request Request) int {
+	c = c.current()
+	return countRequestTokens(c.config, c.getModel(request.Model), request)
+}
+
+// Capabilities reports what the client supports for its provider
+func (c *azureClient) Capabilities() Capabilities {
+	return providerCapabilities(c.config.Provider)
+}
+
+// ListModels lists the deployments of the Azure OpenAI resource. IDs are
+// deployment names; BaseModel is the model each deployment serves.
+func (c *azureClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	c = c.current()
+	return c.models.get(ctx, c.config, c.fetchModels)
+}
+
+// fetchModels calls the deployments endpoint without caching
+func (c *azureClient) fetchModels(ctx context.Context) ([]ModelInfo, error) {
+	var apiResp struct {
+		Data []struct {
+			ID    string `json:"id"`
+			Model string `json:"model"`
+			Owner string `json:"owner"`
+		} `json:"data"`
+	}
+	url := azureResourceURL(c.config.BaseURL) + "/deployments?api-version=2022-12-01"
+	auth := func(req *http.Request) { req.Header.Set("api-key", c.config.APIKey) }
+	if err := fetchJSON(ctx, c.httpClient, c.config, url, auth, "Azure OpenAI API error", &apiResp); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(apiResp.Data))
+	for _, d := range apiResp.Data {
+		models = append(models, ModelInfo{ID: d.ID, OwnedBy: d.Owner, BaseModel: d.Model})
+	}
+	return models, nil
+}
+
+// Ping verifies credentials and reachability using Config.PingStrategy
+func (c *azureClient) Ping(ctx context.Context) error {
+	c = c.current()
+	return ping(ctx, c.config, c.fetchModels, c.generate)
+}
+
+// Warmup opens idle connections to the provider ahead of the first call
+func (c *azureClient) Warmup(ctx context.Context) error {
+	c = c.current()
+	return warmup(ctx, c.httpClient, c.config)
 }
 
 // CreateEmbedding generates embeddings for the given text(s)
 func (c *azureClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	return nil, fmt.Errorf("embeddings not supported for Azure provider yet")
+	c = c.current()
+	model := ""
+	if request.Model != nil {
+		model = *request.Model
+	}
+	return instrumentEmbedding(ctx, c.config, model, request, c.createEmbedding)
+}
+
+// createEmbedding performs the embedding call without instrumentation
+func (c *azureClient) createEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityEmbeddings}
+}
+
+// Moderate classifies content with the deployment's /moderations endpoint;
+// the deployment must serve a moderation model
+func (c *azureClient) Moderate(ctx context.Context, request ModerationRequest) (*ModerationResponse, error) {
+	c = c.current()
+	return instrumentModeration(ctx, c.config, getModerationModel(request.Model), request, c.moderate)
+}
+
+// moderate performs the moderation call without instrumentation
+func (c *azureClient) moderate(ctx context.Context, request ModerationRequest) (*ModerationResponse, error) {
+	startTime := time.Now()
+
+	jsonPayload, err := json.Marshal(moderationPayload(request, getModerationModel(request.Model)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal moderation request: %w", err)
+	}
+
+	url := endpointURL(c.config, "/moderations") + "?api-version=2023-12-01-preview"
+	req, err := newJSONRequest(ctx, c.config, url, jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create moderation request: %w", err)
+	}
+
+	req.Header.Set("api-key", c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send moderation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.config, "Azure OpenAI Moderation API error", resp, body)
+	}
+
+	response, err := parseModerations(body)
+	if err != nil {
+		return nil, err
+	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// Transcribe converts speech to text with the deployment's
+// /audio/transcriptions endpoint; the deployment must serve a Whisper model
+func (c *azureClient) Transcribe(ctx context.Context, request TranscriptionRequest) (*TranscriptionResponse, error) {
+	c = c.current()
+	return instrumentTranscription(ctx, c.config, getTranscriptionModel(request.Model), request, c.transcribe)
 }
 
-// buildPayload builds the request payload for Azure OpenAI API (same as OpenAI)
-func (c *azureClient) buildPayload(request Request) map[string]interface{} {
-	payload := map[string]interface{}{
-		"messages": c.convertMessages(request.Messages),
-		"stream":   request.Stream,
+// transcribe performs the transcription call without instrumentation
+func (c *azureClient) transcribe(ctx context.Context, request TranscriptionRequest) (*TranscriptionResponse, error) {
+	startTime := time.Now()
+	model := getTranscriptionModel(request.Model)
+
+	req, err := newTranscriptionRequest(ctx, endpointURL(c.config, "/audio/transcriptions")+"?api-version=2024-06-01", request, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcription request: %w", err)
 	}
 
-	// Add parameters if set
-	if request.Temperature != nil {
-		payload["temperature"] = *request.Temperature
-	} else if c.config.DefaultTemperature != nil {
-		payload["temperature"] = *c.config.DefaultTemperature
+	req.Header.Set("api-key", c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send transcription request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	if request.MaxTokens != nil {
-		payload["max_tokens"] = *request.MaxTokens
-	} else if c.config.DefaultMaxTokens != nil {
-		payload["max_tokens"] = *c.config.DefaultMaxTokens
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcription response: %w", err)
 	}
 
-	if request.TopP != nil {
-		payload["top_p"] = *request.TopP
-	} else if c.config.DefaultTopP != nil {
-		payload["top_p"] = *c.config.DefaultTopP
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, transcriptionError(c.config, "Azure OpenAI Transcription API error", resp, body)
 	}
 
-	// Add extra parameters
-	for k, v := range request.ExtraParams {
-		payload[k] = v
+	response, err := parseTranscription(body, request.ResponseFormat, model)
+	if err != nil {
+		return nil, err
 	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// GenerateImage generates images with the deployment's
+// /images/generations endpoint; the deployment must serve an image model
+func (c *azureClient) GenerateImage(ctx context.Context, request ImageRequest) (*ImageResponse, error) {
+	c = c.current()
+	return instrumentImage(ctx, c.config, getImageModel(c.config.Provider, request.Model), request, c.generateImage)
+}
+
+// generateImage performs the image call without instrumentation
+func (c *azureClient) generateImage(ctx context.Context, request ImageRequest) (*ImageResponse, error) {
+	startTime := time.Now()
+	model := getImageModel(c.config.Provider, request.Model)
+
+	jsonPayload, err := json.Marshal(imagePayload(request, model))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal image request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/images/generations")+"?api-version=2024-02-01", jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image request: %w", err)
+	}
+
+	req.Header.Set("api-key", c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send image request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// b64_json images are large; allow the embedding-sized limit
+	body, err := readBody(c.config, resp, defaultMaxEmbeddingResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.config, "Azure OpenAI Image API error", resp, body)
+	}
+
+	response, err := parseImages(body, model)
+	if err != nil {
+		return nil, err
+	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
 
-	return payload
+// buildPayload builds the request payload for Azure OpenAI API (same as
+// OpenAI, without a model as Azure routes by deployment)
+func (c *azureClient) buildPayload(request Request) chatPayload {
+	return newChatPayload(c.config, "", request)
 }
 
-// convertMessages converts internal Message format to OpenAI format
-func (c *azureClient) convertMessages(messages []Message) []map[string]interface{} {
-	result := make([]map[string]interface{}, len(messages))
-	for i, msg := range messages {
-		result[i] = map[string]interface{}{
-			"role":    string(msg.Role),
-			"content": msg.Content,
-		}
-		if msg.Name != "" {
-			result[i]["name"] = msg.Name
-		}
+// getModel returns the model to use for the request. Azure routes by
+// deployment, so this only labels the request.
+func (c *azureClient) getModel(override *string) string {
+	if override != nil {
+		return *override
 	}
-	return result
+	return c.config.DefaultModel
 }
@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BatchStatus is the state of a batch job
+type BatchStatus string
+
+// Batch job states reported by the Batch API
+const (
+	BatchValidating BatchStatus = "validating"
+	BatchInProgress BatchStatus = "in_progress"
+	BatchFinalizing BatchStatus = "finalizing"
+	BatchCompleted  BatchStatus = "completed"
+	BatchFailed     BatchStatus = "failed"
+	BatchExpired    BatchStatus = "expired"
+	BatchCancelling BatchStatus = "cancelling"
+	BatchCancelled  BatchStatus = "cancelled"
+)
+
+// Done reports whether the job has stopped; results of a completed, expired
+// or cancelled job can be downloaded with BatchResults
+func (s BatchStatus) Done() bool {
+	switch s {
+	case BatchCompleted, BatchFailed, BatchExpired, BatchCancelled:
+		return true
+	}
+	return false
+}
+
+// BatchRequest is one chat request of a batch. CustomID keys its result;
+// an empty CustomID is set to "request-<index>".
+type BatchRequest struct {
+	CustomID string  `json:"custom_id"`
+	Request  Request `json:"request"`
+}
+
+// BatchRequestCounts counts the requests of a batch job by outcome
+type BatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+// BatchJob is a batch as last reported by the provider
+type BatchJob struct {
+	ID            string             `json:"id"`
+	Status        BatchStatus        `json:"status"`
+	InputFileID   string             `json:"input_file_id"`
+	OutputFileID  string             `json:"output_file_id,omitempty"`
+	ErrorFileID   string             `json:"error_file_id,omitempty"`
+	RequestCounts BatchRequestCounts `json:"request_counts"`
+	// Errors explains why a failed batch was rejected (e.g. a malformed line)
+	Errors      []string  `json:"errors,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at,omitzero"`
+	CompletedAt time.Time `json:"completed_at,omitzero"`
+}
+
+// BatchResult is the outcome of one BatchRequest: Response on success,
+// otherwise Err (an *APIError carrying the request's status and body)
+type BatchResult struct {
+	CustomID string
+	Response *Response
+	Err      error
+}
+
+// BatchJobError is returned by BatchResults for a batch that produced no
+// results, such as one that failed validation
+type BatchJobError struct {
+	ID     string
+	Status BatchStatus
+	Errors []string
+}
+
+func (e *BatchJobError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("batch %s %s without results", e.ID, e.Status)
+	}
+	return fmt.Sprintf("batch %s %s: %s", e.ID, e.Status, strings.Join(e.Errors, "; "))
+}
+
+// Batcher is implemented by clients whose provider offers a batch API for
+// asynchronous, discounted chat requests; check Capabilities().Batch
+type Batcher interface {
+	CreateBatch(ctx context.Context, requests []BatchRequest) (*BatchJob, error)
+	GetBatch(ctx context.Context, id string) (*BatchJob, error)
+	CancelBatch(ctx context.Context, id string) (*BatchJob, error)
+	// BatchResults downloads the results of a finished job keyed by
+	// custom ID. Requests that never ran (expired or cancelled batches)
+	// have no entry.
+	BatchResults(ctx context.Context, job *BatchJob) (map[string]BatchResult, error)
+}
+
+// batcher returns client as a Batcher, or a CapabilityError
+func batcher(client Client) (Batcher, error) {
+	b, ok := client.(Batcher)
+	if !ok || !client.Capabilities().Batch {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilityBatch}
+	}
+	return b, nil
+}
+
+// CreateBatch uploads requests and starts a batch job with client
+func CreateBatch(ctx context.Context, client Client, requests []BatchRequest) (*BatchJob, error) {
+	b, err := batcher(client)
+	if err != nil {
+		return nil, err
+	}
+	return b.CreateBatch(ctx, requests)
+}
+
+// GetBatch returns the current state of a batch job
+func GetBatch(ctx context.Context, client Client, id string) (*BatchJob, error) {
+	b, err := batcher(client)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetBatch(ctx, id)
+}
+
+// CancelBatch asks the provider to stop a batch job; requests already
+// finished keep their results
+func CancelBatch(ctx context.Context, client Client, id string) (*BatchJob, error) {
+	b, err := batcher(client)
+	if err != nil {
+		return nil, err
+	}
+	return b.CancelBatch(ctx, id)
+}
+
+// BatchResults downloads the results of a finished batch job
+func BatchResults(ctx context.Context, client Client, job *BatchJob) (map[string]BatchResult, error) {
+	b, err := batcher(client)
+	if err != nil {
+		return nil, err
+	}
+	return b.BatchResults(ctx, job)
+}
+
+// WaitBatch polls a batch job every interval (0 = 30s) until it is Done or
+// ctx ends
+func WaitBatch(ctx context.Context, client Client, id string, interval time.Duration) (*BatchJob, error) {
+	b, err := batcher(client)
+	if err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		job, err := b.GetBatch(ctx, id)
+		if err != nil && !isTransient(err) {
+			return nil, err
+		}
+		if err == nil && job.Status.Done() {
+			return job, nil
+		}
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// batchInputLine is one line of an OpenAI batch input file
+type batchInputLine struct {
+	CustomID string      `json:"custom_id"`
+	Method   string      `json:"method"`
+	URL      string      `json:"url"`
+	Body     chatPayload `json:"body"`
+}
+
+// encodeBatchInput writes requests as an OpenAI batch input file, one
+// chat completion per line built with buildPayload
+func encodeBatchInput(requests []BatchRequest, buildPayload func(Request) chatPayload) ([]byte, error) {
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("batch has no requests")
+	}
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	seen := make(map[string]bool, len(requests))
+	for i, request := range requests {
+		id := request.CustomID
+		if id == "" {
+			id = fmt.Sprintf("request-%d", i)
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate batch custom ID %q", id)
+		}
+		seen[id] = true
+
+		body := buildPayload(request.Request)
+		body.Stream = new(bool)
+		if err := encoder.Encode(batchInputLine{CustomID: id, Method: "POST", URL: "/v1/chat/completions", Body: body}); err != nil {
+			return nil, fmt.Errorf("failed to encode batch request %q: %w", id, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// parseBatchOutput adds the results of an OpenAI batch output or error file
+// to results
+func parseBatchOutput(config Config, data []byte, results map[string]BatchResult) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64<<10), int(defaultMaxResponseBytes))
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var item struct {
+			CustomID string `json:"custom_id"`
+			Response *struct {
+				StatusCode int             `json:"status_code"`
+				RequestID  string          `json:"request_id"`
+				Body       json.RawMessage `json:"body"`
+			} `json:"response"`
+			Error *struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(line, &item); err != nil {
+			return fmt.Errorf("failed to parse batch output line: %w", err)
+		}
+
+		result := BatchResult{CustomID: item.CustomID}
+		switch {
+		case item.Response != nil && item.Response.StatusCode >= 200 && item.Response.StatusCode < 300:
+			result.Response, result.Err = parseChatCompletion(item.Response.Body)
+			if result.Response != nil {
+				result.Response.RequestID = item.Response.RequestID
+			}
+		case item.Response != nil:
+			result.Err = &APIError{
+				Provider:          config.Provider,
+				StatusCode:        item.Response.StatusCode,
+				Body:              redactSecrets(config, string(item.Response.Body)),
+				ProviderRequestID: item.Response.RequestID,
+				prefix:            "Batch request error",
+			}
+		case item.Error != nil:
+			result.Err = fmt.Errorf("batch request %s failed: %s: %s", item.CustomID, item.Error.Code, item.Error.Message)
+		default:
+			result.Err = fmt.Errorf("batch request %s has neither response nor error", item.CustomID)
+		}
+		results[item.CustomID] = result
+	}
+	return scanner.Err()
+}
+
+// unixTime converts optional Unix seconds to a time (zero for 0)
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
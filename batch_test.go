@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatch(t *testing.T) {
+	var mu sync.Mutex
+	var uploaded []map[string]interface{}
+	var purpose string
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/files":
+			r.ParseMultipartForm(1 << 20)
+			purpose = r.FormValue("purpose")
+			file, _, _ := r.FormFile("file")
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var line map[string]interface{}
+				json.Unmarshal(scanner.Bytes(), &line)
+				uploaded = append(uploaded, line)
+			}
+			w.Write([]byte(`{"id":"file-in"}`))
+		case "/batches":
+			var payload map[string]string
+			json.NewDecoder(r.Body).Decode(&payload)
+			if payload["input_file_id"] != "file-in" || payload["endpoint"] != "/v1/chat/completions" || payload["completion_window"] != "24h" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"id":"batch_1","status":"validating","input_file_id":"file-in","created_at":1700000000}`))
+		case "/batches/batch_1":
+			polls++
+			if polls < 2 {
+				w.Write([]byte(`{"id":"batch_1","status":"in_progress","request_counts":{"total":3,"completed":1,"failed":0}}`))
+				return
+			}
+			w.Write([]byte(`{"id":"batch_1","status":"completed","output_file_id":"file-out","error_file_id":"file-err",
+				"request_counts":{"total":3,"completed":2,"failed":1},"completed_at":1700000100}`))
+		case "/batches/batch_bad":
+			w.Write([]byte(`{"id":"batch_bad","status":"failed","errors":{"data":[{"code":"invalid_json_line","message":"bad line","line":2}]}}`))
+		case "/files/file-out/content":
+			io.WriteString(w, `{"custom_id":"a","response":{"status_code":200,"request_id":"req_a","body":{"model":"gpt-4o-mini","choices":[{"message":{"role":"assistant","content":"A"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}}}
+{"custom_id":"request-1","response":{"status_code":200,"body":{"choices":[{"message":{"role":"assistant","content":"B"}}]}}}
+`)
+		case "/files/file-err/content":
+			io.WriteString(w, `{"custom_id":"c","response":{"status_code":400,"body":{"error":{"message":"bad request"}}},"error":null}`+"\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true, DefaultModel: "gpt-4o-mini"})
+	job, err := CreateBatch(ctx, client, []BatchRequest{
+		{CustomID: "a", Request: BuildSimpleRequest("first")},
+		{Request: BuildSimpleRequest("second")},
+		{CustomID: "c", Request: BuildSimpleRequest("third")},
+	})
+	if err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	if job.ID != "batch_1" || job.Status != BatchValidating || job.CreatedAt.Unix() != 1700000000 {
+		t.Errorf("Unexpected job %+v", job)
+	}
+	if purpose != "batch" || len(uploaded) != 3 || uploaded[1]["custom_id"] != "request-1" || uploaded[0]["url"] != "/v1/chat/completions" {
+		t.Fatalf("Unexpected upload (purpose %q): %v", purpose, uploaded)
+	}
+	if body := uploaded[0]["body"].(map[string]interface{}); body["model"] != "gpt-4o-mini" || body["stream"] != false {
+		t.Errorf("Unexpected request body %v", body)
+	}
+
+	job, err = WaitBatch(ctx, client, job.ID, time.Millisecond)
+	if err != nil {
+		t.Fatalf("WaitBatch failed: %v", err)
+	}
+	if job.Status != BatchCompleted || job.RequestCounts.Failed != 1 {
+		t.Errorf("Unexpected finished job %+v", job)
+	}
+
+	results, err := BatchResults(ctx, client, job)
+	if err != nil {
+		t.Fatalf("BatchResults failed: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 results, got %v", results)
+	}
+	if a := results["a"]; a.Err != nil || a.Response.Content != "A" || a.Response.Usage.TotalTokens != 4 || a.Response.RequestID != "req_a" {
+		t.Errorf("Unexpected result a: %+v", a)
+	}
+	if b := results["request-1"]; b.Err != nil || b.Response.Content != "B" {
+		t.Errorf("Unexpected result request-1: %+v", b)
+	}
+	var apiErr *APIError
+	if c := results["c"]; !errors.As(c.Err, &apiErr) || apiErr.StatusCode != 400 || !strings.Contains(apiErr.Body, "bad request") {
+		t.Errorf("Expected an APIError for c, got %v", c.Err)
+	}
+
+	bad, err := GetBatch(ctx, client, "batch_bad")
+	if err != nil {
+		t.Fatalf("GetBatch failed: %v", err)
+	}
+	var jobErr *BatchJobError
+	if _, err := BatchResults(ctx, client, bad); !errors.As(err, &jobErr) || len(jobErr.Errors) != 1 || jobErr.Errors[0] != "line 2: invalid_json_line: bad line" {
+		t.Errorf("Expected a BatchJobError, got %v", err)
+	}
+
+	if _, err := CreateBatch(ctx, client, []BatchRequest{{CustomID: "x"}, {CustomID: "x"}}); err == nil {
+		t.Error("Expected duplicate custom IDs to fail")
+	}
+
+	deepseek, _ := NewClient(Config{Provider: ProviderDeepSeek, APIKey: "test-key", BaseURL: server.URL})
+	if _, err := CreateBatch(ctx, deepseek, []BatchRequest{{Request: BuildSimpleRequest("hi")}}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
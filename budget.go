@@ -0,0 +1,283 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrBudgetExceeded is returned by BudgetClient when a call would be made
+// after the budget's hard cap has been reached
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Spend is the cost and token usage accounted against a budget
+type Spend struct {
+	USD    float64
+	Tokens int
+}
+
+// BudgetConfig configures NewBudgetClient. At least one of MaxCost and
+// MaxTokens should be set; a zero cap is not enforced.
+type BudgetConfig struct {
+	// MaxCost is the hard cap in USD
+	MaxCost float64
+
+	// MaxTokens is the hard cap in total tokens
+	MaxTokens int
+
+	// Window sums spend over a sliding window (0 = since the client was created)
+	Window time.Duration
+
+	// Key selects the budget a call is accounted under (nil = the key set by
+	// WithBudgetKey, or "" for calls without one)
+	Key func(ctx context.Context) string
+
+	// SoftLimit is the fraction of a cap (for example 0.8) at which
+	// OnSoftLimit fires. It fires once per crossing, not per call.
+	SoftLimit   float64
+	OnSoftLimit func(key string, spend Spend)
+
+	// Pricing prices responses (nil = the default table, see SetPricing).
+	// Responses of unpriced models count tokens only.
+	Pricing Pricing
+}
+
+// BudgetClient wraps a Client and rejects calls once a spend cap is reached
+type BudgetClient struct {
+	Client
+	budget BudgetConfig
+
+	mu      sync.Mutex
+	ledgers map[string]*budgetLedger
+}
+
+// budgetLedger is the spend of one budget key
+type budgetLedger struct {
+	entries []budgetEntry // only kept with a Window
+	total   Spend
+	alerted bool
+}
+
+// budgetEntry is the spend of one call
+type budgetEntry struct {
+	at    time.Time
+	spend Spend
+}
+
+// NewBudgetClient returns a client that accounts the actual usage reported
+// by inner's responses and fails calls with ErrBudgetExceeded, before they
+// are sent, once the spend of their key has reached a cap. Calls already in
+// flight when the cap is reached still complete, so spend can overshoot a
+// cap by the cost of concurrent calls.
+func NewBudgetClient(inner Client, budget BudgetConfig) *BudgetClient {
+	return &BudgetClient{
+		Client:  inner,
+		budget:  budget,
+		ledgers: make(map[string]*budgetLedger),
+	}
+}
+
+// Generate sends the request if the budget allows and accounts its usage
+func (c *BudgetClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	key := c.key(ctx)
+	if err := c.check(key); err != nil {
+		return nil, err
+	}
+	response, err := c.Client.Generate(ctx, request)
+	if response != nil {
+		c.record(key, c.responseSpend(response))
+	}
+	return response, err
+}
+
+// TryGenerate sends the request if the budget allows and the wrapped client
+// can send it without waiting (see TryGenerate), and accounts its usage
+func (c *BudgetClient) TryGenerate(ctx context.Context, request Request) (*Response, error) {
+	key := c.key(ctx)
+	if err := c.check(key); err != nil {
+		return nil, err
+	}
+	response, err := TryGenerate(ctx, c.Client, request)
+	if response != nil {
+		c.record(key, c.responseSpend(response))
+	}
+	return response, err
+}
+
+// EstimateWait reports the wait of the wrapped client
+func (c *BudgetClient) EstimateWait(ctx context.Context, request Request) WaitEstimate {
+	return EstimateWait(ctx, c.Client, request)
+}
+
+// GenerateStream starts the stream if the budget allows and accounts the
+// usage reported by its final chunk
+func (c *BudgetClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	key := c.key(ctx)
+	if err := c.check(key); err != nil {
+		return nil, err
+	}
+	response, err := GenerateStream(ctx, c.Client, request)
+	if err != nil {
+		return nil, err
+	}
+	return relayStream(ctx, response, func(final *StreamChunk) {
+		if final != nil && final.Usage != nil {
+			c.record(key, c.responseSpend(&Response{Provider: response.Provider, Model: response.Model, Usage: *final.Usage}))
+		}
+	}), nil
+}
+
+// GenerateWithHistory sends the conversation if the budget allows and accounts its usage
+func (c *BudgetClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	key := c.key(ctx)
+	if err := c.check(key); err != nil {
+		return nil, err
+	}
+	response, err := c.Client.GenerateWithHistory(ctx, history, userMessage, systemPrompt)
+	if response != nil {
+		c.record(key, c.responseSpend(response))
+	}
+	return response, err
+}
+
+// CreateEmbedding embeds the input if the budget allows and accounts its usage
+func (c *BudgetClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	key := c.key(ctx)
+	if err := c.check(key); err != nil {
+		return nil, err
+	}
+	response, err := c.Client.CreateEmbedding(ctx, request)
+	if response != nil {
+		spend := Spend{Tokens: response.Usage.TotalTokens}
+		if spend.Tokens == 0 {
+			spend.Tokens = response.TokensUsed
+		}
+		provider := c.Client.GetConfig().Provider
+		if usd, err := c.pricing().Cost(provider, response.Model, spend.Tokens, 0, 0); err == nil {
+			spend.USD = usd
+		}
+		c.record(key, spend)
+	}
+	return response, err
+}
+
+// Spend returns the current spend of a budget key ("" for calls without one)
+func (c *BudgetClient) Spend(key string) Spend {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ledger, ok := c.ledgers[key]
+	if !ok {
+		return Spend{}
+	}
+	c.prune(ledger)
+	return ledger.total
+}
+
+// SpendFromContext returns the current spend of the budget ctx is accounted under
+func (c *BudgetClient) SpendFromContext(ctx context.Context) Spend {
+	return c.Spend(c.key(ctx))
+}
+
+// key returns the budget key of a call
+func (c *BudgetClient) key(ctx context.Context) string {
+	if c.budget.Key != nil {
+		return c.budget.Key(ctx)
+	}
+	key, _ := BudgetKeyFromContext(ctx)
+	return key
+}
+
+// pricing returns the price table used for accounting
+func (c *BudgetClient) pricing() Pricing {
+	if c.budget.Pricing != nil {
+		return c.budget.Pricing
+	}
+	return DefaultPricing()
+}
+
+// responseSpend prices a chat response from its reported usage
+func (c *BudgetClient) responseSpend(response *Response) Spend {
+	spend := Spend{Tokens: response.Usage.TotalTokens}
+	if spend.Tokens == 0 {
+		spend.Tokens = response.TokensUsed
+	}
+	if cost, err := c.pricing().Estimate(response, nil); err == nil {
+		spend.USD = cost.USD
+	}
+	return spend
+}
+
+// check fails with ErrBudgetExceeded if key has reached a cap
+func (c *BudgetClient) check(key string) error {
+	spend := c.Spend(key)
+	if c.budget.MaxCost > 0 && spend.USD >= c.budget.MaxCost {
+		return fmt.Errorf("%w: %q spent $%.4f of $%.4f", ErrBudgetExceeded, key, spend.USD, c.budget.MaxCost)
+	}
+	if c.budget.MaxTokens > 0 && spend.Tokens >= c.budget.MaxTokens {
+		return fmt.Errorf("%w: %q used %d of %d tokens", ErrBudgetExceeded, key, spend.Tokens, c.budget.MaxTokens)
+	}
+	return nil
+}
+
+// record adds the spend of a call to key and fires OnSoftLimit on crossing
+func (c *BudgetClient) record(key string, spend Spend) {
+	c.mu.Lock()
+	ledger, ok := c.ledgers[key]
+	if !ok {
+		ledger = &budgetLedger{}
+		c.ledgers[key] = ledger
+	}
+	c.prune(ledger)
+	if c.budget.Window > 0 {
+		ledger.entries = append(ledger.entries, budgetEntry{at: now(), spend: spend})
+	}
+	ledger.total.USD += spend.USD
+	ledger.total.Tokens += spend.Tokens
+
+	fire := false
+	if c.overSoftLimit(ledger.total) {
+		fire = !ledger.alerted
+		ledger.alerted = true
+	}
+	total := ledger.total
+	c.mu.Unlock()
+
+	if fire && c.budget.OnSoftLimit != nil {
+		c.budget.OnSoftLimit(key, total)
+	}
+}
+
+// prune drops entries that left the window; c.mu must be held
+func (c *BudgetClient) prune(ledger *budgetLedger) {
+	if c.budget.Window <= 0 {
+		return
+	}
+	cutoff := now().Add(-c.budget.Window)
+	i := 0
+	for ; i < len(ledger.entries) && !ledger.entries[i].at.After(cutoff); i++ {
+		ledger.total.USD -= ledger.entries[i].spend.USD
+		ledger.total.Tokens -= ledger.entries[i].spend.Tokens
+	}
+	if i == 0 {
+		return
+	}
+	ledger.entries = append(ledger.entries[:0], ledger.entries[i:]...)
+	if len(ledger.entries) == 0 {
+		// Avoid accumulating floating-point drift across windows
+		ledger.total = Spend{}
+	}
+	if !c.overSoftLimit(ledger.total) {
+		ledger.alerted = false
+	}
+}
+
+// overSoftLimit reports whether spend has reached the soft limit of a cap
+func (c *BudgetClient) overSoftLimit(spend Spend) bool {
+	if c.budget.SoftLimit <= 0 {
+		return false
+	}
+	return (c.budget.MaxCost > 0 && spend.USD >= c.budget.SoftLimit*c.budget.MaxCost) ||
+		(c.budget.MaxTokens > 0 && float64(spend.Tokens) >= c.budget.SoftLimit*float64(c.budget.MaxTokens))
+}
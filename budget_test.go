@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBudgetTestClient() *scriptedClient {
+	return &scriptedClient{reply: func(Request) (*Response, error) {
+		return &Response{
+			Content:  "ok",
+			Provider: ProviderOpenAI,
+			Model:    "gpt-4o",
+			Usage:    Usage{PromptTokens: 100_000, CompletionTokens: 10_000, TotalTokens: 110_000},
+		}, nil
+	}}
+}
+
+func TestBudgetClientCostCap(t *testing.T) {
+	var alerts []Spend
+	client := NewBudgetClient(newBudgetTestClient(), BudgetConfig{
+		MaxCost:     1.0, // each call costs $0.35
+		SoftLimit:   0.5,
+		OnSoftLimit: func(key string, spend Spend) { alerts = append(alerts, spend) },
+	})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if _, err := client.Generate(ctx, BuildSimpleRequest("hi")); err != nil {
+			t.Fatalf("Call %d: unexpected error %v", i, err)
+		}
+	}
+	if _, err := client.Generate(ctx, BuildSimpleRequest("hi")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected ErrBudgetExceeded, got %v", err)
+	}
+
+	spend := client.Spend("")
+	if math.Abs(spend.USD-1.05) > 1e-9 || spend.Tokens != 330_000 {
+		t.Errorf("Unexpected spend %+v", spend)
+	}
+	if len(alerts) != 1 || math.Abs(alerts[0].USD-0.70) > 1e-9 {
+		t.Errorf("Expected one soft-limit alert at $0.70, got %+v", alerts)
+	}
+}
+
+func TestBudgetClientKeysAndWindow(t *testing.T) {
+	current := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+	defer func() { now = time.Now }()
+
+	client := NewBudgetClient(newBudgetTestClient(), BudgetConfig{MaxTokens: 200_000, Window: time.Minute})
+	tenantA := WithBudgetKey(context.Background(), "a")
+	tenantB := WithBudgetKey(context.Background(), "b")
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Generate(tenantA, BuildSimpleRequest("hi")); err != nil {
+			t.Fatalf("Unexpected error %v", err)
+		}
+	}
+	if _, err := client.Generate(tenantA, BuildSimpleRequest("hi")); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("Expected tenant a to be over budget, got %v", err)
+	}
+	if _, err := client.Generate(tenantB, BuildSimpleRequest("hi")); err != nil {
+		t.Fatalf("Tenant b should have its own budget, got %v", err)
+	}
+
+	current = current.Add(2 * time.Minute)
+	if spend := client.SpendFromContext(tenantA); spend != (Spend{}) {
+		t.Errorf("Expected spend to leave the window, got %+v", spend)
+	}
+	if _, err := client.Generate(tenantA, BuildSimpleRequest("hi")); err != nil {
+		t.Errorf("Expected budget to recover after the window, got %v", err)
+	}
+}
+
+func TestBudgetClientConcurrent(t *testing.T) {
+	client := NewBudgetClient(newBudgetTestClient(), BudgetConfig{MaxTokens: 1 << 40})
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Generate(context.Background(), BuildSimpleRequest("hi"))
+		}()
+	}
+	wg.Wait()
+	if spend := client.Spend(""); spend.Tokens != 50*110_000 {
+		t.Errorf("Expected %d tokens, got %d", 50*110_000, spend.Tokens)
+	}
+}
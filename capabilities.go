@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupported is matched by every CapabilityError via errors.Is
+var ErrUnsupported = errors.New("operation not supported")
+
+// Capability names reported by CapabilityError
+const (
+	CapabilityChat       = "chat"
+	CapabilityStreaming  = "streaming"
+	CapabilityEmbeddings = "embeddings"
+	// CapabilityEmbeddingDimensions is server-side shortening of embeddings
+	CapabilityEmbeddingDimensions = "embedding_dimensions"
+	CapabilityTools               = "tools"
+	CapabilityVision              = "vision"
+	CapabilityJSONMode            = "json_mode"
+	CapabilityJSONSchema          = "json_schema"
+	CapabilityLogprobs            = "logprobs"
+	CapabilityTopK                = "top_k"
+	CapabilityRerank              = "rerank"
+	CapabilityListModels          = "list_models"
+	CapabilityModeration          = "moderation"
+	CapabilityTranscription       = "transcription"
+	CapabilitySpeech              = "speech"
+	CapabilityImageGeneration     = "image_generation"
+	CapabilityBatch               = "batch"
+)
+
+// Capabilities describes what a client can do. Flags cover features this
+// package implements for the provider; parameters such as JSON mode and
+// logprobs are passed through Request.ExtraParams.
+type Capabilities struct {
+	Chat       bool `json:"chat"`
+	Streaming  bool `json:"streaming"`
+	Embeddings bool `json:"embeddings"`
+	// EmbeddingDimensions is support for EmbeddingRequest.Dimensions
+	EmbeddingDimensions bool `json:"embedding_dimensions"`
+	// NormalizedEmbeddings means the provider already returns unit-length
+	// vectors, making EmbeddingRequest.Normalize a no-op
+	NormalizedEmbeddings bool `json:"normalized_embeddings"`
+	Tools                bool `json:"tools"`
+	Vision               bool `json:"vision"`
+	JSONMode             bool `json:"json_mode"`
+	JSONSchema           bool `json:"json_schema"`
+	Logprobs             bool `json:"logprobs"`
+	TopK                 bool `json:"top_k"`
+	// Rerank means the client implements Reranker
+	Rerank bool `json:"rerank"`
+	// Moderation means the client implements Moderator
+	Moderation bool `json:"moderation"`
+	// Transcription means the client implements Transcriber
+	Transcription bool `json:"transcription"`
+	// Speech means the client implements Speaker
+	Speech bool `json:"speech"`
+	// ImageGeneration means the client implements ImageGenerator
+	ImageGeneration bool `json:"image_generation"`
+	// Batch means the client implements Batcher
+	Batch bool `json:"batch"`
+
+	// Limits (0 = unknown or unlimited)
+	MaxStopSequences  int `json:"max_stop_sequences,omitempty"`
+	MaxEmbeddingBatch int `json:"max_embedding_batch,omitempty"`
+	// MaxEmbeddingInputTokens is the token limit of one embedding input
+	MaxEmbeddingInputTokens int `json:"max_embedding_input_tokens,omitempty"`
+}
+
+// CapabilityError is returned for operations a provider does not support
+type CapabilityError struct {
+	Provider   Provider
+	Capability string
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("%s is not supported for provider %s", e.Capability, e.Provider)
+}
+
+// Is makes errors.Is(err, ErrUnsupported) match
+func (e *CapabilityError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// providerCapabilities returns the capabilities of the built-in clients
+func providerCapabilities(provider Provider) Capabilities {
+	switch provider {
+	case ProviderDeepSeek:
+		return Capabilities{Chat: true, Streaming: true, JSONMode: true, Logprobs: true, MaxStopSequences: 16}
+	case ProviderQwen:
+		return Capabilities{Chat: true, Embeddings: true, EmbeddingDimensions: true, JSONMode: true, TopK: true, MaxEmbeddingBatch: 25, MaxEmbeddingInputTokens: 8192}
+	case ProviderAzure:
+		return Capabilities{Chat: true, JSONMode: true, JSONSchema: true, Logprobs: true, Moderation: true, Transcription: true, ImageGeneration: true, MaxStopSequences: 4}
+	case ProviderJina:
+		return Capabilities{Embeddings: true, EmbeddingDimensions: true, NormalizedEmbeddings: true, Rerank: true, MaxEmbeddingBatch: 2048, MaxEmbeddingInputTokens: 8192}
+	case ProviderGemini:
+		return Capabilities{Chat: true, Streaming: true, Embeddings: true, EmbeddingDimensions: true, TopK: true, MaxEmbeddingBatch: 100, MaxEmbeddingInputTokens: 2048}
+	case ProviderCohere:
+		return Capabilities{Chat: true, Embeddings: true, JSONMode: true, TopK: true, MaxStopSequences: 5, MaxEmbeddingBatch: 96, MaxEmbeddingInputTokens: 512}
+	default:
+		// OpenAI and other OpenAI-compatible endpoints
+		return Capabilities{Chat: true, Streaming: true, Embeddings: true, EmbeddingDimensions: true, NormalizedEmbeddings: true, JSONMode: true, JSONSchema: true, Logprobs: true, Moderation: true, Transcription: true, Speech: true, ImageGeneration: true, Batch: true, MaxStopSequences: 4, MaxEmbeddingBatch: 2048, MaxEmbeddingInputTokens: 8191}
+	}
+}
@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		config     Config
+		embeddings bool
+		topK       bool
+	}{
+		{config: Config{Provider: ProviderOpenAI}, embeddings: true},
+		{config: Config{Provider: ProviderDeepSeek}},
+		{config: Config{Provider: ProviderQwen}, embeddings: true, topK: true},
+		{config: Config{Provider: ProviderAzure, BaseURL: server.URL + "/openai/deployments/chat"}},
+		{config: Config{Provider: ProviderCohere}, embeddings: true, topK: true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.config.Provider), func(t *testing.T) {
+			tt.config.APIKey = "test-key"
+			if tt.config.BaseURL == "" {
+				tt.config.BaseURL = server.URL
+			}
+			client, err := NewClient(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			caps := client.Capabilities()
+			if !caps.Chat || caps.Embeddings != tt.embeddings || caps.TopK != tt.topK {
+				t.Errorf("Unexpected capabilities %+v", caps)
+			}
+			if tt.embeddings {
+				return
+			}
+
+			requests = 0
+			_, err = client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"hi"}})
+			var capErr *CapabilityError
+			if !errors.Is(err, ErrUnsupported) || !errors.As(err, &capErr) || capErr.Capability != CapabilityEmbeddings {
+				t.Errorf("Expected a CapabilityError, got %v", err)
+			}
+			if ErrorClass(err) != StatusUnsupported {
+				t.Errorf("Expected class %s, got %s", StatusUnsupported, ErrorClass(err))
+			}
+			if requests != 0 {
+				t.Errorf("Unsupported call reached the server")
+			}
+		})
+	}
+}
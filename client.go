@@ -1,24 +1,41 @@
 package llm
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
-// NewClient creates a new LLM client based on the provider
+// NewClient creates a new LLM client with the factory of Config.Provider,
+// built in or added with RegisterProvider. An empty Config.Provider is
+// detected from DefaultModel (see DetectProvider). The
+// client keeps a copy of config (see Config.Clone), so later changes to the
+// caller's pointers and maps do not affect it.
 func NewClient(config Config) (Client, error) {
-	switch config.Provider {
-	case ProviderOpenAI, ProviderDeepSeek:
-		return NewOpenAICompatibleClient(config)
-	case ProviderQwen:
-		return NewQwenClient(config)
-	case ProviderAzure:
-		return NewAzureClient(config)
-	case ProviderCohere:
-		return newCohereClient(config)
-	default:
-		return nil, fmt.Errorf("unsupported LLM provider: %s", config.Provider)
+	config = config.Clone()
+	if config.Provider != "" && !config.Provider.Valid() {
+		provider, err := ParseProvider(string(config.Provider))
+		if err != nil {
+			return nil, err
+		}
+		config.Provider = provider
+	}
+	config, err := detectConfigProvider(config)
+	if err != nil {
+		return nil, err
+	}
+
+	factory, ok := providerFactory(config.Provider)
+	if !ok {
+		return nil, fmt.Errorf("unsupported LLM provider %q (supported: %s)", config.Provider, supportedProviders())
 	}
+	client, err := factory(config)
+	if err == nil && config.PreconnectOnCreate {
+		go preconnect(client, config)
+	}
+	return client, err
 }
 
 // NewOpenAICompatibleClient creates a client for OpenAI-compatible APIs (OpenAI, DeepSeek, etc.)
@@ -41,6 +58,16 @@ func NewCohereClient(config Config) (Client, error) {
 	return newCohereClient(config)
 }
 
+// NewJinaClient creates a client for Jina AI embeddings and reranking
+func NewJinaClient(config Config) (Client, error) {
+	return newJinaClient(config)
+}
+
+// NewGeminiClient creates a client for Google Gemini embeddings
+func NewGeminiClient(config Config) (Client, error) {
+	return newGeminiClient(config)
+}
+
 // Helper functions for building requests
 
 // BuildSimpleRequest creates a simple request with a single user message
@@ -123,14 +150,134 @@ func (r *Request) SetDeepSeekThinking(enabled bool) {
 	r.DeepSeekThinking = &enabled
 }
 
+// Clone returns a deep copy of the request: pointer fields point to new
+// values and messages, headers and extra parameters (including nested maps
+// and slices) are copied, so the clone can be modified concurrently with
+// the original.
+func (r Request) Clone() Request {
+	clone := r
+	clone.Messages = copyMessages(r.Messages)
+	clone.Temperature = clonePtr(r.Temperature)
+	clone.MaxTokens = clonePtr(r.MaxTokens)
+	clone.TopP = clonePtr(r.TopP)
+	clone.TopK = clonePtr(r.TopK)
+	clone.Model = clonePtr(r.Model)
+	clone.DeepSeekThinking = clonePtr(r.DeepSeekThinking)
+	if r.SafetySettings != nil {
+		clone.SafetySettings = append([]SafetySetting(nil), r.SafetySettings...)
+	}
+	if r.ExtraParams != nil {
+		clone.ExtraParams = cloneValue(r.ExtraParams).(map[string]interface{})
+	}
+	if r.Headers != nil {
+		clone.Headers = make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			clone.Headers[k] = v
+		}
+	}
+	return clone
+}
+
+// Clone returns a deep copy of the configuration: the Default* parameter
+// pointers and extra parameters, safety settings, headers, TLS material, hook lists and
+// ExtraConfig (including nested maps and slices) are copied, so the clone
+// can be modified without affecting a client. HTTPClient, Logger, Tokenizer,
+// Metrics, OnUsage and the hooks themselves are shared.
+func (c Config) Clone() Config {
+	clone := c
+	clone.DefaultTemperature = clonePtr(c.DefaultTemperature)
+	clone.DefaultMaxTokens = clonePtr(c.DefaultMaxTokens)
+	clone.DefaultTopP = clonePtr(c.DefaultTopP)
+	clone.DefaultTopK = clonePtr(c.DefaultTopK)
+	if c.SafetySettings != nil {
+		clone.SafetySettings = append([]SafetySetting(nil), c.SafetySettings...)
+	}
+	if c.Headers != nil {
+		clone.Headers = make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			clone.Headers[k] = v
+		}
+	}
+	if c.ModelTimeouts != nil {
+		clone.ModelTimeouts = make(map[string]time.Duration, len(c.ModelTimeouts))
+		for k, v := range c.ModelTimeouts {
+			clone.ModelTimeouts[k] = v
+		}
+	}
+	if c.TLS != nil {
+		tlsConfig := *c.TLS
+		tlsConfig.CACertPEM = bytes.Clone(c.TLS.CACertPEM)
+		tlsConfig.ClientCertPEM = bytes.Clone(c.TLS.ClientCertPEM)
+		tlsConfig.ClientKeyPEM = bytes.Clone(c.TLS.ClientKeyPEM)
+		clone.TLS = &tlsConfig
+	}
+	if c.BeforeRequest != nil {
+		clone.BeforeRequest = append([]BeforeRequestHook(nil), c.BeforeRequest...)
+	}
+	if c.AfterResponse != nil {
+		clone.AfterResponse = append([]AfterResponseHook(nil), c.AfterResponse...)
+	}
+	if c.ConfigChanged != nil {
+		clone.ConfigChanged = append([]ConfigChangeHook(nil), c.ConfigChanged...)
+	}
+	if c.DefaultExtraParams != nil {
+		clone.DefaultExtraParams = cloneValue(c.DefaultExtraParams).(map[string]interface{})
+	}
+	if c.ExtraConfig != nil {
+		clone.ExtraConfig = cloneValue(c.ExtraConfig).(map[string]interface{})
+	}
+	return clone
+}
+
+// clonePtr returns a pointer to a copy of *p, or nil
+func clonePtr[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	v := *p
+	return &v
+}
+
+// cloneValue deep-copies the JSON-like maps and slices used in ExtraParams
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		clone := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			clone[k] = cloneValue(item)
+		}
+		return clone
+	case []interface{}:
+		clone := make([]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item)
+		}
+		return clone
+	case []map[string]interface{}:
+		clone := make([]map[string]interface{}, len(v))
+		for i, item := range v {
+			clone[i] = cloneValue(item).(map[string]interface{})
+		}
+		return clone
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return v
+	}
+}
+
 // ChatHistory methods
 
+// Clone returns a deep copy of the history
+func (h ChatHistory) Clone() ChatHistory {
+	clone := h
+	clone.Messages = copyMessages(h.Messages)
+	return clone
+}
+
 // AddMessage adds a message to the chat history
 func (h *ChatHistory) AddMessage(role MessageRole, content string) {
-	h.Messages = append(h.Messages, Message{
-		Role:    role,
-		Content: content,
-	})
+	h.append(Message{Role: role, Content: content})
 }
 
 // AddSystemMessage adds a system message to history
@@ -166,11 +313,95 @@ func (h *ChatHistory) GetLastMessage() *Message {
 	return &h.Messages[len(h.Messages)-1]
 }
 
-// Truncate truncates history to keep only the last n messages
+// Truncate truncates history to at most n messages. Leading system messages
+// are always kept; the rest of the budget goes to the most recent messages,
+// less any tool results left without their call.
 func (h *ChatHistory) Truncate(n int) {
-	if len(h.Messages) > n {
-		h.Messages = h.Messages[len(h.Messages)-n:]
+	if len(h.Messages) <= n {
+		return
+	}
+
+	pinned := 0
+	for pinned < len(h.Messages) && h.Messages[pinned].Role == RoleSystem {
+		pinned++
+	}
+	tail := n - pinned
+	if tail < 0 {
+		tail = 0
+	}
+
+	kept := h.Messages[len(h.Messages)-tail:]
+	for len(kept) > 0 && kept[0].Role == RoleTool {
+		kept = kept[1:]
+	}
+
+	messages := make([]Message, 0, pinned+len(kept))
+	messages = append(messages, h.Messages[:pinned]...)
+	messages = append(messages, kept...)
+	h.Messages = messages
+}
+
+// TruncateToTokens drops the oldest non-system messages until the history
+// fits in maxTokens as counted by tokenizer (nil = HeuristicTokenizer).
+// System messages are always kept, and an assistant message or tool result
+// is never left as the first conversational turn. It returns how many messages and tokens
+// were dropped; the history may still exceed maxTokens if only system
+// messages remain.
+func (h *ChatHistory) TruncateToTokens(maxTokens int, tokenizer Tokenizer) (droppedMessages, droppedTokens int) {
+	if tokenizer == nil {
+		tokenizer = HeuristicTokenizer{}
+	}
+
+	before := tokenizer.CountMessages("", h.Messages)
+	kept := trimOldest(h.Messages, func(messages []Message) bool {
+		return tokenizer.CountMessages("", messages) > maxTokens
+	})
+
+	droppedMessages = len(h.Messages) - len(kept)
+	if droppedMessages == 0 {
+		return 0, 0
+	}
+	h.Messages = kept
+	return droppedMessages, before - tokenizer.CountMessages("", kept)
+}
+
+// trimOldest drops the oldest non-system message while tooBig reports true,
+// also dropping any orphaned message left as the first conversational turn
+func trimOldest(messages []Message, tooBig func([]Message) bool) []Message {
+	for tooBig(messages) {
+		i := firstConversational(messages)
+		if i < 0 {
+			break
+		}
+		messages = removeMessage(messages, i)
+		for i = firstConversational(messages); i >= 0 && orphaned(messages[i]); i = firstConversational(messages) {
+			messages = removeMessage(messages, i)
+		}
+	}
+	return messages
+}
+
+// orphaned reports whether msg cannot start a conversation: an assistant
+// reply, or a tool result whose call was dropped
+func orphaned(msg Message) bool {
+	return msg.Role == RoleAssistant || msg.Role == RoleTool
+}
+
+// firstConversational returns the index of the first non-system message, or -1
+func firstConversational(messages []Message) int {
+	for i, msg := range messages {
+		if msg.Role != RoleSystem {
+			return i
+		}
 	}
+	return -1
+}
+
+// removeMessage returns a copy of messages without index i
+func removeMessage(messages []Message, i int) []Message {
+	result := make([]Message, 0, len(messages)-1)
+	result = append(result, messages[:i]...)
+	return append(result, messages[i+1:]...)
 }
 
 // Convenience functions for common operations
@@ -181,6 +412,25 @@ func GenerateSimple(ctx context.Context, client Client, prompt string) (*Respons
 	return client.Generate(ctx, req)
 }
 
+// GenerateText generates a response for prompt and returns only its text,
+// without a leading byte order mark or surrounding whitespace. opts are
+// applied before prompt is added as the last user message, so WithSystem
+// and WithMessages come first.
+func GenerateText(ctx context.Context, client Client, prompt string, opts ...RequestOption) (string, error) {
+	req := NewRequest(opts...)
+	req.AddUserMessage(prompt)
+	response, err := client.Generate(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	return cleanText(response.Content), nil
+}
+
+// cleanText strips a byte order mark and surrounding whitespace
+func cleanText(content string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content), "\ufeff"))
+}
+
 // GenerateWithHistory generates a response using chat history
 func GenerateWithHistory(ctx context.Context, client Client, history ChatHistory, userMessage, systemPrompt string) (*Response, error) {
 	req := BuildChatRequest(history.GetMessages(), userMessage)
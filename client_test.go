@@ -2,7 +2,10 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -125,7 +128,11 @@ func TestClientConfiguration(t *testing.T) {
 		t.Errorf("Expected provider %s, got %s", ProviderDeepSeek, clientConfig.Provider)
 	}
 
-	if clientConfig.APIKey != "test-key" {
+	if clientConfig.APIKey == "test-key" {
+		t.Error("GetConfig should mask the API key")
+	}
+
+	if client.GetConfigWithSecrets().APIKey != "test-key" {
 		t.Error("API key not set correctly")
 	}
 }
@@ -207,3 +214,183 @@ func TestRequestBuilding(t *testing.T) {
 		t.Error("Model not set correctly")
 	}
 }
+
+func TestChatHistoryTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles []MessageRole
+		n     int
+		want  []string
+	}{
+		{
+			name:  "no system message",
+			roles: []MessageRole{RoleUser, RoleAssistant, RoleUser, RoleAssistant},
+			n:     2,
+			want:  []string{"2", "3"},
+		},
+		{
+			name:  "one system message",
+			roles: []MessageRole{RoleSystem, RoleUser, RoleAssistant, RoleUser, RoleAssistant, RoleUser},
+			n:     4,
+			want:  []string{"0", "3", "4", "5"},
+		},
+		{
+			name:  "multiple system messages",
+			roles: []MessageRole{RoleSystem, RoleSystem, RoleUser, RoleAssistant, RoleUser},
+			n:     3,
+			want:  []string{"0", "1", "4"},
+		},
+		{
+			name:  "budget smaller than system prefix",
+			roles: []MessageRole{RoleSystem, RoleSystem, RoleUser, RoleAssistant},
+			n:     1,
+			want:  []string{"0", "1"},
+		},
+		{
+			name:  "later system message is not pinned",
+			roles: []MessageRole{RoleUser, RoleSystem, RoleUser, RoleAssistant},
+			n:     2,
+			want:  []string{"2", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			history := ChatHistory{}
+			for i, role := range tt.roles {
+				history.AddMessage(role, strconv.Itoa(i))
+			}
+			history.Truncate(tt.n)
+
+			var got []string
+			for _, msg := range history.GetMessages() {
+				got = append(got, msg.Content)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Truncate(%d) kept %v, want %v", tt.n, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChatHistoryTruncateToTokens(t *testing.T) {
+	// One token per byte keeps the arithmetic readable: each message costs
+	// 3 overhead + role + content, plus 3 per history.
+	tokenizer := TokenizerFunc(func(model, text string) int { return len(text) })
+
+	newHistory := func() ChatHistory {
+		history := ChatHistory{}
+		history.AddSystemMessage("sys")                      // 3+6+3 = 12
+		history.AddUserMessage(strings.Repeat("u", 100))     // 3+4+100 = 107
+		history.AddAssistantMessage(strings.Repeat("a", 10)) // 3+9+10 = 22
+		history.AddUserMessage("hi")                         // 3+4+2 = 9
+		history.AddAssistantMessage("hello")                 // 3+9+5 = 17
+		return history
+	}
+
+	t.Run("fits", func(t *testing.T) {
+		history := newHistory()
+		if dropped, tokens := history.TruncateToTokens(1000, tokenizer); dropped != 0 || tokens != 0 {
+			t.Errorf("Expected nothing dropped, got %d messages / %d tokens", dropped, tokens)
+		}
+	})
+
+	t.Run("drops oldest pair", func(t *testing.T) {
+		history := newHistory()
+		dropped, tokens := history.TruncateToTokens(50, tokenizer)
+		if dropped != 2 || tokens != 129 {
+			t.Errorf("Expected 2 messages / 129 tokens dropped, got %d / %d", dropped, tokens)
+		}
+		messages := history.GetMessages()
+		if len(messages) != 3 || messages[0].Role != RoleSystem || messages[1].Content != "hi" {
+			t.Errorf("Unexpected history after truncation: %+v", messages)
+		}
+	})
+
+	t.Run("no dangling assistant", func(t *testing.T) {
+		history := newHistory()
+		history.TruncateToTokens(40, tokenizer)
+		messages := history.GetMessages()
+		if len(messages) != 1 || messages[0].Role != RoleSystem {
+			t.Errorf("Expected only the system message, got %+v", messages)
+		}
+	})
+}
+
+func TestRequestClone(t *testing.T) {
+	original := BuildRequestWithSystemPrompt("sys", "hello")
+	original.SetTemperature(0.2)
+	original.SetModel("gpt-4o")
+	original.ExtraParams = map[string]interface{}{
+		"response_format": map[string]interface{}{"type": "json_object"},
+		"stop":            []interface{}{"\n"},
+	}
+	original.Headers = map[string]string{"X-Tenant": "a"}
+
+	clone := original.Clone()
+	*clone.Temperature = 0.9
+	*clone.Model = "gpt-4o-mini"
+	clone.Messages[0].Content = "changed"
+	clone.ExtraParams["response_format"].(map[string]interface{})["type"] = "text"
+	clone.ExtraParams["stop"].([]interface{})[0] = "END"
+	clone.Headers["X-Tenant"] = "b"
+
+	if *original.Temperature != 0.2 || *original.Model != "gpt-4o" || original.Messages[0].Content != "sys" {
+		t.Errorf("Clone shares scalar state with the original: %+v", original)
+	}
+	if original.ExtraParams["response_format"].(map[string]interface{})["type"] != "json_object" || original.ExtraParams["stop"].([]interface{})[0] != "\n" {
+		t.Errorf("Clone shares ExtraParams with the original: %+v", original.ExtraParams)
+	}
+	if original.Headers["X-Tenant"] != "a" {
+		t.Error("Clone shares Headers with the original")
+	}
+
+	history := ChatHistory{}
+	history.AddUserMessage("hi")
+	historyClone := history.Clone()
+	historyClone.Messages[0].Content = "changed"
+	if history.Messages[0].Content != "hi" {
+		t.Error("ChatHistory.Clone shares messages with the original")
+	}
+}
+
+func TestGenerateText(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"plain", "Paris", "Paris"},
+		{"whitespace", "\n  Paris.\n\n", "Paris."},
+		{"byte order mark", "\ufeffParis", "Paris"},
+		{"whitespace around byte order mark", " \ufeff\nParis\n", "Paris"},
+		{"inner whitespace kept", "Line one\n\nLine two\n", "Line one\n\nLine two"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Request
+			client := &scriptedClient{reply: func(request Request) (*Response, error) {
+				got = request
+				return &Response{Content: tt.content}, nil
+			}}
+			text, err := GenerateText(context.Background(), client, "Capital of France?", WithSystem("Be brief."), WithMaxTokens(5))
+			if err != nil {
+				t.Fatalf("GenerateText failed: %v", err)
+			}
+			if text != tt.want {
+				t.Errorf("GenerateText = %q, want %q", text, tt.want)
+			}
+			if len(got.Messages) != 2 || got.Messages[0].Role != RoleSystem || got.Messages[1].Content != "Capital of France?" {
+				t.Errorf("Expected the prompt after the options' messages, got %+v", got.Messages)
+			}
+			if got.MaxTokens == nil || *got.MaxTokens != 5 {
+				t.Errorf("Expected options to be applied, got %+v", got)
+			}
+		})
+	}
+
+	failing := &scriptedClient{reply: func(Request) (*Response, error) { return nil, errors.New("boom") }}
+	if text, err := GenerateText(context.Background(), failing, "Hi"); err == nil || text != "" {
+		t.Errorf("Expected an error and no text, got %q, %v", text, err)
+	}
+}
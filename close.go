@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrClientClosed is returned for requests a client sends after Close
+var ErrClientClosed = errors.New("client is closed")
+
+// requestTracker is the transport of a provider client. It counts the HTTP
+// requests in flight, including response bodies still being read such as
+// streams, and refuses new ones once the client is closed.
+type requestTracker struct {
+	base http.RoundTripper
+	// owned is set when the client built base itself, so it may close its
+	// idle connections; the shared pool and Config.HTTPClient are left alone
+	owned bool
+
+	mu       sync.Mutex
+	closed   bool
+	inFlight int
+	drained  chan struct{} // closed once closed and nothing is in flight
+}
+
+// trackRequests returns a copy of httpClient whose requests go through a
+// requestTracker, and the tracker
+func trackRequests(config Config, httpClient *http.Client) (*http.Client, *requestTracker) {
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	tracker := &requestTracker{
+		base:    base,
+		owned:   config.HTTPClient == nil && base != sharedTransport,
+		drained: make(chan struct{}),
+	}
+	tracked := *httpClient
+	tracked.Transport = tracker
+	return &tracked, tracker
+}
+
+// RoundTrip sends req unless the client is closed. The request counts as in
+// flight until its response body is closed.
+func (t *requestTracker) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.acquire() {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, ErrClientClosed
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		t.release()
+		return nil, err
+	}
+	resp.Body = &trackedBody{ReadCloser: resp.Body, release: sync.OnceFunc(t.release)}
+	return resp, nil
+}
+
+// acquire counts a new request, or reports false once closed
+func (t *requestTracker) acquire() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	t.inFlight++
+	return true
+}
+
+// release ends a request; the last one to end after Close closes the idle
+// connections it leaves behind
+func (t *requestTracker) release() {
+	t.mu.Lock()
+	t.inFlight--
+	drained := t.closed && t.inFlight == 0
+	if drained {
+		close(t.drained)
+	}
+	t.mu.Unlock()
+	if drained {
+		t.closeIdleConnections()
+	}
+}
+
+// close refuses new requests and waits up to timeout for those in flight.
+// Only the first call does anything.
+func (t *requestTracker) close(timeout time.Duration) error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	if t.inFlight == 0 {
+		close(t.drained)
+	}
+	t.mu.Unlock()
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-t.drained:
+		case <-timer.C:
+			t.mu.Lock()
+			pending := t.inFlight
+			t.mu.Unlock()
+			if pending > 0 {
+				return fmt.Errorf("%d requests still in flight after %v: %w", pending, timeout, context.DeadlineExceeded)
+			}
+		}
+	}
+	t.closeIdleConnections()
+	return nil
+}
+
+// closeIdleConnections closes the idle connections of an owned transport
+func (t *requestTracker) closeIdleConnections() {
+	if closer, ok := t.base.(interface{ CloseIdleConnections() }); ok && t.owned {
+		closer.CloseIdleConnections()
+	}
+}
+
+// trackedBody releases its request when closed
+type trackedBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
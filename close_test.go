@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCloseRejectsNewRequests(t *testing.T) {
+	var hits atomic.Int32
+	server := newChatServer(t, func(*http.Request) { hits.Add(1) })
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, CloseTimeout: time.Second})
+
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	start := time.Now()
+	if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed, got %v", err)
+	}
+	if _, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"Hi"}}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed from CreateEmbedding, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Expected calls after Close to fail fast, took %v", elapsed)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("Expected no requests to reach the server, got %d", hits.Load())
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Expected a second Close to do nothing, got %v", err)
+	}
+}
+
+func TestCloseDrainsStream(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\" world\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, CloseTimeout: 5 * time.Second})
+	response, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hi"))
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	first := <-response.Stream
+
+	closed := make(chan error, 1)
+	go func() { closed <- client.Close() }()
+	select {
+	case err := <-closed:
+		t.Fatalf("Close returned before the stream finished: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected ErrClientClosed while draining, got %v", err)
+	}
+
+	close(release)
+	content, _, final := drainStream(t, response)
+	if first.Content+content != "Hello world" || final.Err != nil {
+		t.Errorf("Expected the stream to complete, got %q (%v)", first.Content+content, final.Err)
+	}
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return after the stream finished")
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := newChatServer(t, func(*http.Request) { <-release })
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, CloseTimeout: 50 * time.Millisecond})
+
+	started := make(chan struct{})
+	go func() {
+		close(started)
+		client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+	}()
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	if err := client.Close(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a drain timeout, got %v", err)
+	}
+}
+
+func TestCloseLeavesSharedTransport(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		owned  bool
+	}{
+		{name: "shared pool", config: Config{}},
+		{name: "custom HTTP client", config: Config{HTTPClient: &http.Client{Transport: newTransport()}}},
+		{name: "dedicated transport", config: Config{DisableProxy: true}, owned: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			httpClient, err := newHTTPClient(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tracked, requests := trackRequests(tt.config, httpClient)
+			if requests.owned != tt.owned {
+				t.Errorf("owned = %v, want %v", requests.owned, tt.owned)
+			}
+			if tracked == httpClient || tracked.Transport != requests {
+				t.Error("Expected a copy of the HTTP client using the tracker")
+			}
+		})
+	}
+}
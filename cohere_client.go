@@ -1,11 +1,9 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 )
@@ -14,6 +12,9 @@ import (
 type cohereClient struct {
 	config     Config
 	httpClient *http.Client
+	requests   *requestTracker
+	models     *modelCache
+	*liveConfig
 }
 
 // newCohereClient creates a new Cohere client
@@ -25,28 +26,65 @@ func newCohereClient(config Config) (*cohereClient, error) {
 	if config.BaseURL == "" {
 		config.BaseURL = "https://api.cohere.ai/v1"
 	}
+	config, err := normalizeBaseURL(config, "/v1")
+	if err != nil {
+		return nil, err
+	}
 
 	if config.Timeout == 0 {
 		config.Timeout = 30 * time.Second
 	}
 
 	if config.DefaultModel == "" {
-		// Default to multilingual v3 for embeddings
-		config.DefaultModel = "embed-multilingual-v3.0"
+		config.DefaultModel = "command-r-plus"
 	}
+	config = withEmbeddingDefault(config, "embed-multilingual-v3.0")
 
-	httpClient := &http.Client{
-		Timeout: config.Timeout,
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
 	}
+	httpClient, requests := trackRequests(config, httpClient)
 
 	return &cohereClient{
 		config:     config,
 		httpClient: httpClient,
+		requests:   requests,
+		models:     &modelCache{},
+		liveConfig: newLiveConfig(config),
 	}, nil
 }
 
+// current returns a copy of the client with the configuration as of now, so
+// that a call sees one consistent set of defaults
+func (c *cohereClient) current() *cohereClient {
+	snapshot := *c
+	snapshot.config = c.load()
+	return &snapshot
+}
+
+// WithModel returns a view of the client using model by default
+func (c *cohereClient) WithModel(model string) Client {
+	return c.WithDefaults(WithModel(model))
+}
+
+// WithDefaults returns a view of the client with the defaults set by opts
+func (c *cohereClient) WithDefaults(opts ...RequestOption) Client {
+	view := c.current()
+	view.config = applyDefaults(view.config, opts)
+	view.httpClient, view.requests = c.requests.view(c.httpClient)
+	view.liveConfig = newLiveConfig(view.config)
+	return view
+}
+
 // Generate sends a request to Cohere and returns the response
 func (c *cohereClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentGenerate(ctx, c.config, c.getModel, request, c.generate)
+}
+
+// generate performs the chat call without instrumentation
+func (c *cohereClient) generate(ctx context.Context, request Request) (*Response, error) {
 	startTime := time.Now()
 
 	// Prepare the request payload
@@ -58,29 +96,30 @@ func (c *cohereClient) Generate(ctx context.Context, request Request) (*Response
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.BaseURL+"/chat", bytes.NewBuffer(jsonPayload))
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/chat"), jsonPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
 
 	// Send request
-	resp, err := c.httpClient.Do(req)
+	resp, err := sendRequest(c.httpClient, c.config, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Cohere API error %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(c.config, "Cohere API error", resp, body)
 	}
 
 	// Parse response
@@ -93,6 +132,10 @@ func (c *cohereClient) Generate(ctx context.Context, request Request) (*Response
 			} `json:"billed_units"`
 		} `json:"meta"`
 		FinishReason string `json:"finish_reason"`
+		ToolCalls    []struct {
+			Name       string          `json:"name"`
+			Parameters json.RawMessage `json:"parameters"`
+		} `json:"tool_calls"`
 	}
 
 	if err := json.Unmarshal(body, &apiResp); err != nil {
@@ -101,12 +144,30 @@ func (c *cohereClient) Generate(ctx context.Context, request Request) (*Response
 
 	responseTime := time.Since(startTime)
 
+	var toolCalls []ToolCall
+	for i, call := range apiResp.ToolCalls {
+		toolCalls = append(toolCalls, ToolCall{ID: generatedToolCallID(call.Name, i), Name: call.Name, Arguments: string(call.Parameters)})
+	}
+	finishReason := normalizeFinishReason(cohereFinishReasons, apiResp.FinishReason)
+	if len(toolCalls) > 0 && finishReason == FinishStop {
+		// Cohere reports COMPLETE after tool calls
+		finishReason = FinishToolCalls
+	}
+
 	return &Response{
-		Content:      apiResp.Text,
-		Role:         RoleAssistant,
-		TokensUsed:   apiResp.Meta.BilledUnits.InputTokens + apiResp.Meta.BilledUnits.OutputTokens,
-		ResponseTime: responseTime,
-		FinishReason: apiResp.FinishReason,
+		Content:    apiResp.Text,
+		Role:       RoleAssistant,
+		TokensUsed: apiResp.Meta.BilledUnits.InputTokens + apiResp.Meta.BilledUnits.OutputTokens,
+		Usage: Usage{
+			PromptTokens:     apiResp.Meta.BilledUnits.InputTokens,
+			CompletionTokens: apiResp.Meta.BilledUnits.OutputTokens,
+			TotalTokens:      apiResp.Meta.BilledUnits.InputTokens + apiResp.Meta.BilledUnits.OutputTokens,
+		},
+		ResponseTime:    responseTime,
+		RequestID:       req.Header.Get(requestIDHeader),
+		FinishReason:    finishReason,
+		RawFinishReason: apiResp.FinishReason,
+		ToolCalls:       toolCalls,
 	}, nil
 }
 
@@ -119,24 +180,74 @@ func (c *cohereClient) GenerateWithHistory(ctx context.Context, history ChatHist
 	return c.Generate(ctx, request)
 }
 
+// CountTokens estimates the prompt tokens of request using Config.Tokenizer
+func (c *cohereClient) CountTokens(request Request) int {
+	c = c.current()
+	return countRequestTokens(c.config, c.getModel(request.Model), request)
+}
+
+// Capabilities reports what the client supports for its provider
+func (c *cohereClient) Capabilities() Capabilities {
+	return providerCapabilities(c.config.Provider)
+}
+
+// ListModels lists the models of Cohere's /models endpoint
+func (c *cohereClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	c = c.current()
+	return c.models.get(ctx, c.config, c.fetchModels)
+}
+
+// fetchModels calls the /models endpoint without caching
+func (c *cohereClient) fetchModels(ctx context.Context) ([]ModelInfo, error) {
+	var apiResp struct {
+		Models []struct {
+			Name          string `json:"name"`
+			ContextLength int    `json:"context_length"`
+		} `json:"models"`
+	}
+	url := endpointURL(c.config, "/models?page_size=1000")
+	if err := fetchJSON(ctx, c.httpClient, c.config, url, bearerAuth(c.config.APIKey), "Cohere API error", &apiResp); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(apiResp.Models))
+	for _, m := range apiResp.Models {
+		models = append(models, ModelInfo{ID: m.Name, OwnedBy: "cohere", ContextLength: m.ContextLength})
+	}
+	return models, nil
+}
+
+// Ping verifies credentials and reachability using Config.PingStrategy
+func (c *cohereClient) Ping(ctx context.Context) error {
+	c = c.current()
+	return ping(ctx, c.config, c.fetchModels, c.generate)
+}
+
+// Warmup opens idle connections to the provider ahead of the first call
+func (c *cohereClient) Warmup(ctx context.Context) error {
+	c = c.current()
+	return warmup(ctx, c.httpClient, c.config)
+}
+
 // CreateEmbedding generates embeddings for the given text(s)
 func (c *cohereClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	startTime := time.Now()
+	c = c.current()
+	return instrumentEmbedding(ctx, c.config, c.getEmbeddingModel(request.Model), request, c.createEmbedding)
+}
 
-	// Determine embedding model
-	embeddingModel := "embed-multilingual-v3.0"
-	if request.Model != nil {
-		embeddingModel = *request.Model
-	} else if c.config.DefaultModel != "" {
-		embeddingModel = c.config.DefaultModel
-	}
+// createEmbedding performs the embedding call without instrumentation
+func (c *cohereClient) createEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	startTime := time.Now()
 
 	// Prepare the request payload for Cohere embed API
 	payload := map[string]interface{}{
-		"model":      embeddingModel,
+		"model":      c.getEmbeddingModel(request.Model),
 		"texts":      request.Input,
 		"input_type": "search_document", // or "search_query", "classification", "clustering"
 	}
+	if task := embeddingTaskValue(c.config.Provider, request.Task); task != "" {
+		payload["input_type"] = task
+	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -144,35 +255,36 @@ func (c *cohereClient) CreateEmbedding(ctx context.Context, request EmbeddingReq
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.BaseURL+"/embed", bytes.NewBuffer(jsonPayload))
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/embed"), jsonPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
 
 	// Send request
-	resp, err := c.httpClient.Do(req)
+	resp, err := sendRequest(c.httpClient, c.config, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send embedding request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := readBody(c.config, resp, defaultMaxEmbeddingResponseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read embedding response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Cohere Embedding API error %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(c.config, "Cohere Embedding API error", resp, body)
 	}
 
 	// Parse response
 	var apiResp struct {
-		Embeddings [][]float64 `json:"embeddings"`
-		ID         string      `json:"id"`
+		Embeddings []json.RawMessage `json:"embeddings"`
+		ID         string            `json:"id"`
 		Meta       struct {
 			BilledUnits struct {
 				InputTokens int `json:"input_tokens"`
@@ -188,24 +300,36 @@ func (c *cohereClient) CreateEmbedding(ctx context.Context, request EmbeddingReq
 		return nil, fmt.Errorf("no embeddings in response")
 	}
 
-	responseTime := time.Since(startTime)
-
-	return &EmbeddingResponse{
-		Embeddings:   apiResp.Embeddings,
-		Model:        embeddingModel,
-		TokensUsed:   apiResp.Meta.BilledUnits.InputTokens,
-		ResponseTime: responseTime,
-	}, nil
+	response := &EmbeddingResponse{
+		Model:      c.getEmbeddingModel(request.Model),
+		TokensUsed: apiResp.Meta.BilledUnits.InputTokens,
+		Usage: Usage{
+			PromptTokens: apiResp.Meta.BilledUnits.InputTokens,
+			TotalTokens:  apiResp.Meta.BilledUnits.InputTokens,
+		},
+		RequestID: req.Header.Get(requestIDHeader),
+	}
+	if err := decodeEmbeddings(apiResp.Embeddings, request.AsFloat32, response); err != nil {
+		return nil, err
+	}
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
 }
 
-// Close closes the client
+// Close stops new requests, waits up to Config.CloseTimeout for those in
+// flight and closes the client's idle connections
 func (c *cohereClient) Close() error {
-	return nil
+	return c.requests.close(c.config.CloseTimeout)
 }
 
-// GetConfig returns the client configuration
+// GetConfig returns a copy of the client configuration with the API key masked
 func (c *cohereClient) GetConfig() Config {
-	return c.config
+	return maskedConfig(c.load())
+}
+
+// GetConfigWithSecrets returns a copy of the client configuration including the API key
+func (c *cohereClient) GetConfigWithSecrets() Config {
+	return c.load().Clone()
 }
 
 // buildPayload builds the request payload for Cohere Chat API
@@ -214,13 +338,35 @@ func (c *cohereClient) buildPayload(request Request) map[string]interface{} {
 	var message string
 	var chatHistory []map[string]interface{}
 
-	for i, msg := range request.Messages {
+	var toolResults []map[string]interface{}
+
+	messages := requestMessages(request)
+	// Results after the last turn answer the calls of this request
+	pending := len(messages)
+	for pending > 0 && messages[pending-1].Role == RoleTool {
+		pending--
+	}
+	for i, msg := range messages {
 		if msg.Role == RoleSystem {
-			// Cohere doesn't have system role, prepend to first user message
+			// Cohere doesn't have a system role, system text goes in the preamble
+			continue
+		}
+		if msg.Role == RoleTool {
+			result := cohereToolResult(messages, msg)
+			if i >= pending {
+				toolResults = append(toolResults, result)
+			} else if last := len(chatHistory) - 1; last >= 0 && chatHistory[last]["role"] == "TOOL" {
+				chatHistory[last]["tool_results"] = append(chatHistory[last]["tool_results"].([]map[string]interface{}), result)
+			} else {
+				chatHistory = append(chatHistory, map[string]interface{}{
+					"role":         "TOOL",
+					"tool_results": []map[string]interface{}{result},
+				})
+			}
 			continue
 		}
 		if msg.Role == RoleUser {
-			if i == len(request.Messages)-1 {
+			if i == len(messages)-1 {
 				// Last user message is the main message
 				message = msg.Content
 			} else {
@@ -230,10 +376,18 @@ func (c *cohereClient) buildPayload(request Request) map[string]interface{} {
 				})
 			}
 		} else if msg.Role == RoleAssistant {
-			chatHistory = append(chatHistory, map[string]interface{}{
+			entry := map[string]interface{}{
 				"role":    "CHATBOT",
 				"message": msg.Content,
-			})
+			}
+			if len(msg.ToolCalls) > 0 {
+				calls := make([]map[string]interface{}, len(msg.ToolCalls))
+				for j, call := range msg.ToolCalls {
+					calls[j] = cohereToolCall(call)
+				}
+				entry["tool_calls"] = calls
+			}
+			chatHistory = append(chatHistory, entry)
 		}
 	}
 
@@ -245,6 +399,13 @@ func (c *cohereClient) buildPayload(request Request) map[string]interface{} {
 	if len(chatHistory) > 0 {
 		payload["chat_history"] = chatHistory
 	}
+	if len(toolResults) > 0 {
+		payload["tool_results"] = toolResults
+	}
+
+	if preamble := systemText(messages); preamble != "" {
+		payload["preamble"] = preamble
+	}
 
 	// Add temperature if set
 	if request.Temperature != nil {
@@ -282,14 +443,40 @@ func (c *cohereClient) buildPayload(request Request) map[string]interface{} {
 	return payload
 }
 
+// cohereToolCall converts call to Cohere's form, which has no ID
+func cohereToolCall(call ToolCall) map[string]interface{} {
+	return map[string]interface{}{
+		"name":       call.Name,
+		"parameters": toolArguments(call.Arguments),
+	}
+}
+
+// cohereToolResult converts a RoleTool message. Cohere identifies a result
+// by repeating its call, which is looked up in messages by ToolCallID.
+func cohereToolResult(messages []Message, msg Message) map[string]interface{} {
+	call, ok := findToolCall(messages, msg.ToolCallID)
+	if !ok {
+		call = ToolCall{Name: msg.Name}
+	}
+	return map[string]interface{}{
+		"call":    cohereToolCall(call),
+		"outputs": []json.RawMessage{toolResultObject(msg.Content)},
+	}
+}
+
 // getModel returns the model to use for the request
 func (c *cohereClient) getModel(override *string) string {
 	if override != nil {
 		return *override
 	}
-	// For chat, use command model if not specified
-	if c.config.DefaultModel == "" || c.config.DefaultModel == "embed-multilingual-v3.0" {
+	// An embedding DefaultModel (see withEmbeddingDefault) is not a chat model
+	if isEmbeddingModel(c.config.DefaultModel) {
 		return "command-r-plus"
 	}
 	return c.config.DefaultModel
 }
+
+// getEmbeddingModel returns the embedding model to use for the request
+func (c *cohereClient) getEmbeddingModel(override *string) string {
+	return resolveEmbeddingModel(c.config, override)
+}
@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultKeepRecent is the number of recent messages Compact never summarizes
+const defaultKeepRecent = 6
+
+// summaryPrefix marks the system message holding a conversation summary, so
+// a later compaction folds it into the next summary instead of pinning it
+const summaryPrefix = "Summary of the earlier conversation:\n"
+
+// DefaultCompactPrompt is the summarization instruction used by Compact
+const DefaultCompactPrompt = "Summarize the following conversation for your own future reference. " +
+	"Keep every fact, name, number, decision and open question; drop pleasantries. " +
+	"Write concise plain text, not a transcript."
+
+// CompactOptions configures ChatHistory.Compact
+type CompactOptions struct {
+	// MaxTokens triggers compaction once the history's estimated size exceeds it
+	MaxTokens int
+
+	// KeepRecent is the number of most recent messages that are never
+	// summarized (0 = 6)
+	KeepRecent int
+
+	// SummarizeCount limits how many of the oldest messages are summarized
+	// per compaction (0 = everything older than KeepRecent)
+	SummarizeCount int
+
+	// Prompt is the summarization instruction (empty = DefaultCompactPrompt)
+	Prompt string
+
+	// Model overrides the client's default model for the summarization call
+	Model *string
+
+	// Tokenizer estimates the history size (nil = HeuristicTokenizer)
+	Tokenizer Tokenizer
+}
+
+// CompactResult reports what Compact did
+type CompactResult struct {
+	Compacted          bool
+	SummarizedMessages int
+	TokensBefore       int
+	TokensAfter        int
+}
+
+// Compact summarizes the oldest messages into a single system message using
+// client when the history exceeds opts.MaxTokens. Leading system messages and
+// the most recent opts.KeepRecent messages are never summarized. If the
+// summarization call fails the history is left unchanged and the error is
+// returned.
+func (h *ChatHistory) Compact(ctx context.Context, client Client, opts CompactOptions) (CompactResult, error) {
+	tokenizer := opts.Tokenizer
+	if tokenizer == nil {
+		tokenizer = HeuristicTokenizer{}
+	}
+	keepRecent := opts.KeepRecent
+	if keepRecent <= 0 {
+		keepRecent = defaultKeepRecent
+	}
+
+	result := CompactResult{TokensBefore: tokenizer.CountMessages("", h.Messages)}
+	result.TokensAfter = result.TokensBefore
+	if result.TokensBefore <= opts.MaxTokens {
+		return result, nil
+	}
+
+	pinned := 0
+	for pinned < len(h.Messages) && h.Messages[pinned].Role == RoleSystem && !isSummary(h.Messages[pinned]) {
+		pinned++
+	}
+	end := len(h.Messages) - keepRecent
+	if opts.SummarizeCount > 0 && pinned+opts.SummarizeCount < end {
+		end = pinned + opts.SummarizeCount
+	}
+	if end-pinned < 1 {
+		return result, nil
+	}
+
+	prompt := opts.Prompt
+	if prompt == "" {
+		prompt = DefaultCompactPrompt
+	}
+	request := BuildRequestWithSystemPrompt(prompt, transcript(h.Messages[pinned:end]))
+	request.Model = opts.Model
+
+	response, err := client.Generate(ctx, request)
+	if err != nil {
+		return result, fmt.Errorf("failed to summarize conversation: %w", err)
+	}
+	summary := strings.TrimSpace(response.Content)
+	if summary == "" {
+		return result, fmt.Errorf("failed to summarize conversation: empty summary")
+	}
+
+	messages := make([]Message, 0, pinned+1+len(h.Messages)-end)
+	messages = append(messages, h.Messages[:pinned]...)
+	messages = append(messages, Message{Role: RoleSystem, Content: summaryPrefix + summary})
+	messages = append(messages, h.Messages[end:]...)
+	h.Messages = messages
+
+	result.Compacted = true
+	result.SummarizedMessages = end - pinned
+	result.TokensAfter = tokenizer.CountMessages("", h.Messages)
+	return result, nil
+}
+
+// isSummary reports whether msg is a summary written by Compact
+func isSummary(msg Message) bool {
+	return msg.Role == RoleSystem && strings.HasPrefix(msg.Content, summaryPrefix)
+}
+
+// transcript renders messages as "role: content" lines for summarization
+func transcript(messages []Message) string {
+	var sb strings.Builder
+	for _, msg := range messages {
+		if isSummary(msg) {
+			sb.WriteString("earlier summary: ")
+			sb.WriteString(strings.TrimPrefix(msg.Content, summaryPrefix))
+		} else {
+			sb.WriteString(string(msg.Role))
+			sb.WriteString(": ")
+			sb.WriteString(msg.Content)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
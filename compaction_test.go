@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestChatHistoryCompact(t *testing.T) {
+	var summarized string
+	server := newChatServer(t, func(r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		summarized = string(body)
+	})
+	client, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	newHistory := func() ChatHistory {
+		history := ChatHistory{}
+		history.AddSystemMessage("You are helpful.")
+		for i := 0; i < 5; i++ {
+			history.AddUserMessage("question " + strconv.Itoa(i))
+			history.AddAssistantMessage("answer " + strconv.Itoa(i))
+		}
+		return history
+	}
+
+	t.Run("below threshold", func(t *testing.T) {
+		history := newHistory()
+		result, err := history.Compact(context.Background(), client, CompactOptions{MaxTokens: 10000})
+		if err != nil || result.Compacted {
+			t.Errorf("Expected no compaction, got %+v, %v", result, err)
+		}
+	})
+
+	t.Run("summarizes oldest messages", func(t *testing.T) {
+		history := newHistory()
+		result, err := history.Compact(context.Background(), client, CompactOptions{MaxTokens: 10, KeepRecent: 4})
+		if err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+		if !result.Compacted || result.SummarizedMessages != 6 || result.TokensAfter >= result.TokensBefore {
+			t.Errorf("Unexpected result %+v", result)
+		}
+
+		messages := history.GetMessages()
+		if len(messages) != 6 {
+			t.Fatalf("Expected system + summary + 4 recent messages, got %d", len(messages))
+		}
+		if messages[0].Content != "You are helpful." || !isSummary(messages[1]) || !strings.HasSuffix(messages[1].Content, "ok") {
+			t.Errorf("Unexpected head of history: %+v", messages[:2])
+		}
+		if messages[2].Content != "question 3" || messages[5].Content != "answer 4" {
+			t.Errorf("Expected the 4 most recent messages to be kept, got %+v", messages[2:])
+		}
+		if !strings.Contains(summarized, "question 0") || strings.Contains(summarized, "question 3") {
+			t.Errorf("Unexpected summarization input: %s", summarized)
+		}
+	})
+
+	t.Run("summarization failure keeps history", func(t *testing.T) {
+		history := newHistory()
+		failing := &failingClient{Client: client, err: errors.New("boom")}
+		_, err := history.Compact(context.Background(), failing, CompactOptions{MaxTokens: 10})
+		if err == nil {
+			t.Fatal("Expected error")
+		}
+		if len(history.GetMessages()) != 11 {
+			t.Errorf("Expected history to be unchanged, got %d messages", len(history.GetMessages()))
+		}
+	})
+}
+
+// failingClient fails every Generate call
+type failingClient struct {
+	Client
+	err error
+}
+
+func (c *failingClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	return nil, c.err
+}
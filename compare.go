@@ -0,0 +1,297 @@
+package llm
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yhwhpe/llm-unified-client/embeddingutil"
+)
+
+// Scorer rates a response against the reference answer of its request,
+// from 0 (no match) to 1 (full match)
+type Scorer interface {
+	// Name labels the score in ComparisonResult.Scores and CSV columns
+	Name() string
+	Score(ctx context.Context, reference string, response *Response) (float64, error)
+}
+
+// CompareOptions configures CompareClients
+type CompareOptions struct {
+	// Concurrency is the number of calls in flight per client (0 = 1);
+	// clients are always called in parallel
+	Concurrency int
+
+	// References are the expected answers, aligned to requests; Scorers
+	// only run for requests with a non-empty reference
+	References []string
+
+	// Scorers rate every successful response (nil = none)
+	Scorers []Scorer
+
+	// Pricing prices responses (nil = the default table, see SetPricing).
+	// Responses of unpriced models have a zero CostUSD.
+	Pricing Pricing
+}
+
+// Comparison is the outcome of CompareClients, serializable to JSON or
+// written as CSV with WriteCSV
+type Comparison struct {
+	// Clients are the client names in column order (sorted)
+	Clients []string `json:"clients"`
+	// Scorers are the scorer names in the order of CompareOptions.Scorers
+	Scorers []string `json:"scorers,omitempty"`
+	// Results holds one row per request and one column per client:
+	// Results[i][j] is requests[i] sent to Clients[j]
+	Results [][]ComparisonResult `json:"results"`
+}
+
+// ComparisonResult is one request sent to one client
+type ComparisonResult struct {
+	Request int    `json:"request"`
+	Client  string `json:"client"`
+	// Response is nil when the call failed
+	Response *Response `json:"response,omitempty"`
+	// Err is the error of a failed call; Error is its message
+	Err     error         `json:"-"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency"`
+	Usage   Usage         `json:"usage"`
+	CostUSD float64       `json:"cost_usd"`
+	// Scores by scorer name; ScoreErrors holds the scorers that failed
+	Scores      map[string]float64 `json:"scores,omitempty"`
+	ScoreErrors map[string]string  `json:"score_errors,omitempty"`
+}
+
+// CompareClients sends every request to every client and returns the
+// responses side by side with their latency, usage, cost and scores. A
+// failing call is recorded in its result and never stops the others.
+func CompareClients(ctx context.Context, clients map[string]Client, requests []Request, opts CompareOptions) (*Comparison, error) {
+	if len(clients) == 0 {
+		return nil, errors.New("no clients to compare")
+	}
+	if opts.References != nil && len(opts.References) != len(requests) {
+		return nil, fmt.Errorf("got %d references for %d requests", len(opts.References), len(requests))
+	}
+	pricing := opts.Pricing
+	if pricing == nil {
+		pricing = DefaultPricing()
+	}
+
+	comparison := &Comparison{Results: make([][]ComparisonResult, len(requests))}
+	for name := range clients {
+		comparison.Clients = append(comparison.Clients, name)
+	}
+	sort.Strings(comparison.Clients)
+	for _, scorer := range opts.Scorers {
+		comparison.Scorers = append(comparison.Scorers, scorer.Name())
+	}
+	for i := range requests {
+		comparison.Results[i] = make([]ComparisonResult, len(comparison.Clients))
+	}
+
+	var wg sync.WaitGroup
+	for j, name := range comparison.Clients {
+		client := clients[name]
+		slots := make(chan struct{}, max(opts.Concurrency, 1))
+		for i, request := range requests {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				select {
+				case slots <- struct{}{}:
+				case <-ctx.Done():
+					comparison.Results[i][j] = ComparisonResult{Request: i, Client: name, Err: ctx.Err(), Error: ctx.Err().Error()}
+					return
+				}
+				defer func() { <-slots }()
+
+				result := ComparisonResult{Request: i, Client: name}
+				startTime := time.Now()
+				response, err := client.Generate(ctx, request)
+				result.Latency = time.Since(startTime)
+				if err != nil {
+					result.Err, result.Error = err, err.Error()
+				} else {
+					result.Response = response
+					result.Usage = response.Usage
+					if cost, err := pricing.Estimate(response, nil); err == nil {
+						result.CostUSD = cost.USD
+					}
+					if opts.References != nil && opts.References[i] != "" {
+						scoreResult(ctx, &result, opts.Scorers, opts.References[i])
+					}
+				}
+				comparison.Results[i][j] = result
+			}()
+		}
+	}
+	wg.Wait()
+	return comparison, nil
+}
+
+// scoreResult runs scorers on a successful result
+func scoreResult(ctx context.Context, result *ComparisonResult, scorers []Scorer, reference string) {
+	for _, scorer := range scorers {
+		score, err := scorer.Score(ctx, reference, result.Response)
+		if err != nil {
+			if result.ScoreErrors == nil {
+				result.ScoreErrors = make(map[string]string)
+			}
+			result.ScoreErrors[scorer.Name()] = err.Error()
+			continue
+		}
+		if result.Scores == nil {
+			result.Scores = make(map[string]float64)
+		}
+		result.Scores[scorer.Name()] = score
+	}
+}
+
+// Result returns the result of requests[request] sent to the named client
+func (c *Comparison) Result(request int, client string) (ComparisonResult, bool) {
+	if request < 0 || request >= len(c.Results) {
+		return ComparisonResult{}, false
+	}
+	for _, result := range c.Results[request] {
+		if result.Client == client {
+			return result, true
+		}
+	}
+	return ComparisonResult{}, false
+}
+
+// WriteCSV writes one line per request and client, with a column per scorer
+func (c *Comparison) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	header := []string{"request", "client", "latency_ms", "prompt_tokens", "completion_tokens", "total_tokens", "cost_usd", "finish_reason", "error", "content"}
+	if err := writer.Write(append(header, c.Scorers...)); err != nil {
+		return err
+	}
+	for _, row := range c.Results {
+		for _, result := range row {
+			var finishReason, content string
+			if result.Response != nil {
+				finishReason, content = string(result.Response.FinishReason), result.Response.Content
+			}
+			record := []string{
+				strconv.Itoa(result.Request),
+				result.Client,
+				strconv.FormatInt(result.Latency.Milliseconds(), 10),
+				strconv.Itoa(result.Usage.PromptTokens),
+				strconv.Itoa(result.Usage.CompletionTokens),
+				strconv.Itoa(result.Usage.TotalTokens),
+				strconv.FormatFloat(result.CostUSD, 'f', -1, 64),
+				finishReason,
+				result.Error,
+				content,
+			}
+			for _, name := range c.Scorers {
+				score := ""
+				if value, ok := result.Scores[name]; ok {
+					score = strconv.FormatFloat(value, 'f', -1, 64)
+				}
+				record = append(record, score)
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExactMatch scores 1 when the response equals the reference, ignoring
+// surrounding whitespace, and 0 otherwise
+func ExactMatch() Scorer {
+	return exactMatch{}
+}
+
+// exactMatch is the Scorer returned by ExactMatch
+type exactMatch struct{}
+
+func (exactMatch) Name() string { return "exact_match" }
+
+func (exactMatch) Score(ctx context.Context, reference string, response *Response) (float64, error) {
+	if strings.TrimSpace(response.Content) == strings.TrimSpace(reference) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// JSONFieldMatch scores the fraction of fields whose value in the response
+// equals the one in the reference, both read as JSON objects (code fences
+// around the response are ignored). Without fields every field of the
+// reference is compared. A response that is not a JSON object scores 0.
+func JSONFieldMatch(fields ...string) Scorer {
+	return jsonFieldMatch{fields: fields}
+}
+
+// jsonFieldMatch is the Scorer returned by JSONFieldMatch
+type jsonFieldMatch struct {
+	fields []string
+}
+
+func (jsonFieldMatch) Name() string { return "json_field_match" }
+
+func (s jsonFieldMatch) Score(ctx context.Context, reference string, response *Response) (float64, error) {
+	var want map[string]interface{}
+	if err := json.Unmarshal([]byte(reference), &want); err != nil {
+		return 0, fmt.Errorf("reference is not a JSON object: %w", err)
+	}
+	fields := s.fields
+	if len(fields) == 0 {
+		for field := range want {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return 0, errors.New("no JSON fields to compare")
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(StripCodeFences(response.Content)), &got); err != nil {
+		return 0, nil
+	}
+	matched := 0
+	for _, field := range fields {
+		value, ok := got[field]
+		if ok && reflect.DeepEqual(value, want[field]) {
+			matched++
+		}
+	}
+	return float64(matched) / float64(len(fields)), nil
+}
+
+// EmbeddingSimilarity scores the cosine similarity between the embeddings
+// of the response and of the reference, created with client
+func EmbeddingSimilarity(client Client) Scorer {
+	return embeddingSimilarity{client: client}
+}
+
+// embeddingSimilarity is the Scorer returned by EmbeddingSimilarity
+type embeddingSimilarity struct {
+	client Client
+}
+
+func (embeddingSimilarity) Name() string { return "embedding_similarity" }
+
+func (s embeddingSimilarity) Score(ctx context.Context, reference string, response *Response) (float64, error) {
+	embeddings, err := s.client.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{reference, response.Content}})
+	if err != nil {
+		return 0, err
+	}
+	if len(embeddings.Embeddings) != 2 {
+		return 0, fmt.Errorf("expected 2 embeddings, got %d", len(embeddings.Embeddings))
+	}
+	return embeddingutil.CosineSimilarity(embeddings.Embeddings[0], embeddings.Embeddings[1])
+}
@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+// vocabularyEmbedder embeds texts as counts of a fixed vocabulary
+type vocabularyEmbedder struct {
+	Client
+}
+
+func (vocabularyEmbedder) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	response := &EmbeddingResponse{}
+	for _, text := range request.Input {
+		vector := make([]float64, 3)
+		for i, word := range []string{"paris", "rome", "capital"} {
+			vector[i] = float64(strings.Count(strings.ToLower(text), word))
+		}
+		response.Embeddings = append(response.Embeddings, vector)
+	}
+	return response, nil
+}
+
+func TestCompareClients(t *testing.T) {
+	answer := func(replies map[string]string) Client {
+		return &scriptedClient{reply: func(request Request) (*Response, error) {
+			reply, ok := replies[request.Messages[0].Content]
+			if !ok {
+				return nil, errors.New("provider down")
+			}
+			return &Response{Content: reply, Provider: ProviderOpenAI, Model: "gpt-4o",
+				Usage: Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100}}, nil
+		}}
+	}
+	clients := map[string]Client{
+		"good": answer(map[string]string{"capital": "Paris", "json": "```json\n{\"city\": \"Paris\", \"country\": \"France\"}\n```"}),
+		"bad":  answer(map[string]string{"capital": "Rome is the capital", "json": `{"city": "Paris", "country": "Italy"}`}),
+		"down": answer(nil),
+	}
+	requests := []Request{BuildSimpleRequest("capital"), BuildSimpleRequest("json")}
+	comparison, err := CompareClients(context.Background(), clients, requests, CompareOptions{
+		Concurrency: 2,
+		References:  []string{"Paris", `{"city": "Paris", "country": "France"}`},
+		Scorers:     []Scorer{ExactMatch(), JSONFieldMatch(), EmbeddingSimilarity(vocabularyEmbedder{})},
+	})
+	if err != nil {
+		t.Fatalf("CompareClients failed: %v", err)
+	}
+
+	if strings.Join(comparison.Clients, ",") != "bad,down,good" || len(comparison.Results) != 2 {
+		t.Fatalf("Unexpected matrix %v x %d", comparison.Clients, len(comparison.Results))
+	}
+	down, _ := comparison.Result(0, "down")
+	if down.Err == nil || down.Error != "provider down" || down.Response != nil {
+		t.Errorf("Expected the failure to be recorded, got %+v", down)
+	}
+	good, _ := comparison.Result(0, "good")
+	if good.Scores["exact_match"] != 1 || good.Scores["embedding_similarity"] < 0.99 || math.Abs(good.CostUSD-0.0035) > 1e-12 {
+		t.Errorf("Unexpected result %+v", good)
+	}
+	if _, ok := good.ScoreErrors["json_field_match"]; !ok {
+		t.Errorf("Expected a JSON scorer error for a plain-text reference, got %+v", good.ScoreErrors)
+	}
+	bad, _ := comparison.Result(0, "bad")
+	if bad.Scores["exact_match"] != 0 || bad.Scores["embedding_similarity"] > 0.9 {
+		t.Errorf("Expected low scores, got %+v", bad.Scores)
+	}
+	if good, _ := comparison.Result(1, "good"); good.Scores["json_field_match"] != 1 {
+		t.Errorf("Expected fenced JSON to match, got %+v", good.Scores)
+	}
+	if bad, _ := comparison.Result(1, "bad"); bad.Scores["json_field_match"] != 0.5 {
+		t.Errorf("Expected half the fields to match, got %+v", bad.Scores)
+	}
+
+	data, err := json.Marshal(comparison)
+	if err != nil || !strings.Contains(string(data), `"error":"provider down"`) {
+		t.Errorf("Expected the comparison to marshal with errors, got %s, %v", data, err)
+	}
+	var buf bytes.Buffer
+	if err := comparison.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil || len(records) != 7 || len(records[0]) != 13 || records[0][10] != "exact_match" {
+		t.Fatalf("Unexpected CSV %v, %v", records, err)
+	}
+	if records[3][1] != "good" || records[3][6] != "0.0035" || records[3][10] != "1" {
+		t.Errorf("Unexpected CSV row %v", records[3])
+	}
+}
@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+)
+
+// contextKey is the type for values this package stores in a context
+type contextKey int
+
+const (
+	idempotencyKeyContextKey contextKey = iota
+	requestIDContextKey
+	budgetKeyContextKey
+	timingContextKey
+	shadowContextKey
+	experimentKeyContextKey
+	variantContextKey
+	priorityContextKey
+	headersContextKey
+	attemptContextKey
+	hooksContextKey
+)
+
+// WithRequestID attaches a correlation ID to ctx. It is sent as X-Request-ID
+// and reported on Response, EmbeddingResponse and APIError. Calls without one
+// get a generated ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, id)
+}
+
+// RequestIDFromContext returns the ID set by WithRequestID
+func RequestIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDContextKey).(string)
+	return id, ok && id != ""
+}
+
+// WithBudgetKey attaches the key (for example a tenant ID) under which a
+// BudgetClient accounts calls made with the returned context
+func WithBudgetKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, budgetKeyContextKey, key)
+}
+
+// BudgetKeyFromContext returns the key set by WithBudgetKey
+func BudgetKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(budgetKeyContextKey).(string)
+	return key, ok && key != ""
+}
+
+// WithExperimentKey attaches the key (for example a user ID) that an
+// ABClient hashes to assign calls made with the returned context to a
+// variant, so that the same key always gets the same variant
+func WithExperimentKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, experimentKeyContextKey, key)
+}
+
+// ExperimentKeyFromContext returns the key set by WithExperimentKey
+func ExperimentKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(experimentKeyContextKey).(string)
+	return key, ok && key != ""
+}
+
+// WithVariant forces an ABClient to serve calls made with the returned
+// context from the named variant, regardless of their experiment key
+func WithVariant(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, variantContextKey, name)
+}
+
+// VariantFromContext returns the variant forced by WithVariant or, within
+// an ABClient call, the variant it chose
+func VariantFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(variantContextKey).(string)
+	return name, ok && name != ""
+}
+
+// WithPriority attaches the priority with which a SchedulerClient queues
+// calls made with the returned context
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey, priority)
+}
+
+// PriorityFromContext returns the priority set by WithPriority
+func PriorityFromContext(ctx context.Context) (Priority, bool) {
+	priority, ok := ctx.Value(priorityContextKey).(Priority)
+	return priority, ok
+}
+
+// WithHeaders attaches extra HTTP headers, for example gateway routing
+// hints, to every call made with the returned context, streams and retries
+// included. They are sent after Config.Headers and before Request.Headers,
+// and never replace the provider's authentication headers. Headers already
+// attached to ctx are kept unless headers sets them again.
+func WithHeaders(ctx context.Context, headers map[string]string) context.Context {
+	merged := make(map[string]string)
+	for k, v := range HeadersFromContext(ctx) {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	for k, v := range headers {
+		merged[http.CanonicalHeaderKey(k)] = v
+	}
+	return context.WithValue(ctx, headersContextKey, merged)
+}
+
+// HeadersFromContext returns the headers set by WithHeaders. The map must
+// not be modified.
+func HeadersFromContext(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(headersContextKey).(map[string]string)
+	return headers
+}
+
+// IsShadowCall reports whether ctx is that of a call mirrored by a
+// ShadowClient, for example to keep it out of billing in Config.OnUsage
+func IsShadowCall(ctx context.Context) bool {
+	shadow, _ := ctx.Value(shadowContextKey).(bool)
+	return shadow
+}
+
+// WithIdempotencyKey attaches an Idempotency-Key to ctx. Calls made with the
+// returned context send this key instead of generating one, which lets
+// retries across processes be deduplicated by the provider.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, idempotencyKeyContextKey, key)
+}
+
+// IdempotencyKeyFromContext returns the key set by WithIdempotencyKey
+func IdempotencyKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(idempotencyKeyContextKey).(string)
+	return key, ok && key != ""
+}
+
+// resolveIdempotencyKey picks the key for one logical request: the request
+// field, then the context, then a freshly generated UUID.
+func resolveIdempotencyKey(ctx context.Context, requestKey string) string {
+	if requestKey != "" {
+		return requestKey
+	}
+	if key, ok := IdempotencyKeyFromContext(ctx); ok {
+		return key
+	}
+	return newUUID()
+}
+
+// newUUID returns a random RFC 4122 version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("llm: failed to generate UUID: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrContinueJSONMode is returned when Request.ContinueOnLength is combined
+// with JSON mode without Request.ContinueJSON
+var ErrContinueJSONMode = errors.New("ContinueOnLength would stitch a JSON mode response from several segments")
+
+// continuationPrompt is the user message that asks the model to continue a
+// response cut off by the token limit
+const continuationPrompt = "Continue exactly where your previous message stopped, without repeating anything."
+
+// WithContinueOnLength re-issues a response cut off by the token limit up to
+// max times and stitches the segments together (see Request.ContinueOnLength)
+func WithContinueOnLength(max int) RequestOption {
+	return func(r *Request) {
+		r.ContinueOnLength = max
+	}
+}
+
+// generateContinued runs generate and, while the response stops with
+// FinishReason "length" and Request.ContinueOnLength allows it, continues it
+// by sending the partial answer back as an assistant message followed by
+// continuationPrompt. The returned response carries the stitched content,
+// the combined usage and the number of continuations.
+func generateContinued(ctx context.Context, request Request, generate generateFunc) (*Response, error) {
+	if request.ContinueOnLength > 0 && jsonMode(request) && !request.ContinueJSON {
+		return nil, ErrContinueJSONMode
+	}
+
+	response, err := generate(ctx, request)
+	if err != nil || request.ContinueOnLength <= 0 {
+		return response, err
+	}
+
+	messages := append([]Message(nil), request.Messages...)
+	for response.Continuations < request.ContinueOnLength && response.FinishReason == FinishLength {
+		n := response.Continuations + 1
+		messages = append(messages, Message{Role: RoleAssistant, Content: response.Content}, Message{Role: RoleUser, Content: continuationPrompt})
+		next := request
+		next.Messages = messages
+		next.IdempotencyKey = derivedIdempotencyKey(ctx, request.IdempotencyKey, fmt.Sprintf("continuation-%d", n))
+
+		segment, err := generate(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		// Later segments only need the latest partial answer
+		messages = messages[:len(messages)-2]
+		segment.Content = response.Content + segment.Content
+		segment.ReasoningContent = response.ReasoningContent + segment.ReasoningContent
+		segment.Usage = addUsage(response.Usage, segment.Usage)
+		segment.TokensUsed = segment.Usage.TotalTokens
+		segment.ResponseTime += response.ResponseTime
+		segment.Continuations = n
+		response = segment
+	}
+	return response, nil
+}
+
+// jsonMode reports whether request asks for JSON output via response_format
+func jsonMode(request Request) bool {
+	format, ok := request.ExtraParams["response_format"].(map[string]interface{})
+	return ok && format["type"] != "text"
+}
+
+// derivedIdempotencyKey returns the Idempotency-Key of a follow-up request
+// made on behalf of one logical request: the caller's key (request field or
+// context) with suffix appended, so the provider does not answer it from the
+// original request, or "" to generate a fresh one.
+func derivedIdempotencyKey(ctx context.Context, requestKey, suffix string) string {
+	if requestKey == "" {
+		requestKey, _ = IdempotencyKeyFromContext(ctx)
+	}
+	if requestKey == "" {
+		return ""
+	}
+	return requestKey + "-" + suffix
+}
@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveSegments returns a server answering with the given segments in turn,
+// each with finish_reason "length" except the last. Payloads and
+// Idempotency-Key headers are recorded.
+func serveSegments(t *testing.T, segments []string, payloads *[]map[string]interface{}, keys *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		i := len(*payloads)
+		*payloads = append(*payloads, payload)
+		*keys = append(*keys, r.Header.Get("Idempotency-Key"))
+		finishReason := "length"
+		if i == len(segments)-1 {
+			finishReason = "stop"
+		}
+		content, _ := json.Marshal(segments[i])
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %s}, "finish_reason": %q}],
+			"usage": {"prompt_tokens": 10, "completion_tokens": 5, "total_tokens": 15}}`, content, finishReason)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestContinueOnLength(t *testing.T) {
+	var payloads []map[string]interface{}
+	var keys []string
+	server := serveSegments(t, []string{"Once upon", " a time", " there was a fox."}, &payloads, &keys)
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	ctx := WithIdempotencyKey(context.Background(), "story-1")
+	response, err := client.Generate(ctx, NewRequest(WithUser("Tell a story"), WithMaxTokens(5), WithContinueOnLength(3)))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if response.Content != "Once upon a time there was a fox." || response.FinishReason != "stop" || response.Continuations != 2 {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if response.Usage != (Usage{PromptTokens: 30, CompletionTokens: 15, TotalTokens: 45}) || response.TokensUsed != 45 {
+		t.Errorf("Expected combined usage, got %+v", response.Usage)
+	}
+
+	if len(payloads) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(payloads))
+	}
+	messages, _ := payloads[2]["messages"].([]interface{})
+	if len(messages) != 3 {
+		t.Fatalf("Expected the partial answer and the continuation prompt, got %v", messages)
+	}
+	partial := messages[1].(map[string]interface{})
+	prompt := messages[2].(map[string]interface{})
+	if partial["role"] != "assistant" || partial["content"] != "Once upon a time" || prompt["role"] != "user" || prompt["content"] != continuationPrompt {
+		t.Errorf("Unexpected continuation messages %v", messages)
+	}
+	if keys[0] != "story-1" || keys[1] != "story-1-continuation-1" || keys[2] != "story-1-continuation-2" {
+		t.Errorf("Expected a distinct Idempotency-Key per segment, got %v", keys)
+	}
+
+	t.Run("limit", func(t *testing.T) {
+		var payloads []map[string]interface{}
+		var keys []string
+		server := serveSegments(t, []string{"Once upon", " a time", " there was a fox."}, &payloads, &keys)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+		response, err := client.Generate(context.Background(), NewRequest(WithUser("Tell a story"), WithContinueOnLength(1)))
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if response.Content != "Once upon a time" || response.FinishReason != "length" || response.Continuations != 1 || len(payloads) != 2 {
+			t.Errorf("Unexpected response %+v after %d requests", response, len(payloads))
+		}
+	})
+
+	t.Run("json mode", func(t *testing.T) {
+		var payloads []map[string]interface{}
+		var keys []string
+		server := serveSegments(t, []string{`{"name": "fo`, `x"}`}, &payloads, &keys)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+		request := NewRequest(WithUser("Extract the name"), WithJSONMode(), WithContinueOnLength(2))
+		if _, err := client.Generate(context.Background(), request); !errors.Is(err, ErrContinueJSONMode) || len(payloads) != 0 {
+			t.Errorf("Expected ErrContinueJSONMode without a request, got %v", err)
+		}
+
+		request.ContinueJSON = true
+		response, err := client.Generate(context.Background(), request)
+		if err != nil || response.Content != `{"name": "fox"}` {
+			t.Errorf("Expected the stitched JSON, got %v, %v", response, err)
+		}
+	})
+}
@@ -0,0 +1,166 @@
+package llm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// debugMu serializes dump writes so concurrent calls don't interleave
+var debugMu sync.Mutex
+
+// sensitiveHeaders are masked in debug dumps regardless of their value
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Api-Key":             true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// clientHeaders are the request headers the client sets itself, shown in
+// debug dumps as is. Every other request header may carry a gateway token,
+// so headers from Config.Headers and Request.Headers are masked; only
+// those from WithHeaders stay readable.
+var clientHeaders = map[string]bool{
+	"Accept":           true,
+	"Accept-Encoding":  true,
+	"Content-Encoding": true,
+	"Content-Type":     true,
+	"Idempotency-Key":  true,
+	"X-Dashscope-Sse":  true,
+	"X-Request-Id":     true,
+}
+
+// dumpRequest writes the outgoing method, URL, headers and body to
+// Config.DebugWriter. The body is read through GetBody, so the request itself
+// is left untouched.
+func dumpRequest(config Config, req *http.Request) {
+	if config.DebugWriter == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, ">>> %s %s\n", req.Method, req.URL.Redacted())
+	writeHeaders(&buf, config, req.Header, requestHeaderMasked(config, req))
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			payload, err := decodeDebugBody(body, req.Header.Get("Content-Encoding"))
+			if err != nil {
+				fmt.Fprintf(&buf, "(unreadable body: %v)\n", err)
+			} else {
+				buf.WriteString(redactSecrets(config, string(payload)))
+				buf.WriteString("\n")
+			}
+		}
+	}
+	writeDebug(config.DebugWriter, buf.Bytes())
+}
+
+// dumpResponse writes the response status, headers and (decoded) body to
+// Config.DebugWriter
+func dumpResponse(config Config, resp *http.Response, body []byte) {
+	if config.DebugWriter == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	requestID := ""
+	if resp.Request != nil {
+		requestID = resp.Request.Header.Get(requestIDHeader)
+	}
+	fmt.Fprintf(&buf, "<<< %s (request %s)\n", resp.Status, requestID)
+	writeHeaders(&buf, config, resp.Header, func(name, value string) bool { return sensitiveHeaders[name] })
+	buf.WriteString(redactSecrets(config, string(body)))
+	buf.WriteString("\n")
+	writeDebug(config.DebugWriter, buf.Bytes())
+}
+
+// requestHeaderMasked returns whether a header of req is masked in debug
+// dumps: credentials always, and anything that may come from Config.Headers
+// or Request.Headers, that is every header except the client's own and the
+// values set by WithHeaders
+func requestHeaderMasked(config Config, req *http.Request) func(name, value string) bool {
+	configured := make(map[string]bool, len(config.Headers))
+	for k := range config.Headers {
+		configured[http.CanonicalHeaderKey(k)] = true
+	}
+	contextHeaders := HeadersFromContext(req.Context())
+	return func(name, value string) bool {
+		if sensitiveHeaders[name] {
+			return true
+		}
+		if v, ok := contextHeaders[name]; ok && v == value {
+			return false
+		}
+		return !clientHeaders[name] || configured[name]
+	}
+}
+
+// dumpEvent writes one server-sent event of a streamed response to
+// Config.DebugWriter, tagged with the X-Request-ID of its request. Events of
+// one stream are written in order, each in one piece.
+func dumpEvent(config Config, requestID string, event sseEvent) {
+	if config.DebugWriter == nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<<< event (request %s)\n", requestID)
+	if event.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", event.Event)
+	}
+	for _, line := range strings.Split(redactSecrets(config, string(event.Data)), "\n") {
+		fmt.Fprintf(&buf, "data: %s\n", line)
+	}
+	buf.WriteString("\n")
+	writeDebug(config.DebugWriter, buf.Bytes())
+}
+
+// writeHeaders writes headers in sorted order, masking the values masked
+// reports and redacting secrets from the others
+func writeHeaders(buf *bytes.Buffer, config Config, header http.Header, masked func(name, value string) bool) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			if masked(k, v) {
+				v = maskSecret(v)
+			} else {
+				v = redactSecrets(config, v)
+			}
+			fmt.Fprintf(buf, "%s: %s\n", k, v)
+		}
+	}
+	buf.WriteString("\n")
+}
+
+// decodeDebugBody reads a request body, undoing gzip compression
+func decodeDebugBody(body io.ReadCloser, encoding string) ([]byte, error) {
+	defer body.Close()
+	if encoding != "gzip" {
+		return io.ReadAll(body)
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// writeDebug writes one complete dump entry under debugMu
+func writeDebug(w io.Writer, p []byte) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+	w.Write(p)
+}
@@ -0,0 +1,171 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultsSetter is implemented by the provider clients. Its methods change
+// the default model and sampling parameters of a client while it serves
+// requests, e.g. to fall back to a cheaper model during an incident. Calls
+// already started keep the values they began with. Every change is logged
+// and passed to the Config.ConfigChanged hooks.
+type DefaultsSetter interface {
+	// SetDefaultModel replaces Config.DefaultModel
+	SetDefaultModel(model string) error
+	// SetDefaultTemperature replaces Config.DefaultTemperature (nil = the
+	// provider's default); SetDefaultMaxTokens, SetDefaultTopP and
+	// SetDefaultTopK work the same way
+	SetDefaultTemperature(temperature *float64)
+	SetDefaultMaxTokens(maxTokens *int)
+	SetDefaultTopP(topP *float64)
+	SetDefaultTopK(topK *int)
+}
+
+// ConfigChange describes a default changed through a DefaultsSetter
+type ConfigChange struct {
+	Provider Provider
+	// Field is the name of the Config field, e.g. "DefaultModel"
+	Field string
+	// Old and New are the values before and after the change, nil for an
+	// unset parameter
+	Old, New interface{}
+}
+
+// ConfigChangeHook observes a change made through a DefaultsSetter
+type ConfigChangeHook func(change ConfigChange)
+
+// withConfigDefaults returns request with Config.DefaultSystemPrompt as its
+// system prompt when it has no system message, and Config.DefaultExtraParams
+// merged under its ExtraParams. The caller's messages and map are not
+// modified.
+func withConfigDefaults(config Config, request Request) Request {
+	if config.DefaultSystemPrompt != "" && request.SystemPrompt == "" && !hasSystemMessage(request.Messages) {
+		request.SystemPrompt = config.DefaultSystemPrompt
+	}
+	if len(config.DefaultExtraParams) > 0 {
+		extra := make(map[string]interface{}, len(config.DefaultExtraParams)+len(request.ExtraParams))
+		for key, value := range config.DefaultExtraParams {
+			extra[key] = value
+		}
+		for key, value := range request.ExtraParams {
+			extra[key] = value
+		}
+		request.ExtraParams = extra
+	}
+	return request
+}
+
+// hasSystemMessage reports whether messages contain a system message
+func hasSystemMessage(messages []Message) bool {
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			return true
+		}
+	}
+	return false
+}
+
+// liveConfig holds the current configuration of a provider client. Calls
+// take a snapshot with load, so one call sees one consistent
+// configuration; the DefaultsSetter methods publish a changed copy.
+type liveConfig struct {
+	mu    sync.Mutex // serializes changes
+	value atomic.Pointer[Config]
+}
+
+// newLiveConfig returns a liveConfig starting at config
+func newLiveConfig(config Config) *liveConfig {
+	live := &liveConfig{}
+	live.value.Store(&config)
+	return live
+}
+
+// load returns the current configuration. Its pointers and maps are never
+// modified once published, so the copy is safe to read.
+func (l *liveConfig) load() Config {
+	return *l.value.Load()
+}
+
+// SetDefaultModel replaces the default model for subsequent calls
+func (l *liveConfig) SetDefaultModel(model string) error {
+	if model == "" {
+		return errors.New("default model must not be empty")
+	}
+	l.update("DefaultModel", func(config *Config) (interface{}, interface{}) {
+		old := config.DefaultModel
+		config.DefaultModel = model
+		return old, model
+	})
+	return nil
+}
+
+// SetDefaultTemperature replaces the default temperature for subsequent calls
+func (l *liveConfig) SetDefaultTemperature(temperature *float64) {
+	setDefault(l, "DefaultTemperature", func(config *Config) **float64 { return &config.DefaultTemperature }, temperature)
+}
+
+// SetDefaultMaxTokens replaces the default max tokens for subsequent calls
+func (l *liveConfig) SetDefaultMaxTokens(maxTokens *int) {
+	setDefault(l, "DefaultMaxTokens", func(config *Config) **int { return &config.DefaultMaxTokens }, maxTokens)
+}
+
+// SetDefaultTopP replaces the default top_p for subsequent calls
+func (l *liveConfig) SetDefaultTopP(topP *float64) {
+	setDefault(l, "DefaultTopP", func(config *Config) **float64 { return &config.DefaultTopP }, topP)
+}
+
+// SetDefaultTopK replaces the default top_k for subsequent calls
+func (l *liveConfig) SetDefaultTopK(topK *int) {
+	setDefault(l, "DefaultTopK", func(config *Config) **int { return &config.DefaultTopK }, topK)
+}
+
+// setDefault replaces the optional parameter field selects with a copy of
+// value, so later changes to the caller's variable have no effect
+func setDefault[T comparable](l *liveConfig, name string, field func(*Config) **T, value *T) {
+	value = clonePtr(value)
+	l.update(name, func(config *Config) (interface{}, interface{}) {
+		target := field(config)
+		old := *target
+		*target = value
+		return ptrValue(old), ptrValue(value)
+	})
+}
+
+// ptrValue returns *p, or nil when p is nil
+func ptrValue[T any](p *T) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// update publishes a copy of the configuration changed by set, which
+// returns the old and new value of the field, and reports the change
+// unless the value stayed the same
+func (l *liveConfig) update(field string, set func(*Config) (interface{}, interface{})) {
+	l.mu.Lock()
+	config := *l.value.Load()
+	old, value := set(&config)
+	l.value.Store(&config)
+	l.mu.Unlock()
+
+	if old == value {
+		return
+	}
+	change := ConfigChange{Provider: config.Provider, Field: field, Old: old, New: value}
+	if config.Logger != nil {
+		config.Logger.LogAttrs(context.Background(), slog.LevelInfo, "llm: default changed",
+			slog.String("provider", string(change.Provider)),
+			slog.String("field", field),
+			slog.Any("old", old),
+			slog.Any("new", value),
+		)
+	}
+	for _, hook := range config.ConfigChanged {
+		hook(change)
+	}
+}
@@ -0,0 +1,250 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// payloadServer answers chat calls and reports each payload's model and
+// temperature on sent
+func payloadServer(t *testing.T, sent func(model string, temperature *float64)) string {
+	t.Helper()
+	server := newChatServer(t, func(r *http.Request) {
+		var payload struct {
+			Model       string   `json:"model"`
+			Temperature *float64 `json:"temperature"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent(payload.Model, payload.Temperature)
+	})
+	return server.URL
+}
+
+func TestSetDefaults(t *testing.T) {
+	var model string
+	var temperature *float64
+	baseURL := payloadServer(t, func(m string, temp *float64) { model, temperature = m, temp })
+
+	var changes []ConfigChange
+	var logs bytes.Buffer
+	initial := 0.5
+	client, _ := NewClient(Config{
+		Provider:           ProviderOpenAI,
+		APIKey:             "test-key",
+		BaseURL:            baseURL,
+		DefaultModel:       "gpt-4o",
+		DefaultTemperature: &initial,
+		Logger:             slog.New(slog.NewTextHandler(&logs, nil)),
+		ConfigChanged:      []ConfigChangeHook{func(change ConfigChange) { changes = append(changes, change) }},
+	})
+	setter := client.(DefaultsSetter)
+
+	if err := setter.SetDefaultModel("gpt-4o-mini"); err != nil {
+		t.Fatalf("SetDefaultModel failed: %v", err)
+	}
+	lower := 0.2
+	setter.SetDefaultTemperature(&lower)
+	// the client keeps its own copy, and unchanged values are not reported
+	lower = 1
+	setter.SetDefaultMaxTokens(nil)
+
+	if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if model != "gpt-4o-mini" || temperature == nil || *temperature != 0.2 {
+		t.Errorf("Expected the new defaults to be sent, got %s and %v", model, temperature)
+	}
+	config := client.GetConfig()
+	if config.DefaultModel != "gpt-4o-mini" || *config.DefaultTemperature != 0.2 {
+		t.Errorf("Expected GetConfig to reflect the new defaults, got %s and %v", config.DefaultModel, *config.DefaultTemperature)
+	}
+
+	want := []ConfigChange{
+		{Provider: ProviderOpenAI, Field: "DefaultModel", Old: "gpt-4o", New: "gpt-4o-mini"},
+		{Provider: ProviderOpenAI, Field: "DefaultTemperature", Old: 0.5, New: 0.2},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+	if strings.Count(logs.String(), "llm: default changed") != 2 || !strings.Contains(logs.String(), "new=gpt-4o-mini") {
+		t.Errorf("Expected a log record per change, got %s", logs.String())
+	}
+
+	if err := setter.SetDefaultModel(""); err == nil {
+		t.Error("Expected an empty model to be rejected")
+	}
+}
+
+func TestDefaultsSetterProviders(t *testing.T) {
+	for _, provider := range []Provider{ProviderOpenAI, ProviderDeepSeek, ProviderQwen, ProviderAzure, ProviderCohere, ProviderJina, ProviderGemini} {
+		client, err := NewClient(Config{Provider: provider, APIKey: "test-key", BaseURL: "https://example.openai.azure.com/openai/deployments/gpt-4o"})
+		if err != nil {
+			t.Fatalf("%s: %v", provider, err)
+		}
+		setter, ok := client.(DefaultsSetter)
+		if !ok {
+			t.Errorf("%s client does not implement DefaultsSetter", provider)
+			continue
+		}
+		setter.SetDefaultModel("swapped")
+		if got := client.GetConfig().DefaultModel; got != "swapped" {
+			t.Errorf("%s: DefaultModel = %q after SetDefaultModel", provider, got)
+		}
+	}
+}
+
+// TestSetDefaultsRace swaps defaults while requests are in flight; run with
+// -race
+func TestSetDefaultsRace(t *testing.T) {
+	var mu sync.Mutex
+	models := map[string]int{}
+	baseURL := payloadServer(t, func(model string, _ *float64) {
+		mu.Lock()
+		models[model]++
+		mu.Unlock()
+	})
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: baseURL, DefaultModel: "gpt-4o"})
+	setter := client.(DefaultsSetter)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); err != nil {
+				t.Errorf("Generate failed: %v", err)
+			}
+		}()
+		go func(i int) {
+			defer wg.Done()
+			temperature := float64(i) / 10
+			setter.SetDefaultTemperature(&temperature)
+			if i%2 == 0 {
+				setter.SetDefaultModel("gpt-4o-mini")
+			} else {
+				setter.SetDefaultModel("gpt-4o")
+			}
+			client.GetConfig()
+		}(i)
+	}
+	wg.Wait()
+
+	for model := range models {
+		if model != "gpt-4o" && model != "gpt-4o-mini" {
+			t.Errorf("Unexpected model %q", model)
+		}
+	}
+}
+
+func TestConfigDefaultsPrecedence(t *testing.T) {
+	var sent map[string]interface{}
+	server := newChatServer(t, func(r *http.Request) {
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+	})
+	defaultExtra := map[string]interface{}{"enable_thinking": false, "seed": 1}
+	client, _ := NewClient(Config{
+		Provider:            ProviderOpenAI,
+		APIKey:              "test-key",
+		BaseURL:             server.URL,
+		DefaultSystemPrompt: "Follow the compliance policy.",
+		DefaultExtraParams:  defaultExtra,
+		SystemMessagePolicy: SystemMessagesError,
+	})
+
+	withSystem := BuildSimpleRequest("Hi")
+	withSystem.AddSystemMessage("Be brief.")
+	withPrompt := BuildSimpleRequest("Hi")
+	withPrompt.SystemPrompt = "Be terse."
+	history := ChatHistory{}
+	history.AddSystemMessage("You are a pirate.")
+	history.AddUserMessage("Ahoy")
+	history.AddAssistantMessage("Arr")
+
+	tests := []struct {
+		name   string
+		call   func() (*Response, error)
+		system string
+		extra  map[string]interface{}
+	}{
+		{
+			name:   "no system message",
+			call:   func() (*Response, error) { return client.Generate(context.Background(), BuildSimpleRequest("Hi")) },
+			system: "Follow the compliance policy.",
+			extra:  map[string]interface{}{"enable_thinking": false, "seed": float64(1)},
+		},
+		{
+			name:   "AddSystemMessage",
+			call:   func() (*Response, error) { return client.Generate(context.Background(), withSystem) },
+			system: "Be brief.",
+			extra:  map[string]interface{}{"enable_thinking": false, "seed": float64(1)},
+		},
+		{
+			name:   "Request.SystemPrompt",
+			call:   func() (*Response, error) { return client.Generate(context.Background(), withPrompt) },
+			system: "Be terse.",
+			extra:  map[string]interface{}{"enable_thinking": false, "seed": float64(1)},
+		},
+		{
+			name: "request extra params win",
+			call: func() (*Response, error) {
+				return client.Generate(context.Background(), NewRequest(WithUser("Hi"), WithExtraParam("seed", 2), WithJSONMode()))
+			},
+			system: "Follow the compliance policy.",
+			extra:  map[string]interface{}{"enable_thinking": false, "seed": float64(2), "response_format": map[string]interface{}{"type": "json_object"}},
+		},
+		{
+			name: "GenerateWithHistory system prompt",
+			call: func() (*Response, error) {
+				return client.GenerateWithHistory(context.Background(), ChatHistory{}, "Hi", "Answer in French.")
+			},
+			system: "Answer in French.",
+			extra:  map[string]interface{}{"enable_thinking": false, "seed": float64(1)},
+		},
+		{
+			name:   "GenerateWithHistory history system message",
+			call:   func() (*Response, error) { return client.GenerateWithHistory(context.Background(), history, "Hi", "") },
+			system: "You are a pirate.",
+			extra:  map[string]interface{}{"enable_thinking": false, "seed": float64(1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.call(); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			var systems []string
+			for _, msg := range sent["messages"].([]interface{}) {
+				if msg := msg.(map[string]interface{}); msg["role"] == "system" {
+					systems = append(systems, msg["content"].(string))
+				}
+			}
+			if len(systems) != 1 || systems[0] != tt.system {
+				t.Errorf("system messages = %q, want [%q]", systems, tt.system)
+			}
+			for key, want := range tt.extra {
+				if !reflect.DeepEqual(sent[key], want) {
+					t.Errorf("%s = %v, want %v", key, sent[key], want)
+				}
+			}
+		})
+	}
+
+	if len(defaultExtra) != 2 || defaultExtra["seed"] != 1 {
+		t.Errorf("Expected the config's map to be left alone, got %v", defaultExtra)
+	}
+	if len(withSystem.Messages) != 2 || withSystem.ExtraParams != nil {
+		t.Errorf("Expected the caller's request to be left alone, got %+v", withSystem)
+	}
+	bare := BuildSimpleRequest("Hi")
+	if client.CountTokens(bare) <= countRequestTokens(Config{}, "gpt-3.5-turbo", bare) {
+		t.Error("Expected CountTokens to include the default system prompt")
+	}
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// modelPrefixes maps model name prefixes to the providers serving them.
+// The longest matching prefix wins; entries with several providers are
+// ambiguous and need Config.Provider.
+var modelPrefixes = []struct {
+	prefix    string
+	providers []Provider
+}{
+	{"gpt-", []Provider{ProviderOpenAI}},
+	{"chatgpt-", []Provider{ProviderOpenAI}},
+	{"o1", []Provider{ProviderOpenAI}},
+	{"o3", []Provider{ProviderOpenAI}},
+	{"o4", []Provider{ProviderOpenAI}},
+	{"text-embedding-3-", []Provider{ProviderOpenAI}},
+	{"text-embedding-ada-", []Provider{ProviderOpenAI}},
+	{"deepseek-", []Provider{ProviderDeepSeek}},
+	// DashScope hosts DeepSeek's open models under their release names
+	{"deepseek-r1", []Provider{ProviderDeepSeek, ProviderQwen}},
+	{"deepseek-v3", []Provider{ProviderDeepSeek, ProviderQwen}},
+	{"qwen", []Provider{ProviderQwen}},
+	{"qwq-", []Provider{ProviderQwen}},
+	{"text-embedding-v", []Provider{ProviderQwen}},
+	{"command", []Provider{ProviderCohere}},
+	{"embed-", []Provider{ProviderCohere}},
+	{"rerank-", []Provider{ProviderCohere}},
+	{"jina-", []Provider{ProviderJina}},
+	{"gemini-", []Provider{ProviderGemini}},
+	{"text-embedding-004", []Provider{ProviderGemini}},
+}
+
+// DetectProvider returns the provider serving model, judged by its name.
+// It reports false for unknown models and for models several providers serve.
+func DetectProvider(model string) (Provider, bool) {
+	candidates := providerCandidates(model)
+	if len(candidates) != 1 {
+		return "", false
+	}
+	return candidates[0], true
+}
+
+// providerCandidates returns the providers of the longest matching prefix
+func providerCandidates(model string) []Provider {
+	model = strings.ToLower(strings.TrimSpace(model))
+	var best []Provider
+	bestLen := 0
+	for _, entry := range modelPrefixes {
+		if strings.HasPrefix(model, entry.prefix) && len(entry.prefix) > bestLen {
+			best, bestLen = entry.providers, len(entry.prefix)
+		}
+	}
+	return best
+}
+
+// detectConfigProvider fills an empty Config.Provider from DefaultModel
+func detectConfigProvider(config Config) (Config, error) {
+	if config.Provider != "" {
+		return config, nil
+	}
+	if config.DefaultModel == "" {
+		return config, fmt.Errorf("provider is required when no default model is set")
+	}
+
+	candidates := providerCandidates(config.DefaultModel)
+	switch len(candidates) {
+	case 0:
+		return config, fmt.Errorf("cannot detect provider for model %q; set Config.Provider", config.DefaultModel)
+	case 1:
+		config.Provider = candidates[0]
+		return config, nil
+	default:
+		names := make([]string, len(candidates))
+		for i, p := range candidates {
+			names[i] = string(p)
+		}
+		return config, fmt.Errorf("model %q is served by several providers (%s); set Config.Provider",
+			config.DefaultModel, strings.Join(names, ", "))
+	}
+}
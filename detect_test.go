@@ -0,0 +1,52 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectProvider(t *testing.T) {
+	tests := []struct {
+		model string
+		want  Provider
+		ok    bool
+	}{
+		{"gpt-4o-mini", ProviderOpenAI, true},
+		{"o3-mini", ProviderOpenAI, true},
+		{"text-embedding-3-small", ProviderOpenAI, true},
+		{"deepseek-chat", ProviderDeepSeek, true},
+		{"qwen-plus", ProviderQwen, true},
+		{"qwen3-next-80b-a3b-instruct", ProviderQwen, true},
+		{"text-embedding-v3", ProviderQwen, true},
+		{"command-r-plus", ProviderCohere, true},
+		{"embed-multilingual-v3.0", ProviderCohere, true},
+		{"Command-R", ProviderCohere, true},
+		{"deepseek-r1", "", false},
+		{"llama-3-70b", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := DetectProvider(tt.model)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("DetectProvider(%q) = %q, %v; want %q, %v", tt.model, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestNewClientDetectsProvider(t *testing.T) {
+	client, err := NewClient(Config{APIKey: "test-key", DefaultModel: "deepseek-chat"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.GetConfig().Provider != ProviderDeepSeek {
+		t.Errorf("Expected detected provider %s, got %s", ProviderDeepSeek, client.GetConfig().Provider)
+	}
+
+	_, err = NewClient(Config{APIKey: "test-key", DefaultModel: "deepseek-r1"})
+	if err == nil || !strings.Contains(err.Error(), "deepseek, qwen") {
+		t.Errorf("Expected an ambiguity error listing candidates, got %v", err)
+	}
+	_, err = NewClient(Config{APIKey: "test-key", DefaultModel: "llama-3-70b"})
+	if err == nil || !strings.Contains(err.Error(), "cannot detect provider") {
+		t.Errorf("Expected an unknown-model error, got %v", err)
+	}
+}
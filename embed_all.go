@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EmbedProgress is passed to EmbedAllOptions.OnProgress after every chunk
+type EmbedProgress struct {
+	// Completed and Failed count inputs; Total is len(texts)
+	Completed int
+	Failed    int
+	Total     int
+	// Tokens is the usage billed so far
+	Tokens int
+}
+
+// EmbedAllOptions configures EmbedAll
+type EmbedAllOptions struct {
+	// Model and Dimensions are passed on every EmbeddingRequest
+	Model      *string
+	Dimensions *int
+
+	// BatchSize is the number of inputs per call (0 = the client's
+	// Capabilities().MaxEmbeddingBatch, or 100 if it reports none)
+	BatchSize int
+
+	// Workers is the number of calls in flight (0 = 1)
+	Workers int
+
+	// Limiter paces calls by request and estimated token count (nil = unlimited)
+	Limiter *RateLimiter
+
+	// MaxRetries is how often a chunk is retried after a transient error
+	// such as a rate limit or 5xx (0 = 3, negative = never), waiting
+	// RetryBackoff (0 = 1s) and doubling after each attempt up to a minute
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// OnProgress is called after every chunk, never concurrently (nil = none)
+	OnProgress func(progress EmbedProgress)
+}
+
+// EmbedAll embeds texts in chunks with opts.Workers concurrent calls and
+// returns the vectors aligned to texts. It is meant for backfills: a chunk
+// that still fails after its retries is reported in an *EmbeddingBatchError
+// while the others carry on, and when ctx ends EmbedAll stops promptly and
+// returns what finished together with ctx's error. Inputs without a vector
+// are nil, so a backfill can checkpoint and resume from them.
+func EmbedAll(ctx context.Context, client Client, texts []string, opts EmbedAllOptions) ([][]float64, error) {
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = client.Capabilities().MaxEmbeddingBatch
+	}
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	workers := max(opts.Workers, 1)
+
+	config := client.GetConfig()
+	tokenizer := tokenizerFor(config)
+	model := resolveEmbeddingModel(config, opts.Model)
+
+	type chunk struct{ start, end int }
+	chunks := make(chan chunk)
+	go func() {
+		defer close(chunks)
+		for start := 0; start < len(texts); start += batchSize {
+			select {
+			case chunks <- chunk{start, min(start+batchSize, len(texts))}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	vectors := make([][]float64, len(texts))
+	var mu sync.Mutex
+	progress := EmbedProgress{Total: len(texts)}
+	batchErr := &EmbeddingBatchError{Inputs: len(texts)}
+
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := range chunks {
+				if ctx.Err() != nil {
+					return
+				}
+				input := texts[c.start:c.end]
+				tokens := 0
+				for _, text := range input {
+					tokens += tokenizer.CountTokens(model, text)
+				}
+				request := EmbeddingRequest{Input: input, Model: opts.Model, Dimensions: opts.Dimensions}
+				response, err := embedChunk(ctx, client, request, tokens, opts)
+				if err != nil && ctx.Err() != nil {
+					// cancelled mid-call: not a failure, just unfinished
+					return
+				}
+
+				mu.Lock()
+				if err != nil {
+					batchErr.Failures = append(batchErr.Failures, EmbeddingChunkError{Start: c.start, End: c.end, Err: err})
+					progress.Failed += c.end - c.start
+				} else {
+					copy(vectors[c.start:c.end], response.Embeddings)
+					progress.Completed += c.end - c.start
+					progress.Tokens += response.Usage.TotalTokens
+				}
+				if opts.OnProgress != nil {
+					opts.OnProgress(progress)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	if ctx.Err() != nil {
+		errs = append(errs, ctx.Err())
+	}
+	if len(batchErr.Failures) > 0 {
+		errs = append(errs, batchErr)
+	}
+	return vectors, errors.Join(errs...)
+}
+
+// embedChunk makes one EmbedAll call, waiting for the limiter and retrying
+// transient failures
+func embedChunk(ctx context.Context, client Client, request EmbeddingRequest, tokens int, opts EmbedAllOptions) (*EmbeddingResponse, error) {
+	return retryTransient(ctx, opts.Limiter, tokens, opts.MaxRetries, opts.RetryBackoff, func() (*EmbeddingResponse, error) {
+		return client.CreateEmbedding(ctx, request)
+	})
+}
+
+// maxRetryBackoff caps the doubling backoff of retryTransient
+const maxRetryBackoff = time.Minute
+
+// retryTransient runs call after waiting for limiter, retrying transient
+// failures up to maxRetries times (0 = 3, negative = never) with a backoff
+// (0 = 1s) that doubles after each attempt (see retryDelay)
+func retryTransient[T any](ctx context.Context, limiter *RateLimiter, tokens, maxRetries int, backoff time.Duration, call func() (T, error)) (T, error) {
+	retries := maxRetries
+	if retries == 0 {
+		retries = 3
+	}
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.Wait(ctx, tokens); err != nil {
+			var zero T
+			return zero, err
+		}
+		response, err := call()
+		if err == nil || attempt >= retries || !isTransient(err) {
+			return response, err
+		}
+
+		timer := time.NewTimer(retryDelay(backoff, attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return response, err
+		}
+	}
+}
+
+// retryDelay returns backoff doubled attempt times, capped at
+// maxRetryBackoff, or at backoff if that is longer. Many retries must not
+// overflow into a zero or negative wait.
+func retryDelay(backoff time.Duration, attempt int) time.Duration {
+	limit := max(backoff, maxRetryBackoff)
+	shift := min(attempt, 16)
+	if backoff > limit>>shift {
+		return limit
+	}
+	return backoff << shift
+}
@@ -0,0 +1,152 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmbedAll(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		attempts[payload.Input[0]]++
+		attempt := attempts[payload.Input[0]]
+		mu.Unlock()
+
+		switch {
+		case payload.Input[0] == "4" && attempt == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		case payload.Input[0] == "8":
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var data []map[string]interface{}
+		for i, input := range payload.Input {
+			n, _ := strconv.Atoi(input)
+			data = append(data, map[string]interface{}{"embedding": []float64{float64(n)}, "index": i})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data, "usage": map[string]int{"total_tokens": len(payload.Input)}})
+	}))
+	defer server.Close()
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true})
+
+	var progress []EmbedProgress
+	vectors, err := EmbedAll(context.Background(), client, numberedInputs(10), EmbedAllOptions{
+		BatchSize:    2,
+		Workers:      3,
+		Limiter:      NewRateLimiter(1000, 0),
+		RetryBackoff: 1,
+		OnProgress:   func(p EmbedProgress) { progress = append(progress, p) },
+	})
+
+	var batchErr *EmbeddingBatchError
+	if !errors.As(err, &batchErr) || len(batchErr.Failures) != 1 || batchErr.Failures[0].Start != 8 {
+		t.Fatalf("Expected only inputs 8-9 to fail, got %v", err)
+	}
+	for i, vector := range vectors {
+		if i >= 8 {
+			if vector != nil {
+				t.Errorf("Expected no vector for failed input %d", i)
+			}
+		} else if len(vector) != 1 || vector[0] != float64(i) {
+			t.Errorf("Vector %d out of order: %v", i, vector)
+		}
+	}
+	if attempts["4"] != 2 || attempts["8"] != 1 {
+		t.Errorf("Expected the 429 to be retried once and the 400 not at all, got %v", attempts)
+	}
+	last := progress[len(progress)-1]
+	if len(progress) != 5 || last.Completed != 8 || last.Failed != 2 || last.Total != 10 || last.Tokens != 8 {
+		t.Errorf("Unexpected progress: %+v", progress)
+	}
+}
+
+func TestEmbedAllEmbeddingModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"embedding": [0.1], "index": 0}], "usage": {"total_tokens": 1}}`))
+	}))
+	defer server.Close()
+	var mu sync.Mutex
+	var models []string
+	client, _ := NewClient(Config{
+		Provider:                ProviderOpenAI,
+		APIKey:                  "test-key",
+		BaseURL:                 server.URL,
+		DefaultModel:            "gpt-4o",
+		DefaultEmbeddingModel:   "text-embedding-3-large",
+		DisableBase64Embeddings: true,
+		Tokenizer: TokenizerFunc(func(model, text string) int {
+			mu.Lock()
+			defer mu.Unlock()
+			models = append(models, model)
+			return len(text)
+		}),
+	})
+
+	if _, err := EmbedAll(context.Background(), client, []string{"a"}, EmbedAllOptions{Limiter: NewRateLimiter(0, 1000)}); err != nil {
+		t.Fatalf("EmbedAll failed: %v", err)
+	}
+	if len(models) == 0 {
+		t.Fatal("Expected the limiter estimate to use the tokenizer")
+	}
+	for _, model := range models {
+		if model != "text-embedding-3-large" {
+			t.Errorf("Expected tokens counted for the embedding model, got %q", model)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for _, tc := range []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{time.Second, 0, time.Second},
+		{time.Second, 3, 8 * time.Second},
+		{time.Second, 6, time.Minute},
+		{time.Second, 34, time.Minute},
+		{time.Second, 1000, time.Minute},
+		{2 * time.Minute, 5, 2 * time.Minute},
+		{time.Duration(1<<62) + 1, 63, time.Duration(1<<62) + 1},
+	} {
+		if got := retryDelay(tc.backoff, tc.attempt); got != tc.want {
+			t.Errorf("retryDelay(%v, %d) = %v, want %v", tc.backoff, tc.attempt, got, tc.want)
+		}
+	}
+}
+
+func TestEmbedAllCancel(t *testing.T) {
+	server, _ := embeddingBatchServer(t, nil)
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vectors, err := EmbedAll(ctx, client, numberedInputs(10), EmbedAllOptions{
+		BatchSize: 2,
+		OnProgress: func(p EmbedProgress) {
+			if p.Completed == 4 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if vectors[3] == nil || vectors[4] != nil {
+		t.Errorf("Expected the first two chunks and nothing after the cancellation, got %v", vectors)
+	}
+}
@@ -0,0 +1,228 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmbeddingChunkError is the failure of one provider call covering the
+// inputs Start (inclusive) to End (exclusive)
+type EmbeddingChunkError struct {
+	Start, End int
+	Err        error
+}
+
+// Error implements error
+func (e EmbeddingChunkError) Error() string {
+	return fmt.Sprintf("inputs %d-%d: %v", e.Start, e.End-1, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e EmbeddingChunkError) Unwrap() error {
+	return e.Err
+}
+
+// EmbeddingBatchError is returned with a partial EmbeddingResponse when an
+// embedding request has EmbeddingRequest.AllowPartial set and some of its
+// inputs failed. Vectors of failed inputs are nil in the response. Calls
+// rejected because of their inputs are retried in halves, so Failures
+// narrow down to the offending inputs; errors.Is and errors.As reach the
+// underlying APIError or ErrInputTooLong through Unwrap.
+type EmbeddingBatchError struct {
+	// Inputs is the total number of inputs in the request
+	Inputs   int
+	Failures []EmbeddingChunkError
+}
+
+// Error implements error
+func (e *EmbeddingBatchError) Error() string {
+	parts := make([]string, len(e.Failures))
+	for i, failure := range e.Failures {
+		parts[i] = failure.Error()
+	}
+	return fmt.Sprintf("embedding batch: %d of %d inputs failed: %s", len(e.FailedIndices()), e.Inputs, strings.Join(parts, "; "))
+}
+
+// Unwrap returns the chunk errors, so errors.Is and errors.As see each of them
+func (e *EmbeddingBatchError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, failure := range e.Failures {
+		errs[i] = failure
+	}
+	return errs
+}
+
+// FailedIndices returns the indices of the inputs without a vector, in order
+func (e *EmbeddingBatchError) FailedIndices() []int {
+	var indices []int
+	for _, failure := range e.Failures {
+		for i := failure.Start; i < failure.End; i++ {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// InputErrors returns the error of every input without a vector, by index
+func (e *EmbeddingBatchError) InputErrors() map[int]error {
+	errs := make(map[int]error)
+	for _, failure := range e.Failures {
+		for i := failure.Start; i < failure.End; i++ {
+			errs[i] = failure.Err
+		}
+	}
+	return errs
+}
+
+// embeddingBatchSize returns how many inputs one provider call may carry
+// (0 = unlimited)
+func embeddingBatchSize(config Config) int {
+	if config.EmbeddingBatchSize > 0 {
+		return config.EmbeddingBatchSize
+	}
+	return providerCapabilities(config.Provider).MaxEmbeddingBatch
+}
+
+// inputError reports whether err may be caused by only some of the inputs
+// of a call (a 400, 413 or 422 response), so that retrying the call in
+// halves can isolate them
+func inputError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.StatusCode {
+	case 400, 413, 422:
+		return true
+	}
+	return false
+}
+
+// embeddingPiece is the outcome of one provider call covering the inputs
+// start (inclusive) to end (exclusive)
+type embeddingPiece struct {
+	start, end int
+	response   *EmbeddingResponse
+	err        error
+}
+
+// embedPiece embeds the inputs start to end of request. With AllowPartial,
+// a call rejected because of its inputs is split in halves, recursively,
+// until the failing inputs are isolated, so that the others still get a
+// vector; k bad inputs among n cost about k*log2(n) extra calls.
+func embedPiece(ctx context.Context, request EmbeddingRequest, start, end int, call embeddingFunc) []embeddingPiece {
+	pieceRequest := request
+	pieceRequest.Input = request.Input[start:end]
+	response, err := call(ctx, pieceRequest)
+	if err == nil && response != nil && len(response.Embeddings)+len(response.Vectors32) != end-start {
+		err = fmt.Errorf("expected %d embeddings, got %d", end-start, len(response.Embeddings)+len(response.Vectors32))
+	}
+	if err == nil || !request.AllowPartial || end-start < 2 || !inputError(err) || ctx.Err() != nil {
+		return []embeddingPiece{{start: start, end: end, response: response, err: err}}
+	}
+	middle := start + (end-start)/2
+	return append(embedPiece(ctx, request, start, middle, call), embedPiece(ctx, request, middle, end, call)...)
+}
+
+// embedInBatches splits request into chunks the provider accepts, runs them
+// with up to Config.EmbeddingConcurrency calls in flight and reassembles the
+// vectors in input order with summed usage. Requests that fit one call are
+// passed through untouched unless they allow partial results.
+func embedInBatches(ctx context.Context, config Config, request EmbeddingRequest, call embeddingFunc) (*EmbeddingResponse, error) {
+	size := embeddingBatchSize(config)
+	if size <= 0 || len(request.Input) <= size {
+		if !request.AllowPartial || len(request.Input) < 2 {
+			return call(ctx, request)
+		}
+		size = len(request.Input)
+	}
+
+	type chunk struct {
+		start, end int
+		pieces     []embeddingPiece
+	}
+	var chunks []*chunk
+	for start := 0; start < len(request.Input); start += size {
+		chunks = append(chunks, &chunk{start: start, end: min(start+size, len(request.Input))})
+	}
+
+	concurrency := max(config.EmbeddingConcurrency, 1)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	startTime := time.Now()
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for _, c := range chunks {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			// all-or-nothing call already failed; don't start more chunks
+			<-sem
+			c.pieces = []embeddingPiece{{start: c.start, end: c.end, err: context.Cause(ctx)}}
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			c.pieces = embedPiece(ctx, request, c.start, c.end, call)
+			if c.pieces[0].err != nil && !request.AllowPartial {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	response := &EmbeddingResponse{}
+	if request.AsFloat32 {
+		response.Vectors32 = make([][]float32, len(request.Input))
+	} else {
+		response.Embeddings = make([][]float64, len(request.Input))
+	}
+	batchErr := &EmbeddingBatchError{Inputs: len(request.Input)}
+	for _, c := range chunks {
+		for _, p := range c.pieces {
+			if p.err != nil {
+				if !request.AllowPartial && !errors.Is(p.err, context.Canceled) {
+					return nil, fmt.Errorf("embedding inputs %d-%d: %w", p.start, p.end-1, p.err)
+				}
+				batchErr.Failures = append(batchErr.Failures, EmbeddingChunkError{Start: p.start, End: p.end, Err: p.err})
+				continue
+			}
+			if request.AsFloat32 {
+				copy(response.Vectors32[p.start:p.end], p.response.Vectors32)
+			} else {
+				copy(response.Embeddings[p.start:p.end], p.response.Embeddings)
+			}
+			if response.Model == "" {
+				response.Model = p.response.Model
+				response.RequestID = p.response.RequestID
+			}
+			if response.Dimensions == 0 {
+				response.Dimensions = p.response.Dimensions
+			}
+			response.TokensUsed += p.response.TokensUsed
+			response.Usage.PromptTokens += p.response.Usage.PromptTokens
+			response.Usage.CompletionTokens += p.response.Usage.CompletionTokens
+			response.Usage.TotalTokens += p.response.Usage.TotalTokens
+			response.Usage.CachedTokens += p.response.Usage.CachedTokens
+		}
+	}
+	response.ResponseTime = time.Since(startTime)
+
+	if len(batchErr.Failures) == 0 {
+		return response, nil
+	}
+	if !request.AllowPartial {
+		// only cancellations are left: the caller's context ended
+		return nil, batchErr.Failures[0].Err
+	}
+	if len(chunks) == 1 && len(batchErr.Failures) == 1 && len(chunks[0].pieces) == 1 {
+		// a single call that failed as a whole keeps its error as is
+		return nil, batchErr.Failures[0].Err
+	}
+	return response, batchErr
+}
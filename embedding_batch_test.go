@@ -0,0 +1,172 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// embeddingBatchServer answers OpenAI embedding calls with one-dimensional
+// vectors holding each input's number, failing inputs listed in fail
+func embeddingBatchServer(t *testing.T, fail map[string]bool) (*httptest.Server, func() (calls, peak int)) {
+	var mu sync.Mutex
+	var calls, inFlight, peak int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		calls++
+		inFlight++
+		peak = max(peak, inFlight)
+		mu.Unlock()
+		defer func() { mu.Lock(); inFlight--; mu.Unlock() }()
+
+		var data []map[string]interface{}
+		for i, input := range payload.Input {
+			if fail[input] {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"message":"input too long"}}`))
+				return
+			}
+			n, _ := strconv.Atoi(input)
+			data = append(data, map[string]interface{}{"embedding": []float64{float64(n)}, "index": i})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  data,
+			"model": "text-embedding-3-small",
+			"usage": map[string]int{"prompt_tokens": len(payload.Input), "total_tokens": len(payload.Input)},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, func() (int, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		return calls, peak
+	}
+}
+
+func numberedInputs(n int) []string {
+	inputs := make([]string, n)
+	for i := range inputs {
+		inputs[i] = strconv.Itoa(i)
+	}
+	return inputs
+}
+
+func TestEmbeddingBatching(t *testing.T) {
+	server, stats := embeddingBatchServer(t, nil)
+	client, _ := NewClient(Config{
+		Provider:                ProviderOpenAI,
+		APIKey:                  "test-key",
+		BaseURL:                 server.URL,
+		DisableBase64Embeddings: true,
+		EmbeddingBatchSize:      3,
+		EmbeddingConcurrency:    2,
+	})
+
+	resp, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: numberedInputs(10)})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if calls, peak := stats(); calls != 4 || peak > 2 {
+		t.Errorf("Expected 4 calls with at most 2 in flight, got %d calls, peak %d", calls, peak)
+	}
+	for i, vector := range resp.Embeddings {
+		if len(vector) != 1 || vector[0] != float64(i) {
+			t.Fatalf("Vector %d out of order: %v", i, resp.Embeddings)
+		}
+	}
+	if resp.Usage.TotalTokens != 10 || resp.TokensUsed != 10 || resp.Dimensions != 1 || resp.Model != "text-embedding-3-small" {
+		t.Errorf("Unexpected merged response: %+v", resp)
+	}
+}
+
+func TestEmbeddingBatchingProviderLimit(t *testing.T) {
+	server, stats := embeddingBatchServer(t, nil)
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true})
+
+	resp, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: numberedInputs(2049), AsFloat32: true})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if calls, _ := stats(); calls != 2 || len(resp.Vectors32) != 2049 || resp.Vectors32[2048][0] != 2048 {
+		t.Errorf("Expected the OpenAI limit of 2048 to split into 2 calls, got %d calls, %d vectors", calls, len(resp.Vectors32))
+	}
+}
+
+func TestEmbeddingBatchingFailures(t *testing.T) {
+	server, _ := embeddingBatchServer(t, map[string]bool{"4": true})
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true, EmbeddingBatchSize: 3})
+	ctx := context.Background()
+
+	resp, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: numberedInputs(7)})
+	var apiErr *APIError
+	if resp != nil || !errors.As(err, &apiErr) {
+		t.Fatalf("Expected the whole call to fail with the APIError, got %v, %v", resp, err)
+	}
+
+	resp, err = client.CreateEmbedding(ctx, EmbeddingRequest{Input: numberedInputs(7), AllowPartial: true})
+	var batchErr *EmbeddingBatchError
+	if !errors.As(err, &batchErr) || !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an EmbeddingBatchError wrapping the APIError, got %v", err)
+	}
+	// The rejected chunk 3-5 is retried in halves down to the bad input
+	if got := fmt.Sprint(batchErr.FailedIndices()); got != "[4]" {
+		t.Errorf("Expected only input 4 to fail, got %s", got)
+	}
+	if resp == nil || resp.Embeddings[4] != nil || resp.Embeddings[3][0] != 3 || resp.Embeddings[6][0] != 6 || resp.Usage.TotalTokens != 6 {
+		t.Errorf("Expected the other vectors and their usage, got %+v", resp)
+	}
+	if errs := batchErr.InputErrors(); len(errs) != 1 || !errors.As(errs[4], &apiErr) || apiErr.StatusCode != 400 {
+		t.Errorf("Expected the APIError of input 4, got %v", errs)
+	}
+}
+
+func TestEmbeddingPartialIsolation(t *testing.T) {
+	server, stats := embeddingBatchServer(t, map[string]bool{"1": true, "6": true})
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true})
+	ctx := context.Background()
+
+	// Requests that fit one call are isolated too
+	resp, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: numberedInputs(8), AllowPartial: true, AsFloat32: true})
+	var batchErr *EmbeddingBatchError
+	if !errors.As(err, &batchErr) || fmt.Sprint(batchErr.FailedIndices()) != "[1 6]" {
+		t.Fatalf("Expected inputs 1 and 6 to fail, got %v", err)
+	}
+	if resp.Vectors32[0][0] != 0 || resp.Vectors32[7][0] != 7 || resp.Vectors32[6] != nil {
+		t.Errorf("Expected the good vectors, got %v", resp.Vectors32)
+	}
+	if calls, _ := stats(); calls != 11 {
+		t.Errorf("Expected 11 calls to bisect 8 inputs with 2 bad ones, made %d", calls)
+	}
+
+	// Without AllowPartial, or when nothing can be isolated, the error is unchanged
+	var apiErr *APIError
+	if resp, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: numberedInputs(8)}); resp != nil || !errors.As(err, &apiErr) || errors.As(err, &batchErr) {
+		t.Errorf("Expected the APIError alone, got %v", err)
+	}
+	if _, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"1"}, AllowPartial: true}); !errors.As(err, &apiErr) || errors.As(err, &batchErr) {
+		t.Errorf("Expected the APIError of a single input, got %v", err)
+	}
+
+	// Inputs over the token limit fail alone before anything is sent
+	long := strings.Repeat("word ", 100)
+	resp, err = client.CreateEmbedding(ctx, EmbeddingRequest{
+		Input: []string{"0", long, "2"}, AllowPartial: true, LongInputStrategy: LongInputError, MaxInputTokens: 10,
+	})
+	if !errors.As(err, &batchErr) || !errors.Is(err, ErrInputTooLong) || fmt.Sprint(batchErr.FailedIndices()) != "[1]" {
+		t.Fatalf("Expected input 1 to be too long, got %v", err)
+	}
+	if resp.Embeddings[0][0] != 0 || resp.Embeddings[1] != nil || resp.Embeddings[2][0] != 2 {
+		t.Errorf("Expected the other vectors in place, got %v", resp.Embeddings)
+	}
+}
@@ -1,10 +1,26 @@
 package llm
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/yhwhpe/llm-unified-client/embeddingutil"
 )
 
 // TestEmbeddingRequest tests the EmbeddingRequest structure
@@ -221,7 +237,10 @@ func TestCosineSimilarity(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			similarity := cosineSimilarity(tt.a, tt.b)
+			similarity, err := embeddingutil.CosineSimilarity(tt.a, tt.b)
+			if err != nil {
+				t.Fatalf("CosineSimilarity failed: %v", err)
+			}
 			if abs(similarity-tt.expected) > tt.delta {
 				t.Errorf("Expected similarity ~%.3f, got %.3f", tt.expected, similarity)
 			}
@@ -231,38 +250,477 @@ func TestCosineSimilarity(t *testing.T) {
 
 // Helper functions
 
-func stringPtr(s string) *string {
-	return &s
+func TestEmbeddingDimensions(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.URL.Path == "/embed" {
+			w.Write([]byte(`{"embeddings":[[3,4,12]],"meta":{"billed_units":{"input_tokens":1}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"embedding":[0.6,0.8],"index":0}],"model":"text-embedding-3-small","usage":{"prompt_tokens":1,"total_tokens":1}}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	two := 2
+
+	openAI, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	resp, err := openAI.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"hi"}, Dimensions: &two})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if payload["dimensions"] != float64(2) || resp.Dimensions != 2 {
+		t.Errorf("Expected dimensions passed through and reported, got payload %v, response %d", payload, resp.Dimensions)
+	}
+
+	cohere, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	_, err = cohere.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"hi"}, Dimensions: &two})
+	var capErr *CapabilityError
+	if !errors.As(err, &capErr) || capErr.Capability != CapabilityEmbeddingDimensions {
+		t.Errorf("Expected a CapabilityError for server-side dimensions, got %v", err)
+	}
+
+	resp, err = cohere.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"hi"}, Dimensions: &two, TruncateDimensions: true})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if _, sent := payload["dimensions"]; sent {
+		t.Error("Dimensions should not be sent when truncating client-side")
+	}
+	if resp.Dimensions != 2 || abs(resp.Embeddings[0][0]-0.6) > 1e-9 || abs(resp.Embeddings[0][1]-0.8) > 1e-9 {
+		t.Errorf("Expected truncated, renormalized [0.6 0.8], got %v (%d)", resp.Embeddings[0], resp.Dimensions)
+	}
 }
 
-func cosineSimilarity(a, b []float64) float64 {
-	if len(a) != len(b) {
-		return 0
+func TestEmbeddingModelResolution(t *testing.T) {
+	var model string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		model = payload.Model
+		if r.URL.Path == "/embed" {
+			w.Write([]byte(`{"embeddings":[[1,0]],"meta":{"billed_units":{"input_tokens":1}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"embedding":[1,0],"index":0}],"usage":{"prompt_tokens":1,"total_tokens":1}}`))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		provider       Provider
+		defaultModel   string
+		embeddingModel string
+		override       *string
+		want           string
+		warn           bool
+	}{
+		{ProviderOpenAI, "gpt-4o-mini", "", nil, "text-embedding-3-small", false},
+		{ProviderOpenAI, "gpt-4o-mini", "text-embedding-3-large", nil, "text-embedding-3-large", false},
+		{ProviderOpenAI, "text-embedding-3-large", "", nil, "text-embedding-3-large", true},
+		{ProviderOpenAI, "gpt-4o-mini", "text-embedding-3-large", stringPtr("text-embedding-ada-002"), "text-embedding-ada-002", false},
+		{ProviderCohere, "command-r", "", nil, "embed-multilingual-v3.0", false},
+		{ProviderCohere, "", "embed-english-v3.0", nil, "embed-english-v3.0", false},
+		{ProviderCohere, "embed-english-v3.0", "", nil, "embed-english-v3.0", true},
+		{ProviderQwen, "qwen-plus", "", nil, "text-embedding-v3", false},
+		{ProviderJina, "", "jina-embeddings-v4", nil, "jina-embeddings-v4", false},
+	}
+	for _, tt := range tests {
+		var logs bytes.Buffer
+		client, err := NewClient(Config{
+			Provider:              tt.provider,
+			APIKey:                "test-key",
+			BaseURL:               server.URL,
+			DisableBaseURLVersion: true,
+			DefaultModel:          tt.defaultModel,
+			DefaultEmbeddingModel: tt.embeddingModel,
+			Logger:                slog.New(slog.NewTextHandler(&logs, nil)),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		if warned := strings.Contains(logs.String(), "DefaultEmbeddingModel"); warned != tt.warn {
+			t.Errorf("%s with default %q: expected warning %v, got %q", tt.provider, tt.defaultModel, tt.warn, logs.String())
+		}
+		if _, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"hi"}, Model: tt.override}); err != nil {
+			t.Fatalf("CreateEmbedding failed: %v", err)
+		}
+		if model != tt.want {
+			t.Errorf("%s with default %q: expected model %q, got %q", tt.provider, tt.defaultModel, tt.want, model)
+		}
 	}
 
-	var dotProduct, normA, normB float64
-	for i := 0; i < len(a); i++ {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
+	// chat never sees the embedding default
+	client, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true, DefaultEmbeddingModel: "embed-english-v3.0"})
+	if got := client.GetConfig().DefaultModel; got != "command-r-plus" {
+		t.Errorf("Expected chat default command-r-plus, got %q", got)
 	}
+}
+
+func TestQwenEmbedding(t *testing.T) {
+	var mu sync.Mutex
+	var batches []int
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model      string   `json:"model"`
+			Input      []string `json:"input"`
+			Dimensions int      `json:"dimensions"`
+		}
+		raw, _ := io.ReadAll(r.Body)
+		json.Unmarshal(raw, &body)
+		mu.Lock()
+		batches = append(batches, len(body.Input))
+		json.Unmarshal(raw, &payload)
+		mu.Unlock()
+
+		data := make([]map[string]interface{}, len(body.Input))
+		for i := range data {
+			data[i] = map[string]interface{}{"embedding": []float64{1, 0}, "index": i}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data":  data,
+			"model": body.Model,
+			"usage": map[string]int{"prompt_tokens": len(body.Input), "total_tokens": len(body.Input)},
+		})
+	}))
+	defer server.Close()
 
-	if normA == 0 || normB == 0 {
-		return 0
+	client, err := NewClient(Config{Provider: ProviderQwen, APIKey: "test-key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	two := 2
+	resp, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: make([]string, 60), Dimensions: &two})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if payload["model"] != "text-embedding-v3" || payload["dimensions"] != float64(2) {
+		t.Errorf("Unexpected payload %v", payload)
 	}
+	if len(batches) != 3 {
+		t.Errorf("Expected 60 inputs split into 3 batches of at most 25, got %v", batches)
+	}
+	if len(resp.Embeddings) != 60 || resp.Usage.PromptTokens != 60 || resp.Model != "text-embedding-v3" {
+		t.Errorf("Unexpected response: %d vectors, usage %+v, model %q", len(resp.Embeddings), resp.Usage, resp.Model)
+	}
+}
+
+func TestEmbeddingNormalize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(`{"embeddings":[[3,4],[0,0]],"meta":{"billed_units":{"input_tokens":2}}}`))
+	}))
+	defer server.Close()
 
-	return dotProduct / (sqrt(normA) * sqrt(normB))
+	client, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL})
+	for _, asFloat32 := range []bool{false, true} {
+		resp, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"a", ""}, Normalize: true, AsFloat32: asFloat32})
+		if err != nil {
+			t.Fatalf("CreateEmbedding failed: %v", err)
+		}
+		first := resp.Embeddings
+		if asFloat32 {
+			first = [][]float64{{float64(resp.Vectors32[0][0]), float64(resp.Vectors32[0][1])}}
+		}
+		if abs(first[0][0]-0.6) > 1e-6 || abs(first[0][1]-0.8) > 1e-6 {
+			t.Errorf("Expected [0.6 0.8], got %v", first[0])
+		}
+		if len(resp.ZeroVectors) != 1 || resp.ZeroVectors[0] != 1 {
+			t.Errorf("Expected the zero vector flagged, got %v", resp.ZeroVectors)
+		}
+	}
 }
 
-func sqrt(x float64) float64 {
-	if x < 0 {
-		return 0
+func TestEmbeddingVectorBase64(t *testing.T) {
+	want := []float32{0.5, -1.25, 3}
+	raw := make([]byte, 0, 12)
+	for _, f := range want {
+		raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(f))
+	}
+
+	for _, raw := range []string{`"` + base64.StdEncoding.EncodeToString(raw) + `"`, `[0.5,-1.25,3]`} {
+		vector64, err := decodeEmbedding[float64](json.RawMessage(raw))
+		if err != nil {
+			t.Fatalf("decodeEmbedding failed: %v", err)
+		}
+		vector32, err := decodeEmbedding[float32](json.RawMessage(raw))
+		if err != nil {
+			t.Fatalf("decodeEmbedding failed: %v", err)
+		}
+		for i, f := range want {
+			if vector64[i] != float64(f) || vector32[i] != f {
+				t.Errorf("Decoded %v and %v, want %v", vector64, vector32, want)
+				break
+			}
+		}
+	}
+
+	long := make([]float32, 1536)
+	for i := range long {
+		long[i] = float32(i) / 7
+	}
+	encoded := base64.StdEncoding.EncodeToString(embeddingBytes(long))
+	vector, err := decodeEmbedding[float32](json.RawMessage(`"` + encoded + `"`))
+	if err != nil || !reflect.DeepEqual(vector, long) {
+		t.Errorf("Expected a vector spanning several decode chunks to round-trip, got %d values, %v", len(vector), err)
+	}
+	escaped := `"` + strings.ReplaceAll(base64.StdEncoding.EncodeToString(raw), "/", `\/`) + `"`
+	if vector, err := decodeEmbedding[float32](json.RawMessage(escaped)); err != nil || !reflect.DeepEqual(vector, want) {
+		t.Errorf("Expected an escaped string to decode, got %v, %v", vector, err)
+	}
+
+	for _, invalid := range []string{`"AAAA="`, `"AAA"`, `"!!!!!!!!"`, `"AAAAAA=A"`, `"`, `"AAAA`} {
+		if _, err := decodeEmbedding[float64](json.RawMessage(invalid)); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
+
+// embeddingBytes encodes a vector as little-endian float32s
+func embeddingBytes(vector []float32) []byte {
+	data := make([]byte, 0, 4*len(vector))
+	for _, f := range vector {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(f))
 	}
-	z := 1.0
-	for i := 0; i < 10; i++ {
-		z = z - (z*z-x)/(2*z)
+	return data
+}
+
+func TestParseOpenAIEmbeddingsStream(t *testing.T) {
+	body := `{"object":"list","data":[` +
+		`{"object":"embedding","index":1,"embedding":"` + base64.StdEncoding.EncodeToString(embeddingBytes([]float32{3, 4})) + `"},` +
+		`{"object":"embedding","index":0,"embedding":[1,2],"extra":{"nested":[1,{}]}}],` +
+		`"model":"text-embedding-3-small","usage":{"prompt_tokens":5,"total_tokens":5}}`
+
+	response, err := parseOpenAIEmbeddings(strings.NewReader(body), false)
+	if err != nil {
+		t.Fatalf("parseOpenAIEmbeddings failed: %v", err)
+	}
+	if !reflect.DeepEqual(response.Embeddings, [][]float64{{1, 2}, {3, 4}}) || response.Model != "text-embedding-3-small" || response.Usage.TotalTokens != 5 {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	response, err = parseOpenAIEmbeddings(strings.NewReader(body), true)
+	if err != nil || !reflect.DeepEqual(response.Vectors32, [][]float32{{1, 2}, {3, 4}}) || response.Embeddings != nil {
+		t.Errorf("Unexpected float32 response %+v, %v", response, err)
+	}
+
+	for name, invalid := range map[string]string{
+		"empty data":     `{"data":[]}`,
+		"null data":      `{"data":null}`,
+		"bad index":      `{"data":[{"index":3,"embedding":[1]}]}`,
+		"not an object":  `[1,2]`,
+		"truncated":      body[:len(body)/2],
+		"bad embedding":  `{"data":[{"index":0,"embedding":"AAAA="}]}`,
+		"data not array": `{"data":{"index":0}}`,
+	} {
+		if _, err := parseOpenAIEmbeddings(strings.NewReader(invalid), false); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
 	}
-	return z
+}
+
+func TestEmbeddingResponseTooLarge(t *testing.T) {
+	vector := base64.StdEncoding.EncodeToString(embeddingBytes(make([]float32, 1536)))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":[`))
+		for i := 0; i < 100; i++ {
+			if i > 0 {
+				w.Write([]byte(","))
+			}
+			fmt.Fprintf(w, `{"index":%d,"embedding":"%s"}`, i, vector)
+		}
+		w.Write([]byte(`],"model":"text-embedding-3-small"}`))
+	}))
+	defer server.Close()
+
+	for _, limit := range []int64{64 << 10, 1 << 20} {
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, MaxResponseBytes: limit})
+		response, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"a"}})
+		if limit < 1<<20 {
+			var tooLarge *ResponseTooLargeError
+			if !errors.As(err, &tooLarge) || tooLarge.Limit != limit {
+				t.Errorf("Expected ResponseTooLargeError for limit %d, got %v", limit, err)
+			}
+			continue
+		}
+		if err != nil || len(response.Embeddings) != 100 {
+			t.Errorf("Expected 100 embeddings within limit %d, got %v", limit, err)
+		}
+	}
+}
+
+// embeddingResponseBody is an OpenAI embeddings response with n base64
+// vectors of the given dimension
+func embeddingResponseBody(n, dimensions int) []byte {
+	vector := make([]float32, dimensions)
+	for i := range vector {
+		vector[i] = float32(i) / float32(dimensions)
+	}
+	encoded := base64.StdEncoding.EncodeToString(embeddingBytes(vector))
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"object":"list","data":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(&buf, `{"object":"embedding","index":%d,"embedding":"%s"}`, i, encoded)
+	}
+	buf.WriteString(`],"model":"text-embedding-3-small","usage":{"prompt_tokens":4096,"total_tokens":4096}}`)
+	return buf.Bytes()
+}
+
+// readAllEmbeddings parses body the way the clients did before
+// parseOpenAIEmbeddings streamed, as the baseline of
+// BenchmarkParseOpenAIEmbeddings
+func readAllEmbeddings(body io.Reader) ([][]float32, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	var apiResp struct {
+		Data []struct {
+			Embedding json.RawMessage `json:"embedding"`
+			Index     int             `json:"index"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(data, &apiResp); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(apiResp.Data))
+	for _, item := range apiResp.Data {
+		var encoded string
+		if err := json.Unmarshal(item.Embedding, &encoded); err != nil {
+			return nil, err
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		vector := make([]float32, len(raw)/4)
+		for i := range vector {
+			vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+		vectors[item.Index] = vector
+	}
+	return vectors, nil
+}
+
+// BenchmarkParseOpenAIEmbeddings decodes a 2048-input response of
+// 1536-dimension base64 vectors (about 17 MB)
+func BenchmarkParseOpenAIEmbeddings(b *testing.B) {
+	body := embeddingResponseBody(2048, 1536)
+
+	b.Run("stream", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for b.Loop() {
+			if _, err := parseOpenAIEmbeddings(bytes.NewReader(body), true); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("readall", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for b.Loop() {
+			if _, err := readAllEmbeddings(bytes.NewReader(body)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestEmbeddingAsFloat32(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.URL.Path == "/embed" {
+			w.Write([]byte(`{"embeddings":[[3,4,12]],"meta":{"billed_units":{"input_tokens":1}}}`))
+			return
+		}
+		w.Write([]byte(`{"data":[{"embedding":[0.5,-1.25],"index":1},{"embedding":[3,4],"index":0}],"model":"text-embedding-3-small"}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	openAI, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	resp, err := openAI.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"a", "b"}, AsFloat32: true})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if resp.Embeddings != nil || len(resp.Vectors32) != 2 || resp.Vectors32[1][1] != -1.25 || resp.Dimensions != 2 {
+		t.Errorf("Expected only ordered Vectors32, got %v / %v (%d)", resp.Embeddings, resp.Vectors32, resp.Dimensions)
+	}
+	if similarity, _ := embeddingutil.CosineSimilarity(resp.Vectors32[0], []float32{6, 8}); abs(similarity-1) > 1e-6 {
+		t.Errorf("Expected similarity 1, got %v", similarity)
+	}
+
+	cohere, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	two := 2
+	resp, err = cohere.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"hi"}, AsFloat32: true, Dimensions: &two, TruncateDimensions: true})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if resp.Embeddings != nil || resp.Dimensions != 2 || abs(float64(resp.Vectors32[0][0])-0.6) > 1e-6 {
+		t.Errorf("Expected truncated, renormalized float32 vectors, got %v (%d)", resp.Vectors32, resp.Dimensions)
+	}
+}
+
+// BenchmarkEmbeddingDecode compares decoding a 100-input batch of
+// 1536-dimensional embeddings sent as JSON floats and as base64
+func BenchmarkEmbeddingDecode(b *testing.B) {
+	const inputs, dims = 100, 1536
+	floatItems := make([]map[string]interface{}, inputs)
+	base64Items := make([]map[string]interface{}, inputs)
+	for i := range floatItems {
+		vector := make([]float64, dims)
+		raw := make([]byte, 0, dims*4)
+		for j := range vector {
+			f := float32(math.Sin(float64(i*dims + j)))
+			vector[j] = float64(f)
+			raw = binary.LittleEndian.AppendUint32(raw, math.Float32bits(f))
+		}
+		floatItems[i] = map[string]interface{}{"embedding": vector, "index": i}
+		base64Items[i] = map[string]interface{}{"embedding": base64.StdEncoding.EncodeToString(raw), "index": i}
+	}
+
+	for _, bench := range []struct {
+		name  string
+		items []map[string]interface{}
+	}{
+		{"float", floatItems},
+		{"base64", base64Items},
+	} {
+		body, _ := json.Marshal(map[string]interface{}{"data": bench.items})
+		b.Run(bench.name, func(b *testing.B) {
+			b.SetBytes(int64(len(body)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var resp struct {
+					Data []struct {
+						Embedding json.RawMessage `json:"embedding"`
+						Index     int             `json:"index"`
+					} `json:"data"`
+				}
+				if err := json.Unmarshal(body, &resp); err != nil {
+					b.Fatal(err)
+				}
+				for _, item := range resp.Data {
+					if _, err := decodeEmbedding[float64](item.Embedding); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
 }
 
 func abs(x float64) float64 {
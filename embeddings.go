@@ -0,0 +1,420 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"strings"
+)
+
+// EmbeddingTask is the purpose of an embedding (see EmbeddingRequest.Task)
+type EmbeddingTask string
+
+// Provider-neutral embedding tasks
+const (
+	EmbeddingTaskQuery          EmbeddingTask = "query"
+	EmbeddingTaskDocument       EmbeddingTask = "document"
+	EmbeddingTaskSimilarity     EmbeddingTask = "similarity"
+	EmbeddingTaskClassification EmbeddingTask = "classification"
+	EmbeddingTaskClustering     EmbeddingTask = "clustering"
+)
+
+// embeddingTasks maps the neutral tasks to each provider's values. A
+// missing entry means the provider has no equivalent and uses its default.
+var embeddingTasks = map[Provider]map[EmbeddingTask]string{
+	ProviderCohere: {
+		EmbeddingTaskQuery:          "search_query",
+		EmbeddingTaskDocument:       "search_document",
+		EmbeddingTaskClassification: "classification",
+		EmbeddingTaskClustering:     "clustering",
+	},
+	ProviderJina: {
+		EmbeddingTaskQuery:          "retrieval.query",
+		EmbeddingTaskDocument:       "retrieval.passage",
+		EmbeddingTaskSimilarity:     "text-matching",
+		EmbeddingTaskClassification: "classification",
+		EmbeddingTaskClustering:     "separation",
+	},
+	ProviderGemini: {
+		EmbeddingTaskQuery:          "RETRIEVAL_QUERY",
+		EmbeddingTaskDocument:       "RETRIEVAL_DOCUMENT",
+		EmbeddingTaskSimilarity:     "SEMANTIC_SIMILARITY",
+		EmbeddingTaskClassification: "CLASSIFICATION",
+		EmbeddingTaskClustering:     "CLUSTERING",
+	},
+}
+
+// embeddingTaskValue returns the provider's value for task ("" = default)
+func embeddingTaskValue(provider Provider, task EmbeddingTask) string {
+	switch task {
+	case EmbeddingTaskQuery, EmbeddingTaskDocument, EmbeddingTaskSimilarity, EmbeddingTaskClassification, EmbeddingTaskClustering:
+		return embeddingTasks[provider][task]
+	}
+	return string(task)
+}
+
+// isEmbeddingModel reports whether model names an embedding model
+// (text-embedding-*, embed-*, jina-embeddings-*, gemini-embedding-*, ...)
+func isEmbeddingModel(model string) bool {
+	return strings.Contains(strings.ToLower(model), "embed")
+}
+
+// withEmbeddingDefault fills an empty Config.DefaultEmbeddingModel with
+// providerDefault. An embedding model configured as DefaultModel still
+// wins, as it did before DefaultEmbeddingModel existed, but is reported on
+// Config.Logger when the provider also serves chat, since chat calls would
+// send it to the chat endpoint.
+func withEmbeddingDefault(config Config, providerDefault string) Config {
+	if isEmbeddingModel(config.DefaultModel) && providerCapabilities(config.Provider).Chat && config.Logger != nil {
+		config.Logger.Warn("llm: embedding model configured as DefaultModel; set DefaultEmbeddingModel instead",
+			slog.String("provider", string(config.Provider)), slog.String("model", config.DefaultModel))
+	}
+	if config.DefaultEmbeddingModel != "" {
+		return config
+	}
+	if isEmbeddingModel(config.DefaultModel) {
+		config.DefaultEmbeddingModel = config.DefaultModel
+	} else {
+		config.DefaultEmbeddingModel = providerDefault
+	}
+	return config
+}
+
+// resolveEmbeddingModel returns the request override or
+// Config.DefaultEmbeddingModel
+func resolveEmbeddingModel(config Config, override *string) string {
+	if override != nil {
+		return *override
+	}
+	return config.DefaultEmbeddingModel
+}
+
+// decodeEmbedding decodes an embedding sent either as a JSON array of
+// numbers or, with encoding_format "base64", as base64 little-endian
+// float32s, straight into the requested element type
+func decodeEmbedding[T float32 | float64](raw json.RawMessage) ([]T, error) {
+	if len(raw) == 0 || raw[0] != '"' {
+		var vector []T
+		err := json.Unmarshal(raw, &vector)
+		return vector, err
+	}
+
+	if len(raw) < 2 || raw[len(raw)-1] != '"' || bytes.IndexByte(raw[1:len(raw)-1], '\\') >= 0 {
+		// escaped (or malformed) strings are rare enough to unquote first
+		var unquoted string
+		if err := json.Unmarshal(raw, &unquoted); err != nil {
+			return nil, err
+		}
+		return decodeBase64Floats[T]([]byte(unquoted))
+	}
+	return decodeBase64Floats[T](raw[1 : len(raw)-1])
+}
+
+// base64Chunk is how many base64 characters decodeBase64Floats decodes at a
+// time; a multiple of 4 that decodes to whole float32s
+const base64Chunk = 1024
+
+// decodeBase64Floats decodes base64 little-endian float32s into a vector,
+// without materializing the decoded bytes
+func decodeBase64Floats[T float32 | float64](encoded []byte) ([]T, error) {
+	size := len(encoded) / 4 * 3
+	if len(encoded)%4 == 0 {
+		size -= len(encoded) - len(bytes.TrimRight(encoded, "="))
+	}
+	if size%4 != 0 {
+		return nil, fmt.Errorf("invalid base64 embedding: %d bytes is not a whole number of float32s", size)
+	}
+
+	vector := make([]T, size/4)
+	var buf [base64Chunk / 4 * 3]byte
+	i := 0
+	for start := 0; start < len(encoded); start += base64Chunk {
+		n, err := base64.StdEncoding.Decode(buf[:], encoded[start:min(start+base64Chunk, len(encoded))])
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 embedding: %w", err)
+		}
+		if i+n/4 > len(vector) || n%4 != 0 {
+			return nil, fmt.Errorf("invalid base64 embedding")
+		}
+		for j := 0; j < n; j += 4 {
+			vector[i] = T(math.Float32frombits(binary.LittleEndian.Uint32(buf[j:])))
+			i++
+		}
+	}
+	if i != len(vector) {
+		return nil, fmt.Errorf("invalid base64 embedding")
+	}
+	return vector, nil
+}
+
+// decodeEmbeddings decodes raw vectors into response, as Vectors32 when
+// asFloat32 is set and as Embeddings otherwise
+func decodeEmbeddings(raws []json.RawMessage, asFloat32 bool, response *EmbeddingResponse) error {
+	if asFloat32 {
+		response.Vectors32 = make([][]float32, len(raws))
+	} else {
+		response.Embeddings = make([][]float64, len(raws))
+	}
+	for i, raw := range raws {
+		var err error
+		if asFloat32 {
+			response.Vectors32[i], err = decodeEmbedding[float32](raw)
+		} else {
+			response.Embeddings[i], err = decodeEmbedding[float64](raw)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to decode embedding %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// parseOpenAIEmbeddings parses the OpenAI embeddings response format, also
+// used by other providers: vectors in a data array ordered by index. The
+// body is decoded as a stream, one vector at a time, so only the decoded
+// vectors are held in memory.
+func parseOpenAIEmbeddings(body io.Reader, asFloat32 bool) (*EmbeddingResponse, error) {
+	var (
+		items []openAIEmbedding
+		model string
+		usage struct {
+			PromptTokens int `json:"prompt_tokens"`
+			TotalTokens  int `json:"total_tokens"`
+		}
+	)
+	err := decodeObject(json.NewDecoder(body), func(dec *json.Decoder, key string) error {
+		switch key {
+		case "data":
+			return decodeArray(dec, func(dec *json.Decoder) error {
+				item, err := decodeOpenAIEmbedding(dec, asFloat32)
+				items = append(items, item)
+				return err
+			})
+		case "model":
+			return dec.Decode(&model)
+		case "usage":
+			return dec.Decode(&usage)
+		}
+		var skip json.RawMessage
+		return dec.Decode(&skip)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embedding response: %w", err)
+	}
+
+	if len(items) == 0 {
+		return nil, fmt.Errorf("no embeddings in response")
+	}
+
+	response := &EmbeddingResponse{
+		Model:      model,
+		TokensUsed: usage.TotalTokens,
+		Usage: Usage{
+			PromptTokens: usage.PromptTokens,
+			TotalTokens:  usage.TotalTokens,
+		},
+	}
+	// Extract embeddings in order
+	if asFloat32 {
+		response.Vectors32 = make([][]float32, len(items))
+	} else {
+		response.Embeddings = make([][]float64, len(items))
+	}
+	for _, item := range items {
+		if item.index < 0 || item.index >= len(items) {
+			return nil, fmt.Errorf("invalid embedding index: %d", item.index)
+		}
+		if asFloat32 {
+			response.Vectors32[item.index] = item.vector32
+		} else {
+			response.Embeddings[item.index] = item.vector
+		}
+	}
+	return response, nil
+}
+
+// openAIEmbedding is one decoded item of an OpenAI embeddings response,
+// with either vector or vector32 set
+type openAIEmbedding struct {
+	index    int
+	vector   []float64
+	vector32 []float32
+}
+
+// decodeOpenAIEmbedding reads one item of the data array from dec,
+// decoding its vector straight into vector32 when asFloat32 is set and into
+// vector otherwise
+func decodeOpenAIEmbedding(dec *json.Decoder, asFloat32 bool) (openAIEmbedding, error) {
+	var raw struct {
+		Index     int             `json:"index"`
+		Embedding json.RawMessage `json:"embedding"`
+	}
+	if err := dec.Decode(&raw); err != nil {
+		return openAIEmbedding{}, err
+	}
+	item := openAIEmbedding{index: raw.Index}
+	var err error
+	if asFloat32 {
+		item.vector32, err = decodeEmbedding[float32](raw.Embedding)
+	} else {
+		item.vector, err = decodeEmbedding[float64](raw.Embedding)
+	}
+	if err != nil {
+		return item, fmt.Errorf("failed to decode embedding %d: %w", item.index, err)
+	}
+	return item, nil
+}
+
+// decodeObject reads a JSON object from dec, calling field for each key to
+// decode its value
+func decodeObject(dec *json.Decoder, field func(dec *json.Decoder, key string) error) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		key, _ := token.(string)
+		if err := field(dec, key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+// decodeArray reads a JSON array from dec, calling element to decode each
+// element. null is an empty array.
+func decodeArray(dec *json.Decoder, element func(dec *json.Decoder) error) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return nil
+	}
+	if token != json.Delim('[') {
+		return fmt.Errorf("expected an array, got %v", token)
+	}
+	for dec.More() {
+		if err := element(dec); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, ']')
+}
+
+// expectDelim reads the delimiter delim from dec
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("expected %v, got %v", delim, token)
+	}
+	return nil
+}
+
+// embedWithDimensions runs an embedding call honoring EmbeddingRequest.Dimensions:
+// passed through to providers that shorten vectors server-side, truncated and
+// renormalized client-side when TruncateDimensions allows it, and rejected
+// with a CapabilityError otherwise. The response reports the actual dimension.
+func embedWithDimensions(ctx context.Context, config Config, request EmbeddingRequest, call embeddingFunc) (*EmbeddingResponse, error) {
+	dimensions := 0
+	if request.Dimensions != nil {
+		dimensions = *request.Dimensions
+		if dimensions <= 0 {
+			return nil, fmt.Errorf("embedding dimensions must be positive, got %d", dimensions)
+		}
+	}
+
+	capabilities := providerCapabilities(config.Provider)
+	truncate := dimensions > 0 && capabilities.Embeddings && !capabilities.EmbeddingDimensions
+	if truncate {
+		if !request.TruncateDimensions {
+			return nil, &CapabilityError{Provider: config.Provider, Capability: CapabilityEmbeddingDimensions}
+		}
+		request.Dimensions = nil
+	}
+
+	response, err := call(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if truncate {
+		if err := truncateVectors(response.Embeddings, dimensions); err != nil {
+			return nil, err
+		}
+		if err := truncateVectors(response.Vectors32, dimensions); err != nil {
+			return nil, err
+		}
+	}
+	if len(response.Embeddings) > 0 {
+		response.Dimensions = len(response.Embeddings[0])
+	} else if len(response.Vectors32) > 0 {
+		response.Dimensions = len(response.Vectors32[0])
+	}
+	return response, nil
+}
+
+// truncateVectors shortens vectors to dimensions and renormalizes them
+func truncateVectors[T float32 | float64](vectors [][]T, dimensions int) error {
+	for i, vector := range vectors {
+		if len(vector) < dimensions {
+			return fmt.Errorf("cannot truncate %d-dimensional embedding to %d dimensions", len(vector), dimensions)
+		}
+		vectors[i] = normalizeVector(vector[:dimensions])
+	}
+	return nil
+}
+
+// normalizeEmbeddings L2-normalizes every vector of response in place for
+// EmbeddingRequest.Normalize and records the zero vectors it had to leave alone
+func normalizeEmbeddings(response *EmbeddingResponse) {
+	response.ZeroVectors = nil
+	for i, vector := range response.Embeddings {
+		if vector != nil && isZeroVector(normalizeVector(vector)) {
+			response.ZeroVectors = append(response.ZeroVectors, i)
+		}
+	}
+	for i, vector := range response.Vectors32 {
+		if vector != nil && isZeroVector(normalizeVector(vector)) {
+			response.ZeroVectors = append(response.ZeroVectors, i)
+		}
+	}
+}
+
+// isZeroVector reports whether every component of v is zero
+func isZeroVector[T float32 | float64](v []T) bool {
+	for _, x := range v {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeVector scales v in place to unit length; zero vectors are left as is
+func normalizeVector[T float32 | float64](v []T) []T {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return v
+	}
+	norm := math.Sqrt(sum)
+	for i := range v {
+		v[i] = T(float64(v[i]) / norm)
+	}
+	return v
+}
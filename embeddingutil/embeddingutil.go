@@ -0,0 +1,169 @@
+// Package embeddingutil provides similarity, distance and top-K search
+// helpers for vectors returned by llm.Client.CreateEmbedding. Every function
+// accepts float64 and float32 vectors (EmbeddingResponse.Embeddings and
+// Vectors32) and accumulates in float64.
+package embeddingutil
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ErrDimensionMismatch is returned when two vectors differ in length
+var ErrDimensionMismatch = errors.New("embeddingutil: vector dimensions differ")
+
+// Float is the element type of a vector
+type Float interface {
+	~float32 | ~float64
+}
+
+// Match is one TopK result
+type Match struct {
+	// Index is the position of the vector in the corpus
+	Index int
+	Score float64
+}
+
+// checkDimensions returns ErrDimensionMismatch for vectors of different length
+func checkDimensions[T Float](a, b []T) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("%w: %d and %d", ErrDimensionMismatch, len(a), len(b))
+	}
+	return nil
+}
+
+// DotProduct returns the dot product of a and b
+func DotProduct[T Float](a, b []T) (float64, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return 0, err
+	}
+	return dot(a, b), nil
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, in
+// [-1, 1]. It is 0 when either vector is all zeros.
+func CosineSimilarity[T Float](a, b []T) (float64, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return 0, err
+	}
+	return cosine(a, b), nil
+}
+
+// EuclideanDistance returns the L2 distance between a and b
+func EuclideanDistance[T Float](a, b []T) (float64, error) {
+	if err := checkDimensions(a, b); err != nil {
+		return 0, err
+	}
+	b = b[:len(a)]
+	var sum float64
+	for i, x := range a {
+		d := float64(x) - float64(b[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum), nil
+}
+
+// Norm returns the L2 length of v
+func Norm[T Float](v []T) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+// Normalize returns a unit-length copy of v. A zero vector cannot be
+// normalized and is returned as an all-zero copy.
+func Normalize[T Float](v []T) []T {
+	out := make([]T, len(v))
+	norm := Norm(v)
+	if norm == 0 {
+		return out
+	}
+	for i, x := range v {
+		out[i] = T(float64(x) / norm)
+	}
+	return out
+}
+
+// TopK returns the k vectors of corpus most similar to query by cosine
+// similarity, best first; ties keep corpus order. Fewer than k matches are
+// returned for smaller corpora. A corpus vector of a different dimension
+// than query fails the whole search.
+func TopK[T Float](query []T, corpus [][]T, k int) ([]Match, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	queryNorm := Norm(query)
+	best := make(matchHeap, 0, min(k, len(corpus)))
+	for i, vector := range corpus {
+		if len(vector) != len(query) {
+			return nil, fmt.Errorf("corpus vector %d: %w: %d and %d", i, ErrDimensionMismatch, len(query), len(vector))
+		}
+		var score float64
+		if norm := Norm(vector); norm != 0 && queryNorm != 0 {
+			score = dot(query, vector) / (queryNorm * norm)
+		}
+		match := Match{Index: i, Score: score}
+		if len(best) < k {
+			heap.Push(&best, match)
+		} else if best.less(best[0], match) {
+			best[0] = match
+			heap.Fix(&best, 0)
+		}
+	}
+
+	matches := make([]Match, len(best))
+	for i := len(matches) - 1; i >= 0; i-- {
+		matches[i] = heap.Pop(&best).(Match)
+	}
+	return matches, nil
+}
+
+// dot is the unchecked dot product; reslicing b lets the compiler drop the
+// bounds check in the loop
+func dot[T Float](a, b []T) float64 {
+	b = b[:len(a)]
+	var sum float64
+	for i, x := range a {
+		sum += float64(x) * float64(b[i])
+	}
+	return sum
+}
+
+// cosine is the unchecked cosine similarity
+func cosine[T Float](a, b []T) float64 {
+	b = b[:len(a)]
+	var ab, aa, bb float64
+	for i, x := range a {
+		y := float64(b[i])
+		ab += float64(x) * y
+		aa += float64(x) * float64(x)
+		bb += y * y
+	}
+	if aa == 0 || bb == 0 {
+		return 0
+	}
+	return ab / (math.Sqrt(aa) * math.Sqrt(bb))
+}
+
+// matchHeap is a min-heap of the best matches so far: its root is the
+// worst one and is replaced when a better match turns up
+type matchHeap []Match
+
+// less orders worse matches first: lower scores, then later indices
+func (h matchHeap) less(a, b Match) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Index > b.Index
+}
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)        { *h = append(*h, x.(Match)) }
+func (h *matchHeap) Pop() any {
+	old := *h
+	match := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return match
+}
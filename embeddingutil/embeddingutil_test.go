@@ -0,0 +1,139 @@
+package embeddingutil
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, b      []float64
+		cosine    float64
+		dot       float64
+		euclidean float64
+	}{
+		{"identical", []float64{1, 2, 3}, []float64{1, 2, 3}, 1, 14, 0},
+		{"orthogonal", []float64{1, 0}, []float64{0, 1}, 0, 0, math.Sqrt2},
+		{"opposite", []float64{1, 0}, []float64{-1, 0}, -1, -1, 2},
+		{"scaled", []float64{3, 4}, []float64{6, 8}, 1, 50, 5},
+		{"zero", []float64{0, 0}, []float64{1, 1}, 0, 0, math.Sqrt2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cosine, _ := CosineSimilarity(tt.a, tt.b)
+			dot, _ := DotProduct(tt.a, tt.b)
+			euclidean, _ := EuclideanDistance(tt.a, tt.b)
+			if math.Abs(cosine-tt.cosine) > 1e-12 || dot != tt.dot || math.Abs(euclidean-tt.euclidean) > 1e-12 {
+				t.Errorf("Got cosine %v, dot %v, euclidean %v", cosine, dot, euclidean)
+			}
+
+			a32, b32 := toFloat32(tt.a), toFloat32(tt.b)
+			if cosine32, _ := CosineSimilarity(a32, b32); math.Abs(cosine32-tt.cosine) > 1e-6 {
+				t.Errorf("float32 cosine %v, want %v", cosine32, tt.cosine)
+			}
+		})
+	}
+}
+
+func TestDimensionMismatch(t *testing.T) {
+	a, b := []float32{1, 2}, []float32{1, 2, 3}
+	if _, err := CosineSimilarity(a, b); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("CosineSimilarity: expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := DotProduct(a, b); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("DotProduct: expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := EuclideanDistance(a, b); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("EuclideanDistance: expected ErrDimensionMismatch, got %v", err)
+	}
+	if _, err := TopK(a, [][]float32{a, b}, 1); !errors.Is(err, ErrDimensionMismatch) {
+		t.Errorf("TopK: expected ErrDimensionMismatch, got %v", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	v := []float64{3, 4}
+	if got := Normalize(v); got[0] != 0.6 || got[1] != 0.8 || v[0] != 3 {
+		t.Errorf("Expected a normalized copy, got %v (input %v)", got, v)
+	}
+	if got := Normalize([]float32{0, 0}); got[0] != 0 || got[1] != 0 {
+		t.Errorf("Expected a zero vector to stay zero, got %v", got)
+	}
+}
+
+func TestTopK(t *testing.T) {
+	corpus := [][]float64{{1, 0}, {0, 1}, {1, 1}, {-1, 0}, {2, 0}}
+	matches, err := TopK([]float64{1, 0.1}, corpus, 3)
+	if err != nil {
+		t.Fatalf("TopK failed: %v", err)
+	}
+	// {1, 0} and {2, 0} tie; corpus order wins
+	want := []int{0, 4, 2}
+	for i, match := range matches {
+		if match.Index != want[i] {
+			t.Fatalf("Expected indices %v, got %+v", want, matches)
+		}
+	}
+	if matches, _ := TopK([]float64{1, 0}, corpus, 10); len(matches) != len(corpus) || matches[4].Index != 3 {
+		t.Errorf("Expected the whole corpus ranked, got %+v", matches)
+	}
+}
+
+func TestTopKMatchesSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	corpus := make([][]float32, 500)
+	for i := range corpus {
+		corpus[i] = randomVector(rng, 16)
+	}
+	query := randomVector(rng, 16)
+
+	matches, _ := TopK(query, corpus, 10)
+	for _, match := range matches {
+		better := 0
+		for _, vector := range corpus {
+			if score, _ := CosineSimilarity(query, vector); score > match.Score {
+				better++
+			}
+		}
+		if better >= 10 {
+			t.Fatalf("Match %+v is not in the top 10", match)
+		}
+	}
+	for i := 1; i < len(matches); i++ {
+		if matches[i].Score > matches[i-1].Score {
+			t.Fatalf("Matches not sorted: %+v", matches)
+		}
+	}
+}
+
+func BenchmarkTopK(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	corpus := make([][]float32, 10000)
+	for i := range corpus {
+		corpus[i] = randomVector(rng, 1536)
+	}
+	query := randomVector(rng, 1536)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		TopK(query, corpus, 10)
+	}
+}
+
+func toFloat32(v []float64) []float32 {
+	out := make([]float32, len(v))
+	for i, x := range v {
+		out[i] = float32(x)
+	}
+	return out
+}
+
+func randomVector(rng *rand.Rand, dims int) []float32 {
+	v := make([]float32, dims)
+	for i := range v {
+		v[i] = rng.Float32()*2 - 1
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrEmptyResponse is matched by EmptyResponseError via errors.Is
+var ErrEmptyResponse = errors.New("empty response")
+
+// EmptyResponseError is returned when a provider answers 200 with no choices,
+// or with a choice that has neither content nor reasoning although the model
+// stopped normally. Such responses are retried first (see
+// Config.EmptyResponseRetries).
+type EmptyResponseError struct {
+	// Reason is "no choices" or "empty content"
+	Reason string
+	// Body is the raw response body, for debugging
+	Body []byte
+}
+
+func (e *EmptyResponseError) Error() string {
+	return fmt.Sprintf("empty response: %s", e.Reason)
+}
+
+// Is makes errors.Is(err, ErrEmptyResponse) match
+func (e *EmptyResponseError) Is(target error) bool {
+	return target == ErrEmptyResponse
+}
+
+// emptyCompletion reports whether a chat completion choice carries nothing
+// although the model stopped normally. Tool calls, truncation and content
+// filtering legitimately come without content.
+func emptyCompletion(content, reasoning, finishReason string) bool {
+	return content == "" && reasoning == "" && (finishReason == "stop" || finishReason == "")
+}
+
+// retryEmptyResponses runs generate and repeats it while it fails with an
+// EmptyResponseError, up to Config.EmptyResponseRetries times. The
+// Idempotency-Key is resolved once so that every attempt sends the same one.
+func retryEmptyResponses(ctx context.Context, config Config, request Request, generate generateFunc) (*Response, error) {
+	retries := config.EmptyResponseRetries
+	if retries == 0 {
+		retries = 1
+	}
+	if retries > 0 {
+		request.IdempotencyKey = resolveIdempotencyKey(ctx, request.IdempotencyKey)
+	}
+
+	for attempt := 0; ; attempt++ {
+		response, err := generate(ctx, request)
+		if attempt >= retries || !errors.Is(err, ErrEmptyResponse) || ctx.Err() != nil {
+			return response, err
+		}
+	}
+}
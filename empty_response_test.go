@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveEmpty returns a server answering the first empty requests with body
+// and later ones with a regular completion, recording Idempotency-Key headers
+func serveEmpty(t *testing.T, empty int, body string, keys *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*keys = append(*keys, r.Header.Get("Idempotency-Key"))
+		if len(*keys) <= empty {
+			w.Write([]byte(body))
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Hello!"}, "finish_reason": "stop"}], "usage": {"total_tokens": 12}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEmptyResponseRetry(t *testing.T) {
+	const noChoices = `{"id": "chatcmpl-1", "choices": [], "usage": {"prompt_tokens": 10, "completion_tokens": 0, "total_tokens": 10}}`
+	const emptyContent = `{"choices": [{"message": {"role": "assistant", "content": ""}, "finish_reason": "stop"}]}`
+
+	for _, provider := range []Provider{ProviderOpenAI, ProviderDeepSeek, ProviderQwen} {
+		t.Run(string(provider), func(t *testing.T) {
+			var keys []string
+			server := serveEmpty(t, 1, noChoices, &keys)
+			client, _ := NewClient(Config{Provider: provider, APIKey: "test-key", BaseURL: server.URL})
+
+			response, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+			if err != nil || response.Content != "Hello!" {
+				t.Fatalf("Expected the retried response, got %v, %v", response, err)
+			}
+			if len(keys) != 2 || keys[0] != keys[1] {
+				t.Errorf("Expected one retry with the same Idempotency-Key, got %q", keys)
+			}
+		})
+	}
+
+	t.Run("exhausted", func(t *testing.T) {
+		var keys []string
+		server := serveEmpty(t, 3, emptyContent, &keys)
+		client, _ := NewClient(Config{Provider: ProviderDeepSeek, APIKey: "test-key", BaseURL: server.URL, EmptyResponseRetries: 2})
+
+		_, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+		var empty *EmptyResponseError
+		if !errors.As(err, &empty) || !errors.Is(err, ErrEmptyResponse) || empty.Reason != "empty content" || !strings.Contains(string(empty.Body), `"content": ""`) {
+			t.Errorf("Expected an EmptyResponseError with the body, got %v", err)
+		}
+		if len(keys) != 3 {
+			t.Errorf("Expected 3 attempts, got %d", len(keys))
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var keys []string
+		server := serveEmpty(t, 1, noChoices, &keys)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, EmptyResponseRetries: -1})
+
+		if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); !errors.Is(err, ErrEmptyResponse) || len(keys) != 1 {
+			t.Errorf("Expected ErrEmptyResponse without retry, got %v after %d requests", err, len(keys))
+		}
+	})
+
+	t.Run("tool calls", func(t *testing.T) {
+		var keys []string
+		server := serveEmpty(t, 1, `{"choices": [{"message": {"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function"}]}, "finish_reason": "tool_calls"}]}`, &keys)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+		response, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+		if err != nil || response.FinishReason != "tool_calls" || len(keys) != 1 {
+			t.Errorf("Expected the tool call response as is, got %v, %v", response, err)
+		}
+	})
+}
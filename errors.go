@@ -0,0 +1,247 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrResponseTooLarge is matched by ResponseTooLargeError via errors.Is
+var ErrResponseTooLarge = errors.New("response body too large")
+
+// ErrContentFiltered matches APIErrors where the provider's safety system
+// rejected the prompt (OpenAI content_policy_violation, Azure content_filter)
+// and every ContentFilteredError
+var ErrContentFiltered = errors.New("content filtered")
+
+// ContentFilteredError is returned when a provider answers successfully but
+// blocked the prompt or the response (Gemini promptFeedback and SAFETY
+// finish reasons) instead of returning empty content
+type ContentFilteredError struct {
+	Provider Provider
+	// Reason is the provider's block reason, e.g. SAFETY or PROHIBITED_CONTENT
+	Reason string
+	// Category and Probability identify the rating that triggered the block,
+	// when the provider reports one
+	Category    HarmCategory
+	Probability string
+}
+
+func (e *ContentFilteredError) Error() string {
+	if e.Category == "" {
+		return fmt.Sprintf("%s blocked the content: %s", e.Provider, e.Reason)
+	}
+	return fmt.Sprintf("%s blocked the content: %s (%s: %s)", e.Provider, e.Reason, e.Category, e.Probability)
+}
+
+// Is makes errors.Is(err, ErrContentFiltered) match
+func (e *ContentFilteredError) Is(target error) bool {
+	return target == ErrContentFiltered
+}
+
+// maxErrorBodyInMessage bounds how much of an error body APIError.Error() prints
+const maxErrorBodyInMessage = 4 << 10
+
+// APIError is returned when a provider answers with a non-2xx status code.
+type APIError struct {
+	Provider   Provider
+	StatusCode int
+	// Body is the full (size-limited) response body; Error() truncates it
+	Body string
+	// RequestID is the X-Request-ID sent with the request (see WithRequestID)
+	RequestID string
+	// ProviderRequestID is the provider's own request ID from the response headers, if any
+	ProviderRequestID string
+	// IdempotencyKey is the Idempotency-Key sent with the request, if any
+	IdempotencyKey string
+
+	// prefix keeps the provider-specific wording of the error message
+	prefix string
+}
+
+func (e *APIError) Error() string {
+	body := e.Body
+	if len(body) > maxErrorBodyInMessage {
+		body = fmt.Sprintf("%s... (truncated, %d bytes total)", body[:maxErrorBodyInMessage], len(e.Body))
+	}
+	return fmt.Sprintf("%s %d: %s", e.prefix, e.StatusCode, body)
+}
+
+// Is makes errors.Is(err, ErrProviderTimeout) match provider-side timeouts
+// and errors.Is(err, ErrContentFiltered) match safety rejections
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrProviderTimeout:
+		return isProviderTimeout(e.StatusCode, e.Body)
+	case ErrContentFiltered:
+		return isContentFiltered(e.StatusCode, e.Body)
+	}
+	return false
+}
+
+// isContentFiltered reports whether a provider response is a safety rejection
+func isContentFiltered(statusCode int, body string) bool {
+	if statusCode != http.StatusBadRequest {
+		return false
+	}
+	return strings.Contains(body, "content_policy_violation") || strings.Contains(body, "content_filter")
+}
+
+// newAPIError creates an APIError for a non-2xx provider response. Secrets
+// echoed back by the provider are masked in the body.
+func newAPIError(config Config, prefix string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Provider:          config.Provider,
+		StatusCode:        resp.StatusCode,
+		Body:              redactSecrets(config, string(body)),
+		ProviderRequestID: providerRequestID(resp.Header),
+		prefix:            prefix,
+	}
+	if resp.Request != nil {
+		apiErr.RequestID = resp.Request.Header.Get(requestIDHeader)
+		apiErr.IdempotencyKey = resp.Request.Header.Get("Idempotency-Key")
+	}
+	return apiErr
+}
+
+// providerRequestID extracts the provider-assigned request ID from response headers
+func providerRequestID(header http.Header) string {
+	for _, name := range []string{"X-Request-Id", "Request-Id", "Apim-Request-Id"} {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// ResponseTooLargeError is returned when a successful response body exceeds
+// Config.MaxResponseBytes.
+type ResponseTooLargeError struct {
+	Limit       int64
+	Read        int64
+	ContentType string
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response body too large: read %d bytes (limit %d, content-type %q)", e.Read, e.Limit, e.ContentType)
+}
+
+// Is makes errors.Is(err, ErrResponseTooLarge) match
+func (e *ResponseTooLargeError) Is(target error) bool {
+	return target == ErrResponseTooLarge
+}
+
+// ProxyError is returned when a request could not pass through the configured
+// egress proxy (connection refused, CONNECT rejected, proxy auth failure).
+// It never reaches the provider, so it is distinct from APIError.
+type ProxyError struct {
+	// Proxy is the proxy URL with any password redacted
+	Proxy string
+	// StatusCode is set when the proxy itself answered (e.g. 407)
+	StatusCode int
+	Err        error
+}
+
+func (e *ProxyError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("proxy %s returned %d", e.Proxy, e.StatusCode)
+	}
+	return fmt.Sprintf("proxy %s: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyError) Unwrap() error {
+	return e.Err
+}
+
+// TLSError is returned when the TLS handshake with an endpoint fails, typically
+// because its certificate is not trusted (see Config.TLS).
+type TLSError struct {
+	// Endpoint is the host:port the client tried to reach
+	Endpoint string
+	Err      error
+}
+
+func (e *TLSError) Error() string {
+	return fmt.Sprintf("TLS handshake with %s failed: %v", e.Endpoint, e.Err)
+}
+
+func (e *TLSError) Unwrap() error {
+	return e.Err
+}
+
+// classifyTransportError converts low-level transport failures into typed errors
+func classifyTransportError(config Config, req *http.Request, err error) error {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "proxyconnect" {
+		return &ProxyError{Proxy: proxyLabel(config), Err: err}
+	}
+	if tlsErr := x509Failure(err); tlsErr != nil {
+		return &TLSError{Endpoint: req.URL.Host, Err: tlsErr}
+	}
+	return err
+}
+
+// x509Failure extracts the underlying certificate error, if any
+func x509Failure(err error) error {
+	var verifyErr *tls.CertificateVerificationError
+	if errors.As(err, &verifyErr) {
+		return verifyErr.Err
+	}
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return unknownAuthority
+	}
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &hostnameErr) {
+		return hostnameErr
+	}
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &invalidErr) {
+		return invalidErr
+	}
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return recordErr
+	}
+	return nil
+}
+
+// classifyProxyResponse returns a ProxyError when a plain HTTP request was
+// rejected by the proxy rather than the provider.
+func classifyProxyResponse(config Config, resp *http.Response) error {
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return &ProxyError{
+			Proxy:      proxyLabel(config),
+			StatusCode: resp.StatusCode,
+			Err:        errors.New(resp.Status),
+		}
+	}
+	return nil
+}
+
+// proxyLabel describes the proxy in use for error messages
+func proxyLabel(config Config) string {
+	if config.ProxyURL == "" {
+		return "(from environment)"
+	}
+	return redactProxyURL(config.ProxyURL)
+}
+
+// isTransient reports whether err is worth retrying: rate limits, provider
+// 5xx and timeouts, network failures and client-side timeouts. Caller
+// cancellation and deadlines are never transient.
+func isTransient(err error) bool {
+	var timeoutErr *TimeoutError
+	if errors.As(err, &timeoutErr) {
+		return timeoutErr.Source != TimeoutCaller
+	}
+	switch ErrorClass(err) {
+	case StatusRateLimited, StatusServerError, StatusProviderTimeout, StatusNetworkError:
+		return true
+	}
+	return false
+}
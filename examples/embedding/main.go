@@ -8,10 +8,11 @@ import (
 	"time"
 
 	llm "github.com/yhwhpe/llm-unified-client"
+	"github.com/yhwhpe/llm-unified-client/embeddingutil"
 )
 
 func main() {
-	fmt.Println("=== LLM Unified Client - Embedding Examples ===\n")
+	fmt.Print("=== LLM Unified Client - Embedding Examples ===\n\n")
 
 	// Example 1: OpenAI Embeddings
 	runOpenAIEmbeddingExample()
@@ -21,6 +22,9 @@ func main() {
 
 	// Example 3: Batch Embeddings
 	runBatchEmbeddingExample()
+
+	// Example 4: Semantic Search
+	runSemanticSearchExample()
 }
 
 // runOpenAIEmbeddingExample demonstrates OpenAI embedding generation
@@ -30,17 +34,17 @@ func runOpenAIEmbeddingExample() {
 
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
-		fmt.Println("⚠️  OPENAI_API_KEY not set, skipping example\n")
+		fmt.Print("⚠️  OPENAI_API_KEY not set, skipping example\n\n")
 		return
 	}
 
 	// Create OpenAI client
 	client, err := llm.NewClient(llm.Config{
-		Provider:     llm.ProviderOpenAI,
-		APIKey:       apiKey,
-		BaseURL:      "https://api.openai.com/v1",
-		DefaultModel: "text-embedding-3-small",
-		Timeout:      30 * time.Second,
+		Provider:              llm.ProviderOpenAI,
+		APIKey:                apiKey,
+		BaseURL:               "https://api.openai.com/v1",
+		DefaultEmbeddingModel: "text-embedding-3-small",
+		Timeout:               30 * time.Second,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create OpenAI client: %v", err)
@@ -80,17 +84,17 @@ func runCohereEmbeddingExample() {
 
 	apiKey := os.Getenv("COHERE_API_KEY")
 	if apiKey == "" {
-		fmt.Println("⚠️  COHERE_API_KEY not set, skipping example\n")
+		fmt.Print("⚠️  COHERE_API_KEY not set, skipping example\n\n")
 		return
 	}
 
 	// Create Cohere client
 	client, err := llm.NewClient(llm.Config{
-		Provider:     llm.ProviderCohere,
-		APIKey:       apiKey,
-		BaseURL:      "https://api.cohere.ai/v1",
-		DefaultModel: "embed-multilingual-v3.0",
-		Timeout:      30 * time.Second,
+		Provider:              llm.ProviderCohere,
+		APIKey:                apiKey,
+		BaseURL:               "https://api.cohere.ai/v1",
+		DefaultEmbeddingModel: "embed-multilingual-v3.0",
+		Timeout:               30 * time.Second,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create Cohere client: %v", err)
@@ -130,16 +134,16 @@ func runBatchEmbeddingExample() {
 
 	apiKey := os.Getenv("OPENAI_API_KEY")
 	if apiKey == "" {
-		fmt.Println("⚠️  OPENAI_API_KEY not set, skipping example\n")
+		fmt.Print("⚠️  OPENAI_API_KEY not set, skipping example\n\n")
 		return
 	}
 
 	// Create OpenAI client
 	client, err := llm.NewClient(llm.Config{
-		Provider:     llm.ProviderOpenAI,
-		APIKey:       apiKey,
-		DefaultModel: "text-embedding-3-small",
-		Timeout:      30 * time.Second,
+		Provider:              llm.ProviderOpenAI,
+		APIKey:                apiKey,
+		DefaultEmbeddingModel: "text-embedding-3-small",
+		Timeout:               30 * time.Second,
 	})
 	if err != nil {
 		log.Fatalf("Failed to create OpenAI client: %v", err)
@@ -178,38 +182,53 @@ func runBatchEmbeddingExample() {
 	}
 
 	// Calculate cosine similarity between first two embeddings
-	similarity := cosineSimilarity(resp.Embeddings[0], resp.Embeddings[1])
+	similarity, err := embeddingutil.CosineSimilarity(resp.Embeddings[0], resp.Embeddings[1])
+	if err != nil {
+		log.Fatalf("Failed to compare embeddings: %v", err)
+	}
 	fmt.Printf("✅ Cosine similarity between text 1 and 2: %.4f\n", similarity)
 }
 
-// cosineSimilarity calculates cosine similarity between two vectors
-func cosineSimilarity(a, b []float64) float64 {
-	if len(a) != len(b) {
-		return 0
-	}
+// runSemanticSearchExample demonstrates retrieval with an in-memory VectorIndex
+func runSemanticSearchExample() {
+	fmt.Println("\n4. Semantic Search Example")
+	fmt.Println("--------------------------")
 
-	var dotProduct, normA, normB float64
-	for i := 0; i < len(a); i++ {
-		dotProduct += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		fmt.Print("⚠️  OPENAI_API_KEY not set, skipping example\n\n")
+		return
 	}
 
-	if normA == 0 || normB == 0 {
-		return 0
+	client, err := llm.NewClient(llm.Config{
+		Provider:              llm.ProviderOpenAI,
+		APIKey:                apiKey,
+		DefaultEmbeddingModel: "text-embedding-3-small",
+		Timeout:               30 * time.Second,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create OpenAI client: %v", err)
 	}
+	defer client.Close()
 
-	return dotProduct / (sqrt(normA) * sqrt(normB))
-}
+	ctx := context.Background()
+	index := llm.NewVectorIndex()
+	profiles := map[string]string{
+		"therapist": "Licensed therapist specializing in burnout and work stress",
+		"coach":     "Career coach helping professionals change industries",
+		"trainer":   "Personal trainer for strength and mobility",
+	}
+	for id, text := range profiles {
+		if err := index.AddText(ctx, client, id, text, map[string]string{"type": "profile"}); err != nil {
+			log.Fatalf("Failed to index %s: %v", id, err)
+		}
+	}
 
-// sqrt calculates square root
-func sqrt(x float64) float64 {
-	if x < 0 {
-		return 0
+	results, err := index.SearchText(ctx, client, "I feel exhausted by my job", 2, nil)
+	if err != nil {
+		log.Fatalf("Failed to search: %v", err)
 	}
-	z := 1.0
-	for i := 0; i < 10; i++ {
-		z = z - (z*z-x)/(2*z)
+	for i, result := range results {
+		fmt.Printf("%d. %s (score %.4f)\n", i+1, result.ID, result.Score)
 	}
-	return z
 }
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+)
+
+// Variant is one arm of an ABClient experiment
+type Variant struct {
+	// Name identifies the variant in weights, responses, metrics and
+	// WithVariant
+	Name   string
+	Client Client
+
+	// Mutate edits a copy of each request served by this variant, for
+	// example to try another model or prompt (nil = unchanged)
+	Mutate func(request *Request)
+}
+
+// ABConfig configures NewABClient
+type ABConfig struct {
+	// Name of the experiment, mixed into the hash so that experiments
+	// sharing keys assign them independently
+	Name string
+
+	// Variants in order; the first is the control, which serves calls
+	// without an experiment key and everything but chat calls
+	Variants []Variant
+
+	// Weights is the relative share of keys each variant gets, by name (nil
+	// = equal shares). A variant with no weight only serves forced calls.
+	Weights map[string]int
+
+	// Key selects the key a call is assigned by (nil = the key set by
+	// WithExperimentKey)
+	Key func(ctx context.Context) string
+}
+
+// ABClient splits chat calls between the variants of an experiment
+type ABClient struct {
+	Client
+	config   ABConfig
+	variants map[string]Variant
+	// bounds[i] is the sum of the weights of Variants[:i+1]
+	bounds []uint64
+}
+
+// NewABClient returns a client that serves each Generate,
+// GenerateWithHistory and GenerateStream call from a variant chosen by hashing the call's
+// experiment key, so the same key always gets the same variant while the
+// weights are unchanged. Calls without a key go to the control variant and
+// WithVariant forces a variant, for holdouts and debugging. The chosen
+// variant is set on Response.Variant and reported in RequestMetrics and
+// UsageEvent.
+func NewABClient(config ABConfig) (*ABClient, error) {
+	if len(config.Variants) == 0 {
+		return nil, errors.New("experiment needs at least one variant")
+	}
+	config.Variants = slices.Clone(config.Variants)
+	c := &ABClient{
+		Client:   config.Variants[0].Client,
+		config:   config,
+		variants: make(map[string]Variant, len(config.Variants)),
+	}
+	for _, variant := range config.Variants {
+		if variant.Name == "" || variant.Client == nil {
+			return nil, errors.New("experiment variants need a name and a client")
+		}
+		if _, ok := c.variants[variant.Name]; ok {
+			return nil, fmt.Errorf("duplicate experiment variant %q", variant.Name)
+		}
+		c.variants[variant.Name] = variant
+	}
+	for name := range config.Weights {
+		if _, ok := c.variants[name]; !ok {
+			return nil, fmt.Errorf("weight for unknown experiment variant %q", name)
+		}
+	}
+	var total uint64
+	for _, variant := range config.Variants {
+		weight := 1
+		if config.Weights != nil {
+			weight = config.Weights[variant.Name]
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("negative weight for experiment variant %q", variant.Name)
+		}
+		total += uint64(weight)
+		c.bounds = append(c.bounds, total)
+	}
+	if total == 0 {
+		return nil, errors.New("experiment weights are all zero")
+	}
+	return c, nil
+}
+
+// Generate serves the request from the variant assigned to the call
+func (c *ABClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	ctx, variant, request := c.route(ctx, request)
+	response, err := variant.Client.Generate(ctx, request)
+	if response != nil {
+		response.Variant = variant.Name
+	}
+	return response, err
+}
+
+// GenerateStream streams the request from the variant assigned to the call
+func (c *ABClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	ctx, variant, request := c.route(ctx, request)
+	response, err := GenerateStream(ctx, variant.Client, request)
+	if response != nil {
+		response.Variant = variant.Name
+	}
+	return response, err
+}
+
+// TryGenerate serves the request from the variant assigned to the call if
+// that variant's client can send it without waiting (see TryGenerate)
+func (c *ABClient) TryGenerate(ctx context.Context, request Request) (*Response, error) {
+	ctx, variant, request := c.route(ctx, request)
+	response, err := TryGenerate(ctx, variant.Client, request)
+	if response != nil {
+		response.Variant = variant.Name
+	}
+	return response, err
+}
+
+// EstimateWait reports the wait of the variant assigned to the call
+func (c *ABClient) EstimateWait(ctx context.Context, request Request) WaitEstimate {
+	ctx, variant, request := c.route(ctx, request)
+	return EstimateWait(ctx, variant.Client, request)
+}
+
+// route assigns a call to a variant and returns its context and request
+func (c *ABClient) route(ctx context.Context, request Request) (context.Context, Variant, Request) {
+	variant := c.Assign(ctx)
+	ctx = WithVariant(ctx, variant.Name)
+	if variant.Mutate != nil {
+		request = request.Clone()
+		variant.Mutate(&request)
+	}
+	return ctx, variant, request
+}
+
+// GenerateWithHistory serves the conversation from the variant assigned to the call
+func (c *ABClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	request := BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// Close closes the client of every variant
+func (c *ABClient) Close() error {
+	var errs []error
+	for _, variant := range c.config.Variants {
+		errs = append(errs, variant.Client.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Assign returns the variant that serves calls made with ctx: the one
+// forced by WithVariant, the control for calls without a key, or the one
+// the key hashes to
+func (c *ABClient) Assign(ctx context.Context) Variant {
+	if name, ok := VariantFromContext(ctx); ok {
+		if variant, ok := c.variants[name]; ok {
+			return variant
+		}
+	}
+	key := c.key(ctx)
+	if key == "" {
+		return c.config.Variants[0]
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(c.config.Name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	bucket := hash.Sum64() % c.bounds[len(c.bounds)-1]
+	i := 0
+	for c.bounds[i] <= bucket {
+		i++
+	}
+	return c.config.Variants[i]
+}
+
+// key returns the experiment key of a call
+func (c *ABClient) key(ctx context.Context) string {
+	if c.config.Key != nil {
+		return c.config.Key(ctx)
+	}
+	key, _ := ExperimentKeyFromContext(ctx)
+	return key
+}
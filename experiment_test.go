@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"testing"
+)
+
+func TestABClientAssignment(t *testing.T) {
+	reply := func(Request) (*Response, error) { return &Response{Content: "ok"}, nil }
+	client, err := NewABClient(ABConfig{
+		Name: "prompt-v2",
+		Variants: []Variant{
+			{Name: "control", Client: &scriptedClient{reply: reply}},
+			{Name: "treatment", Client: &scriptedClient{reply: reply}},
+			{Name: "debug", Client: &scriptedClient{reply: reply}},
+		},
+		Weights: map[string]int{"control": 3, "treatment": 1},
+	})
+	if err != nil {
+		t.Fatalf("NewABClient failed: %v", err)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 4000; i++ {
+		ctx := WithExperimentKey(context.Background(), fmt.Sprintf("user-%d", i))
+		name := client.Assign(ctx).Name
+		if again := client.Assign(ctx).Name; again != name {
+			t.Fatalf("Key user-%d moved from %s to %s", i, name, again)
+		}
+		counts[name]++
+	}
+	if share := float64(counts["treatment"]) / 4000; math.Abs(share-0.25) > 0.03 || counts["debug"] != 0 {
+		t.Errorf("Expected a quarter of the keys in treatment and none in debug, got %v", counts)
+	}
+
+	if got := client.Assign(context.Background()).Name; got != "control" {
+		t.Errorf("Expected calls without a key in control, got %s", got)
+	}
+	forced := WithVariant(WithExperimentKey(context.Background(), "user-1"), "debug")
+	response, err := client.Generate(forced, BuildSimpleRequest("Hello"))
+	if err != nil || response.Variant != "debug" {
+		t.Errorf("Expected the forced variant, got %+v, %v", response, err)
+	}
+
+	for name, config := range map[string]ABConfig{
+		"no variants":    {},
+		"unknown weight": {Variants: []Variant{{Name: "a", Client: &scriptedClient{}}}, Weights: map[string]int{"b": 1}},
+		"zero weights":   {Variants: []Variant{{Name: "a", Client: &scriptedClient{}}}, Weights: map[string]int{"a": 0}},
+		"duplicate":      {Variants: []Variant{{Name: "a", Client: &scriptedClient{}}, {Name: "a", Client: &scriptedClient{}}}},
+	} {
+		if _, err := NewABClient(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestABClientReporting(t *testing.T) {
+	var models []string
+	server := newChatServer(t, func(r *http.Request) {
+		var payload chatPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		models = append(models, payload.Model)
+	})
+	metrics := &recordingMetrics{}
+	var events []UsageEvent
+	inner, err := NewClient(Config{
+		Provider:     ProviderOpenAI,
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		DefaultModel: "gpt-4o-mini",
+		Metrics:      metrics,
+		OnUsage:      func(ctx context.Context, event UsageEvent) { events = append(events, event) },
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	original := BuildSimpleRequest("Hello")
+	client, err := NewABClient(ABConfig{
+		Variants: []Variant{
+			{Name: "mini", Client: inner},
+			{Name: "large", Client: inner, Mutate: func(r *Request) { r.Apply(WithModel("gpt-4o")) }},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewABClient failed: %v", err)
+	}
+
+	ctx := context.Background()
+	client.Generate(ctx, original)
+	response, err := client.Generate(WithVariant(ctx, "large"), original)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if response.Variant != "large" || original.Model != nil {
+		t.Errorf("Expected the variant on the response and the caller's request untouched, got %q", response.Variant)
+	}
+	if len(models) != 2 || models[0] != "gpt-4o-mini" || models[1] != "gpt-4o" {
+		t.Errorf("Expected the large variant to change the model, got %v", models)
+	}
+	if len(metrics.observations) != 2 || metrics.observations[0].Variant != "mini" || metrics.observations[1].Variant != "large" {
+		t.Errorf("Expected the variants in the metrics, got %+v", metrics.observations)
+	}
+	if len(events) != 2 || events[0].Variant != "mini" || events[1].Variant != "large" {
+		t.Errorf("Expected the variants in the usage events, got %+v", events)
+	}
+}
@@ -0,0 +1,47 @@
+package llm
+
+import "fmt"
+
+// Example is a few-shot (user, assistant) pair
+type Example struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+}
+
+// AddExample adds a few-shot pair. Examples go after any existing examples
+// and before the final user turn, if the request already has one, so the
+// real question always comes last. A pair with an empty half is rejected.
+func (r *Request) AddExample(userContent, assistantContent string) error {
+	if userContent == "" || assistantContent == "" {
+		return fmt.Errorf("few-shot example needs both a user and an assistant message")
+	}
+
+	at := len(r.Messages)
+	if at > 0 && r.Messages[at-1].Role == RoleUser {
+		at--
+	}
+	messages := make([]Message, 0, len(r.Messages)+2)
+	messages = append(messages, r.Messages[:at]...)
+	messages = append(messages,
+		Message{Role: RoleUser, Content: userContent},
+		Message{Role: RoleAssistant, Content: assistantContent},
+	)
+	r.Messages = append(messages, r.Messages[at:]...)
+	return nil
+}
+
+// BuildFewShotRequest creates a request with a system prompt (optional),
+// the example pairs in order and the final user message
+func BuildFewShotRequest(system string, examples []Example, userMessage string) (Request, error) {
+	var request Request
+	if system != "" {
+		request.Messages = append(request.Messages, Message{Role: RoleSystem, Content: system})
+	}
+	for i, example := range examples {
+		if err := request.AddExample(example.User, example.Assistant); err != nil {
+			return Request{}, fmt.Errorf("example %d: %w", i, err)
+		}
+	}
+	request.AddUserMessage(userMessage)
+	return request, nil
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildFewShotRequest(t *testing.T) {
+	request, err := BuildFewShotRequest("Classify sentiment.", []Example{
+		{User: "I love it", Assistant: "positive"},
+		{User: "Awful", Assistant: "negative"},
+	}, "Not bad")
+	if err != nil {
+		t.Fatalf("BuildFewShotRequest failed: %v", err)
+	}
+
+	want := []Message{
+		{Role: RoleSystem, Content: "Classify sentiment."},
+		{Role: RoleUser, Content: "I love it"},
+		{Role: RoleAssistant, Content: "positive"},
+		{Role: RoleUser, Content: "Awful"},
+		{Role: RoleAssistant, Content: "negative"},
+		{Role: RoleUser, Content: "Not bad"},
+	}
+	if !reflect.DeepEqual(request.Messages, want) {
+		t.Errorf("Messages = %+v", request.Messages)
+	}
+
+	// Adding an example later keeps the real question last
+	if err := request.AddExample("Meh", "neutral"); err != nil {
+		t.Fatalf("AddExample failed: %v", err)
+	}
+	n := len(request.Messages)
+	if request.Messages[n-3].Content != "Meh" || request.Messages[n-2].Content != "neutral" || request.Messages[n-1].Content != "Not bad" {
+		t.Errorf("Example not inserted before the final user turn: %+v", request.Messages)
+	}
+
+	if _, err := BuildFewShotRequest("", []Example{{User: "dangling"}}, "q"); err == nil {
+		t.Error("Expected an error for an example without an assistant reply")
+	}
+}
@@ -0,0 +1,71 @@
+package llm
+
+// FinishReason is why the model stopped generating, normalized across
+// providers. The provider's own value is kept in RawFinishReason.
+type FinishReason string
+
+// Normalized finish reasons
+const (
+	// FinishStop is a natural end of the answer or a stop sequence
+	FinishStop FinishReason = "stop"
+	// FinishLength means the answer was cut off by the token limit
+	FinishLength FinishReason = "length"
+	// FinishToolCalls means the model stopped to call tools
+	FinishToolCalls FinishReason = "tool_calls"
+	// FinishContentFilter means the answer was withheld or cut by a safety filter
+	FinishContentFilter FinishReason = "content_filter"
+	// FinishOther is any reason without a normalized equivalent
+	FinishOther FinishReason = "other"
+)
+
+// openAIFinishReasons maps the finish reasons of OpenAI-compatible chat
+// completions (OpenAI, Azure, DeepSeek, Qwen)
+var openAIFinishReasons = map[string]FinishReason{
+	"stop":           FinishStop,
+	"length":         FinishLength,
+	"tool_calls":     FinishToolCalls,
+	"function_call":  FinishToolCalls,
+	"content_filter": FinishContentFilter,
+}
+
+// responsesFinishReasons maps Responses API statuses and incomplete reasons
+var responsesFinishReasons = map[string]FinishReason{
+	"completed":         FinishStop,
+	"max_output_tokens": FinishLength,
+	"content_filter":    FinishContentFilter,
+}
+
+// cohereFinishReasons maps Cohere finish reasons
+var cohereFinishReasons = map[string]FinishReason{
+	"COMPLETE":      FinishStop,
+	"STOP_SEQUENCE": FinishStop,
+	"MAX_TOKENS":    FinishLength,
+	"TOOL_CALL":     FinishToolCalls,
+	"ERROR_TOXIC":   FinishContentFilter,
+}
+
+// geminiFinishReasons maps Gemini finish reasons; the blocking ones normally
+// surface as a ContentFilteredError instead
+var geminiFinishReasons = map[string]FinishReason{
+	"STOP":                 FinishStop,
+	"MAX_TOKENS":           FinishLength,
+	"UNEXPECTED_TOOL_CALL": FinishToolCalls,
+	"SAFETY":               FinishContentFilter,
+	"RECITATION":           FinishContentFilter,
+	"BLOCKLIST":            FinishContentFilter,
+	"PROHIBITED_CONTENT":   FinishContentFilter,
+	"SPII":                 FinishContentFilter,
+	"IMAGE_SAFETY":         FinishContentFilter,
+}
+
+// normalizeFinishReason looks raw up in a provider's table. Unknown reasons
+// become FinishOther; an empty raw reason stays empty.
+func normalizeFinishReason(table map[string]FinishReason, raw string) FinishReason {
+	if raw == "" {
+		return ""
+	}
+	if reason, ok := table[raw]; ok {
+		return reason
+	}
+	return FinishOther
+}
@@ -0,0 +1,117 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFinishReasonMapping(t *testing.T) {
+	openAIBody := func(raw string) string {
+		return fmt.Sprintf(`{"choices": [{"message": {"role": "assistant", "content": "Hi"}, "finish_reason": %q}], "usage": {"total_tokens": 3}}`, raw)
+	}
+	providers := []struct {
+		config Config
+		body   func(raw string) string
+		table  map[string]FinishReason
+	}{
+		{
+			config: Config{Provider: ProviderOpenAI},
+			body:   openAIBody,
+			table: map[string]FinishReason{
+				"stop": FinishStop, "length": FinishLength, "tool_calls": FinishToolCalls,
+				"function_call": FinishToolCalls, "content_filter": FinishContentFilter,
+			},
+		},
+		{
+			config: Config{Provider: ProviderDeepSeek},
+			body:   openAIBody,
+			table: map[string]FinishReason{
+				"stop": FinishStop, "length": FinishLength, "content_filter": FinishContentFilter,
+				"insufficient_system_resource": FinishOther,
+			},
+		},
+		{
+			config: Config{Provider: ProviderAzure, BaseURL: "/openai/deployments/chat"},
+			body:   openAIBody,
+			table:  map[string]FinishReason{"stop": FinishStop, "length": FinishLength, "content_filter": FinishContentFilter},
+		},
+		{
+			config: Config{Provider: ProviderQwen},
+			body:   openAIBody,
+			table:  map[string]FinishReason{"stop": FinishStop, "length": FinishLength, "tool_calls": FinishToolCalls},
+		},
+		{
+			config: Config{Provider: ProviderCohere},
+			body: func(raw string) string {
+				return fmt.Sprintf(`{"text": "Hi", "finish_reason": %q}`, raw)
+			},
+			table: map[string]FinishReason{
+				"COMPLETE": FinishStop, "STOP_SEQUENCE": FinishStop, "MAX_TOKENS": FinishLength,
+				"TOOL_CALL": FinishToolCalls, "ERROR_TOXIC": FinishContentFilter, "ERROR": FinishOther,
+			},
+		},
+		{
+			config: Config{Provider: ProviderGemini},
+			body: func(raw string) string {
+				return fmt.Sprintf(`{"candidates": [{"content": {"parts": [{"text": "Hi"}], "role": "model"}, "finishReason": %q}]}`, raw)
+			},
+			table: map[string]FinishReason{
+				"STOP": FinishStop, "MAX_TOKENS": FinishLength, "UNEXPECTED_TOOL_CALL": FinishToolCalls,
+				"MALFORMED_FUNCTION_CALL": FinishOther, "OTHER": FinishOther,
+			},
+		},
+		{
+			config: Config{Provider: ProviderOpenAI, UseResponsesAPI: true},
+			body: func(raw string) string {
+				if raw == "completed" {
+					return `{"status": "completed", "output": [{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Hi"}]}]}`
+				}
+				return fmt.Sprintf(`{"status": "incomplete", "incomplete_details": {"reason": %q},
+					"output": [{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Hi"}]}]}`, raw)
+			},
+			table: map[string]FinishReason{"completed": FinishStop, "max_output_tokens": FinishLength, "content_filter": FinishContentFilter},
+		},
+	}
+
+	for _, provider := range providers {
+		name := string(provider.config.Provider)
+		if provider.config.UseResponsesAPI {
+			name += " responses"
+		}
+		t.Run(name, func(t *testing.T) {
+			var body string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(body))
+			}))
+			defer server.Close()
+
+			config := provider.config
+			config.APIKey = "test-key"
+			config.BaseURL = server.URL + config.BaseURL
+			client, _ := NewClient(config)
+			for raw, want := range provider.table {
+				body = provider.body(raw)
+				response, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+				if err != nil {
+					t.Fatalf("%s: Generate failed: %v", raw, err)
+				}
+				if response.FinishReason != want || response.RawFinishReason != raw {
+					t.Errorf("%s: expected %q, got %q (raw %q)", raw, want, response.FinishReason, response.RawFinishReason)
+				}
+			}
+		})
+	}
+}
+
+func TestGeminiFunctionCallFinishReason(t *testing.T) {
+	response, err := parseGeminiContent([]byte(`{"candidates": [{"content": {"parts": [{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}}], "role": "model"}, "finishReason": "STOP"}]}`))
+	if err != nil {
+		t.Fatalf("parseGeminiContent failed: %v", err)
+	}
+	if response.FinishReason != FinishToolCalls || response.RawFinishReason != "STOP" {
+		t.Errorf("Expected tool_calls for a function call, got %q (raw %q)", response.FinishReason, response.RawFinishReason)
+	}
+}
@@ -0,0 +1,193 @@
+package llm_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+	"github.com/yhwhpe/llm-unified-client/llmtest"
+)
+
+// fixtureClient creates a client whose HTTP traffic goes through a
+// record/replay transport backed by testdata/fixtures. Without LLM_RECORD the
+// committed fixtures are replayed and no credentials are needed; with
+// LLM_RECORD=1 the provider is called using the key from keyEnv.
+func fixtureClient(t *testing.T, config llm.Config, keyEnv string) llm.Client {
+	t.Helper()
+	recorder := llmtest.NewRecorderFromEnv("testdata/fixtures")
+	config.APIKey = os.Getenv(keyEnv)
+	if config.APIKey == "" {
+		if recorder.Recording() {
+			t.Skipf("%s not set, cannot record", keyEnv)
+		}
+		config.APIKey = "replay-key"
+	}
+	config.HTTPClient = &http.Client{Transport: recorder}
+
+	client, err := llm.NewClient(config)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestProviderFixturesChat(t *testing.T) {
+	maxTokens := 16
+	tests := []struct {
+		name   string
+		config llm.Config
+		keyEnv string
+	}{
+		{name: "openai", config: llm.Config{Provider: llm.ProviderOpenAI, DefaultModel: "gpt-4o-mini"}, keyEnv: "OPENAI_API_KEY"},
+		{name: "qwen", config: llm.Config{Provider: llm.ProviderQwen, DefaultModel: "qwen-turbo"}, keyEnv: "QWEN_API_KEY"},
+		{name: "azure", config: llm.Config{
+			Provider: llm.ProviderAzure,
+			// Only the path is part of the fixture key, so record against a deployment named gpt-4o-mini
+			BaseURL: "https://fixtures.openai.azure.com/openai/deployments/gpt-4o-mini",
+		}, keyEnv: "AZURE_OPENAI_API_KEY"},
+		{name: "cohere", config: llm.Config{Provider: llm.ProviderCohere, DefaultModel: "command-r"}, keyEnv: "COHERE_API_KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.config.Provider == llm.ProviderAzure && os.Getenv("AZURE_OPENAI_BASE_URL") != "" {
+				tt.config.BaseURL = os.Getenv("AZURE_OPENAI_BASE_URL")
+			}
+			client := fixtureClient(t, tt.config, tt.keyEnv)
+
+			request := llm.BuildRequestWithSystemPrompt("Answer with a single word.", "What is the capital of France?")
+			request.MaxTokens = &maxTokens
+			response, err := client.Generate(context.Background(), request)
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if response.Content == "" {
+				t.Error("Expected non-empty content")
+			}
+			if response.Usage.PromptTokens == 0 || response.Usage.CompletionTokens == 0 {
+				t.Errorf("Expected token usage breakdown, got %+v", response.Usage)
+			}
+			if response.Usage.TotalTokens != response.TokensUsed {
+				t.Errorf("Expected TokensUsed to match Usage.TotalTokens, got %d and %d", response.TokensUsed, response.Usage.TotalTokens)
+			}
+			if response.FinishReason == "" && tt.config.Provider != llm.ProviderQwen {
+				t.Error("Expected a finish reason")
+			}
+		})
+	}
+}
+
+func TestProviderFixturesEmbedding(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    llm.Config
+		keyEnv    string
+		dimension int
+	}{
+		{name: "openai", config: llm.Config{Provider: llm.ProviderOpenAI}, keyEnv: "OPENAI_API_KEY", dimension: 1536},
+		{name: "cohere", config: llm.Config{Provider: llm.ProviderCohere}, keyEnv: "COHERE_API_KEY", dimension: 1024},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fixtureClient(t, tt.config, tt.keyEnv)
+
+			response, err := client.CreateEmbedding(context.Background(), llm.EmbeddingRequest{
+				Input: []string{"The quick brown fox", "jumps over the lazy dog"},
+			})
+			if err != nil {
+				t.Fatalf("CreateEmbedding failed: %v", err)
+			}
+			if len(response.Embeddings) != 2 {
+				t.Fatalf("Expected 2 embeddings, got %d", len(response.Embeddings))
+			}
+			for i, embedding := range response.Embeddings {
+				if len(embedding) != tt.dimension {
+					t.Errorf("Embedding %d: expected %d dimensions, got %d", i, tt.dimension, len(embedding))
+				}
+			}
+			if response.Usage.PromptTokens == 0 {
+				t.Error("Expected prompt token usage")
+			}
+		})
+	}
+}
+
+func TestProviderFixturesToolConversation(t *testing.T) {
+	description := "Get the current weather for a city"
+	parameters := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+		"required":   []string{"city"},
+	}
+	tests := []struct {
+		name   string
+		config llm.Config
+		keyEnv string
+		// tools declares get_weather in the provider's format
+		tools interface{}
+	}{
+		{name: "openai", config: llm.Config{Provider: llm.ProviderOpenAI, DefaultModel: "gpt-4o-mini"}, keyEnv: "OPENAI_API_KEY", tools: []interface{}{
+			map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "get_weather", "description": description, "parameters": parameters}},
+		}},
+		{name: "responses", config: llm.Config{Provider: llm.ProviderOpenAI, DefaultModel: "gpt-4o-mini", UseResponsesAPI: true}, keyEnv: "OPENAI_API_KEY", tools: []interface{}{
+			map[string]interface{}{"type": "function", "name": "get_weather", "description": description, "parameters": parameters},
+		}},
+		{name: "gemini", config: llm.Config{Provider: llm.ProviderGemini, DefaultModel: "gemini-2.5-flash"}, keyEnv: "GEMINI_API_KEY", tools: []interface{}{
+			map[string]interface{}{"functionDeclarations": []interface{}{
+				map[string]interface{}{"name": "get_weather", "description": description, "parameters": parameters},
+			}},
+		}},
+		{name: "cohere", config: llm.Config{Provider: llm.ProviderCohere, DefaultModel: "command-r"}, keyEnv: "COHERE_API_KEY", tools: []interface{}{
+			map[string]interface{}{"name": "get_weather", "description": description, "parameter_definitions": map[string]interface{}{
+				"city": map[string]interface{}{"type": "str", "required": true},
+			}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			client := fixtureClient(t, tt.config, tt.keyEnv)
+			store, err := llm.NewFileHistoryStore(t.TempDir())
+			if err != nil {
+				t.Fatalf("Failed to create store: %v", err)
+			}
+
+			var history llm.ChatHistory
+			history.AddUserMessage("What's the weather in Paris?")
+			response, err := client.Generate(ctx, llm.Request{Messages: history.Messages, ExtraParams: map[string]interface{}{"tools": tt.tools}})
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if response.FinishReason != llm.FinishToolCalls || len(response.ToolCalls) != 1 {
+				t.Fatalf("Expected one tool call, got %q %+v", response.FinishReason, response.ToolCalls)
+			}
+			call := response.ToolCalls[0]
+			if call.ID == "" || call.Name != "get_weather" || !strings.Contains(call.Arguments, "Paris") {
+				t.Fatalf("Unexpected tool call %+v", call)
+			}
+			history.AddToolCalls(response.Content, response.ToolCalls)
+			history.AddToolResult(call.ID, `{"temperature_c":18,"conditions":"cloudy"}`)
+
+			// Resume the conversation from the store, as a later request would
+			if err := store.Put(ctx, tt.name, history); err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			restored, err := store.Get(ctx, tt.name)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			response, err = client.Generate(ctx, llm.Request{Messages: restored.Messages, ExtraParams: map[string]interface{}{"tools": tt.tools}})
+			if err != nil {
+				t.Fatalf("Generate with tool results failed: %v", err)
+			}
+			if !strings.Contains(response.Content, "18") || len(response.ToolCalls) != 0 {
+				t.Errorf("Expected an answer using the tool result, got %q %+v", response.Content, response.ToolCalls)
+			}
+		})
+	}
+}
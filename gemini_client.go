@@ -0,0 +1,643 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// geminiClient implements Client for the Google Gemini API
+type geminiClient struct {
+	config     Config
+	httpClient *http.Client
+	requests   *requestTracker
+	models     *modelCache
+	*liveConfig
+}
+
+// newGeminiClient creates a new Gemini client
+func newGeminiClient(config Config) (*geminiClient, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = "https://generativelanguage.googleapis.com/v1beta"
+	}
+	config, err := normalizeBaseURL(config, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	if config.DefaultModel == "" {
+		config.DefaultModel = "gemini-2.5-flash"
+	}
+	config = withEmbeddingDefault(config, "gemini-embedding-001")
+
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, requests := trackRequests(config, httpClient)
+
+	return &geminiClient{
+		config:     config,
+		httpClient: httpClient,
+		requests:   requests,
+		models:     &modelCache{},
+		liveConfig: newLiveConfig(config),
+	}, nil
+}
+
+// current returns a copy of the client with the configuration as of now, so
+// that a call sees one consistent set of defaults
+func (c *geminiClient) current() *geminiClient {
+	snapshot := *c
+	snapshot.config = c.load()
+	return &snapshot
+}
+
+// WithModel returns a view of the client using model by default
+func (c *geminiClient) WithModel(model string) Client {
+	return c.WithDefaults(WithModel(model))
+}
+
+// WithDefaults returns a view of the client with the defaults set by opts
+func (c *geminiClient) WithDefaults(opts ...RequestOption) Client {
+	view := c.current()
+	view.config = applyDefaults(view.config, opts)
+	view.httpClient, view.requests = c.requests.view(c.httpClient)
+	view.liveConfig = newLiveConfig(view.config)
+	return view
+}
+
+// Generate sends a request to Gemini and returns the response
+func (c *geminiClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentGenerate(ctx, c.config, c.getModel, request, c.generate)
+}
+
+// generate calls generateContent without instrumentation
+func (c *geminiClient) generate(ctx context.Context, request Request) (*Response, error) {
+	startTime := time.Now()
+	model := geminiModelName(c.getModel(request.Model))
+
+	payload, err := c.buildPayload(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/"+model+":generateContent"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-goog-api-key", c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.config, "Gemini API error", resp, body)
+	}
+
+	response, err := parseGeminiContent(body)
+	if err != nil {
+		return nil, err
+	}
+	if response.Model == "" {
+		response.Model = strings.TrimPrefix(model, "models/")
+	}
+	response.ResponseTime = time.Since(startTime)
+	response.RequestID = req.Header.Get(requestIDHeader)
+	return response, nil
+}
+
+// GenerateStream streams a chat response (see the package-level GenerateStream)
+func (c *geminiClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentStream(ctx, c.config, c.getModel, request, c.stream)
+}
+
+// stream opens a streamGenerateContent event stream (alt=sse)
+func (c *geminiClient) stream(ctx context.Context, request Request) (*providerStream, error) {
+	model := geminiModelName(c.getModel(request.Model))
+
+	payload, err := c.buildPayload(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/"+model+":streamGenerateContent?alt=sse"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("x-goog-api-key", c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	body, err := openSSE(c.httpClient, c.config, req, "Gemini API error")
+	if err != nil {
+		return nil, err
+	}
+	return &providerStream{body: body, requestID: req.Header.Get(requestIDHeader), decode: geminiEventDecoder()}, nil
+}
+
+// geminiEventDecoder returns the decoder of one streamGenerateContent
+// stream. Every event is a complete GenerateContentResponse holding the next
+// text and functionCall parts and the usage so far; the last one has the
+// finish reason. Function calls arrive whole and are numbered across the
+// stream.
+func geminiEventDecoder() func(event sseEvent) (StreamChunk, error) {
+	calls := 0
+	return func(event sseEvent) (StreamChunk, error) {
+		response, err := parseGeminiContent(event.Data)
+		if err != nil {
+			return StreamChunk{}, err
+		}
+		chunk := StreamChunk{
+			Content:          response.Content,
+			ReasoningContent: response.ReasoningContent,
+			FinishReason:     response.FinishReason,
+			RawFinishReason:  response.RawFinishReason,
+		}
+		for i, call := range response.ToolCalls {
+			if call.ID == generatedToolCallID(call.Name, i) {
+				// number generated IDs across events, not within one
+				call.ID = generatedToolCallID(call.Name, calls)
+			}
+			chunk.ToolCallDeltas = append(chunk.ToolCallDeltas, ToolCallDelta{Index: calls, ID: call.ID, Name: call.Name, Arguments: call.Arguments})
+			calls++
+		}
+		if response.Usage.TotalTokens > 0 {
+			chunk.Usage = &response.Usage
+		}
+		return chunk, nil
+	}
+}
+
+// geminiSafetyRating is a safety rating of a Gemini prompt or candidate
+type geminiSafetyRating struct {
+	Category    HarmCategory `json:"category"`
+	Probability string       `json:"probability"`
+	Blocked     bool         `json:"blocked"`
+}
+
+// geminiBlockReasons are the candidate finish reasons that mean the
+// response was withheld by a filter
+var geminiBlockReasons = map[string]bool{
+	"SAFETY":             true,
+	"RECITATION":         true,
+	"BLOCKLIST":          true,
+	"PROHIBITED_CONTENT": true,
+	"SPII":               true,
+	"IMAGE_SAFETY":       true,
+}
+
+// parseGeminiContent parses a generateContent response. A blocked prompt or
+// candidate fails with a ContentFilteredError naming the triggering rating.
+func parseGeminiContent(body []byte) (*Response, error) {
+	var apiResp struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text         string `json:"text"`
+					Thought      bool   `json:"thought"`
+					FunctionCall *struct {
+						ID   string          `json:"id"`
+						Name string          `json:"name"`
+						Args json.RawMessage `json:"args"`
+					} `json:"functionCall"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason  string               `json:"finishReason"`
+			SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+		} `json:"candidates"`
+		PromptFeedback struct {
+			BlockReason   string               `json:"blockReason"`
+			SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
+		} `json:"promptFeedback"`
+		UsageMetadata struct {
+			PromptTokenCount        int `json:"promptTokenCount"`
+			CandidatesTokenCount    int `json:"candidatesTokenCount"`
+			ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+			TotalTokenCount         int `json:"totalTokenCount"`
+			CachedContentTokenCount int `json:"cachedContentTokenCount"`
+		} `json:"usageMetadata"`
+		ModelVersion string `json:"modelVersion"`
+	}
+
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	if reason := apiResp.PromptFeedback.BlockReason; reason != "" {
+		return nil, geminiContentFiltered(reason, apiResp.PromptFeedback.SafetyRatings)
+	}
+	if len(apiResp.Candidates) == 0 {
+		return nil, fmt.Errorf("no candidates in Gemini response")
+	}
+
+	candidate := apiResp.Candidates[0]
+	if geminiBlockReasons[candidate.FinishReason] {
+		return nil, geminiContentFiltered(candidate.FinishReason, candidate.SafetyRatings)
+	}
+
+	var content, reasoning strings.Builder
+	var toolCalls []ToolCall
+	finishReason := normalizeFinishReason(geminiFinishReasons, candidate.FinishReason)
+	for _, part := range candidate.Content.Parts {
+		switch {
+		case part.FunctionCall != nil:
+			call := ToolCall{ID: part.FunctionCall.ID, Name: part.FunctionCall.Name, Arguments: string(part.FunctionCall.Args)}
+			if call.ID == "" {
+				call.ID = generatedToolCallID(call.Name, len(toolCalls))
+			}
+			toolCalls = append(toolCalls, call)
+			// Gemini reports STOP after function calls
+			if finishReason == FinishStop {
+				finishReason = FinishToolCalls
+			}
+		case part.Thought:
+			reasoning.WriteString(part.Text)
+		default:
+			content.WriteString(part.Text)
+		}
+	}
+
+	usage := apiResp.UsageMetadata
+	return &Response{
+		Content:    content.String(),
+		Role:       RoleAssistant,
+		TokensUsed: usage.TotalTokenCount,
+		Usage: Usage{
+			PromptTokens: usage.PromptTokenCount,
+			// Thinking tokens are billed as output
+			CompletionTokens: usage.CandidatesTokenCount + usage.ThoughtsTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+			CachedTokens:     usage.CachedContentTokenCount,
+		},
+		Model:            apiResp.ModelVersion,
+		FinishReason:     finishReason,
+		RawFinishReason:  candidate.FinishReason,
+		ReasoningContent: reasoning.String(),
+		ToolCalls:        toolCalls,
+	}, nil
+}
+
+// geminiContentFiltered builds the ContentFilteredError for a block,
+// reporting the rating marked as blocked, or else the most likely one
+func geminiContentFiltered(reason string, ratings []geminiSafetyRating) error {
+	err := &ContentFilteredError{Provider: ProviderGemini, Reason: reason}
+	probabilities := map[string]int{"NEGLIGIBLE": 1, "LOW": 2, "MEDIUM": 3, "HIGH": 4}
+	var trigger *geminiSafetyRating
+	for i, rating := range ratings {
+		if rating.Blocked {
+			trigger = &ratings[i]
+			break
+		}
+		if trigger == nil || probabilities[rating.Probability] > probabilities[trigger.Probability] {
+			trigger = &ratings[i]
+		}
+	}
+	if trigger != nil {
+		err.Category = trigger.Category
+		err.Probability = trigger.Probability
+	}
+	return err
+}
+
+// GenerateWithHistory generates a response using chat history
+func (c *geminiClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	request := BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// CreateEmbedding generates embeddings for the given text(s)
+func (c *geminiClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	c = c.current()
+	return instrumentEmbedding(ctx, c.config, c.getEmbeddingModel(request.Model), request, c.createEmbedding)
+}
+
+// createEmbedding calls batchEmbedContents, which takes up to 100 inputs
+// (Capabilities.MaxEmbeddingBatch, so larger requests are split)
+func (c *geminiClient) createEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	startTime := time.Now()
+	model := geminiModelName(c.getEmbeddingModel(request.Model))
+
+	type part struct {
+		Text string `json:"text"`
+	}
+	type content struct {
+		Parts []part `json:"parts"`
+	}
+	type embedRequest struct {
+		Model                string  `json:"model"`
+		Content              content `json:"content"`
+		TaskType             string  `json:"taskType,omitempty"`
+		Title                string  `json:"title,omitempty"`
+		OutputDimensionality *int    `json:"outputDimensionality,omitempty"`
+	}
+	requests := make([]embedRequest, len(request.Input))
+	for i, input := range request.Input {
+		requests[i] = embedRequest{
+			Model:                model,
+			Content:              content{Parts: []part{{Text: input}}},
+			TaskType:             embeddingTaskValue(c.config.Provider, request.Task),
+			OutputDimensionality: request.Dimensions,
+		}
+		// Gemini only accepts a title for document embeddings
+		if requests[i].TaskType == "RETRIEVAL_DOCUMENT" {
+			requests[i].Title = request.Title
+		}
+	}
+
+	jsonPayload, err := json.Marshal(map[string]interface{}{"requests": requests})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/"+model+":batchEmbedContents"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+
+	req.Header.Set("x-goog-api-key", c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send embedding request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(c.config, resp, defaultMaxEmbeddingResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.config, "Gemini Embedding API error", resp, body)
+	}
+
+	var apiResp struct {
+		Embeddings []struct {
+			Values json.RawMessage `json:"values"`
+		} `json:"embeddings"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal embedding response: %w", err)
+	}
+
+	if len(apiResp.Embeddings) == 0 {
+		return nil, fmt.Errorf("no embeddings in response")
+	}
+
+	raws := make([]json.RawMessage, len(apiResp.Embeddings))
+	for i, embedding := range apiResp.Embeddings {
+		raws[i] = embedding.Values
+	}
+
+	// Gemini does not report token usage for embeddings
+	response := &EmbeddingResponse{
+		Model:     strings.TrimPrefix(model, "models/"),
+		RequestID: req.Header.Get(requestIDHeader),
+	}
+	if err := decodeEmbeddings(raws, request.AsFloat32, response); err != nil {
+		return nil, err
+	}
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// Close stops new requests, waits up to Config.CloseTimeout for those in
+// flight and closes the client's idle connections
+func (c *geminiClient) Close() error {
+	return c.requests.close(c.config.CloseTimeout)
+}
+
+// GetConfig returns a copy of the client configuration with the API key masked
+func (c *geminiClient) GetConfig() Config {
+	return maskedConfig(c.load())
+}
+
+// GetConfigWithSecrets returns a copy of the client configuration including the API key
+func (c *geminiClient) GetConfigWithSecrets() Config {
+	return c.load().Clone()
+}
+
+// CountTokens estimates the prompt tokens of request using Config.Tokenizer
+func (c *geminiClient) CountTokens(request Request) int {
+	c = c.current()
+	return countRequestTokens(c.config, c.getModel(request.Model), request)
+}
+
+// Capabilities reports what the client supports for its provider
+func (c *geminiClient) Capabilities() Capabilities {
+	return providerCapabilities(c.config.Provider)
+}
+
+// ListModels lists the models of the /models endpoint
+func (c *geminiClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	c = c.current()
+	return c.models.get(ctx, c.config, c.fetchModels)
+}
+
+// fetchModels calls the /models endpoint without caching
+func (c *geminiClient) fetchModels(ctx context.Context) ([]ModelInfo, error) {
+	var apiResp struct {
+		Models []struct {
+			Name            string `json:"name"`
+			BaseModelID     string `json:"baseModelId"`
+			InputTokenLimit int    `json:"inputTokenLimit"`
+		} `json:"models"`
+	}
+	auth := func(req *http.Request) { req.Header.Set("x-goog-api-key", c.config.APIKey) }
+	if err := fetchJSON(ctx, c.httpClient, c.config, endpointURL(c.config, "/models?pageSize=1000"), auth, "Gemini API error", &apiResp); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(apiResp.Models))
+	for _, m := range apiResp.Models {
+		models = append(models, ModelInfo{
+			ID:            strings.TrimPrefix(m.Name, "models/"),
+			OwnedBy:       "google",
+			BaseModel:     m.BaseModelID,
+			ContextLength: m.InputTokenLimit,
+		})
+	}
+	return models, nil
+}
+
+// Ping verifies credentials and reachability using Config.PingStrategy
+func (c *geminiClient) Ping(ctx context.Context) error {
+	c = c.current()
+	return ping(ctx, c.config, c.fetchModels, c.generate)
+}
+
+// Warmup opens idle connections to the provider ahead of the first call
+func (c *geminiClient) Warmup(ctx context.Context) error {
+	c = c.current()
+	return warmup(ctx, c.httpClient, c.config)
+}
+
+// buildPayload builds the generateContent request body. System messages
+// become systemInstruction, assistant turns use Gemini's "model" role, tool
+// calls and results become functionCall and functionResponse parts, and the
+// turns are made to alternate per Config.RoleOrderPolicy.
+func (c *geminiClient) buildPayload(ctx context.Context, request Request) (map[string]interface{}, error) {
+	messages, err := normalizeRoleOrder(ctx, c.config, requestMessages(request))
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]map[string]interface{}, 0, len(messages))
+	previousTool := false
+	for _, msg := range messages {
+		role := "user"
+		switch msg.Role {
+		case RoleSystem:
+			continue
+		case RoleAssistant:
+			role = "model"
+		}
+		parts := geminiParts(messages, msg)
+		tool := hasToolData(msg)
+		if last := len(contents) - 1; last >= 0 && contents[last]["role"] == role && (tool || previousTool) {
+			// Parallel calls and their results each form one turn
+			contents[last]["parts"] = append(contents[last]["parts"].([]map[string]interface{}), parts...)
+		} else {
+			contents = append(contents, map[string]interface{}{
+				"role":  role,
+				"parts": parts,
+			})
+		}
+		previousTool = tool
+	}
+
+	payload := map[string]interface{}{"contents": contents}
+	if system := systemText(messages); system != "" {
+		payload["systemInstruction"] = map[string]interface{}{
+			"parts": []map[string]string{{"text": system}},
+		}
+	}
+
+	generationConfig := map[string]interface{}{}
+	if request.Temperature != nil {
+		generationConfig["temperature"] = *request.Temperature
+	} else if c.config.DefaultTemperature != nil {
+		generationConfig["temperature"] = *c.config.DefaultTemperature
+	}
+	if request.MaxTokens != nil {
+		generationConfig["maxOutputTokens"] = *request.MaxTokens
+	} else if c.config.DefaultMaxTokens != nil {
+		generationConfig["maxOutputTokens"] = *c.config.DefaultMaxTokens
+	}
+	if request.TopP != nil {
+		generationConfig["topP"] = *request.TopP
+	} else if c.config.DefaultTopP != nil {
+		generationConfig["topP"] = *c.config.DefaultTopP
+	}
+	if request.TopK != nil {
+		generationConfig["topK"] = *request.TopK
+	} else if c.config.DefaultTopK != nil {
+		generationConfig["topK"] = *c.config.DefaultTopK
+	}
+	if len(generationConfig) > 0 {
+		payload["generationConfig"] = generationConfig
+	}
+
+	if settings := mergeSafetySettings(c.config.SafetySettings, request.SafetySettings); len(settings) > 0 {
+		payload["safetySettings"] = settings
+	}
+
+	for k, v := range request.ExtraParams {
+		payload[k] = v
+	}
+	return payload, nil
+}
+
+// geminiParts converts msg to content parts. Gemini matches a
+// functionResponse to its call by function name, which is looked up in
+// messages by the result's ToolCallID (falling back to msg.Name).
+func geminiParts(messages []Message, msg Message) []map[string]interface{} {
+	var parts []map[string]interface{}
+	if msg.Role == RoleTool {
+		name := msg.Name
+		if call, ok := findToolCall(messages, msg.ToolCallID); ok {
+			name = call.Name
+		}
+		return append(parts, map[string]interface{}{
+			"functionResponse": map[string]interface{}{
+				"name":     name,
+				"response": toolResultObject(msg.Content),
+			},
+		})
+	}
+	if msg.Content != "" || len(msg.ToolCalls) == 0 {
+		parts = append(parts, map[string]interface{}{"text": msg.Content})
+	}
+	for _, call := range msg.ToolCalls {
+		parts = append(parts, map[string]interface{}{
+			"functionCall": map[string]interface{}{
+				"name": call.Name,
+				"args": toolArguments(call.Arguments),
+			},
+		})
+	}
+	return parts
+}
+
+// getModel returns the model to use for the request
+func (c *geminiClient) getModel(override *string) string {
+	if override != nil {
+		return *override
+	}
+	// An embedding DefaultModel (see withEmbeddingDefault) is not a chat model
+	if isEmbeddingModel(c.config.DefaultModel) {
+		return "gemini-2.5-flash"
+	}
+	return c.config.DefaultModel
+}
+
+// getEmbeddingModel returns the embedding model to use for the request
+func (c *geminiClient) getEmbeddingModel(override *string) string {
+	return resolveEmbeddingModel(c.config, override)
+}
+
+// geminiModelName returns model in the "models/<id>" form the API expects
+func geminiModelName(model string) string {
+	if strings.HasPrefix(model, "models/") {
+		return model
+	}
+	return "models/" + model
+}
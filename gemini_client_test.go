@@ -0,0 +1,211 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestGeminiEmbeddings(t *testing.T) {
+	var mu sync.Mutex
+	var path string
+	var batches [][]map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-goog-api-key") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var payload struct {
+			Requests []map[string]interface{} `json:"requests"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		path = r.URL.Path
+		batches = append(batches, payload.Requests)
+		mu.Unlock()
+		embeddings := make([]map[string]interface{}, len(payload.Requests))
+		for i := range embeddings {
+			embeddings[i] = map[string]interface{}{"values": []float64{float64(i), 1}}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{APIKey: "test-key", BaseURL: server.URL, DefaultModel: "gemini-embedding-001"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	dims := 2
+	resp, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"a", "b"}, Task: EmbeddingTaskDocument, Title: "Doc", Dimensions: &dims})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if path != "/models/gemini-embedding-001:batchEmbedContents" {
+		t.Errorf("Unexpected path %q", path)
+	}
+	first := batches[0][0]
+	if first["model"] != "models/gemini-embedding-001" || first["taskType"] != "RETRIEVAL_DOCUMENT" || first["title"] != "Doc" || first["outputDimensionality"] != float64(2) {
+		t.Errorf("Unexpected request %v", first)
+	}
+	if len(resp.Embeddings) != 2 || resp.Embeddings[1][0] != 1 || resp.Model != "gemini-embedding-001" || resp.Dimensions != 2 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	// query embeddings carry no title; batches are split at 100 inputs
+	batches = nil
+	inputs := make([]string, 150)
+	resp, err = client.CreateEmbedding(ctx, EmbeddingRequest{Input: inputs, Task: EmbeddingTaskQuery, Title: "Doc"})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	sort.Slice(batches, func(i, j int) bool { return len(batches[i]) > len(batches[j]) })
+	if len(batches) != 2 || len(batches[0]) != 100 || len(batches[1]) != 50 {
+		t.Fatalf("Expected batches of 100 and 50, got %d batches", len(batches))
+	}
+	if batches[1][0]["taskType"] != "RETRIEVAL_QUERY" || batches[1][0]["title"] != nil {
+		t.Errorf("Unexpected query request %v", batches[1][0])
+	}
+	if len(resp.Embeddings) != 150 || resp.Embeddings[120][0] != 20 {
+		t.Errorf("Expected 150 vectors reassembled in order, got %d", len(resp.Embeddings))
+	}
+}
+
+func TestGeminiChat(t *testing.T) {
+	var path string
+	var payload map[string]interface{}
+	reply := `{"candidates": [{"content": {"role": "model", "parts": [{"text": "Thinking it over.", "thought": true}, {"text": "Paris"}]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 12, "candidatesTokenCount": 1, "thoughtsTokenCount": 5, "totalTokenCount": 18},
+		"modelVersion": "gemini-2.5-flash"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(reply))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{
+		Provider:       ProviderGemini,
+		APIKey:         "test-key",
+		BaseURL:        server.URL,
+		DefaultModel:   "gemini-embedding-001",
+		SafetySettings: []SafetySetting{{HarmCategoryHarassment, BlockOnlyHigh}, {HarmCategoryHateSpeech, BlockOnlyHigh}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ctx := context.Background()
+
+	request := NewRequest(WithSystem("Answer briefly."), WithUser("Capital of France?"), WithAssistant("Paris."), WithUser("Again?"),
+		WithMaxTokens(20), WithTopK(3), WithSafetySetting(HarmCategoryHateSpeech, BlockNone))
+	resp, err := client.Generate(ctx, request)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	// an embedding DefaultModel falls back to the chat default
+	if path != "/models/gemini-2.5-flash:generateContent" {
+		t.Errorf("Unexpected path %q", path)
+	}
+	contents := payload["contents"].([]interface{})
+	if len(contents) != 3 || contents[1].(map[string]interface{})["role"] != "model" {
+		t.Errorf("Unexpected contents %v", contents)
+	}
+	system := payload["systemInstruction"].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+	if system["text"] != "Answer briefly." {
+		t.Errorf("Unexpected systemInstruction %v", system)
+	}
+	generationConfig := payload["generationConfig"].(map[string]interface{})
+	if generationConfig["maxOutputTokens"] != float64(20) || generationConfig["topK"] != float64(3) {
+		t.Errorf("Unexpected generationConfig %v", generationConfig)
+	}
+	wantSafety := []interface{}{
+		map[string]interface{}{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_ONLY_HIGH"},
+		map[string]interface{}{"category": "HARM_CATEGORY_HATE_SPEECH", "threshold": "BLOCK_NONE"},
+	}
+	if !reflect.DeepEqual(payload["safetySettings"], wantSafety) {
+		t.Errorf("Unexpected safetySettings %v", payload["safetySettings"])
+	}
+	if resp.Content != "Paris" || resp.ReasoningContent != "Thinking it over." || resp.FinishReason != "stop" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if resp.Usage != (Usage{PromptTokens: 12, CompletionTokens: 6, TotalTokens: 18}) {
+		t.Errorf("Unexpected usage %+v", resp.Usage)
+	}
+
+	tests := []struct {
+		name        string
+		reply       string
+		reason      string
+		category    HarmCategory
+		probability string
+	}{
+		{
+			name: "blocked prompt",
+			reply: `{"promptFeedback": {"blockReason": "SAFETY", "safetyRatings": [
+				{"category": "HARM_CATEGORY_HARASSMENT", "probability": "LOW"},
+				{"category": "HARM_CATEGORY_DANGEROUS_CONTENT", "probability": "HIGH"}]}}`,
+			reason:      "SAFETY",
+			category:    HarmCategoryDangerousContent,
+			probability: "HIGH",
+		},
+		{
+			name: "blocked candidate",
+			reply: `{"candidates": [{"finishReason": "SAFETY", "safetyRatings": [
+				{"category": "HARM_CATEGORY_HATE_SPEECH", "probability": "MEDIUM", "blocked": true},
+				{"category": "HARM_CATEGORY_HARASSMENT", "probability": "HIGH"}]}]}`,
+			reason:      "SAFETY",
+			category:    HarmCategoryHateSpeech,
+			probability: "MEDIUM",
+		},
+		{
+			name:   "prohibited content",
+			reply:  `{"promptFeedback": {"blockReason": "PROHIBITED_CONTENT"}}`,
+			reason: "PROHIBITED_CONTENT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply = tt.reply
+			_, err := client.Generate(ctx, BuildSimpleRequest("hi"))
+			var filtered *ContentFilteredError
+			if !errors.As(err, &filtered) || !errors.Is(err, ErrContentFiltered) {
+				t.Fatalf("Expected a ContentFilteredError, got %v", err)
+			}
+			if filtered.Reason != tt.reason || filtered.Category != tt.category || filtered.Probability != tt.probability {
+				t.Errorf("Unexpected error %+v", filtered)
+			}
+			if ErrorClass(err) != StatusContentFilter {
+				t.Errorf("Expected class %s, got %s", StatusContentFilter, ErrorClass(err))
+			}
+		})
+	}
+}
+
+func TestEmbeddingTaskValue(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		task     EmbeddingTask
+		want     string
+	}{
+		{ProviderCohere, EmbeddingTaskQuery, "search_query"},
+		{ProviderJina, EmbeddingTaskDocument, "retrieval.passage"},
+		{ProviderGemini, EmbeddingTaskSimilarity, "SEMANTIC_SIMILARITY"},
+		{ProviderCohere, EmbeddingTaskSimilarity, ""},
+		{ProviderOpenAI, EmbeddingTaskQuery, ""},
+		{ProviderJina, "code.query", "code.query"},
+	}
+	for _, tt := range tests {
+		if got := embeddingTaskValue(tt.provider, tt.task); got != tt.want {
+			t.Errorf("embeddingTaskValue(%s, %s) = %q, want %q", tt.provider, tt.task, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrBatchSkipped is the error of GenerateBatch requests that were not sent
+// because an earlier one failed under GenerateBatchOptions.StopOnError
+var ErrBatchSkipped = errors.New("request skipped after an earlier failure")
+
+// GenerateProgress is passed to GenerateBatchOptions.OnProgress after every
+// request
+type GenerateProgress struct {
+	// Completed and Failed count requests; Total is len(requests)
+	Completed int
+	Failed    int
+	Total     int
+	// Usage is the usage of the completed requests so far
+	Usage Usage
+}
+
+// GenerateBatchOptions configures GenerateBatch
+type GenerateBatchOptions struct {
+	// Concurrency is the number of calls in flight (0 = 1)
+	Concurrency int
+
+	// StopOnError stops sending requests after the first failure (after
+	// its retries) and cancels the calls in flight; requests not sent fail
+	// with ErrBatchSkipped
+	StopOnError bool
+
+	// Limiter paces calls by request and estimated prompt tokens (nil = unlimited)
+	Limiter *RateLimiter
+
+	// MaxRetries is how often a request is retried after a transient error
+	// such as a rate limit or 5xx (0 = 3, negative = never), waiting
+	// RetryBackoff (0 = 1s) and doubling after each attempt up to a minute
+	MaxRetries   int
+	RetryBackoff time.Duration
+
+	// ItemTimeout bounds each call of requests without their own
+	// Request.Timeout (0 = Config.Timeout)
+	ItemTimeout time.Duration
+
+	// OnProgress is called after every request, never concurrently (nil = none)
+	OnProgress func(progress GenerateProgress)
+}
+
+// GenerateBatch sends independent chat requests with opts.Concurrency
+// concurrent calls and returns the responses and errors aligned to requests:
+// for every index exactly one of them is set. When ctx ends, GenerateBatch
+// stops promptly and returns what finished; the others carry ctx's error or
+// the error of their interrupted call. TotalUsage sums the usage of the
+// responses.
+func GenerateBatch(ctx context.Context, client Client, requests []Request, opts GenerateBatchOptions) ([]*Response, []error) {
+	concurrency := max(opts.Concurrency, 1)
+	responses := make([]*Response, len(requests))
+	errs := make([]error, len(requests))
+
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indices := make(chan int)
+	go func() {
+		defer close(indices)
+		for i := range requests {
+			select {
+			case indices <- i:
+			case <-batchCtx.Done():
+				return
+			}
+		}
+	}()
+
+	var mu sync.Mutex
+	progress := GenerateProgress{Total: len(requests)}
+	stopped := false
+
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				if batchCtx.Err() != nil {
+					return
+				}
+				request := requests[i]
+				if request.Timeout == 0 {
+					request.Timeout = opts.ItemTimeout
+				}
+				// retries must not bill the provider twice for one item
+				request.IdempotencyKey = resolveIdempotencyKey(batchCtx, request.IdempotencyKey)
+				tokens := client.CountTokens(request)
+				response, err := retryTransient(batchCtx, opts.Limiter, tokens, opts.MaxRetries, opts.RetryBackoff, func() (*Response, error) {
+					return client.Generate(batchCtx, request)
+				})
+
+				mu.Lock()
+				if err != nil {
+					errs[i] = err
+					progress.Failed++
+					if opts.StopOnError && !stopped && ctx.Err() == nil {
+						stopped = true
+						cancel()
+					}
+				} else {
+					responses[i] = response
+					progress.Completed++
+					progress.Usage = addUsage(progress.Usage, response.Usage)
+				}
+				if opts.OnProgress != nil {
+					opts.OnProgress(progress)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range requests {
+		if responses[i] != nil || errs[i] != nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			errs[i] = ctx.Err()
+		} else {
+			errs[i] = ErrBatchSkipped
+		}
+	}
+	return responses, errs
+}
+
+// TotalUsage returns the combined usage of responses, skipping nil entries
+func TotalUsage(responses []*Response) Usage {
+	var total Usage
+	for _, response := range responses {
+		if response != nil {
+			total = addUsage(total, response.Usage)
+		}
+	}
+	return total
+}
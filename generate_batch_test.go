@@ -0,0 +1,204 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveEcho returns a chat server that answers each prompt with itself.
+// status, when set, picks a status code for a prompt and its attempt number.
+func serveEcho(t *testing.T, status func(prompt string, attempt int) int) *httptest.Server {
+	t.Helper()
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Messages []Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		prompt := payload.Messages[len(payload.Messages)-1].Content
+		mu.Lock()
+		attempts[prompt]++
+		attempt := attempts[prompt]
+		mu.Unlock()
+
+		if status != nil {
+			if code := status(prompt, attempt); code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+		}
+		n, _ := strconv.Atoi(prompt)
+		// later prompts finish first, so ordering is not arrival order
+		time.Sleep(time.Duration(10-n%10) * time.Millisecond)
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 2, "completion_tokens": 1, "total_tokens": 3}}`, prompt)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// numberedRequests returns n requests whose prompts are "0" to "n-1"
+func numberedRequests(n int) []Request {
+	requests := make([]Request, n)
+	for i := range requests {
+		requests[i] = BuildSimpleRequest(strconv.Itoa(i))
+	}
+	return requests
+}
+
+func TestGenerateBatch(t *testing.T) {
+	server := serveEcho(t, func(prompt string, attempt int) int {
+		switch {
+		case prompt == "3" && attempt == 1:
+			return http.StatusTooManyRequests
+		case prompt == "7":
+			return http.StatusBadRequest
+		}
+		return http.StatusOK
+	})
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	var progress []GenerateProgress
+	responses, errs := GenerateBatch(context.Background(), client, numberedRequests(10), GenerateBatchOptions{
+		Concurrency:  4,
+		Limiter:      NewRateLimiter(1000, 0),
+		RetryBackoff: 1,
+		OnProgress:   func(p GenerateProgress) { progress = append(progress, p) },
+	})
+
+	for i := range 10 {
+		if i == 7 {
+			var apiErr *APIError
+			if responses[i] != nil || !errors.As(errs[i], &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected request 7 to fail with a 400, got %v, %v", responses[i], errs[i])
+			}
+			continue
+		}
+		if errs[i] != nil || responses[i] == nil || responses[i].Content != strconv.Itoa(i) {
+			t.Errorf("Request %d: unexpected %v, %v", i, responses[i], errs[i])
+		}
+	}
+
+	if len(progress) != 10 {
+		t.Fatalf("Expected 10 progress reports, got %d", len(progress))
+	}
+	last := progress[len(progress)-1]
+	if last.Completed != 9 || last.Failed != 1 || last.Total != 10 || last.Usage.TotalTokens != 27 {
+		t.Errorf("Unexpected final progress %+v", last)
+	}
+	if TotalUsage(responses) != last.Usage {
+		t.Errorf("Expected TotalUsage %+v, got %+v", last.Usage, TotalUsage(responses))
+	}
+}
+
+func TestGenerateBatchStopOnError(t *testing.T) {
+	server := serveEcho(t, func(prompt string, attempt int) int {
+		if prompt == "2" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	})
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	responses, errs := GenerateBatch(context.Background(), client, numberedRequests(20), GenerateBatchOptions{StopOnError: true})
+	if responses[0] == nil || responses[1] == nil {
+		t.Errorf("Expected the requests before the failure to complete, got %v", errs[:2])
+	}
+	var apiErr *APIError
+	if !errors.As(errs[2], &apiErr) {
+		t.Errorf("Expected request 2 to fail with an APIError, got %v", errs[2])
+	}
+	for i := 3; i < 20; i++ {
+		if responses[i] != nil || !errors.Is(errs[i], ErrBatchSkipped) {
+			t.Errorf("Request %d: expected ErrBatchSkipped, got %v, %v", i, responses[i], errs[i])
+		}
+	}
+}
+
+func TestGenerateBatchCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var once sync.Once
+	server := serveEcho(t, nil)
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	responses, errs := GenerateBatch(ctx, client, numberedRequests(50), GenerateBatchOptions{
+		Concurrency: 2,
+		OnProgress: func(p GenerateProgress) {
+			if p.Completed == 4 {
+				once.Do(cancel)
+			}
+		},
+	})
+
+	completed := 0
+	for i := range responses {
+		switch {
+		case responses[i] != nil:
+			completed++
+		case errs[i] == nil:
+			t.Errorf("Request %d has neither a response nor an error", i)
+		case !errors.Is(errs[i], context.Canceled) && !errors.Is(errs[i], ErrCancelled):
+			t.Errorf("Request %d: expected a cancellation, got %v", i, errs[i])
+		}
+	}
+	if completed < 4 || completed > 6 {
+		t.Errorf("Expected the completed results to be kept and the rest aborted, got %d completed", completed)
+	}
+}
+
+func TestGenerateBatchItemTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	_, errs := GenerateBatch(context.Background(), client, numberedRequests(1), GenerateBatchOptions{ItemTimeout: 20 * time.Millisecond, MaxRetries: -1})
+	var timeoutErr *TimeoutError
+	if !errors.As(errs[0], &timeoutErr) || timeoutErr.Timeout != 20*time.Millisecond {
+		t.Errorf("Expected the item timeout, got %v", errs[0])
+	}
+}
+
+func TestGenerateBatchRetryIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		attempt := len(keys)
+		mu.Unlock()
+		if attempt == 1 {
+			// the provider accepted the request but the client gave up waiting
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "0"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	_, errs := GenerateBatch(context.Background(), client, numberedRequests(1), GenerateBatchOptions{ItemTimeout: 20 * time.Millisecond, MaxRetries: 1, RetryBackoff: 1})
+	if errs[0] != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", errs[0])
+	}
+	if len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("Expected both attempts to send the same Idempotency-Key, got %q", keys)
+	}
+}
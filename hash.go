@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// RequestHashVersion prefixes every HashRequest result. It changes whenever
+// the canonical form changes, so hashes persisted under an older version
+// stop matching instead of colliding.
+const RequestHashVersion = "v1"
+
+// canonicalRequest is the form of a request hashed by HashRequest. Its
+// fields are encoded in declaration order and maps with sorted keys;
+// pointers stay pointers, so an unset parameter (the provider default)
+// hashes differently from an explicit one.
+type canonicalRequest struct {
+	Provider         Provider               `json:"provider"`
+	Model            string                 `json:"model"`
+	SystemPrompt     string                 `json:"system_prompt"`
+	Messages         []canonicalMessage     `json:"messages"`
+	Temperature      *float64               `json:"temperature"`
+	MaxTokens        *int                   `json:"max_tokens"`
+	TopP             *float64               `json:"top_p"`
+	TopK             *int                   `json:"top_k"`
+	DeepSeekThinking *bool                  `json:"deepseek_thinking"`
+	SafetySettings   []SafetySetting        `json:"safety_settings"`
+	ExtraParams      map[string]interface{} `json:"extra_params"`
+	ContinueOnLength int                    `json:"continue_on_length"`
+	ContinueJSON     bool                   `json:"continue_json"`
+}
+
+// canonicalMessage is the part of a message sent to the provider
+type canonicalMessage struct {
+	Role         MessageRole `json:"role"`
+	Content      string      `json:"content"`
+	Name         string      `json:"name"`
+	CacheControl bool        `json:"cache_control"`
+	ToolCalls    []ToolCall  `json:"tool_calls"`
+	ToolCallID   string      `json:"tool_call_id"`
+}
+
+// HashRequest returns a stable hash of what request asks of model on
+// provider, as "<RequestHashVersion>:<hex SHA-256>", for cache keys,
+// deduplication, idempotency keys and golden files. Request.Model, when
+// set, replaces model.
+//
+// The hash covers the messages (including tool calls and results), the
+// system prompt, the sampling parameters, DeepSeekThinking, the safety
+// settings, ContinueOnLength and ContinueJSON, and ExtraParams, which carry
+// tool definitions and response_format. It ignores what does not change
+// the answer: Stream, Headers, IdempotencyKey, Timeout, Priority and
+// Message.CreatedAt. Equal requests hash equally across processes and
+// releases for a given RequestHashVersion. It fails only when ExtraParams
+// cannot be encoded as JSON.
+func HashRequest(provider Provider, model string, request Request) (string, error) {
+	if request.Model != nil && *request.Model != "" {
+		model = *request.Model
+	}
+	canonical := canonicalRequest{
+		Provider:         provider,
+		Model:            model,
+		SystemPrompt:     request.SystemPrompt,
+		Messages:         make([]canonicalMessage, len(request.Messages)),
+		Temperature:      request.Temperature,
+		MaxTokens:        request.MaxTokens,
+		TopP:             request.TopP,
+		TopK:             request.TopK,
+		DeepSeekThinking: request.DeepSeekThinking,
+		SafetySettings:   request.SafetySettings,
+		ExtraParams:      request.ExtraParams,
+		ContinueOnLength: request.ContinueOnLength,
+		ContinueJSON:     request.ContinueJSON,
+	}
+	for i, message := range request.Messages {
+		canonical.Messages[i] = canonicalMessage{
+			Role:         message.Role,
+			Content:      message.Content,
+			Name:         message.Name,
+			CacheControl: message.CacheControl,
+			ToolCalls:    message.ToolCalls,
+			ToolCallID:   message.ToolCallID,
+		}
+	}
+
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return "", fmt.Errorf("hash request: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return RequestHashVersion + ":" + hex.EncodeToString(sum[:]), nil
+}
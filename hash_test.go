@@ -0,0 +1,70 @@
+package llm
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashRequest(t *testing.T) {
+	build := func(opts ...RequestOption) Request {
+		return NewRequest(append([]RequestOption{
+			WithSystem("Be brief."),
+			WithUser("What's the weather?"),
+			WithExtraParam("tools", []map[string]interface{}{{"type": "function", "function": map[string]interface{}{"name": "get_weather"}}}),
+		}, opts...)...)
+	}
+	hash := func(provider Provider, model string, request Request) string {
+		t.Helper()
+		h, err := HashRequest(provider, model, request)
+		if err != nil {
+			t.Fatalf("HashRequest failed: %v", err)
+		}
+		return h
+	}
+
+	base := hash(ProviderOpenAI, "gpt-4o", build())
+	if !strings.HasPrefix(base, RequestHashVersion+":") || len(base) != len(RequestHashVersion)+1+64 {
+		t.Fatalf("Unexpected hash format %q", base)
+	}
+
+	// Volatile metadata and map order do not change the hash
+	volatile := build(WithTimeout(time.Second), WithExtraParam("a", 1), WithExtraParam("b", 2))
+	volatile.Headers = map[string]string{"X-Trace": "1"}
+	volatile.IdempotencyKey = "key"
+	volatile.Priority = PriorityHigh
+	volatile.Stream = true
+	volatile.Messages[1].CreatedAt = time.Now()
+	reordered := build(WithExtraParam("b", 2), WithExtraParam("a", 1.0))
+	if hash(ProviderOpenAI, "gpt-4o", volatile) != hash(ProviderOpenAI, "gpt-4o", reordered) {
+		t.Error("Expected volatile fields and map order to be ignored")
+	}
+	if hash(ProviderOpenAI, "gpt-4o", build()) != base {
+		t.Error("Expected equal requests to hash equally")
+	}
+	if hash(ProviderOpenAI, "", build(WithModel("gpt-4o"))) != base {
+		t.Error("Expected Request.Model to replace the model argument")
+	}
+
+	for name, other := range map[string]string{
+		"provider":         hash(ProviderDeepSeek, "gpt-4o", build()),
+		"model":            hash(ProviderOpenAI, "gpt-4o-mini", build()),
+		"temperature":      hash(ProviderOpenAI, "gpt-4o", build(WithTemperature(1))),
+		"zero temperature": hash(ProviderOpenAI, "gpt-4o", build(WithTemperature(0))),
+		"json mode":        hash(ProviderOpenAI, "gpt-4o", build(WithJSONMode())),
+		"tools":            hash(ProviderOpenAI, "gpt-4o", build(WithExtraParam("tools", nil))),
+		"messages":         hash(ProviderOpenAI, "gpt-4o", build(WithUser("And tomorrow?"))),
+	} {
+		if other == base {
+			t.Errorf("Expected %s to change the hash", name)
+		}
+	}
+	if hash(ProviderOpenAI, "gpt-4o", build(WithTemperature(1))) == hash(ProviderOpenAI, "gpt-4o", build(WithTemperature(0))) {
+		t.Error("Expected distinct temperatures to hash differently")
+	}
+
+	if _, err := HashRequest(ProviderOpenAI, "gpt-4o", build(WithExtraParam("bad", math.Inf(1)))); err == nil {
+		t.Error("Expected an error for parameters that cannot be encoded")
+	}
+}
@@ -0,0 +1,157 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// HistoryVersion is the schema version written by ChatHistory.MarshalJSON
+const HistoryVersion = 1
+
+// ErrUnsupportedHistoryVersion is returned when decoding a ChatHistory
+// written by a newer (or unknown) schema version
+var ErrUnsupportedHistoryVersion = errors.New("unsupported chat history version")
+
+// historyJSON is the persisted form of ChatHistory. Data saved before
+// versioning has no version field and decodes as version 0, which has the
+// same message layout as version 1.
+type historyJSON struct {
+	Version  int       `json:"version"`
+	Messages []Message `json:"messages"`
+}
+
+// MarshalJSON encodes the history with its schema version
+func (h ChatHistory) MarshalJSON() ([]byte, error) {
+	messages := h.Messages
+	if messages == nil {
+		messages = []Message{}
+	}
+	return json.Marshal(historyJSON{Version: HistoryVersion, Messages: messages})
+}
+
+// UnmarshalJSON decodes a history, rejecting unknown schema versions and
+// messages with roles other than the MessageRole constants
+func (h *ChatHistory) UnmarshalJSON(data []byte) error {
+	var decoded historyJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.Version < 0 || decoded.Version > HistoryVersion {
+		return fmt.Errorf("%w %d (this library supports up to %d)", ErrUnsupportedHistoryVersion, decoded.Version, HistoryVersion)
+	}
+	for i, msg := range decoded.Messages {
+		if !validRole(msg.Role) {
+			return fmt.Errorf("chat history message %d: invalid role %q", i, msg.Role)
+		}
+	}
+	h.Messages = decoded.Messages
+	return nil
+}
+
+// validRole reports whether role is one of the MessageRole constants
+func validRole(role MessageRole) bool {
+	switch role {
+	case RoleSystem, RoleUser, RoleAssistant, RoleFunction, RoleTool:
+		return true
+	}
+	return false
+}
+
+// now is the clock used to stamp and expire messages (replaced in tests)
+var now = time.Now
+
+// HistoryLimits bound a ChatHistory. They are enforced on every Add using
+// the same policy as TruncateToTokens: the oldest non-system messages go
+// first, system messages are kept and no assistant reply or tool result is
+// left as the first turn. Zero values disable a limit.
+type HistoryLimits struct {
+	// MaxMessages caps the number of messages, system messages included
+	MaxMessages int
+	// MaxAge drops messages whose CreatedAt is older than this
+	MaxAge time.Duration
+	// MaxTokens caps the estimated prompt size of the history
+	MaxTokens int
+	// Tokenizer counts tokens for MaxTokens and Stats (nil = HeuristicTokenizer)
+	Tokenizer Tokenizer
+}
+
+// HistoryStats describes the current size of a ChatHistory
+type HistoryStats struct {
+	Messages          int
+	SystemMessages    int
+	UserMessages      int
+	AssistantMessages int
+	EstimatedTokens   int
+	// Oldest is the CreatedAt of the oldest timestamped message, if any
+	Oldest time.Time
+}
+
+// NewChatHistory creates an empty history that enforces limits
+func NewChatHistory(limits HistoryLimits) ChatHistory {
+	return ChatHistory{Limits: limits}
+}
+
+// Stats returns the current message counts and estimated token size
+func (h *ChatHistory) Stats() HistoryStats {
+	stats := HistoryStats{
+		Messages:        len(h.Messages),
+		EstimatedTokens: h.tokenizer().CountMessages("", h.Messages),
+	}
+	for _, msg := range h.Messages {
+		switch msg.Role {
+		case RoleSystem:
+			stats.SystemMessages++
+		case RoleUser:
+			stats.UserMessages++
+		case RoleAssistant:
+			stats.AssistantMessages++
+		}
+		if !msg.CreatedAt.IsZero() && (stats.Oldest.IsZero() || msg.CreatedAt.Before(stats.Oldest)) {
+			stats.Oldest = msg.CreatedAt
+		}
+	}
+	return stats
+}
+
+// append stamps messages with the current time and adds them, then
+// enforces the limits
+func (h *ChatHistory) append(messages ...Message) {
+	stamp := now().UTC()
+	for _, msg := range messages {
+		if msg.CreatedAt.IsZero() {
+			msg.CreatedAt = stamp
+		}
+		h.Messages = append(h.Messages, msg)
+	}
+	h.enforceLimits()
+}
+
+// enforceLimits trims the history to h.Limits
+func (h *ChatHistory) enforceLimits() {
+	limits := h.Limits
+	if limits.MaxAge > 0 {
+		cutoff := now().Add(-limits.MaxAge)
+		h.Messages = trimOldest(h.Messages, func(messages []Message) bool {
+			i := firstConversational(messages)
+			return i >= 0 && !messages[i].CreatedAt.IsZero() && messages[i].CreatedAt.Before(cutoff)
+		})
+	}
+	if limits.MaxMessages > 0 {
+		h.Messages = trimOldest(h.Messages, func(messages []Message) bool {
+			return len(messages) > limits.MaxMessages
+		})
+	}
+	if limits.MaxTokens > 0 {
+		h.TruncateToTokens(limits.MaxTokens, limits.Tokenizer)
+	}
+}
+
+// tokenizer returns the tokenizer configured in the limits or the default
+func (h *ChatHistory) tokenizer() Tokenizer {
+	if h.Limits.Tokenizer != nil {
+		return h.Limits.Tokenizer
+	}
+	return HeuristicTokenizer{}
+}
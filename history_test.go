@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatHistoryJSON(t *testing.T) {
+	history := ChatHistory{}
+	history.AddSystemMessage("You are helpful.")
+	history.AddUserMessage("Hello")
+	history.Messages = append(history.Messages, Message{Role: RoleFunction, Content: `{"temp":21}`, Name: "get_weather"})
+	history.AddAssistantMessage("It is 21 degrees.")
+
+	data, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"version":1`) {
+		t.Errorf("Expected version in %s", data)
+	}
+
+	var decoded ChatHistory
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, history) {
+		t.Errorf("Round trip changed history:\n got %+v\nwant %+v", decoded, history)
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "unversioned legacy data", data: `{"messages":[{"role":"user","content":"hi"}]}`},
+		{name: "empty history", data: `{"version":1,"messages":[]}`},
+		{name: "unknown version", data: `{"version":7,"messages":[]}`, wantErr: "version 7"},
+		{name: "invalid role", data: `{"version":1,"messages":[{"role":"user","content":"a"},{"role":"bot","content":"b"}]}`, wantErr: `message 1: invalid role "bot"`},
+		{name: "malformed JSON", data: `{"version":1,"messages":`, wantErr: "unexpected end"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var h ChatHistory
+			err := json.Unmarshal([]byte(tt.data), &h)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	var h ChatHistory
+	if err := json.Unmarshal([]byte(`{"version":2}`), &h); !errors.Is(err, ErrUnsupportedHistoryVersion) {
+		t.Errorf("Expected ErrUnsupportedHistoryVersion, got %v", err)
+	}
+}
+
+func TestChatHistoryLimits(t *testing.T) {
+	clock := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return clock }
+	defer func() { now = time.Now }()
+
+	roles := func(h ChatHistory) string {
+		var parts []string
+		for _, msg := range h.Messages {
+			parts = append(parts, string(msg.Role)[:1]+":"+msg.Content)
+		}
+		return strings.Join(parts, " ")
+	}
+
+	t.Run("max messages", func(t *testing.T) {
+		history := NewChatHistory(HistoryLimits{MaxMessages: 4})
+		history.AddSystemMessage("sys")
+		for _, turn := range []string{"1", "2", "3"} {
+			history.AddUserMessage(turn)
+			history.AddAssistantMessage(turn)
+		}
+		if got := roles(history); got != "s:sys u:3 a:3" {
+			t.Errorf("History = %q", got)
+		}
+	})
+
+	t.Run("max age", func(t *testing.T) {
+		history := NewChatHistory(HistoryLimits{MaxAge: time.Hour})
+		history.AddSystemMessage("sys")
+		history.AddUserMessage("old")
+		history.AddAssistantMessage("old")
+		clock = clock.Add(2 * time.Hour)
+		history.AddUserMessage("new")
+		if got := roles(history); got != "s:sys u:new" {
+			t.Errorf("History = %q", got)
+		}
+		if history.Messages[1].CreatedAt != clock {
+			t.Errorf("Expected CreatedAt to be stamped, got %v", history.Messages[1].CreatedAt)
+		}
+	})
+
+	t.Run("max tokens", func(t *testing.T) {
+		history := NewChatHistory(HistoryLimits{
+			MaxTokens: 30,
+			Tokenizer: TokenizerFunc(func(model, text string) int { return len(text) }),
+		})
+		history.AddUserMessage(strings.Repeat("x", 20))
+		history.AddAssistantMessage("ok")
+		history.AddUserMessage("next")
+		if got := roles(history); got != "u:next" {
+			t.Errorf("History = %q", got)
+		}
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		history := ChatHistory{}
+		history.AddSystemMessage("sys")
+		history.AddUserMessage("hi")
+		history.AddAssistantMessage("hello")
+		stats := history.Stats()
+		if stats.Messages != 3 || stats.SystemMessages != 1 || stats.UserMessages != 1 || stats.AssistantMessages != 1 {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+		if stats.EstimatedTokens == 0 || stats.Oldest != clock {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+	})
+}
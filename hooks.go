@@ -0,0 +1,167 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// BeforeRequestHook runs before a chat request is sent and may modify it
+// (inject a tenant system prompt, cap max tokens, ...). Returning an error
+// aborts the call. It runs again, on a fresh copy of the caller's request,
+// for every attempt the client makes; see AttemptFromContext.
+type BeforeRequestHook func(ctx context.Context, request *Request) error
+
+// AfterResponseHook observes the outcome of a chat call. request is the
+// request as sent, after all BeforeRequest hooks ran. It runs once per
+// attempt: with the outcome of each attempt that is retried, and with the
+// outcome of the whole call for the last one.
+type AfterResponseHook func(ctx context.Context, request *Request, response *Response, err error)
+
+// AttemptFromContext returns the attempt a hook runs for: 1 for the first
+// send of a chat request, 2 and up when the client sends it again, to retry
+// an empty response (Config.EmptyResponseRetries), continue a truncated one
+// (Request.ContinueOnLength) or resume an interrupted stream
+// (Config.StreamResumeAttempts)
+func AttemptFromContext(ctx context.Context) (int, bool) {
+	attempt, ok := ctx.Value(attemptContextKey).(int)
+	return attempt, ok
+}
+
+// withAttempt marks ctx as that of attempt n
+func withAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptContextKey, n)
+}
+
+// chatAttempts runs the hooks of each attempt of one chat call. The first
+// attempt is prepared by prepareChatRequest. A retry layer derives the
+// request of a later attempt from the first one by appending messages (a
+// continuation prompt, for example) and setting an idempotency key; start
+// rebuilds it from the caller's request, so the hooks never see their own
+// changes twice, and runs the AfterResponse hooks of the previous attempt.
+type chatAttempts struct {
+	config   Config
+	getModel func(*string) string
+	// original is the caller's request with the config defaults applied,
+	// first the request of the first attempt
+	original, first Request
+
+	n        int
+	sent     Request
+	response *Response
+	err      error
+}
+
+// newChatAttempts tracks the attempts of a call to the caller's request
+// original, once prepareChatRequest has turned it into first
+func newChatAttempts(config Config, getModel func(*string) string, original, first Request) *chatAttempts {
+	return &chatAttempts{config: config, getModel: getModel, original: original, first: first}
+}
+
+// start begins the next attempt with request, derived from the first
+// attempt's, and returns its context and the request to send. An error
+// from the hooks aborts the attempt.
+func (a *chatAttempts) start(ctx context.Context, request Request) (context.Context, Request, error) {
+	if a.n > 0 {
+		response := a.response
+		if response != nil {
+			// hooks must not alter what later attempts build on
+			copied := *response
+			response = &copied
+		}
+		a.finish(ctx, response, a.err)
+
+		retried := a.original.Clone()
+		if len(request.Messages) > len(a.first.Messages) {
+			retried.Messages = append(retried.Messages, request.Messages[len(a.first.Messages):]...)
+		}
+		retried.IdempotencyKey = request.IdempotencyKey
+		request = retried
+	}
+	a.n++
+	a.response, a.err = nil, nil
+	ctx = withAttempt(ctx, a.n)
+	if a.n > 1 {
+		if err := prepareAttempt(ctx, a.config, a.getModel, &request); err != nil {
+			a.sent, a.err = request, err
+			return ctx, request, err
+		}
+	}
+	a.sent = request
+	return ctx, request, nil
+}
+
+// record notes the outcome of the current attempt
+func (a *chatAttempts) record(response *Response, err error) {
+	a.response, a.err = response, err
+}
+
+// finish runs the AfterResponse hooks of the current attempt
+func (a *chatAttempts) finish(ctx context.Context, response *Response, err error) {
+	runAfterHooks(withAttempt(ctx, max(a.n, 1)), a.config, &a.sent, response, err)
+}
+
+// contextHooks are the hooks attached to a context by WithHooks
+type contextHooks struct {
+	before []BeforeRequestHook
+	after  []AfterResponseHook
+}
+
+// WithHooks returns a context whose chat calls also run before and after
+// (either may be nil), once per attempt like the Config hooks and after
+// them. Wrappers use it to observe the attempts a client makes for a call
+// they forward. Hooks attached by an outer context run first. ShadowClient
+// does not run them for the calls it mirrors.
+func WithHooks(ctx context.Context, before BeforeRequestHook, after AfterResponseHook) context.Context {
+	outer := hooksFromContext(ctx)
+	var hooks contextHooks
+	hooks.before = append(hooks.before, outer.before...)
+	hooks.after = append(hooks.after, outer.after...)
+	if before != nil {
+		hooks.before = append(hooks.before, before)
+	}
+	if after != nil {
+		hooks.after = append(hooks.after, after)
+	}
+	return context.WithValue(ctx, hooksContextKey, &hooks)
+}
+
+// hooksFromContext returns the hooks attached by WithHooks
+func hooksFromContext(ctx context.Context) contextHooks {
+	hooks, _ := ctx.Value(hooksContextKey).(*contextHooks)
+	if hooks == nil {
+		return contextHooks{}
+	}
+	return *hooks
+}
+
+// withoutHooks drops the hooks attached by WithHooks from ctx
+func withoutHooks(ctx context.Context) context.Context {
+	return context.WithValue(ctx, hooksContextKey, (*contextHooks)(nil))
+}
+
+// runBeforeHooks executes the BeforeRequest hooks in registration order,
+// followed by those attached to ctx
+func runBeforeHooks(ctx context.Context, config Config, request *Request) error {
+	for i, hook := range config.BeforeRequest {
+		if err := hook(ctx, request); err != nil {
+			return fmt.Errorf("before-request hook %d: %w", i, err)
+		}
+	}
+	for i, hook := range hooksFromContext(ctx).before {
+		if err := hook(ctx, request); err != nil {
+			return fmt.Errorf("context before-request hook %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// runAfterHooks executes the AfterResponse hooks in registration order,
+// followed by those attached to ctx
+func runAfterHooks(ctx context.Context, config Config, request *Request, response *Response, err error) {
+	for _, hook := range config.AfterResponse {
+		hook(ctx, request, response, err)
+	}
+	for _, hook := range hooksFromContext(ctx).after {
+		hook(ctx, request, response, err)
+	}
+}
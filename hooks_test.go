@@ -0,0 +1,224 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var sent struct {
+		Messages []Message `json:"messages"`
+	}
+	server := newChatServer(t, func(r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &sent)
+	})
+
+	var order []string
+	var observed *Response
+	client, err := NewClient(Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		BeforeRequest: []BeforeRequestHook{
+			func(ctx context.Context, request *Request) error {
+				order = append(order, "tenant")
+				request.AddSystemMessage("You work for tenant 42.")
+				return nil
+			},
+			func(ctx context.Context, request *Request) error {
+				order = append(order, "budget")
+				return nil
+			},
+		},
+		AfterResponse: []AfterResponseHook{
+			func(ctx context.Context, request *Request, response *Response, err error) {
+				order = append(order, "audit")
+				observed = response
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	request := BuildSimpleRequest("Hello")
+	response, err := client.Generate(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(request.Messages) != 1 {
+		t.Errorf("Hooks modified the caller's request: %+v", request.Messages)
+	}
+	if len(order) != 3 || order[0] != "tenant" || order[1] != "budget" || order[2] != "audit" {
+		t.Errorf("Unexpected hook order %v", order)
+	}
+	if len(sent.Messages) != 2 || sent.Messages[0].Content != "You work for tenant 42." {
+		t.Errorf("Expected injected system prompt, got %+v", sent.Messages)
+	}
+	if observed != response {
+		t.Error("Expected AfterResponse to observe the returned response")
+	}
+
+	t.Run("before hook aborts", func(t *testing.T) {
+		errBudget := errors.New("budget exceeded")
+		var hookErr error
+		calls := 0
+		server := newChatServer(t, func(r *http.Request) { calls++ })
+		client, err := NewClient(Config{
+			Provider: ProviderQwen,
+			APIKey:   "test-key",
+			BaseURL:  server.URL,
+			BeforeRequest: []BeforeRequestHook{
+				func(ctx context.Context, request *Request) error { return errBudget },
+			},
+			AfterResponse: []AfterResponseHook{
+				func(ctx context.Context, request *Request, response *Response, err error) { hookErr = err },
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		_, err = GenerateSimple(context.Background(), client, "Hello")
+		if !errors.Is(err, errBudget) {
+			t.Fatalf("Expected hook error, got %v", err)
+		}
+		if calls != 0 {
+			t.Errorf("Expected no provider call, got %d", calls)
+		}
+		if !errors.Is(hookErr, errBudget) {
+			t.Errorf("Expected AfterResponse to observe the abort, got %v", hookErr)
+		}
+	})
+}
+
+func TestHookAttempts(t *testing.T) {
+	var payloads []map[string]interface{}
+	var keys []string
+	server := serveSegments(t, []string{"Once upon", " a time", " there was a fox."}, &payloads, &keys)
+
+	var before, after []int
+	var last *Response
+	config := Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		BeforeRequest: []BeforeRequestHook{
+			func(ctx context.Context, request *Request) error {
+				attempt, _ := AttemptFromContext(ctx)
+				before = append(before, attempt)
+				request.AddSystemMessage("You work for tenant 42.")
+				return nil
+			},
+		},
+		AfterResponse: []AfterResponseHook{
+			func(ctx context.Context, request *Request, response *Response, err error) {
+				attempt, _ := AttemptFromContext(ctx)
+				after = append(after, attempt)
+				last = response
+			},
+		},
+	}
+	client, _ := NewClient(config)
+
+	response, err := client.Generate(context.Background(), NewRequest(WithUser("Tell a story"), WithContinueOnLength(3)))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if fmt.Sprint(before) != "[1 2 3]" || fmt.Sprint(after) != "[1 2 3]" {
+		t.Errorf("Expected hooks per attempt, got before %v after %v", before, after)
+	}
+	if last != response {
+		t.Error("Expected the last AfterResponse to observe the returned response")
+	}
+	for i, payload := range payloads {
+		messages, _ := payload["messages"].([]interface{})
+		system := 0
+		for _, message := range messages {
+			if message.(map[string]interface{})["role"] == "system" {
+				system++
+			}
+		}
+		if system != 1 {
+			t.Errorf("Expected one system message in request %d, got %v", i+1, messages)
+		}
+	}
+
+	t.Run("stream resume", func(t *testing.T) {
+		var payloads []map[string]interface{}
+		server := serveDroppingStream(t, 1, &payloads)
+		before, after = nil, nil
+		config.BaseURL = server.URL
+		config.StreamResumeAttempts = 2
+		client, _ := NewClient(config)
+
+		response, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(StreamChunk) error { return nil })
+		if err != nil {
+			t.Fatalf("GenerateWithCallback failed: %v", err)
+		}
+		if fmt.Sprint(before) != "[1 2]" || fmt.Sprint(after) != "[1 2]" {
+			t.Errorf("Expected hooks per attempt, got before %v after %v", before, after)
+		}
+		if last.Content != response.Content {
+			t.Errorf("Expected the last AfterResponse to observe the whole stream, got %+v", last)
+		}
+		messages, _ := payloads[1]["messages"].([]interface{})
+		if len(messages) != 3 {
+			t.Errorf("Expected the system prompt, the question and the resume prompt, got %v", messages)
+		}
+	})
+}
+
+func TestContextHooks(t *testing.T) {
+	server := newChatServer(t, nil)
+	var order []string
+	config := Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		BeforeRequest: []BeforeRequestHook{
+			func(ctx context.Context, request *Request) error {
+				order = append(order, "config")
+				return nil
+			},
+		},
+	}
+	primary, _ := NewClient(config)
+	config.BeforeRequest = nil
+	mirrored, _ := NewClient(config)
+	client := NewShadowClient(primary, mirrored, ShadowConfig{SampleRate: 1})
+
+	var mu sync.Mutex
+	ctx := WithHooks(context.Background(), func(ctx context.Context, request *Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, "outer")
+		return nil
+	}, nil)
+	ctx = WithHooks(ctx, func(ctx context.Context, request *Request) error {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, "inner")
+		return nil
+	}, func(ctx context.Context, request *Request, response *Response, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempt, _ := AttemptFromContext(ctx)
+		order = append(order, fmt.Sprintf("after %d", attempt))
+	})
+	if _, err := client.Generate(ctx, BuildSimpleRequest("Hello")); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	client.Close()
+
+	if fmt.Sprint(order) != "[config outer inner after 1]" {
+		t.Errorf("Expected the context hooks to run once after the config hooks, got %v", order)
+	}
+}
@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Default response body limits, see Config.MaxResponseBytes
+const (
+	defaultMaxResponseBytes          int64 = 8 << 20
+	defaultMaxEmbeddingResponseBytes int64 = 64 << 20
+	defaultMaxBatchFileBytes         int64 = 512 << 20
+)
+
+// newJSONRequest creates a POST request carrying a JSON payload, gzip-compressed
+// when Config.GzipRequests is set. Accept-Encoding is always advertised and
+// compressed responses are decoded in readBody.
+func newJSONRequest(ctx context.Context, config Config, url string, payload []byte) (*http.Request, error) {
+	body := payload
+	if config.GzipRequests {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(payload); err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress request: %w", err)
+		}
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	if config.GzipRequests {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req, nil
+}
+
+// newGetRequest creates a GET request accepting gzip responses
+func newGetRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+	return req, nil
+}
+
+// authHeaders carry provider credentials, which headers from the context
+// never replace
+var authHeaders = map[string]bool{
+	"Authorization":       true,
+	"Api-Key":             true,
+	"X-Api-Key":           true,
+	"X-Goog-Api-Key":      true,
+	"Proxy-Authorization": true,
+}
+
+// applyHeaders sets user-supplied headers on an outgoing request.
+// Config.Headers are applied first, then the headers of the request context
+// (see WithHeaders) and per-request headers last, all after the
+// provider-specific headers, so the config and the request can override
+// things like the authorization header a gateway expects.
+func applyHeaders(req *http.Request, config Config, requestHeaders map[string]string) {
+	for k, v := range config.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, v := range HeadersFromContext(req.Context()) {
+		if !authHeaders[k] {
+			req.Header.Set(k, v)
+		}
+	}
+	for k, v := range requestHeaders {
+		req.Header.Set(k, v)
+	}
+}
+
+// requestIDHeader carries the caller's correlation ID
+const requestIDHeader = "X-Request-ID"
+
+// setRequestID sets X-Request-ID from the context, generating one if absent
+func setRequestID(ctx context.Context, req *http.Request) {
+	id, ok := RequestIDFromContext(ctx)
+	if !ok {
+		id = newUUID()
+	}
+	req.Header.Set(requestIDHeader, id)
+}
+
+// setIdempotencyKey attaches an Idempotency-Key header for providers that honor it
+func setIdempotencyKey(ctx context.Context, req *http.Request, provider Provider, requestKey string) {
+	if provider != ProviderOpenAI {
+		return
+	}
+	req.Header.Set("Idempotency-Key", resolveIdempotencyKey(ctx, requestKey))
+}
+
+// responseLimit returns the configured body limit or the given default
+func responseLimit(config Config, defaultLimit int64) int64 {
+	if config.MaxResponseBytes > 0 {
+		return config.MaxResponseBytes
+	}
+	return defaultLimit
+}
+
+// readBody reads at most the configured limit (or defaultLimit) of the
+// (decompressed) response body and dumps it to Config.DebugWriter. An
+// oversized successful response fails with ResponseTooLargeError; an oversized
+// error response is truncated to the limit so it can still be reported.
+func readBody(config Config, resp *http.Response, defaultLimit int64) ([]byte, error) {
+	limit := responseLimit(config, defaultLimit)
+	reader, err := decompressedBody(resp)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) <= limit {
+		dumpResponse(config, resp, body)
+		return body, nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		dumpResponse(config, resp, body[:limit])
+		return body[:limit], nil
+	}
+	return nil, &ResponseTooLargeError{
+		Limit:       limit,
+		Read:        int64(len(body)),
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+}
+
+// bodyReader returns the (decompressed) body of a successful response for
+// decoding as a stream, so large bodies are never held in memory whole.
+// Reading past the limit of readBody fails with ResponseTooLargeError. With
+// Config.DebugWriter the body is read with readBody instead, to dump it.
+func bodyReader(config Config, resp *http.Response, defaultLimit int64) (io.Reader, error) {
+	if config.DebugWriter != nil {
+		body, err := readBody(config, resp, defaultLimit)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(body), nil
+	}
+	reader, err := decompressedBody(resp)
+	if err != nil {
+		return nil, err
+	}
+	limit := responseLimit(config, defaultLimit)
+	return &limitedBody{reader: reader, limit: limit, remaining: limit, contentType: resp.Header.Get("Content-Type")}, nil
+}
+
+// decompressedBody returns the body of resp, gunzipped if it is compressed
+func decompressedBody(resp *http.Response) (io.Reader, error) {
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		return resp.Body, nil
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip response: %w", err)
+	}
+	return zr, nil
+}
+
+// limitedBody is a body reader that fails with ResponseTooLargeError once
+// more than limit bytes are available
+type limitedBody struct {
+	reader      io.Reader
+	limit       int64
+	remaining   int64
+	contentType string
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		var probe [1]byte
+		n, err := b.reader.Read(probe[:])
+		if n > 0 {
+			return 0, &ResponseTooLargeError{Limit: b.limit, Read: b.limit + 1, ContentType: b.contentType}
+		}
+		return 0, err
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
@@ -0,0 +1,402 @@
+package llm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// newChatServer starts a test server answering every request with a minimal
+// OpenAI-style chat completion and passing the request to inspect.
+func newChatServer(t *testing.T, inspect func(r *http.Request)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inspect != nil {
+			inspect(r)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCustomHeaders(t *testing.T) {
+	var got http.Header
+	server := newChatServer(t, func(r *http.Request) {
+		got = r.Header.Clone()
+	})
+
+	client, err := NewClient(Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		Headers: map[string]string{
+			"X-Title":              "config-title",
+			"cf-aig-authorization": "Bearer gateway",
+			"Authorization":        "Bearer override",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	request := BuildSimpleRequest("Hello")
+	request.Headers = map[string]string{"X-Title": "request-title"}
+
+	if _, err := client.Generate(context.Background(), request); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if got.Get("X-Title") != "request-title" {
+		t.Errorf("Expected request header to win, got %q", got.Get("X-Title"))
+	}
+	if got.Get("Cf-Aig-Authorization") != "Bearer gateway" {
+		t.Errorf("Config header not applied, got %q", got.Get("Cf-Aig-Authorization"))
+	}
+	if got.Get("Authorization") != "Bearer override" {
+		t.Errorf("Config header should override provider header, got %q", got.Get("Authorization"))
+	}
+	if got.Get("Content-Type") != "application/json" {
+		t.Errorf("Provider header lost, got %q", got.Get("Content-Type"))
+	}
+}
+
+func TestContextHeaders(t *testing.T) {
+	stream, err := os.ReadFile("testdata/streams/openai_chat.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Clone())
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write(stream)
+			return
+		}
+		if len(got) == 1 {
+			// an empty answer is retried
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":""},"finish_reason":"stop"}]}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	var debug bytes.Buffer
+	client, err := NewClient(Config{
+		Provider:    ProviderOpenAI,
+		APIKey:      "test-key",
+		BaseURL:     server.URL,
+		Headers:     map[string]string{"X-Route": "config", "X-Title": "config-title"},
+		DebugWriter: &debug,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := WithHeaders(context.Background(), map[string]string{"x-route": "outer", "X-Tenant": "acme"})
+	ctx = WithHeaders(ctx, map[string]string{"X-Route": "eu-west", "Authorization": "Bearer stolen", "X-Api-Key": "secret"})
+	request := BuildSimpleRequest("Hello")
+	request.Headers = map[string]string{"X-Title": "request-title"}
+	if _, err := client.Generate(ctx, request); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	response, err := GenerateStream(ctx, client, BuildSimpleRequest("Hello"))
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	for range response.Stream {
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("Expected a retry and a stream, got %d requests", len(got))
+	}
+	for i, header := range got {
+		if header.Get("X-Route") != "eu-west" || header.Get("X-Tenant") != "acme" {
+			t.Errorf("Request %d: expected the context headers over the config ones, got %v", i, header)
+		}
+		if header.Get("Authorization") != "Bearer test-key" || header.Get("X-Api-Key") != "" {
+			t.Errorf("Request %d: expected auth headers untouched, got %v", i, header)
+		}
+	}
+	if got[0].Get("X-Title") != "request-title" {
+		t.Errorf("Expected the request header to win, got %q", got[0].Get("X-Title"))
+	}
+	if out := debug.String(); !strings.Contains(out, "X-Route: eu-west") || !strings.Contains(out, "X-Tenant: acme") {
+		t.Errorf("Expected context headers in the debug dump: %s", out)
+	}
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(status)
+		w.Write([]byte(strings.Repeat("<html>", 20000)))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{
+		Provider:         ProviderOpenAI,
+		APIKey:           "test-key",
+		BaseURL:          server.URL,
+		MaxResponseBytes: 64 << 10,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	t.Run("oversized success", func(t *testing.T) {
+		_, err := GenerateSimple(context.Background(), client, "Hello")
+		if !errors.Is(err, ErrResponseTooLarge) {
+			t.Fatalf("Expected ErrResponseTooLarge, got %v", err)
+		}
+		var tooLarge *ResponseTooLargeError
+		if !errors.As(err, &tooLarge) {
+			t.Fatalf("Expected ResponseTooLargeError, got %T", err)
+		}
+		if tooLarge.ContentType != "text/html" || tooLarge.Read <= tooLarge.Limit {
+			t.Errorf("Unexpected error details: %+v", tooLarge)
+		}
+	})
+
+	t.Run("oversized error body", func(t *testing.T) {
+		status = http.StatusBadGateway
+		_, err := GenerateSimple(context.Background(), client, "Hello")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected APIError, got %v", err)
+		}
+		if len(apiErr.Body) != 64<<10 {
+			t.Errorf("Expected body bounded to the limit, got %d bytes", len(apiErr.Body))
+		}
+		if len(err.Error()) > maxErrorBodyInMessage+200 {
+			t.Errorf("Error message not truncated: %d bytes", len(err.Error()))
+		}
+	})
+}
+
+func TestGzipCompression(t *testing.T) {
+	var gotInput []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Expected gzip request body, got %q", r.Header.Get("Content-Encoding"))
+		}
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			t.Errorf("Expected Accept-Encoding gzip, got %q", r.Header.Get("Accept-Encoding"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("Request body is not gzip: %v", err)
+		}
+		var payload struct {
+			Input []string `json:"input"`
+		}
+		if err := json.NewDecoder(zr).Decode(&payload); err != nil {
+			t.Fatalf("Failed to decode request: %v", err)
+		}
+		gotInput = payload.Input
+
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"data":[{"embedding":[0.1,0.2],"index":0}],"model":"m","usage":{"total_tokens":2}}`))
+		zw.Close()
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{
+		Provider:     ProviderOpenAI,
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		GzipRequests: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	resp, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"hello"}})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if len(gotInput) != 1 || gotInput[0] != "hello" {
+		t.Errorf("Unexpected request input %v", gotInput)
+	}
+	if len(resp.Embeddings) != 1 || len(resp.Embeddings[0]) != 2 {
+		t.Errorf("Unexpected embeddings %v", resp.Embeddings)
+	}
+}
+
+// BenchmarkGzipEmbeddingPayload measures compressing a ~1MB embedding batch,
+// reporting the compression ratio alongside the CPU cost.
+func BenchmarkGzipEmbeddingPayload(b *testing.B) {
+	inputs := make([]string, 0, 2048)
+	size := 0
+	for i := 0; size < 1<<20; i++ {
+		text := fmt.Sprintf("Document %d: the specialist helps clients with burnout, anxiety and career transitions in a supportive setting.", i)
+		inputs = append(inputs, text)
+		size += len(text)
+	}
+	payload, err := json.Marshal(map[string]interface{}{"model": "text-embedding-3-small", "input": inputs})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ctx := context.Background()
+	config := Config{GzipRequests: true}
+	var compressed int64
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req, err := newJSONRequest(ctx, config, "http://localhost/embeddings", payload)
+		if err != nil {
+			b.Fatal(err)
+		}
+		compressed = req.ContentLength
+	}
+	b.ReportMetric(float64(len(payload))/float64(compressed), "ratio")
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	var got string
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Idempotency-Key")
+		if fail {
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	newTestClient := func(provider Provider) Client {
+		client, err := NewClient(Config{Provider: provider, APIKey: "test-key", BaseURL: server.URL})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+	openAI := newTestClient(ProviderOpenAI)
+	ctx := context.Background()
+
+	t.Run("generated", func(t *testing.T) {
+		if _, err := GenerateSimple(ctx, openAI, "Hello"); err != nil {
+			t.Fatal(err)
+		}
+		if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`).MatchString(got) {
+			t.Errorf("Expected UUIDv4 idempotency key, got %q", got)
+		}
+	})
+
+	t.Run("context and request override", func(t *testing.T) {
+		keyed := WithIdempotencyKey(ctx, "ctx-key")
+		if _, err := GenerateSimple(keyed, openAI, "Hello"); err != nil {
+			t.Fatal(err)
+		}
+		if got != "ctx-key" {
+			t.Errorf("Expected context key, got %q", got)
+		}
+
+		request := BuildSimpleRequest("Hello")
+		request.IdempotencyKey = "request-key"
+		if _, err := openAI.Generate(keyed, request); err != nil {
+			t.Fatal(err)
+		}
+		if got != "request-key" {
+			t.Errorf("Expected request key to win, got %q", got)
+		}
+	})
+
+	t.Run("ignored by other providers", func(t *testing.T) {
+		if _, err := GenerateSimple(WithIdempotencyKey(ctx, "ctx-key"), newTestClient(ProviderDeepSeek), "Hello"); err != nil {
+			t.Fatal(err)
+		}
+		if got != "" {
+			t.Errorf("Expected no idempotency key for DeepSeek, got %q", got)
+		}
+	})
+
+	t.Run("attached to APIError", func(t *testing.T) {
+		fail = true
+		defer func() { fail = false }()
+		_, err := GenerateSimple(WithIdempotencyKey(ctx, "retry-key"), openAI, "Hello")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.IdempotencyKey != "retry-key" {
+			t.Errorf("Expected APIError with idempotency key, got %v", err)
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	var got string
+	server := newChatServer(t, func(r *http.Request) {
+		got = r.Header.Get("X-Request-ID")
+	})
+
+	configs := map[string]Config{
+		"openai": {Provider: ProviderOpenAI, BaseURL: server.URL},
+		"qwen":   {Provider: ProviderQwen, BaseURL: server.URL},
+		"azure":  {Provider: ProviderAzure, BaseURL: server.URL + "/openai/deployments/test"},
+		"cohere": {Provider: ProviderCohere, BaseURL: server.URL},
+	}
+
+	for name, config := range configs {
+		t.Run(name, func(t *testing.T) {
+			config.APIKey = "test-key"
+			client, err := NewClient(config)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			response, err := GenerateSimple(WithRequestID(context.Background(), "req-123"), client, "Hello")
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if got != "req-123" || response.RequestID != "req-123" {
+				t.Errorf("Expected request ID req-123, sent %q, response %q", got, response.RequestID)
+			}
+
+			response, err = GenerateSimple(context.Background(), client, "Hello")
+			if err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if got == "" || response.RequestID != got {
+				t.Errorf("Expected generated request ID, sent %q, response %q", got, response.RequestID)
+			}
+		})
+	}
+
+	t.Run("APIError", func(t *testing.T) {
+		failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-Id", "provider-456")
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer failing.Close()
+
+		client, err := NewClient(Config{Provider: ProviderDeepSeek, APIKey: "test-key", BaseURL: failing.URL})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		_, err = GenerateSimple(WithRequestID(context.Background(), "req-123"), client, "Hello")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected APIError, got %v", err)
+		}
+		if apiErr.RequestID != "req-123" || apiErr.ProviderRequestID != "provider-456" {
+			t.Errorf("Unexpected request IDs: %q / %q", apiErr.RequestID, apiErr.ProviderRequestID)
+		}
+	})
+}
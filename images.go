@@ -0,0 +1,176 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Image response formats
+const (
+	ImageFormatURL     = "url"
+	ImageFormatB64JSON = "b64_json"
+)
+
+// ImageRequest asks an image model to draw Prompt
+type ImageRequest struct {
+	Prompt string `json:"prompt"`
+
+	// Model override (optional, default dall-e-3 for OpenAI; gateways such
+	// as Together or Fireworks need their own model name)
+	Model *string `json:"model,omitempty"`
+
+	// Size such as "1024x1024" (optional, provider default)
+	Size string `json:"size,omitempty"`
+
+	// Quality such as "standard", "hd" or "high" (optional)
+	Quality string `json:"quality,omitempty"`
+
+	// N is the number of images (0 = 1)
+	N int `json:"n,omitempty"`
+
+	// ResponseFormat is ImageFormatURL (default) or ImageFormatB64JSON,
+	// which returns the decoded bytes in Image.Data
+	ResponseFormat string `json:"response_format,omitempty"`
+
+	// Extra HTTP headers for this request only. Applied after Config.Headers.
+	Headers map[string]string `json:"-"`
+}
+
+// Image is one generated image: URL for ImageFormatURL, Data otherwise
+type Image struct {
+	URL  string `json:"url,omitempty"`
+	Data []byte `json:"data,omitempty"`
+	// RevisedPrompt is the prompt the model actually used, when it rewrote it
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+// ImageResponse holds the generated images
+type ImageResponse struct {
+	Images       []Image       `json:"images"`
+	Model        string        `json:"model"`
+	ResponseTime time.Duration `json:"response_time"`
+
+	// RequestID is the X-Request-ID sent with the request (see WithRequestID)
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ImageGenerator is implemented by clients whose provider offers image
+// generation; check Capabilities().ImageGeneration or call GenerateImage
+type ImageGenerator interface {
+	GenerateImage(ctx context.Context, request ImageRequest) (*ImageResponse, error)
+}
+
+// GenerateImage generates images with client, failing with a
+// CapabilityError when its provider has no image model. Prompts rejected by
+// the provider's safety system fail with an APIError matching
+// ErrContentFiltered.
+func GenerateImage(ctx context.Context, client Client, request ImageRequest) (*ImageResponse, error) {
+	generator, ok := client.(ImageGenerator)
+	if !ok || !client.Capabilities().ImageGeneration {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilityImageGeneration}
+	}
+	return generator.GenerateImage(ctx, request)
+}
+
+// imageFunc performs a single provider image generation call
+type imageFunc func(ctx context.Context, request ImageRequest) (*ImageResponse, error)
+
+// instrumentImage runs a provider image call under the client timeout and
+// reports it like instrumentRerank does. Images are billed per image, so no
+// token usage is reported.
+func instrumentImage(ctx context.Context, config Config, model string, request ImageRequest, call imageFunc) (*ImageResponse, error) {
+	if request.Prompt == "" {
+		return nil, fmt.Errorf("image request has no prompt")
+	}
+	switch request.ResponseFormat {
+	case "", ImageFormatURL, ImageFormatB64JSON:
+	default:
+		return nil, fmt.Errorf("unsupported image response format %q (supported: url, b64_json)", request.ResponseFormat)
+	}
+
+	startTime := time.Now()
+	response, err := withTimeout(ctx, config, "", 0, func(ctx context.Context) (*ImageResponse, error) {
+		return call(ctx, request)
+	})
+	latency := time.Since(startTime)
+
+	observe(ctx, config, OperationImage, model, latency, Usage{}, err)
+	if config.Logger != nil && config.Logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("provider", string(config.Provider)),
+			slog.String("model", model),
+			slog.Int("n", max(request.N, 1)),
+			slog.Duration("latency", latency),
+		}
+		logResult(ctx, config, "llm image", attrs, err)
+	}
+	return response, err
+}
+
+// imagePayload builds the body of an OpenAI /images/generations request
+func imagePayload(request ImageRequest, model string) map[string]interface{} {
+	payload := map[string]interface{}{"prompt": request.Prompt}
+	if model != "" {
+		payload["model"] = model
+	}
+	if request.Size != "" {
+		payload["size"] = request.Size
+	}
+	if request.Quality != "" {
+		payload["quality"] = request.Quality
+	}
+	if request.N > 0 {
+		payload["n"] = request.N
+	}
+	if request.ResponseFormat != "" {
+		payload["response_format"] = request.ResponseFormat
+	}
+	return payload
+}
+
+// parseImages parses an OpenAI /images/generations response, decoding
+// base64 images
+func parseImages(body []byte, model string) (*ImageResponse, error) {
+	var apiResp struct {
+		Data []struct {
+			URL           string `json:"url"`
+			B64JSON       string `json:"b64_json"`
+			RevisedPrompt string `json:"revised_prompt"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal image response: %w", err)
+	}
+	if len(apiResp.Data) == 0 {
+		return nil, fmt.Errorf("no images in response")
+	}
+
+	images := make([]Image, len(apiResp.Data))
+	for i, item := range apiResp.Data {
+		images[i] = Image{URL: item.URL, RevisedPrompt: item.RevisedPrompt}
+		if item.B64JSON != "" {
+			data, err := base64.StdEncoding.DecodeString(item.B64JSON)
+			if err != nil {
+				return nil, fmt.Errorf("invalid base64 image: %w", err)
+			}
+			images[i].Data = data
+		}
+	}
+	return &ImageResponse{Images: images, Model: model}, nil
+}
+
+// getImageModel returns the image model to use for the request. Only
+// OpenAI has a default; gateways and Azure deployments choose their own.
+func getImageModel(provider Provider, override *string) string {
+	if override != nil {
+		return *override
+	}
+	if provider == ProviderOpenAI {
+		return "dall-e-3"
+	}
+	return ""
+}
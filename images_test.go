@@ -0,0 +1,64 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGenerateImage(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		switch {
+		case payload["prompt"] == "forbidden":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"code":"content_policy_violation","message":"Your request was rejected by the safety system."}}`))
+		case payload["response_format"] == "b64_json":
+			w.Write([]byte(`{"data":[{"b64_json":"iVBORw=="}]}`))
+		default:
+			w.Write([]byte(`{"data":[{"url":"https://example.com/cat.png","revised_prompt":"A fluffy cat"}]}`))
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+	resp, err := GenerateImage(ctx, client, ImageRequest{Prompt: "a cat", Size: "1024x1024", Quality: "hd"})
+	if err != nil {
+		t.Fatalf("GenerateImage failed: %v", err)
+	}
+	if payload["model"] != "dall-e-3" || payload["size"] != "1024x1024" || payload["quality"] != "hd" {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+	if len(resp.Images) != 1 || resp.Images[0].URL != "https://example.com/cat.png" || resp.Images[0].RevisedPrompt != "A fluffy cat" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	model := "black-forest-labs/FLUX.1-schnell"
+	resp, err = GenerateImage(ctx, client, ImageRequest{Prompt: "a cat", Model: &model, N: 1, ResponseFormat: ImageFormatB64JSON})
+	if err != nil {
+		t.Fatalf("GenerateImage failed: %v", err)
+	}
+	if payload["model"] != model || string(resp.Images[0].Data) != "\x89PNG" {
+		t.Errorf("Unexpected base64 result %q for payload %v", resp.Images[0].Data, payload)
+	}
+
+	_, err = GenerateImage(ctx, client, ImageRequest{Prompt: "forbidden"})
+	if !errors.Is(err, ErrContentFiltered) || ErrorClass(err) != StatusContentFilter {
+		t.Errorf("Expected a content filter error, got %v (%s)", err, ErrorClass(err))
+	}
+
+	if _, err := GenerateImage(ctx, client, ImageRequest{Prompt: "a cat", ResponseFormat: "png"}); err == nil {
+		t.Error("Expected an unsupported response format to fail")
+	}
+
+	cohere, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL})
+	if _, err := GenerateImage(ctx, cohere, ImageRequest{Prompt: "a cat"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
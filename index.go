@@ -0,0 +1,209 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/yhwhpe/llm-unified-client/embeddingutil"
+)
+
+// SearchResult is one VectorIndex match
+type SearchResult struct {
+	ID       string            `json:"id"`
+	Score    float64           `json:"score"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// indexEntry is one stored vector
+type indexEntry struct {
+	ID       string            `json:"id"`
+	Vector   []float64         `json:"vector"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// VectorIndex is an in-memory vector store for prototypes, tests and small
+// corpora. Search scans every vector and ranks by cosine similarity
+// (embeddingutil.TopK), which is fast enough up to about 100k vectors. It is
+// safe for concurrent use.
+type VectorIndex struct {
+	mu         sync.RWMutex
+	entries    []indexEntry
+	positions  map[string]int // id -> position in entries
+	dimensions int
+}
+
+// NewVectorIndex creates an empty index; its dimension is set by the first Add
+func NewVectorIndex() *VectorIndex {
+	return &VectorIndex{positions: map[string]int{}}
+}
+
+// Add stores vector under id, replacing any vector with the same id. All
+// vectors must have the same dimension.
+func (x *VectorIndex) Add(id string, vector []float64, metadata map[string]string) error {
+	if len(vector) == 0 {
+		return fmt.Errorf("vector for %q is empty", id)
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.dimensions != 0 && len(vector) != x.dimensions {
+		return fmt.Errorf("vector for %q: %w: index has %d, got %d", id, embeddingutil.ErrDimensionMismatch, x.dimensions, len(vector))
+	}
+	x.dimensions = len(vector)
+
+	entry := indexEntry{ID: id, Vector: vector, Metadata: metadata}
+	if i, ok := x.positions[id]; ok {
+		x.entries[i] = entry
+		return nil
+	}
+	x.positions[id] = len(x.entries)
+	x.entries = append(x.entries, entry)
+	return nil
+}
+
+// AddText embeds text with client and stores the vector under id
+func (x *VectorIndex) AddText(ctx context.Context, client Client, id, text string, metadata map[string]string) error {
+	vector, err := embedText(ctx, client, text)
+	if err != nil {
+		return err
+	}
+	return x.Add(id, vector, metadata)
+}
+
+// Delete removes id and reports whether it was present
+func (x *VectorIndex) Delete(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	i, ok := x.positions[id]
+	if !ok {
+		return false
+	}
+	last := len(x.entries) - 1
+	x.entries[i] = x.entries[last]
+	x.positions[x.entries[i].ID] = i
+	x.entries = x.entries[:last]
+	delete(x.positions, id)
+	return true
+}
+
+// Len returns the number of stored vectors
+func (x *VectorIndex) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.entries)
+}
+
+// Dimensions returns the dimension of the stored vectors (0 while empty)
+func (x *VectorIndex) Dimensions() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.dimensions
+}
+
+// Search returns the k vectors most similar to query, best first. Only
+// entries whose metadata has every key/value pair of filter are considered
+// (nil = all).
+func (x *VectorIndex) Search(query []float64, k int, filter map[string]string) ([]SearchResult, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	candidates := make([]*indexEntry, 0, len(x.entries))
+	corpus := make([][]float64, 0, len(x.entries))
+	for i := range x.entries {
+		if matchesFilter(x.entries[i].Metadata, filter) {
+			candidates = append(candidates, &x.entries[i])
+			corpus = append(corpus, x.entries[i].Vector)
+		}
+	}
+	matches, err := embeddingutil.TopK(query, corpus, k)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SearchResult, len(matches))
+	for i, match := range matches {
+		entry := candidates[match.Index]
+		results[i] = SearchResult{ID: entry.ID, Score: match.Score, Metadata: entry.Metadata}
+	}
+	return results, nil
+}
+
+// SearchText embeds query with client and searches for it
+func (x *VectorIndex) SearchText(ctx context.Context, client Client, query string, k int, filter map[string]string) ([]SearchResult, error) {
+	vector, err := embedText(ctx, client, query)
+	if err != nil {
+		return nil, err
+	}
+	return x.Search(vector, k, filter)
+}
+
+// Save writes the index to path as JSON. The file is replaced atomically,
+// so a crash never leaves a truncated index behind.
+func (x *VectorIndex) Save(path string) error {
+	x.mu.RLock()
+	data, err := json.Marshal(x.entries)
+	x.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadVectorIndex reads an index written by Save
+func LoadVectorIndex(path string) (*VectorIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+	var entries []indexEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %w", err)
+	}
+
+	x := NewVectorIndex()
+	for _, entry := range entries {
+		if err := x.Add(entry.ID, entry.Vector, entry.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to load index: %w", err)
+		}
+	}
+	return x, nil
+}
+
+// matchesFilter reports whether metadata has every pair of filter
+func matchesFilter(metadata, filter map[string]string) bool {
+	for key, value := range filter {
+		if got, ok := metadata[key]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// embedText embeds a single text as a float64 vector
+func embedText(ctx context.Context, client Client, text string) ([]float64, error) {
+	response, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{text}})
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(response.Embeddings))
+	}
+	return response.Embeddings[0], nil
+}
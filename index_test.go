@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yhwhpe/llm-unified-client/embeddingutil"
+)
+
+func TestVectorIndex(t *testing.T) {
+	index := NewVectorIndex()
+	index.Add("x", []float64{1, 0}, map[string]string{"lang": "en"})
+	index.Add("y", []float64{0, 1}, map[string]string{"lang": "en"})
+	index.Add("xy", []float64{1, 1}, map[string]string{"lang": "de"})
+	index.Add("-x", []float64{-1, 0}, nil)
+
+	results, err := index.Search([]float64{1, 0.2}, 2, nil)
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if len(results) != 2 || results[0].ID != "x" || results[1].ID != "xy" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+	if want, _ := embeddingutil.CosineSimilarity([]float64{1, 0.2}, []float64{1, 1}); results[1].Score != want {
+		t.Errorf("Expected embeddingutil scoring %v, got %v", want, results[1].Score)
+	}
+
+	results, _ = index.Search([]float64{1, 0.2}, 5, map[string]string{"lang": "en"})
+	if len(results) != 2 || results[0].ID != "x" || results[1].ID != "y" {
+		t.Errorf("Expected only lang=en entries, got %+v", results)
+	}
+
+	if err := index.Add("z", []float64{1, 2, 3}, nil); !errors.Is(err, embeddingutil.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch, got %v", err)
+	}
+	if !index.Delete("x") || index.Delete("x") || index.Len() != 3 {
+		t.Errorf("Delete did not remove exactly one entry, %d left", index.Len())
+	}
+	if results, _ := index.Search([]float64{1, 0}, 1, nil); results[0].ID != "xy" {
+		t.Errorf("Deleted entry still found: %+v", results)
+	}
+
+	path := filepath.Join(t.TempDir(), "index.json")
+	if err := index.Save(path); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	loaded, err := LoadVectorIndex(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if loaded.Len() != 3 || loaded.Dimensions() != 2 {
+		t.Errorf("Loaded %d vectors of dimension %d", loaded.Len(), loaded.Dimensions())
+	}
+	if results, _ := loaded.Search([]float64{0, 1}, 1, map[string]string{"lang": "de"}); results[0].ID != "xy" || results[0].Metadata["lang"] != "de" {
+		t.Errorf("Unexpected results after loading: %+v", results)
+	}
+}
+
+func TestVectorIndexText(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		text := payload.Input[0]
+		vector := []float64{float64(strings.Count(text, "cat")), float64(strings.Count(text, "dog")), 0.1}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": []map[string]interface{}{{"embedding": vector, "index": 0}}})
+	}))
+	defer server.Close()
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true})
+	ctx := context.Background()
+
+	index := NewVectorIndex()
+	for id, text := range map[string]string{"cats": "cat cat cat", "dogs": "dog dog", "both": "cat dog"} {
+		if err := index.AddText(ctx, client, id, text, nil); err != nil {
+			t.Fatalf("AddText failed: %v", err)
+		}
+	}
+	results, err := index.SearchText(ctx, client, "a dog", 1, nil)
+	if err != nil {
+		t.Fatalf("SearchText failed: %v", err)
+	}
+	if results[0].ID != "dogs" {
+		t.Errorf("Expected dogs first, got %+v", results)
+	}
+}
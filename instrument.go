@@ -0,0 +1,162 @@
+package llm
+
+import (
+	"context"
+	"time"
+)
+
+// generateFunc performs a single provider chat call
+type generateFunc func(ctx context.Context, request Request) (*Response, error)
+
+// embeddingFunc performs a single provider embedding call
+type embeddingFunc func(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error)
+
+// instrumentGenerate runs a provider chat call between the configured hooks
+// and reports it to the metrics recorder and logger. Every client's Generate
+// goes through here; getModel resolves the model after hooks ran.
+func instrumentGenerate(ctx context.Context, config Config, getModel func(*string) string, request Request, call generateFunc) (*Response, error) {
+	original := withConfigDefaults(config, request)
+	if err := prepareChatRequest(ctx, config, getModel, &request); err != nil {
+		return nil, err
+	}
+	model := getModel(request.Model)
+	attempts := newChatAttempts(config, getModel, original, request)
+
+	startTime := time.Now()
+	callCtx, trace := withTimingTrace(ctx, config, startTime)
+	response, err := generateContinued(callCtx, request, func(ctx context.Context, request Request) (*Response, error) {
+		return retryEmptyResponses(ctx, config, request, func(ctx context.Context, request Request) (*Response, error) {
+			ctx, request, err := attempts.start(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			response, err := withTimeout(ctx, config, model, request.Timeout, func(ctx context.Context) (*Response, error) {
+				return call(ctx, request)
+			})
+			attempts.record(response, err)
+			return response, err
+		})
+	})
+	latency := time.Since(startTime)
+	var timing *Timing
+	if trace != nil {
+		timing = trace.finish(startTime.Add(latency))
+	}
+
+	var usage Usage
+	if response != nil {
+		usage = response.Usage
+		response.Timing = timing
+		response.Provider = config.Provider
+		if response.Model == "" {
+			response.Model = model
+		}
+	}
+	observeTiming(ctx, config, OperationChat, model, latency, usage, timing, err)
+	logGenerate(ctx, config, model, request, response, latency, err)
+	if err == nil && response != nil {
+		reportUsage(ctx, config, UsageEvent{
+			Model:     response.Model,
+			Operation: OperationChat,
+			Usage:     usage,
+			Latency:   latency,
+			RequestID: response.RequestID,
+		})
+	}
+	attempts.finish(ctx, response, err)
+
+	return response, err
+}
+
+// prepareChatRequest prepares the first attempt of a chat call (see
+// prepareAttempt). On failure the AfterResponse hooks have already seen the
+// error.
+func prepareChatRequest(ctx context.Context, config Config, getModel func(*string) string, request *Request) error {
+	ctx = withAttempt(ctx, 1)
+	if err := prepareAttempt(ctx, config, getModel, request); err != nil {
+		runAfterHooks(ctx, config, request, nil, err)
+		return err
+	}
+	return nil
+}
+
+// prepareAttempt applies the config defaults, runs the BeforeRequest hooks
+// on a copy of request and applies the system message policy and the prompt
+// size guard
+func prepareAttempt(ctx context.Context, config Config, getModel func(*string) string, request *Request) error {
+	*request = withConfigDefaults(config, *request)
+	if len(config.BeforeRequest) > 0 || len(hooksFromContext(ctx).before) > 0 {
+		// hooks may modify the request; never let that reach the caller's copy
+		*request = request.Clone()
+	}
+	if err := runBeforeHooks(ctx, config, request); err != nil {
+		return err
+	}
+	if err := applySystemPolicy(config, request); err != nil {
+		return err
+	}
+	return guardPromptSize(ctx, config, getModel(request.Model), request)
+}
+
+// instrumentEmbedding runs a provider embedding call and reports it to the
+// configured metrics recorder and logger. Every client's CreateEmbedding goes through here.
+func instrumentEmbedding(ctx context.Context, config Config, model string, request EmbeddingRequest, call embeddingFunc) (*EmbeddingResponse, error) {
+	startTime := time.Now()
+	response, err := embedLongInputs(ctx, config, request, func(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+		return embedInBatches(ctx, config, request, func(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+			return withTimeout(ctx, config, model, 0, func(ctx context.Context) (*EmbeddingResponse, error) {
+				return embedWithDimensions(ctx, config, request, call)
+			})
+		})
+	})
+	if response != nil && request.Normalize {
+		normalizeEmbeddings(response)
+	}
+	latency := time.Since(startTime)
+
+	var usage Usage
+	if response != nil {
+		usage = response.Usage
+		if response.Model != "" {
+			model = response.Model
+		}
+	}
+	observe(ctx, config, OperationEmbedding, model, latency, usage, err)
+	logEmbedding(ctx, config, model, request, response, latency, err)
+	if response != nil {
+		// partial batches (AllowPartial) were still billed for their vectors
+		reportUsage(ctx, config, UsageEvent{
+			Model:     model,
+			Operation: OperationEmbedding,
+			Usage:     usage,
+			Latency:   latency,
+			RequestID: response.RequestID,
+		})
+	}
+
+	return response, err
+}
+
+// observe reports a finished call to Config.Metrics
+func observe(ctx context.Context, config Config, operation, model string, latency time.Duration, usage Usage, err error) {
+	observeTiming(ctx, config, operation, model, latency, usage, nil, err)
+}
+
+// observeTiming is observe with the latency breakdown of the call
+func observeTiming(ctx context.Context, config Config, operation, model string, latency time.Duration, usage Usage, timing *Timing, err error) {
+	if config.Metrics == nil {
+		return
+	}
+	variant, _ := VariantFromContext(ctx)
+	config.Metrics.ObserveRequest(RequestMetrics{
+		Provider:  config.Provider,
+		Model:     model,
+		Operation: operation,
+		Status:    ErrorClass(err),
+		Latency:   latency,
+		Usage:     usage,
+		Timing:    timing,
+		Shadow:    IsShadowCall(ctx),
+		Variant:   variant,
+	})
+}
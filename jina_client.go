@@ -0,0 +1,313 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// jinaClient implements Client and Reranker for Jina AI. Jina serves
+// embeddings and reranking only; chat calls fail with a CapabilityError.
+type jinaClient struct {
+	config     Config
+	httpClient *http.Client
+	requests   *requestTracker
+	*liveConfig
+}
+
+// newJinaClient creates a new Jina AI client
+func newJinaClient(config Config) (*jinaClient, error) {
+	if config.APIKey == "" {
+		return nil, fmt.Errorf("API key is required")
+	}
+
+	if config.BaseURL == "" {
+		config.BaseURL = "https://api.jina.ai/v1"
+	}
+	config, err := normalizeBaseURL(config, "/v1")
+	if err != nil {
+		return nil, err
+	}
+
+	if config.Timeout == 0 {
+		config.Timeout = 30 * time.Second
+	}
+
+	if config.DefaultModel == "" {
+		config.DefaultModel = "jina-embeddings-v3"
+	}
+	config = withEmbeddingDefault(config, "jina-embeddings-v3")
+
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, requests := trackRequests(config, httpClient)
+
+	return &jinaClient{
+		config:     config,
+		httpClient: httpClient,
+		requests:   requests,
+		liveConfig: newLiveConfig(config),
+	}, nil
+}
+
+// current returns a copy of the client with the configuration as of now, so
+// that a call sees one consistent set of defaults
+func (c *jinaClient) current() *jinaClient {
+	snapshot := *c
+	snapshot.config = c.load()
+	return &snapshot
+}
+
+// WithModel returns a view of the client using model by default
+func (c *jinaClient) WithModel(model string) Client {
+	return c.WithDefaults(WithModel(model))
+}
+
+// WithDefaults returns a view of the client with the defaults set by opts
+func (c *jinaClient) WithDefaults(opts ...RequestOption) Client {
+	view := c.current()
+	view.config = applyDefaults(view.config, opts)
+	view.httpClient, view.requests = c.requests.view(c.httpClient)
+	view.liveConfig = newLiveConfig(view.config)
+	return view
+}
+
+// Generate fails: Jina has no chat models
+func (c *jinaClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentGenerate(ctx, c.config, c.getModel, request, c.generate)
+}
+
+// generate returns the CapabilityError for chat
+func (c *jinaClient) generate(ctx context.Context, request Request) (*Response, error) {
+	return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityChat}
+}
+
+// GenerateWithHistory fails: Jina has no chat models
+func (c *jinaClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	request := BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// CreateEmbedding generates embeddings for the given text(s)
+func (c *jinaClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	c = c.current()
+	return instrumentEmbedding(ctx, c.config, c.getEmbeddingModel(request.Model), request, c.createEmbedding)
+}
+
+// createEmbedding performs the embedding call without instrumentation
+func (c *jinaClient) createEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	startTime := time.Now()
+
+	// Same shape as OpenAI's embeddings API plus Jina's own fields
+	payload := map[string]interface{}{
+		"model": c.getEmbeddingModel(request.Model),
+		"input": request.Input,
+	}
+	if task := embeddingTaskValue(c.config.Provider, request.Task); task != "" {
+		payload["task"] = task
+	}
+	if request.LateChunking {
+		payload["late_chunking"] = true
+	}
+	if request.Dimensions != nil {
+		payload["dimensions"] = *request.Dimensions
+	}
+
+	var response *EmbeddingResponse
+	req, err := c.post(ctx, "/embeddings", payload, request.Headers, defaultMaxEmbeddingResponseBytes, "Jina Embedding API error", func(body io.Reader) (err error) {
+		response, err = parseOpenAIEmbeddings(body, request.AsFloat32)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// Rerank orders documents by relevance to the query with Jina's reranker
+func (c *jinaClient) Rerank(ctx context.Context, request RerankRequest) (*RerankResponse, error) {
+	c = c.current()
+	return instrumentRerank(ctx, c.config, c.getRerankModel(request.Model), request, c.rerank)
+}
+
+// rerank performs the rerank call without instrumentation
+func (c *jinaClient) rerank(ctx context.Context, request RerankRequest) (*RerankResponse, error) {
+	startTime := time.Now()
+
+	payload := map[string]interface{}{
+		"model":            c.getRerankModel(request.Model),
+		"query":            request.Query,
+		"documents":        request.Documents,
+		"return_documents": request.ReturnDocuments,
+	}
+	if request.TopN != nil {
+		payload["top_n"] = *request.TopN
+	}
+
+	var apiResp struct {
+		Model   string `json:"model"`
+		Results []struct {
+			Index          int     `json:"index"`
+			RelevanceScore float64 `json:"relevance_score"`
+			Document       *struct {
+				Text string `json:"text"`
+			} `json:"document"`
+		} `json:"results"`
+		Usage struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	req, err := c.post(ctx, "/rerank", payload, request.Headers, defaultMaxResponseBytes, "Jina Rerank API error", func(body io.Reader) error {
+		if err := json.NewDecoder(body).Decode(&apiResp); err != nil {
+			return fmt.Errorf("failed to unmarshal rerank response: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]RerankResult, len(apiResp.Results))
+	for i, result := range apiResp.Results {
+		results[i] = RerankResult{Index: result.Index, Score: result.RelevanceScore}
+		if result.Document != nil {
+			results[i].Document = result.Document.Text
+		}
+	}
+
+	return &RerankResponse{
+		Results: results,
+		Model:   apiResp.Model,
+		Usage: Usage{
+			PromptTokens: apiResp.Usage.TotalTokens,
+			TotalTokens:  apiResp.Usage.TotalTokens,
+		},
+		ResponseTime: time.Since(startTime),
+		RequestID:    req.Header.Get(requestIDHeader),
+	}, nil
+}
+
+// post sends a JSON request to path and decodes the body of a successful
+// response with decode, as a stream
+func (c *jinaClient) post(ctx context.Context, path string, payload map[string]interface{}, headers map[string]string, maxBytes int64, errorPrefix string, decode func(body io.Reader) error) (*http.Request, error) {
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, path), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, err := readBody(c.config, resp, maxBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, newAPIError(c.config, errorPrefix, resp, body)
+	}
+
+	body, err := bodyReader(c.config, resp, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if err := decode(body); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Close stops new requests, waits up to Config.CloseTimeout for those in
+// flight and closes the client's idle connections
+func (c *jinaClient) Close() error {
+	return c.requests.close(c.config.CloseTimeout)
+}
+
+// GetConfig returns a copy of the client configuration with the API key masked
+func (c *jinaClient) GetConfig() Config {
+	return maskedConfig(c.load())
+}
+
+// GetConfigWithSecrets returns a copy of the client configuration including the API key
+func (c *jinaClient) GetConfigWithSecrets() Config {
+	return c.load().Clone()
+}
+
+// CountTokens estimates the prompt tokens of request using Config.Tokenizer
+func (c *jinaClient) CountTokens(request Request) int {
+	c = c.current()
+	return countRequestTokens(c.config, c.getModel(request.Model), request)
+}
+
+// Capabilities reports what the client supports for its provider
+func (c *jinaClient) Capabilities() Capabilities {
+	return providerCapabilities(c.config.Provider)
+}
+
+// ListModels fails: Jina has no model listing endpoint
+func (c *jinaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	c = c.current()
+	return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityListModels}
+}
+
+// Ping verifies credentials and reachability by embedding a single word,
+// since Jina can neither list models nor chat
+func (c *jinaClient) Ping(ctx context.Context) error {
+	c = c.current()
+	_, err := withTimeout(ctx, c.config, "", 0, func(ctx context.Context) (*EmbeddingResponse, error) {
+		return c.createEmbedding(ctx, EmbeddingRequest{Input: []string{"ping"}})
+	})
+	if err != nil {
+		return fmt.Errorf("ping: %w", err)
+	}
+	return nil
+}
+
+// Warmup opens idle connections to the provider ahead of the first call
+func (c *jinaClient) Warmup(ctx context.Context) error {
+	c = c.current()
+	return warmup(ctx, c.httpClient, c.config)
+}
+
+// getModel returns the model to use for the request
+func (c *jinaClient) getModel(override *string) string {
+	if override != nil {
+		return *override
+	}
+	return c.config.DefaultModel
+}
+
+// getEmbeddingModel returns the embedding model to use for the request
+func (c *jinaClient) getEmbeddingModel(override *string) string {
+	return resolveEmbeddingModel(c.config, override)
+}
+
+// getRerankModel returns the reranker to use for the request
+func (c *jinaClient) getRerankModel(override *string) string {
+	if override != nil {
+		return *override
+	}
+	return "jina-reranker-v2-base-multilingual"
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJinaClient(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/embeddings":
+			w.Write([]byte(`{"model":"jina-embeddings-v3","data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}],"usage":{"total_tokens":4,"prompt_tokens":4}}`))
+		case "/rerank":
+			w.Write([]byte(`{"model":"jina-reranker-v2-base-multilingual","results":[{"index":1,"relevance_score":0.9,"document":{"text":"b"}},{"index":0,"relevance_score":0.1,"document":{"text":"a"}}],"usage":{"total_tokens":7}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true, DefaultModel: "jina-embeddings-v3"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.GetConfig().Provider != ProviderJina {
+		t.Errorf("Expected the provider detected from the model, got %q", client.GetConfig().Provider)
+	}
+	ctx := context.Background()
+
+	dims := 2
+	resp, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"a", "b"}, Task: "retrieval.passage", LateChunking: true, Dimensions: &dims})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if payload["task"] != "retrieval.passage" || payload["late_chunking"] != true || payload["dimensions"] != float64(2) {
+		t.Errorf("Jina fields not sent: %v", payload)
+	}
+	if resp.Embeddings[0][0] != 1 || resp.Embeddings[1][1] != 1 || resp.Usage.TotalTokens != 4 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+
+	topN := 2
+	ranked, err := Rerank(ctx, client, RerankRequest{Query: "q", Documents: []string{"a", "b"}, TopN: &topN, ReturnDocuments: true})
+	if err != nil {
+		t.Fatalf("Rerank failed: %v", err)
+	}
+	if payload["top_n"] != float64(2) || payload["query"] != "q" {
+		t.Errorf("Unexpected rerank payload: %v", payload)
+	}
+	if len(ranked.Results) != 2 || ranked.Results[0].Index != 1 || ranked.Results[0].Score != 0.9 || ranked.Results[0].Document != "b" || ranked.Usage.TotalTokens != 7 {
+		t.Errorf("Unexpected rerank response %+v", ranked)
+	}
+
+	_, err = client.Generate(ctx, BuildSimpleRequest("hi"))
+	var capErr *CapabilityError
+	if !errors.As(err, &capErr) || capErr.Capability != CapabilityChat {
+		t.Errorf("Expected a chat CapabilityError, got %v", err)
+	}
+	if err := client.Ping(ctx); err != nil {
+		t.Errorf("Ping failed: %v", err)
+	}
+	if caps := client.Capabilities(); caps.Chat || !caps.Embeddings || !caps.Rerank {
+		t.Errorf("Unexpected capabilities %+v", caps)
+	}
+}
+
+func TestRerankUnsupported(t *testing.T) {
+	client, _ := NewClient(Config{Provider: ProviderQwen, APIKey: "test-key"})
+	_, err := Rerank(context.Background(), client, RerankRequest{Query: "q", Documents: []string{"a"}})
+	if !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// StreamingJSONAccumulator collects a JSON object streamed in JSON mode and
+// exposes the fields completed so far, for rendering a structured answer
+// before the stream ends:
+//
+//	var acc llm.StreamingJSONAccumulator
+//	for chunk, err := range llm.Stream(ctx, client, request) {
+//		// ...
+//		acc.Add(chunk)
+//		render(acc.BestEffortValue())
+//	}
+//
+// Text before the opening brace, such as a sentence of prose or a code
+// fence, is skipped, as is anything after the closing brace. The zero value
+// is ready to use; an accumulator is not safe for concurrent use.
+type StreamingJSONAccumulator struct {
+	text    strings.Builder // from the opening brace on
+	started bool
+	done    bool
+
+	stack    []jsonFrame
+	inString bool
+	escaped  bool
+	literal  bool // inside a number, true, false or null
+
+	// cut is the length of the longest prefix of text that ends after a
+	// complete value; closers closes the containers open at that point
+	cut     int
+	closers string
+}
+
+// jsonFrame is an open object or array
+type jsonFrame struct {
+	closer    byte
+	expectKey bool // in an object, the next string is a key
+}
+
+// Add consumes the content delta of chunk
+func (a *StreamingJSONAccumulator) Add(chunk StreamChunk) {
+	a.WriteString(chunk.Content)
+}
+
+// WriteString consumes a delta of the streamed text. It never fails.
+func (a *StreamingJSONAccumulator) WriteString(s string) (int, error) {
+	for i := 0; i < len(s) && !a.done; i++ {
+		a.consume(s[i])
+	}
+	return len(s), nil
+}
+
+// consume advances the scanner by one byte. Multi-byte UTF-8 sequences only
+// occur inside strings, where they need no special handling.
+func (a *StreamingJSONAccumulator) consume(c byte) {
+	if !a.started {
+		if c != '{' {
+			return
+		}
+		a.started = true
+	}
+
+	if a.inString {
+		a.text.WriteByte(c)
+		switch {
+		case a.escaped:
+			a.escaped = false
+		case c == '\\':
+			a.escaped = true
+		case c == '"':
+			a.inString = false
+			if top := a.top(); top != nil && top.closer == '}' && top.expectKey {
+				// A key is not a value; the cut stays before it
+				top.expectKey = false
+			} else {
+				a.completed()
+			}
+		}
+		return
+	}
+
+	if a.literal && (c == ',' || c == '}' || c == ']' || c == ' ' || c == '\t' || c == '\n' || c == '\r') {
+		a.literal = false
+		a.completed()
+	}
+	a.text.WriteByte(c)
+
+	switch c {
+	case '{', '[':
+		closer := byte('}')
+		if c == '[' {
+			closer = ']'
+		}
+		a.stack = append(a.stack, jsonFrame{closer: closer, expectKey: c == '{'})
+		a.completed()
+	case '}', ']':
+		if len(a.stack) > 0 {
+			a.stack = a.stack[:len(a.stack)-1]
+		}
+		a.completed()
+		a.done = len(a.stack) == 0
+	case ',':
+		if top := a.top(); top != nil && top.closer == '}' {
+			top.expectKey = true
+		}
+	case '"':
+		a.inString = true
+	case ':', ' ', '\t', '\n', '\r':
+	default:
+		a.literal = true
+	}
+}
+
+// top returns the innermost open container, or nil
+func (a *StreamingJSONAccumulator) top() *jsonFrame {
+	if len(a.stack) == 0 {
+		return nil
+	}
+	return &a.stack[len(a.stack)-1]
+}
+
+// completed records the current end of text as the end of a complete value
+func (a *StreamingJSONAccumulator) completed() {
+	a.cut = a.text.Len()
+	closers := make([]byte, len(a.stack))
+	for i, frame := range a.stack {
+		closers[len(closers)-1-i] = frame.closer
+	}
+	a.closers = string(closers)
+}
+
+// Done reports whether the closing brace of the object has been seen
+func (a *StreamingJSONAccumulator) Done() bool {
+	return a.done
+}
+
+// Text returns the JSON text received so far, from the opening brace
+func (a *StreamingJSONAccumulator) Text() string {
+	return a.text.String()
+}
+
+// BestEffortValue returns the object made of the largest prefix of the text
+// that ends after a complete value, with the open objects and arrays
+// closed. Fields appear once their value is complete: a string once its
+// closing quote arrives, a number or literal once a delimiter follows it.
+// It returns nil before the opening brace, or if the model's output is not
+// valid JSON up to that point.
+func (a *StreamingJSONAccumulator) BestEffortValue() map[string]interface{} {
+	if !a.started {
+		return nil
+	}
+	var value map[string]interface{}
+	if err := json.Unmarshal([]byte(a.text.String()[:a.cut]+a.closers), &value); err != nil {
+		return nil
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestStreamingJSONAccumulator(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		want   map[string]interface{}
+		done   bool
+	}{
+		{
+			name:   "string completes on its closing quote",
+			chunks: []string{`{"title": "Hel`, `lo", "count": 1`},
+			want:   map[string]interface{}{"title": "Hello"},
+		},
+		{
+			name:   "number completes on a delimiter",
+			chunks: []string{`{"title": "Hello", "count": 12`, `,`},
+			want:   map[string]interface{}{"title": "Hello", "count": float64(12)},
+		},
+		{
+			name:   "keys without values are left out",
+			chunks: []string{`{"a": true, "b"`, `: `},
+			want:   map[string]interface{}{"a": true},
+		},
+		{
+			name:   "nested containers are closed",
+			chunks: []string{`{"items": [{"id": 1}, {"id": 2, "tags": ["x", "y`},
+			want: map[string]interface{}{"items": []interface{}{
+				map[string]interface{}{"id": float64(1)},
+				map[string]interface{}{"id": float64(2), "tags": []interface{}{"x"}},
+			}},
+		},
+		{
+			name:   "split inside an escaped quote",
+			chunks: []string{`{"q": "say \`, `"hi\`, `"", "n": null}`},
+			want:   map[string]interface{}{"q": `say "hi"`, "n": nil},
+			done:   true,
+		},
+		{
+			name:   "split inside a unicode escape",
+			chunks: []string{`{"s": "caf\u00`, `e9 \ud83d`, `\ude00"}`},
+			want:   map[string]interface{}{"s": "café 😀"},
+			done:   true,
+		},
+		{
+			name:   "escaped backslash before the closing quote",
+			chunks: []string{`{"path": "C:\\`, `dir\\`, `"}`},
+			want:   map[string]interface{}{"path": `C:\dir\`},
+			done:   true,
+		},
+		{
+			name:   "leading prose and code fences",
+			chunks: []string{"Sure! Here is the JSON:\n```json\n", `{"ok": true}`, "\n```\nLet me know {if} you need more."},
+			want:   map[string]interface{}{"ok": true},
+			done:   true,
+		},
+		{
+			name:   "nothing before the opening brace",
+			chunks: []string{"Thinking about it"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var acc StreamingJSONAccumulator
+			for _, chunk := range tt.chunks {
+				acc.Add(StreamChunk{Content: chunk})
+			}
+			if got := acc.BestEffortValue(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+			if acc.Done() != tt.done {
+				t.Errorf("Expected Done %v, got %v", tt.done, acc.Done())
+			}
+		})
+	}
+}
+
+// TestStreamingJSONAccumulatorEveryBoundary feeds a document one byte at a
+// time, so that every chunk boundary is exercised, checking that each
+// partial value is a valid prefix of the final one
+func TestStreamingJSONAccumulatorEveryBoundary(t *testing.T) {
+	document := "Here you go: " + `{
+		"quote": "she said \"hi\" \\ \/ \b\f\n\r\t",
+		"unicode": "caf\u00e9 \ud83d\ude00 日本",
+		"numbers": [0, -12, 3.5e-2, 1E+3],
+		"literals": {"t": true, "f": false, "n": null},
+		"nested": {"list": [[], {}, [{"deep": "yes"}]], "empty": ""},
+		"last": 42
+	}` + " trailing prose"
+	var want map[string]interface{}
+	start := len("Here you go: ")
+	end := len(document) - len(" trailing prose")
+	if err := json.Unmarshal([]byte(document[start:end]), &want); err != nil {
+		t.Fatalf("Invalid test document: %v", err)
+	}
+
+	var acc StreamingJSONAccumulator
+	previous := 0
+	for i := 0; i < len(document); i++ {
+		acc.WriteString(document[i : i+1])
+		got := acc.BestEffortValue()
+		if i < start {
+			if got != nil {
+				t.Fatalf("Byte %d: expected no value before the opening brace, got %v", i, got)
+			}
+			continue
+		}
+		if got == nil {
+			t.Fatalf("Byte %d: expected a value after %q", i, acc.Text())
+		}
+		for key, value := range got {
+			switch want[key].(type) {
+			case map[string]interface{}, []interface{}:
+				// Containers fill in gradually
+				continue
+			}
+			if !reflect.DeepEqual(value, want[key]) {
+				t.Fatalf("Byte %d: expected %s to be %v once present, got %v", i, key, want[key], value)
+			}
+		}
+		if len(got) < previous {
+			t.Fatalf("Byte %d: fields disappeared: %v", i, got)
+		}
+		previous = len(got)
+		if acc.Done() != (i >= end-1) {
+			t.Fatalf("Byte %d: unexpected Done %v", i, acc.Done())
+		}
+	}
+	if got := acc.BestEffortValue(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+	if acc.Text() != document[start:end] {
+		t.Errorf("Expected the text from the opening to the closing brace, got %q", acc.Text())
+	}
+}
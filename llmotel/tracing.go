@@ -0,0 +1,396 @@
+// Package llmotel adds OpenTelemetry tracing to llm clients. It lives in its
+// own module so the core package does not depend on OpenTelemetry.
+package llmotel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Attribute keys from the OpenTelemetry gen_ai semantic conventions
+const (
+	attrOperationName  = attribute.Key("gen_ai.operation.name")
+	attrSystem         = attribute.Key("gen_ai.system")
+	attrRequestModel   = attribute.Key("gen_ai.request.model")
+	attrResponseModel  = attribute.Key("gen_ai.response.model")
+	attrTemperature    = attribute.Key("gen_ai.request.temperature")
+	attrMaxTokens      = attribute.Key("gen_ai.request.max_tokens")
+	attrTopP           = attribute.Key("gen_ai.request.top_p")
+	attrFinishReasons  = attribute.Key("gen_ai.response.finish_reasons")
+	attrTotalTokens    = attribute.Key("gen_ai.usage.total_tokens")
+	attrRequestID      = attribute.Key("llm.request_id")
+	attrInputCount     = attribute.Key("llm.embedding.input_count")
+	attrHTTPStatusCode = attribute.Key("http.response.status_code")
+	attrAttempt        = attribute.Key("llm.attempt")
+
+	attrTimeToFirstToken = attribute.Key("gen_ai.response.time_to_first_token")
+)
+
+// eventFirstToken marks the first delta of a stream
+const eventFirstToken = "gen_ai.first_token"
+
+// TracedClient wraps a Client and records a client span per call, with a
+// child span for every attempt the wrapped client makes (retries of empty
+// responses, ContinueOnLength continuations and stream resumes). It
+// implements the optional interfaces of the llm package (Streamer,
+// WaitEstimator, Moderator, Reranker, Speaker, Transcriber, ImageGenerator
+// and Batcher) by forwarding to the wrapped client, whose Capabilities still
+// decide which calls succeed.
+type TracedClient struct {
+	inner  llm.Client
+	tracer trace.Tracer
+}
+
+// NewTracedClient returns a TracedClient that records spans for the calls
+// made on inner.
+func NewTracedClient(inner llm.Client, tracer trace.Tracer) *TracedClient {
+	return &TracedClient{inner: inner, tracer: tracer}
+}
+
+// Generate traces a chat completion
+func (c *TracedClient) Generate(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	return c.traceChat(ctx, request, func(ctx context.Context) (*llm.Response, error) {
+		return c.inner.Generate(ctx, request)
+	})
+}
+
+// TryGenerate traces a chat completion sent only if it would not wait
+func (c *TracedClient) TryGenerate(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	return c.traceChat(ctx, request, func(ctx context.Context) (*llm.Response, error) {
+		return llm.TryGenerate(ctx, c.inner, request)
+	})
+}
+
+// EstimateWait forwards to the wrapped client
+func (c *TracedClient) EstimateWait(ctx context.Context, request llm.Request) llm.WaitEstimate {
+	return llm.EstimateWait(ctx, c.inner, request)
+}
+
+// GenerateWithHistory traces through Generate so history calls get the same span
+func (c *TracedClient) GenerateWithHistory(ctx context.Context, history llm.ChatHistory, userMessage string, systemPrompt string) (*llm.Response, error) {
+	request := llm.BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// GenerateStream traces a streaming chat completion. The span ends when the
+// stream does; the first delta is recorded as an event carrying the time to
+// first token.
+func (c *TracedClient) GenerateStream(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	ctx, span := c.startChatSpan(ctx, request)
+	startTime := time.Now()
+	response, err := llm.GenerateStream(c.withAttemptSpans(ctx), c.inner, request)
+	if err != nil {
+		recordError(span, err)
+		span.End()
+		return nil, err
+	}
+	span.SetAttributes(attrRequestID.String(response.RequestID))
+
+	in := response.Stream
+	out := make(chan llm.StreamChunk, cap(in))
+	relayed := *response
+	relayed.Stream = out
+	go func() {
+		defer close(out)
+		// the inner stream closes after its attempt spans ended
+		defer span.End()
+		first := true
+		forwarding := true
+		for chunk := range in {
+			if first && (chunk.Content != "" || chunk.ReasoningContent != "" || len(chunk.ToolCallDeltas) > 0) {
+				first = false
+				span.AddEvent(eventFirstToken, trace.WithAttributes(attrTimeToFirstToken.Float64(time.Since(startTime).Seconds())))
+			}
+			if chunk.Done {
+				if chunk.Err != nil {
+					recordError(span, chunk.Err)
+				}
+				if chunk.Usage != nil {
+					span.SetAttributes(attrTotalTokens.Int(chunk.Usage.TotalTokens))
+				}
+				if chunk.FinishReason != "" {
+					span.SetAttributes(attrFinishReasons.StringSlice([]string{string(chunk.FinishReason)}))
+				}
+			}
+			if !forwarding {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// keep draining so the inner stream shuts down
+				forwarding = false
+			}
+		}
+	}()
+	return &relayed, nil
+}
+
+// traceChat records a chat span around call, which runs with a context that
+// opens a child span per attempt
+func (c *TracedClient) traceChat(ctx context.Context, request llm.Request, call func(ctx context.Context) (*llm.Response, error)) (*llm.Response, error) {
+	ctx, span := c.startChatSpan(ctx, request)
+	defer span.End()
+
+	response, err := call(c.withAttemptSpans(ctx))
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+	recordResponse(span, response)
+	return response, nil
+}
+
+// startChatSpan starts the span of a chat call with the request parameters
+func (c *TracedClient) startChatSpan(ctx context.Context, request llm.Request) (context.Context, trace.Span) {
+	model := c.inner.GetConfig().DefaultModel
+	if request.Model != nil {
+		model = *request.Model
+	}
+
+	ctx, span := c.startSpan(ctx, "chat", model)
+	if request.Temperature != nil {
+		span.SetAttributes(attrTemperature.Float64(*request.Temperature))
+	}
+	if request.MaxTokens != nil {
+		span.SetAttributes(attrMaxTokens.Int(*request.MaxTokens))
+	}
+	if request.TopP != nil {
+		span.SetAttributes(attrTopP.Float64(*request.TopP))
+	}
+	return ctx, span
+}
+
+// recordResponse adds the usage, request ID and finish reason of a chat
+// response to span
+func recordResponse(span trace.Span, response *llm.Response) {
+	span.SetAttributes(
+		attrTotalTokens.Int(response.TokensUsed),
+		attrRequestID.String(response.RequestID),
+	)
+	if response.FinishReason != "" {
+		span.SetAttributes(attrFinishReasons.StringSlice([]string{string(response.FinishReason)}))
+	}
+}
+
+// attemptSpans records a child span of a chat span for every attempt of
+// the call, through hooks attached with llm.WithHooks
+type attemptSpans struct {
+	tracer trace.Tracer
+	// ctx is that of the chat span
+	ctx context.Context
+
+	mu      sync.Mutex
+	current trace.Span
+}
+
+// withAttemptSpans returns ctx with hooks that record the attempts of the
+// chat call whose span ctx carries
+func (c *TracedClient) withAttemptSpans(ctx context.Context) context.Context {
+	spans := &attemptSpans{tracer: c.tracer, ctx: ctx}
+	return llm.WithHooks(ctx, spans.start, spans.end)
+}
+
+// start opens the span of an attempt
+func (s *attemptSpans) start(ctx context.Context, request *llm.Request) error {
+	attempt, _ := llm.AttemptFromContext(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current != nil {
+		// a wrapper sent the call to another client
+		s.current.End()
+	}
+	_, s.current = s.tracer.Start(s.ctx, "attempt", trace.WithAttributes(attrAttempt.Int(attempt)))
+	return nil
+}
+
+// end closes the span of an attempt with its outcome
+func (s *attemptSpans) end(ctx context.Context, request *llm.Request, response *llm.Response, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current == nil {
+		// the call failed before the attempt started
+		return
+	}
+	if err != nil {
+		recordError(s.current, err)
+	} else if response != nil {
+		recordResponse(s.current, response)
+	}
+	s.current.End()
+	s.current = nil
+}
+
+// CreateEmbedding traces an embedding call
+func (c *TracedClient) CreateEmbedding(ctx context.Context, request llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	model := ""
+	if request.Model != nil {
+		model = *request.Model
+	}
+
+	ctx, span := c.startSpan(ctx, "embeddings", model)
+	defer span.End()
+	span.SetAttributes(attrInputCount.Int(len(request.Input)))
+
+	response, err := c.inner.CreateEmbedding(ctx, request)
+	if err != nil {
+		recordError(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(
+		attrResponseModel.String(response.Model),
+		attrTotalTokens.Int(response.TokensUsed),
+		attrRequestID.String(response.RequestID),
+	)
+	return response, nil
+}
+
+// Moderate traces a moderation call
+func (c *TracedClient) Moderate(ctx context.Context, request llm.ModerationRequest) (*llm.ModerationResponse, error) {
+	return traceCall(ctx, c, "moderation", request.Model, func(ctx context.Context) (*llm.ModerationResponse, error) {
+		return llm.Moderate(ctx, c.inner, request)
+	})
+}
+
+// Rerank traces a rerank call
+func (c *TracedClient) Rerank(ctx context.Context, request llm.RerankRequest) (*llm.RerankResponse, error) {
+	return traceCall(ctx, c, "rerank", request.Model, func(ctx context.Context) (*llm.RerankResponse, error) {
+		return llm.Rerank(ctx, c.inner, request)
+	})
+}
+
+// Speak traces a text to speech call up to the start of the audio stream
+func (c *TracedClient) Speak(ctx context.Context, request llm.SpeechRequest) (io.ReadCloser, error) {
+	return traceCall(ctx, c, "speech", request.Model, func(ctx context.Context) (io.ReadCloser, error) {
+		return llm.Speak(ctx, c.inner, request)
+	})
+}
+
+// Transcribe traces a speech to text call
+func (c *TracedClient) Transcribe(ctx context.Context, request llm.TranscriptionRequest) (*llm.TranscriptionResponse, error) {
+	return traceCall(ctx, c, "transcription", request.Model, func(ctx context.Context) (*llm.TranscriptionResponse, error) {
+		return llm.Transcribe(ctx, c.inner, request)
+	})
+}
+
+// GenerateImage traces an image generation call
+func (c *TracedClient) GenerateImage(ctx context.Context, request llm.ImageRequest) (*llm.ImageResponse, error) {
+	return traceCall(ctx, c, "image_generation", request.Model, func(ctx context.Context) (*llm.ImageResponse, error) {
+		return llm.GenerateImage(ctx, c.inner, request)
+	})
+}
+
+// traceCall records a span named after operation and model around call
+func traceCall[T any](ctx context.Context, c *TracedClient, operation string, model *string, call func(ctx context.Context) (T, error)) (T, error) {
+	name := ""
+	if model != nil {
+		name = *model
+	}
+	ctx, span := c.startSpan(ctx, operation, name)
+	defer span.End()
+
+	result, err := call(ctx)
+	if err != nil {
+		recordError(span, err)
+	}
+	return result, err
+}
+
+// CreateBatch forwards to the wrapped client
+func (c *TracedClient) CreateBatch(ctx context.Context, requests []llm.BatchRequest) (*llm.BatchJob, error) {
+	return llm.CreateBatch(ctx, c.inner, requests)
+}
+
+// GetBatch forwards to the wrapped client
+func (c *TracedClient) GetBatch(ctx context.Context, id string) (*llm.BatchJob, error) {
+	return llm.GetBatch(ctx, c.inner, id)
+}
+
+// CancelBatch forwards to the wrapped client
+func (c *TracedClient) CancelBatch(ctx context.Context, id string) (*llm.BatchJob, error) {
+	return llm.CancelBatch(ctx, c.inner, id)
+}
+
+// BatchResults forwards to the wrapped client
+func (c *TracedClient) BatchResults(ctx context.Context, job *llm.BatchJob) (map[string]llm.BatchResult, error) {
+	return llm.BatchResults(ctx, c.inner, job)
+}
+
+// Close closes the wrapped client
+func (c *TracedClient) Close() error {
+	return c.inner.Close()
+}
+
+// GetConfig returns the wrapped client's configuration
+func (c *TracedClient) GetConfig() llm.Config {
+	return c.inner.GetConfig()
+}
+
+// GetConfigWithSecrets returns the wrapped client's configuration with secrets
+func (c *TracedClient) GetConfigWithSecrets() llm.Config {
+	return c.inner.GetConfigWithSecrets()
+}
+
+// CountTokens forwards to the wrapped client
+func (c *TracedClient) CountTokens(request llm.Request) int {
+	return c.inner.CountTokens(request)
+}
+
+// ListModels forwards to the wrapped client
+func (c *TracedClient) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	return c.inner.ListModels(ctx)
+}
+
+// Capabilities reports the wrapped client's capabilities
+func (c *TracedClient) Capabilities() llm.Capabilities {
+	return c.inner.Capabilities()
+}
+
+// Ping forwards to the wrapped client
+func (c *TracedClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+// Warmup forwards to the wrapped client
+func (c *TracedClient) Warmup(ctx context.Context) error {
+	return c.inner.Warmup(ctx)
+}
+
+// startSpan starts a client span named "<operation> <model>"
+func (c *TracedClient) startSpan(ctx context.Context, operation, model string) (context.Context, trace.Span) {
+	name := operation
+	if model != "" {
+		name += " " + model
+	}
+	ctx, span := c.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+	span.SetAttributes(
+		attrOperationName.String(operation),
+		attrSystem.String(string(c.inner.GetConfig().Provider)),
+	)
+	if model != "" {
+		span.SetAttributes(attrRequestModel.String(model))
+	}
+	return ctx, span
+}
+
+// recordError marks the span as failed, adding the HTTP status for provider errors
+func recordError(span trace.Span, err error) {
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		span.SetAttributes(attrHTTPStatusCode.Int(apiErr.StatusCode))
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
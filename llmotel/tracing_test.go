@@ -0,0 +1,248 @@
+package llmotel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// stubClient returns canned results without network access
+type stubClient struct {
+	response *llm.Response
+	err      error
+}
+
+func (s *stubClient) Generate(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	return s.response, s.err
+}
+
+func (s *stubClient) GenerateWithHistory(ctx context.Context, history llm.ChatHistory, userMessage string, systemPrompt string) (*llm.Response, error) {
+	return s.response, s.err
+}
+
+func (s *stubClient) CreateEmbedding(ctx context.Context, request llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &llm.EmbeddingResponse{Embeddings: [][]float64{{0.1}}, Model: "text-embedding-3-small", TokensUsed: 4}, nil
+}
+
+func (s *stubClient) Close() error { return nil }
+
+func (s *stubClient) GetConfig() llm.Config {
+	return llm.Config{Provider: llm.ProviderOpenAI, DefaultModel: "gpt-4o"}
+}
+
+func (s *stubClient) GetConfigWithSecrets() llm.Config { return s.GetConfig() }
+
+func (s *stubClient) CountTokens(request llm.Request) int { return 0 }
+
+func (s *stubClient) ListModels(ctx context.Context) ([]llm.ModelInfo, error) { return nil, nil }
+
+func (s *stubClient) Capabilities() llm.Capabilities { return llm.Capabilities{Chat: true} }
+
+func (s *stubClient) Ping(ctx context.Context) error { return nil }
+
+func (s *stubClient) Warmup(ctx context.Context) error { return nil }
+
+func newRecorder() (*tracetest.SpanRecorder, *sdktrace.TracerProvider) {
+	recorder := tracetest.NewSpanRecorder()
+	return recorder, sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	result := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		result[kv.Key] = kv.Value
+	}
+	return result
+}
+
+func TestTracedGenerate(t *testing.T) {
+	recorder, provider := newRecorder()
+	client := NewTracedClient(&stubClient{response: &llm.Response{
+		Content:      "hi",
+		TokensUsed:   12,
+		FinishReason: "stop",
+		RequestID:    "req-1",
+	}}, provider.Tracer("test"))
+
+	request := llm.BuildSimpleRequest("Hello")
+	request.SetTemperature(0.2)
+	if _, err := client.Generate(context.Background(), request); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	if spans[0].Name() != "chat gpt-4o" {
+		t.Errorf("Unexpected span name %q", spans[0].Name())
+	}
+
+	attrs := attributes(spans[0])
+	if attrs[attrSystem].AsString() != "openai" || attrs[attrRequestModel].AsString() != "gpt-4o" {
+		t.Errorf("Missing provider/model attributes: %v", attrs)
+	}
+	if attrs[attrTotalTokens].AsInt64() != 12 || attrs[attrTemperature].AsFloat64() != 0.2 {
+		t.Errorf("Missing usage/parameter attributes: %v", attrs)
+	}
+	if reasons := attrs[attrFinishReasons].AsStringSlice(); len(reasons) != 1 || reasons[0] != "stop" {
+		t.Errorf("Unexpected finish reasons %v", reasons)
+	}
+}
+
+func TestTracedErrors(t *testing.T) {
+	recorder, provider := newRecorder()
+	client := NewTracedClient(&stubClient{err: &llm.APIError{StatusCode: 429}}, provider.Tracer("test"))
+
+	if _, err := client.CreateEmbedding(context.Background(), llm.EmbeddingRequest{Input: []string{"a"}}); err == nil {
+		t.Fatal("Expected error")
+	}
+	if _, err := llm.GenerateSimple(context.Background(), client, "Hello"); !errors.As(err, new(*llm.APIError)) {
+		t.Fatalf("Expected APIError, got %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if span.Status().Code != codes.Error {
+			t.Errorf("Span %q should have error status", span.Name())
+		}
+		if attributes(span)[attrHTTPStatusCode].AsInt64() != 429 {
+			t.Errorf("Span %q missing HTTP status", span.Name())
+		}
+	}
+}
+
+func TestTracedAttempts(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		content := ""
+		if calls > 1 {
+			content = "hi"
+		}
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6}}`, content)
+	}))
+	defer server.Close()
+	inner, err := llm.NewClient(llm.Config{Provider: llm.ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, EmptyResponseRetries: 1})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	recorder, provider := newRecorder()
+	client := NewTracedClient(inner, provider.Tracer("test"))
+	if _, err := llm.GenerateSimple(context.Background(), client, "Hello"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("Expected a chat span and 2 attempt spans, got %d", len(spans))
+	}
+	chat := spans[2]
+	for i, span := range spans[:2] {
+		if span.Name() != "attempt" || attributes(span)[attrAttempt].AsInt64() != int64(i+1) {
+			t.Errorf("Unexpected attempt span %q %v", span.Name(), attributes(span))
+		}
+		if span.Parent().SpanID() != chat.SpanContext().SpanID() {
+			t.Errorf("Attempt %d is not a child of the chat span", i+1)
+		}
+	}
+	if spans[0].Status().Code != codes.Error {
+		t.Error("Expected the empty first attempt to be marked failed")
+	}
+	if spans[1].Status().Code == codes.Error || chat.Status().Code == codes.Error {
+		t.Error("Expected the retry and the call to succeed")
+	}
+}
+
+func TestTracedStream(t *testing.T) {
+	fixture, err := os.ReadFile("../testdata/streams/openai_chat.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+	inner, err := llm.NewClient(llm.Config{Provider: llm.ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DefaultModel: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	recorder, provider := newRecorder()
+	client := NewTracedClient(inner, provider.Tracer("test"))
+	response, err := llm.GenerateWithCallback(context.Background(), client, llm.BuildSimpleRequest("Hello"), func(llm.StreamChunk) error { return nil })
+	if err != nil {
+		t.Fatalf("GenerateWithCallback failed: %v", err)
+	}
+	if response.Content != "The capital is Paris." {
+		t.Errorf("Unexpected content %q", response.Content)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected an attempt span and a chat span, got %d", len(spans))
+	}
+	chat := spans[1]
+	if chat.Name() != "chat gpt-4o-mini" || chat.Status().Code == codes.Error {
+		t.Errorf("Unexpected chat span %q %v", chat.Name(), chat.Status())
+	}
+	attrs := attributes(chat)
+	if attrs[attrTotalTokens].AsInt64() != 30 {
+		t.Errorf("Missing stream usage: %v", attrs)
+	}
+	if reasons := attrs[attrFinishReasons].AsStringSlice(); len(reasons) != 1 || reasons[0] != "stop" {
+		t.Errorf("Unexpected finish reasons %v", reasons)
+	}
+	events := chat.Events()
+	if len(events) != 1 || events[0].Name != eventFirstToken || len(events[0].Attributes) != 1 || events[0].Attributes[0].Key != attrTimeToFirstToken {
+		t.Errorf("Expected a time to first token event, got %+v", events)
+	}
+}
+
+func TestTracedOptionalInterfaces(t *testing.T) {
+	var client llm.Client = NewTracedClient(&stubClient{}, sdktrace.NewTracerProvider().Tracer("test"))
+	for name, ok := range map[string]bool{
+		"Streamer":       isA[llm.Streamer](client),
+		"WaitEstimator":  isA[llm.WaitEstimator](client),
+		"Moderator":      isA[llm.Moderator](client),
+		"Reranker":       isA[llm.Reranker](client),
+		"Speaker":        isA[llm.Speaker](client),
+		"Transcriber":    isA[llm.Transcriber](client),
+		"ImageGenerator": isA[llm.ImageGenerator](client),
+		"Batcher":        isA[llm.Batcher](client),
+	} {
+		if !ok {
+			t.Errorf("TracedClient does not implement %s", name)
+		}
+	}
+
+	// the wrapped client still decides what is supported
+	_, err := llm.Moderate(context.Background(), client, llm.ModerationRequest{Inputs: []string{"hi"}})
+	if !errors.As(err, new(*llm.CapabilityError)) {
+		t.Errorf("Expected a CapabilityError, got %v", err)
+	}
+}
+
+func isA[T any](client llm.Client) bool {
+	_, ok := client.(T)
+	return ok
+}
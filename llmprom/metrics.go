@@ -0,0 +1,86 @@
+// Package llmprom exports llm client metrics to Prometheus. It lives in its
+// own module so the core package does not depend on the Prometheus client.
+package llmprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+// Recorder implements llm.MetricsRecorder with Prometheus collectors
+type Recorder struct {
+	requests *prometheus.CounterVec
+	latency  *prometheus.HistogramVec
+	phases   *prometheus.HistogramVec
+	tokens   *prometheus.CounterVec
+}
+
+// NewRecorder creates a Recorder and registers its collectors with reg
+// (use prometheus.DefaultRegisterer for the global registry).
+func NewRecorder(reg prometheus.Registerer) (*Recorder, error) {
+	r := &Recorder{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "llm_requests_total",
+			Help: "LLM calls by provider, model, operation and status.",
+		}, []string{"provider", "model", "operation", "status"}),
+		latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llm_request_duration_seconds",
+			Help:    "LLM call latency in seconds.",
+			Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}, []string{"provider", "model", "operation"}),
+		phases: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "llm_request_phase_duration_seconds",
+			Help:    "LLM call latency by phase (dns, connect, tls, wait, first_token, generation) in seconds, when llm.Config.CaptureTiming is on.",
+			Buckets: []float64{0.005, 0.025, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+		}, []string{"provider", "model", "operation", "phase"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "llm_tokens_total",
+			Help: "Tokens consumed by provider, model and type (prompt or completion).",
+		}, []string{"provider", "model", "type"}),
+	}
+
+	for _, collector := range []prometheus.Collector{r.requests, r.latency, r.phases, r.tokens} {
+		if err := reg.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// ObserveRequest records one call
+func (r *Recorder) ObserveRequest(m llm.RequestMetrics) {
+	provider := string(m.Provider)
+	r.requests.WithLabelValues(provider, m.Model, m.Operation, m.Status).Inc()
+	r.latency.WithLabelValues(provider, m.Model, m.Operation).Observe(m.Latency.Seconds())
+
+	if m.Timing != nil {
+		r.observePhases(provider, m.Model, m.Operation, m.Timing)
+	}
+	if m.Usage.PromptTokens > 0 {
+		r.tokens.WithLabelValues(provider, m.Model, "prompt").Add(float64(m.Usage.PromptTokens))
+	}
+	if m.Usage.CompletionTokens > 0 {
+		r.tokens.WithLabelValues(provider, m.Model, "completion").Add(float64(m.Usage.CompletionTokens))
+	}
+}
+
+// observePhases records the latency breakdown of a call. Connection phases
+// are only recorded for new connections and first_token only for streams,
+// so their percentiles are not skewed by zeros.
+func (r *Recorder) observePhases(provider, model, operation string, timing *llm.Timing) {
+	observe := func(phase string, d time.Duration) {
+		r.phases.WithLabelValues(provider, model, operation, phase).Observe(d.Seconds())
+	}
+	if !timing.ConnectionReused {
+		observe("dns", timing.DNS)
+		observe("connect", timing.Connect)
+		observe("tls", timing.TLS)
+	}
+	observe("wait", timing.Wait)
+	if timing.TimeToFirstToken > 0 {
+		observe("first_token", timing.TimeToFirstToken)
+	}
+	observe("generation", timing.Generation)
+}
@@ -0,0 +1,57 @@
+package llmprom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+func TestRecorder(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	recorder, err := NewRecorder(reg)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+
+	var _ llm.MetricsRecorder = recorder
+
+	recorder.ObserveRequest(llm.RequestMetrics{
+		Provider:  llm.ProviderOpenAI,
+		Model:     "gpt-4o",
+		Operation: llm.OperationChat,
+		Status:    llm.StatusOK,
+		Latency:   300 * time.Millisecond,
+		Usage:     llm.Usage{PromptTokens: 10, CompletionTokens: 4, TotalTokens: 14},
+		Timing:    &llm.Timing{ConnectionReused: true, Wait: 200 * time.Millisecond, TimeToFirstToken: 220 * time.Millisecond, Generation: 80 * time.Millisecond},
+	})
+	recorder.ObserveRequest(llm.RequestMetrics{
+		Provider:  llm.ProviderOpenAI,
+		Model:     "gpt-4o",
+		Operation: llm.OperationChat,
+		Status:    llm.StatusRateLimited,
+		Latency:   50 * time.Millisecond,
+	})
+
+	if got := testutil.ToFloat64(recorder.requests.WithLabelValues("openai", "gpt-4o", "chat", "ok")); got != 1 {
+		t.Errorf("Expected 1 successful request, got %v", got)
+	}
+	if got := testutil.ToFloat64(recorder.requests.WithLabelValues("openai", "gpt-4o", "chat", "rate_limited")); got != 1 {
+		t.Errorf("Expected 1 rate-limited request, got %v", got)
+	}
+	if got := testutil.ToFloat64(recorder.tokens.WithLabelValues("openai", "gpt-4o", "prompt")); got != 10 {
+		t.Errorf("Expected 10 prompt tokens, got %v", got)
+	}
+	if got := testutil.CollectAndCount(recorder.latency); got != 1 {
+		t.Errorf("Expected 1 latency series, got %d", got)
+	}
+
+	if got := testutil.CollectAndCount(recorder.phases); got != 3 {
+		t.Errorf("Expected wait, first_token and generation phase series, got %d", got)
+	}
+	if _, err := NewRecorder(reg); err == nil {
+		t.Error("Expected duplicate registration to fail")
+	}
+}
@@ -0,0 +1,376 @@
+package llmtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fault is an error condition the FakeServer can inject
+type Fault int
+
+const (
+	// FaultRateLimit answers 429 with a Retry-After header
+	FaultRateLimit Fault = iota + 1
+	// FaultServerError answers 500
+	FaultServerError
+	// FaultMalformedJSON answers 200 with a truncated JSON body
+	FaultMalformedJSON
+)
+
+// defaultFakeDimensions is the length of FakeServer embeddings unless changed
+const defaultFakeDimensions = 16
+
+// FakeRequest is a request received by a FakeServer
+type FakeRequest struct {
+	Path   string
+	Header http.Header
+	Model  string
+	// Messages holds the chat messages of /chat/completions requests
+	Messages []FakeMessage
+	// Input holds the texts of /embeddings requests
+	Input  []string
+	Stream bool
+	Body   []byte
+}
+
+// FakeMessage is a chat message received by a FakeServer
+type FakeMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// FakeServer is a local server speaking the OpenAI chat completions (plain
+// and SSE) and embeddings protocol, for running examples and load tests
+// offline with ProviderOpenAI. Replies are canned or echoed, embeddings are
+// derived from a hash of the input so they are stable across runs.
+type FakeServer struct {
+	// URL is the base URL to use as Config.BaseURL
+	URL string
+
+	server     *httptest.Server
+	mu         sync.Mutex
+	replies    []string
+	reply      string
+	echo       bool
+	latency    time.Duration
+	retryAfter time.Duration
+	dimensions int
+	faults     []Fault
+	requests   []FakeRequest
+}
+
+// NewFakeServer starts a FakeServer answering "ok" to every chat request.
+// Call Close when done.
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{reply: "ok", dimensions: defaultFakeDimensions, retryAfter: time.Second}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	s.URL = s.server.URL + "/v1"
+	return s
+}
+
+// Close shuts the server down
+func (s *FakeServer) Close() {
+	s.server.Close()
+}
+
+// SetReply sets the completion returned when no queued reply is left
+func (s *FakeServer) SetReply(content string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reply = content
+}
+
+// QueueReplies queues completions returned by the next chat requests, in order
+func (s *FakeServer) QueueReplies(contents ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, contents...)
+}
+
+// SetEcho makes chat requests answer with the last user message
+func (s *FakeServer) SetEcho(echo bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.echo = echo
+}
+
+// SetLatency delays every response by d
+func (s *FakeServer) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetDimensions sets the length of returned embeddings
+func (s *FakeServer) SetDimensions(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dimensions = n
+}
+
+// SetRetryAfter sets the Retry-After value sent with FaultRateLimit
+func (s *FakeServer) SetRetryAfter(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retryAfter = d
+}
+
+// InjectFault makes the next times requests fail with fault. Faults are
+// consumed in the order they were injected.
+func (s *FakeServer) InjectFault(fault Fault, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < times; i++ {
+		s.faults = append(s.faults, fault)
+	}
+}
+
+// Requests returns the requests received so far
+func (s *FakeServer) Requests() []FakeRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]FakeRequest(nil), s.requests...)
+}
+
+// RequestCount returns the number of requests received so far
+func (s *FakeServer) RequestCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.requests)
+}
+
+// LastRequest returns the most recent request, or false if none was received
+func (s *FakeServer) LastRequest() (FakeRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		return FakeRequest{}, false
+	}
+	return s.requests[len(s.requests)-1], true
+}
+
+// handle serves a single request
+func (s *FakeServer) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := decodedBody(r.Body, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		http.Error(w, `{"error":{"message":"unreadable body"}}`, http.StatusBadRequest)
+		return
+	}
+
+	var payload struct {
+		Model    string          `json:"model"`
+		Messages []FakeMessage   `json:"messages"`
+		Input    json.RawMessage `json:"input"`
+		Stream   bool            `json:"stream"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, `{"error":{"message":"invalid JSON body"}}`, http.StatusBadRequest)
+		return
+	}
+	received := FakeRequest{
+		Path:     r.URL.Path,
+		Header:   r.Header.Clone(),
+		Model:    payload.Model,
+		Messages: payload.Messages,
+		Input:    embeddingInput(payload.Input),
+		Stream:   payload.Stream,
+		Body:     body,
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, received)
+	var fault Fault
+	if len(s.faults) > 0 {
+		fault, s.faults = s.faults[0], s.faults[1:]
+	}
+	latency, retryAfter, dimensions := s.latency, s.retryAfter, s.dimensions
+	content := s.nextReply(payload.Messages)
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch fault {
+	case FaultRateLimit:
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retryAfter.Seconds()))))
+		w.WriteHeader(http.StatusTooManyRequests)
+		io.WriteString(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+		return
+	case FaultServerError:
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, `{"error":{"message":"The server had an error while processing your request.","type":"server_error"}}`)
+		return
+	case FaultMalformedJSON:
+		io.WriteString(w, `{"choices":[{"message":{"role":"assist`)
+		return
+	}
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+		if payload.Stream {
+			s.writeStream(w, payload.Model, content)
+			return
+		}
+		writeJSON(w, chatCompletion(payload.Model, content, payload.Messages))
+	case strings.HasSuffix(r.URL.Path, "/embeddings"):
+		writeJSON(w, embeddingList(payload.Model, received.Input, dimensions))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":{"message":"unknown endpoint"}}`)
+	}
+}
+
+// nextReply picks the completion for a chat request; s.mu must be held
+func (s *FakeServer) nextReply(messages []FakeMessage) string {
+	if s.echo {
+		for i := len(messages) - 1; i >= 0; i-- {
+			if messages[i].Role == "user" {
+				return messages[i].Content
+			}
+		}
+		return ""
+	}
+	if len(s.replies) > 0 {
+		reply := s.replies[0]
+		s.replies = s.replies[1:]
+		return reply
+	}
+	return s.reply
+}
+
+// writeStream sends content as SSE chat.completion.chunk frames, one per word
+func (s *FakeServer) writeStream(w http.ResponseWriter, model, content string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+
+	send := func(delta map[string]interface{}, finishReason interface{}) {
+		chunk := map[string]interface{}{
+			"id":      "chatcmpl-fake",
+			"object":  "chat.completion.chunk",
+			"created": 0,
+			"model":   model,
+			"choices": []map[string]interface{}{{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+		data, _ := json.Marshal(chunk)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	send(map[string]interface{}{"role": "assistant", "content": ""}, nil)
+	for i, word := range strings.SplitAfter(content, " ") {
+		if word == "" && i > 0 {
+			continue
+		}
+		send(map[string]interface{}{"content": word}, nil)
+	}
+	send(map[string]interface{}{}, "stop")
+	io.WriteString(w, "data: [DONE]\n\n")
+}
+
+// chatCompletion builds a chat.completion response
+func chatCompletion(model, content string, messages []FakeMessage) map[string]interface{} {
+	promptTokens := 0
+	for _, msg := range messages {
+		promptTokens += fakeTokens(msg.Content)
+	}
+	completionTokens := fakeTokens(content)
+	return map[string]interface{}{
+		"id":      "chatcmpl-fake",
+		"object":  "chat.completion",
+		"created": 0,
+		"model":   model,
+		"choices": []map[string]interface{}{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+		"usage": map[string]int{
+			"prompt_tokens":     promptTokens,
+			"completion_tokens": completionTokens,
+			"total_tokens":      promptTokens + completionTokens,
+		},
+	}
+}
+
+// embeddingList builds an embeddings response with one vector per input
+func embeddingList(model string, input []string, dimensions int) map[string]interface{} {
+	data := make([]map[string]interface{}, len(input))
+	tokens := 0
+	for i, text := range input {
+		data[i] = map[string]interface{}{"object": "embedding", "index": i, "embedding": FakeEmbedding(text, dimensions)}
+		tokens += fakeTokens(text)
+	}
+	if model == "" {
+		model = "fake-embedding"
+	}
+	return map[string]interface{}{
+		"object": "list",
+		"data":   data,
+		"model":  model,
+		"usage":  map[string]int{"prompt_tokens": tokens, "total_tokens": tokens},
+	}
+}
+
+// FakeEmbedding returns the unit-length pseudo-embedding a FakeServer
+// produces for text. Equal texts always map to equal vectors.
+func FakeEmbedding(text string, dimensions int) []float64 {
+	vector := make([]float64, dimensions)
+	var norm float64
+	for i := range vector {
+		h := fnv.New64a()
+		h.Write([]byte(text))
+		binary.Write(h, binary.LittleEndian, uint32(i))
+		vector[i] = float64(h.Sum64())/math.MaxUint64*2 - 1
+		norm += vector[i] * vector[i]
+	}
+	norm = math.Sqrt(norm)
+	if norm > 0 {
+		for i := range vector {
+			vector[i] /= norm
+		}
+	}
+	return vector
+}
+
+// embeddingInput decodes an embeddings "input" that is a string or a list of strings
+func embeddingInput(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}
+	}
+	return nil
+}
+
+// fakeTokens approximates a token count as one token per four bytes
+func fakeTokens(s string) int {
+	return (len(s) + 3) / 4
+}
+
+// writeJSON writes v as a JSON response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,132 @@
+package llmtest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+func newFakeClient(t *testing.T, server *FakeServer) llm.Client {
+	t.Helper()
+	client, err := llm.NewClient(llm.Config{Provider: llm.ProviderOpenAI, APIKey: "sk-fake", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestFakeServerChat(t *testing.T) {
+	server := NewFakeServer()
+	defer server.Close()
+	client := newFakeClient(t, server)
+	ctx := context.Background()
+
+	server.QueueReplies("first")
+	response, err := llm.GenerateSimple(ctx, client, "Hello")
+	if err != nil || response.Content != "first" {
+		t.Fatalf("Expected queued reply, got %+v, %v", response, err)
+	}
+	if response.Usage.TotalTokens == 0 {
+		t.Error("Expected usage in fake response")
+	}
+
+	server.SetEcho(true)
+	response, err = llm.GenerateSimple(ctx, client, "echo me")
+	if err != nil || response.Content != "echo me" {
+		t.Fatalf("Expected echo, got %+v, %v", response, err)
+	}
+
+	last, ok := server.LastRequest()
+	if !ok || last.Path != "/v1/chat/completions" || last.Messages[0].Content != "echo me" {
+		t.Errorf("Unexpected recorded request %+v", last)
+	}
+	if last.Header.Get("Authorization") != "Bearer sk-fake" {
+		t.Errorf("Expected authorization header, got %q", last.Header.Get("Authorization"))
+	}
+	if server.RequestCount() != 2 {
+		t.Errorf("Expected 2 requests, got %d", server.RequestCount())
+	}
+}
+
+func TestFakeServerFaults(t *testing.T) {
+	server := NewFakeServer()
+	defer server.Close()
+	client := newFakeClient(t, server)
+	ctx := context.Background()
+
+	server.InjectFault(FaultRateLimit, 1)
+	server.InjectFault(FaultServerError, 1)
+	server.InjectFault(FaultMalformedJSON, 1)
+
+	var apiErr *llm.APIError
+	if _, err := llm.GenerateSimple(ctx, client, "Hello"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected 429, got %v", err)
+	}
+	if _, err := llm.GenerateSimple(ctx, client, "Hello"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected 500, got %v", err)
+	}
+	if _, err := llm.GenerateSimple(ctx, client, "Hello"); err == nil || !strings.Contains(err.Error(), "unmarshal") {
+		t.Errorf("Expected unmarshal error, got %v", err)
+	}
+	if _, err := llm.GenerateSimple(ctx, client, "Hello"); err != nil {
+		t.Errorf("Expected faults to be consumed, got %v", err)
+	}
+}
+
+func TestFakeServerEmbeddings(t *testing.T) {
+	server := NewFakeServer()
+	defer server.Close()
+	server.SetDimensions(8)
+	client := newFakeClient(t, server)
+
+	response, err := client.CreateEmbedding(context.Background(), llm.EmbeddingRequest{Input: []string{"a", "b", "a"}})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if len(response.Embeddings) != 3 || len(response.Embeddings[0]) != 8 {
+		t.Fatalf("Unexpected embedding shape: %d vectors", len(response.Embeddings))
+	}
+	if !reflect.DeepEqual(response.Embeddings[0], response.Embeddings[2]) {
+		t.Error("Expected equal inputs to produce equal embeddings")
+	}
+	if reflect.DeepEqual(response.Embeddings[0], response.Embeddings[1]) {
+		t.Error("Expected different inputs to produce different embeddings")
+	}
+	if !reflect.DeepEqual(response.Embeddings[1], FakeEmbedding("b", 8)) {
+		t.Error("Expected embeddings to match FakeEmbedding")
+	}
+}
+
+func TestFakeServerStream(t *testing.T) {
+	server := NewFakeServer()
+	defer server.Close()
+	server.SetReply("hello fake world")
+
+	resp, err := http.Post(server.URL+"/chat/completions", "application/json",
+		bytes.NewReader([]byte(`{"model":"gpt-4o-mini","stream":true,"messages":[{"role":"user","content":"hi"}]}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Errorf("Expected SSE content type, got %q", resp.Header.Get("Content-Type"))
+	}
+	var frames []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			frames = append(frames, data)
+		}
+	}
+	if len(frames) != 6 || frames[len(frames)-1] != "[DONE]" {
+		t.Errorf("Unexpected frames: %v", frames)
+	}
+}
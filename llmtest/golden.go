@@ -0,0 +1,217 @@
+package llmtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+// Operations recorded in golden files
+const (
+	goldenGenerate  = "generate"
+	goldenEmbedding = "embedding"
+)
+
+// GoldenFile is the on-disk form of a recorded call
+type GoldenFile struct {
+	Provider  llm.Provider    `json:"provider"`
+	Operation string          `json:"operation"`
+	Request   json.RawMessage `json:"request"`
+	Response  json.RawMessage `json:"response"`
+}
+
+// GoldenClient is an llm.Client that records the responses of the client it
+// wraps to golden files and replays them. Unlike Recorder it works at the
+// Client interface, so it also covers wrapper clients and any provider
+// without fixture plumbing. Only Generate, GenerateWithHistory and
+// CreateEmbedding are recorded; other calls go to the wrapped client.
+type GoldenClient struct {
+	llm.Client
+	dir  string
+	mode Mode
+}
+
+// goldenChatKey is the part of a chat request that identifies its golden
+// file, including the fields that are not serialized with the request
+type goldenChatKey struct {
+	Provider         llm.Provider `json:"provider"`
+	DefaultModel     string       `json:"default_model,omitempty"`
+	Request          llm.Request  `json:"request"`
+	ContinueOnLength int          `json:"continue_on_length,omitempty"`
+}
+
+// goldenEmbeddingKey is the part of an embedding request that identifies
+// its golden file
+type goldenEmbeddingKey struct {
+	Provider          llm.Provider          `json:"provider"`
+	DefaultModel      string                `json:"default_model,omitempty"`
+	Request           llm.EmbeddingRequest  `json:"request"`
+	Task              llm.EmbeddingTask     `json:"task,omitempty"`
+	Title             string                `json:"title,omitempty"`
+	Normalize         bool                  `json:"normalize,omitempty"`
+	AsFloat32         bool                  `json:"as_float32,omitempty"`
+	LateChunking      bool                  `json:"late_chunking,omitempty"`
+	LongInputStrategy llm.LongInputStrategy `json:"long_input_strategy,omitempty"`
+	MaxInputTokens    int                   `json:"max_input_tokens,omitempty"`
+}
+
+// NewGoldenClient wraps inner so that its responses are recorded to dir
+// (ModeRecord) or replayed from it (ModeReplay). In replay mode inner is
+// never called, so it can be created with a placeholder API key. Wire it to
+// an -update flag in your tests:
+//
+//	var update = flag.Bool("update", false, "record golden responses")
+//
+//	mode := llmtest.ModeReplay
+//	if *update {
+//		mode = llmtest.ModeRecord
+//	}
+//	client := llmtest.NewGoldenClient(inner, "testdata/golden", mode)
+func NewGoldenClient(inner llm.Client, dir string, mode Mode) *GoldenClient {
+	return &GoldenClient{Client: inner, dir: dir, mode: mode}
+}
+
+// GenerateStream streams the request from the wrapped client; streams are
+// not recorded
+func (c *GoldenClient) GenerateStream(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	return llm.GenerateStream(ctx, c.Client, request)
+}
+
+// NewGoldenClientFromEnv creates a GoldenClient that records when
+// LLM_RECORD is set and replays otherwise
+func NewGoldenClientFromEnv(inner llm.Client, dir string) *GoldenClient {
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+	return NewGoldenClient(inner, dir, mode)
+}
+
+// Recording reports whether the client is in record mode
+func (g *GoldenClient) Recording() bool {
+	return g.mode == ModeRecord
+}
+
+// Generate replays or records the response to request. Identical requests
+// share a golden file.
+func (g *GoldenClient) Generate(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	config := g.Client.GetConfig()
+	key := goldenChatKey{Provider: config.Provider, DefaultModel: config.DefaultModel, Request: request, ContinueOnLength: request.ContinueOnLength}
+	var response llm.Response
+	err := g.golden(goldenGenerate, key, &response, func() (interface{}, error) {
+		response, err := g.Client.Generate(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		normalizeResponse(response)
+		return response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// GenerateWithHistory replays or records the response to the conversation
+func (g *GoldenClient) GenerateWithHistory(ctx context.Context, history llm.ChatHistory, userMessage string, systemPrompt string) (*llm.Response, error) {
+	request := llm.BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return g.Generate(ctx, request)
+}
+
+// CreateEmbedding replays or records the response to request
+func (g *GoldenClient) CreateEmbedding(ctx context.Context, request llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	config := g.Client.GetConfig()
+	key := goldenEmbeddingKey{
+		Provider:          config.Provider,
+		DefaultModel:      config.DefaultEmbeddingModel,
+		Request:           request,
+		Task:              request.Task,
+		Title:             request.Title,
+		Normalize:         request.Normalize,
+		AsFloat32:         request.AsFloat32,
+		LateChunking:      request.LateChunking,
+		LongInputStrategy: request.LongInputStrategy,
+		MaxInputTokens:    request.MaxInputTokens,
+	}
+	var response llm.EmbeddingResponse
+	err := g.golden(goldenEmbedding, key, &response, func() (interface{}, error) {
+		response, err := g.Client.CreateEmbedding(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		response.RequestID = "golden-request-id"
+		response.ResponseTime = 0
+		return response, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// golden decodes the golden file of key into response, first recording the
+// result of call to it in record mode. The recorded bytes are decoded in
+// both modes, so callers see the same response when recording and replaying.
+func (g *GoldenClient) golden(operation string, key interface{}, response interface{}, call func() (interface{}, error)) error {
+	request, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("llmtest: encode golden key: %w", err)
+	}
+	hash := sha256.Sum256(append([]byte(operation+"\n"), request...))
+	path := filepath.Join(g.dir, operation+"-"+hex.EncodeToString(hash[:])[:16]+".json")
+
+	if g.mode == ModeRecord {
+		result, err := call()
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("llmtest: encode golden response: %w", err)
+		}
+		file := GoldenFile{Provider: g.Client.GetConfig().Provider, Operation: operation, Request: request, Response: body}
+		data, err := json.MarshalIndent(file, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(g.dir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+			return fmt.Errorf("llmtest: write golden file: %w", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("llmtest: no golden response for %s (%s); record it with %s=1 or your -update flag: %w", operation, path, RecordEnv, err)
+	}
+	var file GoldenFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("llmtest: invalid golden file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(file.Response, response); err != nil {
+		return fmt.Errorf("llmtest: invalid golden response in %s: %w", path, err)
+	}
+	return nil
+}
+
+// normalizeResponse replaces the fields of a chat response that change on
+// every call with fixed values
+func normalizeResponse(response *llm.Response) {
+	response.RequestID = "golden-request-id"
+	response.ResponseTime = 0
+	response.Timing = nil
+	for i := range response.ToolCalls {
+		response.ToolCalls[i].ID = fmt.Sprintf("golden-call-%d", i)
+	}
+}
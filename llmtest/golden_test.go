@@ -0,0 +1,78 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+func TestGoldenClient(t *testing.T) {
+	dir := t.TempDir()
+	config := llm.Config{Provider: llm.ProviderDeepSeek, DefaultModel: "deepseek-chat"}
+	request := llm.BuildRequestWithSystemPrompt("Be brief.", "What's the weather?")
+	ctx := context.Background()
+
+	recorded := NewMockClient(config)
+	recorded.Enqueue(&llm.Response{
+		Content:      "Let me check.",
+		Role:         llm.RoleAssistant,
+		FinishReason: llm.FinishToolCalls,
+		ToolCalls:    []llm.ToolCall{{ID: "call_x7Qz", Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		RequestID:    "3f2a9c1e-random",
+		ResponseTime: 1234 * time.Millisecond,
+		Timing:       &llm.Timing{Wait: time.Second},
+		Usage:        llm.Usage{PromptTokens: 12, CompletionTokens: 8, TotalTokens: 20},
+	})
+	recorded.EnqueueEmbedding(&llm.EmbeddingResponse{Embeddings: [][]float64{{0.25, -0.5}}, Model: "embed", RequestID: "random"})
+
+	// Wrappers are recorded like any other client
+	recorder := NewGoldenClient(llm.NewBudgetClient(recorded, llm.BudgetConfig{}), dir, ModeRecord)
+	first, err := recorder.Generate(ctx, request)
+	if err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	if first.RequestID != "golden-request-id" || first.ResponseTime != 0 || first.Timing != nil || first.ToolCalls[0].ID != "golden-call-0" {
+		t.Errorf("Expected volatile fields to be normalized, got %+v", first)
+	}
+	if _, err := recorder.CreateEmbedding(ctx, llm.EmbeddingRequest{Input: []string{"hi"}}); err != nil {
+		t.Fatalf("Record embedding failed: %v", err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 2 {
+		t.Fatalf("Expected two golden files, got %v", files)
+	}
+	data, _ := os.ReadFile(files[1])
+	if strings.Contains(string(data), "call_x7Qz") || strings.Contains(string(data), "3f2a9c1e") {
+		t.Errorf("Golden file contains volatile fields: %s", data)
+	}
+
+	// Replaying never calls the wrapped client, which has nothing scripted
+	replayer := NewGoldenClient(NewMockClient(config), dir, ModeReplay)
+	replayed, err := replayer.Generate(ctx, request)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if !reflect.DeepEqual(replayed, first) {
+		t.Errorf("Expected the recorded response, got %+v", replayed)
+	}
+	embedding, err := replayer.CreateEmbedding(ctx, llm.EmbeddingRequest{Input: []string{"hi"}})
+	if err != nil || embedding.Embeddings[0][1] != -0.5 {
+		t.Errorf("Expected the recorded embedding, got %+v, %v", embedding, err)
+	}
+
+	if _, err := replayer.Generate(ctx, llm.BuildSimpleRequest("Something else")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing golden file error, got %v", err)
+	}
+	other := NewGoldenClient(NewMockClient(llm.Config{Provider: llm.ProviderOpenAI}), dir, ModeReplay)
+	if _, err := other.Generate(ctx, request); err == nil {
+		t.Error("Expected the provider to be part of the golden key")
+	}
+}
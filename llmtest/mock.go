@@ -0,0 +1,450 @@
+// Package llmtest provides test doubles for code that depends on llm.Client.
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+// ErrNoResponse is returned when a MockClient has no rule or queued reply for a call
+var ErrNoResponse = errors.New("llmtest: no response scripted for request")
+
+// Matcher reports whether a rule applies to a request
+type Matcher func(request llm.Request) bool
+
+// Contains matches requests whose messages contain substr
+func Contains(substr string) Matcher {
+	return func(request llm.Request) bool {
+		for _, msg := range request.Messages {
+			if strings.Contains(msg.Content, substr) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// MatchesRegexp matches requests with a message matching pattern. It panics
+// if pattern does not compile, like regexp.MustCompile.
+func MatchesRegexp(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(request llm.Request) bool {
+		for _, msg := range request.Messages {
+			if re.MatchString(msg.Content) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Model matches requests that explicitly ask for model
+func Model(model string) Matcher {
+	return func(request llm.Request) bool {
+		return request.Model != nil && *request.Model == model
+	}
+}
+
+// reply is a scripted outcome of a single call
+type reply struct {
+	response *llm.Response
+	err      error
+}
+
+// Rule returns a fixed reply for every request its matcher accepts
+type Rule struct {
+	match Matcher
+	reply reply
+}
+
+// Return makes the rule answer with response
+func (r *Rule) Return(response *llm.Response) *Rule {
+	r.reply = reply{response: response}
+	return r
+}
+
+// ReturnText makes the rule answer with an assistant message containing content
+func (r *Rule) ReturnText(content string) *Rule {
+	return r.Return(TextResponse(content))
+}
+
+// ReturnError makes the rule fail with err
+func (r *Rule) ReturnError(err error) *Rule {
+	r.reply = reply{err: err}
+	return r
+}
+
+// MockClient is a scriptable llm.Client. Generate answers with the first
+// matching rule, otherwise with the next queued reply, otherwise with
+// ErrNoResponse. GenerateStream plays the next queued chunk sequence, or
+// streams the reply Generate would give. All received requests are
+// recorded. It is safe for concurrent use.
+type MockClient struct {
+	mu                sync.Mutex
+	config            llm.Config
+	latency           time.Duration
+	rules             []*Rule
+	queue             []reply
+	streams           [][]llm.StreamChunk
+	embeddings        []*llm.EmbeddingResponse
+	embeddingErrs     []error
+	requests          []llm.Request
+	streamRequests    []llm.Request
+	embeddingRequests []llm.EmbeddingRequest
+	models            []llm.ModelInfo
+	capabilities      *llm.Capabilities
+	pingErr           error
+	closed            bool
+}
+
+// NewMockClient creates a MockClient reporting config from GetConfig
+func NewMockClient(config llm.Config) *MockClient {
+	return &MockClient{config: config}
+}
+
+// TextResponse builds an assistant response with the given content
+func TextResponse(content string) *llm.Response {
+	return &llm.Response{Content: content, Role: llm.RoleAssistant, FinishReason: llm.FinishStop}
+}
+
+// On adds a rule for requests accepted by match. Rules are tried in the order
+// they were added and are never consumed.
+func (m *MockClient) On(match Matcher) *Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rule := &Rule{match: match, reply: reply{err: ErrNoResponse}}
+	m.rules = append(m.rules, rule)
+	return rule
+}
+
+// Enqueue queues a response for the next unmatched Generate call
+func (m *MockClient) Enqueue(response *llm.Response) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, reply{response: response})
+}
+
+// EnqueueText queues an assistant message for the next unmatched Generate call
+func (m *MockClient) EnqueueText(content string) {
+	m.Enqueue(TextResponse(content))
+}
+
+// EnqueueError queues an error for the next unmatched Generate call
+func (m *MockClient) EnqueueError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = append(m.queue, reply{err: err})
+}
+
+// EnqueueStream queues the chunks delivered by the next GenerateStream call.
+// A final chunk with Done set and FinishStop is added unless the last chunk
+// is one.
+func (m *MockClient) EnqueueStream(chunks ...llm.StreamChunk) {
+	chunks = append([]llm.StreamChunk(nil), chunks...)
+	if len(chunks) == 0 || !chunks[len(chunks)-1].Done {
+		chunks = append(chunks, llm.StreamChunk{FinishReason: llm.FinishStop, Usage: &llm.Usage{}, Done: true})
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.streams = append(m.streams, chunks)
+}
+
+// EnqueueStreamError queues the chunks delivered by the next GenerateStream
+// call, followed by a final chunk failing with err, as when a stream dies
+// mid-generation
+func (m *MockClient) EnqueueStreamError(err error, chunks ...llm.StreamChunk) {
+	m.EnqueueStream(append(append([]llm.StreamChunk(nil), chunks...), llm.StreamChunk{Usage: &llm.Usage{}, Done: true, Err: err})...)
+}
+
+// TextChunks returns a content chunk per delta, for EnqueueStream
+func TextChunks(deltas ...string) []llm.StreamChunk {
+	chunks := make([]llm.StreamChunk, len(deltas))
+	for i, delta := range deltas {
+		chunks[i] = llm.StreamChunk{Content: delta}
+	}
+	return chunks
+}
+
+// EnqueueEmbedding queues a response for the next CreateEmbedding call
+func (m *MockClient) EnqueueEmbedding(response *llm.EmbeddingResponse) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.embeddings = append(m.embeddings, response)
+	m.embeddingErrs = append(m.embeddingErrs, nil)
+}
+
+// EnqueueEmbeddingError queues an error for the next CreateEmbedding call
+func (m *MockClient) EnqueueEmbeddingError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.embeddings = append(m.embeddings, nil)
+	m.embeddingErrs = append(m.embeddingErrs, err)
+}
+
+// SetLatency delays every call by d, or until the context is done
+func (m *MockClient) SetLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.latency = d
+}
+
+// Requests returns the chat requests received so far
+func (m *MockClient) Requests() []llm.Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]llm.Request(nil), m.requests...)
+}
+
+// StreamRequests returns the requests received by GenerateStream so far
+func (m *MockClient) StreamRequests() []llm.Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]llm.Request(nil), m.streamRequests...)
+}
+
+// EmbeddingRequests returns the embedding requests received so far
+func (m *MockClient) EmbeddingRequests() []llm.EmbeddingRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]llm.EmbeddingRequest(nil), m.embeddingRequests...)
+}
+
+// Closed reports whether Close was called
+func (m *MockClient) Closed() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closed
+}
+
+// Generate records the request and returns the scripted reply
+func (m *MockClient) Generate(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	m.mu.Lock()
+	m.requests = append(m.requests, request)
+	next := m.nextReply(request)
+	latency := m.latency
+	m.mu.Unlock()
+
+	if err := wait(ctx, latency); err != nil {
+		return nil, err
+	}
+	if next.err != nil {
+		return nil, next.err
+	}
+	response := *next.response
+	return &response, nil
+}
+
+// GenerateStream records the request and streams the next queued chunk
+// sequence. Without one it streams the content of the reply Generate would
+// give as a single chunk, and returns its error before the stream starts.
+// The stream stops early, with the context error on its final chunk, when
+// ctx is done.
+func (m *MockClient) GenerateStream(ctx context.Context, request llm.Request) (*llm.Response, error) {
+	m.mu.Lock()
+	m.streamRequests = append(m.streamRequests, request)
+	var chunks []llm.StreamChunk
+	if len(m.streams) > 0 {
+		chunks = m.streams[0]
+		m.streams = m.streams[1:]
+	} else {
+		next := m.nextReply(request)
+		if next.err != nil {
+			m.mu.Unlock()
+			return nil, next.err
+		}
+		usage := next.response.Usage
+		chunks = []llm.StreamChunk{
+			{Content: next.response.Content, ReasoningContent: next.response.ReasoningContent},
+			{FinishReason: next.response.FinishReason, ToolCalls: next.response.ToolCalls, Usage: &usage, Done: true},
+		}
+	}
+	latency := m.latency
+	m.mu.Unlock()
+
+	if err := wait(ctx, latency); err != nil {
+		return nil, err
+	}
+	stream := make(chan llm.StreamChunk)
+	go func() {
+		defer close(stream)
+		for _, chunk := range chunks {
+			select {
+			case stream <- chunk:
+			case <-ctx.Done():
+				select {
+				case stream <- llm.StreamChunk{Done: true, Err: ctx.Err()}:
+				default:
+				}
+				return
+			}
+		}
+	}()
+	model := m.config.DefaultModel
+	if request.Model != nil {
+		model = *request.Model
+	}
+	return &llm.Response{Role: llm.RoleAssistant, Provider: m.config.Provider, Model: model, Stream: stream}, nil
+}
+
+// nextReply picks the reply to request, consuming a queued one if no rule
+// matches. m.mu must be held.
+func (m *MockClient) nextReply(request llm.Request) reply {
+	next := reply{err: ErrNoResponse}
+	matched := false
+	for _, rule := range m.rules {
+		if rule.match(request) {
+			next, matched = rule.reply, true
+			break
+		}
+	}
+	if !matched && len(m.queue) > 0 {
+		next = m.queue[0]
+		m.queue = m.queue[1:]
+	}
+	return next
+}
+
+// GenerateWithHistory builds the request like the real clients and calls Generate
+func (m *MockClient) GenerateWithHistory(ctx context.Context, history llm.ChatHistory, userMessage string, systemPrompt string) (*llm.Response, error) {
+	request := llm.BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return m.Generate(ctx, request)
+}
+
+// CreateEmbedding records the request and returns the next queued embedding reply
+func (m *MockClient) CreateEmbedding(ctx context.Context, request llm.EmbeddingRequest) (*llm.EmbeddingResponse, error) {
+	m.mu.Lock()
+	m.embeddingRequests = append(m.embeddingRequests, request)
+	var response *llm.EmbeddingResponse
+	err := ErrNoResponse
+	if len(m.embeddings) > 0 {
+		response, err = m.embeddings[0], m.embeddingErrs[0]
+		m.embeddings, m.embeddingErrs = m.embeddings[1:], m.embeddingErrs[1:]
+	}
+	latency := m.latency
+	m.mu.Unlock()
+
+	if waitErr := wait(ctx, latency); waitErr != nil {
+		return nil, waitErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := *response
+	return &result, nil
+}
+
+// Close marks the client closed
+func (m *MockClient) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closed = true
+	return nil
+}
+
+// GetConfig returns the configured config with the API key masked
+func (m *MockClient) GetConfig() llm.Config {
+	config := m.config.Clone()
+	if config.APIKey != "" {
+		config.APIKey = "****"
+	}
+	return config
+}
+
+// GetConfigWithSecrets returns the configured config
+func (m *MockClient) GetConfigWithSecrets() llm.Config {
+	return m.config.Clone()
+}
+
+// SetModels sets the models returned by ListModels
+func (m *MockClient) SetModels(models ...llm.ModelInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.models = append([]llm.ModelInfo(nil), models...)
+}
+
+// ListModels returns the models set with SetModels
+func (m *MockClient) ListModels(ctx context.Context) ([]llm.ModelInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]llm.ModelInfo(nil), m.models...), nil
+}
+
+// SetCapabilities sets the value returned by Capabilities
+func (m *MockClient) SetCapabilities(capabilities llm.Capabilities) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.capabilities = &capabilities
+}
+
+// Capabilities returns the value set with SetCapabilities, or chat,
+// streaming and embeddings support
+func (m *MockClient) Capabilities() llm.Capabilities {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.capabilities != nil {
+		return *m.capabilities
+	}
+	return llm.Capabilities{Chat: true, Streaming: true, Embeddings: true}
+}
+
+// SetPingError sets the error returned by Ping
+func (m *MockClient) SetPingError(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pingErr = err
+}
+
+// Ping returns the error set with SetPingError
+func (m *MockClient) Ping(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.pingErr
+}
+
+// Warmup does nothing: the mock has no connections
+func (m *MockClient) Warmup(ctx context.Context) error {
+	return nil
+}
+
+// CountTokens estimates the prompt tokens of request with the configured
+// tokenizer, or HeuristicTokenizer
+func (m *MockClient) CountTokens(request llm.Request) int {
+	var tokenizer llm.Tokenizer = llm.HeuristicTokenizer{}
+	if m.config.Tokenizer != nil {
+		tokenizer = m.config.Tokenizer
+	}
+	model := m.config.DefaultModel
+	if request.Model != nil {
+		model = *request.Model
+	}
+	return tokenizer.CountMessages(model, request.Messages)
+}
+
+// wait sleeps for d unless ctx is done first
+func wait(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+var (
+	_ llm.Client   = (*MockClient)(nil)
+	_ llm.Streamer = (*MockClient)(nil)
+)
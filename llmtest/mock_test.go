@@ -0,0 +1,123 @@
+package llmtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+func TestMockClient(t *testing.T) {
+	mock := NewMockClient(llm.Config{Provider: llm.ProviderOpenAI, APIKey: "sk-test"})
+	errRateLimited := errors.New("rate limited")
+
+	mock.On(Contains("weather")).ReturnText("sunny")
+	mock.On(Model("gpt-4")).ReturnError(errRateLimited)
+	mock.EnqueueText("first")
+	mock.EnqueueText("second")
+
+	gpt4 := llm.BuildSimpleRequest("hello")
+	gpt4.SetModel("gpt-4")
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		request llm.Request
+		want    string
+		wantErr error
+	}{
+		{request: llm.BuildSimpleRequest("what's the weather?"), want: "sunny"},
+		{request: llm.BuildSimpleRequest("hello"), want: "first"},
+		{request: gpt4, wantErr: errRateLimited},
+		{request: llm.BuildSimpleRequest("hello"), want: "second"},
+		{request: llm.BuildSimpleRequest("hello"), wantErr: ErrNoResponse},
+	} {
+		response, err := mock.Generate(ctx, tt.request)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Expected %v, got %v", tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil || response.Content != tt.want {
+			t.Errorf("Expected %q, got %+v, %v", tt.want, response, err)
+		}
+	}
+
+	if got := len(mock.Requests()); got != 5 {
+		t.Errorf("Expected 5 recorded requests, got %d", got)
+	}
+	if mock.GetConfig().APIKey == "sk-test" || mock.GetConfigWithSecrets().APIKey != "sk-test" {
+		t.Error("Expected GetConfig to mask the API key")
+	}
+}
+
+func TestMockClientEmbeddings(t *testing.T) {
+	mock := NewMockClient(llm.Config{})
+	mock.EnqueueEmbedding(&llm.EmbeddingResponse{Embeddings: [][]float64{{1, 0}}})
+
+	response, err := mock.CreateEmbedding(context.Background(), llm.EmbeddingRequest{Input: []string{"a"}})
+	if err != nil || len(response.Embeddings) != 1 {
+		t.Fatalf("Unexpected embedding result: %+v, %v", response, err)
+	}
+	if _, err := mock.CreateEmbedding(context.Background(), llm.EmbeddingRequest{}); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected ErrNoResponse, got %v", err)
+	}
+	if got := len(mock.EmbeddingRequests()); got != 2 {
+		t.Errorf("Expected 2 recorded embedding requests, got %d", got)
+	}
+}
+
+func TestMockClientLatency(t *testing.T) {
+	mock := NewMockClient(llm.Config{})
+	mock.SetLatency(time.Second)
+	mock.EnqueueText("slow")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := mock.Generate(ctx, llm.BuildSimpleRequest("hi")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestMockClientStream(t *testing.T) {
+	mock := NewMockClient(llm.Config{Provider: llm.ProviderOpenAI})
+	errDropped := errors.New("connection reset")
+	mock.EnqueueStream(TextChunks("Hel", "lo")...)
+	mock.EnqueueStreamError(errDropped, TextChunks("Par")...)
+	mock.On(Contains("weather")).ReturnText("sunny")
+
+	ctx := context.Background()
+	response, err := llm.GenerateWithCallback(ctx, mock, llm.BuildSimpleRequest("hi"), func(llm.StreamChunk) error { return nil })
+	if err != nil || response.Content != "Hello" || response.FinishReason != llm.FinishStop {
+		t.Errorf("Expected the queued stream, got %+v, %v", response, err)
+	}
+	if _, err := llm.GenerateWithCallback(ctx, mock, llm.BuildSimpleRequest("hi"), func(llm.StreamChunk) error { return nil }); !errors.Is(err, errDropped) {
+		t.Errorf("Expected the mid-stream error, got %v", err)
+	}
+
+	// Without a queued stream the Generate script is streamed
+	var deltas []string
+	for chunk, err := range llm.Stream(ctx, mock, llm.BuildSimpleRequest("what's the weather?")) {
+		if err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if !chunk.Done {
+			deltas = append(deltas, chunk.Content)
+		}
+	}
+	if len(deltas) != 1 || deltas[0] != "sunny" {
+		t.Errorf("Expected the rule's reply, got %q", deltas)
+	}
+	if _, err := llm.GenerateStream(ctx, mock, llm.BuildSimpleRequest("hi")); !errors.Is(err, ErrNoResponse) {
+		t.Errorf("Expected ErrNoResponse before the stream starts, got %v", err)
+	}
+
+	if got := len(mock.StreamRequests()); got != 4 {
+		t.Errorf("Expected 4 recorded stream requests, got %d", got)
+	}
+	if got := len(mock.Requests()); got != 0 {
+		t.Errorf("Expected streams not recorded as Generate calls, got %d", got)
+	}
+}
@@ -0,0 +1,255 @@
+package llmtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RecordEnv is the environment variable that switches NewRecorderFromEnv into
+// record mode when set to a non-empty value
+const RecordEnv = "LLM_RECORD"
+
+// Mode selects whether a Recorder talks to the network
+type Mode int
+
+const (
+	// ModeReplay serves responses from fixture files and never touches the network
+	ModeReplay Mode = iota
+	// ModeRecord forwards requests and writes sanitized fixtures
+	ModeRecord
+)
+
+// volatileFields are response fields that change on every call; they are
+// replaced with fixed values when recording
+var volatileFields = map[string]interface{}{
+	"id":                 "fixture-id",
+	"created":            0,
+	"system_fingerprint": "fixture",
+	"response_id":        "fixture-id",
+	"generation_id":      "fixture-id",
+}
+
+// keptResponseHeaders are the only response headers written to fixtures
+var keptResponseHeaders = []string{"Content-Type", "Retry-After"}
+
+// Fixture is the on-disk form of a recorded exchange
+type Fixture struct {
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Request  json.RawMessage   `json:"request,omitempty"`
+	Status   int               `json:"status"`
+	Header   map[string]string `json:"header,omitempty"`
+	Response json.RawMessage   `json:"response,omitempty"`
+	// ResponseText holds bodies that are not JSON (SSE streams, HTML error pages)
+	ResponseText string `json:"response_text,omitempty"`
+}
+
+// Recorder is an http.RoundTripper that records provider exchanges to
+// fixture files and replays them. Fixtures are keyed by a hash of the
+// method, path, query and canonicalized JSON body, so the host and headers
+// (including credentials) do not matter on replay.
+type Recorder struct {
+	dir  string
+	mode Mode
+	next http.RoundTripper
+}
+
+// NewRecorder creates a Recorder storing fixtures in dir. next performs real
+// requests in record mode; nil means http.DefaultTransport.
+func NewRecorder(dir string, mode Mode, next http.RoundTripper) *Recorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Recorder{dir: dir, mode: mode, next: next}
+}
+
+// NewRecorderFromEnv creates a Recorder that records when LLM_RECORD is set
+// and replays otherwise
+func NewRecorderFromEnv(dir string) *Recorder {
+	mode := ModeReplay
+	if os.Getenv(RecordEnv) != "" {
+		mode = ModeRecord
+	}
+	return NewRecorder(dir, mode, nil)
+}
+
+// Recording reports whether the recorder is in record mode
+func (r *Recorder) Recording() bool {
+	return r.mode == ModeRecord
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("llmtest: read request body: %w", err)
+	}
+	canonical := canonicalJSON(body)
+	key := fixtureKey(req, canonical)
+	path := filepath.Join(r.dir, key+".json")
+
+	if r.mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("llmtest: no fixture for %s %s (%s); run with %s=1 to record: %w", req.Method, req.URL.Path, path, RecordEnv, err)
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return nil, fmt.Errorf("llmtest: invalid fixture %s: %w", path, err)
+		}
+		return fixtureResponse(req, fixture), nil
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.Header.Del("Content-Encoding")
+	req.ContentLength = int64(len(body))
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := decodedBody(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, fmt.Errorf("llmtest: read response body: %w", err)
+	}
+
+	fixture := Fixture{
+		Method:  req.Method,
+		Path:    requestPath(req),
+		Request: requestJSON(canonical),
+		Status:  resp.StatusCode,
+		Header:  map[string]string{},
+	}
+	if json.Valid(respBody) {
+		fixture.Response = normalizeVolatile(respBody)
+	} else {
+		fixture.ResponseText = string(respBody)
+	}
+	for _, k := range keptResponseHeaders {
+		if v := resp.Header.Get(k); v != "" {
+			fixture.Header[k] = v
+		}
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(r.dir, 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, fmt.Errorf("llmtest: write fixture: %w", err)
+	}
+	return fixtureResponse(req, fixture), nil
+}
+
+// fixtureKey hashes the parts of a request that identify a fixture
+func fixtureKey(req *http.Request, canonicalBody []byte) string {
+	h := sha256.New()
+	h.Write([]byte(req.Method + " " + requestPath(req) + "\n"))
+	h.Write(canonicalBody)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// requestPath returns the path and query of a request
+func requestPath(req *http.Request) string {
+	if req.URL.RawQuery == "" {
+		return req.URL.Path
+	}
+	return req.URL.Path + "?" + req.URL.RawQuery
+}
+
+// requestBody reads the request body, undoing gzip compression
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	return decodedBody(req.Body, req.Header.Get("Content-Encoding"))
+}
+
+// decodedBody reads body, undoing gzip compression
+func decodedBody(body io.ReadCloser, encoding string) ([]byte, error) {
+	defer body.Close()
+	if !strings.EqualFold(encoding, "gzip") {
+		return io.ReadAll(body)
+	}
+	zr, err := gzip.NewReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// canonicalJSON re-encodes a JSON document with sorted keys; other bodies
+// are returned unchanged
+func canonicalJSON(body []byte) []byte {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// normalizeVolatile replaces top-level volatileFields in a JSON object
+func normalizeVolatile(body []byte) []byte {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(body, &obj); err != nil {
+		return body
+	}
+	for k, v := range volatileFields {
+		if _, ok := obj[k]; ok {
+			obj[k] = v
+		}
+	}
+	out, err := json.Marshal(obj)
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+// requestJSON returns a canonical request body for the fixture, or nil if it isn't JSON
+func requestJSON(body []byte) json.RawMessage {
+	if len(body) == 0 || !json.Valid(body) {
+		return nil
+	}
+	return body
+}
+
+// fixtureResponse builds the HTTP response described by fixture
+func fixtureResponse(req *http.Request, fixture Fixture) *http.Response {
+	header := http.Header{}
+	for k, v := range fixture.Header {
+		header.Set(k, v)
+	}
+	body := []byte(fixture.Response)
+	if fixture.ResponseText != "" {
+		body = []byte(fixture.ResponseText)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fixture.Status, http.StatusText(fixture.Status)),
+		StatusCode:    fixture.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
@@ -0,0 +1,74 @@
+package llmtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	llm "github.com/yhwhpe/llm-unified-client"
+)
+
+func TestRecorder(t *testing.T) {
+	dir := t.TempDir()
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.Write([]byte(`{"id":"chatcmpl-123","created":1760000000,"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	generate := func(mode Mode) (*llm.Response, error) {
+		client, err := llm.NewClient(llm.Config{
+			Provider:     llm.ProviderOpenAI,
+			APIKey:       "sk-recorded-secret",
+			BaseURL:      server.URL,
+			GzipRequests: true,
+			HTTPClient:   &http.Client{Transport: NewRecorder(dir, mode, nil)},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return llm.GenerateSimple(context.Background(), client, "Hello")
+	}
+
+	if _, err := generate(ModeRecord); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	response, err := generate(ModeReplay)
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if response.Content != "ok" || calls != 1 {
+		t.Errorf("Expected replayed content without a second call, got %q after %d calls", response.Content, calls)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("Expected one fixture, got %v", files)
+	}
+	data, _ := os.ReadFile(files[0])
+	fixture := string(data)
+	for _, leaked := range []string{"sk-recorded-secret", "chatcmpl-123", "1760000000", "session=abc"} {
+		if strings.Contains(fixture, leaked) {
+			t.Errorf("Fixture contains %q: %s", leaked, fixture)
+		}
+	}
+
+	t.Run("missing fixture", func(t *testing.T) {
+		client, _ := llm.NewClient(llm.Config{
+			Provider:   llm.ProviderOpenAI,
+			APIKey:     "sk-test",
+			HTTPClient: &http.Client{Transport: NewRecorder(t.TempDir(), ModeReplay, nil)},
+		})
+		_, err := llm.GenerateSimple(context.Background(), client, "Hello")
+		if err == nil || !strings.Contains(err.Error(), RecordEnv) {
+			t.Errorf("Expected missing fixture error, got %v", err)
+		}
+	})
+}
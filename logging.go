@@ -0,0 +1,130 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxPromptPreview bounds the prompt excerpt written to debug logs
+const maxPromptPreview = 200
+
+// logGenerate writes a debug record for a finished chat call to Config.Logger
+func logGenerate(ctx context.Context, config Config, model string, request Request, response *Response, latency time.Duration, err error) {
+	if config.Logger == nil || !config.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("provider", string(config.Provider)),
+		slog.String("model", model),
+		slog.Int("messages", len(request.Messages)),
+		slog.Duration("latency", latency),
+	}
+	if !config.RedactPrompts {
+		attrs = append(attrs, slog.String("prompt_preview", promptPreview(request.Messages)))
+	}
+	if response != nil {
+		attrs = append(attrs,
+			slog.String("request_id", response.RequestID),
+			slog.Int("prompt_tokens", response.Usage.PromptTokens),
+			slog.Int("completion_tokens", response.Usage.CompletionTokens),
+			slog.String("finish_reason", string(response.FinishReason)),
+		)
+	}
+	logResult(ctx, config, "llm chat", attrs, err)
+}
+
+// logEmbedding writes a debug record for a finished embedding call to Config.Logger
+func logEmbedding(ctx context.Context, config Config, model string, request EmbeddingRequest, response *EmbeddingResponse, latency time.Duration, err error) {
+	if config.Logger == nil || !config.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("provider", string(config.Provider)),
+		slog.String("model", model),
+		slog.Int("inputs", len(request.Input)),
+		slog.Duration("latency", latency),
+	}
+	if response != nil {
+		attrs = append(attrs,
+			slog.String("request_id", response.RequestID),
+			slog.Int("prompt_tokens", response.Usage.PromptTokens),
+		)
+	}
+	logResult(ctx, config, "llm embedding", attrs, err)
+}
+
+// logResult adds the error, if any, and emits the record
+func logResult(ctx context.Context, config Config, msg string, attrs []slog.Attr, err error) {
+	if err != nil {
+		attrs = append(attrs,
+			slog.String("status", ErrorClass(err)),
+			slog.String("error", redactSecrets(config, err.Error())),
+		)
+	} else {
+		attrs = append(attrs, slog.String("status", StatusOK))
+	}
+	config.Logger.LogAttrs(ctx, slog.LevelDebug, msg, attrs...)
+}
+
+// promptPreview returns a truncated excerpt of the last user message
+func promptPreview(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			return truncateString(messages[i].Content, maxPromptPreview)
+		}
+	}
+	return ""
+}
+
+// truncateString shortens s to at most n bytes without splitting a UTF-8 sequence
+func truncateString(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + "..."
+}
+
+// redactSecrets masks the API key and configured header values wherever they
+// appear in s, e.g. in a provider error body that echoes the credentials.
+func redactSecrets(config Config, s string) string {
+	secrets := make([]string, 0, len(config.Headers)+1)
+	if config.APIKey != "" {
+		secrets = append(secrets, config.APIKey)
+	}
+	for _, v := range config.Headers {
+		if len(v) >= 8 {
+			secrets = append(secrets, v)
+		}
+	}
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, maskSecret(secret))
+	}
+	return s
+}
+
+// maskSecret hides all but the last four characters of a secret
+func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "****"
+	}
+	return "****" + secret[len(secret)-4:]
+}
+
+// maskedConfig returns a deep copy of config with the API key masked for
+// display
+func maskedConfig(config Config) Config {
+	config = config.Clone()
+	config.APIKey = maskSecret(config.APIKey)
+	return config
+}
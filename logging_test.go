@@ -0,0 +1,298 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	const apiKey = "sk-secret-key-1234567890"
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte(`{"error":"Incorrect API key provided: ` + apiKey + `"}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	newLoggedClient := func(buf *bytes.Buffer, redactPrompts bool) Client {
+		client, err := NewClient(Config{
+			Provider:      ProviderOpenAI,
+			APIKey:        apiKey,
+			BaseURL:       server.URL,
+			Logger:        slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+			RedactPrompts: redactPrompts,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	t.Run("success", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := GenerateSimple(context.Background(), newLoggedClient(&buf, false), "What is the capital of France?"); err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		out := buf.String()
+		for _, want := range []string{`"provider":"openai"`, `"messages":1`, `"prompt_preview":"What is the capital of France?"`, `"finish_reason":"stop"`, `"completion_tokens":2`, `"status":"ok"`} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected %s in log output: %s", want, out)
+			}
+		}
+	})
+
+	t.Run("redacted prompts", func(t *testing.T) {
+		var buf bytes.Buffer
+		if _, err := GenerateSimple(context.Background(), newLoggedClient(&buf, true), "patient record 42"); err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if strings.Contains(buf.String(), "patient record") {
+			t.Errorf("Prompt leaked into log output: %s", buf.String())
+		}
+	})
+
+	t.Run("secrets in error bodies", func(t *testing.T) {
+		status = http.StatusUnauthorized
+		defer func() { status = http.StatusOK }()
+
+		var buf bytes.Buffer
+		_, err := GenerateSimple(context.Background(), newLoggedClient(&buf, false), "Hello")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("Expected APIError, got %v", err)
+		}
+		if strings.Contains(err.Error(), apiKey) || strings.Contains(buf.String(), apiKey) {
+			t.Errorf("API key leaked: error=%v log=%s", err, buf.String())
+		}
+		if !strings.Contains(apiErr.Body, "****7890") {
+			t.Errorf("Expected masked key in body, got %s", apiErr.Body)
+		}
+		if !strings.Contains(buf.String(), `"status":"auth_error"`) {
+			t.Errorf("Expected auth_error status in log: %s", buf.String())
+		}
+	})
+}
+
+func TestGetConfigMasksAPIKey(t *testing.T) {
+	client, err := NewClient(Config{Provider: ProviderCohere, APIKey: "co-1234567890abcd"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if got := client.GetConfig().APIKey; got != "****abcd" {
+		t.Errorf("Expected masked key, got %q", got)
+	}
+	if got := client.GetConfigWithSecrets().APIKey; got != "co-1234567890abcd" {
+		t.Errorf("Expected real key, got %q", got)
+	}
+}
+
+func TestGetConfigCopy(t *testing.T) {
+	temperature := 0.5
+	caller := Config{
+		Provider:           ProviderOpenAI,
+		APIKey:             "sk-1234567890abcd",
+		DefaultTemperature: &temperature,
+		Headers:            map[string]string{"X-Team": "search"},
+		ExtraConfig:        map[string]interface{}{"nested": map[string]interface{}{"a": 1}, "list": []interface{}{"x"}},
+		TLS:                &TLSConfig{InsecureSkipVerify: true},
+	}
+	client, err := NewClient(caller)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// neither the caller's config nor returned copies reach the client
+	temperature = 1.5
+	caller.Headers["X-Team"] = "ads"
+	for _, config := range []Config{client.GetConfig(), client.GetConfigWithSecrets()} {
+		*config.DefaultTemperature = 2
+		config.Headers["X-Team"] = "billing"
+		config.ExtraConfig["nested"].(map[string]interface{})["a"] = 2
+		config.ExtraConfig["list"].([]interface{})[0] = "y"
+		config.TLS.InsecureSkipVerify = false
+	}
+
+	config := client.GetConfigWithSecrets()
+	if *config.DefaultTemperature != 0.5 || config.Headers["X-Team"] != "search" ||
+		config.ExtraConfig["nested"].(map[string]interface{})["a"] != 1 || config.ExtraConfig["list"].([]interface{})[0] != "x" ||
+		!config.TLS.InsecureSkipVerify {
+		t.Errorf("Client configuration was modified through a copy: %+v", config)
+	}
+	if got := client.GetConfig().APIKey; got != "****abcd" {
+		t.Errorf("Expected masked key keeping the last four characters, got %q", got)
+	}
+}
+
+// TestGetConfigMutationRace mutates returned configs while requests are in
+// flight; run with -race
+func TestGetConfigMutationRace(t *testing.T) {
+	var mu sync.Mutex
+	var temperatures []float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Temperature float64 `json:"temperature"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		temperatures = append(temperatures, payload.Temperature)
+		mu.Unlock()
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	temperature := 0.5
+	client, _ := NewClient(Config{
+		Provider:           ProviderOpenAI,
+		APIKey:             "test-key",
+		BaseURL:            server.URL,
+		DefaultTemperature: &temperature,
+		Headers:            map[string]string{"X-Team": "search"},
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); err != nil {
+				t.Errorf("Generate failed: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			config := client.GetConfig()
+			*config.DefaultTemperature = 1.5
+			config.Headers["X-Team"] = "debug"
+		}()
+	}
+	wg.Wait()
+
+	for _, got := range temperatures {
+		if got != 0.5 {
+			t.Errorf("Expected every request to use temperature 0.5, got %v", temperatures)
+			break
+		}
+	}
+}
+
+func TestDebugWriter(t *testing.T) {
+	const apiKey = "sk-secret-key-1234567890"
+	server := newChatServer(t, nil)
+
+	var buf bytes.Buffer
+	client, err := NewClient(Config{
+		Provider:              ProviderOpenAI,
+		APIKey:                apiKey,
+		BaseURL:               server.URL,
+		DisableBaseURLVersion: true,
+		GzipRequests:          true,
+		DebugWriter:           &buf,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-debug")
+	if _, err := GenerateSimple(ctx, client, "Hello debug"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"POST " + server.URL + "/chat/completions",
+		"Authorization: ****7890",
+		`"content":"Hello debug"`,
+		"<<< 200 OK (request req-debug)",
+		`"finish_reason":"stop"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in debug output: %s", want, out)
+		}
+	}
+	if strings.Contains(out, apiKey) {
+		t.Errorf("API key leaked into debug output: %s", out)
+	}
+}
+
+func TestDebugWriterMasksHeaders(t *testing.T) {
+	server := newChatServer(t, nil)
+
+	var buf bytes.Buffer
+	client, err := NewClient(Config{
+		Provider:    ProviderOpenAI,
+		APIKey:      "test-key",
+		BaseURL:     server.URL,
+		Headers:     map[string]string{"X-Gateway-Key": "short1", "X-Route": "config-route"},
+		DebugWriter: &buf,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := WithHeaders(context.Background(), map[string]string{"X-Route": "eu-west", "Cookie": "session=abc"})
+	request := BuildSimpleRequest("Hello")
+	request.Headers = map[string]string{"cf-aig-authorization": "Bearer gateway-token-1234"}
+	if _, err := client.Generate(ctx, request); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{
+		"X-Gateway-Key: ****\n",
+		"Cf-Aig-Authorization: ****1234\n",
+		"X-Route: eu-west\n",
+		"Content-Type: application/json\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in debug output: %s", want, out)
+		}
+	}
+	for _, secret := range []string{"short1", "gateway-token", "session=abc"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("Header value %q leaked into debug output: %s", secret, out)
+		}
+	}
+}
+
+func TestDebugWriterStream(t *testing.T) {
+	var path, query string
+	var payload map[string]interface{}
+	server := serveStream(t, "openai_chat.sse", &path, &query, &payload)
+
+	var buf bytes.Buffer
+	client, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DebugWriter: &buf})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-stream")
+	response, err := GenerateStream(ctx, client, BuildSimpleRequest("Hello"))
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	drainStream(t, response)
+
+	out := buf.String()
+	for _, want := range []string{
+		"<<< 200 OK (request req-stream)",
+		"Content-Type: text/event-stream",
+		"<<< event (request req-stream)\ndata: {",
+		"<<< event (request req-stream)\ndata: [DONE]\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in debug output: %s", want, out)
+		}
+	}
+}
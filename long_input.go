@@ -0,0 +1,305 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// ErrInputTooLong is returned for embedding inputs over the model's token
+// limit when EmbeddingRequest.LongInputStrategy is LongInputError
+var ErrInputTooLong = errors.New("embedding input exceeds the token limit")
+
+// LongInputStrategy selects what CreateEmbedding does with inputs longer
+// than the model's token limit
+type LongInputStrategy string
+
+const (
+	// LongInputPassThrough sends inputs as they are and lets the provider
+	// reject them (the default)
+	LongInputPassThrough LongInputStrategy = ""
+	// LongInputError fails with ErrInputTooLong before calling the provider
+	LongInputError LongInputStrategy = "error"
+	// LongInputTruncate embeds only the first limit's worth of tokens
+	LongInputTruncate LongInputStrategy = "truncate"
+	// LongInputChunkMean embeds overlapping chunks and returns their
+	// length-weighted mean, renormalized to unit length
+	LongInputChunkMean LongInputStrategy = "chunk_mean"
+)
+
+// textChunk is a piece of an input and its token count
+type textChunk struct {
+	text   string
+	tokens int
+}
+
+// wordPattern splits text into words with their trailing whitespace
+var wordPattern = regexp.MustCompile(`\S+\s*|\s+`)
+
+// embedLongInputs applies EmbeddingRequest.LongInputStrategy before call:
+// inputs over the limit are rejected, truncated or replaced by their chunks,
+// and chunk vectors are averaged back into one vector per input
+func embedLongInputs(ctx context.Context, config Config, request EmbeddingRequest, call embeddingFunc) (*EmbeddingResponse, error) {
+	limit := request.MaxInputTokens
+	if limit <= 0 {
+		limit = providerCapabilities(config.Provider).MaxEmbeddingInputTokens
+	}
+	if request.LongInputStrategy == LongInputPassThrough || limit <= 0 {
+		return call(ctx, request)
+	}
+
+	tokenizer := tokenizerFor(config)
+	model := ""
+	if request.Model != nil {
+		model = *request.Model
+	}
+	count := func(text string) int { return tokenizer.CountTokens(model, text) }
+
+	switch request.LongInputStrategy {
+	case LongInputError:
+		var failures []EmbeddingChunkError
+		for i, input := range request.Input {
+			if tokens := count(input); tokens > limit {
+				err := fmt.Errorf("%w: input %d has about %d tokens, limit %d", ErrInputTooLong, i, tokens, limit)
+				if !request.AllowPartial {
+					return nil, err
+				}
+				failures = append(failures, EmbeddingChunkError{Start: i, End: i + 1, Err: err})
+			}
+		}
+		if len(failures) == 0 {
+			return call(ctx, request)
+		}
+		return embedExcluding(ctx, request, failures, call)
+
+	case LongInputTruncate:
+		truncated := make([]bool, len(request.Input))
+		inputs := make([]string, len(request.Input))
+		for i, input := range request.Input {
+			inputs[i] = input
+			if count(input) > limit {
+				inputs[i] = splitByTokens(count, input, limit, 0)[0].text
+				truncated[i] = true
+			}
+		}
+		request.Input = inputs
+		response, err := call(ctx, request)
+		if response != nil {
+			response.Truncated = truncated
+		}
+		return response, err
+
+	case LongInputChunkMean:
+		return embedChunkMean(ctx, request, count, limit, call)
+	}
+	return nil, fmt.Errorf("unknown long input strategy %q", request.LongInputStrategy)
+}
+
+// embedExcluding embeds the inputs of request not covered by failures, the
+// single-input failures found before calling, and reports both those and
+// the failures of the call against the caller's inputs
+func embedExcluding(ctx context.Context, request EmbeddingRequest, failures []EmbeddingChunkError, call embeddingFunc) (*EmbeddingResponse, error) {
+	excluded := make([]bool, len(request.Input))
+	for _, failure := range failures {
+		excluded[failure.Start] = true
+	}
+	var inputs []string
+	var owner []int // input index of every input sent
+	for i, input := range request.Input {
+		if !excluded[i] {
+			inputs = append(inputs, input)
+			owner = append(owner, i)
+		}
+	}
+
+	response := &EmbeddingResponse{}
+	var err error
+	if len(inputs) > 0 {
+		sent := request
+		sent.Input = inputs
+		response, err = call(ctx, sent)
+		if response == nil {
+			return nil, err
+		}
+	}
+	var batchErr *EmbeddingBatchError
+	if errors.As(err, &batchErr) {
+		for _, failure := range batchErr.Failures {
+			for j := failure.Start; j < failure.End; j++ {
+				failures = append(failures, EmbeddingChunkError{Start: owner[j], End: owner[j] + 1, Err: failure.Err})
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(failures, func(a, b EmbeddingChunkError) int { return a.Start - b.Start })
+
+	response.Embeddings = spreadVectors(response.Embeddings, owner, len(request.Input))
+	response.Vectors32 = spreadVectors(response.Vectors32, owner, len(request.Input))
+	if len(inputs) == 0 {
+		// nothing was sent
+		if request.AsFloat32 {
+			response.Vectors32 = make([][]float32, len(request.Input))
+		} else {
+			response.Embeddings = make([][]float64, len(request.Input))
+		}
+	}
+	return response, &EmbeddingBatchError{Inputs: len(request.Input), Failures: failures}
+}
+
+// spreadVectors places the vectors of the inputs sent at their index among
+// n inputs, leaving the others nil
+func spreadVectors[T float32 | float64](vectors [][]T, owner []int, n int) [][]T {
+	if vectors == nil {
+		return nil
+	}
+	spread := make([][]T, n)
+	for j, vector := range vectors {
+		spread[owner[j]] = vector
+	}
+	return spread
+}
+
+// embedChunkMean embeds every chunk of every input in one (auto-batched)
+// call and averages each input's chunks
+func embedChunkMean(ctx context.Context, request EmbeddingRequest, count func(string) int, limit int, call embeddingFunc) (*EmbeddingResponse, error) {
+	overlap := request.ChunkOverlapTokens
+	if overlap <= 0 {
+		overlap = limit / 10
+	}
+	overlap = min(overlap, limit/2)
+
+	var inputs []string
+	var weights []float64
+	owner := make([]int, 0, len(request.Input)) // input index of every chunk
+	chunks := make([]int, len(request.Input))
+	for i, input := range request.Input {
+		pieces := []textChunk{{text: input, tokens: count(input)}}
+		if pieces[0].tokens > limit {
+			pieces = splitByTokens(count, input, limit, overlap)
+		}
+		chunks[i] = len(pieces)
+		for _, piece := range pieces {
+			inputs = append(inputs, piece.text)
+			weights = append(weights, float64(max(piece.tokens, 1)))
+			owner = append(owner, i)
+		}
+	}
+
+	chunkRequest := request
+	chunkRequest.Input = inputs
+	response, err := call(ctx, chunkRequest)
+	if response == nil {
+		return nil, err
+	}
+
+	failed := make([]bool, len(request.Input))
+	var batchErr *EmbeddingBatchError
+	if errors.As(err, &batchErr) {
+		// report failures against the caller's inputs, not the chunks
+		remapped := &EmbeddingBatchError{Inputs: len(request.Input)}
+		for _, failure := range batchErr.Failures {
+			start, end := owner[failure.Start], owner[failure.End-1]+1
+			for i := start; i < end; i++ {
+				failed[i] = true
+			}
+			remapped.Failures = append(remapped.Failures, EmbeddingChunkError{Start: start, End: end, Err: failure.Err})
+		}
+		err = remapped
+	}
+
+	response.Embeddings = meanVectors(response.Embeddings, owner, weights, failed)
+	response.Vectors32 = meanVectors(response.Vectors32, owner, weights, failed)
+	response.Chunks = chunks
+	return response, err
+}
+
+// meanVectors averages the chunk vectors of each input weighted by their
+// token counts and normalizes the result. Inputs with a failed chunk get nil.
+func meanVectors[T float32 | float64](vectors [][]T, owner []int, weights []float64, failed []bool) [][]T {
+	if vectors == nil {
+		return nil
+	}
+	sums := make([][]float64, len(failed))
+	for j, vector := range vectors {
+		i := owner[j]
+		if failed[i] || vector == nil {
+			failed[i] = true
+			continue
+		}
+		if sums[i] == nil {
+			sums[i] = make([]float64, len(vector))
+		}
+		for k, x := range vector {
+			if k < len(sums[i]) {
+				sums[i][k] += weights[j] * float64(x)
+			}
+		}
+	}
+
+	result := make([][]T, len(failed))
+	for i, sum := range sums {
+		if failed[i] || sum == nil {
+			continue
+		}
+		result[i] = make([]T, len(sum))
+		for k, x := range sum {
+			result[i][k] = T(x)
+		}
+		normalizeVector(result[i])
+	}
+	return result
+}
+
+// splitByTokens splits text at word boundaries into chunks of at most limit
+// tokens as counted by count, consecutive chunks sharing about overlap
+// tokens. Words longer than limit, and scripts without spaces, are split
+// between characters.
+func splitByTokens(count func(string) int, text string, limit, overlap int) []textChunk {
+	var units []textChunk
+	for _, word := range wordPattern.FindAllString(text, -1) {
+		tokens := count(word)
+		if tokens <= limit {
+			units = append(units, textChunk{word, tokens})
+			continue
+		}
+		// pack characters; approximate counts so slightly long words still fit
+		perRune := float64(tokens) / float64(utf8.RuneCountInString(word))
+		size := max(int(math.Floor(float64(limit)/perRune)), 1)
+		runes := []rune(word)
+		for start := 0; start < len(runes); start += size {
+			piece := string(runes[start:min(start+size, len(runes))])
+			units = append(units, textChunk{piece, min(count(piece), limit)})
+		}
+	}
+
+	var chunks []textChunk
+	for start := 0; start < len(units); {
+		end, tokens := start, 0
+		for end < len(units) && (end == start || tokens+units[end].tokens <= limit) {
+			tokens += units[end].tokens
+			end++
+		}
+		chunk := textChunk{tokens: tokens}
+		for _, unit := range units[start:end] {
+			chunk.text += unit.text
+		}
+		chunks = append(chunks, chunk)
+		if end == len(units) {
+			break
+		}
+
+		// step back over overlap tokens, always moving forward
+		next, shared := end, 0
+		for next-1 > start && shared+units[next-1].tokens <= overlap {
+			next--
+			shared += units[next].tokens
+		}
+		start = next
+	}
+	return chunks
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLongInputStrategies(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Input []string `json:"input"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		mu.Lock()
+		seen = append(seen, payload.Input...)
+		mu.Unlock()
+		// vector = [number of "a" words, number of "b" words]
+		var data []map[string]interface{}
+		for i, input := range payload.Input {
+			words := strings.Fields(input)
+			a := float64(strings.Count(input, "a"))
+			data = append(data, map[string]interface{}{"embedding": []float64{a, float64(len(words)) - a}, "index": i})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer server.Close()
+
+	words := TokenizerFunc(func(model, text string) int { return len(strings.Fields(text)) })
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBase64Embeddings: true, Tokenizer: words})
+	ctx := context.Background()
+	inputs := []string{"a a a a a a b b", "b b"}
+
+	_, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: inputs, MaxInputTokens: 4, LongInputStrategy: LongInputError})
+	if !errors.Is(err, ErrInputTooLong) || len(seen) != 0 {
+		t.Errorf("Expected ErrInputTooLong without a call, got %v after %d inputs", err, len(seen))
+	}
+
+	resp, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: inputs, MaxInputTokens: 4, LongInputStrategy: LongInputTruncate})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if seen[0] != "a a a a " || resp.Embeddings[0][0] != 4 || resp.Truncated[0] != true || resp.Truncated[1] != false {
+		t.Errorf("Expected the first input truncated to 4 words, sent %q, got %+v", seen[0], resp)
+	}
+
+	seen = nil
+	resp, err = client.CreateEmbedding(ctx, EmbeddingRequest{Input: inputs, MaxInputTokens: 4, ChunkOverlapTokens: 1, LongInputStrategy: LongInputChunkMean, AsFloat32: true})
+	if err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if got := strings.Join(seen, "|"); got != "a a a a |a a a b |b b|b b" {
+		t.Errorf("Unexpected chunks %q", got)
+	}
+	// chunks [4 0], [3 1], [0 2] weighted 4, 4, 2 sum to [28 8]
+	norm := math.Hypot(28, 8)
+	first := resp.Vectors32[0]
+	if math.Abs(float64(first[0])-28/norm) > 1e-6 || math.Abs(float64(first[1])-8/norm) > 1e-6 {
+		t.Errorf("Expected the normalized weighted mean, got %v", first)
+	}
+	if resp.Vectors32[1][1] != 1 || len(resp.Vectors32) != 2 || resp.Chunks[0] != 3 || resp.Chunks[1] != 1 {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+}
+
+func TestSplitByTokens(t *testing.T) {
+	count := HeuristicTokenizer{}.CountTokens
+	text := strings.Repeat("x", 100)
+	chunks := splitByTokens(func(s string) int { return count("", s) }, text, 10, 0)
+	var joined string
+	for _, chunk := range chunks {
+		if chunk.tokens > 10 {
+			t.Errorf("Chunk over the limit: %+v", chunk)
+		}
+		joined += chunk.text
+	}
+	if joined != text || len(chunks) != 3 {
+		t.Errorf("Expected a word without spaces split into 3 chunks, got %d", len(chunks))
+	}
+}
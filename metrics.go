@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+)
+
+// Operation names reported to metrics
+const (
+	OperationChat          = "chat"
+	OperationEmbedding     = "embedding"
+	OperationRerank        = "rerank"
+	OperationModeration    = "moderation"
+	OperationTranscription = "transcription"
+	OperationSpeech        = "speech"
+	OperationImage         = "image"
+)
+
+// Error classes returned by ErrorClass and reported as RequestMetrics.Status
+const (
+	StatusOK               = "ok"
+	StatusCanceled         = "canceled"
+	StatusTimeout          = "timeout"
+	StatusProviderTimeout  = "provider_timeout"
+	StatusAuthError        = "auth_error"
+	StatusRateLimited      = "rate_limited"
+	StatusClientError      = "client_error"
+	StatusServerError      = "server_error"
+	StatusProxyError       = "proxy_error"
+	StatusTLSError         = "tls_error"
+	StatusResponseTooLarge = "response_too_large"
+	StatusNetworkError     = "network_error"
+	StatusUnsupported      = "unsupported"
+	StatusContentFilter    = "content_filter"
+	StatusError            = "error"
+)
+
+// RequestMetrics describes one completed call
+type RequestMetrics struct {
+	Provider  Provider
+	Model     string
+	Operation string
+	// Status is StatusOK or the ErrorClass of the returned error
+	Status  string
+	Latency time.Duration
+	Usage   Usage
+	// Timing is the latency breakdown of chat calls when
+	// Config.CaptureTiming is on, nil otherwise
+	Timing *Timing
+	// Shadow is set for calls mirrored by a ShadowClient
+	Shadow bool
+	// Variant is the ABClient variant of the call, if any
+	Variant string
+}
+
+// MetricsRecorder receives an observation for every Generate and
+// CreateEmbedding call. Implementations must be safe for concurrent use.
+// See the llmprom module for a Prometheus adapter.
+type MetricsRecorder interface {
+	ObserveRequest(metrics RequestMetrics)
+}
+
+// MultiRecorder returns a MetricsRecorder that forwards every observation
+// to each of recorders, for example a UsageAccountant and a Prometheus adapter
+func MultiRecorder(recorders ...MetricsRecorder) MetricsRecorder {
+	return multiRecorder(recorders)
+}
+
+// multiRecorder is the MetricsRecorder returned by MultiRecorder
+type multiRecorder []MetricsRecorder
+
+// ObserveRequest forwards metrics to every recorder
+func (m multiRecorder) ObserveRequest(metrics RequestMetrics) {
+	for _, recorder := range m {
+		recorder.ObserveRequest(metrics)
+	}
+}
+
+// ErrorClass maps an error returned by a client to a low-cardinality class
+// suitable for metric labels. It returns StatusOK for a nil error.
+func ErrorClass(err error) string {
+	if err == nil {
+		return StatusOK
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch {
+		case errors.Is(apiErr, ErrProviderTimeout):
+			return StatusProviderTimeout
+		case errors.Is(apiErr, ErrContentFiltered):
+			return StatusContentFilter
+		case apiErr.StatusCode == 401 || apiErr.StatusCode == 403:
+			return StatusAuthError
+		case apiErr.StatusCode == 429:
+			return StatusRateLimited
+		case apiErr.StatusCode >= 500:
+			return StatusServerError
+		default:
+			return StatusClientError
+		}
+	}
+
+	var proxyErr *ProxyError
+	var tlsErr *TLSError
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.Canceled):
+		return StatusCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusTimeout
+	case errors.As(err, &proxyErr):
+		return StatusProxyError
+	case errors.As(err, &tlsErr):
+		return StatusTLSError
+	case errors.Is(err, ErrResponseTooLarge):
+		return StatusResponseTooLarge
+	case errors.Is(err, ErrAudioTooLarge):
+		return StatusClientError
+	case errors.Is(err, ErrContentFiltered):
+		return StatusContentFilter
+	case errors.Is(err, ErrUnsupported):
+		return StatusUnsupported
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			return StatusTimeout
+		}
+		return StatusNetworkError
+	}
+	return StatusError
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordingMetrics collects observations for assertions
+type recordingMetrics struct {
+	mu           sync.Mutex
+	observations []RequestMetrics
+}
+
+func (r *recordingMetrics) ObserveRequest(metrics RequestMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, metrics)
+}
+
+func TestMetricsRecorder(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	metrics := &recordingMetrics{}
+	client, err := NewClient(Config{
+		Provider:     ProviderDeepSeek,
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		DefaultModel: "deepseek-chat",
+		Metrics:      metrics,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := GenerateSimple(context.Background(), client, "Hello"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	status = http.StatusTooManyRequests
+	if _, err := GenerateSimple(context.Background(), client, "Hello"); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	if len(metrics.observations) != 2 {
+		t.Fatalf("Expected 2 observations, got %d", len(metrics.observations))
+	}
+	ok, limited := metrics.observations[0], metrics.observations[1]
+	if ok.Provider != ProviderDeepSeek || ok.Model != "deepseek-chat" || ok.Operation != OperationChat {
+		t.Errorf("Unexpected labels: %+v", ok)
+	}
+	if ok.Status != StatusOK || ok.Usage.PromptTokens != 5 || ok.Usage.CompletionTokens != 2 {
+		t.Errorf("Unexpected success observation: %+v", ok)
+	}
+	if limited.Status != StatusRateLimited {
+		t.Errorf("Expected rate_limited status, got %q", limited.Status)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, StatusOK},
+		{&APIError{StatusCode: 401}, StatusAuthError},
+		{&APIError{StatusCode: 429}, StatusRateLimited},
+		{&APIError{StatusCode: 400}, StatusClientError},
+		{fmt.Errorf("wrapped: %w", &APIError{StatusCode: 503}), StatusServerError},
+		{fmt.Errorf("failed to send request: %w", context.Canceled), StatusCanceled},
+		{context.DeadlineExceeded, StatusTimeout},
+		{&ProxyError{Err: errors.New("refused")}, StatusProxyError},
+		{&TLSError{Err: errors.New("x509")}, StatusTLSError},
+		{&ResponseTooLargeError{}, StatusResponseTooLarge},
+		{errors.New("boom"), StatusError},
+	}
+
+	for _, tt := range tests {
+		if got := ErrorClass(tt.err); got != tt.want {
+			t.Errorf("ErrorClass(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultModelListTTL is how long ListModels results are cached when
+// Config.ModelListTTL is unset
+const defaultModelListTTL = 10 * time.Minute
+
+// ModelInfo describes a model available to a client
+type ModelInfo struct {
+	ID       string   `json:"id"`
+	Provider Provider `json:"provider"`
+	OwnedBy  string   `json:"owned_by,omitempty"`
+	// BaseModel is the model behind an Azure deployment
+	BaseModel string `json:"base_model,omitempty"`
+	// ContextLength is the context window in tokens, when the API reports it
+	ContextLength int `json:"context_length,omitempty"`
+}
+
+// modelCache caches the result of a client's ListModels
+type modelCache struct {
+	mu      sync.Mutex
+	models  []ModelInfo
+	expires time.Time
+}
+
+// get returns the cached models, calling fetch once they expired
+func (c *modelCache) get(ctx context.Context, config Config, fetch func(ctx context.Context) ([]ModelInfo, error)) ([]ModelInfo, error) {
+	ttl := config.ModelListTTL
+	if ttl == 0 {
+		ttl = defaultModelListTTL
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.models == nil || ttl < 0 || !now().Before(c.expires) {
+		models, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for i := range models {
+			models[i].Provider = config.Provider
+		}
+		c.models = models
+		c.expires = now().Add(ttl)
+	}
+	return append([]ModelInfo(nil), c.models...), nil
+}
+
+// fetchJSON sends a GET request and decodes a successful JSON response into out
+func fetchJSON(ctx context.Context, httpClient *http.Client, config Config, url string, auth func(*http.Request), errorPrefix string, out interface{}) error {
+	_, err := withTimeout(ctx, config, "", 0, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, getJSON(ctx, httpClient, config, url, auth, errorPrefix, out)
+	})
+	return err
+}
+
+// getJSON performs the request of fetchJSON without a timeout
+func getJSON(ctx context.Context, httpClient *http.Client, config Config, url string, auth func(*http.Request), errorPrefix string, out interface{}) error {
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	auth(req)
+	setRequestID(ctx, req)
+	applyHeaders(req, config, nil)
+
+	resp, err := sendRequest(httpClient, config, req)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(config, resp, defaultMaxResponseBytes)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(config, errorPrefix, resp, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return nil
+}
+
+// listOpenAIModels lists models from an OpenAI-compatible GET /models
+// endpoint. Self-hosted servers (vLLM and others) also report context lengths.
+func listOpenAIModels(ctx context.Context, httpClient *http.Client, config Config, auth func(*http.Request)) ([]ModelInfo, error) {
+	var apiResp struct {
+		Data []struct {
+			ID            string `json:"id"`
+			OwnedBy       string `json:"owned_by"`
+			ContextLength int    `json:"context_length"`
+			MaxModelLen   int    `json:"max_model_len"` // vLLM
+		} `json:"data"`
+	}
+	if err := fetchJSON(ctx, httpClient, config, endpointURL(config, "/models"), auth, "List models API error", &apiResp); err != nil {
+		return nil, err
+	}
+
+	models := make([]ModelInfo, 0, len(apiResp.Data))
+	for _, m := range apiResp.Data {
+		contextLength := m.ContextLength
+		if contextLength == 0 {
+			contextLength = m.MaxModelLen
+		}
+		models = append(models, ModelInfo{ID: m.ID, OwnedBy: m.OwnedBy, ContextLength: contextLength})
+	}
+	return models, nil
+}
+
+// bearerAuth returns an auth function setting a bearer token
+func bearerAuth(apiKey string) func(*http.Request) {
+	return func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+}
+
+// azureResourceURL strips the deployment from an Azure OpenAI base URL
+func azureResourceURL(baseURL string) string {
+	if i := strings.Index(baseURL, "/deployments/"); i >= 0 {
+		return baseURL[:i]
+	}
+	return strings.TrimSuffix(baseURL, "/")
+}
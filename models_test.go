@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestListModels(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/models" && r.Header.Get("Authorization") == "Bearer test-key":
+			w.Write([]byte(`{"object":"list","data":[{"id":"gpt-4o","owned_by":"openai"},{"id":"llama-3","owned_by":"vllm","max_model_len":8192}]}`))
+		case r.URL.Path == "/openai/deployments" && r.Header.Get("api-key") == "test-key":
+			w.Write([]byte(`{"data":[{"id":"chat","model":"gpt-4o","owner":"organization-owner"}]}`))
+		case r.URL.Path == "/cohere/models":
+			w.Write([]byte(`{"models":[{"name":"command-r-plus","context_length":128000}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	t.Run("openai with cache", func(t *testing.T) {
+		calls.Store(0)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+		for i := 0; i < 2; i++ {
+			models, err := client.ListModels(ctx)
+			if err != nil {
+				t.Fatalf("ListModels failed: %v", err)
+			}
+			if len(models) != 2 || models[0].ID != "gpt-4o" || models[0].Provider != ProviderOpenAI || models[1].ContextLength != 8192 {
+				t.Errorf("Unexpected models %+v", models)
+			}
+			models[0].ID = "mutated"
+		}
+		if calls.Load() != 1 {
+			t.Errorf("Expected the second call to be cached, got %d requests", calls.Load())
+		}
+	})
+
+	t.Run("ttl expiry", func(t *testing.T) {
+		calls.Store(0)
+		current := time.Now()
+		now = func() time.Time { return current }
+		defer func() { now = time.Now }()
+
+		client, _ := NewClient(Config{Provider: ProviderQwen, APIKey: "test-key", BaseURL: server.URL, ModelListTTL: time.Minute})
+		client.ListModels(ctx)
+		current = current.Add(2 * time.Minute)
+		client.ListModels(ctx)
+		if calls.Load() != 2 {
+			t.Errorf("Expected a refetch after the TTL, got %d requests", calls.Load())
+		}
+	})
+
+	t.Run("azure deployments", func(t *testing.T) {
+		client, _ := NewClient(Config{Provider: ProviderAzure, APIKey: "test-key", BaseURL: server.URL + "/openai/deployments/chat"})
+		models, err := client.ListModels(ctx)
+		if err != nil || len(models) != 1 || models[0].ID != "chat" || models[0].BaseModel != "gpt-4o" {
+			t.Errorf("Unexpected deployments %+v, %v", models, err)
+		}
+	})
+
+	t.Run("cohere", func(t *testing.T) {
+		client, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL + "/cohere"})
+		models, err := client.ListModels(ctx)
+		if err != nil || len(models) != 1 || models[0].ID != "command-r-plus" || models[0].ContextLength != 128000 {
+			t.Errorf("Unexpected models %+v, %v", models, err)
+		}
+	})
+
+	t.Run("errors are not cached", func(t *testing.T) {
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "wrong", BaseURL: server.URL, DisableBaseURLVersion: true})
+		if _, err := client.ListModels(ctx); err == nil {
+			t.Error("Expected an APIError")
+		}
+	})
+}
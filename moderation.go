@@ -0,0 +1,196 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ModerationRequest asks a moderation model to classify content
+type ModerationRequest struct {
+	// Inputs are moderated independently, one result each
+	Inputs []string `json:"inputs,omitempty"`
+
+	// Content is a single multimodal input (text and image URLs) for
+	// omni-moderation models, moderated as a whole into one result. It is
+	// sent instead of Inputs.
+	Content []ModerationContent `json:"content,omitempty"`
+
+	// Model override (optional, default omni-moderation-latest)
+	Model *string `json:"model,omitempty"`
+
+	// Extra HTTP headers for this request only. Applied after Config.Headers.
+	Headers map[string]string `json:"-"`
+}
+
+// ModerationContent is one part of a multimodal moderation input: either
+// Text or ImageURL (an https or data URL)
+type ModerationContent struct {
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+}
+
+// ModerationResult is the verdict for one input
+type ModerationResult struct {
+	Flagged bool `json:"flagged"`
+	// Categories maps each category (e.g. "harassment", "violence/graphic")
+	// to whether it was flagged
+	Categories map[string]bool `json:"categories"`
+	// CategoryScores maps each category to the model's confidence in [0, 1]
+	CategoryScores map[string]float64 `json:"category_scores"`
+	// CategoryInputTypes lists, per category, which input types ("text",
+	// "image") the verdict applies to (omni models only)
+	CategoryInputTypes map[string][]string `json:"category_input_types,omitempty"`
+}
+
+// FlaggedCategories returns the flagged categories in sorted order
+func (r ModerationResult) FlaggedCategories() []string {
+	var categories []string
+	for category, flagged := range r.Categories {
+		if flagged {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+// ModerationResponse holds one result per input, in input order
+type ModerationResponse struct {
+	Results      []ModerationResult `json:"results"`
+	Model        string             `json:"model"`
+	ResponseTime time.Duration      `json:"response_time"`
+
+	// RequestID is the X-Request-ID sent with the request (see WithRequestID)
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Flagged reports whether any input was flagged
+func (r *ModerationResponse) Flagged() bool {
+	for _, result := range r.Results {
+		if result.Flagged {
+			return true
+		}
+	}
+	return false
+}
+
+// Moderator is implemented by clients whose provider offers a moderation
+// endpoint; check Capabilities().Moderation or call Moderate
+type Moderator interface {
+	Moderate(ctx context.Context, request ModerationRequest) (*ModerationResponse, error)
+}
+
+// Moderate classifies content with client, failing with a CapabilityError
+// when its provider has no moderation endpoint
+func Moderate(ctx context.Context, client Client, request ModerationRequest) (*ModerationResponse, error) {
+	moderator, ok := client.(Moderator)
+	if !ok || !client.Capabilities().Moderation {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilityModeration}
+	}
+	return moderator.Moderate(ctx, request)
+}
+
+// moderationFunc performs a single provider moderation call
+type moderationFunc func(ctx context.Context, request ModerationRequest) (*ModerationResponse, error)
+
+// instrumentModeration runs a provider moderation call under the client
+// timeout and reports it like instrumentRerank does. Moderation is free, so
+// no usage is reported.
+func instrumentModeration(ctx context.Context, config Config, model string, request ModerationRequest, call moderationFunc) (*ModerationResponse, error) {
+	inputs := len(request.Inputs)
+	if len(request.Content) > 0 {
+		inputs = 1
+	}
+	if inputs == 0 {
+		return nil, fmt.Errorf("moderation request has no input")
+	}
+
+	startTime := time.Now()
+	response, err := withTimeout(ctx, config, "", 0, func(ctx context.Context) (*ModerationResponse, error) {
+		return call(ctx, request)
+	})
+	latency := time.Since(startTime)
+
+	if response != nil && response.Model != "" {
+		model = response.Model
+	}
+	observe(ctx, config, OperationModeration, model, latency, Usage{}, err)
+	if config.Logger != nil && config.Logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("provider", string(config.Provider)),
+			slog.String("model", model),
+			slog.Int("inputs", inputs),
+			slog.Duration("latency", latency),
+		}
+		if response != nil {
+			attrs = append(attrs, slog.Bool("flagged", response.Flagged()))
+		}
+		logResult(ctx, config, "llm moderation", attrs, err)
+	}
+	return response, err
+}
+
+// moderationPayload builds the body of an OpenAI /moderations request
+func moderationPayload(request ModerationRequest, model string) map[string]interface{} {
+	payload := map[string]interface{}{"model": model}
+	if len(request.Content) == 0 {
+		payload["input"] = request.Inputs
+		return payload
+	}
+
+	parts := make([]map[string]interface{}, 0, len(request.Content))
+	for _, content := range request.Content {
+		if content.ImageURL != "" {
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]string{"url": content.ImageURL},
+			})
+			continue
+		}
+		parts = append(parts, map[string]interface{}{"type": "text", "text": content.Text})
+	}
+	payload["input"] = parts
+	return payload
+}
+
+// parseModerations parses an OpenAI /moderations response
+func parseModerations(body []byte) (*ModerationResponse, error) {
+	var apiResp struct {
+		Model   string `json:"model"`
+		Results []struct {
+			Flagged                   bool                `json:"flagged"`
+			Categories                map[string]bool     `json:"categories"`
+			CategoryScores            map[string]float64  `json:"category_scores"`
+			CategoryAppliedInputTypes map[string][]string `json:"category_applied_input_types"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal moderation response: %w", err)
+	}
+	if len(apiResp.Results) == 0 {
+		return nil, fmt.Errorf("no results in moderation response")
+	}
+
+	results := make([]ModerationResult, len(apiResp.Results))
+	for i, result := range apiResp.Results {
+		results[i] = ModerationResult{
+			Flagged:            result.Flagged,
+			Categories:         result.Categories,
+			CategoryScores:     result.CategoryScores,
+			CategoryInputTypes: result.CategoryAppliedInputTypes,
+		}
+	}
+	return &ModerationResponse{Results: results, Model: apiResp.Model}, nil
+}
+
+// getModerationModel returns the moderation model to use for the request
+func getModerationModel(override *string) string {
+	if override != nil {
+		return *override
+	}
+	return "omni-moderation-latest"
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModerate(t *testing.T) {
+	var requests int
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/moderations" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		payload = nil
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"model":"omni-moderation-2024-09-26","results":[
+			{"flagged":false,"categories":{"violence":false},"category_scores":{"violence":0.01}},
+			{"flagged":true,"categories":{"violence":true,"harassment":true},"category_scores":{"violence":0.9},
+			 "category_applied_input_types":{"violence":["text","image"]}}]}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	resp, err := Moderate(ctx, client, ModerationRequest{Inputs: []string{"hello", "threat"}})
+	if err != nil {
+		t.Fatalf("Moderate failed: %v", err)
+	}
+	if payload["model"] != "omni-moderation-latest" || len(payload["input"].([]interface{})) != 2 {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+	if !resp.Flagged() || resp.Results[0].Flagged || resp.Model != "omni-moderation-2024-09-26" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if got := resp.Results[1].FlaggedCategories(); len(got) != 2 || got[0] != "harassment" || got[1] != "violence" {
+		t.Errorf("Unexpected flagged categories %v", got)
+	}
+	if types := resp.Results[1].CategoryInputTypes["violence"]; len(types) != 2 || types[1] != "image" {
+		t.Errorf("Unexpected input types %v", types)
+	}
+
+	// multimodal content is sent as typed parts
+	_, err = Moderate(ctx, client, ModerationRequest{Content: []ModerationContent{
+		{Text: "caption"},
+		{ImageURL: "https://example.com/a.png"},
+	}})
+	if err != nil {
+		t.Fatalf("Moderate failed: %v", err)
+	}
+	parts := payload["input"].([]interface{})
+	image := parts[1].(map[string]interface{})
+	if parts[0].(map[string]interface{})["text"] != "caption" || image["type"] != "image_url" || image["image_url"].(map[string]interface{})["url"] != "https://example.com/a.png" {
+		t.Errorf("Unexpected multimodal input %v", parts)
+	}
+
+	// providers without moderation fail without reaching the server
+	requests = 0
+	for _, provider := range []Provider{ProviderDeepSeek, ProviderCohere} {
+		other, _ := NewClient(Config{Provider: provider, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+		_, err := Moderate(ctx, other, ModerationRequest{Inputs: []string{"hi"}})
+		var capErr *CapabilityError
+		if !errors.Is(err, ErrUnsupported) || !errors.As(err, &capErr) || capErr.Capability != CapabilityModeration {
+			t.Errorf("%s: expected a moderation CapabilityError, got %v", provider, err)
+		}
+	}
+	if requests != 0 {
+		t.Errorf("Unsupported moderation reached the server")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"time"
 )
@@ -14,6 +15,9 @@ import (
 type openAIClient struct {
 	config     Config
 	httpClient *http.Client
+	requests   *requestTracker
+	models     *modelCache
+	*liveConfig
 }
 
 // newOpenAIClient creates a new OpenAI-compatible client
@@ -33,6 +37,14 @@ func newOpenAIClient(config Config) (*openAIClient, error) {
 			config.BaseURL = "https://api.openai.com/v1"
 		}
 	}
+	version := ""
+	if config.Provider != ProviderDeepSeek {
+		version = "/v1"
+	}
+	config, err := normalizeBaseURL(config, version)
+	if err != nil {
+		return nil, err
+	}
 
 	if config.Timeout == 0 {
 		config.Timeout = 30 * time.Second
@@ -49,23 +61,61 @@ func newOpenAIClient(config Config) (*openAIClient, error) {
 			config.DefaultModel = "gpt-3.5-turbo"
 		}
 	}
+	config = withEmbeddingDefault(config, "text-embedding-3-small")
 
-	httpClient := &http.Client{
-		Timeout: config.Timeout,
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
 	}
+	httpClient, requests := trackRequests(config, httpClient)
 
 	return &openAIClient{
 		config:     config,
 		httpClient: httpClient,
+		requests:   requests,
+		models:     &modelCache{},
+		liveConfig: newLiveConfig(config),
 	}, nil
 }
 
+// current returns a copy of the client with the configuration as of now, so
+// that a call sees one consistent set of defaults
+func (c *openAIClient) current() *openAIClient {
+	snapshot := *c
+	snapshot.config = c.load()
+	return &snapshot
+}
+
+// WithModel returns a view of the client using model by default
+func (c *openAIClient) WithModel(model string) Client {
+	return c.WithDefaults(WithModel(model))
+}
+
+// WithDefaults returns a view of the client with the defaults set by opts
+func (c *openAIClient) WithDefaults(opts ...RequestOption) Client {
+	view := c.current()
+	view.config = applyDefaults(view.config, opts)
+	view.httpClient, view.requests = c.requests.view(c.httpClient)
+	view.liveConfig = newLiveConfig(view.config)
+	return view
+}
+
 // Generate sends a request to the LLM and returns the response
 func (c *openAIClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentGenerate(ctx, c.config, c.getModel, request, c.generate)
+}
+
+// generate performs the chat call without instrumentation
+func (c *openAIClient) generate(ctx context.Context, request Request) (*Response, error) {
 	startTime := time.Now()
 
 	// Prepare the request payload
-	payload := c.buildPayload(request)
+	var payload interface{} = c.buildPayload(request)
+	path, parse := "/chat/completions", parseChatCompletion
+	if c.useResponsesAPI() {
+		payload, path, parse = c.buildResponsesPayload(request), "/responses", parseResponse
+	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -73,46 +123,56 @@ func (c *openAIClient) Generate(ctx context.Context, request Request) (*Response
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonPayload))
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, path), jsonPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setIdempotencyKey(ctx, req, c.config.Provider, request.IdempotencyKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
 
 	// Send request
-	resp, err := c.httpClient.Do(req)
+	resp, err := sendRequest(c.httpClient, c.config, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("LLM API error %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(c.config, "LLM API error", resp, body)
 	}
 
-	// Parse response
+	response, err := parse(body)
+	if err != nil {
+		return nil, err
+	}
+	response.ResponseTime = time.Since(startTime)
+	response.RequestID = req.Header.Get(requestIDHeader)
+	return response, nil
+}
+
+// parseChatCompletion parses an OpenAI-compatible chat completion body
+func parseChatCompletion(body []byte) (*Response, error) {
 	var apiResp struct {
 		Choices []struct {
 			Message struct {
-				Role             string `json:"role"`
-				Content          string `json:"content"`
-				ReasoningContent string `json:"reasoning_content"` // DeepSeek thinking mode
+				Role             string         `json:"role"`
+				Content          string         `json:"content"`
+				ReasoningContent string         `json:"reasoning_content"` // DeepSeek thinking mode
+				ToolCalls        []chatToolCall `json:"tool_calls"`
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
-		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
-		} `json:"usage"`
+		Model string      `json:"model"`
+		Usage openAIUsage `json:"usage"`
 	}
 
 	if err := json.Unmarshal(body, &apiResp); err != nil {
@@ -120,21 +180,154 @@ func (c *openAIClient) Generate(ctx context.Context, request Request) (*Response
 	}
 
 	if len(apiResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in LLM response")
+		return nil, &EmptyResponseError{Reason: "no choices", Body: body}
+	}
+	choice := apiResp.Choices[0]
+	if len(choice.Message.ToolCalls) == 0 && emptyCompletion(choice.Message.Content, choice.Message.ReasoningContent, choice.FinishReason) {
+		return nil, &EmptyResponseError{Reason: "empty content", Body: body}
 	}
-
-	responseTime := time.Since(startTime)
 
 	return &Response{
-		Content:          apiResp.Choices[0].Message.Content,
-		Role:             MessageRole(apiResp.Choices[0].Message.Role),
+		Content:          choice.Message.Content,
+		Role:             MessageRole(choice.Message.Role),
 		TokensUsed:       apiResp.Usage.TotalTokens,
-		ResponseTime:     responseTime,
-		FinishReason:     apiResp.Choices[0].FinishReason,
-		ReasoningContent: apiResp.Choices[0].Message.ReasoningContent,
+		Usage:            apiResp.Usage.usage(),
+		Model:            apiResp.Model,
+		FinishReason:     normalizeFinishReason(openAIFinishReasons, choice.FinishReason),
+		RawFinishReason:  choice.FinishReason,
+		ReasoningContent: choice.Message.ReasoningContent,
+		ToolCalls:        chatToolCalls(choice.Message.ToolCalls),
 	}, nil
 }
 
+// openAIUsage is the usage block of OpenAI-compatible chat responses
+type openAIUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	TotalTokens         int `json:"total_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	PromptCacheHitTokens int `json:"prompt_cache_hit_tokens"` // DeepSeek
+}
+
+// usage converts the block to Usage
+func (u openAIUsage) usage() Usage {
+	cachedTokens := u.PromptTokensDetails.CachedTokens
+	if cachedTokens == 0 {
+		cachedTokens = u.PromptCacheHitTokens
+	}
+	return Usage{
+		PromptTokens:     u.PromptTokens,
+		CompletionTokens: u.CompletionTokens,
+		TotalTokens:      u.TotalTokens,
+		CachedTokens:     cachedTokens,
+	}
+}
+
+// GenerateStream streams a chat response (see the package-level GenerateStream)
+func (c *openAIClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentStream(ctx, c.config, c.getModel, request, c.stream)
+}
+
+// stream opens a chat completion event stream, or a Responses API one with
+// Config.UseResponsesAPI
+func (c *openAIClient) stream(ctx context.Context, request Request) (*providerStream, error) {
+	var payload interface{}
+	path, decode := "/chat/completions", decodeChatCompletionChunk
+	if c.useResponsesAPI() {
+		responses := c.buildResponsesPayload(request)
+		responses["stream"] = true
+		payload, path, decode = responses, "/responses", decodeResponseEvent
+	} else {
+		streaming := true
+		chat := c.buildPayload(request)
+		chat.Stream = &streaming
+		chat.StreamOptions = &chatStreamOptions{IncludeUsage: true}
+		payload = chat
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, path), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setIdempotencyKey(ctx, req, c.config.Provider, request.IdempotencyKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	body, err := openSSE(c.httpClient, c.config, req, "LLM API error")
+	if err != nil {
+		return nil, err
+	}
+	return &providerStream{body: body, requestID: req.Header.Get(requestIDHeader), decode: decode}, nil
+}
+
+// decodeChatCompletionChunk decodes one chat.completion.chunk event. With
+// stream_options.include_usage the usage arrives in a last chunk without
+// choices, followed by [DONE].
+func decodeChatCompletionChunk(event sseEvent) (StreamChunk, error) {
+	if string(event.Data) == "[DONE]" {
+		return StreamChunk{}, io.EOF
+	}
+
+	var apiChunk struct {
+		Choices []struct {
+			Delta struct {
+				Content          string `json:"content"`
+				ReasoningContent string `json:"reasoning_content"` // DeepSeek thinking mode
+				ToolCalls        []struct {
+					Index    int    `json:"index"`
+					ID       string `json:"id"`
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"delta"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage *openAIUsage `json:"usage"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(event.Data, &apiChunk); err != nil {
+		return StreamChunk{}, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+	}
+	if apiChunk.Error != nil {
+		return StreamChunk{}, fmt.Errorf("stream error: %s", apiChunk.Error.Message)
+	}
+
+	var chunk StreamChunk
+	if len(apiChunk.Choices) > 0 {
+		chunk.Content = apiChunk.Choices[0].Delta.Content
+		chunk.ReasoningContent = apiChunk.Choices[0].Delta.ReasoningContent
+		for _, call := range apiChunk.Choices[0].Delta.ToolCalls {
+			chunk.ToolCallDeltas = append(chunk.ToolCallDeltas, ToolCallDelta{
+				Index:     call.Index,
+				ID:        call.ID,
+				Name:      call.Function.Name,
+				Arguments: call.Function.Arguments,
+			})
+		}
+		chunk.RawFinishReason = apiChunk.Choices[0].FinishReason
+		chunk.FinishReason = normalizeFinishReason(openAIFinishReasons, chunk.RawFinishReason)
+	}
+	if apiChunk.Usage != nil {
+		usage := apiChunk.Usage.usage()
+		chunk.Usage = &usage
+	}
+	return chunk, nil
+}
+
 // GenerateWithHistory generates a response using chat history
 func (c *openAIClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
 	request := BuildChatRequest(history.GetMessages(), userMessage)
@@ -144,35 +337,81 @@ func (c *openAIClient) GenerateWithHistory(ctx context.Context, history ChatHist
 	return c.Generate(ctx, request)
 }
 
-// Close closes the client
+// Close stops new requests, waits up to Config.CloseTimeout for those in
+// flight and closes the client's idle connections
 func (c *openAIClient) Close() error {
-	// HTTP client doesn't need explicit closing
-	return nil
+	return c.requests.close(c.config.CloseTimeout)
 }
 
-// GetConfig returns the client configuration
+// GetConfig returns a copy of the client configuration with the API key masked
 func (c *openAIClient) GetConfig() Config {
-	return c.config
+	return maskedConfig(c.load())
+}
+
+// GetConfigWithSecrets returns a copy of the client configuration including the API key
+func (c *openAIClient) GetConfigWithSecrets() Config {
+	return c.load().Clone()
+}
+
+// CountTokens estimates the prompt tokens of request using Config.Tokenizer
+func (c *openAIClient) CountTokens(request Request) int {
+	c = c.current()
+	return countRequestTokens(c.config, c.getModel(request.Model), request)
+}
+
+// Capabilities reports what the client supports for its provider
+func (c *openAIClient) Capabilities() Capabilities {
+	return providerCapabilities(c.config.Provider)
+}
+
+// ListModels lists the models of the OpenAI-compatible /models endpoint
+func (c *openAIClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	c = c.current()
+	return c.models.get(ctx, c.config, c.fetchModels)
+}
+
+// fetchModels calls the /models endpoint without caching
+func (c *openAIClient) fetchModels(ctx context.Context) ([]ModelInfo, error) {
+	return listOpenAIModels(ctx, c.httpClient, c.config, bearerAuth(c.config.APIKey))
+}
+
+// Ping verifies credentials and reachability using Config.PingStrategy
+func (c *openAIClient) Ping(ctx context.Context) error {
+	c = c.current()
+	return ping(ctx, c.config, c.fetchModels, c.generate)
+}
+
+// Warmup opens idle connections to the provider ahead of the first call
+func (c *openAIClient) Warmup(ctx context.Context) error {
+	c = c.current()
+	return warmup(ctx, c.httpClient, c.config)
 }
 
 // CreateEmbedding generates embeddings for the given text(s)
 func (c *openAIClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	startTime := time.Now()
+	c = c.current()
+	return instrumentEmbedding(ctx, c.config, c.getEmbeddingModel(request.Model), request, c.createEmbedding)
+}
 
-	// Determine embedding model
-	embeddingModel := "text-embedding-3-small"
-	if request.Model != nil {
-		embeddingModel = *request.Model
-	} else if c.config.Provider == ProviderOpenAI {
-		// Use newer model for OpenAI
-		embeddingModel = "text-embedding-3-small"
+// createEmbedding performs the embedding call without instrumentation
+func (c *openAIClient) createEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	if !c.Capabilities().Embeddings {
+		return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityEmbeddings}
 	}
+	startTime := time.Now()
 
 	// Prepare the request payload
 	payload := map[string]interface{}{
-		"model": embeddingModel,
+		"model": c.getEmbeddingModel(request.Model),
 		"input": request.Input,
 	}
+	if request.Dimensions != nil {
+		payload["dimensions"] = *request.Dimensions
+	}
+	if !c.config.DisableBase64Embeddings {
+		// Roughly half the size of JSON floats and much faster to decode
+		payload["encoding_format"] = "base64"
+	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
@@ -180,98 +419,466 @@ func (c *openAIClient) CreateEmbedding(ctx context.Context, request EmbeddingReq
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.BaseURL+"/embeddings", bytes.NewBuffer(jsonPayload))
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/embeddings"), jsonPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create embedding request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setIdempotencyKey(ctx, req, c.config.Provider, "")
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
 
 	// Send request
-	resp, err := c.httpClient.Do(req)
+	resp, err := sendRequest(c.httpClient, c.config, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send embedding request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	// Read response
-	body, err := io.ReadAll(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, err := readBody(c.config, resp, defaultMaxEmbeddingResponseBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedding response: %w", err)
+		}
+		return nil, newAPIError(c.config, "Embedding API error", resp, body)
+	}
+
+	// Decode the (possibly very large) response as it arrives
+	body, err := bodyReader(c.config, resp, defaultMaxEmbeddingResponseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read embedding response: %w", err)
 	}
+	response, err := parseOpenAIEmbeddings(body, request.AsFloat32)
+	if err != nil {
+		return nil, err
+	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// Moderate classifies content with the /moderations endpoint
+func (c *openAIClient) Moderate(ctx context.Context, request ModerationRequest) (*ModerationResponse, error) {
+	c = c.current()
+	return instrumentModeration(ctx, c.config, getModerationModel(request.Model), request, c.moderate)
+}
+
+// moderate performs the moderation call without instrumentation
+func (c *openAIClient) moderate(ctx context.Context, request ModerationRequest) (*ModerationResponse, error) {
+	if !c.Capabilities().Moderation {
+		return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityModeration}
+	}
+	startTime := time.Now()
+
+	jsonPayload, err := json.Marshal(moderationPayload(request, getModerationModel(request.Model)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal moderation request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/moderations"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create moderation request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send moderation request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation response: %w", err)
+	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Embedding API error %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(c.config, "Moderation API error", resp, body)
 	}
 
-	// Parse response
-	var apiResp struct {
-		Data []struct {
-			Embedding []float64 `json:"embedding"`
-			Index     int       `json:"index"`
-		} `json:"data"`
-		Model string `json:"model"`
-		Usage struct {
-			PromptTokens int `json:"prompt_tokens"`
-			TotalTokens  int `json:"total_tokens"`
-		} `json:"usage"`
+	response, err := parseModerations(body)
+	if err != nil {
+		return nil, err
 	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
 
-	if err := json.Unmarshal(body, &apiResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal embedding response: %w", err)
+// Transcribe converts speech to text with the /audio/transcriptions
+// endpoint (OpenAI, or Groq and other compatible servers via BaseURL)
+func (c *openAIClient) Transcribe(ctx context.Context, request TranscriptionRequest) (*TranscriptionResponse, error) {
+	c = c.current()
+	return instrumentTranscription(ctx, c.config, getTranscriptionModel(request.Model), request, c.transcribe)
+}
+
+// transcribe performs the transcription call without instrumentation
+func (c *openAIClient) transcribe(ctx context.Context, request TranscriptionRequest) (*TranscriptionResponse, error) {
+	if !c.Capabilities().Transcription {
+		return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityTranscription}
 	}
+	startTime := time.Now()
+	model := getTranscriptionModel(request.Model)
 
-	if len(apiResp.Data) == 0 {
-		return nil, fmt.Errorf("no embeddings in response")
+	req, err := newTranscriptionRequest(ctx, endpointURL(c.config, "/audio/transcriptions"), request, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transcription request: %w", err)
 	}
 
-	// Extract embeddings in order
-	embeddings := make([][]float64, len(apiResp.Data))
-	for _, item := range apiResp.Data {
-		if item.Index >= len(embeddings) {
-			return nil, fmt.Errorf("invalid embedding index: %d", item.Index)
-		}
-		embeddings[item.Index] = item.Embedding
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send transcription request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	responseTime := time.Since(startTime)
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read transcription response: %w", err)
+	}
 
-	return &EmbeddingResponse{
-		Embeddings:   embeddings,
-		Model:        apiResp.Model,
-		TokensUsed:   apiResp.Usage.TotalTokens,
-		ResponseTime: responseTime,
-	}, nil
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, transcriptionError(c.config, "Transcription API error", resp, body)
+	}
+
+	response, err := parseTranscription(body, request.ResponseFormat, model)
+	if err != nil {
+		return nil, err
+	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
 }
 
-// buildPayload builds the request payload for OpenAI API
-func (c *openAIClient) buildPayload(request Request) map[string]interface{} {
+// Speak streams speech audio from the /audio/speech endpoint
+func (c *openAIClient) Speak(ctx context.Context, request SpeechRequest) (io.ReadCloser, error) {
+	c = c.current()
+	return instrumentSpeech(ctx, c.config, getSpeechModel(request.Model), request, c.speak)
+}
+
+// speak starts the speech call without instrumentation and returns the
+// response body unread
+func (c *openAIClient) speak(ctx context.Context, request SpeechRequest) (io.ReadCloser, error) {
+	if !c.Capabilities().Speech {
+		return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilitySpeech}
+	}
+
 	payload := map[string]interface{}{
-		"model":    c.getModel(request.Model),
-		"messages": c.convertMessages(request.Messages),
-		"stream":   request.Stream,
+		"model": getSpeechModel(request.Model),
+		"input": request.Input,
+		"voice": request.Voice,
+	}
+	if request.Voice == "" {
+		payload["voice"] = "alloy"
+	}
+	if request.Format != "" {
+		payload["response_format"] = request.Format
+	}
+	if request.Speed != nil {
+		payload["speed"] = *request.Speed
+	}
+
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal speech request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/audio/speech"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create speech request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	// Audio does not compress; asking for identity keeps the body streamable
+	req.Header.Set("Accept-Encoding", "identity")
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send speech request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, err := readBody(c.config, resp, defaultMaxResponseBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read speech response: %w", err)
+		}
+		return nil, newAPIError(c.config, "Speech API error", resp, body)
+	}
+	return resp.Body, nil
+}
+
+// GenerateImage generates images with the /images/generations endpoint
+// (OpenAI, or gateways such as Together and Fireworks via BaseURL)
+func (c *openAIClient) GenerateImage(ctx context.Context, request ImageRequest) (*ImageResponse, error) {
+	c = c.current()
+	return instrumentImage(ctx, c.config, getImageModel(c.config.Provider, request.Model), request, c.generateImage)
+}
+
+// generateImage performs the image call without instrumentation
+func (c *openAIClient) generateImage(ctx context.Context, request ImageRequest) (*ImageResponse, error) {
+	if !c.Capabilities().ImageGeneration {
+		return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityImageGeneration}
+	}
+	startTime := time.Now()
+	model := getImageModel(c.config.Provider, request.Model)
+
+	jsonPayload, err := json.Marshal(imagePayload(request, model))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal image request: %w", err)
+	}
+
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/images/generations"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create image request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send image request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// b64_json images are large; allow the embedding-sized limit
+	body, err := readBody(c.config, resp, defaultMaxEmbeddingResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.config, "Image API error", resp, body)
+	}
+
+	response, err := parseImages(body, model)
+	if err != nil {
+		return nil, err
+	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// CreateBatch uploads requests as a JSONL file through the Files API and
+// starts a chat completion batch with a 24h completion window
+func (c *openAIClient) CreateBatch(ctx context.Context, requests []BatchRequest) (*BatchJob, error) {
+	c = c.current()
+	if !c.Capabilities().Batch {
+		return nil, &CapabilityError{Provider: c.config.Provider, Capability: CapabilityBatch}
+	}
+	input, err := encodeBatchInput(requests, func(request Request) chatPayload {
+		return c.buildPayload(withConfigDefaults(c.config, request))
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Add parameters if set
-	if request.Temperature != nil {
-		payload["temperature"] = *request.Temperature
-	} else if c.config.DefaultTemperature != nil {
-		payload["temperature"] = *c.config.DefaultTemperature
+	return withTimeout(ctx, c.config, "", 0, func(ctx context.Context) (*BatchJob, error) {
+		fileID, err := c.uploadFile(ctx, "batch", "batch.jsonl", input)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := json.Marshal(map[string]interface{}{
+			"input_file_id":     fileID,
+			"endpoint":          "/v1/chat/completions",
+			"completion_window": "24h",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal batch request: %w", err)
+		}
+		req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/batches"), payload)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch request: %w", err)
+		}
+		return c.batchJob(req)
+	})
+}
+
+// GetBatch returns the current state of a batch job
+func (c *openAIClient) GetBatch(ctx context.Context, id string) (*BatchJob, error) {
+	c = c.current()
+	return withTimeout(ctx, c.config, "", 0, func(ctx context.Context) (*BatchJob, error) {
+		req, err := newGetRequest(ctx, endpointURL(c.config, "/batches/"+id))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch request: %w", err)
+		}
+		return c.batchJob(req)
+	})
+}
+
+// CancelBatch asks the provider to stop a batch job
+func (c *openAIClient) CancelBatch(ctx context.Context, id string) (*BatchJob, error) {
+	c = c.current()
+	return withTimeout(ctx, c.config, "", 0, func(ctx context.Context) (*BatchJob, error) {
+		req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/batches/"+id+"/cancel"), nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create batch request: %w", err)
+		}
+		return c.batchJob(req)
+	})
+}
+
+// BatchResults downloads and parses the output and error files of a
+// finished batch job. Failed requests carry an *APIError in their result.
+func (c *openAIClient) BatchResults(ctx context.Context, job *BatchJob) (map[string]BatchResult, error) {
+	c = c.current()
+	if !job.Status.Done() {
+		return nil, fmt.Errorf("batch %s is still %s", job.ID, job.Status)
+	}
+	if job.OutputFileID == "" && job.ErrorFileID == "" {
+		return nil, &BatchJobError{ID: job.ID, Status: job.Status, Errors: job.Errors}
 	}
 
-	if request.MaxTokens != nil {
-		payload["max_tokens"] = *request.MaxTokens
-	} else if c.config.DefaultMaxTokens != nil {
-		payload["max_tokens"] = *c.config.DefaultMaxTokens
+	results := make(map[string]BatchResult, job.RequestCounts.Total)
+	for _, fileID := range []string{job.OutputFileID, job.ErrorFileID} {
+		if fileID == "" {
+			continue
+		}
+		data, err := withTimeout(ctx, c.config, "", 0, func(ctx context.Context) ([]byte, error) {
+			req, err := newGetRequest(ctx, endpointURL(c.config, "/files/"+fileID+"/content"))
+			if err != nil {
+				return nil, fmt.Errorf("failed to create file request: %w", err)
+			}
+			return c.do(req, defaultMaxBatchFileBytes, "Files API error")
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to download batch file %s: %w", fileID, err)
+		}
+		if err := parseBatchOutput(c.config, data, results); err != nil {
+			return nil, err
+		}
 	}
+	return results, nil
+}
 
-	if request.TopP != nil {
-		payload["top_p"] = *request.TopP
-	} else if c.config.DefaultTopP != nil {
-		payload["top_p"] = *c.config.DefaultTopP
+// uploadFile uploads data through the Files API and returns its file ID
+func (c *openAIClient) uploadFile(ctx context.Context, purpose, filename string, data []byte) (string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if err := writer.WriteField("purpose", purpose); err != nil {
+		return "", err
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
 	}
 
+	req, err := http.NewRequestWithContext(ctx, "POST", endpointURL(c.config, "/files"), bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create file upload: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	body, err := c.do(req, defaultMaxResponseBytes, "Files API error")
+	if err != nil {
+		return "", fmt.Errorf("failed to upload batch input: %w", err)
+	}
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &file); err != nil {
+		return "", fmt.Errorf("failed to unmarshal file response: %w", err)
+	}
+	return file.ID, nil
+}
+
+// batchJob sends a Batch API request and parses the returned batch
+func (c *openAIClient) batchJob(req *http.Request) (*BatchJob, error) {
+	body, err := c.do(req, defaultMaxResponseBytes, "Batch API error")
+	if err != nil {
+		return nil, err
+	}
+
+	var apiResp struct {
+		ID            string             `json:"id"`
+		Status        BatchStatus        `json:"status"`
+		InputFileID   string             `json:"input_file_id"`
+		OutputFileID  string             `json:"output_file_id"`
+		ErrorFileID   string             `json:"error_file_id"`
+		RequestCounts BatchRequestCounts `json:"request_counts"`
+		Errors        *struct {
+			Data []struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+				Line    *int   `json:"line"`
+			} `json:"data"`
+		} `json:"errors"`
+		CreatedAt   int64 `json:"created_at"`
+		ExpiresAt   int64 `json:"expires_at"`
+		CompletedAt int64 `json:"completed_at"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+	}
+
+	job := &BatchJob{
+		ID:            apiResp.ID,
+		Status:        apiResp.Status,
+		InputFileID:   apiResp.InputFileID,
+		OutputFileID:  apiResp.OutputFileID,
+		ErrorFileID:   apiResp.ErrorFileID,
+		RequestCounts: apiResp.RequestCounts,
+		CreatedAt:     unixTime(apiResp.CreatedAt),
+		ExpiresAt:     unixTime(apiResp.ExpiresAt),
+		CompletedAt:   unixTime(apiResp.CompletedAt),
+	}
+	if apiResp.Errors != nil {
+		for _, e := range apiResp.Errors.Data {
+			message := e.Code + ": " + e.Message
+			if e.Line != nil {
+				message = fmt.Sprintf("line %d: %s", *e.Line, message)
+			}
+			job.Errors = append(job.Errors, message)
+		}
+	}
+	return job, nil
+}
+
+// do authorizes and sends req and returns the body of a successful response
+func (c *openAIClient) do(req *http.Request, maxBytes int64, errorPrefix string) ([]byte, error) {
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(req.Context(), req)
+	applyHeaders(req, c.config, nil)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readBody(c.config, resp, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, newAPIError(c.config, errorPrefix, resp, body)
+	}
+	return body, nil
+}
+
+// buildPayload builds the request payload for OpenAI API
+func (c *openAIClient) buildPayload(request Request) chatPayload {
+	payload := newChatPayload(c.config, c.getModel(request.Model), request)
+
 	// DeepSeek thinking mode (thinker vs instruct)
 	if c.config.Provider == ProviderDeepSeek {
 		thinkingEnabled := c.config.DeepSeekThinkingEnabled
@@ -279,15 +886,9 @@ func (c *openAIClient) buildPayload(request Request) map[string]interface{} {
 			thinkingEnabled = *request.DeepSeekThinking
 		}
 		if thinkingEnabled {
-			payload["thinking"] = map[string]string{"type": "enabled"}
+			payload.Thinking = &chatThinking{Type: "enabled"}
 		}
 	}
-
-	// Add extra parameters
-	for k, v := range request.ExtraParams {
-		payload[k] = v
-	}
-
 	return payload
 }
 
@@ -299,17 +900,7 @@ func (c *openAIClient) getModel(override *string) string {
 	return c.config.DefaultModel
 }
 
-// convertMessages converts internal Message format to OpenAI format
-func (c *openAIClient) convertMessages(messages []Message) []map[string]interface{} {
-	result := make([]map[string]interface{}, len(messages))
-	for i, msg := range messages {
-		result[i] = map[string]interface{}{
-			"role":    string(msg.Role),
-			"content": msg.Content,
-		}
-		if msg.Name != "" {
-			result[i]["name"] = msg.Name
-		}
-	}
-	return result
+// getEmbeddingModel returns the embedding model to use for the request
+func (c *openAIClient) getEmbeddingModel(override *string) string {
+	return resolveEmbeddingModel(c.config, override)
 }
@@ -0,0 +1,123 @@
+package llm
+
+import "time"
+
+// RequestOption sets fields of a Request. Options only set fields, so they
+// compose with struct literals and Set* calls, and a []RequestOption can be
+// shared as a set of defaults.
+type RequestOption func(*Request)
+
+// NewRequest builds a Request from options, applied in order
+func NewRequest(opts ...RequestOption) Request {
+	var request Request
+	request.Apply(opts...)
+	return request
+}
+
+// Apply applies options to the request in order
+func (r *Request) Apply(opts ...RequestOption) {
+	for _, opt := range opts {
+		opt(r)
+	}
+}
+
+// WithSystem appends a system message
+func WithSystem(content string) RequestOption {
+	return func(r *Request) {
+		r.Messages = append(r.Messages, Message{Role: RoleSystem, Content: content})
+	}
+}
+
+// WithCachedSystem appends a system message marked with CacheControl, for
+// large static system prompts reused across calls
+func WithCachedSystem(content string) RequestOption {
+	return func(r *Request) {
+		r.Messages = append(r.Messages, Message{Role: RoleSystem, Content: content, CacheControl: true})
+	}
+}
+
+// WithUser appends a user message
+func WithUser(content string) RequestOption {
+	return func(r *Request) {
+		r.Messages = append(r.Messages, Message{Role: RoleUser, Content: content})
+	}
+}
+
+// WithAssistant appends an assistant message
+func WithAssistant(content string) RequestOption {
+	return func(r *Request) {
+		r.Messages = append(r.Messages, Message{Role: RoleAssistant, Content: content})
+	}
+}
+
+// WithMessages appends a copy of messages, e.g. a full history
+func WithMessages(messages ...Message) RequestOption {
+	return func(r *Request) {
+		r.Messages = append(r.Messages, messages...)
+	}
+}
+
+// WithTemperature sets the sampling temperature
+func WithTemperature(temperature float64) RequestOption {
+	return func(r *Request) {
+		r.Temperature = &temperature
+	}
+}
+
+// WithMaxTokens sets the completion token limit
+func WithMaxTokens(tokens int) RequestOption {
+	return func(r *Request) {
+		r.MaxTokens = &tokens
+	}
+}
+
+// WithTopP sets nucleus sampling
+func WithTopP(topP float64) RequestOption {
+	return func(r *Request) {
+		r.TopP = &topP
+	}
+}
+
+// WithTopK sets top-k sampling (providers that support it)
+func WithTopK(topK int) RequestOption {
+	return func(r *Request) {
+		r.TopK = &topK
+	}
+}
+
+// WithTimeout bounds the call instead of Config.Timeout
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(r *Request) {
+		r.Timeout = timeout
+	}
+}
+
+// WithModel overrides the client's default model
+func WithModel(model string) RequestOption {
+	return func(r *Request) {
+		r.Model = &model
+	}
+}
+
+// WithExtraParam sets a provider-specific payload parameter
+func WithExtraParam(key string, value interface{}) RequestOption {
+	return func(r *Request) {
+		if r.ExtraParams == nil {
+			r.ExtraParams = make(map[string]interface{})
+		}
+		r.ExtraParams[key] = value
+	}
+}
+
+// WithJSONMode asks the model to reply with a JSON object
+// (response_format: json_object)
+func WithJSONMode() RequestOption {
+	return WithExtraParam("response_format", map[string]interface{}{"type": "json_object"})
+}
+
+// WithDeepSeekThinking overrides Config.DeepSeekThinkingEnabled
+func WithDeepSeekThinking(enabled bool) RequestOption {
+	return func(r *Request) {
+		r.DeepSeekThinking = &enabled
+	}
+}
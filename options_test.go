@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewRequest(t *testing.T) {
+	defaults := []RequestOption{WithTemperature(0.2), WithModel("gpt-4o")}
+	history := []Message{{Role: RoleUser, Content: "Hi"}, {Role: RoleAssistant, Content: "Hello!"}}
+
+	request := NewRequest(append(defaults,
+		WithSystem("Be brief."),
+		WithMessages(history...),
+		WithUser("What's new?"),
+		WithMaxTokens(500),
+		WithJSONMode(),
+	)...)
+
+	wantMessages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Hi"},
+		{Role: RoleAssistant, Content: "Hello!"},
+		{Role: RoleUser, Content: "What's new?"},
+	}
+	if !reflect.DeepEqual(request.Messages, wantMessages) {
+		t.Errorf("Messages = %+v", request.Messages)
+	}
+	if *request.Temperature != 0.2 || *request.Model != "gpt-4o" || *request.MaxTokens != 500 {
+		t.Errorf("Unexpected parameters: %+v", request)
+	}
+	if !reflect.DeepEqual(request.ExtraParams["response_format"], map[string]interface{}{"type": "json_object"}) {
+		t.Errorf("Expected JSON mode, got %v", request.ExtraParams)
+	}
+
+	history[0].Content = "changed"
+	if request.Messages[1].Content != "Hi" {
+		t.Error("WithMessages must copy the messages")
+	}
+
+	// Options compose with existing fields
+	existing := BuildSimpleRequest("hello")
+	existing.Apply(WithTemperature(0.9))
+	if len(existing.Messages) != 1 || *existing.Temperature != 0.9 {
+		t.Errorf("Apply changed unrelated fields: %+v", existing)
+	}
+}
@@ -0,0 +1,197 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// chatPayload is the body of an OpenAI-compatible /chat/completions
+// request, shared by the OpenAI, DeepSeek, Azure and Qwen clients. Optional
+// parameters are pointers so unset ones are left to the provider.
+type chatPayload struct {
+	// Model is empty for Azure, which routes by deployment
+	Model         string             `json:"model,omitempty"`
+	Messages      []chatMessage      `json:"messages"`
+	Stream        *bool              `json:"stream,omitempty"`
+	StreamOptions *chatStreamOptions `json:"stream_options,omitempty"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	MaxTokens     *int               `json:"max_tokens,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	TopK          *int               `json:"top_k,omitempty"`
+	Thinking      *chatThinking      `json:"thinking,omitempty"`
+
+	// Extra holds Request.ExtraParams, merged in last so they override the
+	// fields above
+	Extra map[string]interface{} `json:"-"`
+}
+
+// chatMessage is one message of a chatPayload
+type chatMessage struct {
+	Role string `json:"role"`
+	// Content is a string, or []chatContentBlock where a provider needs
+	// per-block options
+	Content    interface{}    `json:"content"`
+	Name       string         `json:"name,omitempty"`
+	ToolCalls  []chatToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+}
+
+// chatToolCall is a function call of an assistant chatMessage or response
+type chatToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// chatContentBlock is a text content block of a chatMessage
+type chatContentBlock struct {
+	Type         string            `json:"type"`
+	Text         string            `json:"text"`
+	CacheControl *chatCacheControl `json:"cache_control,omitempty"`
+}
+
+// chatCacheControl marks a prompt-cache breakpoint
+type chatCacheControl struct {
+	Type string `json:"type"`
+}
+
+// chatStreamOptions asks for usage on the last chunk of a stream
+type chatStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// chatThinking enables DeepSeek's thinking mode
+type chatThinking struct {
+	Type string `json:"type"`
+}
+
+// newChatPayload builds the parameters every OpenAI-compatible client
+// sends: model, messages with their names, and temperature, max_tokens and
+// top_p from request or the config defaults
+func newChatPayload(config Config, model string, request Request) chatPayload {
+	messages := requestMessages(request)
+	payload := chatPayload{
+		Model:       model,
+		Messages:    make([]chatMessage, len(messages)),
+		Stream:      &request.Stream,
+		Temperature: orDefault(request.Temperature, config.DefaultTemperature),
+		MaxTokens:   orDefault(request.MaxTokens, config.DefaultMaxTokens),
+		TopP:        orDefault(request.TopP, config.DefaultTopP),
+		Extra:       request.ExtraParams,
+	}
+	for i, msg := range messages {
+		payload.Messages[i] = newChatMessage(msg)
+	}
+	return payload
+}
+
+// newChatMessage converts msg, with its tool calls or the ID of the call it
+// answers. An assistant message with only tool calls has null content.
+func newChatMessage(msg Message) chatMessage {
+	message := chatMessage{Role: string(msg.Role), Content: msg.Content, Name: msg.Name, ToolCallID: msg.ToolCallID}
+	for _, call := range msg.ToolCalls {
+		toolCall := chatToolCall{ID: call.ID, Type: "function"}
+		toolCall.Function.Name = call.Name
+		toolCall.Function.Arguments = call.arguments()
+		message.ToolCalls = append(message.ToolCalls, toolCall)
+	}
+	if len(message.ToolCalls) > 0 && msg.Content == "" {
+		message.Content = nil
+	}
+	return message
+}
+
+// chatToolCalls converts the tool calls of a chat completion
+func chatToolCalls(calls []chatToolCall) []ToolCall {
+	var result []ToolCall
+	for _, call := range calls {
+		result = append(result, ToolCall{ID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+	}
+	return result
+}
+
+// orDefault returns value, or fallback when value is nil
+func orDefault[T any](value, fallback *T) *T {
+	if value != nil {
+		return value
+	}
+	return fallback
+}
+
+// MarshalJSON encodes the payload with Extra merged in. Without Extra the
+// struct is encoded directly, in field order.
+func (p chatPayload) MarshalJSON() ([]byte, error) {
+	type plain chatPayload
+	data, err := json.Marshal(plain(p))
+	if err != nil || len(p.Extra) == 0 {
+		return data, err
+	}
+	return mergeExtraParams(data, p.Extra, chatPayloadFields)
+}
+
+// chatPayloadFields are the JSON names of the fields of chatPayload
+var chatPayloadFields = jsonFieldNames(reflect.TypeOf(chatPayload{}))
+
+// jsonFieldNames returns the JSON names of the encoded fields of struct t
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// mergeExtraParams sets the keys of extra in the JSON object data, which
+// has the given fields. New keys are appended in sorted order; only when
+// extra replaces one of fields is the object decoded and re-encoded.
+func mergeExtraParams(data []byte, extra map[string]interface{}, fields map[string]bool) ([]byte, error) {
+	keys := make([]string, 0, len(extra))
+	replaces := false
+	for key := range extra {
+		keys = append(keys, key)
+		replaces = replaces || fields[key]
+	}
+	sort.Strings(keys)
+
+	if replaces {
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			raw, err := json.Marshal(extra[key])
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal extra parameter %q: %w", key, err)
+			}
+			object[key] = raw
+		}
+		return json.Marshal(object)
+	}
+
+	buf := bytes.NewBuffer(data[:len(data)-1])
+	for _, key := range keys {
+		name, _ := json.Marshal(key)
+		raw, err := json.Marshal(extra[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal extra parameter %q: %w", key, err)
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(raw)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,173 @@
+package llm
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestChatPayloadJSON(t *testing.T) {
+	temperature, topP := 0.2, 0.9
+	tests := []struct {
+		name    string
+		want    map[string]interface{}
+		request Request
+	}{
+		{
+			name:    "defaults",
+			request: BuildSimpleRequest("Hi"),
+			want: map[string]interface{}{
+				"model":    "gpt-4o",
+				"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+				"stream":   false,
+			},
+		},
+		{
+			name: "parameters",
+			request: NewRequest(WithSystem("Be brief."), WithUser("Hi"), WithTemperature(temperature), WithTopP(topP), WithMaxTokens(10),
+				WithMessages(Message{Role: RoleUser, Content: "Again", Name: "bob"})),
+			want: map[string]interface{}{
+				"model": "gpt-4o",
+				"messages": []interface{}{
+					map[string]interface{}{"role": "system", "content": "Be brief."},
+					map[string]interface{}{"role": "user", "content": "Hi"},
+					map[string]interface{}{"role": "user", "content": "Again", "name": "bob"},
+				},
+				"stream":      false,
+				"temperature": temperature,
+				"top_p":       topP,
+				"max_tokens":  float64(10),
+			},
+		},
+		{
+			name:    "extra params",
+			request: NewRequest(WithUser("Hi"), WithJSONMode(), WithExtraParam("seed", 7)),
+			want: map[string]interface{}{
+				"model":           "gpt-4o",
+				"messages":        []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+				"stream":          false,
+				"response_format": map[string]interface{}{"type": "json_object"},
+				"seed":            float64(7),
+			},
+		},
+		{
+			name:    "extra params override fields",
+			request: NewRequest(WithUser("Hi"), WithMaxTokens(10), WithExtraParam("max_tokens", 20), WithExtraParam("seed", 7)),
+			want: map[string]interface{}{
+				"model":      "gpt-4o",
+				"messages":   []interface{}{map[string]interface{}{"role": "user", "content": "Hi"}},
+				"stream":     false,
+				"max_tokens": float64(20),
+				"seed":       float64(7),
+			},
+		},
+	}
+
+	client, _ := newOpenAIClient(Config{APIKey: "test-key", DefaultModel: "gpt-4o"})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(client.buildPayload(tt.request))
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("payload = %s", data)
+			}
+		})
+	}
+
+	t.Run("unencodable extra param", func(t *testing.T) {
+		request := NewRequest(WithUser("Hi"), WithExtraParam("bad", make(chan int)))
+		if _, err := json.Marshal(client.buildPayload(request)); err == nil {
+			t.Error("Expected an error")
+		}
+	})
+}
+
+// benchmarkRequest is a typical chat request with a short history
+func benchmarkRequest() Request {
+	history := ChatHistory{}
+	history.AddSystemMessage("You are a helpful assistant that answers in one paragraph.")
+	for i := 0; i < 4; i++ {
+		history.AddUserMessage("What is the capital of France, and why is it famous?")
+		history.AddAssistantMessage("Paris. It is famous for its art, fashion, gastronomy and culture.")
+	}
+	request := BuildChatRequest(history.GetMessages(), "And of Italy?")
+	request.Apply(WithTemperature(0.7), WithMaxTokens(500))
+	return request
+}
+
+// mapChatPayload builds the payload the way the clients did before
+// chatPayload, as the baseline of BenchmarkEncodeChatPayload
+func mapChatPayload(config Config, request Request) map[string]interface{} {
+	messages := requestMessages(request)
+	converted := make([]map[string]interface{}, len(messages))
+	for i, msg := range messages {
+		converted[i] = map[string]interface{}{"role": string(msg.Role), "content": msg.Content}
+		if msg.Name != "" {
+			converted[i]["name"] = msg.Name
+		}
+	}
+	payload := map[string]interface{}{
+		"model":    config.DefaultModel,
+		"messages": converted,
+		"stream":   request.Stream,
+	}
+	if request.Temperature != nil {
+		payload["temperature"] = *request.Temperature
+	}
+	if request.MaxTokens != nil {
+		payload["max_tokens"] = *request.MaxTokens
+	}
+	if request.TopP != nil {
+		payload["top_p"] = *request.TopP
+	}
+	for k, v := range request.ExtraParams {
+		payload[k] = v
+	}
+	return payload
+}
+
+func BenchmarkEncodeChatPayload(b *testing.B) {
+	client, _ := newOpenAIClient(Config{APIKey: "test-key", DefaultModel: "gpt-4o"})
+	request := benchmarkRequest()
+	withExtra := benchmarkRequest()
+	withExtra.Apply(WithJSONMode())
+
+	b.Run("typed", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(client.buildPayload(request)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("typed/extra", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(client.buildPayload(withExtra)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("map", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(mapChatPayload(client.config, request)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("map/extra", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := json.Marshal(mapChatPayload(client.config, withExtra)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+)
+
+// PingStrategy selects how Ping probes a provider
+type PingStrategy string
+
+const (
+	// PingModels lists models, which costs nothing (the default)
+	PingModels PingStrategy = "models"
+	// PingGenerate sends a one-token generation, for keys that may chat but
+	// not list models
+	PingGenerate PingStrategy = "generate"
+)
+
+// ping probes a provider with the configured strategy. Errors keep their
+// type (APIError, ProxyError, TLSError, net errors), so ErrorClass tells a
+// bad key (StatusAuthError) from an unreachable provider.
+func ping(ctx context.Context, config Config, fetchModels func(ctx context.Context) ([]ModelInfo, error), generate generateFunc) error {
+	switch config.PingStrategy {
+	case "", PingModels:
+		if _, err := fetchModels(ctx); err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+	case PingGenerate:
+		request := BuildSimpleRequest("ping")
+		request.SetMaxTokens(1)
+		_, err := withTimeout(ctx, config, "", 0, func(ctx context.Context) (*Response, error) {
+			return generate(ctx, request)
+		})
+		if err != nil {
+			return fmt.Errorf("ping: %w", err)
+		}
+	default:
+		return fmt.Errorf("ping: unknown strategy %q", config.PingStrategy)
+	}
+	return nil
+}
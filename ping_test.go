@@ -0,0 +1,60 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	var paths []string
+	var maxTokens float64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer good-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":{"message":"invalid api key"}}`))
+			return
+		}
+		if r.URL.Path == "/chat/completions" {
+			var payload map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&payload)
+			maxTokens, _ = payload["max_tokens"].(float64)
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"p"}}]}`))
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "good-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	if err := client.Ping(ctx); err != nil || paths[0] != "GET /models" {
+		t.Errorf("Expected a models probe, got %v (%v)", paths, err)
+	}
+	// Ping bypasses the ListModels cache
+	client.Ping(ctx)
+	if len(paths) != 2 {
+		t.Errorf("Expected every Ping to reach the server, got %v", paths)
+	}
+
+	paths = nil
+	client, _ = NewClient(Config{Provider: ProviderOpenAI, APIKey: "good-key", BaseURL: server.URL, DisableBaseURLVersion: true, PingStrategy: PingGenerate})
+	if err := client.Ping(ctx); err != nil || paths[0] != "POST /chat/completions" || maxTokens != 1 {
+		t.Errorf("Expected a one-token generation, got %v max_tokens=%v (%v)", paths, maxTokens, err)
+	}
+
+	client, _ = NewClient(Config{Provider: ProviderOpenAI, APIKey: "bad-key", BaseURL: server.URL, DisableBaseURLVersion: true})
+	if err := client.Ping(ctx); ErrorClass(err) != StatusAuthError {
+		t.Errorf("Expected %s, got %s (%v)", StatusAuthError, ErrorClass(err), err)
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	client, _ = NewClient(Config{Provider: ProviderOpenAI, APIKey: "good-key", BaseURL: down.URL, DisableBaseURLVersion: true})
+	if err := client.Ping(ctx); ErrorClass(err) != StatusNetworkError {
+		t.Errorf("Expected %s, got %s (%v)", StatusNetworkError, ErrorClass(err), err)
+	}
+}
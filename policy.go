@@ -0,0 +1,386 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// ErrPolicyViolation is matched by PolicyError via errors.Is
+var ErrPolicyViolation = errors.New("policy violation")
+
+// PolicyRule names one constraint of a PolicyConfig
+type PolicyRule string
+
+const (
+	PolicyRuleModel        PolicyRule = "model"
+	PolicyRuleTemperature  PolicyRule = "temperature"
+	PolicyRuleMaxTokens    PolicyRule = "max_tokens"
+	PolicyRuleTools        PolicyRule = "tools"
+	PolicyRulePromptTokens PolicyRule = "prompt_tokens"
+)
+
+// toolParams are the ExtraParams keys that carry tool definitions
+var toolParams = []string{"tools", "tool_choice", "functions", "function_call"}
+
+// PolicyViolation describes a request that broke a rule of a PolicyClient
+type PolicyViolation struct {
+	Rule  PolicyRule
+	Model string
+	// Detail says what was wrong, for example "temperature 1.5 above 1"
+	Detail string
+	// Clamped is set when the request was rewritten to conform instead of
+	// being rejected
+	Clamped bool
+}
+
+// PolicyError is returned, without calling the provider, for a request
+// that breaks a rule that is not clamped
+type PolicyError struct {
+	PolicyViolation
+	// Err is the underlying error, a PromptTooLargeError for
+	// PolicyRulePromptTokens
+	Err error
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("policy violation (%s) for %s: %s", e.Rule, e.Model, e.Detail)
+}
+
+// Is makes errors.Is(err, ErrPolicyViolation) match
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyViolation
+}
+
+func (e *PolicyError) Unwrap() error {
+	return e.Err
+}
+
+// PolicyConfig configures NewPolicyClient. Zero fields impose no constraint.
+type PolicyConfig struct {
+	// AllowedModels lists the models calls may use; dated snapshots are
+	// allowed with their prefix ("gpt-4o" allows "gpt-4o-2024-08-06").
+	// Clamping replaces another model with the first one.
+	AllowedModels []string
+
+	// MaxTemperature caps the temperature of calls that set one, or that
+	// inherit Config.DefaultTemperature
+	MaxTemperature *float64
+
+	// MaxTokens caps the output tokens. Calls that set no limit, neither
+	// Request.MaxTokens nor Config.DefaultMaxTokens, break the rule too;
+	// clamping gives them the cap.
+	MaxTokens int
+
+	// DisableTools forbids tool definitions (the "tools", "tool_choice",
+	// "functions" and "function_call" extra parameters), including those
+	// the client adds from Config.DefaultExtraParams. Clamping drops them
+	// from the request; calls that get them from the client's defaults are
+	// rejected, since those cannot be dropped per call.
+	DisableTools bool
+
+	// MaxPromptTokens caps the estimated prompt size; clamping drops the
+	// oldest messages as PromptOverflowTruncate does
+	MaxPromptTokens int
+
+	// Clamp lists the rules that rewrite a violating request instead of
+	// rejecting it with a PolicyError
+	Clamp []PolicyRule
+
+	// OnViolation receives every violation, clamped or rejected
+	OnViolation func(ctx context.Context, violation PolicyViolation)
+}
+
+// PolicyClient wraps a Client and enforces a PolicyConfig on its chat calls.
+// The wrapped client is not exposed, so calls cannot bypass the policy.
+type PolicyClient struct {
+	inner  Client
+	policy PolicyConfig
+}
+
+// NewPolicyClient returns a client that checks every Generate,
+// GenerateWithHistory, GenerateStream and TryGenerate call against policy
+// before it reaches inner.
+// Conforming requests pass through untouched; violating ones are clamped or
+// rejected per rule. Rules apply to the model the call resolves to
+// (Request.Model, else inner's default model). Every violation goes to
+// PolicyConfig.OnViolation and, as a warning, to inner's Config.Logger;
+// rejected calls also run inner's AfterResponse hooks with the PolicyError,
+// so audit hooks see them. Embeddings and the other calls are passed to
+// inner unchecked.
+func NewPolicyClient(inner Client, policy PolicyConfig) *PolicyClient {
+	return &PolicyClient{inner: inner, policy: policy}
+}
+
+// Generate sends the request if it conforms to the policy, once clamped
+func (c *PolicyClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	request, err := c.enforce(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return c.inner.Generate(ctx, request)
+}
+
+// GenerateStream starts the stream if the request conforms to the policy, once clamped
+func (c *PolicyClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	request, err := c.enforce(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return GenerateStream(ctx, c.inner, request)
+}
+
+// TryGenerate sends the request if it conforms to the policy, once clamped,
+// and the wrapped client can send it without waiting (see TryGenerate)
+func (c *PolicyClient) TryGenerate(ctx context.Context, request Request) (*Response, error) {
+	request, err := c.enforce(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return TryGenerate(ctx, c.inner, request)
+}
+
+// EstimateWait reports the wait of the wrapped client
+func (c *PolicyClient) EstimateWait(ctx context.Context, request Request) WaitEstimate {
+	return EstimateWait(ctx, c.inner, request)
+}
+
+// GenerateWithHistory sends the conversation if it conforms to the policy, once clamped
+func (c *PolicyClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	request := BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// CreateEmbedding embeds the input with the wrapped client, unchecked
+func (c *PolicyClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	return c.inner.CreateEmbedding(ctx, request)
+}
+
+// Close closes the wrapped client
+func (c *PolicyClient) Close() error {
+	return c.inner.Close()
+}
+
+// GetConfig returns the configuration of the wrapped client
+func (c *PolicyClient) GetConfig() Config {
+	return c.inner.GetConfig()
+}
+
+// GetConfigWithSecrets returns the configuration of the wrapped client,
+// including the API key
+func (c *PolicyClient) GetConfigWithSecrets() Config {
+	return c.inner.GetConfigWithSecrets()
+}
+
+// CountTokens estimates the prompt tokens of request with the wrapped client
+func (c *PolicyClient) CountTokens(request Request) int {
+	return c.inner.CountTokens(request)
+}
+
+// ListModels lists the models of the wrapped client, allowed or not
+func (c *PolicyClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	return c.inner.ListModels(ctx)
+}
+
+// Capabilities reports the capabilities of the wrapped client
+func (c *PolicyClient) Capabilities() Capabilities {
+	return c.inner.Capabilities()
+}
+
+// Ping pings the wrapped client
+func (c *PolicyClient) Ping(ctx context.Context) error {
+	return c.inner.Ping(ctx)
+}
+
+// Warmup warms up the wrapped client
+func (c *PolicyClient) Warmup(ctx context.Context) error {
+	return c.inner.Warmup(ctx)
+}
+
+// Check reports whether request conforms to the policy as is, returning
+// the PolicyError of the first rule it breaks otherwise. It clamps and
+// reports nothing.
+func (c *PolicyClient) Check(request Request) error {
+	config := c.inner.GetConfig()
+	for _, check := range c.checks() {
+		if violation := check(config, &request, false); violation != nil {
+			return violation
+		}
+	}
+	return nil
+}
+
+// policyCheck tests one rule against request, rewriting it to conform when
+// clamp is set and the rule is in PolicyConfig.Clamp. It returns the
+// violation found, if any, with Clamped set when it was rewritten.
+type policyCheck func(config Config, request *Request, clamp bool) *PolicyError
+
+// checks returns the checks of the policy, in the order they run
+func (c *PolicyClient) checks() []policyCheck {
+	return []policyCheck{c.checkModel, c.checkTemperature, c.checkMaxTokens, c.checkTools, c.checkPromptTokens}
+}
+
+// enforce applies every rule to request, clamping a copy of it
+func (c *PolicyClient) enforce(ctx context.Context, request Request) (Request, error) {
+	config := c.inner.GetConfig()
+	if len(c.policy.Clamp) > 0 {
+		// the caller may reuse its request
+		request = request.Clone()
+	}
+	for _, check := range c.checks() {
+		violation := check(config, &request, true)
+		if violation == nil {
+			continue
+		}
+		c.report(ctx, config, violation.PolicyViolation)
+		if !violation.Clamped {
+			runAfterHooks(withAttempt(ctx, 1), config, &request, nil, violation)
+			return request, violation
+		}
+	}
+	return request, nil
+}
+
+// report passes a violation to OnViolation and the logger
+func (c *PolicyClient) report(ctx context.Context, config Config, violation PolicyViolation) {
+	if c.policy.OnViolation != nil {
+		c.policy.OnViolation(ctx, violation)
+	}
+	if config.Logger != nil {
+		config.Logger.LogAttrs(ctx, slog.LevelWarn, "llm: policy violation",
+			slog.String("provider", string(config.Provider)),
+			slog.String("model", violation.Model),
+			slog.String("rule", string(violation.Rule)),
+			slog.String("detail", violation.Detail),
+			slog.Bool("clamped", violation.Clamped),
+		)
+	}
+}
+
+// model returns the model a call resolves to
+func (c *PolicyClient) model(config Config, request Request) string {
+	if request.Model != nil && *request.Model != "" {
+		return *request.Model
+	}
+	return config.DefaultModel
+}
+
+// violation builds the error of a broken rule
+func (c *PolicyClient) violation(rule PolicyRule, config Config, request Request, detail string, args ...interface{}) *PolicyError {
+	return &PolicyError{PolicyViolation: PolicyViolation{Rule: rule, Model: c.model(config, request), Detail: fmt.Sprintf(detail, args...)}}
+}
+
+// clamps reports whether rule may be clamped
+func (c *PolicyClient) clamps(rule PolicyRule) bool {
+	return slices.Contains(c.policy.Clamp, rule)
+}
+
+func (c *PolicyClient) checkModel(config Config, request *Request, clamp bool) *PolicyError {
+	allowed := c.policy.AllowedModels
+	model := c.model(config, *request)
+	if len(allowed) == 0 || slices.ContainsFunc(allowed, func(name string) bool {
+		return model == name || strings.HasPrefix(model, name+"-")
+	}) {
+		return nil
+	}
+	violation := c.violation(PolicyRuleModel, config, *request, "model %q is not allowed", model)
+	if clamp && c.clamps(PolicyRuleModel) {
+		request.SetModel(allowed[0])
+		violation.Clamped = true
+	}
+	return violation
+}
+
+func (c *PolicyClient) checkTemperature(config Config, request *Request, clamp bool) *PolicyError {
+	limit := c.policy.MaxTemperature
+	temperature := request.Temperature
+	if temperature == nil {
+		temperature = config.DefaultTemperature
+	}
+	if limit == nil || temperature == nil || *temperature <= *limit {
+		return nil
+	}
+	violation := c.violation(PolicyRuleTemperature, config, *request, "temperature %g above %g", *temperature, *limit)
+	if clamp && c.clamps(PolicyRuleTemperature) {
+		request.SetTemperature(*limit)
+		violation.Clamped = true
+	}
+	return violation
+}
+
+func (c *PolicyClient) checkMaxTokens(config Config, request *Request, clamp bool) *PolicyError {
+	limit := c.policy.MaxTokens
+	maxTokens := request.MaxTokens
+	if maxTokens == nil {
+		maxTokens = config.DefaultMaxTokens
+	}
+	if limit <= 0 || (maxTokens != nil && *maxTokens <= limit) {
+		return nil
+	}
+	var violation *PolicyError
+	if maxTokens == nil {
+		violation = c.violation(PolicyRuleMaxTokens, config, *request, "no max_tokens, limit %d", limit)
+	} else {
+		violation = c.violation(PolicyRuleMaxTokens, config, *request, "max_tokens %d above %d", *maxTokens, limit)
+	}
+	if clamp && c.clamps(PolicyRuleMaxTokens) {
+		request.SetMaxTokens(limit)
+		violation.Clamped = true
+	}
+	return violation
+}
+
+func (c *PolicyClient) checkTools(config Config, request *Request, clamp bool) *PolicyError {
+	if !c.policy.DisableTools {
+		return nil
+	}
+	// the client merges its default extra parameters in before sending
+	extra := withConfigDefaults(config, *request).ExtraParams
+	var found []string
+	defaulted := false
+	for _, key := range toolParams {
+		if _, ok := extra[key]; ok {
+			found = append(found, key)
+			_, set := config.DefaultExtraParams[key]
+			defaulted = defaulted || set
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	violation := c.violation(PolicyRuleTools, config, *request, "tools are disabled (%s)", strings.Join(found, ", "))
+	if clamp && c.clamps(PolicyRuleTools) && !defaulted {
+		for _, key := range found {
+			delete(request.ExtraParams, key)
+		}
+		violation.Clamped = true
+	}
+	return violation
+}
+
+func (c *PolicyClient) checkPromptTokens(config Config, request *Request, clamp bool) *PolicyError {
+	if c.policy.MaxPromptTokens <= 0 {
+		return nil
+	}
+	config.MaxPromptTokens = c.policy.MaxPromptTokens
+	config.PromptOverflowPolicy = PromptOverflowError
+	config.Logger = nil
+	err := guardPromptSize(context.Background(), config, c.model(config, *request), request)
+	if err == nil {
+		return nil
+	}
+	violation := c.violation(PolicyRulePromptTokens, config, *request, "%v", err)
+	violation.Err = err
+	if clamp && c.clamps(PolicyRulePromptTokens) {
+		// fails when even the last turn alone does not fit
+		config.PromptOverflowPolicy = PromptOverflowTruncate
+		violation.Clamped = guardPromptSize(context.Background(), config, c.model(config, *request), request) == nil
+	}
+	return violation
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPolicyClient(t *testing.T) {
+	var sent []map[string]interface{}
+	server := newChatServer(t, func(r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent = append(sent, payload)
+	})
+	var audited []error
+	inner, err := NewClient(Config{
+		Provider:     ProviderOpenAI,
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		DefaultModel: "gpt-4o-mini",
+		AfterResponse: []AfterResponseHook{func(ctx context.Context, request *Request, response *Response, err error) {
+			audited = append(audited, err)
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	maxTemperature := 1.0
+	var violations []PolicyViolation
+	client := NewPolicyClient(inner, PolicyConfig{
+		AllowedModels:   []string{"gpt-4o-mini", "gpt-4o"},
+		MaxTemperature:  &maxTemperature,
+		MaxTokens:       500,
+		DisableTools:    true,
+		MaxPromptTokens: 50,
+		Clamp:           []PolicyRule{PolicyRuleTemperature, PolicyRuleMaxTokens, PolicyRuleTools},
+		OnViolation:     func(ctx context.Context, v PolicyViolation) { violations = append(violations, v) },
+	})
+	ctx := context.Background()
+
+	// Conforming requests pass untouched, dated snapshots included
+	conforming := NewRequest(WithUser("Hello"), WithModel("gpt-4o-2024-08-06"), WithMaxTokens(100), WithTemperature(0.5))
+	if err := client.Check(conforming); err != nil {
+		t.Errorf("Expected a conforming request, got %v", err)
+	}
+	if _, err := client.Generate(ctx, conforming); err != nil || len(violations) != 0 {
+		t.Fatalf("Generate failed: %v, %+v", err, violations)
+	}
+
+	// Clamped rules rewrite a copy of the request
+	loose := NewRequest(WithUser("Hello"), WithTemperature(1.8), WithExtraParam("tools", []string{"search"}))
+	if err := client.Check(loose); !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("Expected Check to report the violation, got %v", err)
+	}
+	if _, err := client.Generate(ctx, loose); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	last := sent[len(sent)-1]
+	if _, tools := last["tools"]; last["temperature"] != 1.0 || last["max_tokens"] != 500.0 || tools {
+		t.Errorf("Expected a clamped payload, got %+v", last)
+	}
+	if *loose.Temperature != 1.8 || loose.MaxTokens != nil || loose.ExtraParams["tools"] == nil {
+		t.Error("Expected the caller's request untouched")
+	}
+	if len(violations) != 3 || violations[0].Rule != PolicyRuleTemperature || !violations[0].Clamped {
+		t.Errorf("Expected three clamped violations, got %+v", violations)
+	}
+
+	// Other rules reject before anything is sent, and audit hooks see it
+	calls := len(sent)
+	_, err = client.Generate(ctx, NewRequest(WithUser("Hello"), WithModel("gpt-4.1"), WithMaxTokens(10)))
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) || policyErr.Rule != PolicyRuleModel || policyErr.Model != "gpt-4.1" || policyErr.Clamped {
+		t.Errorf("Expected a model violation, got %v", err)
+	}
+	_, err = client.Generate(ctx, NewRequest(WithUser(strings.Repeat("word ", 200)), WithMaxTokens(10)))
+	if !errors.Is(err, ErrPolicyViolation) || !errors.Is(err, ErrPromptTooLarge) {
+		t.Errorf("Expected a prompt size violation, got %v", err)
+	}
+	if len(sent) != calls {
+		t.Error("Expected rejected requests not to be sent")
+	}
+	if len(audited) != 4 || !errors.Is(audited[3], ErrPolicyViolation) {
+		t.Errorf("Expected AfterResponse hooks to see the rejections, got %v", audited)
+	}
+
+	// Streams are checked too
+	_, err = GenerateStream(ctx, client, NewRequest(WithUser("Hello"), WithModel("gpt-4.1"), WithMaxTokens(10)))
+	if !errors.As(err, &policyErr) || policyErr.Rule != PolicyRuleModel {
+		t.Errorf("Expected a model violation for the stream, got %v", err)
+	}
+
+	// Tools from the client's defaults cannot be clamped away
+	violations = nil
+	view := NewPolicyClient(inner.(Viewer).WithDefaults(WithExtraParam("tools", []string{"search"})), PolicyConfig{
+		DisableTools: true,
+		Clamp:        []PolicyRule{PolicyRuleTools},
+		OnViolation:  func(ctx context.Context, v PolicyViolation) { violations = append(violations, v) },
+	})
+	_, err = view.Generate(ctx, NewRequest(WithUser("Hello")))
+	if !errors.As(err, &policyErr) || policyErr.Rule != PolicyRuleTools || len(violations) != 1 || violations[0].Clamped {
+		t.Errorf("Expected default tools to be rejected, got %v, %+v", err, violations)
+	}
+	if len(sent) != calls {
+		t.Error("Expected rejected requests not to be sent")
+	}
+}
@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// PostProcessor rewrites the content of a response, e.g. StripCodeFences
+type PostProcessor func(content string) string
+
+// PostProcessHook returns an AfterResponseHook that runs processors, in
+// order, on the content of every successful response, so Response.Content
+// arrives clean:
+//
+//	config.AfterResponse = append(config.AfterResponse, llm.PostProcessHook(llm.StripThinkTags, llm.StripCodeFences))
+//
+// Streamed deltas are delivered before the hook runs and are not affected.
+func PostProcessHook(processors ...PostProcessor) AfterResponseHook {
+	return func(ctx context.Context, request *Request, response *Response, err error) {
+		if err != nil || response == nil {
+			return
+		}
+		for _, process := range processors {
+			response.Content = process(response.Content)
+		}
+	}
+}
+
+// codeFence is a fenced code block found by parseCodeBlocks
+type codeFence struct {
+	// info is the info string after the opening fence, e.g. "json"
+	info string
+	body string
+	// start and end are the line indices of the opening and closing fences
+	start, end int
+}
+
+// parseCodeBlocks finds the closed fenced code blocks of a Markdown
+// document: a line of at least three backticks or tildes, indented by at
+// most three spaces, opens a block that the next fence of the same character
+// and at least the same length closes. Unclosed blocks are ignored.
+func parseCodeBlocks(content string) []codeFence {
+	lines := strings.Split(content, "\n")
+	var blocks []codeFence
+	for i := 0; i < len(lines); i++ {
+		char, length, info, ok := fenceLine(lines[i])
+		if !ok || (char == '`' && strings.Contains(info, "`")) {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			closeChar, closeLength, closeInfo, ok := fenceLine(lines[j])
+			if ok && closeChar == char && closeLength >= length && closeInfo == "" {
+				blocks = append(blocks, codeFence{
+					info:  info,
+					body:  strings.Join(lines[i+1:j], "\n"),
+					start: i,
+					end:   j,
+				})
+				i = j
+				break
+			}
+		}
+	}
+	return blocks
+}
+
+// fenceLine reports whether line is a code fence, returning its character,
+// length and trimmed info string
+func fenceLine(line string) (char byte, length int, info string, ok bool) {
+	line = strings.TrimRight(line, " \t\r")
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return 0, 0, "", false
+	}
+	char = trimmed[0]
+	for length < len(trimmed) && trimmed[length] == char {
+		length++
+	}
+	if length < 3 {
+		return 0, 0, "", false
+	}
+	return char, length, strings.TrimSpace(trimmed[length:]), true
+}
+
+// StripCodeFences removes the fences around content that consists of
+// exactly one fenced code block, such as JSON wrapped in ```json ... ```.
+// Anything else, including fences in the middle of prose, is returned
+// unchanged.
+func StripCodeFences(content string) string {
+	trimmed := strings.TrimSpace(content)
+	blocks := parseCodeBlocks(trimmed)
+	if len(blocks) != 1 {
+		return content
+	}
+	block := blocks[0]
+	if block.start != 0 || block.end != strings.Count(trimmed, "\n") {
+		return content
+	}
+	return block.body
+}
+
+// ExtractCodeBlocks returns the bodies of the fenced code blocks in content
+// whose language (the first word of the info string) is lang, compared
+// case-insensitively. An empty lang returns every block.
+func ExtractCodeBlocks(content, lang string) []string {
+	var bodies []string
+	for _, block := range parseCodeBlocks(content) {
+		language, _, _ := strings.Cut(block.info, " ")
+		if lang == "" || strings.EqualFold(language, lang) {
+			bodies = append(bodies, block.body)
+		}
+	}
+	return bodies
+}
+
+// StripThinkTags removes a <think>...</think> block that reasoning models
+// leak into the content on some gateways. Only a block at the start of the
+// content is removed, as is everything up to a single </think> without an
+// opening tag (when the gateway put the opening tag in the prompt). Tags
+// elsewhere, and an unterminated <think>, are left alone.
+func StripThinkTags(content string) string {
+	trimmed := strings.TrimLeft(content, " \t\r\n")
+	const open, close = "<think>", "</think>"
+	if rest, ok := strings.CutPrefix(trimmed, open); ok {
+		if _, after, found := strings.Cut(rest, close); found {
+			return strings.TrimLeft(after, " \t\r\n")
+		}
+		return content
+	}
+	if !strings.Contains(content, open) && strings.Count(content, close) == 1 {
+		if _, after, found := strings.Cut(content, close); found {
+			return strings.TrimLeft(after, " \t\r\n")
+		}
+	}
+	return content
+}
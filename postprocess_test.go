@@ -0,0 +1,106 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestStripCodeFences(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"json fence", "```json\n{\"a\": 1}\n```", `{"a": 1}`},
+		{"no language", "```\nSELECT 1;\n```", "SELECT 1;"},
+		{"surrounding whitespace", "\n  ```json\n{\"a\": 1}\n```  \n", `{"a": 1}`},
+		{"tildes", "~~~yaml\na: 1\n~~~", "a: 1"},
+		{"windows line endings", "```json\r\n{\"a\": 1}\r\n```\r\n", "{\"a\": 1}\r"},
+		{"longer fence around inner fence", "````markdown\nUse:\n```go\nx := 1\n```\n````", "Use:\n```go\nx := 1\n```"},
+		{"empty block", "```\n```", ""},
+		{"plain text", `{"a": 1}`, `{"a": 1}`},
+		{"prose before", "Here you go:\n```json\n{\"a\": 1}\n```", "Here you go:\n```json\n{\"a\": 1}\n```"},
+		{"prose after", "```json\n{\"a\": 1}\n```\nHope this helps!", "```json\n{\"a\": 1}\n```\nHope this helps!"},
+		{"two blocks", "```go\na\n```\n```go\nb\n```", "```go\na\n```\n```go\nb\n```"},
+		{"unclosed", "```json\n{\"a\": 1}", "```json\n{\"a\": 1}"},
+		{"inline backticks", "```code``` is inline", "```code``` is inline"},
+		{"indented four spaces", "    ```\n    x\n    ```", "    ```\n    x\n    ```"},
+		{"mismatched closing char", "```\nx\n~~~", "```\nx\n~~~"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripCodeFences(tt.content); got != tt.want {
+				t.Errorf("StripCodeFences(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCodeBlocks(t *testing.T) {
+	content := "Install it:\n\n```bash\ngo get example.com/x\n```\n\nThen:\n\n```Go title=main.go\npackage main\n\nfunc main() {}\n```\n\n" +
+		"Inline ```go nope``` text.\n\n````md\n```go\ninner\n```\n````\n\n```go\nunclosed"
+
+	tests := []struct {
+		lang string
+		want []string
+	}{
+		{"go", []string{"package main\n\nfunc main() {}"}},
+		{"bash", []string{"go get example.com/x"}},
+		{"", []string{"go get example.com/x", "package main\n\nfunc main() {}", "```go\ninner\n```"}},
+		{"python", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.lang, func(t *testing.T) {
+			if got := ExtractCodeBlocks(content, tt.lang); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractCodeBlocks(%q) = %q, want %q", tt.lang, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStripThinkTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"leading block", "<think>\nThe user wants a greeting.\n</think>\n\nHello!", "Hello!"},
+		{"leading whitespace", "\n\n<think>hmm</think>Hello!", "Hello!"},
+		{"empty block", "<think></think>\nHello!", "Hello!"},
+		{"missing opening tag", "The user wants a greeting.\n</think>\n\nHello!", "Hello!"},
+		{"unterminated", "<think>Still thinking about", "<think>Still thinking about"},
+		{"no tags", "Hello!", "Hello!"},
+		{"tags mid-content", "Use <think> and </think> to mark reasoning.", "Use <think> and </think> to mark reasoning."},
+		{"closing tag twice", "Write </think> then </think> again.", "Write </think> then </think> again."},
+		{"only first block", "<think>a</think>Answer with <think>b</think> inside", "Answer with <think>b</think> inside"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StripThinkTags(tt.content); got != tt.want {
+				t.Errorf("StripThinkTags(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostProcessHook(t *testing.T) {
+	server := newChatServer(t, func(r *http.Request) {})
+	client, _ := NewClient(Config{
+		Provider:      ProviderOpenAI,
+		APIKey:        "test-key",
+		BaseURL:       server.URL,
+		AfterResponse: []AfterResponseHook{PostProcessHook(func(content string) string { return "[" + content + "]" }, StripCodeFences)},
+	})
+	response, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if response.Content[0] != '[' {
+		t.Errorf("Expected the processors to rewrite the content, got %q", response.Content)
+	}
+
+	// failed calls have no response to process
+	PostProcessHook(StripThinkTags)(context.Background(), nil, nil, context.Canceled)
+}
@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ErrNoPricing is returned when no price is known for a model
+var ErrNoPricing = errors.New("no pricing for model")
+
+// ModelPrice is the price of a model in USD per million tokens
+type ModelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+	// CachedInput applies to prompt tokens served from the provider's cache
+	// (0 = same as Input)
+	CachedInput float64 `json:"cached_input,omitempty"`
+}
+
+// Pricing maps models to prices. Keys are either a model name, matching any
+// provider, or "provider/model" for a provider-specific price. Dated
+// snapshots such as "gpt-4o-mini-2024-07-18" use the price of their longest
+// listed prefix.
+type Pricing map[string]ModelPrice
+
+// Cost is an estimated price of a call
+type Cost struct {
+	USD float64
+	// Approximate is set when token counts had to be estimated with a tokenizer
+	Approximate bool
+}
+
+// defaultPricing holds list prices (USD per million tokens) for common
+// models, current as of 2025. Prices change; override them with SetPricing.
+var defaultPricing = Pricing{
+	// OpenAI
+	"gpt-4o":                 {Input: 2.50, CachedInput: 1.25, Output: 10.00},
+	"gpt-4o-mini":            {Input: 0.15, CachedInput: 0.075, Output: 0.60},
+	"gpt-4.1":                {Input: 2.00, CachedInput: 0.50, Output: 8.00},
+	"gpt-4.1-mini":           {Input: 0.40, CachedInput: 0.10, Output: 1.60},
+	"gpt-4.1-nano":           {Input: 0.10, CachedInput: 0.025, Output: 0.40},
+	"o3-mini":                {Input: 1.10, CachedInput: 0.55, Output: 4.40},
+	"gpt-3.5-turbo":          {Input: 0.50, Output: 1.50},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+
+	// DeepSeek
+	"deepseek-chat":     {Input: 0.28, CachedInput: 0.028, Output: 0.42},
+	"deepseek-reasoner": {Input: 0.28, CachedInput: 0.028, Output: 0.42},
+
+	// Qwen (international endpoint)
+	"qwen-turbo":                  {Input: 0.05, Output: 0.20},
+	"qwen-plus":                   {Input: 0.40, Output: 1.20},
+	"qwen-max":                    {Input: 1.60, Output: 6.40},
+	"qwen3-next-80b-a3b-instruct": {Input: 0.15, Output: 1.20},
+
+	// Cohere
+	"command-r":               {Input: 0.15, Output: 0.60},
+	"command-r-plus":          {Input: 2.50, Output: 10.00},
+	"command-a-03-2025":       {Input: 2.50, Output: 10.00},
+	"embed-multilingual-v3.0": {Input: 0.10},
+	"embed-english-v3.0":      {Input: 0.10},
+}
+
+// pricingMu guards defaultPricing
+var pricingMu sync.RWMutex
+
+// SetPricing sets or replaces the default price of a model (see Pricing for
+// key formats). It is safe to call concurrently with estimates.
+func SetPricing(model string, price ModelPrice) {
+	pricingMu.Lock()
+	defer pricingMu.Unlock()
+	defaultPricing[model] = price
+}
+
+// DefaultPricing returns a copy of the current default price table
+func DefaultPricing() Pricing {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	table := make(Pricing, len(defaultPricing))
+	for k, v := range defaultPricing {
+		table[k] = v
+	}
+	return table
+}
+
+// Lookup returns the price of model for provider
+func (p Pricing) Lookup(provider Provider, model string) (ModelPrice, bool) {
+	if price, ok := p[string(provider)+"/"+model]; ok {
+		return price, true
+	}
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for key := range p {
+		name := key
+		if i := strings.IndexByte(key, '/'); i >= 0 {
+			if Provider(key[:i]) != provider {
+				continue
+			}
+			name = key[i+1:]
+		}
+		if strings.HasPrefix(model, name+"-") && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return p[best], true
+}
+
+// Cost returns the USD price of the given token counts for model;
+// cachedTokens is the part of promptTokens served from the prompt cache
+func (p Pricing) Cost(provider Provider, model string, promptTokens, cachedTokens, completionTokens int) (float64, error) {
+	price, ok := p.Lookup(provider, model)
+	if !ok {
+		return 0, fmt.Errorf("%w %q", ErrNoPricing, model)
+	}
+	cachedRate := price.CachedInput
+	if cachedRate == 0 {
+		cachedRate = price.Input
+	}
+	usd := float64(promptTokens-cachedTokens)*price.Input +
+		float64(cachedTokens)*cachedRate +
+		float64(completionTokens)*price.Output
+	return usd / 1e6, nil
+}
+
+// Estimate prices a response. Responses without a prompt/completion split
+// are estimated with tokenizer (nil = HeuristicTokenizer) from the reply
+// text and TokensUsed, and marked Approximate.
+func (p Pricing) Estimate(response *Response, tokenizer Tokenizer) (Cost, error) {
+	usage := response.Usage
+	approximate := false
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 {
+		if tokenizer == nil {
+			tokenizer = HeuristicTokenizer{}
+		}
+		usage.CompletionTokens = tokenizer.CountTokens(response.Model, response.Content)
+		if response.TokensUsed > usage.CompletionTokens {
+			usage.PromptTokens = response.TokensUsed - usage.CompletionTokens
+		}
+		approximate = true
+	}
+	usd, err := p.Cost(response.Provider, response.Model, usage.PromptTokens, usage.CachedTokens, usage.CompletionTokens)
+	if err != nil {
+		return Cost{}, err
+	}
+	return Cost{USD: usd, Approximate: approximate}, nil
+}
+
+// EstimateCost prices a response with the default price table
+func EstimateCost(response *Response) (Cost, error) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	return defaultPricing.Estimate(response, nil)
+}
+
+// EstimateRequestCost prices the given token counts with the default price table
+func EstimateRequestCost(provider Provider, model string, promptTokens, completionTokens int) (float64, error) {
+	pricingMu.RLock()
+	defer pricingMu.RUnlock()
+	return defaultPricing.Cost(provider, model, promptTokens, 0, completionTokens)
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestEstimateRequestCost(t *testing.T) {
+	tests := []struct {
+		provider Provider
+		model    string
+		want     float64
+	}{
+		{provider: ProviderOpenAI, model: "gpt-4o", want: 2.50 + 10.00},
+		{provider: ProviderOpenAI, model: "gpt-4o-mini-2024-07-18", want: 0.15 + 0.60},
+		{provider: ProviderDeepSeek, model: "deepseek-chat", want: 0.28 + 0.42},
+	}
+	for _, tt := range tests {
+		got, err := EstimateRequestCost(tt.provider, tt.model, 1_000_000, 1_000_000)
+		if err != nil || math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("EstimateRequestCost(%s) = %v, %v; want %v", tt.model, got, err, tt.want)
+		}
+	}
+
+	if _, err := EstimateRequestCost(ProviderOpenAI, "unknown-model", 1, 1); !errors.Is(err, ErrNoPricing) {
+		t.Errorf("Expected ErrNoPricing, got %v", err)
+	}
+}
+
+func TestPricingOverrides(t *testing.T) {
+	pricing := Pricing{
+		"gpt-4o":       {Input: 2.50, CachedInput: 1.25, Output: 10.00},
+		"azure/gpt-4o": {Input: 2.00, Output: 8.00},
+	}
+
+	cost, err := pricing.Cost(ProviderOpenAI, "gpt-4o", 1_000_000, 400_000, 0)
+	if err != nil || math.Abs(cost-(0.6*2.50+0.4*1.25)) > 1e-9 {
+		t.Errorf("Cached cost = %v, %v", cost, err)
+	}
+	cost, _ = pricing.Cost(ProviderAzure, "gpt-4o-2024-08-06", 1_000_000, 0, 0)
+	if math.Abs(cost-2.00) > 1e-9 {
+		t.Errorf("Expected provider-specific negotiated price, got %v", cost)
+	}
+
+	SetPricing("custom-model", ModelPrice{Input: 1, Output: 2})
+	defer func() {
+		pricingMu.Lock()
+		delete(defaultPricing, "custom-model")
+		pricingMu.Unlock()
+	}()
+	if got, err := EstimateRequestCost(ProviderOpenAI, "custom-model", 1_000_000, 1_000_000); err != nil || got != 3 {
+		t.Errorf("Expected SetPricing to take effect, got %v, %v", got, err)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	exact, err := EstimateCost(&Response{
+		Provider: ProviderOpenAI,
+		Model:    "gpt-4o-mini",
+		Usage:    Usage{PromptTokens: 1000, CompletionTokens: 500, TotalTokens: 1500},
+	})
+	if err != nil || exact.Approximate || math.Abs(exact.USD-(1000*0.15+500*0.60)/1e6) > 1e-12 {
+		t.Errorf("Unexpected exact cost %+v, %v", exact, err)
+	}
+
+	approx, err := EstimateCost(&Response{
+		Provider:   ProviderOpenAI,
+		Model:      "gpt-4o-mini",
+		Content:    "12345678",
+		TokensUsed: 100,
+	})
+	if err != nil || !approx.Approximate || math.Abs(approx.USD-(98*0.15+2*0.60)/1e6) > 1e-12 {
+		t.Errorf("Unexpected approximate cost %+v, %v", approx, err)
+	}
+}
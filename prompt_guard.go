@@ -0,0 +1,207 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// PromptLimitAuto as Config.MaxPromptTokens limits prompts to the context
+// window of the model (see ContextWindow), less the requested output tokens
+const PromptLimitAuto = -1
+
+// PromptOverflowPolicy decides what happens to a chat request whose
+// estimated prompt exceeds Config.MaxPromptTokens
+type PromptOverflowPolicy string
+
+const (
+	// PromptOverflowError fails the call with a PromptTooLargeError before
+	// anything is sent (the default)
+	PromptOverflowError PromptOverflowPolicy = ""
+	// PromptOverflowTruncate drops the oldest non-system messages, as
+	// ChatHistory.TruncateToTokens does, until the prompt fits, logging a
+	// warning to Config.Logger. It still fails if only system messages and
+	// the last turn are left and they do not fit.
+	PromptOverflowTruncate PromptOverflowPolicy = "truncate"
+)
+
+// ErrPromptTooLarge is matched by PromptTooLargeError via errors.Is
+var ErrPromptTooLarge = errors.New("prompt too large")
+
+// maxPromptContributors is how many messages PromptTooLargeError lists
+const maxPromptContributors = 3
+
+// PromptTooLargeError is returned, without calling the provider, when the
+// estimated prompt of a chat request exceeds Config.MaxPromptTokens
+type PromptTooLargeError struct {
+	Model string
+	// Estimated is the prompt size counted with Config.Tokenizer
+	Estimated int
+	Limit     int
+	// Largest are the biggest messages, largest first
+	Largest []MessageTokens
+}
+
+// MessageTokens is the estimated size of one message of a request
+type MessageTokens struct {
+	// Index is the position among the messages sent, where
+	// Request.SystemPrompt, when set, is message 0
+	Index  int
+	Role   MessageRole
+	Tokens int
+}
+
+func (e *PromptTooLargeError) Error() string {
+	largest := make([]string, len(e.Largest))
+	for i, msg := range e.Largest {
+		largest[i] = fmt.Sprintf("message %d (%s) %d tokens", msg.Index, msg.Role, msg.Tokens)
+	}
+	return fmt.Sprintf("prompt too large for %s: estimated %d tokens, limit %d (largest: %s)", e.Model, e.Estimated, e.Limit, strings.Join(largest, ", "))
+}
+
+// Is makes errors.Is(err, ErrPromptTooLarge) match
+func (e *PromptTooLargeError) Is(target error) bool {
+	return target == ErrPromptTooLarge
+}
+
+// defaultContextWindows holds the context window in tokens of common chat
+// models. Dated snapshots use the window of their longest listed prefix.
+var defaultContextWindows = map[string]int{
+	// OpenAI
+	"gpt-4o":        128000,
+	"gpt-4o-mini":   128000,
+	"gpt-4.1":       1047576,
+	"gpt-4.1-mini":  1047576,
+	"gpt-4.1-nano":  1047576,
+	"gpt-4":         8192,
+	"gpt-4-turbo":   128000,
+	"gpt-3.5-turbo": 16385,
+	"o3-mini":       200000,
+
+	// DeepSeek
+	"deepseek-chat":     128000,
+	"deepseek-reasoner": 128000,
+
+	// Qwen
+	"qwen-turbo": 1000000,
+	"qwen-plus":  131072,
+	"qwen-max":   32768,
+
+	// Cohere
+	"command-r":         128000,
+	"command-r-plus":    128000,
+	"command-a-03-2025": 256000,
+
+	// Gemini
+	"gemini-2.5-pro":   1048576,
+	"gemini-2.5-flash": 1048576,
+	"gemini-2.0-flash": 1048576,
+}
+
+// contextWindowsMu guards defaultContextWindows
+var contextWindowsMu sync.RWMutex
+
+// SetContextWindow sets or replaces the context window of model, in tokens,
+// used by PromptLimitAuto. It is safe to call concurrently with calls.
+func SetContextWindow(model string, tokens int) {
+	contextWindowsMu.Lock()
+	defer contextWindowsMu.Unlock()
+	defaultContextWindows[model] = tokens
+}
+
+// ContextWindow returns the context window of model in tokens, from an
+// exact match or the longest listed prefix of a dated snapshot
+func ContextWindow(model string) (int, bool) {
+	contextWindowsMu.RLock()
+	defer contextWindowsMu.RUnlock()
+	if tokens, ok := defaultContextWindows[model]; ok {
+		return tokens, true
+	}
+	best := ""
+	for name := range defaultContextWindows {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return defaultContextWindows[best], true
+}
+
+// promptLimit returns the prompt token limit for a request to model, or 0
+// when the guard is off or the model's window is unknown
+func promptLimit(config Config, model string, request Request) int {
+	if config.MaxPromptTokens != PromptLimitAuto {
+		return max(config.MaxPromptTokens, 0)
+	}
+	window, ok := ContextWindow(model)
+	if !ok {
+		return 0
+	}
+	if maxTokens := orDefault(request.MaxTokens, config.DefaultMaxTokens); maxTokens != nil {
+		window -= *maxTokens
+	}
+	return max(window, 1)
+}
+
+// guardPromptSize applies Config.MaxPromptTokens and
+// Config.PromptOverflowPolicy to request
+func guardPromptSize(ctx context.Context, config Config, model string, request *Request) error {
+	limit := promptLimit(config, model, *request)
+	if limit == 0 {
+		return nil
+	}
+	tokenizer := tokenizerFor(config)
+	estimated := tokenizer.CountMessages(model, requestMessages(*request))
+	if estimated <= limit {
+		return nil
+	}
+
+	if config.PromptOverflowPolicy == PromptOverflowTruncate {
+		trimmed := *request
+		count := func(messages []Message) int {
+			trimmed.Messages = messages
+			return tokenizer.CountMessages(model, requestMessages(trimmed))
+		}
+		// The last turn is the question being asked; it is never dropped
+		kept := trimOldest(request.Messages, func(messages []Message) bool {
+			return count(messages) > limit && firstConversational(messages) < len(messages)-1
+		})
+		if fits := count(kept); fits <= limit {
+			trimmed.Messages = kept
+			if config.Logger != nil {
+				config.Logger.LogAttrs(ctx, slog.LevelWarn, "llm: truncated prompt",
+					slog.String("provider", string(config.Provider)),
+					slog.String("model", model),
+					slog.Int("dropped_messages", len(request.Messages)-len(kept)),
+					slog.Int("estimated_tokens", estimated),
+					slog.Int("truncated_tokens", fits),
+					slog.Int("limit", limit),
+				)
+			}
+			*request = trimmed
+			return nil
+		}
+	} else if config.PromptOverflowPolicy != PromptOverflowError {
+		return fmt.Errorf("unknown prompt overflow policy %q", config.PromptOverflowPolicy)
+	}
+
+	return &PromptTooLargeError{Model: model, Estimated: estimated, Limit: limit, Largest: largestMessages(tokenizer, model, requestMessages(*request))}
+}
+
+// largestMessages returns the biggest of messages, largest first
+func largestMessages(tokenizer Tokenizer, model string, messages []Message) []MessageTokens {
+	sizes := make([]MessageTokens, len(messages))
+	for i, msg := range messages {
+		sizes[i] = MessageTokens{Index: i, Role: msg.Role, Tokens: tokenizer.CountMessages(model, []Message{msg})}
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].Tokens > sizes[j].Tokens
+	})
+	return sizes[:min(len(sizes), maxPromptContributors)]
+}
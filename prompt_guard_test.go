@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPromptGuard(t *testing.T) {
+	// One token per word, plus 3 per message, 1 for the role and 3 per reply
+	words := TokenizerFunc(func(model, text string) int { return len(strings.Fields(text)) })
+	long := strings.Repeat("word ", 100)
+	request := func(last string) Request {
+		return NewRequest(WithSystem("Be brief."), WithUser(long), WithAssistant("Sure, noted."), WithUser(last))
+	}
+
+	var sent []chatMessage
+	calls := 0
+	server := newChatServer(t, func(r *http.Request) {
+		calls++
+		var payload chatPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		sent = payload.Messages
+	})
+	newClient := func(config Config) Client {
+		config.Provider, config.APIKey, config.BaseURL, config.Tokenizer = ProviderOpenAI, "test-key", server.URL, words
+		client, err := NewClient(config)
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	t.Run("error", func(t *testing.T) {
+		_, err := newClient(Config{MaxPromptTokens: 50}).Generate(context.Background(), request("Hi"))
+		var tooLarge *PromptTooLargeError
+		if !errors.Is(err, ErrPromptTooLarge) || !errors.As(err, &tooLarge) {
+			t.Fatalf("Expected a PromptTooLargeError, got %v", err)
+		}
+		if tooLarge.Limit != 50 || tooLarge.Estimated != 124 {
+			t.Errorf("Expected 124 tokens over a limit of 50, got %d and %d", tooLarge.Estimated, tooLarge.Limit)
+		}
+		if len(tooLarge.Largest) != 3 || tooLarge.Largest[0] != (MessageTokens{Index: 1, Role: RoleUser, Tokens: 107}) {
+			t.Errorf("Expected the long user message first, got %+v", tooLarge.Largest)
+		}
+		if calls != 0 {
+			t.Errorf("Expected no call to the provider, got %d", calls)
+		}
+	})
+
+	t.Run("truncate", func(t *testing.T) {
+		client := newClient(Config{MaxPromptTokens: 50, PromptOverflowPolicy: PromptOverflowTruncate})
+		if _, err := client.Generate(context.Background(), request("Hi")); err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if len(sent) != 2 || sent[0].Content != "Be brief." || sent[1].Content != "Hi" {
+			t.Errorf("Expected the system message and the last turn, got %+v", sent)
+		}
+
+		// The last turn is never dropped
+		_, err := client.Generate(context.Background(), request(long))
+		if !errors.Is(err, ErrPromptTooLarge) {
+			t.Errorf("Expected ErrPromptTooLarge when the last turn does not fit, got %v", err)
+		}
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		SetContextWindow("guard-test-model", 60)
+		maxTokens := 20
+		client := newClient(Config{DefaultModel: "guard-test-model-2025-01-01", DefaultMaxTokens: &maxTokens, MaxPromptTokens: PromptLimitAuto})
+		_, err := client.Generate(context.Background(), request("Hi"))
+		var tooLarge *PromptTooLargeError
+		if !errors.As(err, &tooLarge) || tooLarge.Limit != 40 {
+			t.Fatalf("Expected the context window less the output tokens as the limit, got %v", err)
+		}
+
+		// Models without a known window are not limited
+		unknown := request("Hi")
+		unknown.Apply(WithModel("unknown-model"))
+		if _, err := client.Generate(context.Background(), unknown); err != nil {
+			t.Errorf("Expected no limit for an unknown model, got %v", err)
+		}
+	})
+}
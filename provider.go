@@ -0,0 +1,154 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ProviderFactory creates a client from a configuration whose Provider is
+// the one the factory was registered for
+type ProviderFactory func(config Config) (Client, error)
+
+// ErrProviderRegistered is returned by RegisterProvider for a name that is
+// already registered or is an alias of a registered provider
+var ErrProviderRegistered = errors.New("provider already registered")
+
+var (
+	// registryMu guards providers, factories and providerAliases
+	registryMu sync.RWMutex
+	// providers lists the registered providers, the built-ins first in
+	// documentation order
+	providers = []Provider{ProviderOpenAI, ProviderDeepSeek, ProviderQwen, ProviderAzure, ProviderCohere, ProviderJina, ProviderGemini}
+	// factories creates the clients of the registered providers
+	factories = map[Provider]ProviderFactory{
+		ProviderOpenAI:   NewOpenAICompatibleClient,
+		ProviderDeepSeek: NewOpenAICompatibleClient,
+		ProviderQwen:     NewQwenClient,
+		ProviderAzure:    NewAzureClient,
+		ProviderCohere:   NewCohereClient,
+		ProviderJina:     NewJinaClient,
+		ProviderGemini:   NewGeminiClient,
+	}
+)
+
+// providerAliases maps normalized spellings to providers
+var providerAliases = map[string]Provider{
+	"openai":            ProviderOpenAI,
+	"open-ai":           ProviderOpenAI,
+	"openai-compatible": ProviderOpenAI,
+	"deepseek":          ProviderDeepSeek,
+	"deep-seek":         ProviderDeepSeek,
+	"qwen":              ProviderQwen,
+	"dashscope":         ProviderQwen,
+	"alibaba":           ProviderQwen,
+	"azure":             ProviderAzure,
+	"azure-openai":      ProviderAzure,
+	"azureopenai":       ProviderAzure,
+	"cohere":            ProviderCohere,
+	"cohere-ai":         ProviderCohere,
+	"jina":              ProviderJina,
+	"jina-ai":           ProviderJina,
+	"jinaai":            ProviderJina,
+	"gemini":            ProviderGemini,
+	"google":            ProviderGemini,
+	"google-ai":         ProviderGemini,
+	"google-gemini":     ProviderGemini,
+}
+
+// ParseProvider converts a provider name from configuration into a
+// Provider. Matching ignores case, surrounding space and the choice of "-",
+// "_" or " " as separator, and accepts common aliases such as
+// "azure-openai" and "openai-compatible".
+// Providers registered with RegisterProvider are matched the same way.
+func ParseProvider(s string) (Provider, error) {
+	registryMu.RLock()
+	provider, ok := providerAliases[providerKey(s)]
+	registryMu.RUnlock()
+	if ok {
+		return provider, nil
+	}
+	return "", fmt.Errorf("unsupported LLM provider %q (supported: %s)", s, supportedProviders())
+}
+
+// providerKey normalizes a provider name for providerAliases
+func providerKey(s string) string {
+	key := strings.ToLower(strings.TrimSpace(s))
+	return strings.NewReplacer("_", "-", " ", "-").Replace(key)
+}
+
+// Valid reports whether p is a built-in or registered provider
+func (p Provider) Valid() bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := factories[p]
+	return ok
+}
+
+// RegisterProvider makes NewClient create the clients of provider with
+// factory, so out-of-tree providers can be used without forking. The name
+// is then accepted by ParseProvider, matching like the built-in names. It
+// fails with ErrProviderRegistered when the name, in any spelling
+// ParseProvider accepts, is already taken, including by a built-in provider
+// or alias.
+func RegisterProvider(provider Provider, factory ProviderFactory) error {
+	key := providerKey(string(provider))
+	if key == "" || factory == nil {
+		return errors.New("provider name and factory are required")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if existing, ok := providerAliases[key]; ok {
+		return fmt.Errorf("%w: %q (as %q)", ErrProviderRegistered, provider, existing)
+	}
+	if _, ok := factories[provider]; ok {
+		return fmt.Errorf("%w: %q", ErrProviderRegistered, provider)
+	}
+	providers = append(providers, provider)
+	factories[provider] = factory
+	providerAliases[key] = provider
+	return nil
+}
+
+// Providers returns the built-in providers followed by those added with
+// RegisterProvider, in registration order
+func Providers() []Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]Provider(nil), providers...)
+}
+
+// providerFactory returns the factory registered for provider
+func providerFactory(provider Provider) (ProviderFactory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	factory, ok := factories[provider]
+	return factory, ok
+}
+
+// UnmarshalText normalizes provider names read from JSON, YAML or env
+// decoders with ParseProvider. An empty value is kept for auto-detection.
+func (p *Provider) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*p = ""
+		return nil
+	}
+	provider, err := ParseProvider(string(text))
+	if err != nil {
+		return err
+	}
+	*p = provider
+	return nil
+}
+
+// supportedProviders lists the registered providers for error messages
+func supportedProviders() string {
+	registered := Providers()
+	names := make([]string, len(registered))
+	for i, p := range registered {
+		names[i] = string(p)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,105 @@
+package llm
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseProvider(t *testing.T) {
+	tests := map[string]Provider{
+		"OpenAI":            ProviderOpenAI,
+		"open_ai":           ProviderOpenAI,
+		"openai-compatible": ProviderOpenAI,
+		" AZURE ":           ProviderAzure,
+		"Azure OpenAI":      ProviderAzure,
+		"azure_openai":      ProviderAzure,
+		"DeepSeek":          ProviderDeepSeek,
+		"dashscope":         ProviderQwen,
+		"Cohere":            ProviderCohere,
+	}
+	for input, want := range tests {
+		got, err := ParseProvider(input)
+		if err != nil || got != want {
+			t.Errorf("ParseProvider(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+
+	_, err := ParseProvider("anthropic")
+	if err == nil || !strings.Contains(err.Error(), "openai, deepseek, qwen, azure, cohere") {
+		t.Errorf("Expected an error listing supported providers, got %v", err)
+	}
+
+	if !ProviderQwen.Valid() || Provider("Qwen").Valid() {
+		t.Error("Valid should accept only canonical provider values")
+	}
+}
+
+func TestProviderNormalization(t *testing.T) {
+	var config Config
+	if err := json.Unmarshal([]byte(`{"provider":"Azure-OpenAI"}`), &config); err != nil || config.Provider != ProviderAzure {
+		t.Errorf("Expected JSON provider to normalize to azure, got %q, %v", config.Provider, err)
+	}
+	if err := json.Unmarshal([]byte(`{"provider":"nope"}`), &config); err == nil {
+		t.Error("Expected an error for an unknown provider in JSON")
+	}
+
+	client, err := NewClient(Config{Provider: "OpenAI", APIKey: "test-key"})
+	if err != nil || client.GetConfig().Provider != ProviderOpenAI {
+		t.Errorf("Expected NewClient to normalize the provider, got %v", err)
+	}
+	if _, err := NewClient(Config{Provider: "nope", APIKey: "test-key"}); err == nil || !strings.Contains(err.Error(), "supported:") {
+		t.Errorf("Expected NewClient to list supported providers, got %v", err)
+	}
+}
+
+// gateway is the provider TestRegisterProvider registers once per test
+// binary; gatewayConfig is the last config its factory received
+const gateway Provider = "acme-gateway"
+
+var (
+	registerGateway sync.Once
+	gatewayConfig   Config
+)
+
+func gatewayFactory(config Config) (Client, error) {
+	gatewayConfig = config
+	return NewOpenAICompatibleClient(config)
+}
+
+func TestRegisterProvider(t *testing.T) {
+	registerGateway.Do(func() {
+		if err := RegisterProvider(gateway, gatewayFactory); err != nil {
+			t.Fatalf("RegisterProvider failed: %v", err)
+		}
+	})
+
+	if _, err := NewClient(Config{Provider: "Acme_Gateway", APIKey: "test-key"}); err != nil {
+		t.Fatalf("NewClient failed: %v", err)
+	}
+	if gatewayConfig.Provider != gateway {
+		t.Errorf("Expected the factory to get the normalized provider, got %q", gatewayConfig.Provider)
+	}
+	if !gateway.Valid() || !slices.Contains(Providers(), gateway) {
+		t.Error("Expected the provider to be valid and listed")
+	}
+	var config Config
+	if err := json.Unmarshal([]byte(`{"provider":"ACME gateway"}`), &config); err != nil || config.Provider != gateway {
+		t.Errorf("Expected JSON provider to normalize to %s, got %q, %v", gateway, config.Provider, err)
+	}
+
+	for _, name := range []Provider{gateway, "ACME_GATEWAY", ProviderOpenAI, "google"} {
+		if err := RegisterProvider(name, gatewayFactory); !errors.Is(err, ErrProviderRegistered) {
+			t.Errorf("RegisterProvider(%q) = %v, want ErrProviderRegistered", name, err)
+		}
+	}
+	if err := RegisterProvider("", gatewayFactory); err == nil {
+		t.Error("Expected an empty name to be rejected")
+	}
+	if err := RegisterProvider("acme-other", nil); err == nil {
+		t.Error("Expected a nil factory to be rejected")
+	}
+}
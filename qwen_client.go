@@ -1,11 +1,9 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"time"
 )
@@ -14,6 +12,9 @@ import (
 type qwenClient struct {
 	config     Config
 	httpClient *http.Client
+	requests   *requestTracker
+	models     *modelCache
+	*liveConfig
 }
 
 // newQwenClient creates a new Qwen client
@@ -25,6 +26,10 @@ func newQwenClient(config Config) (*qwenClient, error) {
 	if config.BaseURL == "" {
 		config.BaseURL = "https://dashscope-intl.aliyuncs.com/compatible-mode/v1"
 	}
+	config, err := normalizeBaseURL(config, "")
+	if err != nil {
+		return nil, err
+	}
 
 	if config.Timeout == 0 {
 		config.Timeout = 30 * time.Second
@@ -33,19 +38,53 @@ func newQwenClient(config Config) (*qwenClient, error) {
 	if config.DefaultModel == "" {
 		config.DefaultModel = "qwen3-next-80b-a3b-instruct"
 	}
+	config = withEmbeddingDefault(config, "text-embedding-v3")
 
-	httpClient := &http.Client{
-		Timeout: config.Timeout,
+	httpClient, err := newHTTPClient(config)
+	if err != nil {
+		return nil, err
 	}
+	httpClient, requests := trackRequests(config, httpClient)
 
 	return &qwenClient{
 		config:     config,
 		httpClient: httpClient,
+		requests:   requests,
+		models:     &modelCache{},
+		liveConfig: newLiveConfig(config),
 	}, nil
 }
 
+// current returns a copy of the client with the configuration as of now, so
+// that a call sees one consistent set of defaults
+func (c *qwenClient) current() *qwenClient {
+	snapshot := *c
+	snapshot.config = c.load()
+	return &snapshot
+}
+
+// WithModel returns a view of the client using model by default
+func (c *qwenClient) WithModel(model string) Client {
+	return c.WithDefaults(WithModel(model))
+}
+
+// WithDefaults returns a view of the client with the defaults set by opts
+func (c *qwenClient) WithDefaults(opts ...RequestOption) Client {
+	view := c.current()
+	view.config = applyDefaults(view.config, opts)
+	view.httpClient, view.requests = c.requests.view(c.httpClient)
+	view.liveConfig = newLiveConfig(view.config)
+	return view
+}
+
 // Generate sends a request to Qwen and returns the response
 func (c *qwenClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	c = c.current()
+	return instrumentGenerate(ctx, c.config, c.getModel, request, c.generate)
+}
+
+// generate performs the chat call without instrumentation
+func (c *qwenClient) generate(ctx context.Context, request Request) (*Response, error) {
 	startTime := time.Now()
 
 	// Prepare the request payload
@@ -57,30 +96,31 @@ func (c *qwenClient) Generate(ctx context.Context, request Request) (*Response,
 	}
 
 	// Create HTTP request (use OpenAI-compatible endpoint)
-	req, err := http.NewRequestWithContext(ctx, "POST", c.config.BaseURL+"/chat/completions", bytes.NewBuffer(jsonPayload))
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/chat/completions"), jsonPayload)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
-	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
 	req.Header.Set("X-DashScope-SSE", "disable") // Disable SSE for simplicity
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
 
 	// Send request
-	resp, err := c.httpClient.Do(req)
+	resp, err := sendRequest(c.httpClient, c.config, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Read response
-	body, err := io.ReadAll(resp.Body)
+	body, err := readBody(c.config, resp, defaultMaxResponseBytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("Qwen API error %d: %s", resp.StatusCode, string(body))
+		return nil, newAPIError(c.config, "Qwen API error", resp, body)
 	}
 
 	// Parse response (OpenAI-compatible format for compatible-mode)
@@ -92,10 +132,15 @@ func (c *qwenClient) Generate(ctx context.Context, request Request) (*Response,
 			} `json:"message"`
 			FinishReason string `json:"finish_reason"`
 		} `json:"choices"`
+		Model string `json:"model"`
 		Usage struct {
-			PromptTokens     int `json:"prompt_tokens"`
-			CompletionTokens int `json:"completion_tokens"`
-			TotalTokens      int `json:"total_tokens"`
+			PromptTokens        int `json:"prompt_tokens"`
+			CompletionTokens    int `json:"completion_tokens"`
+			TotalTokens         int `json:"total_tokens"`
+			PromptTokensDetails struct {
+				CachedTokens             int `json:"cached_tokens"`
+				CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+			} `json:"prompt_tokens_details"`
 		} `json:"usage"`
 	}
 
@@ -104,16 +149,30 @@ func (c *qwenClient) Generate(ctx context.Context, request Request) (*Response,
 	}
 
 	if len(apiResp.Choices) == 0 {
-		return nil, fmt.Errorf("no choices in response")
+		return nil, &EmptyResponseError{Reason: "no choices", Body: body}
+	}
+	if emptyCompletion(apiResp.Choices[0].Message.Content, "", apiResp.Choices[0].FinishReason) {
+		return nil, &EmptyResponseError{Reason: "empty content", Body: body}
 	}
 
 	responseTime := time.Since(startTime)
 
 	return &Response{
-		Content:      apiResp.Choices[0].Message.Content,
-		Role:         RoleAssistant,
-		TokensUsed:   apiResp.Usage.TotalTokens,
-		ResponseTime: responseTime,
+		Content:    apiResp.Choices[0].Message.Content,
+		Role:       RoleAssistant,
+		TokensUsed: apiResp.Usage.TotalTokens,
+		Usage: Usage{
+			PromptTokens:        apiResp.Usage.PromptTokens,
+			CompletionTokens:    apiResp.Usage.CompletionTokens,
+			TotalTokens:         apiResp.Usage.TotalTokens,
+			CachedTokens:        apiResp.Usage.PromptTokensDetails.CachedTokens,
+			CacheCreationTokens: apiResp.Usage.PromptTokensDetails.CacheCreationInputTokens,
+		},
+		Model:           apiResp.Model,
+		ResponseTime:    responseTime,
+		RequestID:       req.Header.Get(requestIDHeader),
+		FinishReason:    normalizeFinishReason(openAIFinishReasons, apiResp.Choices[0].FinishReason),
+		RawFinishReason: apiResp.Choices[0].FinishReason,
 	}, nil
 }
 
@@ -126,65 +185,145 @@ func (c *qwenClient) GenerateWithHistory(ctx context.Context, history ChatHistor
 	return c.Generate(ctx, request)
 }
 
-// Close closes the client
+// Close stops new requests, waits up to Config.CloseTimeout for those in
+// flight and closes the client's idle connections
 func (c *qwenClient) Close() error {
-	return nil
+	return c.requests.close(c.config.CloseTimeout)
 }
 
-// GetConfig returns the client configuration
+// GetConfig returns a copy of the client configuration with the API key masked
 func (c *qwenClient) GetConfig() Config {
-	return c.config
+	return maskedConfig(c.load())
+}
+
+// GetConfigWithSecrets returns a copy of the client configuration including the API key
+func (c *qwenClient) GetConfigWithSecrets() Config {
+	return c.load().Clone()
+}
+
+// CountTokens estimates the prompt tokens of request using Config.Tokenizer
+func (c *qwenClient) CountTokens(request Request) int {
+	c = c.current()
+	return countRequestTokens(c.config, c.getModel(request.Model), request)
+}
+
+// Capabilities reports what the client supports for its provider
+func (c *qwenClient) Capabilities() Capabilities {
+	return providerCapabilities(c.config.Provider)
+}
+
+// ListModels lists the models of the compatible-mode /models endpoint
+func (c *qwenClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	c = c.current()
+	return c.models.get(ctx, c.config, c.fetchModels)
+}
+
+// fetchModels calls the /models endpoint without caching
+func (c *qwenClient) fetchModels(ctx context.Context) ([]ModelInfo, error) {
+	return listOpenAIModels(ctx, c.httpClient, c.config, bearerAuth(c.config.APIKey))
+}
+
+// Ping verifies credentials and reachability using Config.PingStrategy
+func (c *qwenClient) Ping(ctx context.Context) error {
+	c = c.current()
+	return ping(ctx, c.config, c.fetchModels, c.generate)
+}
+
+// Warmup opens idle connections to the provider ahead of the first call
+func (c *qwenClient) Warmup(ctx context.Context) error {
+	c = c.current()
+	return warmup(ctx, c.httpClient, c.config)
 }
 
 // CreateEmbedding generates embeddings for the given text(s)
 func (c *qwenClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
-	return nil, fmt.Errorf("embeddings not supported for Qwen provider yet")
+	c = c.current()
+	return instrumentEmbedding(ctx, c.config, c.getEmbeddingModel(request.Model), request, c.createEmbedding)
 }
 
-// buildPayload builds the request payload for Qwen API (OpenAI-compatible)
-func (c *qwenClient) buildPayload(request Request) map[string]interface{} {
-	// Convert messages to OpenAI format
-	var messages []map[string]interface{}
-	for _, msg := range request.Messages {
-		messages = append(messages, map[string]interface{}{
-			"role":    string(msg.Role),
-			"content": msg.Content,
-		})
-	}
+// createEmbedding calls the compatible-mode /embeddings endpoint, which
+// takes up to 25 inputs (Capabilities.MaxEmbeddingBatch, so larger requests
+// are split)
+func (c *qwenClient) createEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	startTime := time.Now()
 
+	// Compatible mode only returns float arrays, so base64 is not requested
 	payload := map[string]interface{}{
-		"model":      c.getModel(request.Model),
-		"messages":   messages,
-		"max_tokens": c.getMaxTokens(request.MaxTokens),
+		"model": c.getEmbeddingModel(request.Model),
+		"input": request.Input,
+	}
+	if request.Dimensions != nil {
+		payload["dimensions"] = *request.Dimensions
 	}
 
-	// Add temperature if set
-	if request.Temperature != nil {
-		payload["temperature"] = *request.Temperature
-	} else if c.config.DefaultTemperature != nil {
-		payload["temperature"] = *c.config.DefaultTemperature
+	jsonPayload, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
 	}
 
-	// Add top_p if set
-	if request.TopP != nil {
-		payload["top_p"] = *request.TopP
-	} else if c.config.DefaultTopP != nil {
-		payload["top_p"] = *c.config.DefaultTopP
+	req, err := newJSONRequest(ctx, c.config, endpointURL(c.config, "/embeddings"), jsonPayload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
 	}
 
-	// Add top_k if set (Qwen-specific parameter)
-	if request.TopK != nil {
-		payload["top_k"] = *request.TopK
-	} else if c.config.DefaultTopK != nil {
-		payload["top_k"] = *c.config.DefaultTopK
+	req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	setRequestID(ctx, req)
+	applyHeaders(req, c.config, request.Headers)
+
+	resp, err := sendRequest(c.httpClient, c.config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send embedding request: %w", err)
 	}
+	defer resp.Body.Close()
 
-	// Add any extra parameters (e.g., enable_thinking for models that support it)
-	// Users can pass enable_thinking via request.ExtraParams if needed
-	for key, value := range request.ExtraParams {
-		payload[key] = value
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, err := readBody(c.config, resp, defaultMaxEmbeddingResponseBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedding response: %w", err)
+		}
+		return nil, newAPIError(c.config, "Qwen Embedding API error", resp, body)
+	}
+
+	body, err := bodyReader(c.config, resp, defaultMaxEmbeddingResponseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedding response: %w", err)
+	}
+	response, err := parseOpenAIEmbeddings(body, request.AsFloat32)
+	if err != nil {
+		return nil, err
 	}
+	response.RequestID = req.Header.Get(requestIDHeader)
+	response.ResponseTime = time.Since(startTime)
+	return response, nil
+}
+
+// getEmbeddingModel returns the embedding model to use for the request
+func (c *qwenClient) getEmbeddingModel(override *string) string {
+	return resolveEmbeddingModel(c.config, override)
+}
 
+// buildPayload builds the request payload for Qwen API (OpenAI-compatible)
+func (c *qwenClient) buildPayload(request Request) chatPayload {
+	maxTokens := c.getMaxTokens(request.MaxTokens)
+	payload := newChatPayload(c.config, c.getModel(request.Model), request)
+	payload.Stream = nil
+	payload.MaxTokens = &maxTokens
+	// top_k is Qwen-specific
+	payload.TopK = orDefault(request.TopK, c.config.DefaultTopK)
+
+	for i, msg := range requestMessages(request) {
+		// names were never sent to Qwen
+		payload.Messages[i].Name = ""
+		if msg.CacheControl {
+			// Explicit cache breakpoints are only accepted on content blocks
+			payload.Messages[i].Content = []chatContentBlock{{
+				Type:         "text",
+				Text:         msg.Content,
+				CacheControl: &chatCacheControl{Type: "ephemeral"},
+			}}
+		}
+	}
+	// Users can pass enable_thinking via request.ExtraParams if needed
 	return payload
 }
 
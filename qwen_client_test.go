@@ -0,0 +1,54 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestQwenPromptCache(t *testing.T) {
+	var payload struct {
+		Messages []map[string]interface{} `json:"messages"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"model": "qwen-plus", "choices": [{"message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 1200, "completion_tokens": 2, "total_tokens": 1202,
+				"prompt_tokens_details": {"cached_tokens": 0, "cache_creation_input_tokens": 1180}}}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Provider: ProviderQwen, APIKey: "test-key", BaseURL: server.URL, DefaultModel: "qwen-plus"})
+	response, err := client.Generate(context.Background(), NewRequest(WithCachedSystem("A long static prompt."), WithUser("Hi")))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	want := []interface{}{map[string]interface{}{
+		"type":          "text",
+		"text":          "A long static prompt.",
+		"cache_control": map[string]interface{}{"type": "ephemeral"},
+	}}
+	if len(payload.Messages) != 2 || !reflect.DeepEqual(payload.Messages[0]["content"], want) {
+		t.Errorf("Expected a cache_control content block, got %v", payload.Messages)
+	}
+	if payload.Messages[1]["content"] != "Hi" {
+		t.Errorf("Expected plain content for uncached messages, got %v", payload.Messages[1])
+	}
+	if response.Usage.CacheCreationTokens != 1180 || response.Usage.CachedTokens != 0 {
+		t.Errorf("Unexpected usage %+v", response.Usage)
+	}
+
+	t.Run("merged system messages stay cacheable", func(t *testing.T) {
+		request := NewRequest(WithCachedSystem("Static."), WithSystem("Dynamic."), WithUser("Hi"))
+		if err := applySystemPolicy(Config{SystemMessagePolicy: SystemMessagesMerge}, &request); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !request.Messages[0].CacheControl || request.Messages[0].Content != "Static.\nDynamic." {
+			t.Errorf("Expected one cacheable system message, got %+v", request.Messages[0])
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter paces calls to a requests-per-minute and tokens-per-minute
+// budget, matching how providers publish their rate limits. Each budget
+// refills continuously and may be spent in a burst of up to one minute's
+// worth. A zero RateLimiter is unlimited; it is safe for concurrent use.
+type RateLimiter struct {
+	requestsPerMinute int
+	tokensPerMinute   int
+
+	mu       sync.Mutex
+	requests float64 // available, may go negative while callers wait
+	tokens   float64
+	last     time.Time
+}
+
+// NewRateLimiter creates a limiter for requestsPerMinute and
+// tokensPerMinute (0 = that dimension is not limited)
+func NewRateLimiter(requestsPerMinute, tokensPerMinute int) *RateLimiter {
+	return &RateLimiter{
+		requestsPerMinute: requestsPerMinute,
+		tokensPerMinute:   tokensPerMinute,
+		requests:          float64(requestsPerMinute),
+		tokens:            float64(tokensPerMinute),
+		last:              now(),
+	}
+}
+
+// Wait blocks until one request of the given token count fits the budget or
+// ctx ends. Callers are served in arrival order. Calls larger than a whole
+// minute of tokens wait for a full bucket instead of failing.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	l.refill()
+	tokens = l.clamp(tokens)
+	l.requests--
+	l.tokens -= float64(tokens)
+	wait := max(deficit(l.requests, l.requestsPerMinute), deficit(l.tokens, l.tokensPerMinute))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// give the reservation back to the callers behind us
+		l.mu.Lock()
+		l.requests++
+		l.tokens += float64(tokens)
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Peek returns how long a call of the given token count would wait if it
+// called Wait now, without reserving anything
+func (l *RateLimiter) Peek(tokens int) time.Duration {
+	if l == nil {
+		return 0
+	}
+	return l.peek(1, l.clamp(tokens))
+}
+
+// TryWait reserves one request of the given token count if it fits the
+// budget now and reports whether it did. It never blocks.
+func (l *RateLimiter) TryWait(tokens int) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	tokens = l.clamp(tokens)
+	if deficit(l.requests-1, l.requestsPerMinute) > 0 || deficit(l.tokens-float64(tokens), l.tokensPerMinute) > 0 {
+		return false
+	}
+	l.requests--
+	l.tokens -= float64(tokens)
+	return true
+}
+
+// clamp caps the token count of one call at a full bucket
+func (l *RateLimiter) clamp(tokens int) int {
+	if l.tokensPerMinute > 0 {
+		return min(tokens, l.tokensPerMinute)
+	}
+	return tokens
+}
+
+// peek returns how long the last of requests calls totalling tokens
+// (clamped) would wait if they called Wait now
+func (l *RateLimiter) peek(requests, tokens int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	return max(deficit(l.requests-float64(requests), l.requestsPerMinute), deficit(l.tokens-float64(tokens), l.tokensPerMinute))
+}
+
+// refill credits the budget for the time since the last call
+func (l *RateLimiter) refill() {
+	t := now()
+	elapsed := t.Sub(l.last).Minutes()
+	l.last = t
+	if l.requestsPerMinute > 0 {
+		l.requests = min(l.requests+elapsed*float64(l.requestsPerMinute), float64(l.requestsPerMinute))
+	}
+	if l.tokensPerMinute > 0 {
+		l.tokens = min(l.tokens+elapsed*float64(l.tokensPerMinute), float64(l.tokensPerMinute))
+	}
+}
+
+// deficit returns how long a budget refilling at perMinute takes to climb
+// from available back to zero
+func deficit(available float64, perMinute int) time.Duration {
+	if perMinute <= 0 || available >= 0 {
+		return 0
+	}
+	return time.Duration(-available / float64(perMinute) * float64(time.Minute))
+}
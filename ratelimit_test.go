@@ -0,0 +1,63 @@
+package llm
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	ctx := context.Background()
+	limiter := NewRateLimiter(0, 600) // 10 tokens per second
+
+	start := time.Now()
+	if err := limiter.Wait(ctx, 5000); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("A full bucket should not wait, waited %v", elapsed)
+	}
+
+	start = time.Now()
+	if err := limiter.Wait(ctx, 1); err != nil {
+		t.Fatalf("Wait failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Expected to wait ~100ms for the bucket to refill, waited %v", elapsed)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := limiter.Wait(cancelled, 600); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if limiter.tokens < -2 {
+		t.Errorf("A cancelled wait should return its reservation, %v tokens left", limiter.tokens)
+	}
+
+	var unlimited *RateLimiter
+	if err := unlimited.Wait(ctx, 1<<20); err != nil {
+		t.Errorf("A nil limiter should not limit: %v", err)
+	}
+}
+
+func TestRateLimiterPeek(t *testing.T) {
+	limiter := NewRateLimiter(0, 600) // 10 tokens per second
+	if wait := limiter.Peek(600); wait != 0 || !limiter.TryWait(500) {
+		t.Fatalf("Expected a full bucket to admit at once, peeked %v", wait)
+	}
+	if wait := limiter.Peek(200); wait < 9*time.Second || wait > 10*time.Second {
+		t.Errorf("Expected ~10s to refill 100 tokens, peeked %v", wait)
+	}
+	if limiter.TryWait(200) {
+		t.Error("Expected TryWait to refuse a call that would wait")
+	}
+	if limiter.tokens < 99 {
+		t.Errorf("Peek and a refused TryWait should not reserve, %v tokens left", limiter.tokens)
+	}
+
+	var unlimited *RateLimiter
+	if unlimited.Peek(1<<20) != 0 || !unlimited.TryWait(1<<20) {
+		t.Error("A nil limiter should not limit")
+	}
+}
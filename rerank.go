@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// RerankRequest asks a reranker to order Documents by relevance to Query
+type RerankRequest struct {
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+
+	// Model override (optional)
+	Model *string `json:"model,omitempty"`
+
+	// TopN limits the results to the most relevant documents (nil = all)
+	TopN *int `json:"top_n,omitempty"`
+
+	// ReturnDocuments echoes each document's text in RerankResult.Document
+	ReturnDocuments bool `json:"return_documents,omitempty"`
+
+	// Extra HTTP headers for this request only. Applied after Config.Headers.
+	Headers map[string]string `json:"-"`
+}
+
+// RerankResult is one reranked document
+type RerankResult struct {
+	// Index is the position of the document in RerankRequest.Documents
+	Index int     `json:"index"`
+	Score float64 `json:"score"`
+	// Document is set when RerankRequest.ReturnDocuments was
+	Document string `json:"document,omitempty"`
+}
+
+// RerankResponse holds reranked documents, most relevant first
+type RerankResponse struct {
+	Results      []RerankResult `json:"results"`
+	Model        string         `json:"model"`
+	Usage        Usage          `json:"usage"`
+	ResponseTime time.Duration  `json:"response_time"`
+
+	// RequestID is the X-Request-ID sent with the request (see WithRequestID)
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Reranker is implemented by clients whose provider offers a rerank
+// endpoint; check Capabilities().Rerank or call Rerank
+type Reranker interface {
+	Rerank(ctx context.Context, request RerankRequest) (*RerankResponse, error)
+}
+
+// Rerank reranks documents with client, failing with a CapabilityError
+// when its provider has no reranker
+func Rerank(ctx context.Context, client Client, request RerankRequest) (*RerankResponse, error) {
+	reranker, ok := client.(Reranker)
+	if !ok {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilityRerank}
+	}
+	return reranker.Rerank(ctx, request)
+}
+
+// rerankFunc performs a single provider rerank call
+type rerankFunc func(ctx context.Context, request RerankRequest) (*RerankResponse, error)
+
+// instrumentRerank runs a provider rerank call under the client timeout and
+// reports it like instrumentEmbedding does
+func instrumentRerank(ctx context.Context, config Config, model string, request RerankRequest, call rerankFunc) (*RerankResponse, error) {
+	startTime := time.Now()
+	response, err := withTimeout(ctx, config, "", 0, func(ctx context.Context) (*RerankResponse, error) {
+		return call(ctx, request)
+	})
+	latency := time.Since(startTime)
+
+	var usage Usage
+	if response != nil {
+		usage = response.Usage
+		if response.Model != "" {
+			model = response.Model
+		}
+	}
+	observe(ctx, config, OperationRerank, model, latency, usage, err)
+	if config.Logger != nil && config.Logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("provider", string(config.Provider)),
+			slog.String("model", model),
+			slog.Int("documents", len(request.Documents)),
+			slog.Duration("latency", latency),
+		}
+		logResult(ctx, config, "llm rerank", attrs, err)
+	}
+	if err == nil && response != nil {
+		reportUsage(ctx, config, UsageEvent{
+			Model:     model,
+			Operation: OperationRerank,
+			Usage:     usage,
+			Latency:   latency,
+			RequestID: response.RequestID,
+		})
+	}
+	return response, err
+}
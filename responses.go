@@ -0,0 +1,233 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// useResponsesAPI reports whether chat requests go to /responses (see
+// Config.UseResponsesAPI). DeepSeek shares the client but has no such endpoint.
+func (c *openAIClient) useResponsesAPI() bool {
+	return c.config.UseResponsesAPI && c.config.Provider != ProviderDeepSeek
+}
+
+// buildResponsesPayload builds the body of an OpenAI /responses request.
+// Messages become easy input messages (role and text), tool calls and
+// results become function_call and function_call_output items, MaxTokens
+// becomes max_output_tokens and nothing is stored server-side unless
+// ExtraParams sets "store".
+func (c *openAIClient) buildResponsesPayload(request Request) map[string]interface{} {
+	messages := requestMessages(request)
+	input := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleTool {
+			input = append(input, map[string]interface{}{
+				"type":    "function_call_output",
+				"call_id": msg.ToolCallID,
+				"output":  msg.Content,
+			})
+			continue
+		}
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			input = append(input, map[string]interface{}{
+				"role":    string(msg.Role),
+				"content": msg.Content,
+			})
+		}
+		for _, call := range msg.ToolCalls {
+			input = append(input, map[string]interface{}{
+				"type":      "function_call",
+				"call_id":   call.ID,
+				"name":      call.Name,
+				"arguments": call.arguments(),
+			})
+		}
+	}
+
+	payload := map[string]interface{}{
+		"model": c.getModel(request.Model),
+		"input": input,
+		"store": false,
+	}
+
+	if request.Temperature != nil {
+		payload["temperature"] = *request.Temperature
+	} else if c.config.DefaultTemperature != nil {
+		payload["temperature"] = *c.config.DefaultTemperature
+	}
+
+	if request.MaxTokens != nil {
+		payload["max_output_tokens"] = *request.MaxTokens
+	} else if c.config.DefaultMaxTokens != nil {
+		payload["max_output_tokens"] = *c.config.DefaultMaxTokens
+	}
+
+	if request.TopP != nil {
+		payload["top_p"] = *request.TopP
+	} else if c.config.DefaultTopP != nil {
+		payload["top_p"] = *c.config.DefaultTopP
+	}
+
+	for k, v := range request.ExtraParams {
+		payload[k] = v
+	}
+	return payload
+}
+
+// parseResponse normalizes an OpenAI /responses body into a Response. Text
+// of all output messages is joined, reasoning summaries go to
+// ReasoningContent, and the status, or the reason of an incomplete response,
+// is normalized into FinishReason, which is FinishToolCalls when the model
+// called a function (hosted or ExtraParams tools).
+func parseResponse(body []byte) (*Response, error) {
+	var apiResp struct {
+		Status            string `json:"status"`
+		Model             string `json:"model"`
+		IncompleteDetails *struct {
+			Reason string `json:"reason"`
+		} `json:"incomplete_details"`
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Output []struct {
+			Type string `json:"type"`
+			Role string `json:"role"`
+			// function_call items
+			CallID    string `json:"call_id"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+			Content   []struct {
+				Type    string `json:"type"`
+				Text    string `json:"text"`
+				Refusal string `json:"refusal"`
+			} `json:"content"`
+			Summary []struct {
+				Text string `json:"text"`
+			} `json:"summary"`
+		} `json:"output"`
+		Usage struct {
+			InputTokens        int `json:"input_tokens"`
+			OutputTokens       int `json:"output_tokens"`
+			TotalTokens        int `json:"total_tokens"`
+			InputTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"input_tokens_details"`
+		} `json:"usage"`
+	}
+
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if apiResp.Status == "failed" && apiResp.Error != nil {
+		return nil, fmt.Errorf("response failed: %s: %s", apiResp.Error.Code, apiResp.Error.Message)
+	}
+	if len(apiResp.Output) == 0 {
+		return nil, fmt.Errorf("no output in LLM response")
+	}
+
+	var content, reasoning strings.Builder
+	role := RoleAssistant
+	toolCall := false
+	var toolCalls []ToolCall
+	for _, item := range apiResp.Output {
+		switch item.Type {
+		case "message":
+			if item.Role != "" {
+				role = MessageRole(item.Role)
+			}
+			for _, part := range item.Content {
+				switch part.Type {
+				case "output_text":
+					content.WriteString(part.Text)
+				case "refusal":
+					content.WriteString(part.Refusal)
+				}
+			}
+		case "reasoning":
+			for _, summary := range item.Summary {
+				if reasoning.Len() > 0 {
+					reasoning.WriteString("\n\n")
+				}
+				reasoning.WriteString(summary.Text)
+			}
+		case "function_call":
+			toolCall = true
+			toolCalls = append(toolCalls, ToolCall{ID: item.CallID, Name: item.Name, Arguments: item.Arguments})
+		case "custom_tool_call":
+			toolCall = true
+		}
+	}
+
+	rawFinishReason := apiResp.Status
+	if apiResp.Status == "incomplete" && apiResp.IncompleteDetails != nil {
+		rawFinishReason = apiResp.IncompleteDetails.Reason
+	}
+	finishReason := normalizeFinishReason(responsesFinishReasons, rawFinishReason)
+	if toolCall && finishReason == FinishStop {
+		finishReason = FinishToolCalls
+	}
+
+	return &Response{
+		Content:    content.String(),
+		Role:       role,
+		TokensUsed: apiResp.Usage.TotalTokens,
+		Usage: Usage{
+			PromptTokens:     apiResp.Usage.InputTokens,
+			CompletionTokens: apiResp.Usage.OutputTokens,
+			TotalTokens:      apiResp.Usage.TotalTokens,
+			CachedTokens:     apiResp.Usage.InputTokensDetails.CachedTokens,
+		},
+		Model:            apiResp.Model,
+		FinishReason:     finishReason,
+		RawFinishReason:  rawFinishReason,
+		ReasoningContent: reasoning.String(),
+		ToolCalls:        toolCalls,
+	}, nil
+}
+
+// decodeResponseEvent decodes one Responses API stream event. Text,
+// reasoning summary and function call deltas are forwarded; the terminal response.completed,
+// response.incomplete or response.failed event carries the full response,
+// from which the finish reason and usage are taken.
+func decodeResponseEvent(event sseEvent) (StreamChunk, error) {
+	var apiEvent struct {
+		Type        string `json:"type"`
+		Delta       string `json:"delta"`
+		OutputIndex int    `json:"output_index"`
+		Item        *struct {
+			Type   string `json:"type"`
+			CallID string `json:"call_id"`
+			Name   string `json:"name"`
+		} `json:"item"`
+		Response json.RawMessage `json:"response"`
+		Message  string          `json:"message"`
+	}
+	if err := json.Unmarshal(event.Data, &apiEvent); err != nil {
+		return StreamChunk{}, fmt.Errorf("failed to unmarshal stream event: %w", err)
+	}
+
+	switch apiEvent.Type {
+	case "response.output_text.delta", "response.refusal.delta":
+		return StreamChunk{Content: apiEvent.Delta}, nil
+	case "response.reasoning_summary_text.delta":
+		return StreamChunk{ReasoningContent: apiEvent.Delta}, nil
+	case "response.output_item.added":
+		if apiEvent.Item == nil || apiEvent.Item.Type != "function_call" {
+			return StreamChunk{}, nil
+		}
+		return StreamChunk{ToolCallDeltas: []ToolCallDelta{{Index: apiEvent.OutputIndex, ID: apiEvent.Item.CallID, Name: apiEvent.Item.Name}}}, nil
+	case "response.function_call_arguments.delta":
+		return StreamChunk{ToolCallDeltas: []ToolCallDelta{{Index: apiEvent.OutputIndex, Arguments: apiEvent.Delta}}}, nil
+	case "response.completed", "response.incomplete", "response.failed":
+		response, err := parseResponse(apiEvent.Response)
+		if err != nil {
+			return StreamChunk{}, err
+		}
+		return StreamChunk{FinishReason: response.FinishReason, RawFinishReason: response.RawFinishReason, Usage: &response.Usage}, nil
+	case "error":
+		return StreamChunk{}, fmt.Errorf("stream error: %s", apiEvent.Message)
+	}
+	return StreamChunk{}, nil
+}
@@ -0,0 +1,133 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponsesAPI(t *testing.T) {
+	var path string
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{
+			"id": "resp_1", "object": "response", "status": "completed", "model": "gpt-4.1-mini-2025-04-14",
+			"output": [
+				{"type": "reasoning", "id": "rs_1", "summary": [{"type": "summary_text", "text": "Recall the capital."}]},
+				{"type": "message", "id": "msg_1", "role": "assistant", "status": "completed",
+				 "content": [{"type": "output_text", "text": "Paris", "annotations": []}, {"type": "output_text", "text": ".", "annotations": []}]}
+			],
+			"usage": {"input_tokens": 20, "input_tokens_details": {"cached_tokens": 8}, "output_tokens": 3, "output_tokens_details": {"reasoning_tokens": 0}, "total_tokens": 23}
+		}`))
+	}))
+	defer server.Close()
+
+	maxTokens := 50
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DisableBaseURLVersion: true, DefaultModel: "gpt-4.1-mini", UseResponsesAPI: true})
+	request := BuildRequestWithSystemPrompt("Answer briefly.", "What is the capital of France?")
+	request.MaxTokens = &maxTokens
+	response, err := client.Generate(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+
+	if path != "/responses" {
+		t.Errorf("Expected /responses, got %s", path)
+	}
+	input, _ := payload["input"].([]interface{})
+	if len(input) != 2 || input[0].(map[string]interface{})["role"] != "system" || payload["max_output_tokens"] != float64(50) || payload["store"] != false {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+	if _, ok := payload["messages"]; ok {
+		t.Error("Responses payload must not carry messages")
+	}
+	if response.Content != "Paris." || response.Role != RoleAssistant || response.FinishReason != "stop" {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if response.ReasoningContent != "Recall the capital." || response.Model != "gpt-4.1-mini-2025-04-14" {
+		t.Errorf("Unexpected reasoning or model: %+v", response)
+	}
+	if response.Usage != (Usage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23, CachedTokens: 8}) || response.TokensUsed != 23 {
+		t.Errorf("Unexpected usage %+v", response.Usage)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		content      string
+		finishReason FinishReason
+		wantErr      bool
+	}{
+		{
+			name: "function call",
+			body: `{"status": "completed", "output": [{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}],
+				"usage": {"input_tokens": 30, "output_tokens": 12, "total_tokens": 42}}`,
+			finishReason: "tool_calls",
+		},
+		{
+			name: "max output tokens",
+			body: `{"status": "incomplete", "incomplete_details": {"reason": "max_output_tokens"},
+				"output": [{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Once upon"}]}]}`,
+			content:      "Once upon",
+			finishReason: "length",
+		},
+		{
+			name: "content filter",
+			body: `{"status": "incomplete", "incomplete_details": {"reason": "content_filter"},
+				"output": [{"type": "message", "role": "assistant", "content": [{"type": "refusal", "refusal": "I can't help with that."}]}]}`,
+			content:      "I can't help with that.",
+			finishReason: "content_filter",
+		},
+		{
+			name:    "failed",
+			body:    `{"status": "failed", "error": {"code": "server_error", "message": "boom"}, "output": []}`,
+			wantErr: true,
+		},
+		{
+			name:    "no output",
+			body:    `{"status": "completed", "output": []}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := parseResponse([]byte(tt.body))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseResponse failed: %v", err)
+			}
+			if response.Content != tt.content || response.FinishReason != tt.finishReason {
+				t.Errorf("Expected %q/%q, got %q/%q", tt.content, tt.finishReason, response.Content, response.FinishReason)
+			}
+		})
+	}
+}
+
+func TestResponsesAPIIgnoredForDeepSeek(t *testing.T) {
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "hi"}, "finish_reason": "stop"}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Provider: ProviderDeepSeek, APIKey: "test-key", BaseURL: server.URL, UseResponsesAPI: true})
+	if _, err := client.Generate(context.Background(), BuildSimpleRequest("hi")); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if path != "/chat/completions" {
+		t.Errorf("Expected DeepSeek to use /chat/completions, got %s", path)
+	}
+}
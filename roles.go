@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+)
+
+// RoleOrderPolicy decides what happens when a request's messages do not
+// alternate between user and assistant, or start with an assistant message,
+// on providers that reject such sequences (Gemini). Truncated histories often
+// produce them.
+type RoleOrderPolicy string
+
+const (
+	// RoleOrderNormalize merges consecutive messages of the same role and
+	// drops assistant messages before the first user message, logging a
+	// warning to Config.Logger when it changes anything (the default)
+	RoleOrderNormalize RoleOrderPolicy = ""
+	// RoleOrderKeep sends the messages as they are
+	RoleOrderKeep RoleOrderPolicy = "keep"
+	// RoleOrderError fails the call with ErrRoleOrder
+	RoleOrderError RoleOrderPolicy = "error"
+)
+
+// ErrRoleOrder is returned under RoleOrderError
+var ErrRoleOrder = errors.New("messages do not alternate between user and assistant")
+
+// normalizeRoleOrder applies Config.RoleOrderPolicy to messages for a
+// provider that needs strictly alternating turns starting with the user.
+// System messages are left in place and do not count as turns; every role
+// other than assistant counts as a user turn. Consecutive messages of a turn
+// that carry tool calls or results are kept apart for the provider to group.
+func normalizeRoleOrder(ctx context.Context, config Config, messages []Message) ([]Message, error) {
+	if config.RoleOrderPolicy == RoleOrderKeep {
+		return messages, nil
+	}
+	if config.RoleOrderPolicy != RoleOrderNormalize && config.RoleOrderPolicy != RoleOrderError {
+		return nil, fmt.Errorf("unknown role order policy %q", config.RoleOrderPolicy)
+	}
+
+	result := make([]Message, 0, len(messages))
+	last := -1 // index in result of the previous turn
+	dropped, merged := 0, 0
+	for _, msg := range messages {
+		switch {
+		case msg.Role == RoleSystem:
+			result = append(result, msg)
+			continue
+		case last < 0 && msg.Role == RoleAssistant:
+			dropped++
+			continue
+		case last >= 0 && (result[last].Role == RoleAssistant) == (msg.Role == RoleAssistant):
+			if hasToolData(result[last]) || hasToolData(msg) {
+				// Tool calls and results cannot be merged as text; the
+				// provider groups them into one turn
+				break
+			}
+			merged++
+			result[last].Content += "\n\n" + msg.Content
+			result[last].CacheControl = result[last].CacheControl || msg.CacheControl
+			continue
+		}
+		result = append(result, msg)
+		last = len(result) - 1
+	}
+
+	if dropped == 0 && merged == 0 {
+		return messages, nil
+	}
+	if config.RoleOrderPolicy == RoleOrderError {
+		return nil, fmt.Errorf("%w (%d leading assistant messages, %d consecutive same-role messages)", ErrRoleOrder, dropped, merged)
+	}
+	if config.Logger != nil {
+		config.Logger.LogAttrs(ctx, slog.LevelWarn, "llm: normalized message roles",
+			slog.String("provider", string(config.Provider)),
+			slog.Int("dropped_leading_assistant", dropped),
+			slog.Int("merged", merged),
+		)
+	}
+	return result, nil
+}
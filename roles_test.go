@@ -0,0 +1,134 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// roles returns the role and content of each message as "role:content"
+func roles(messages []Message) []string {
+	result := make([]string, len(messages))
+	for i, msg := range messages {
+		result[i] = string(msg.Role) + ":" + msg.Content
+	}
+	return result
+}
+
+func TestNormalizeRoleOrder(t *testing.T) {
+	// A history truncated mid-conversation keeps the system prompt but now
+	// starts with the assistant's reply to a dropped question
+	var history ChatHistory
+	history.AddSystemMessage("Be brief.")
+	for _, turn := range []string{"one", "two", "three"} {
+		history.AddUserMessage("Q " + turn)
+		history.AddAssistantMessage("A " + turn)
+	}
+	history.Truncate(4)
+	request := BuildChatRequest(history.GetMessages(), "Q four")
+
+	tests := []struct {
+		name     string
+		messages []Message
+		want     []string
+	}{
+		{
+			name:     "truncated history",
+			messages: request.Messages,
+			want:     []string{"system:Be brief.", "user:Q three", "assistant:A three", "user:Q four"},
+		},
+		{
+			name: "consecutive user messages",
+			messages: []Message{
+				{Role: RoleUser, Content: "Here is the document."},
+				{Role: RoleUser, Content: "Summarize it."},
+				{Role: RoleAssistant, Content: "It says"},
+				{Role: RoleAssistant, Content: "hello."},
+				{Role: RoleSystem, Content: "Be brief."},
+				{Role: RoleUser, Content: "Shorter."},
+			},
+			want: []string{"user:Here is the document.\n\nSummarize it.", "assistant:It says\n\nhello.", "system:Be brief.", "user:Shorter."},
+		},
+		{
+			name:     "only assistant messages",
+			messages: []Message{{Role: RoleAssistant, Content: "Hello!"}},
+			want:     []string{},
+		},
+		{
+			name:     "already alternating",
+			messages: []Message{{Role: RoleUser, Content: "Hi"}, {Role: RoleAssistant, Content: "Hello!"}, {Role: RoleUser, Content: "Bye"}},
+			want:     []string{"user:Hi", "assistant:Hello!", "user:Bye"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeRoleOrder(context.Background(), Config{}, tt.messages)
+			if err != nil {
+				t.Fatalf("normalizeRoleOrder failed: %v", err)
+			}
+			if !reflect.DeepEqual(roles(got), tt.want) {
+				t.Errorf("Expected %q, got %q", tt.want, roles(got))
+			}
+		})
+	}
+
+	t.Run("caller's messages untouched", func(t *testing.T) {
+		messages := []Message{{Role: RoleUser, Content: "a"}, {Role: RoleUser, Content: "b"}}
+		normalizeRoleOrder(context.Background(), Config{}, messages)
+		if messages[0].Content != "a" {
+			t.Errorf("Expected the input to be left alone, got %q", messages[0].Content)
+		}
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		got, err := normalizeRoleOrder(context.Background(), Config{RoleOrderPolicy: RoleOrderKeep}, request.Messages)
+		if err != nil || len(got) != len(request.Messages) {
+			t.Errorf("Expected the messages as they are, got %q, %v", roles(got), err)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := normalizeRoleOrder(context.Background(), Config{RoleOrderPolicy: RoleOrderError}, request.Messages)
+		if !errors.Is(err, ErrRoleOrder) {
+			t.Errorf("Expected ErrRoleOrder, got %v", err)
+		}
+	})
+
+	t.Run("logged", func(t *testing.T) {
+		var logs bytes.Buffer
+		config := Config{Provider: ProviderGemini, Logger: slog.New(slog.NewTextHandler(&logs, nil))}
+		normalizeRoleOrder(context.Background(), config, request.Messages)
+		if !strings.Contains(logs.String(), "level=WARN") || !strings.Contains(logs.String(), "dropped_leading_assistant=1") {
+			t.Errorf("Expected a warning, got %q", logs.String())
+		}
+	})
+}
+
+func TestGeminiRoleOrder(t *testing.T) {
+	var payload struct {
+		Contents []struct {
+			Role string `json:"role"`
+		} `json:"contents"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+		w.Write([]byte(`{"candidates": [{"content": {"parts": [{"text": "Hi"}], "role": "model"}, "finishReason": "STOP"}]}`))
+	}))
+	defer server.Close()
+
+	client, _ := NewClient(Config{Provider: ProviderGemini, APIKey: "test-key", BaseURL: server.URL})
+	request := NewRequest(WithAssistant("Hello, how can I help?"), WithUser("Hi"), WithUser("What's new?"))
+	if _, err := client.Generate(context.Background(), request); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if len(payload.Contents) != 1 || payload.Contents[0].Role != "user" {
+		t.Errorf("Expected a single user turn, got %+v", payload.Contents)
+	}
+}
@@ -0,0 +1,60 @@
+package llm
+
+// HarmCategory is a Gemini safety category
+type HarmCategory string
+
+// Gemini harm categories
+const (
+	HarmCategoryHarassment       HarmCategory = "HARM_CATEGORY_HARASSMENT"
+	HarmCategoryHateSpeech       HarmCategory = "HARM_CATEGORY_HATE_SPEECH"
+	HarmCategorySexuallyExplicit HarmCategory = "HARM_CATEGORY_SEXUALLY_EXPLICIT"
+	HarmCategoryDangerousContent HarmCategory = "HARM_CATEGORY_DANGEROUS_CONTENT"
+	HarmCategoryCivicIntegrity   HarmCategory = "HARM_CATEGORY_CIVIC_INTEGRITY"
+)
+
+// HarmBlockThreshold is the probability from which Gemini blocks a category
+type HarmBlockThreshold string
+
+// Gemini block thresholds, from most to least permissive
+const (
+	// BlockOff disables the safety filter for the category
+	BlockOff            HarmBlockThreshold = "OFF"
+	BlockNone           HarmBlockThreshold = "BLOCK_NONE"
+	BlockOnlyHigh       HarmBlockThreshold = "BLOCK_ONLY_HIGH"
+	BlockMediumAndAbove HarmBlockThreshold = "BLOCK_MEDIUM_AND_ABOVE"
+	BlockLowAndAbove    HarmBlockThreshold = "BLOCK_LOW_AND_ABOVE"
+)
+
+// SafetySetting sets the block threshold of one harm category. Only Gemini
+// honors safety settings; other providers ignore them.
+type SafetySetting struct {
+	Category  HarmCategory       `json:"category"`
+	Threshold HarmBlockThreshold `json:"threshold"`
+}
+
+// WithSafetySetting sets the block threshold of category for this request,
+// overriding Config.SafetySettings for that category
+func WithSafetySetting(category HarmCategory, threshold HarmBlockThreshold) RequestOption {
+	return func(r *Request) {
+		r.SafetySettings = append(r.SafetySettings, SafetySetting{Category: category, Threshold: threshold})
+	}
+}
+
+// mergeSafetySettings returns the client settings with the request settings
+// applied on top: a request entry replaces the client entry of its category
+func mergeSafetySettings(client, request []SafetySetting) []SafetySetting {
+	if len(request) == 0 {
+		return client
+	}
+	merged := make([]SafetySetting, 0, len(client)+len(request))
+	index := make(map[HarmCategory]int, len(client)+len(request))
+	for _, setting := range append(append([]SafetySetting(nil), client...), request...) {
+		if i, ok := index[setting.Category]; ok {
+			merged[i] = setting
+			continue
+		}
+		index[setting.Category] = len(merged)
+		merged = append(merged, setting)
+	}
+	return merged
+}
@@ -0,0 +1,408 @@
+package llm
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrLoadShed is returned by SchedulerClient when a low-priority call
+// arrives while its queue is longer than SchedulerConfig.MaxQueue
+var ErrLoadShed = errors.New("load shed")
+
+// Priority orders calls in a SchedulerClient queue: higher values go first.
+// The zero value is PriorityNormal.
+type Priority int
+
+// Common priorities; any other value orders between or around them
+const (
+	PriorityLow    Priority = -1
+	PriorityNormal Priority = 0
+	PriorityHigh   Priority = 1
+)
+
+// String returns "low", "normal", "high" or the number of a custom priority
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("%d", int(p))
+}
+
+// SchedulerConfig configures NewSchedulerClient
+type SchedulerConfig struct {
+	// MaxConcurrency is the number of calls in flight (0 = unlimited)
+	MaxConcurrency int
+
+	// Limiter paces calls by request and estimated token count (nil =
+	// unlimited). Calls reserve capacity one at a time in priority order, so
+	// a high-priority call never waits behind queued low-priority ones.
+	Limiter *RateLimiter
+
+	// MaxQueue is the queue depth beyond which calls with a priority below
+	// ShedBelow fail with ErrLoadShed instead of queueing (0 = never shed)
+	MaxQueue int
+
+	// ShedBelow is the priority under which calls may be shed (zero value =
+	// PriorityNormal, so that only low-priority calls are shed)
+	ShedBelow Priority
+
+	// OnAdmit receives the priority of every call that leaves the queue and
+	// the time it waited for a concurrency slot and the limiter, for example
+	// to feed a histogram. It must be safe for concurrent use.
+	OnAdmit func(priority Priority, wait time.Duration)
+}
+
+// SchedulerStats is a snapshot of a SchedulerClient queue
+type SchedulerStats struct {
+	// Running is the number of calls in flight
+	Running int
+	// Queued is the number of calls waiting
+	Queued int
+	// Priorities breaks the queue down by priority
+	Priorities map[Priority]PriorityStats
+}
+
+// PriorityStats is the queue activity of one priority since the
+// SchedulerClient was created
+type PriorityStats struct {
+	// Queued is the number of calls of this priority waiting now
+	Queued int
+	// Admitted and Shed count the calls that left the queue and those
+	// rejected with ErrLoadShed
+	Admitted int64
+	Shed     int64
+	// TotalWait is the time admitted calls spent queued (TotalWait /
+	// Admitted is the mean wait); MaxWait is the longest of those waits
+	TotalWait time.Duration
+	MaxWait   time.Duration
+}
+
+// SchedulerClient wraps a Client and queues its calls by priority in front
+// of a concurrency limit and a rate limiter
+type SchedulerClient struct {
+	Client
+	config SchedulerConfig
+
+	mu        sync.Mutex
+	queue     schedulerQueue
+	seq       uint64
+	running   int
+	admitting bool // a call holds the turn to reserve limiter capacity
+	stats     map[Priority]*PriorityStats
+}
+
+// schedulerWaiter is a call waiting in the queue
+type schedulerWaiter struct {
+	priority Priority
+	tokens   int // estimated, for the limiter
+	seq      uint64
+	ready    chan struct{} // closed when the call is dequeued
+	index    int           // position in the heap, -1 once dequeued
+}
+
+// NewSchedulerClient returns a client that admits Generate,
+// GenerateWithHistory, GenerateStream and CreateEmbedding calls to inner in priority order,
+// then in arrival order, once a concurrency slot is free and the limiter
+// allows. The priority of a call is Request.Priority if set, else the one
+// set by WithPriority. Calls cancelled while queued return the context
+// error; the other calls are not scheduled.
+func NewSchedulerClient(inner Client, config SchedulerConfig) *SchedulerClient {
+	return &SchedulerClient{
+		Client: inner,
+		config: config,
+		stats:  make(map[Priority]*PriorityStats),
+	}
+}
+
+// Generate sends the request once the scheduler admits it
+func (c *SchedulerClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	release, err := c.acquire(ctx, c.priority(ctx, request), c.chatTokens(request))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Client.Generate(ctx, request)
+}
+
+// GenerateStream starts the stream once the scheduler admits it. The stream
+// holds its concurrency slot until it ends.
+func (c *SchedulerClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	release, err := c.acquire(ctx, c.priority(ctx, request), c.chatTokens(request))
+	if err != nil {
+		return nil, err
+	}
+	response, err := GenerateStream(ctx, c.Client, request)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return relayStream(ctx, response, func(*StreamChunk) { release() }), nil
+}
+
+// TryGenerate sends the request only if the scheduler, and the client it
+// wraps, can admit it at once, and fails with ErrWouldBlock otherwise
+func (c *SchedulerClient) TryGenerate(ctx context.Context, request Request) (*Response, error) {
+	release, err := c.tryAcquire(c.priority(ctx, request), c.chatTokens(request))
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return TryGenerate(ctx, c.Client, request)
+}
+
+// EstimateWait reports the calls queued ahead of request, whether the
+// concurrency slots are all busy and the rate limiter wait once the calls
+// ahead have reserved their share, added to the estimate of the wrapped client
+func (c *SchedulerClient) EstimateWait(ctx context.Context, request Request) WaitEstimate {
+	priority, tokens := c.priority(ctx, request), c.chatTokens(request)
+	c.mu.Lock()
+	var estimate WaitEstimate
+	for _, waiter := range c.queue {
+		if waiter.priority >= priority {
+			estimate.Queued++
+			tokens += waiter.tokens
+		}
+	}
+	estimate.Saturated = c.saturated()
+	c.mu.Unlock()
+	if c.config.Limiter != nil {
+		estimate.Wait = c.config.Limiter.peek(estimate.Queued+1, tokens)
+	}
+	return estimate.add(EstimateWait(ctx, c.Client, request))
+}
+
+// GenerateWithHistory sends the conversation once the scheduler admits it
+func (c *SchedulerClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	request := BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// CreateEmbedding embeds the input once the scheduler admits it
+func (c *SchedulerClient) CreateEmbedding(ctx context.Context, request EmbeddingRequest) (*EmbeddingResponse, error) {
+	priority, _ := PriorityFromContext(ctx)
+	tokens := 0
+	if c.config.Limiter != nil {
+		config := c.Client.GetConfig()
+		model := resolveEmbeddingModel(config, request.Model)
+		tokenizer := tokenizerFor(config)
+		for _, text := range request.Input {
+			tokens += tokenizer.CountTokens(model, text)
+		}
+		tokens = c.config.Limiter.clamp(tokens)
+	}
+	release, err := c.acquire(ctx, priority, tokens)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return c.Client.CreateEmbedding(ctx, request)
+}
+
+// Stats returns the current queue depth and the wait times so far
+func (c *SchedulerClient) Stats() SchedulerStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := SchedulerStats{
+		Running:    c.running,
+		Queued:     len(c.queue),
+		Priorities: make(map[Priority]PriorityStats, len(c.stats)),
+	}
+	for priority, s := range c.stats {
+		stats.Priorities[priority] = *s
+	}
+	return stats
+}
+
+// acquire queues a call until it holds a concurrency slot and its limiter
+// reservation. The returned release frees the slot.
+func (c *SchedulerClient) acquire(ctx context.Context, priority Priority, tokens int) (func(), error) {
+	enqueued := now()
+	c.mu.Lock()
+	stats := c.priorityStats(priority)
+	if c.config.MaxQueue > 0 && priority < c.config.ShedBelow && len(c.queue) >= c.config.MaxQueue {
+		stats.Shed++
+		depth := len(c.queue)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: %d calls queued", ErrLoadShed, depth)
+	}
+	c.seq++
+	waiter := &schedulerWaiter{priority: priority, tokens: tokens, seq: c.seq, ready: make(chan struct{})}
+	heap.Push(&c.queue, waiter)
+	stats.Queued++
+	c.dispatch()
+	c.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+	case <-ctx.Done():
+		c.mu.Lock()
+		if waiter.index >= 0 {
+			heap.Remove(&c.queue, waiter.index)
+			stats.Queued--
+			c.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		// dequeued concurrently: hand the turn and the slot back
+		c.admitted(false)
+		c.mu.Unlock()
+		return nil, ctx.Err()
+	}
+
+	// Reserving limiter capacity while holding the turn keeps the limiter's
+	// own FIFO in priority order
+	err := c.config.Limiter.Wait(ctx, tokens)
+	wait := now().Sub(enqueued)
+	c.mu.Lock()
+	c.admitted(err == nil)
+	if err == nil {
+		stats.Admitted++
+		stats.TotalWait += wait
+		stats.MaxWait = max(stats.MaxWait, wait)
+	}
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if c.config.OnAdmit != nil {
+		c.config.OnAdmit(priority, wait)
+	}
+
+	return c.releaser(), nil
+}
+
+// tryAcquire takes a concurrency slot and a limiter reservation if both are
+// available without waiting behind queued calls, or fails with ErrWouldBlock
+func (c *SchedulerClient) tryAcquire(priority Priority, tokens int) (func(), error) {
+	c.mu.Lock()
+	for _, waiter := range c.queue {
+		if waiter.priority >= priority {
+			c.mu.Unlock()
+			return nil, ErrWouldBlock
+		}
+	}
+	if c.admitting || c.saturated() || !c.config.Limiter.TryWait(tokens) {
+		c.mu.Unlock()
+		return nil, ErrWouldBlock
+	}
+	c.running++
+	c.priorityStats(priority).Admitted++
+	c.mu.Unlock()
+	if c.config.OnAdmit != nil {
+		c.config.OnAdmit(priority, 0)
+	}
+	return c.releaser(), nil
+}
+
+// releaser returns the function that frees the slot of an admitted call
+func (c *SchedulerClient) releaser() func() {
+	return sync.OnceFunc(func() {
+		c.mu.Lock()
+		c.running--
+		c.dispatch()
+		c.mu.Unlock()
+	})
+}
+
+// saturated reports whether every concurrency slot is busy. c.mu must be held.
+func (c *SchedulerClient) saturated() bool {
+	return c.config.MaxConcurrency > 0 && c.running >= c.config.MaxConcurrency
+}
+
+// priority returns the priority of a chat call
+func (c *SchedulerClient) priority(ctx context.Context, request Request) Priority {
+	if request.Priority != PriorityNormal {
+		return request.Priority
+	}
+	priority, _ := PriorityFromContext(ctx)
+	return priority
+}
+
+// chatTokens estimates the prompt tokens of a chat call for the limiter
+func (c *SchedulerClient) chatTokens(request Request) int {
+	if c.config.Limiter == nil {
+		return 0
+	}
+	return c.config.Limiter.clamp(c.Client.CountTokens(request))
+}
+
+// dispatch dequeues the highest-priority call if it may proceed. c.mu must be held.
+func (c *SchedulerClient) dispatch() {
+	for len(c.queue) > 0 && !c.admitting {
+		if c.saturated() {
+			return
+		}
+		waiter := heap.Pop(&c.queue).(*schedulerWaiter)
+		c.stats[waiter.priority].Queued--
+		c.running++
+		// Without a limiter there is nothing to reserve, so calls need not
+		// take turns
+		c.admitting = c.config.Limiter != nil
+		close(waiter.ready)
+	}
+}
+
+// admitted ends the turn of a dequeued call, which keeps its slot if it
+// proceeds. c.mu must be held.
+func (c *SchedulerClient) admitted(proceed bool) {
+	c.admitting = false
+	if !proceed {
+		c.running--
+	}
+	c.dispatch()
+}
+
+// priorityStats returns the stats of priority, creating them. c.mu must be held.
+func (c *SchedulerClient) priorityStats(priority Priority) *PriorityStats {
+	stats, ok := c.stats[priority]
+	if !ok {
+		stats = &PriorityStats{}
+		c.stats[priority] = stats
+	}
+	return stats
+}
+
+// schedulerQueue is a heap of waiting calls, highest priority first, then
+// in arrival order
+type schedulerQueue []*schedulerWaiter
+
+func (q schedulerQueue) Len() int { return len(q) }
+
+func (q schedulerQueue) Less(i, j int) bool {
+	if q[i].priority != q[j].priority {
+		return q[i].priority > q[j].priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q schedulerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *schedulerQueue) Push(x any) {
+	waiter := x.(*schedulerWaiter)
+	waiter.index = len(*q)
+	*q = append(*q, waiter)
+}
+
+func (q *schedulerQueue) Pop() any {
+	old := *q
+	waiter := old[len(old)-1]
+	old[len(old)-1] = nil
+	waiter.index = -1
+	*q = old[:len(old)-1]
+	return waiter
+}
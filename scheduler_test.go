@@ -0,0 +1,251 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSchedulerClient(t *testing.T) {
+	gate := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+	inner := &scriptedClient{reply: func(request Request) (*Response, error) {
+		content := request.Messages[len(request.Messages)-1].Content
+		if content == "first" {
+			<-gate
+		}
+		mu.Lock()
+		order = append(order, content)
+		mu.Unlock()
+		return &Response{Content: content}, nil
+	}}
+	var waits []Priority
+	client := NewSchedulerClient(inner, SchedulerConfig{
+		MaxConcurrency: 1,
+		MaxQueue:       2,
+		OnAdmit: func(priority Priority, wait time.Duration) {
+			mu.Lock()
+			waits = append(waits, priority)
+			mu.Unlock()
+		},
+	})
+
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	call := func(ctx context.Context, request Request) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Generate(ctx, request); err != nil {
+				t.Errorf("Generate %q failed: %v", request.Messages[0].Content, err)
+			}
+		}()
+	}
+	waitQueued := func(n int) {
+		for deadline := time.Now().Add(5 * time.Second); client.Stats().Queued != n; {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %d queued calls, got %+v", n, client.Stats())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	call(ctx, BuildSimpleRequest("first"))
+	for client.Stats().Running != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	call(WithPriority(ctx, PriorityLow), BuildSimpleRequest("low"))
+	waitQueued(1)
+	high := BuildSimpleRequest("high")
+	high.Priority = PriorityHigh
+	call(WithPriority(ctx, PriorityLow), high)
+	waitQueued(2)
+
+	// A full queue sheds low-priority calls only
+	_, err := client.Generate(WithPriority(ctx, PriorityLow), BuildSimpleRequest("shed"))
+	if !errors.Is(err, ErrLoadShed) {
+		t.Errorf("Expected ErrLoadShed, got %v", err)
+	}
+	call(ctx, BuildSimpleRequest("normal"))
+	waitQueued(3)
+
+	// A call cancelled while queued leaves the queue
+	cancelled, cancel := context.WithCancel(WithPriority(ctx, PriorityHigh))
+	cancel()
+	if _, err := client.Generate(cancelled, BuildSimpleRequest("cancelled")); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+
+	stats := client.Stats()
+	if stats.Running != 1 || stats.Queued != 3 || stats.Priorities[PriorityLow].Shed != 1 || stats.Priorities[PriorityHigh].Queued != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	close(gate)
+	wg.Wait()
+	if len(order) != 4 || order[1] != "high" || order[2] != "normal" || order[3] != "low" {
+		t.Errorf("Expected calls in priority order, got %v", order)
+	}
+	stats = client.Stats()
+	if stats.Running != 0 || stats.Queued != 0 || stats.Priorities[PriorityLow].Admitted != 1 || stats.Priorities[PriorityLow].MaxWait <= 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if len(waits) != 4 || waits[1] != PriorityHigh {
+		t.Errorf("Expected OnAdmit for every call, got %v", waits)
+	}
+}
+
+func TestSchedulerClientLimiter(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	base, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	inner := &scriptedClient{Client: base, reply: func(request Request) (*Response, error) {
+		mu.Lock()
+		order = append(order, request.Messages[0].Content)
+		mu.Unlock()
+		return &Response{}, nil
+	}}
+	// One request every 100ms, starting with an empty bucket
+	limiter := NewRateLimiter(600, 0)
+	limiter.requests = 0
+	client := NewSchedulerClient(inner, SchedulerConfig{Limiter: limiter})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	start := func(ctx context.Context, content string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Generate(ctx, BuildSimpleRequest(content))
+		}()
+	}
+	start(ctx, "holds the turn")
+	for client.Stats().Running != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, content := range []string{"low-1", "low-2"} {
+		start(WithPriority(ctx, PriorityLow), content)
+	}
+	for client.Stats().Queued != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	start(WithPriority(ctx, PriorityHigh), "high")
+	wg.Wait()
+
+	if len(order) != 4 || order[1] != "high" {
+		t.Errorf("Expected the high-priority call to reserve the limiter first, got %v", order)
+	}
+}
+
+func TestSchedulerClientEmbeddingTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data": [{"embedding": [0.1], "index": 0}], "usage": {"total_tokens": 1}}`))
+	}))
+	defer server.Close()
+	var mu sync.Mutex
+	var models []string
+	inner, _ := NewClient(Config{
+		Provider:                ProviderOpenAI,
+		APIKey:                  "test-key",
+		BaseURL:                 server.URL,
+		DefaultModel:            "gpt-4o",
+		DefaultEmbeddingModel:   "text-embedding-3-large",
+		DisableBase64Embeddings: true,
+		Tokenizer: TokenizerFunc(func(model, text string) int {
+			mu.Lock()
+			defer mu.Unlock()
+			models = append(models, model)
+			return len(text)
+		}),
+	})
+	client := NewSchedulerClient(inner, SchedulerConfig{Limiter: NewRateLimiter(0, 1000)})
+
+	if _, err := client.CreateEmbedding(context.Background(), EmbeddingRequest{Input: []string{"a"}}); err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+	if len(models) == 0 {
+		t.Fatal("Expected the limiter estimate to use the tokenizer")
+	}
+	for _, model := range models {
+		if model != "text-embedding-3-large" {
+			t.Errorf("Expected tokens counted for the embedding model, got %q", model)
+		}
+	}
+}
+
+func TestSchedulerClientTryGenerate(t *testing.T) {
+	base, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	gate := make(chan struct{})
+	inner := &scriptedClient{Client: base, reply: func(request Request) (*Response, error) {
+		if request.Messages[0].Content == "slow" {
+			<-gate
+		}
+		return &Response{Content: "ok"}, nil
+	}}
+	limiter := NewRateLimiter(60, 0) // one request per second
+	limiter.requests = 2
+	fast := NewSchedulerClient(inner, SchedulerConfig{Limiter: limiter})
+	busy := NewSchedulerClient(inner, SchedulerConfig{MaxConcurrency: 1})
+	client, err := NewABClient(ABConfig{Variants: []Variant{
+		{Name: "fast", Client: NewBudgetClient(fast, BudgetConfig{})},
+		{Name: "busy", Client: busy},
+	}})
+	if err != nil {
+		t.Fatalf("NewABClient failed: %v", err)
+	}
+	ctx := context.Background()
+	request := BuildSimpleRequest("Hello")
+
+	// The estimate follows the variant the call would be routed to, through
+	// the budget wrapper
+	if estimate := EstimateWait(ctx, client, request); estimate.Blocks() {
+		t.Errorf("Expected no wait with tokens in the bucket, got %+v", estimate)
+	}
+	if response, err := TryGenerate(ctx, client, request); err != nil || response.Variant != "fast" {
+		t.Fatalf("TryGenerate failed: %+v, %v", response, err)
+	}
+	client.Generate(ctx, request)
+	if estimate := EstimateWait(ctx, client, request); estimate.Wait < 500*time.Millisecond || estimate.Saturated {
+		t.Errorf("Expected a limiter wait of about a second, got %+v", estimate)
+	}
+	if _, err := TryGenerate(ctx, client, request); !errors.Is(err, ErrWouldBlock) {
+		t.Errorf("Expected ErrWouldBlock, got %v", err)
+	}
+
+	busyCtx := WithVariant(ctx, "busy")
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		busy.Generate(ctx, BuildSimpleRequest("slow"))
+	}()
+	for busy.Stats().Running != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if estimate := EstimateWait(busyCtx, client, request); !estimate.Saturated || estimate.Wait != 0 {
+		t.Errorf("Expected saturated slots, got %+v", estimate)
+	}
+	if _, err := TryGenerate(busyCtx, client, request); !errors.Is(err, ErrWouldBlock) {
+		t.Errorf("Expected ErrWouldBlock, got %v", err)
+	}
+	close(gate)
+	<-done
+	if response, err := TryGenerate(busyCtx, client, request); err != nil || response.Variant != "busy" {
+		t.Errorf("Expected a free slot to admit the call, got %+v, %v", response, err)
+	}
+
+	// Clients that never queue just generate
+	if _, err := TryGenerate(ctx, inner, request); err != nil || EstimateWait(ctx, inner, request).Blocks() {
+		t.Errorf("Expected plain clients not to block, got %v", err)
+	}
+}
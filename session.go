@@ -0,0 +1,158 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Session is a conversation, optionally backed by a HistoryStore. The
+// history is loaded on first use, turns are appended in memory and written
+// back by Flush or Close. A Session must not be copied after first use.
+type Session struct {
+	// Client answers Send
+	Client Client
+	// ID identifies the conversation in Store
+	ID string
+	// Store persists the conversation (nil = History is kept in memory only)
+	Store HistoryStore
+	// History is the current conversation
+	History ChatHistory
+
+	mu     sync.Mutex
+	loaded bool
+	dirty  bool
+}
+
+// NewSession creates a session for id backed by store
+func NewSession(store HistoryStore, id string) *Session {
+	return &Session{ID: id, Store: store}
+}
+
+// Messages returns a copy of the conversation, loading it first if needed
+func (s *Session) Messages(ctx context.Context) ([]Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	return copyMessages(s.History.Messages), nil
+}
+
+// Append adds messages to the conversation, loading it first if needed
+func (s *Session) Append(ctx context.Context, messages ...Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+	s.History.append(messages...)
+	s.dirty = true
+	return nil
+}
+
+// AddUserMessage appends a user turn
+func (s *Session) AddUserMessage(ctx context.Context, content string) error {
+	return s.Append(ctx, Message{Role: RoleUser, Content: content})
+}
+
+// AddAssistantMessage appends an assistant turn
+func (s *Session) AddAssistantMessage(ctx context.Context, content string) error {
+	return s.Append(ctx, Message{Role: RoleAssistant, Content: content})
+}
+
+// Send appends userMessage to the conversation, generates a reply with the
+// whole history and appends the reply. On error the history is left
+// untouched. Concurrent Sends on one session are serialized.
+func (s *Session) Send(ctx context.Context, userMessage string) (*Response, error) {
+	return s.send(ctx, userMessage, nil, nil)
+}
+
+// SendStream is Send with the reply streamed: onDelta receives every delta
+// as in GenerateWithCallback. Both turns are appended only once the stream
+// has ended cleanly; when it fails, is interrupted or onDelta returns an
+// error, the history is left untouched.
+func (s *Session) SendStream(ctx context.Context, userMessage string, onDelta func(chunk StreamChunk) error) (*Response, error) {
+	return s.send(ctx, userMessage, nil, onDelta)
+}
+
+// send implements Send and SendStream; clean, if set, rewrites the reply
+// stored in the history, and onDelta, if set, streams the reply
+func (s *Session) send(ctx context.Context, userMessage string, clean func(string) string, onDelta func(chunk StreamChunk) error) (*Response, error) {
+	if s.Client == nil {
+		return nil, fmt.Errorf("session has no client")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	request := BuildChatRequest(s.History.Messages, userMessage)
+	var response *Response
+	var err error
+	if onDelta != nil {
+		response, err = GenerateWithCallback(ctx, s.Client, request, onDelta)
+	} else {
+		response, err = s.Client.Generate(ctx, request)
+	}
+	if err != nil {
+		return nil, err
+	}
+	reply := response.Content
+	if clean != nil {
+		reply = clean(reply)
+	}
+	s.History.append(
+		Message{Role: RoleUser, Content: userMessage},
+		Message{Role: RoleAssistant, Content: reply, ToolCalls: response.ToolCalls},
+	)
+	s.dirty = true
+	return response, nil
+}
+
+// SendText is Send returning only the reply text, without a leading byte
+// order mark or surrounding whitespace. The history keeps the cleaned text.
+func (s *Session) SendText(ctx context.Context, userMessage string) (string, error) {
+	response, err := s.send(ctx, userMessage, cleanText, nil)
+	if err != nil {
+		return "", err
+	}
+	return cleanText(response.Content), nil
+}
+
+// Flush writes the conversation to the store if it changed
+func (s *Session) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty || s.Store == nil {
+		return nil
+	}
+	if err := s.Store.Put(ctx, s.ID, s.History); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+// Close flushes pending changes to the store
+func (s *Session) Close() error {
+	return s.Flush(context.Background())
+}
+
+// load reads the stored conversation once; s.mu must be held. Messages
+// added to History before the first load are kept after the stored ones.
+func (s *Session) load(ctx context.Context) error {
+	if s.loaded || s.Store == nil {
+		s.loaded = true
+		return nil
+	}
+	stored, err := s.Store.Get(ctx, s.ID)
+	if err != nil && !errors.Is(err, ErrHistoryNotFound) {
+		return err
+	}
+	s.History.Messages = append(stored.Messages, s.History.Messages...)
+	s.loaded = true
+	return nil
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// defaultShadowTimeout bounds a shadow call when ShadowConfig.Timeout is 0
+const defaultShadowTimeout = 30 * time.Second
+
+// ShadowConfig configures NewShadowClient
+type ShadowConfig struct {
+	// SampleRate is the fraction of successful calls mirrored, from 0
+	// (none) to 1 (all)
+	SampleRate float64
+
+	// Timeout bounds each shadow call (0 = 30s). It starts when the primary
+	// call returns and does not depend on the caller's context.
+	Timeout time.Duration
+
+	// Model replaces Request.Model in shadow calls ("" = keep the
+	// request's model), for when the shadow provider names models differently
+	Model string
+
+	// OnPair receives each mirrored request with the primary response and
+	// the outcome of the shadow call. It runs on the shadow goroutine, so it
+	// may be called concurrently and after the primary call has returned.
+	OnPair func(request Request, primary *Response, shadow *Response, shadowErr error)
+}
+
+// ShadowClient wraps a primary Client and mirrors a sample of its chat calls
+// to a shadow Client, for comparing a candidate provider on production traffic
+type ShadowClient struct {
+	Client
+	shadow Client
+	config ShadowConfig
+
+	mu      sync.Mutex
+	closed  bool
+	pending sync.WaitGroup
+}
+
+// NewShadowClient returns a client that serves every call from primary and
+// mirrors a sample of the successful Generate and GenerateWithHistory calls
+// to shadow. Shadow calls run on their own goroutine with a context that
+// keeps the caller's values but not its cancellation, so they never add
+// latency or errors to the primary path; their results only reach
+// ShadowConfig.OnPair. Streams, embeddings and the other calls go to
+// primary only.
+//
+// Shadow calls are made with a context marked by IsShadowCall, so that
+// UsageAccountant, RequestMetrics and UsageEvent attribute their usage
+// separately from the primary traffic.
+func NewShadowClient(primary, shadow Client, config ShadowConfig) *ShadowClient {
+	return &ShadowClient{
+		Client: primary,
+		shadow: shadow,
+		config: config,
+	}
+}
+
+// Generate serves the request from the primary client and may mirror it
+func (c *ShadowClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	response, err := c.Client.Generate(ctx, request)
+	if err == nil {
+		c.mirror(ctx, request, response)
+	}
+	return response, err
+}
+
+// TryGenerate serves the request from the primary client if it can send it
+// without waiting (see TryGenerate), and may mirror it
+func (c *ShadowClient) TryGenerate(ctx context.Context, request Request) (*Response, error) {
+	response, err := TryGenerate(ctx, c.Client, request)
+	if err == nil {
+		c.mirror(ctx, request, response)
+	}
+	return response, err
+}
+
+// EstimateWait reports the wait of the primary client; shadow calls never
+// hold the primary path back
+func (c *ShadowClient) EstimateWait(ctx context.Context, request Request) WaitEstimate {
+	return EstimateWait(ctx, c.Client, request)
+}
+
+// GenerateStream streams the request from the primary client; streams are
+// not mirrored
+func (c *ShadowClient) GenerateStream(ctx context.Context, request Request) (*Response, error) {
+	return GenerateStream(ctx, c.Client, request)
+}
+
+// GenerateWithHistory serves the conversation from the primary client and may mirror it
+func (c *ShadowClient) GenerateWithHistory(ctx context.Context, history ChatHistory, userMessage string, systemPrompt string) (*Response, error) {
+	request := BuildChatRequest(history.GetMessages(), userMessage)
+	if systemPrompt != "" {
+		request.AddSystemMessage(systemPrompt)
+	}
+	return c.Generate(ctx, request)
+}
+
+// Close waits for the shadow calls in flight, then closes the primary and
+// the shadow client
+func (c *ShadowClient) Close() error {
+	c.mu.Lock()
+	c.closed = true
+	c.mu.Unlock()
+	c.pending.Wait()
+	return errors.Join(c.Client.Close(), c.shadow.Close())
+}
+
+// mirror starts a shadow call for a sampled request
+func (c *ShadowClient) mirror(ctx context.Context, request Request, primary *Response) {
+	if c.config.SampleRate <= 0 || rand.Float64() >= c.config.SampleRate {
+		return
+	}
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return
+	}
+	c.pending.Add(1)
+	c.mu.Unlock()
+
+	// the caller may reuse its request once Generate returns
+	request = request.Clone()
+	timeout := c.config.Timeout
+	if timeout <= 0 {
+		timeout = defaultShadowTimeout
+	}
+	// hooks attached to ctx observe the primary call only
+	ctx = context.WithValue(withoutHooks(context.WithoutCancel(ctx)), shadowContextKey, true)
+
+	go func() {
+		defer c.pending.Done()
+		defer func() {
+			// neither the shadow client nor OnPair may take the process down
+			if r := recover(); r != nil {
+				if logger := c.shadow.GetConfig().Logger; logger != nil {
+					logger.LogAttrs(ctx, slog.LevelError, "llm: shadow call panicked", slog.Any("panic", r))
+				}
+			}
+		}()
+
+		shadowCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		shadowRequest := request
+		if c.config.Model != "" {
+			shadowRequest.Model = &c.config.Model
+		}
+		response, err := c.shadow.Generate(shadowCtx, shadowRequest)
+		if c.config.OnPair != nil {
+			c.config.OnPair(request, primary, response, err)
+		}
+	}()
+}
@@ -0,0 +1,104 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShadowClient(t *testing.T) {
+	release := make(chan struct{})
+	var shadowModel string
+	shadowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		var payload chatPayload
+		json.NewDecoder(r.Body).Decode(&payload)
+		shadowModel = payload.Model
+		w.Write([]byte(`{"model":"deepseek-chat","choices":[{"message":{"role":"assistant","content":"shadow"}}],"usage":{"prompt_tokens":10,"completion_tokens":5,"total_tokens":15}}`))
+	}))
+	defer shadowServer.Close()
+	primaryServer := newChatServer(t, nil)
+
+	accountant := NewUsageAccountant()
+	var mu sync.Mutex
+	var events []UsageEvent
+	newClient := func(provider Provider, baseURL string) Client {
+		client, err := NewClient(Config{
+			Provider: provider,
+			APIKey:   "test-key",
+			BaseURL:  baseURL,
+			Metrics:  accountant,
+			OnUsage: func(ctx context.Context, event UsageEvent) {
+				mu.Lock()
+				defer mu.Unlock()
+				events = append(events, event)
+			},
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	pairs := make(chan [2]*Response, 1)
+	client := NewShadowClient(newClient(ProviderOpenAI, primaryServer.URL), newClient(ProviderDeepSeek, shadowServer.URL), ShadowConfig{
+		SampleRate: 1,
+		Model:      "deepseek-chat",
+		OnPair: func(request Request, primary, shadow *Response, shadowErr error) {
+			if shadowErr != nil || request.Messages[0].Content != "Hello" {
+				t.Errorf("Unexpected shadow outcome %v for %+v", shadowErr, request)
+			}
+			pairs <- [2]*Response{primary, shadow}
+		},
+	})
+
+	// The shadow call neither delays the primary one nor dies with its context
+	ctx, cancel := context.WithCancel(context.Background())
+	response, err := client.Generate(ctx, BuildSimpleRequest("Hello"))
+	cancel()
+	if err != nil || response.Content != "ok" {
+		t.Fatalf("Expected the primary response, got %v, %v", response, err)
+	}
+	close(release)
+	select {
+	case pair := <-pairs:
+		if pair[0] != response || pair[1].Content != "shadow" {
+			t.Errorf("Unexpected pair %+v, %+v", pair[0], pair[1])
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnPair was not called")
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if shadowModel != "deepseek-chat" {
+		t.Errorf("Expected the shadow model, got %q", shadowModel)
+	}
+
+	stats := accountant.UsageStats()
+	if stats.Totals.Requests != 1 || stats.Totals.TotalTokens != 3 {
+		t.Errorf("Expected only the primary call in Totals, got %+v", stats.Totals)
+	}
+	if stats.Shadow.Requests != 1 || stats.Shadow.TotalTokens != 15 {
+		t.Errorf("Expected the shadow call in Shadow, got %+v", stats.Shadow)
+	}
+	if len(stats.Models) != 2 || stats.Models[0].Shadow || !stats.Models[1].Shadow || stats.Models[1].Provider != ProviderDeepSeek {
+		t.Errorf("Expected a shadow row last, got %+v", stats.Models)
+	}
+	if len(events) != 2 || events[0].Shadow || !events[1].Shadow {
+		t.Errorf("Expected the shadow usage event to be marked, got %+v", events)
+	}
+
+	// Nothing is mirrored without a sample rate
+	unsampled := NewShadowClient(newClient(ProviderOpenAI, primaryServer.URL), newClient(ProviderDeepSeek, shadowServer.URL), ShadowConfig{
+		OnPair: func(Request, *Response, *Response, error) { t.Error("Unexpected shadow call") },
+	})
+	if _, err := unsampled.Generate(context.Background(), BuildSimpleRequest("Hello")); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	unsampled.Close()
+}
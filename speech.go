@@ -0,0 +1,115 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// Speech audio formats
+const (
+	SpeechFormatMP3  = "mp3"
+	SpeechFormatOpus = "opus"
+	SpeechFormatWAV  = "wav"
+	// SpeechFormatPCM is raw 24kHz 16-bit signed little-endian mono samples
+	SpeechFormatPCM = "pcm"
+)
+
+// SpeechRequest asks a text-to-speech model to read Input aloud
+type SpeechRequest struct {
+	Input string `json:"input"`
+
+	// Model override (optional, default tts-1)
+	Model *string `json:"model,omitempty"`
+
+	// Voice is the provider's voice name (default alloy)
+	Voice string `json:"voice,omitempty"`
+
+	// Format is one of the SpeechFormat constants (default mp3)
+	Format string `json:"format,omitempty"`
+
+	// Speed scales the speaking rate, 0.25 to 4.0 (optional)
+	Speed *float64 `json:"speed,omitempty"`
+
+	// Extra HTTP headers for this request only. Applied after Config.Headers.
+	Headers map[string]string `json:"-"`
+}
+
+// Speaker is implemented by clients whose provider offers text to speech;
+// check Capabilities().Speech or call Speak
+type Speaker interface {
+	Speak(ctx context.Context, request SpeechRequest) (io.ReadCloser, error)
+}
+
+// Speak converts text to audio with client, failing with a CapabilityError
+// when its provider has no speech support. The returned reader streams the
+// audio as the provider sends it, so playback can start before synthesis
+// ends; the caller must close it. Cancelling ctx aborts the stream.
+func Speak(ctx context.Context, client Client, request SpeechRequest) (io.ReadCloser, error) {
+	speaker, ok := client.(Speaker)
+	if !ok || !client.Capabilities().Speech {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilitySpeech}
+	}
+	return speaker.Speak(ctx, request)
+}
+
+// speechFunc starts a provider speech call and returns the audio stream
+// once the response headers have arrived
+type speechFunc func(ctx context.Context, request SpeechRequest) (io.ReadCloser, error)
+
+// instrumentSpeech validates request and starts a provider speech call.
+// Config.Timeout bounds the wait for the response headers only: the audio
+// stream then lives until the caller closes it or cancels ctx.
+func instrumentSpeech(ctx context.Context, config Config, model string, request SpeechRequest, call speechFunc) (io.ReadCloser, error) {
+	if request.Input == "" {
+		return nil, fmt.Errorf("speech request has no input")
+	}
+	switch request.Format {
+	case "", SpeechFormatMP3, SpeechFormatOpus, SpeechFormatWAV, SpeechFormatPCM:
+	default:
+		return nil, fmt.Errorf("unsupported speech format %q (supported: mp3, opus, wav, pcm)", request.Format)
+	}
+
+	startTime := time.Now()
+	stream, cancel, err := startStream(ctx, config, "", 0, func(ctx context.Context) (io.ReadCloser, error) {
+		return call(ctx, request)
+	})
+	latency := time.Since(startTime)
+
+	observe(ctx, config, OperationSpeech, model, latency, Usage{}, err)
+	if config.Logger != nil && config.Logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("provider", string(config.Provider)),
+			slog.String("model", model),
+			slog.Int("characters", len([]rune(request.Input))),
+			slog.Duration("latency", latency),
+		}
+		logResult(ctx, config, "llm speech", attrs, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &speechStream{ReadCloser: stream, cancel: cancel}, nil
+}
+
+// speechStream is an audio response body that releases its context on Close
+type speechStream struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (s *speechStream) Close() error {
+	err := s.ReadCloser.Close()
+	s.cancel()
+	return err
+}
+
+// getSpeechModel returns the speech model to use for the request
+func getSpeechModel(override *string) string {
+	if override != nil {
+		return *override
+	}
+	return "tts-1"
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSpeak(t *testing.T) {
+	release := make(chan struct{})
+	payloads := make(chan map[string]interface{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		payloads <- payload
+		if payload["input"] == "fail" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"bad voice"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+			w.Write([]byte("-second"))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	// The client timeout bounds the wait for headers, not the stream
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, Timeout: 100 * time.Millisecond})
+	stream, err := Speak(context.Background(), client, SpeechRequest{Input: "Hello", Format: SpeechFormatOpus})
+	if err != nil {
+		t.Fatalf("Speak failed: %v", err)
+	}
+	payload := <-payloads
+	if payload["model"] != "tts-1" || payload["voice"] != "alloy" || payload["response_format"] != "opus" {
+		t.Errorf("Unexpected payload %v", payload)
+	}
+
+	// The first chunk is readable while the server is still synthesizing
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(stream, buf); err != nil || string(buf) != "first" {
+		t.Fatalf("Expected the first chunk before the stream ended, got %q, %v", buf, err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	release <- struct{}{}
+	rest, err := io.ReadAll(stream)
+	if err != nil || string(rest) != "-second" {
+		t.Errorf("Expected the rest of the stream after the client timeout, got %q, %v", rest, err)
+	}
+	stream.Close()
+
+	// Cancelling the context aborts the stream
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err = Speak(ctx, client, SpeechRequest{Input: "Hello"})
+	if err != nil {
+		t.Fatalf("Speak failed: %v", err)
+	}
+	<-payloads
+	cancel()
+	if _, err := io.ReadAll(stream); err == nil {
+		t.Error("Expected reading a cancelled stream to fail")
+	}
+	stream.Close()
+
+	if _, err := Speak(context.Background(), client, SpeechRequest{Input: "Hello", Format: "flac"}); err == nil {
+		t.Error("Expected an unsupported format to fail")
+	}
+	var apiErr *APIError
+	if _, err := Speak(context.Background(), client, SpeechRequest{Input: "fail"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected an APIError, got %v", err)
+	}
+	if len(payloads) != 1 {
+		t.Errorf("Expected only the failing request to reach the server, got %d", len(payloads))
+	}
+
+	deepseek, _ := NewClient(Config{Provider: ProviderDeepSeek, APIKey: "test-key", BaseURL: server.URL})
+	if _, err := Speak(context.Background(), deepseek, SpeechRequest{Input: "Hello"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
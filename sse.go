@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// sseEvent is one server-sent event
+type sseEvent struct {
+	// Event is the event type, empty for the default "message" type
+	Event string
+	Data  []byte
+}
+
+// readSSE calls fn for every event of a text/event-stream body until the body
+// ends or fn returns an error. An event cut off by the end of the body is
+// discarded, as the SSE specification requires.
+func readSSE(r io.Reader, fn func(event sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), int(defaultMaxResponseBytes))
+
+	var event sseEvent
+	var data bytes.Buffer
+	hasData := false
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if hasData {
+				event.Data = bytes.Clone(data.Bytes())
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+			event, hasData = sseEvent{}, false
+			data.Reset()
+			continue
+		}
+		if line[0] == ':' {
+			// comment, used as keep-alive
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			event.Event = string(value)
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+		}
+	}
+	return scanner.Err()
+}
+
+// openSSE sends a streaming request and returns the unread event stream
+// body, or the APIError of an unsuccessful response
+func openSSE(httpClient *http.Client, config Config, req *http.Request, errorPrefix string) (io.ReadCloser, error) {
+	req.Header.Set("Accept", "text/event-stream")
+	// Compression would make the transport buffer events
+	req.Header.Set("Accept-Encoding", "identity")
+
+	resp, err := sendRequest(httpClient, config, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, err := readBody(config, resp, defaultMaxResponseBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, newAPIError(config, errorPrefix, resp, body)
+	}
+	// the events follow one by one, see dumpEvent
+	dumpResponse(config, resp, nil)
+	return resp.Body, nil
+}
@@ -0,0 +1,251 @@
+package llm
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrHistoryNotFound is returned by HistoryStore.Get for unknown sessions
+var ErrHistoryNotFound = errors.New("chat history not found")
+
+// HistoryStore persists conversations keyed by session ID. Implementations
+// must be safe for concurrent use.
+type HistoryStore interface {
+	// Get returns the history of a session, or ErrHistoryNotFound
+	Get(ctx context.Context, sessionID string) (ChatHistory, error)
+
+	// Put stores the history of a session, replacing any previous one
+	Put(ctx context.Context, sessionID string, history ChatHistory) error
+
+	// Delete removes a session; deleting an unknown session is not an error
+	Delete(ctx context.Context, sessionID string) error
+
+	// List returns the stored session IDs in sorted order
+	List(ctx context.Context) ([]string, error)
+}
+
+// MemoryHistoryStore keeps conversations in memory, evicting the least
+// recently used session once more than maxSessions are stored
+type MemoryHistoryStore struct {
+	mu          sync.Mutex
+	maxSessions int
+	order       *list.List
+	sessions    map[string]*list.Element
+}
+
+// memoryEntry is an element of MemoryHistoryStore.order
+type memoryEntry struct {
+	id       string
+	messages []Message
+}
+
+// NewMemoryHistoryStore creates a MemoryHistoryStore holding at most
+// maxSessions conversations (0 = unlimited)
+func NewMemoryHistoryStore(maxSessions int) *MemoryHistoryStore {
+	return &MemoryHistoryStore{
+		maxSessions: maxSessions,
+		order:       list.New(),
+		sessions:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns a copy of the stored history
+func (s *MemoryHistoryStore) Get(ctx context.Context, sessionID string) (ChatHistory, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.sessions[sessionID]
+	if !ok {
+		return ChatHistory{}, ErrHistoryNotFound
+	}
+	s.order.MoveToFront(elem)
+	return ChatHistory{Messages: copyMessages(elem.Value.(*memoryEntry).messages)}, nil
+}
+
+// Put stores a copy of history, evicting the least recently used session if needed
+func (s *MemoryHistoryStore) Put(ctx context.Context, sessionID string, history ChatHistory) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := copyMessages(history.Messages)
+	if elem, ok := s.sessions[sessionID]; ok {
+		elem.Value.(*memoryEntry).messages = messages
+		s.order.MoveToFront(elem)
+		return nil
+	}
+	s.sessions[sessionID] = s.order.PushFront(&memoryEntry{id: sessionID, messages: messages})
+	if s.maxSessions > 0 && s.order.Len() > s.maxSessions {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.sessions, oldest.Value.(*memoryEntry).id)
+	}
+	return nil
+}
+
+// Delete removes a session
+func (s *MemoryHistoryStore) Delete(ctx context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if elem, ok := s.sessions[sessionID]; ok {
+		s.order.Remove(elem)
+		delete(s.sessions, sessionID)
+	}
+	return nil
+}
+
+// List returns the stored session IDs
+func (s *MemoryHistoryStore) List(ctx context.Context) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// FileHistoryStore keeps one JSON file per session in a directory. Files
+// are replaced atomically, so a crash never leaves a half-written history.
+type FileHistoryStore struct {
+	mu  sync.RWMutex
+	dir string
+}
+
+// historyFileExt is the extension of FileHistoryStore files
+const historyFileExt = ".json"
+
+// NewFileHistoryStore creates a FileHistoryStore in dir, creating it if needed
+func NewFileHistoryStore(dir string) (*FileHistoryStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	return &FileHistoryStore{dir: dir}, nil
+}
+
+// Get reads the history file of a session
+func (s *FileHistoryStore) Get(ctx context.Context, sessionID string) (ChatHistory, error) {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return ChatHistory{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ChatHistory{}, ErrHistoryNotFound
+	}
+	if err != nil {
+		return ChatHistory{}, fmt.Errorf("failed to read history: %w", err)
+	}
+	var history ChatHistory
+	if err := json.Unmarshal(data, &history); err != nil {
+		return ChatHistory{}, fmt.Errorf("failed to decode history %s: %w", sessionID, err)
+	}
+	return history, nil
+}
+
+// Put writes the history file of a session
+func (s *FileHistoryStore) Put(ctx context.Context, sessionID string, history ChatHistory) error {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(history)
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, ".history-*")
+	if err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// Delete removes the history file of a session
+func (s *FileHistoryStore) Delete(ctx context.Context, sessionID string) error {
+	path, err := s.path(sessionID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete history: %w", err)
+	}
+	return nil
+}
+
+// List returns the session IDs with a history file
+func (s *FileHistoryStore) List(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list histories: %w", err)
+	}
+	var ids []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, historyFileExt) {
+			continue
+		}
+		id, err := url.PathUnescape(strings.TrimSuffix(name, historyFileExt))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// path maps a session ID to its file, escaping characters that are not
+// safe in file names
+func (s *FileHistoryStore) path(sessionID string) (string, error) {
+	if sessionID == "" {
+		return "", fmt.Errorf("session ID is required")
+	}
+	name := url.PathEscape(sessionID)
+	if strings.HasPrefix(name, ".") {
+		name = "%2E" + name[1:]
+	}
+	return filepath.Join(s.dir, name+historyFileExt), nil
+}
+
+// copyMessages returns a copy of messages that shares no backing array,
+// including the tool calls of each message
+func copyMessages(messages []Message) []Message {
+	if messages == nil {
+		return nil
+	}
+	result := append([]Message(nil), messages...)
+	for i := range result {
+		if result[i].ToolCalls != nil {
+			result[i].ToolCalls = append([]ToolCall(nil), result[i].ToolCalls...)
+		}
+	}
+	return result
+}
@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHistoryStores(t *testing.T) {
+	fileStore, err := NewFileHistoryStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create file store: %v", err)
+	}
+	stores := map[string]HistoryStore{
+		"memory": NewMemoryHistoryStore(0),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrHistoryNotFound) {
+				t.Errorf("Expected ErrHistoryNotFound, got %v", err)
+			}
+
+			history := ChatHistory{}
+			history.AddSystemMessage("You are helpful.")
+			history.AddUserMessage("Hello")
+			for _, id := range []string{"user/42", "..", "b"} {
+				if err := store.Put(ctx, id, history); err != nil {
+					t.Fatalf("Put(%q) failed: %v", id, err)
+				}
+			}
+
+			got, err := store.Get(ctx, "user/42")
+			if err != nil || !reflect.DeepEqual(got.Messages, history.Messages) {
+				t.Errorf("Get returned %+v, %v", got, err)
+			}
+			got.Messages[0].Content = "mutated"
+			if again, _ := store.Get(ctx, "user/42"); again.Messages[0].Content != "You are helpful." {
+				t.Error("Mutating a returned history changed the store")
+			}
+
+			ids, err := store.List(ctx)
+			if err != nil || !reflect.DeepEqual(ids, []string{"..", "b", "user/42"}) {
+				t.Errorf("List returned %v, %v", ids, err)
+			}
+
+			if err := store.Delete(ctx, "b"); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if err := store.Delete(ctx, "b"); err != nil {
+				t.Errorf("Deleting a missing session should succeed, got %v", err)
+			}
+			if _, err := store.Get(ctx, "b"); !errors.Is(err, ErrHistoryNotFound) {
+				t.Errorf("Expected deleted session to be gone, got %v", err)
+			}
+
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					id := fmt.Sprintf("concurrent-%d", i%4)
+					store.Put(ctx, id, history)
+					store.Get(ctx, id)
+				}(i)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestMemoryHistoryStoreEviction(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryHistoryStore(2)
+	store.Put(ctx, "a", ChatHistory{})
+	store.Put(ctx, "b", ChatHistory{})
+	store.Get(ctx, "a")
+	store.Put(ctx, "c", ChatHistory{})
+
+	ids, _ := store.List(ctx)
+	if !reflect.DeepEqual(ids, []string{"a", "c"}) {
+		t.Errorf("Expected least recently used session to be evicted, got %v", ids)
+	}
+}
+
+func TestSession(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryHistoryStore(0)
+	store.Put(ctx, "s1", ChatHistory{Messages: []Message{{Role: RoleSystem, Content: "sys"}}})
+
+	session := NewSession(store, "s1")
+	if err := session.AddUserMessage(ctx, "Hello"); err != nil {
+		t.Fatalf("AddUserMessage failed: %v", err)
+	}
+	session.AddAssistantMessage(ctx, "Hi")
+
+	if stored, _ := store.Get(ctx, "s1"); len(stored.Messages) != 1 {
+		t.Errorf("Expected changes to stay in memory until Close, got %d stored messages", len(stored.Messages))
+	}
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	stored, _ := store.Get(ctx, "s1")
+	if len(stored.Messages) != 3 || stored.Messages[0].Content != "sys" || stored.Messages[2].Content != "Hi" {
+		t.Errorf("Unexpected stored history %+v", stored.Messages)
+	}
+}
+
+func TestSessionSend(t *testing.T) {
+	var requests []Request
+	client := &scriptedClient{reply: func(request Request) (*Response, error) {
+		requests = append(requests, request)
+		if request.Messages[len(request.Messages)-1].Content == "fail" {
+			return nil, errors.New("boom")
+		}
+		return &Response{Content: "reply " + strconv.Itoa(len(requests))}, nil
+	}}
+	session := &Session{Client: client, History: ChatHistory{Messages: []Message{{Role: RoleSystem, Content: "sys"}}}}
+	ctx := context.Background()
+
+	if _, err := session.Send(ctx, "first"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if _, err := session.Send(ctx, "fail"); err == nil {
+		t.Fatal("Expected error")
+	}
+	if _, err := session.Send(ctx, "second"); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var got []string
+	for _, msg := range session.History.Messages {
+		got = append(got, string(msg.Role)+":"+msg.Content)
+	}
+	want := []string{"system:sys", "user:first", "assistant:reply 1", "user:second", "assistant:reply 3"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("History = %v, want %v", got, want)
+	}
+	if last := requests[2].Messages; len(last) != 4 || last[2].Content != "reply 1" {
+		t.Errorf("Expected the reply to be sent back as context, got %+v", last)
+	}
+}
+
+func TestSessionSendText(t *testing.T) {
+	client := &scriptedClient{reply: func(request Request) (*Response, error) {
+		return &Response{Content: "\ufeff  Hello!\n"}, nil
+	}}
+	session := &Session{Client: client}
+
+	text, err := session.SendText(context.Background(), "Hi")
+	if err != nil {
+		t.Fatalf("SendText failed: %v", err)
+	}
+	if text != "Hello!" {
+		t.Errorf("SendText = %q, want %q", text, "Hello!")
+	}
+	if last := session.History.Messages[len(session.History.Messages)-1]; last.Content != "Hello!" {
+		t.Errorf("Expected the cleaned reply in the history, got %q", last.Content)
+	}
+}
+
+func TestSessionSendStream(t *testing.T) {
+	stream, err := os.ReadFile("testdata/streams/openai_chat.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var truncate atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		if truncate.Load() {
+			// cut off before the end of the response
+			w.Write(stream[:len(stream)/2])
+			return
+		}
+		w.Write(stream)
+	}))
+	t.Cleanup(server.Close)
+	client, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	session := &Session{Client: client}
+	ctx := context.Background()
+
+	var streamed strings.Builder
+	response, err := session.SendStream(ctx, "first", func(chunk StreamChunk) error {
+		streamed.WriteString(chunk.Content)
+		return nil
+	})
+	if err != nil || response.Content == "" || streamed.String() != response.Content {
+		t.Fatalf("SendStream failed: %+v, %v", response, err)
+	}
+	if len(session.History.Messages) != 2 || session.History.Messages[1].Content != response.Content {
+		t.Errorf("Expected both turns appended, got %+v", session.History.Messages)
+	}
+
+	truncate.Store(true)
+	var interrupted *StreamInterruptedError
+	if _, err := session.SendStream(ctx, "second", func(StreamChunk) error { return nil }); !errors.As(err, &interrupted) {
+		t.Errorf("Expected an interrupted stream, got %v", err)
+	}
+	truncate.Store(false)
+	errStop := errors.New("stop")
+	if _, err := session.SendStream(ctx, "third", func(StreamChunk) error { return errStop }); !errors.Is(err, errStop) {
+		t.Errorf("Expected the callback error, got %v", err)
+	}
+	if len(session.History.Messages) != 2 {
+		t.Errorf("Expected the history untouched by failed streams, got %+v", session.History.Messages)
+	}
+}
+
+// scriptedClient answers Generate with a function
+type scriptedClient struct {
+	Client
+	reply func(Request) (*Response, error)
+}
+
+func (c *scriptedClient) Generate(ctx context.Context, request Request) (*Response, error) {
+	return c.reply(request)
+}
@@ -0,0 +1,485 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStreamIncomplete is the cause of a StreamInterruptedError when the
+// stream ended cleanly but before the provider signalled the end of the
+// response
+var ErrStreamIncomplete = errors.New("stream ended before the response was complete")
+
+// StreamInterruptedError is the error of a stream that died mid-generation,
+// because the connection dropped or ended early. It carries what was
+// received so the caller can keep it instead of starting over.
+type StreamInterruptedError struct {
+	Provider Provider
+	// PartialContent and PartialReasoning are the content and reasoning
+	// deltas received before the interruption
+	PartialContent   string
+	PartialReasoning string
+	// ChunksReceived is the number of deltas received
+	ChunksReceived int
+	// Err is ErrStreamIncomplete or the read error
+	Err error
+}
+
+func (e *StreamInterruptedError) Error() string {
+	return fmt.Sprintf("%s stream interrupted after %d chunks: %v", e.Provider, e.ChunksReceived, e.Err)
+}
+
+func (e *StreamInterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// streamResumePrompt prefixes the partial text in the user message that asks
+// the model to continue an interrupted stream
+const streamResumePrompt = "Your previous answer was cut off. Reply with only the rest of it, without repeating anything. Continue from:\n\n"
+
+// Streamer is implemented by clients that can stream chat responses; check
+// Capabilities().Streaming or call GenerateStream
+type Streamer interface {
+	GenerateStream(ctx context.Context, request Request) (*Response, error)
+}
+
+// GenerateStream starts a streaming chat call with client, failing with a
+// CapabilityError when it cannot stream. Errors before the first byte
+// (including APIErrors) are returned directly. Otherwise the Response only
+// has Provider, Model and RequestID set, and its Stream channel delivers the
+// content, reasoning and tool call deltas followed by a final chunk with
+// Done set, which carries FinishReason, the assembled ToolCalls, Usage and,
+// if the stream failed, Err. The channel is closed
+// after the final chunk.
+//
+// Config.Timeout (or Request.Timeout) bounds the wait for the response
+// headers only. The caller must drain Stream or cancel ctx, which aborts the
+// upstream request.
+func GenerateStream(ctx context.Context, client Client, request Request) (*Response, error) {
+	streamer, ok := client.(Streamer)
+	if !ok || !client.Capabilities().Streaming {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilityStreaming}
+	}
+	return streamer.GenerateStream(ctx, request)
+}
+
+// providerStream is an open provider event stream
+type providerStream struct {
+	body io.ReadCloser
+	// requestID is the X-Request-ID sent with the request
+	requestID string
+	// decode turns one event into a chunk carrying deltas and, when the
+	// provider reports them, FinishReason and Usage. It returns io.EOF for an
+	// explicit end-of-stream event such as OpenAI's [DONE].
+	decode func(event sseEvent) (StreamChunk, error)
+}
+
+// Close closes the response body, aborting the stream
+func (s *providerStream) Close() error {
+	return s.body.Close()
+}
+
+// streamFunc sends a provider streaming request and returns the open stream
+// once the response headers have arrived
+type streamFunc func(ctx context.Context, request Request) (*providerStream, error)
+
+// streamBuffer is how many chunks may wait for a slow reader before the
+// stream stops reading from the provider
+const streamBuffer = 16
+
+// instrumentStream is instrumentGenerate for streaming calls: hooks and the
+// system message policy run before the call, while metrics, logging, usage
+// reporting and AfterResponse hooks run when the stream ends, with the
+// accumulated response.
+func instrumentStream(ctx context.Context, config Config, getModel func(*string) string, request Request, call streamFunc) (*Response, error) {
+	original := withConfigDefaults(config, request)
+	if err := prepareChatRequest(ctx, config, getModel, &request); err != nil {
+		return nil, err
+	}
+	model := getModel(request.Model)
+	attempts := newChatAttempts(config, getModel, original, request)
+
+	startTime := time.Now()
+	ctx, trace := withTimingTrace(ctx, config, startTime)
+	attemptCtx, request, _ := attempts.start(ctx, request)
+	stream, cancel, err := startStream(attemptCtx, config, model, request.Timeout, func(ctx context.Context) (*providerStream, error) {
+		return call(ctx, request)
+	})
+	if err != nil {
+		latency := time.Since(startTime)
+		var timing *Timing
+		if trace != nil {
+			timing = trace.finish(startTime.Add(latency))
+		}
+		observeTiming(ctx, config, OperationChat, model, latency, Usage{}, timing, err)
+		logGenerate(ctx, config, model, request, nil, latency, err)
+		attempts.finish(ctx, nil, err)
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk, streamBuffer)
+	go func() {
+		defer close(chunks)
+		defer cancel()
+
+		response, err := pumpStream(ctx, config, stream, chunks)
+		stream.Close()
+		attempts.record(response, err)
+		for attempt := 0; attempt < config.StreamResumeAttempts && ctx.Err() == nil; attempt++ {
+			interrupted, ok := err.(*StreamInterruptedError)
+			if !ok {
+				break
+			}
+			err = resumeStream(ctx, config, model, request, call, attempts, attempt+1, response, chunks, interrupted)
+		}
+		if err != nil {
+			err = streamContextError(ctx, err)
+		}
+		latency := time.Since(startTime)
+		response.Provider = config.Provider
+		response.Model = model
+		response.RequestID = stream.requestID
+		response.ResponseTime = latency
+		if trace != nil {
+			response.Timing = trace.finish(startTime.Add(latency))
+		}
+
+		final := StreamChunk{FinishReason: response.FinishReason, RawFinishReason: response.RawFinishReason, ToolCalls: response.ToolCalls, Usage: &response.Usage, Timing: response.Timing, Done: true, Err: err}
+		if ctx.Err() == nil {
+			select {
+			case chunks <- final:
+			case <-ctx.Done():
+			}
+		} else {
+			select {
+			case chunks <- final:
+			default:
+			}
+		}
+
+		observeTiming(ctx, config, OperationChat, model, latency, response.Usage, response.Timing, err)
+		logGenerate(ctx, config, model, request, response, latency, err)
+		if err == nil {
+			reportUsage(ctx, config, UsageEvent{
+				Model:     model,
+				Operation: OperationChat,
+				Usage:     response.Usage,
+				Latency:   latency,
+				RequestID: response.RequestID,
+			})
+		}
+		attempts.finish(ctx, response, err)
+	}()
+
+	return &Response{
+		Role:      RoleAssistant,
+		Provider:  config.Provider,
+		Model:     model,
+		RequestID: stream.requestID,
+		Stream:    chunks,
+	}, nil
+}
+
+// pumpStream decodes the events of stream, forwards content deltas to
+// chunks and returns the accumulated response. A stream that fails to read,
+// or ends without an end-of-stream event or a finish reason, fails with a
+// StreamInterruptedError.
+func pumpStream(ctx context.Context, config Config, stream *providerStream, chunks chan<- StreamChunk) (*Response, error) {
+	response := &Response{Role: RoleAssistant}
+	var content, reasoning strings.Builder
+	var toolCalls toolCallAccumulator
+	received := 0
+	ended := false
+	trace := timingTraceFrom(ctx)
+	var eventErr error
+	err := readSSE(stream.body, func(event sseEvent) error {
+		dumpEvent(config, stream.requestID, event)
+		chunk, err := stream.decode(event)
+		if err != nil {
+			eventErr = err
+			return err
+		}
+		if chunk.FinishReason != "" {
+			response.FinishReason = chunk.FinishReason
+			response.RawFinishReason = chunk.RawFinishReason
+		}
+		if chunk.Usage != nil {
+			response.Usage = *chunk.Usage
+		}
+		if chunk.Content == "" && chunk.ReasoningContent == "" && len(chunk.ToolCallDeltas) == 0 {
+			return nil
+		}
+		if trace != nil {
+			trace.markFirstToken()
+		}
+		content.WriteString(chunk.Content)
+		reasoning.WriteString(chunk.ReasoningContent)
+		toolCalls.add(chunk.ToolCallDeltas)
+		received++
+		select {
+		case chunks <- StreamChunk{Content: chunk.Content, ReasoningContent: chunk.ReasoningContent, ToolCallDeltas: chunk.ToolCallDeltas}:
+			return nil
+		case <-ctx.Done():
+			eventErr = ctx.Err()
+			return eventErr
+		}
+	})
+	if errors.Is(eventErr, io.EOF) {
+		ended, err = true, nil
+	}
+	if err == nil && !ended && response.FinishReason == "" {
+		err = ErrStreamIncomplete
+	}
+
+	response.Content = content.String()
+	response.ReasoningContent = reasoning.String()
+	response.ToolCalls = toolCalls.result()
+	response.TokensUsed = response.Usage.TotalTokens
+	if err != nil && err != eventErr && ctx.Err() == nil {
+		err = &StreamInterruptedError{
+			Provider:         config.Provider,
+			PartialContent:   response.Content,
+			PartialReasoning: response.ReasoningContent,
+			ChunksReceived:   received,
+			Err:              err,
+		}
+	}
+	return response, err
+}
+
+// resumeStream asks the model to continue the interrupted response (resume
+// attempt number attempt), streaming the continuation into chunks and
+// appending it to response. It returns the outcome of the continuation,
+// where a further interruption covers everything received so far, or
+// interrupted if the continuation could not be started. The hooks run for
+// the continuation as a new attempt of attempts; an error from them ends the
+// stream.
+func resumeStream(ctx context.Context, config Config, model string, request Request, call streamFunc, attempts *chatAttempts, attempt int, response *Response, chunks chan<- StreamChunk, interrupted *StreamInterruptedError) error {
+	resumed := request
+	resumed.IdempotencyKey = derivedIdempotencyKey(ctx, request.IdempotencyKey, fmt.Sprintf("resume-%d", attempt))
+	resumed.Messages = append(append([]Message(nil), request.Messages...), Message{
+		Role:    RoleUser,
+		Content: streamResumePrompt + response.Content,
+	})
+	attemptCtx, resumed, err := attempts.start(ctx, resumed)
+	if err != nil {
+		return err
+	}
+
+	stream, cancel, startErr := startStream(attemptCtx, config, model, resumed.Timeout, func(ctx context.Context) (*providerStream, error) {
+		return call(ctx, resumed)
+	})
+	if startErr != nil {
+		attempts.record(nil, startErr)
+		return interrupted
+	}
+	defer cancel()
+	part, partErr := pumpStream(ctx, config, stream, chunks)
+	stream.Close()
+	attempts.record(part, partErr)
+
+	response.Content += part.Content
+	response.ReasoningContent += part.ReasoningContent
+	response.ToolCalls = append(response.ToolCalls, part.ToolCalls...)
+	response.FinishReason = part.FinishReason
+	response.RawFinishReason = part.RawFinishReason
+	response.Usage = addUsage(response.Usage, part.Usage)
+	response.TokensUsed = response.Usage.TotalTokens
+	if next, ok := partErr.(*StreamInterruptedError); ok {
+		next.PartialContent = response.Content
+		next.PartialReasoning = response.ReasoningContent
+		next.ChunksReceived += interrupted.ChunksReceived
+	}
+	return partErr
+}
+
+// addUsage returns the sum of two usages
+func addUsage(a, b Usage) Usage {
+	return Usage{
+		PromptTokens:        a.PromptTokens + b.PromptTokens,
+		CompletionTokens:    a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:         a.TotalTokens + b.TotalTokens,
+		CachedTokens:        a.CachedTokens + b.CachedTokens,
+		CacheCreationTokens: a.CacheCreationTokens + b.CacheCreationTokens,
+	}
+}
+
+// streamContextError classifies a mid-stream error caused by the caller's
+// context like withTimeout does
+func streamContextError(ctx context.Context, err error) error {
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return &TimeoutError{Source: TimeoutCaller, Err: err}
+	case ctx.Err() != nil:
+		return fmt.Errorf("%w: %w", ErrCancelled, err)
+	}
+	return err
+}
+
+// startStream runs call, which opens a stream, with the request, model or client
+// timeout bounding only the wait for call to return. The stream then lives
+// until the returned cancel is called, which the caller must do once the
+// stream is closed.
+func startStream[T io.Closer](ctx context.Context, config Config, model string, requestTimeout time.Duration, call func(ctx context.Context) (T, error)) (T, context.CancelFunc, error) {
+	timeout, source := callTimeout(config, model, requestTimeout)
+	streamCtx, cancel := context.WithCancel(ctx)
+	var timer *time.Timer
+	var timedOut atomic.Bool
+	if timeout > 0 {
+		timer = time.AfterFunc(timeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+	}
+
+	stream, err := call(streamCtx)
+	if timer != nil {
+		timer.Stop()
+	}
+	if err == nil && timedOut.Load() {
+		// The timer fired between the headers arriving and Stop
+		stream.Close()
+		err = context.DeadlineExceeded
+	}
+	if err != nil {
+		switch {
+		case timedOut.Load():
+			err = &TimeoutError{Source: source, Timeout: timeout, Err: err}
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = &TimeoutError{Source: TimeoutCaller, Err: err}
+		case ctx.Err() != nil:
+			err = fmt.Errorf("%w: %w", ErrCancelled, err)
+		}
+		cancel()
+		var zero T
+		return zero, nil, err
+	}
+	return stream, cancel, nil
+}
+
+// relayStream returns a copy of the streaming response whose Stream
+// forwards the chunks of response.Stream, for wrappers that act when a
+// stream ends. end is called once: with the final chunk, before it is
+// forwarded, or with nil if the stream closed without one. Once ctx is done
+// the remaining chunks are drained instead of forwarded, so the wrapped
+// stream always shuts down.
+func relayStream(ctx context.Context, response *Response, end func(final *StreamChunk)) *Response {
+	in := response.Stream
+	out := make(chan StreamChunk, streamBuffer)
+	relayed := *response
+	relayed.Stream = out
+	go func() {
+		defer close(out)
+		ended := false
+		forwarding := true
+		for chunk := range in {
+			if chunk.Done && !ended {
+				ended = true
+				end(&chunk)
+			}
+			if !forwarding {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				forwarding = false
+			}
+		}
+		if !ended {
+			end(nil)
+		}
+	}()
+	return &relayed
+}
+
+// GenerateWithCallback streams a chat call with client like GenerateStream,
+// calling onDelta for every content, reasoning or tool call delta, and
+// returns the accumulated response with FinishReason, ToolCalls and Usage
+// once the stream ends. An
+// error returned by onDelta aborts the stream, cancelling the upstream
+// request, and is returned as is. The stream is also closed if onDelta
+// panics.
+func GenerateWithCallback(ctx context.Context, client Client, request Request, onDelta func(chunk StreamChunk) error) (*Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	startTime := time.Now()
+	response, err := GenerateStream(ctx, client, request)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stream := response.Stream
+	defer func() {
+		// Wait for the stream to shut down so the body is closed and hooks
+		// have run, even when onDelta panicked
+		cancel()
+		for range stream {
+		}
+	}()
+
+	var content, reasoning strings.Builder
+	for chunk := range stream {
+		if chunk.Done {
+			if chunk.Err != nil {
+				return nil, chunk.Err
+			}
+			response.FinishReason = chunk.FinishReason
+			response.RawFinishReason = chunk.RawFinishReason
+			response.ToolCalls = chunk.ToolCalls
+			if chunk.Usage != nil {
+				response.Usage = *chunk.Usage
+			}
+			break
+		}
+		content.WriteString(chunk.Content)
+		reasoning.WriteString(chunk.ReasoningContent)
+		if err := onDelta(chunk); err != nil {
+			return nil, err
+		}
+	}
+
+	response.Content = content.String()
+	response.ReasoningContent = reasoning.String()
+	response.TokensUsed = response.Usage.TotalTokens
+	response.ResponseTime = time.Since(startTime)
+	response.Stream = nil
+	return response, nil
+}
+
+// Stream returns an iterator over a streaming chat call with client, for use
+// as
+//
+//	for chunk, err := range llm.Stream(ctx, client, request) {
+//
+// It yields the deltas, then the final Done chunk with FinishReason and Usage
+// and the stream error, if any. An error before the stream starts is yielded
+// on its own. Breaking out of the loop closes the connection. Every range
+// over the iterator starts a new call.
+func Stream(ctx context.Context, client Client, request Request) iter.Seq2[StreamChunk, error] {
+	return func(yield func(StreamChunk, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		response, err := GenerateStream(ctx, client, request)
+		if err != nil {
+			cancel()
+			yield(StreamChunk{}, err)
+			return
+		}
+		defer func() {
+			cancel()
+			for range response.Stream {
+			}
+		}()
+
+		for chunk := range response.Stream {
+			if !yield(chunk, chunk.Err) || chunk.Done {
+				return
+			}
+		}
+	}
+}
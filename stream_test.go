@@ -0,0 +1,556 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serveStream returns a server that answers every request with the recorded
+// event stream in testdata/streams/name, recording the request path, query
+// and payload
+func serveStream(t *testing.T, name string, path, query *string, payload *map[string]interface{}) *httptest.Server {
+	t.Helper()
+	body, err := os.ReadFile("testdata/streams/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*path, *query = r.URL.Path, r.URL.RawQuery
+		json.NewDecoder(r.Body).Decode(payload)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// drainStream collects the deltas of response.Stream and returns them with
+// the final chunk
+func drainStream(t *testing.T, response *Response) (content, reasoning string, final StreamChunk) {
+	t.Helper()
+	var c, r strings.Builder
+	for chunk := range response.Stream {
+		if chunk.Done {
+			final = chunk
+			continue
+		}
+		c.WriteString(chunk.Content)
+		r.WriteString(chunk.ReasoningContent)
+	}
+	if !final.Done {
+		t.Fatal("Stream closed without a final chunk")
+	}
+	return c.String(), r.String(), final
+}
+
+func TestGenerateStream(t *testing.T) {
+	tests := []struct {
+		name      string
+		fixture   string
+		config    Config
+		path      string
+		query     string
+		content   string
+		reasoning string
+		usage     Usage
+	}{
+		{
+			name:    "openai chat completions",
+			fixture: "openai_chat.sse",
+			config:  Config{Provider: ProviderOpenAI, DefaultModel: "gpt-4o-mini"},
+			path:    "/chat/completions",
+			content: "The capital is Paris.",
+			usage:   Usage{PromptTokens: 24, CompletionTokens: 6, TotalTokens: 30},
+		},
+		{
+			name:      "deepseek reasoner",
+			fixture:   "deepseek_reasoner.sse",
+			config:    Config{Provider: ProviderDeepSeek, DefaultModel: "deepseek-reasoner"},
+			path:      "/chat/completions",
+			content:   "Paris",
+			reasoning: "France's capital is Paris.",
+			usage:     Usage{PromptTokens: 14, CompletionTokens: 9, TotalTokens: 23},
+		},
+		{
+			name:    "openai responses",
+			fixture: "openai_responses.sse",
+			config:  Config{Provider: ProviderOpenAI, DefaultModel: "gpt-4.1-mini", UseResponsesAPI: true},
+			path:    "/responses",
+			content: "Paris.",
+			usage:   Usage{PromptTokens: 20, CompletionTokens: 3, TotalTokens: 23},
+		},
+		{
+			name:    "gemini",
+			fixture: "gemini.sse",
+			config:  Config{Provider: ProviderGemini, DefaultModel: "gemini-2.5-flash"},
+			path:    "/models/gemini-2.5-flash:streamGenerateContent",
+			query:   "alt=sse",
+			content: "The capital of France is Paris.",
+			usage:   Usage{PromptTokens: 9, CompletionTokens: 31, TotalTokens: 40},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, query string
+			var payload map[string]interface{}
+			server := serveStream(t, tt.fixture, &path, &query, &payload)
+
+			var afterResponse *Response
+			config := tt.config
+			config.APIKey = "test-key"
+			config.BaseURL = server.URL
+			config.AfterResponse = []AfterResponseHook{func(_ context.Context, _ *Request, resp *Response, err error) {
+				afterResponse = resp
+			}}
+			client, _ := NewClient(config)
+			response, err := GenerateStream(context.Background(), client, BuildSimpleRequest("What is the capital of France?"))
+			if err != nil {
+				t.Fatalf("GenerateStream failed: %v", err)
+			}
+			if response.Provider != tt.config.Provider || response.Model != tt.config.DefaultModel || response.RequestID == "" {
+				t.Errorf("Unexpected response %+v", response)
+			}
+
+			content, reasoning, final := drainStream(t, response)
+			if final.Err != nil {
+				t.Fatalf("Stream failed: %v", final.Err)
+			}
+			if content != tt.content || reasoning != tt.reasoning {
+				t.Errorf("Expected %q/%q, got %q/%q", tt.content, tt.reasoning, content, reasoning)
+			}
+			if final.FinishReason != FinishStop || final.RawFinishReason == "" || final.Usage == nil || *final.Usage != tt.usage {
+				t.Errorf("Unexpected final chunk %+v (usage %+v)", final, final.Usage)
+			}
+
+			if !strings.HasSuffix(path, tt.path) || query != tt.query {
+				t.Errorf("Expected %s?%s, got %s?%s", tt.path, tt.query, path, query)
+			}
+			if tt.config.Provider != ProviderGemini && payload["stream"] != true {
+				t.Errorf("Expected stream: true, got %v", payload)
+			}
+			if tt.path == "/chat/completions" {
+				options, _ := payload["stream_options"].(map[string]interface{})
+				if options["include_usage"] != true {
+					t.Errorf("Expected include_usage, got %v", payload["stream_options"])
+				}
+			}
+
+			if afterResponse == nil || afterResponse.Content != tt.content || afterResponse.Usage != tt.usage {
+				t.Errorf("Expected the accumulated response in AfterResponse hooks, got %+v", afterResponse)
+			}
+		})
+	}
+}
+
+func TestGenerateStreamErrors(t *testing.T) {
+	t.Run("api error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error": {"message": "Incorrect API key provided", "type": "invalid_request_error", "code": "invalid_api_key"}}`))
+		}))
+		defer server.Close()
+
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "bad-key", BaseURL: server.URL})
+		_, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hello"))
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expected a 401 APIError, got %v", err)
+		}
+	})
+
+	t.Run("incomplete", func(t *testing.T) {
+		body, _ := os.ReadFile("testdata/streams/openai_chat.sse")
+		cut := body[:bytes.Index(body, []byte(`"finish_reason":"stop"`))]
+		cut = cut[:bytes.LastIndex(cut, []byte("\n\n"))+2]
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write(cut)
+		}))
+		defer server.Close()
+
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+		response, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hello"))
+		if err != nil {
+			t.Fatalf("GenerateStream failed: %v", err)
+		}
+		content, _, final := drainStream(t, response)
+		if !errors.Is(final.Err, ErrStreamIncomplete) || content != "The capital is Paris." {
+			t.Errorf("Expected ErrStreamIncomplete after the received deltas, got %q, %v", content, final.Err)
+		}
+		var interrupted *StreamInterruptedError
+		if !errors.As(final.Err, &interrupted) || interrupted.PartialContent != content || interrupted.ChunksReceived != 3 {
+			t.Errorf("Expected a StreamInterruptedError with the partial content, got %v", final.Err)
+		}
+	})
+
+	t.Run("gemini safety block", func(t *testing.T) {
+		var path, query string
+		var payload map[string]interface{}
+		server := serveStream(t, "gemini_blocked.sse", &path, &query, &payload)
+
+		client, _ := NewClient(Config{Provider: ProviderGemini, APIKey: "test-key", BaseURL: server.URL})
+		response, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hello"))
+		if err != nil {
+			t.Fatalf("GenerateStream failed: %v", err)
+		}
+		content, _, final := drainStream(t, response)
+		var filtered *ContentFilteredError
+		if !errors.As(final.Err, &filtered) || filtered.Category != HarmCategoryDangerousContent || content != "Here is" {
+			t.Errorf("Expected a ContentFilteredError, got %q, %v", content, final.Err)
+		}
+		if !errors.Is(final.Err, ErrContentFiltered) {
+			t.Errorf("Expected ErrContentFiltered, got %v", final.Err)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		for _, provider := range []Provider{ProviderCohere, ProviderQwen} {
+			client, _ := NewClient(Config{Provider: provider, APIKey: "test-key"})
+			_, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hello"))
+			var capErr *CapabilityError
+			if !errors.As(err, &capErr) || capErr.Capability != CapabilityStreaming {
+				t.Errorf("%s: expected a streaming CapabilityError, got %v", provider, err)
+			}
+		}
+	})
+}
+
+func TestReadSSE(t *testing.T) {
+	input := ": keep-alive\n\nevent: update\ndata: first\ndata: second\n\ndata:{\"a\":1}\r\n\r\ndata: cut off"
+	var events []sseEvent
+	err := readSSE(strings.NewReader(input), func(event sseEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("readSSE failed: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", events)
+	}
+	if events[0].Event != "update" || string(events[0].Data) != "first\nsecond" {
+		t.Errorf("Unexpected first event %+v", events[0])
+	}
+	if events[1].Event != "" || string(events[1].Data) != `{"a":1}` {
+		t.Errorf("Unexpected second event %q", events[1].Data)
+	}
+}
+
+func TestGenerateWithCallback(t *testing.T) {
+	var path, query string
+	var payload map[string]interface{}
+	server := serveStream(t, "openai_chat.sse", &path, &query, &payload)
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+	var deltas []string
+	response, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(chunk StreamChunk) error {
+		deltas = append(deltas, chunk.Content)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("GenerateWithCallback failed: %v", err)
+	}
+	if strings.Join(deltas, "|") != "The| capital| is Paris." {
+		t.Errorf("Unexpected deltas %q", deltas)
+	}
+	if response.Content != "The capital is Paris." || response.FinishReason != "stop" || response.TokensUsed != 30 || response.Stream != nil {
+		t.Errorf("Unexpected response %+v", response)
+	}
+}
+
+// serveEndlessStream returns a server that sends one chat chunk and then
+// holds the stream open, closing done once the client went away
+func serveEndlessStream(t *testing.T) (*httptest.Server, chan struct{}) {
+	t.Helper()
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Once\"}}]}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(done)
+	}))
+	t.Cleanup(server.Close)
+	return server, done
+}
+
+func TestGenerateWithCallbackAbort(t *testing.T) {
+	t.Run("callback error", func(t *testing.T) {
+		server, done := serveEndlessStream(t)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+		errStop := errors.New("stop")
+		_, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(chunk StreamChunk) error {
+			return errStop
+		})
+		if err != errStop {
+			t.Errorf("Expected the callback error, got %v", err)
+		}
+		<-done
+	})
+
+	t.Run("callback panic", func(t *testing.T) {
+		server, done := serveEndlessStream(t)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected the panic to propagate")
+				}
+			}()
+			GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(chunk StreamChunk) error {
+				panic("boom")
+			})
+		}()
+		<-done
+	})
+}
+
+func TestStreamIterator(t *testing.T) {
+	t.Run("complete", func(t *testing.T) {
+		var path, query string
+		var payload map[string]interface{}
+		server := serveStream(t, "gemini.sse", &path, &query, &payload)
+		client, _ := NewClient(Config{Provider: ProviderGemini, APIKey: "test-key", BaseURL: server.URL})
+
+		var content strings.Builder
+		var final StreamChunk
+		for chunk, err := range Stream(context.Background(), client, BuildSimpleRequest("Hello")) {
+			if err != nil {
+				t.Fatalf("Stream failed: %v", err)
+			}
+			content.WriteString(chunk.Content)
+			final = chunk
+		}
+		if content.String() != "The capital of France is Paris." || !final.Done || final.FinishReason != "stop" || final.Usage.TotalTokens != 40 {
+			t.Errorf("Unexpected stream %q, final %+v", content.String(), final)
+		}
+	})
+
+	t.Run("early break", func(t *testing.T) {
+		server, done := serveEndlessStream(t)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+		for chunk, err := range Stream(context.Background(), client, BuildSimpleRequest("Hello")) {
+			if err != nil || chunk.Content != "Once" {
+				t.Errorf("Unexpected chunk %+v, %v", chunk, err)
+			}
+			break
+		}
+		<-done
+	})
+
+	t.Run("error before start", func(t *testing.T) {
+		client, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key"})
+		calls := 0
+		for _, err := range Stream(context.Background(), client, BuildSimpleRequest("Hello")) {
+			calls++
+			if !errors.Is(err, ErrUnsupported) {
+				t.Errorf("Expected ErrUnsupported, got %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("Expected one error, got %d iterations", calls)
+		}
+	})
+}
+
+// serveDroppingStream returns a server whose first count responses send the
+// first cut bytes of the openai_chat.sse fixture and then drop the
+// connection; later responses send the whole fixture. Request payloads are
+// appended to payloads.
+func serveDroppingStream(t *testing.T, count int, payloads *[]map[string]interface{}) *httptest.Server {
+	t.Helper()
+	body, err := os.ReadFile("testdata/streams/openai_chat.sse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cut := bytes.Index(body, []byte(": keep-alive"))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&payload)
+		*payloads = append(*payloads, payload)
+		w.Header().Set("Content-Type", "text/event-stream")
+		if len(*payloads) > count {
+			w.Write(body)
+			return
+		}
+		w.Write(body[:cut])
+		w.(http.Flusher).Flush()
+		panic(http.ErrAbortHandler)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestStreamInterrupted(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := serveDroppingStream(t, 1, &payloads)
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	_, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(StreamChunk) error { return nil })
+	var interrupted *StreamInterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected a StreamInterruptedError, got %v", err)
+	}
+	if interrupted.PartialContent != "The capital" || interrupted.ChunksReceived != 2 || interrupted.Provider != ProviderOpenAI {
+		t.Errorf("Unexpected interruption %+v", interrupted)
+	}
+	if errors.Is(err, ErrStreamIncomplete) || len(payloads) != 1 {
+		t.Errorf("Expected a read error and no retry, got %v after %d requests", err, len(payloads))
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := serveDroppingStream(t, 1, &payloads)
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, StreamResumeAttempts: 2})
+
+	response, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(StreamChunk) error { return nil })
+	if err != nil {
+		t.Fatalf("GenerateWithCallback failed: %v", err)
+	}
+	if response.Content != "The capitalThe capital is Paris." || response.Usage.TotalTokens != 30 || response.FinishReason != "stop" {
+		t.Errorf("Unexpected response %+v", response)
+	}
+	if len(payloads) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(payloads))
+	}
+	messages, _ := payloads[1]["messages"].([]interface{})
+	last, _ := messages[len(messages)-1].(map[string]interface{})
+	if len(messages) != 2 || last["role"] != "user" || !strings.HasSuffix(last["content"].(string), "Continue from:\n\nThe capital") {
+		t.Errorf("Unexpected resume messages %v", messages)
+	}
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		var payloads []map[string]interface{}
+		server := serveDroppingStream(t, 3, &payloads)
+		client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, StreamResumeAttempts: 2})
+
+		_, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Hello"), func(StreamChunk) error { return nil })
+		var interrupted *StreamInterruptedError
+		if !errors.As(err, &interrupted) || interrupted.PartialContent != "The capitalThe capitalThe capital" || interrupted.ChunksReceived != 6 {
+			t.Errorf("Expected the interruption to cover all attempts, got %+v", err)
+		}
+		if len(payloads) != 3 {
+			t.Errorf("Expected 3 requests, got %d", len(payloads))
+		}
+	})
+}
+
+func TestWrapperStreams(t *testing.T) {
+	var path, query string
+	var payload map[string]interface{}
+	server := serveStream(t, "openai_chat.sse", &path, &query, &payload)
+	inner, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, DefaultModel: "gpt-4o-mini"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	ab, err := NewABClient(ABConfig{Variants: []Variant{{Name: "control", Client: inner}}})
+	if err != nil {
+		t.Fatalf("Failed to create experiment: %v", err)
+	}
+	budget := NewBudgetClient(inner, BudgetConfig{MaxTokens: 1000})
+	scheduler := NewSchedulerClient(inner, SchedulerConfig{MaxConcurrency: 1})
+
+	for name, client := range map[string]Client{
+		"budget":    budget,
+		"ab":        ab,
+		"scheduler": scheduler,
+		"shadow":    NewShadowClient(inner, inner, ShadowConfig{}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			response, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hello"))
+			if err != nil {
+				t.Fatalf("GenerateStream failed: %v", err)
+			}
+			content, _, final := drainStream(t, response)
+			if content == "" || final.Err != nil || final.Usage == nil || final.Usage.TotalTokens == 0 {
+				t.Errorf("Expected the streamed answer, got %q, %+v", content, final)
+			}
+		})
+	}
+
+	if spend := budget.Spend(""); spend.Tokens == 0 {
+		t.Error("Expected the budget to account the stream")
+	}
+	if stats := scheduler.Stats(); stats.Running != 0 || stats.Priorities[PriorityNormal].Admitted != 1 {
+		t.Errorf("Expected the stream admitted and its slot released, got %+v", stats)
+	}
+	response, err := GenerateStream(context.Background(), ab, BuildSimpleRequest("Hello"))
+	if err != nil || response.Variant != "control" {
+		t.Fatalf("Expected the variant on the stream, got %v, %v", response, err)
+	}
+	drainStream(t, response)
+}
+
+func TestGenerateStreamToolCalls(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		config  Config
+		want    []ToolCall
+	}{
+		{
+			name:    "openai chat completions",
+			fixture: "openai_tool_calls.sse",
+			config:  Config{Provider: ProviderOpenAI},
+			want: []ToolCall{
+				{ID: "call_weather", Name: "get_weather", Arguments: `{"city":"Paris"}`},
+				{ID: "call_time", Name: "get_time", Arguments: "{}"},
+			},
+		},
+		{
+			name:    "openai responses",
+			fixture: "openai_responses_tool_calls.sse",
+			config:  Config{Provider: ProviderOpenAI, UseResponsesAPI: true},
+			want:    []ToolCall{{ID: "call_weather", Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		},
+		{
+			name:    "gemini",
+			fixture: "gemini_tool_calls.sse",
+			config:  Config{Provider: ProviderGemini, DefaultModel: "gemini-2.5-flash"},
+			want: []ToolCall{
+				{ID: "get_weather_0", Name: "get_weather", Arguments: `{"city": "Paris"}`},
+				{ID: "get_weather_1", Name: "get_weather", Arguments: `{"city": "Rome"}`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path, query string
+			var payload map[string]interface{}
+			server := serveStream(t, tt.fixture, &path, &query, &payload)
+			config := tt.config
+			config.APIKey = "test-key"
+			config.BaseURL = server.URL
+			client, _ := NewClient(config)
+
+			var deltas int
+			response, err := GenerateWithCallback(context.Background(), client, BuildSimpleRequest("Weather?"), func(chunk StreamChunk) error {
+				deltas += len(chunk.ToolCallDeltas)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("GenerateWithCallback failed: %v", err)
+			}
+			if deltas == 0 {
+				t.Error("Expected tool call deltas to be forwarded")
+			}
+			if response.FinishReason != FinishToolCalls || !reflect.DeepEqual(response.ToolCalls, tt.want) {
+				t.Errorf("Expected tool calls %+v, got %+v (%s)", tt.want, response.ToolCalls, response.FinishReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SummaryPlaceholder is replaced by the text to summarize in
+// SummarizeOptions.MapPrompt and ReducePrompt
+const SummaryPlaceholder = "{{text}}"
+
+// Default SummarizeLong prompts
+const (
+	DefaultMapPrompt    = "Summarize the following part of a longer document. Keep every fact, name and number that matters.\n\n{{text}}"
+	DefaultReducePrompt = "The following are summaries of consecutive parts of one document. Combine them into a single coherent summary.\n\n{{text}}"
+)
+
+// SummarizeOptions configures SummarizeLong
+type SummarizeOptions struct {
+	// ChunkTokens is the size of the pieces the text is split into, and the
+	// budget of summaries combined in one reduce call (0 = 3000)
+	ChunkTokens int
+	// Overlap is the number of tokens consecutive pieces share (0 = none)
+	Overlap int
+
+	// MapPrompt and ReducePrompt are the prompts of the map and reduce calls.
+	// Each must contain SummaryPlaceholder, which is replaced by the piece of
+	// text or the joined summaries (empty = DefaultMapPrompt and
+	// DefaultReducePrompt).
+	MapPrompt    string
+	ReducePrompt string
+
+	// MaxDepth is the number of reduce levels (0 = 5). The last level
+	// combines all remaining summaries in one call, even over ChunkTokens.
+	MaxDepth int
+
+	// Concurrency is the number of calls in flight (0 = 1)
+	Concurrency int
+
+	// Options are applied to every request, e.g. WithModel or WithMaxTokens
+	Options []RequestOption
+}
+
+// SummaryResult is the outcome of SummarizeLong
+type SummaryResult struct {
+	Summary string
+	// Levels holds the intermediate summaries for auditing: Levels[0] are the
+	// summaries of the pieces, each further level those of a reduce round.
+	// The last level holds only Summary.
+	Levels [][]string
+	// Usage is the combined usage of all calls
+	Usage Usage
+}
+
+// SummarizeLong summarizes text that does not fit the model's context:
+// the text is split into pieces with the client's tokenizer, every piece is
+// summarized (map), and the summaries are combined in groups that fit
+// ChunkTokens until one summary remains (reduce). A text that fits in one
+// piece takes a single call.
+func SummarizeLong(ctx context.Context, client Client, text string, opts SummarizeOptions) (*SummaryResult, error) {
+	chunkTokens := opts.ChunkTokens
+	if chunkTokens <= 0 {
+		chunkTokens = 3000
+	}
+	maxDepth := opts.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = 5
+	}
+	mapPrompt, reducePrompt := opts.MapPrompt, opts.ReducePrompt
+	if mapPrompt == "" {
+		mapPrompt = DefaultMapPrompt
+	}
+	if reducePrompt == "" {
+		reducePrompt = DefaultReducePrompt
+	}
+	if !strings.Contains(mapPrompt, SummaryPlaceholder) || !strings.Contains(reducePrompt, SummaryPlaceholder) {
+		return nil, fmt.Errorf("summary prompts must contain %s", SummaryPlaceholder)
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, errors.New("nothing to summarize")
+	}
+
+	config := client.GetConfig()
+	tokenizer := tokenizerFor(config)
+	model := config.DefaultModel
+	if probe := NewRequest(opts.Options...); probe.Model != nil {
+		model = *probe.Model
+	}
+	count := func(text string) int { return tokenizer.CountTokens(model, text) }
+
+	pieces := []string{text}
+	if count(text) > chunkTokens {
+		pieces = nil
+		for _, chunk := range splitByTokens(count, text, chunkTokens, min(opts.Overlap, chunkTokens/2)) {
+			pieces = append(pieces, chunk.text)
+		}
+	}
+
+	result := &SummaryResult{}
+	summaries, err := summarizeAll(ctx, client, mapPrompt, pieces, opts, result)
+	if err != nil {
+		return result, err
+	}
+	for depth := 1; len(summaries) > 1; depth++ {
+		groups := [][]string{summaries}
+		if depth < maxDepth {
+			groups = groupSummaries(summaries, count, chunkTokens)
+		}
+		joined := make([]string, len(groups))
+		for i, group := range groups {
+			joined[i] = strings.Join(group, "\n\n")
+		}
+		if summaries, err = summarizeAll(ctx, client, reducePrompt, joined, opts, result); err != nil {
+			return result, err
+		}
+	}
+	result.Summary = summaries[0]
+	return result, nil
+}
+
+// summarizeAll runs one map or reduce level, summarizing every text with
+// prompt, and records the summaries and usage in result
+func summarizeAll(ctx context.Context, client Client, prompt string, texts []string, opts SummarizeOptions, result *SummaryResult) ([]string, error) {
+	requests := make([]Request, len(texts))
+	for i, text := range texts {
+		requests[i] = NewRequest(opts.Options...)
+		requests[i].AddUserMessage(strings.ReplaceAll(prompt, SummaryPlaceholder, text))
+	}
+
+	responses, errs := GenerateBatch(ctx, client, requests, GenerateBatchOptions{
+		Concurrency: opts.Concurrency,
+		StopOnError: true,
+	})
+	result.Usage = addUsage(result.Usage, TotalUsage(responses))
+	summaries := make([]string, len(texts))
+	for i, response := range responses {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("summarizing part %d of %d: %w", i+1, len(texts), errs[i])
+		}
+		summaries[i] = response.Content
+	}
+	result.Levels = append(result.Levels, summaries)
+	return summaries, nil
+}
+
+// groupSummaries packs consecutive summaries into groups of at most
+// chunkTokens, with at least two per group so every level shrinks
+func groupSummaries(summaries []string, count func(string) int, chunkTokens int) [][]string {
+	var groups [][]string
+	var group []string
+	tokens := 0
+	for _, summary := range summaries {
+		n := count(summary)
+		if len(group) >= 2 && tokens+n > chunkTokens {
+			groups = append(groups, group)
+			group, tokens = nil, 0
+		}
+		group = append(group, summary)
+		tokens += n
+	}
+	if len(group) == 1 && len(groups) > 0 {
+		// never leave a summary to be "combined" alone
+		groups[len(groups)-1] = append(groups[len(groups)-1], group[0])
+	} else {
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// serveSummaries returns a chat server for SummarizeLong with "MAP: " and
+// "REDUCE: " prompts: a map call answers "part <first word> x x" and a
+// reduce call "sum <number of summaries>"
+func serveSummaries(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Messages []Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		prompt := payload.Messages[len(payload.Messages)-1].Content
+		var content string
+		if text, ok := strings.CutPrefix(prompt, "MAP: "); ok {
+			content = "part " + strings.Fields(text)[0] + " x x"
+		} else {
+			text, _ := strings.CutPrefix(prompt, "REDUCE: ")
+			content = fmt.Sprintf("sum %d", len(strings.Split(text, "\n\n")))
+		}
+		fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %q}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 2, "completion_tokens": 1, "total_tokens": 3}}`, content)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSummarizeLong(t *testing.T) {
+	server := serveSummaries(t)
+	words := TokenizerFunc(func(model, text string) int { return len(strings.Fields(text)) })
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, Tokenizer: words})
+
+	var text strings.Builder
+	for i := range 40 {
+		fmt.Fprintf(&text, "w%d ", i)
+	}
+	opts := SummarizeOptions{ChunkTokens: 10, MapPrompt: "MAP: {{text}}", ReducePrompt: "REDUCE: {{text}}", Concurrency: 3}
+
+	result, err := SummarizeLong(context.Background(), client, text.String(), opts)
+	if err != nil {
+		t.Fatalf("SummarizeLong failed: %v", err)
+	}
+	want := [][]string{
+		{"part w0 x x", "part w10 x x", "part w20 x x", "part w30 x x"},
+		{"sum 2", "sum 2"},
+		{"sum 2"},
+	}
+	if !reflect.DeepEqual(result.Levels, want) || result.Summary != "sum 2" {
+		t.Errorf("Unexpected levels %q, summary %q", result.Levels, result.Summary)
+	}
+	if result.Usage.TotalTokens != 21 {
+		t.Errorf("Expected the usage of 7 calls, got %+v", result.Usage)
+	}
+
+	t.Run("max depth", func(t *testing.T) {
+		opts := opts
+		opts.MaxDepth = 1
+		result, err := SummarizeLong(context.Background(), client, text.String(), opts)
+		if err != nil || len(result.Levels) != 2 || result.Summary != "sum 4" {
+			t.Errorf("Expected one reduce of all 4 summaries, got %+v, %v", result, err)
+		}
+	})
+
+	t.Run("short text", func(t *testing.T) {
+		result, err := SummarizeLong(context.Background(), client, "w0 w1 w2", opts)
+		if err != nil || len(result.Levels) != 1 || result.Summary != "part w0 x x" {
+			t.Errorf("Expected a single map call, got %+v, %v", result, err)
+		}
+	})
+
+	t.Run("prompt without placeholder", func(t *testing.T) {
+		opts := opts
+		opts.ReducePrompt = "Combine these."
+		if _, err := SummarizeLong(context.Background(), client, text.String(), opts); err == nil {
+			t.Error("Expected an error for a prompt without the placeholder")
+		}
+	})
+}
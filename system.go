@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SystemMessagePolicy decides what happens when a request ends up with more
+// than one system message, e.g. GenerateWithHistory adding a system prompt
+// to a history that already starts with one
+type SystemMessagePolicy string
+
+const (
+	// SystemMessagesKeep sends every system message as-is (the default)
+	SystemMessagesKeep SystemMessagePolicy = ""
+	// SystemMessagesMerge joins all system messages, in order, into a single
+	// system message at the front
+	SystemMessagesMerge SystemMessagePolicy = "merge"
+	// SystemMessagesReplace keeps only the first system message, which is the
+	// one added last by AddSystemMessage or Request.SystemPrompt
+	SystemMessagesReplace SystemMessagePolicy = "replace"
+	// SystemMessagesError fails the call with ErrMultipleSystemMessages
+	SystemMessagesError SystemMessagePolicy = "error"
+)
+
+// ErrMultipleSystemMessages is returned under SystemMessagesError
+var ErrMultipleSystemMessages = errors.New("request has more than one system message")
+
+// requestMessages returns the messages to send for request: Request.SystemPrompt,
+// when set, comes first as a system message, followed by request.Messages
+// (including any system messages they contain). Payload builders must use
+// this instead of reading request.Messages directly.
+func requestMessages(request Request) []Message {
+	if request.SystemPrompt == "" {
+		return request.Messages
+	}
+	messages := make([]Message, 0, len(request.Messages)+1)
+	messages = append(messages, Message{Role: RoleSystem, Content: request.SystemPrompt})
+	return append(messages, request.Messages...)
+}
+
+// systemText joins the content of all system messages, in order, for
+// providers that take the system prompt as a separate field
+func systemText(messages []Message) string {
+	return systemJoin(messages, "\n\n")
+}
+
+// systemJoin joins the non-empty system message contents with sep
+func systemJoin(messages []Message, sep string) string {
+	var parts []string
+	for _, msg := range messages {
+		if msg.Role == RoleSystem && msg.Content != "" {
+			parts = append(parts, msg.Content)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// applySystemPolicy folds Request.SystemPrompt into the messages and applies
+// Config.SystemMessagePolicy, so every provider sees the same system text
+func applySystemPolicy(config Config, request *Request) error {
+	messages := requestMessages(*request)
+	count := 0
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			count++
+		}
+	}
+	if count > 1 {
+		switch config.SystemMessagePolicy {
+		case SystemMessagesKeep:
+		case SystemMessagesMerge:
+			messages = mergeSystemMessages(messages, systemJoin(messages, "\n"))
+		case SystemMessagesReplace:
+			messages = mergeSystemMessages(messages, firstSystem(messages))
+		case SystemMessagesError:
+			return fmt.Errorf("%w (%d found)", ErrMultipleSystemMessages, count)
+		default:
+			return fmt.Errorf("unknown system message policy %q", config.SystemMessagePolicy)
+		}
+	}
+	request.SystemPrompt = ""
+	request.Messages = messages
+	return nil
+}
+
+// mergeSystemMessages replaces all system messages with a single one at the
+// front, cacheable if any of them was
+func mergeSystemMessages(messages []Message, content string) []Message {
+	result := make([]Message, 1, len(messages))
+	result[0] = Message{Role: RoleSystem, Content: content}
+	for _, msg := range messages {
+		if msg.Role != RoleSystem {
+			result = append(result, msg)
+		} else if msg.CacheControl {
+			result[0].CacheControl = true
+		}
+	}
+	return result
+}
+
+// firstSystem returns the content of the first system message
+func firstSystem(messages []Message) string {
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			return msg.Content
+		}
+	}
+	return ""
+}
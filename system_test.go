@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSystemPromptPayloads(t *testing.T) {
+	request := Request{
+		SystemPrompt: "You are terse.",
+		Messages: []Message{
+			{Role: RoleSystem, Content: "Answer in French."},
+			{Role: RoleUser, Content: "Hello"},
+		},
+	}
+	wantMessages := []chatMessage{
+		{Role: "system", Content: "You are terse."},
+		{Role: "system", Content: "Answer in French."},
+		{Role: "user", Content: "Hello"},
+	}
+
+	config := Config{APIKey: "test-key"}
+
+	t.Run("openai", func(t *testing.T) {
+		client, _ := newOpenAIClient(config)
+		payload := client.buildPayload(request)
+		if !reflect.DeepEqual(payload.Messages, wantMessages) {
+			t.Errorf("messages = %v", payload.Messages)
+		}
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		client, _ := newAzureClient(Config{APIKey: "test-key", BaseURL: "https://example.openai.azure.com/openai/deployments/gpt-4o"})
+		payload := client.buildPayload(request)
+		if !reflect.DeepEqual(payload.Messages, wantMessages) {
+			t.Errorf("messages = %v", payload.Messages)
+		}
+	})
+
+	t.Run("qwen", func(t *testing.T) {
+		client, _ := newQwenClient(config)
+		payload := client.buildPayload(request)
+		if !reflect.DeepEqual(payload.Messages, wantMessages) {
+			t.Errorf("messages = %v", payload.Messages)
+		}
+	})
+
+	t.Run("cohere", func(t *testing.T) {
+		client, _ := newCohereClient(config)
+		payload := client.buildPayload(request)
+		if payload["preamble"] != "You are terse.\n\nAnswer in French." {
+			t.Errorf("preamble = %q", payload["preamble"])
+		}
+		if payload["message"] != "Hello" || payload["chat_history"] != nil {
+			t.Errorf("Unexpected payload %v", payload)
+		}
+	})
+
+	t.Run("without system prompt", func(t *testing.T) {
+		client, _ := newOpenAIClient(config)
+		payload := client.buildPayload(BuildSimpleRequest("Hello"))
+		want := []chatMessage{{Role: "user", Content: "Hello"}}
+		if !reflect.DeepEqual(payload.Messages, want) {
+			t.Errorf("messages = %v", payload.Messages)
+		}
+	})
+}
+
+func TestSystemMessagePolicy(t *testing.T) {
+	history := ChatHistory{}
+	history.AddSystemMessage("From history.")
+	history.AddUserMessage("Hi")
+	history.AddAssistantMessage("Hello")
+
+	tests := []struct {
+		name    string
+		policy  SystemMessagePolicy
+		want    []string
+		wantErr error
+	}{
+		{name: "keep", policy: SystemMessagesKeep, want: []string{"system:Prompt.", "system:From history.", "user:Hi", "assistant:Hello", "user:Next"}},
+		{name: "merge", policy: SystemMessagesMerge, want: []string{"system:Prompt.\nFrom history.", "user:Hi", "assistant:Hello", "user:Next"}},
+		{name: "replace", policy: SystemMessagesReplace, want: []string{"system:Prompt.", "user:Hi", "assistant:Hello", "user:Next"}},
+		{name: "error", policy: SystemMessagesError, wantErr: ErrMultipleSystemMessages},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			client := &openAIClient{config: Config{Provider: ProviderOpenAI, SystemMessagePolicy: tt.policy}}
+			call := func(ctx context.Context, request Request) (*Response, error) {
+				for _, msg := range client.buildPayload(request).Messages {
+					sent = append(sent, msg.Role+":"+msg.Content.(string))
+				}
+				return &Response{}, nil
+			}
+
+			request := BuildChatRequest(history.GetMessages(), "Next")
+			request.AddSystemMessage("Prompt.")
+			_, err := instrumentGenerate(context.Background(), client.config, client.getModel, request, call)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(sent, tt.want) {
+				t.Errorf("Sent %q, want %q", sent, tt.want)
+			}
+		})
+	}
+
+	t.Run("single system message is untouched", func(t *testing.T) {
+		request := Request{SystemPrompt: "Only.", Messages: []Message{{Role: RoleUser, Content: "Hi"}}}
+		if err := applySystemPolicy(Config{SystemMessagePolicy: SystemMessagesError}, &request); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if request.SystemPrompt != "" || len(request.Messages) != 2 || request.Messages[0].Content != "Only." {
+			t.Errorf("Expected SystemPrompt folded into messages, got %+v", request)
+		}
+	})
+}
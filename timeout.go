@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinel errors for calls that did not complete in time, matched with errors.Is
+var (
+	// ErrCancelled matches calls whose context was cancelled by the caller.
+	// Retrying them is pointless.
+	ErrCancelled = errors.New("call cancelled")
+	// ErrDeadline matches every TimeoutError; its Source tells which deadline applied
+	ErrDeadline = errors.New("deadline exceeded")
+	// ErrProviderTimeout matches APIErrors where the provider or a gateway in
+	// front of it timed out (408, 504, 524, or a 5xx timeout body); these are
+	// usually safe to retry
+	ErrProviderTimeout = errors.New("provider timed out")
+)
+
+// TimeoutSource identifies which limit ended a call
+type TimeoutSource string
+
+const (
+	// TimeoutRequest is Request.Timeout
+	TimeoutRequest TimeoutSource = "request"
+	// TimeoutClient is Config.Timeout
+	TimeoutClient TimeoutSource = "client"
+	// TimeoutModel is an entry of Config.ModelTimeouts
+	TimeoutModel TimeoutSource = "model"
+	// TimeoutCaller is a deadline on the context passed by the caller
+	TimeoutCaller TimeoutSource = "caller"
+)
+
+// TimeoutError is returned when a call runs out of time. It unwraps to the
+// underlying error, so errors.Is(err, context.DeadlineExceeded) still holds.
+type TimeoutError struct {
+	Source TimeoutSource
+	// Timeout is the configured limit (0 for TimeoutCaller)
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Source == TimeoutCaller {
+		return fmt.Sprintf("caller's context deadline exceeded: %v", e.Err)
+	}
+	return fmt.Sprintf("%s timeout of %v exceeded: %v", e.Source, e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrDeadline) match
+func (e *TimeoutError) Is(target error) bool {
+	return target == ErrDeadline
+}
+
+// isProviderTimeout reports whether a provider response means it timed out
+func isProviderTimeout(statusCode int, body string) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout, 524: // 524: Cloudflare origin timeout
+		return true
+	}
+	if statusCode < 500 {
+		return false
+	}
+	body = strings.ToLower(body)
+	return strings.Contains(body, "timeout") || strings.Contains(body, "timed out")
+}
+
+// modelTimeout returns the Config.ModelTimeouts entry of model, from an
+// exact match or the longest listed prefix of a dated snapshot
+func modelTimeout(config Config, model string) (time.Duration, bool) {
+	if model == "" {
+		return 0, false
+	}
+	if timeout, ok := config.ModelTimeouts[model]; ok && timeout > 0 {
+		return timeout, true
+	}
+	best := ""
+	for name, timeout := range config.ModelTimeouts {
+		if timeout > 0 && strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return 0, false
+	}
+	return config.ModelTimeouts[best], true
+}
+
+// callTimeout returns the limit that bounds a call to model (empty for
+// calls without one) and where it comes from. A request timeout replaces
+// the client timeout, and wins over a model timeout only if it is shorter.
+func callTimeout(config Config, model string, requestTimeout time.Duration) (time.Duration, TimeoutSource) {
+	limit, ok := modelTimeout(config, model)
+	switch {
+	case requestTimeout > 0 && (!ok || requestTimeout <= limit):
+		return requestTimeout, TimeoutRequest
+	case ok:
+		return limit, TimeoutModel
+	}
+	return config.Timeout, TimeoutClient
+}
+
+// withTimeout runs call under the request, model or client timeout, derived
+// from ctx so the shared http.Client is never modified. A deadline is
+// reported as a TimeoutError naming the limit that fired (TimeoutCaller
+// when ctx's own deadline came first), and a cancelled ctx as ErrCancelled.
+func withTimeout[T any](ctx context.Context, config Config, model string, requestTimeout time.Duration, call func(ctx context.Context) (T, error)) (T, error) {
+	timeout, source := callTimeout(config, model, requestTimeout)
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := call(callCtx)
+	if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+		return result, fmt.Errorf("%w: %w", ErrCancelled, err)
+	}
+	if err != nil && errors.Is(callCtx.Err(), context.DeadlineExceeded) {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return result, &TimeoutError{Source: TimeoutCaller, Err: err}
+		}
+		return result, &TimeoutError{Source: source, Timeout: timeout, Err: err}
+	}
+	return result, err
+}
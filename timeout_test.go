@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTimeoutSources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"slow"}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  server.URL,
+		Timeout:  20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	check := func(t *testing.T, err error, source TimeoutSource) {
+		t.Helper()
+		var timeoutErr *TimeoutError
+		if !errors.As(err, &timeoutErr) || timeoutErr.Source != source {
+			t.Fatalf("Expected a %s TimeoutError, got %v", source, err)
+		}
+		if !errors.Is(err, context.DeadlineExceeded) || ErrorClass(err) != StatusTimeout {
+			t.Errorf("TimeoutError should unwrap to context.DeadlineExceeded, got %v", err)
+		}
+	}
+
+	t.Run("client default", func(t *testing.T) {
+		_, err := GenerateSimple(context.Background(), client, "hi")
+		check(t, err, TimeoutClient)
+	})
+
+	t.Run("request override", func(t *testing.T) {
+		request := NewRequest(WithUser("hi"), WithTimeout(5*time.Millisecond))
+		_, err := client.Generate(context.Background(), request)
+		check(t, err, TimeoutRequest)
+	})
+
+	t.Run("request raises the limit", func(t *testing.T) {
+		request := NewRequest(WithUser("hi"), WithTimeout(2*time.Second))
+		if _, err := client.Generate(context.Background(), request); err != nil {
+			t.Errorf("Expected Request.Timeout to outlast Config.Timeout, got %v", err)
+		}
+	})
+
+	t.Run("caller context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		_, err := GenerateSimple(ctx, client, "hi")
+		check(t, err, TimeoutCaller)
+	})
+}
+
+func TestModelTimeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(100 * time.Millisecond):
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"slow"}}]}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{
+		Provider:              ProviderOpenAI,
+		APIKey:                "test-key",
+		BaseURL:               server.URL,
+		DisableBaseURLVersion: true,
+		Timeout:               20 * time.Millisecond,
+		ModelTimeouts:         map[string]time.Duration{"reasoner": 2 * time.Second, "chat": 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	check := func(t *testing.T, err error, source TimeoutSource, timeout time.Duration) {
+		t.Helper()
+		var timeoutErr *TimeoutError
+		if !errors.As(err, &timeoutErr) || timeoutErr.Source != source || timeoutErr.Timeout != timeout {
+			t.Fatalf("Expected a %s TimeoutError of %v, got %v", source, timeout, err)
+		}
+	}
+
+	t.Run("model raises the client limit", func(t *testing.T) {
+		// Dated snapshots use the entry of their prefix
+		request := NewRequest(WithUser("hi"), WithModel("reasoner-2025-01-31"))
+		if _, err := client.Generate(context.Background(), request); err != nil {
+			t.Errorf("Expected the model timeout to outlast Config.Timeout, got %v", err)
+		}
+	})
+
+	t.Run("model lowers the client limit", func(t *testing.T) {
+		_, err := client.Generate(context.Background(), NewRequest(WithUser("hi"), WithModel("chat")))
+		check(t, err, TimeoutModel, 10*time.Millisecond)
+	})
+
+	t.Run("tighter request wins", func(t *testing.T) {
+		request := NewRequest(WithUser("hi"), WithModel("reasoner"), WithTimeout(5*time.Millisecond))
+		_, err := client.Generate(context.Background(), request)
+		check(t, err, TimeoutRequest, 5*time.Millisecond)
+	})
+
+	t.Run("request cannot extend a model limit", func(t *testing.T) {
+		request := NewRequest(WithUser("hi"), WithModel("chat"), WithTimeout(5*time.Second))
+		_, err := client.Generate(context.Background(), request)
+		check(t, err, TimeoutModel, 10*time.Millisecond)
+	})
+
+	t.Run("tighter caller wins", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+		defer cancel()
+		_, err := client.Generate(ctx, NewRequest(WithUser("hi"), WithModel("reasoner")))
+		check(t, err, TimeoutCaller, 0)
+	})
+
+	t.Run("first byte of a stream", func(t *testing.T) {
+		_, err := GenerateStream(context.Background(), client, NewRequest(WithUser("hi"), WithModel("chat")))
+		check(t, err, TimeoutModel, 10*time.Millisecond)
+	})
+}
+
+func TestCancellationVersusTimeout(t *testing.T) {
+	var mu sync.Mutex
+	status, body := 0, ""
+	reply := func(newStatus int, newBody string) {
+		mu.Lock()
+		defer mu.Unlock()
+		status, body = newStatus, newBody
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status, body := status, body
+		mu.Unlock()
+		if status != 0 {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+			return
+		}
+		// Hang until the client gives up; the server only notices the
+		// disconnect once the body has been read
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	configs := []Config{
+		{Provider: ProviderOpenAI, BaseURL: server.URL},
+		{Provider: ProviderQwen, BaseURL: server.URL},
+		{Provider: ProviderAzure, BaseURL: server.URL + "/openai/deployments/chat"},
+		{Provider: ProviderCohere, BaseURL: server.URL},
+	}
+	for _, config := range configs {
+		config.APIKey = "test-key"
+		config.Timeout = 50 * time.Millisecond
+		client, err := NewClient(config)
+		if err != nil {
+			t.Fatalf("Failed to create %s client: %v", config.Provider, err)
+		}
+
+		t.Run(string(config.Provider), func(t *testing.T) {
+			reply(0, "")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(10*time.Millisecond, cancel)
+			_, err := GenerateSimple(ctx, client, "hi")
+			if !errors.Is(err, ErrCancelled) || !errors.Is(err, context.Canceled) || errors.Is(err, ErrDeadline) {
+				t.Errorf("Expected ErrCancelled, got %v", err)
+			}
+			if ErrorClass(err) != StatusCanceled {
+				t.Errorf("Expected class %s, got %s", StatusCanceled, ErrorClass(err))
+			}
+
+			_, err = GenerateSimple(context.Background(), client, "hi")
+			if !errors.Is(err, ErrDeadline) || errors.Is(err, ErrCancelled) || errors.Is(err, ErrProviderTimeout) {
+				t.Errorf("Expected ErrDeadline, got %v", err)
+			}
+
+			for _, tt := range []struct {
+				status  int
+				body    string
+				timeout bool
+			}{
+				{status: http.StatusRequestTimeout, timeout: true},
+				{status: http.StatusGatewayTimeout, timeout: true},
+				{status: http.StatusInternalServerError, body: `{"error":"upstream request timed out"}`, timeout: true},
+				{status: http.StatusInternalServerError, body: `{"error":"boom"}`},
+				{status: http.StatusBadRequest, body: `{"error":"timeout must be positive"}`},
+			} {
+				reply(tt.status, tt.body)
+				_, err = GenerateSimple(context.Background(), client, "hi")
+				if errors.Is(err, ErrProviderTimeout) != tt.timeout || errors.Is(err, ErrDeadline) {
+					t.Errorf("%d %s: errors.Is(ErrProviderTimeout) = %v, want %v", tt.status, tt.body, !tt.timeout, tt.timeout)
+				}
+				if tt.timeout && ErrorClass(err) != StatusProviderTimeout {
+					t.Errorf("%d: expected class %s, got %s", tt.status, StatusProviderTimeout, ErrorClass(err))
+				}
+			}
+		})
+	}
+}
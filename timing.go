@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing breaks down the latency of a chat call, see Config.CaptureTiming.
+// When a call sends several HTTP requests (retries, continuations, stream
+// resumes), the phases are those of the last request while Total and
+// TimeToFirstToken cover the whole call.
+type Timing struct {
+	// DNS, Connect and TLS are the DNS lookup, TCP connect and TLS handshake
+	// durations, zero when a pooled connection was reused
+	DNS     time.Duration `json:"dns"`
+	Connect time.Duration `json:"connect"`
+	TLS     time.Duration `json:"tls"`
+	// ConnectionReused reports whether a pooled connection was used
+	ConnectionReused bool `json:"connection_reused"`
+	// Wait is from the request being written to the first response byte:
+	// provider queueing plus, unless streaming, the whole generation
+	Wait time.Duration `json:"wait"`
+	// TimeToFirstByte is from the start of the call to the first response byte
+	TimeToFirstByte time.Duration `json:"time_to_first_byte"`
+	// TimeToFirstToken is from the start of the call to the first content or
+	// reasoning delta of a stream, zero for other calls
+	TimeToFirstToken time.Duration `json:"time_to_first_token,omitempty"`
+	// Generation is from the first response byte to the end of the response
+	Generation time.Duration `json:"generation"`
+	// Total is the whole call, like Response.ResponseTime
+	Total time.Duration `json:"total"`
+}
+
+// timingTrace collects a Timing from httptrace callbacks, which may run on
+// other goroutines
+type timingTrace struct {
+	mu                                  sync.Mutex
+	start                               time.Time
+	dnsStart, connectStart, tlsStart    time.Time
+	wroteRequest, firstByte, firstToken time.Time
+	dns, connect, tlsHandshake          time.Duration
+	reused                              bool
+}
+
+// withTimingTrace returns ctx with the trace attached, both as an
+// httptrace.ClientTrace for the requests made with it and for
+// timingTraceFrom. Without Config.CaptureTiming ctx is returned unchanged
+// and the trace is nil.
+func withTimingTrace(ctx context.Context, config Config, start time.Time) (context.Context, *timingTrace) {
+	if !config.CaptureTiming {
+		return ctx, nil
+	}
+	trace := &timingTrace{start: start}
+	ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+	return context.WithValue(ctx, timingContextKey, trace), trace
+}
+
+// timingTraceFrom returns the trace attached by withTimingTrace, or nil
+func timingTraceFrom(ctx context.Context) *timingTrace {
+	trace, _ := ctx.Value(timingContextKey).(*timingTrace)
+	return trace
+}
+
+// clientTrace returns the httptrace callbacks filling t
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// a new request: forget the phases of the previous one
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.dnsStart, t.connectStart, t.tlsStart = time.Time{}, time.Time{}, time.Time{}
+			t.wroteRequest, t.firstByte = time.Time{}, time.Time{}
+			t.dns, t.connect, t.tlsHandshake, t.reused = 0, 0, 0, false
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mark(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.measure(&t.dns, &t.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			t.mark(&t.connectStart)
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.measure(&t.connect, &t.connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mark(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.measure(&t.tlsHandshake, &t.tlsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.reused = info.Reused
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mark(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.mark(&t.firstByte)
+		},
+	}
+}
+
+// mark records the current time in at, keeping the first of parallel
+// attempts such as dual-stack dials
+func (t *timingTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if at.IsZero() {
+		*at = time.Now()
+	}
+}
+
+// measure sets d to the time since start
+func (t *timingTrace) measure(d *time.Duration, start *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !start.IsZero() {
+		*d = time.Since(*start)
+	}
+}
+
+// markFirstToken records the arrival of the first stream delta of the call
+func (t *timingTrace) markFirstToken() {
+	t.mark(&t.firstToken)
+}
+
+// finish returns the Timing of a call that ended at end
+func (t *timingTrace) finish(end time.Time) *Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := &Timing{
+		DNS:              t.dns,
+		Connect:          t.connect,
+		TLS:              t.tlsHandshake,
+		ConnectionReused: t.reused,
+		Total:            end.Sub(t.start),
+	}
+	if !t.firstByte.IsZero() {
+		timing.TimeToFirstByte = t.firstByte.Sub(t.start)
+		timing.Generation = end.Sub(t.firstByte)
+		if !t.wroteRequest.IsZero() {
+			timing.Wait = t.firstByte.Sub(t.wroteRequest)
+		}
+	}
+	if !t.firstToken.IsZero() {
+		timing.TimeToFirstToken = t.firstToken.Sub(t.start)
+	}
+	return timing
+}
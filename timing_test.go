@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCaptureTiming(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}],"usage":{"total_tokens":3}}`))
+	}))
+	defer server.Close()
+
+	metrics := &recordingMetrics{}
+	client, _ := NewClient(Config{
+		Provider:      ProviderOpenAI,
+		APIKey:        "test-key",
+		BaseURL:       server.URL,
+		HTTPClient:    server.Client(),
+		Metrics:       metrics,
+		CaptureTiming: true,
+	})
+
+	first, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	timing := first.Timing
+	if timing == nil {
+		t.Fatal("Expected Timing to be set")
+	}
+	if timing.ConnectionReused || timing.Connect <= 0 || timing.TLS <= 0 {
+		t.Errorf("Expected a new connection with connect and TLS times, got %+v", timing)
+	}
+	if timing.Wait < delay || timing.TimeToFirstByte < timing.Wait || timing.Total < timing.TimeToFirstByte+timing.Generation {
+		t.Errorf("Inconsistent phases %+v", timing)
+	}
+	if timing.TimeToFirstToken != 0 {
+		t.Errorf("Expected no time to first token outside streaming, got %v", timing.TimeToFirstToken)
+	}
+	if len(metrics.observations) != 1 || metrics.observations[0].Timing != timing {
+		t.Errorf("Expected metrics to receive the response's timing, got %+v", metrics.observations)
+	}
+
+	second, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if !second.Timing.ConnectionReused || second.Timing.Connect != 0 || second.Timing.TLS != 0 {
+		t.Errorf("Expected a reused connection without connect and TLS times, got %+v", second.Timing)
+	}
+}
+
+func TestCaptureTimingDisabled(t *testing.T) {
+	server := newChatServer(t, nil)
+	metrics := &recordingMetrics{}
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, Metrics: metrics})
+
+	response, err := client.Generate(context.Background(), BuildSimpleRequest("Hi"))
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if response.Timing != nil || metrics.observations[0].Timing != nil {
+		t.Errorf("Expected no timing without CaptureTiming, got %+v", response.Timing)
+	}
+}
+
+func TestCaptureTimingStream(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte(": keep-alive\n\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(delay)
+		w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"},\"finish_reason\":\"stop\"}]}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	metrics := &recordingMetrics{}
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, Metrics: metrics, CaptureTiming: true})
+	response, err := GenerateStream(context.Background(), client, BuildSimpleRequest("Hi"))
+	if err != nil {
+		t.Fatalf("GenerateStream failed: %v", err)
+	}
+	_, _, final := drainStream(t, response)
+
+	timing := final.Timing
+	if timing == nil {
+		t.Fatal("Expected Timing on the final chunk")
+	}
+	if timing.TimeToFirstToken < timing.TimeToFirstByte+delay {
+		t.Errorf("Expected the first token after the first byte and the delay, got %+v", timing)
+	}
+	if timing.Generation < delay || timing.Total < timing.TimeToFirstToken {
+		t.Errorf("Inconsistent phases %+v", timing)
+	}
+
+	metrics.mu.Lock()
+	defer metrics.mu.Unlock()
+	if len(metrics.observations) != 1 || metrics.observations[0].Timing != timing {
+		t.Errorf("Expected metrics to receive the stream's timing, got %+v", metrics.observations)
+	}
+}
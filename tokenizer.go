@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Chat format overhead, following OpenAI's accounting for chat models: each
+// message costs a few tokens for its role/delimiters, a name costs one more,
+// and every reply is primed with a few tokens.
+const (
+	tokensPerMessage = 3
+	tokensPerName    = 1
+	tokensPerReply   = 3
+)
+
+// Tokenizer counts tokens for budgeting prompts before they are sent. The
+// library's truncation and budget helpers all count through this interface,
+// so plugging in an exact tokenizer makes every estimate consistent.
+type Tokenizer interface {
+	// CountTokens returns the number of tokens in text for model
+	CountTokens(model, text string) int
+
+	// CountMessages returns the number of prompt tokens for messages,
+	// including the per-message overhead of the chat format
+	CountMessages(model string, messages []Message) int
+}
+
+// TokenizerFunc adapts a plain text counter to Tokenizer, adding the chat
+// format overhead in CountMessages. It is the adapter point for exact
+// tokenizers such as tiktoken-go:
+//
+//	enc, _ := tiktoken.EncodingForModel("gpt-4o")
+//	config.Tokenizer = llm.TokenizerFunc(func(model, text string) int {
+//		return len(enc.Encode(text, nil, nil))
+//	})
+type TokenizerFunc func(model, text string) int
+
+// CountTokens calls f
+func (f TokenizerFunc) CountTokens(model, text string) int {
+	return f(model, text)
+}
+
+// CountMessages sums f over the messages plus the chat format overhead
+func (f TokenizerFunc) CountMessages(model string, messages []Message) int {
+	return countMessages(f, model, messages)
+}
+
+// HeuristicTokenizer estimates tokens without a vocabulary: about four
+// characters per token for ASCII text, two for other alphabetic scripts and
+// one per character for CJK. It is the default when Config.Tokenizer is nil
+// and is usually within 10-20% of the real count.
+type HeuristicTokenizer struct{}
+
+// CountTokens estimates the tokens in text
+func (HeuristicTokenizer) CountTokens(model, text string) int {
+	return estimateTokens(text)
+}
+
+// CountMessages estimates the prompt tokens for messages
+func (t HeuristicTokenizer) CountMessages(model string, messages []Message) int {
+	return countMessages(t.CountTokens, model, messages)
+}
+
+// estimateTokens implements the HeuristicTokenizer estimate
+func estimateTokens(text string) int {
+	var ascii, other, cjk int
+	for _, r := range text {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii++
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			cjk++
+		default:
+			other++
+		}
+	}
+	return (ascii+3)/4 + (other+1)/2 + cjk
+}
+
+// countMessages adds the chat format overhead to per-message token counts
+func countMessages(count func(model, text string) int, model string, messages []Message) int {
+	if len(messages) == 0 {
+		return 0
+	}
+	total := tokensPerReply
+	for _, msg := range messages {
+		total += tokensPerMessage + count(model, string(msg.Role)) + count(model, msg.Content)
+		if msg.Name != "" {
+			total += tokensPerName + count(model, msg.Name)
+		}
+		for _, call := range msg.ToolCalls {
+			total += count(model, call.Name) + count(model, call.Arguments)
+		}
+	}
+	return total
+}
+
+// tokenizerFor returns the configured tokenizer or the heuristic default
+func tokenizerFor(config Config) Tokenizer {
+	if config.Tokenizer != nil {
+		return config.Tokenizer
+	}
+	return HeuristicTokenizer{}
+}
+
+// countRequestTokens estimates the prompt tokens of request for model,
+// including the Config.DefaultSystemPrompt it would be sent with
+func countRequestTokens(config Config, model string, request Request) int {
+	return tokenizerFor(config).CountMessages(model, requestMessages(withConfigDefaults(config, request)))
+}
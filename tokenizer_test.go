@@ -0,0 +1,55 @@
+package llm
+
+import "testing"
+
+func TestHeuristicTokenizer(t *testing.T) {
+	tokenizer := HeuristicTokenizer{}
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "", want: 0},
+		{text: "Hello, world!", want: 4},
+		{text: "Привет мир", want: 6},
+		{text: "你好世界", want: 4},
+	}
+	for _, tt := range tests {
+		if got := tokenizer.CountTokens("gpt-4o", tt.text); got != tt.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+
+	messages := []Message{
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Hello, world!"},
+	}
+	// 3 reply + (3 overhead + 2 "system" + 3) + (3 overhead + 1 "user" + 4)
+	if got := tokenizer.CountMessages("gpt-4o", messages); got != 19 {
+		t.Errorf("CountMessages = %d, want 19", got)
+	}
+}
+
+func TestClientCountTokens(t *testing.T) {
+	var models []string
+	client, err := NewClient(Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		Tokenizer: TokenizerFunc(func(model, text string) int {
+			models = append(models, model)
+			return len(text)
+		}),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	request := BuildSimpleRequest("abcd")
+	request.SetModel("gpt-4o")
+	// 3 reply + 3 overhead + len("user") + len("abcd")
+	if got := client.CountTokens(request); got != 14 {
+		t.Errorf("CountTokens = %d, want 14", got)
+	}
+	if len(models) == 0 || models[0] != "gpt-4o" {
+		t.Errorf("Expected tokenizer to receive the request model, got %v", models)
+	}
+}
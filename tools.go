@@ -0,0 +1,138 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ToolCall is a function call requested by the model. Arguments is the
+// JSON argument object as sent by the provider.
+type ToolCall struct {
+	// ID links the call to its RoleTool result. Providers that do not
+	// number calls (Gemini, Cohere) get one generated from Name.
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// ToolCallDelta is a fragment of a tool call streamed by the model. The
+// fragments of one call share its Index, the position of the call in the
+// response; ID and Name arrive once, usually first, and Arguments are to be
+// appended in order. Providers that stream whole calls (Gemini) send one
+// fragment per call.
+type ToolCallDelta struct {
+	Index     int    `json:"index"`
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// toolCallAccumulator assembles streamed tool call fragments into calls
+type toolCallAccumulator struct {
+	calls []ToolCall
+}
+
+// add merges the fragments of a chunk
+func (a *toolCallAccumulator) add(deltas []ToolCallDelta) {
+	for _, delta := range deltas {
+		if delta.Index < 0 {
+			continue
+		}
+		for len(a.calls) <= delta.Index {
+			a.calls = append(a.calls, ToolCall{})
+		}
+		call := &a.calls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Name != "" {
+			call.Name = delta.Name
+		}
+		call.Arguments += delta.Arguments
+	}
+}
+
+// result returns the assembled calls, skipping indexes that were not calls
+func (a *toolCallAccumulator) result() []ToolCall {
+	var calls []ToolCall
+	for _, call := range a.calls {
+		if call.Name != "" {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// AddToolCalls adds an assistant message requesting calls, with optional text
+func (h *ChatHistory) AddToolCalls(content string, calls []ToolCall) {
+	h.append(Message{Role: RoleAssistant, Content: content, ToolCalls: calls})
+}
+
+// AddToolResult adds the result of the call with ID toolCallID
+func (h *ChatHistory) AddToolResult(toolCallID, content string) {
+	h.append(Message{Role: RoleTool, Content: content, ToolCallID: toolCallID})
+}
+
+// AddToolCalls adds an assistant message requesting calls to the request
+func (r *Request) AddToolCalls(content string, calls []ToolCall) {
+	r.Messages = append(r.Messages, Message{Role: RoleAssistant, Content: content, ToolCalls: calls})
+}
+
+// AddToolResult adds the result of the call with ID toolCallID to the request
+func (r *Request) AddToolResult(toolCallID, content string) {
+	r.Messages = append(r.Messages, Message{Role: RoleTool, Content: content, ToolCallID: toolCallID})
+}
+
+// arguments returns the arguments, or {} when the call has none
+func (c ToolCall) arguments() string {
+	if c.Arguments == "" {
+		return "{}"
+	}
+	return c.Arguments
+}
+
+// hasToolData reports whether msg requests or answers a tool call
+func hasToolData(msg Message) bool {
+	return len(msg.ToolCalls) > 0 || msg.ToolCallID != ""
+}
+
+// findToolCall returns the call with id requested by one of messages, for
+// providers that identify results by the call itself (Gemini, Cohere)
+func findToolCall(messages []Message, id string) (ToolCall, bool) {
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			if call.ID == id {
+				return call, true
+			}
+		}
+	}
+	return ToolCall{}, false
+}
+
+// generatedToolCallID returns the ID of the i-th call of a response from a
+// provider that does not number calls
+func generatedToolCallID(name string, i int) string {
+	return fmt.Sprintf("%s_%d", name, i)
+}
+
+// toolArguments returns the arguments of a call as a JSON object, for
+// providers that take them as one; missing or malformed arguments become {}
+func toolArguments(arguments string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if json.Unmarshal([]byte(arguments), &object) != nil || object == nil {
+		return json.RawMessage("{}")
+	}
+	return json.RawMessage(arguments)
+}
+
+// toolResultObject returns a tool result as a JSON object, for providers
+// that only accept objects: a JSON object is kept, anything else is wrapped
+// as {"content": content}
+func toolResultObject(content string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if json.Unmarshal([]byte(content), &object) == nil && object != nil {
+		return json.RawMessage(content)
+	}
+	wrapped, _ := json.Marshal(map[string]string{"content": content})
+	return wrapped
+}
@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// toolConversation is a user question answered with two parallel tool
+// calls, their results and a follow-up question
+func toolConversation() []Message {
+	var history ChatHistory
+	history.AddSystemMessage("Use the tools.")
+	history.AddUserMessage("Weather in Paris and Rome?")
+	history.AddToolCalls("", []ToolCall{
+		{ID: "call_1", Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		{ID: "call_2", Name: "get_weather", Arguments: `{"city":"Rome"}`},
+	})
+	history.AddToolResult("call_1", `{"temp_c":18}`)
+	history.AddToolResult("call_2", "sunny")
+	history.AddAssistantMessage("Paris is 18°C, Rome is sunny.")
+	history.AddUserMessage("Thanks")
+	return history.Messages
+}
+
+// assertJSON fails unless got encodes to the same JSON value as want
+func assertJSON(t *testing.T, got interface{}, want string) {
+	t.Helper()
+	data, err := json.Marshal(got)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var gotValue, wantValue interface{}
+	json.Unmarshal(data, &gotValue)
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("Invalid expected JSON: %v", err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("Expected %s, got %s", want, data)
+	}
+}
+
+func TestToolMessageSerialization(t *testing.T) {
+	request := Request{Messages: toolConversation()}
+	newClient := func(provider Provider, useResponses bool) Client {
+		client, err := NewClient(Config{Provider: provider, APIKey: "test-key", UseResponsesAPI: useResponses})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		return client
+	}
+
+	t.Run("openai", func(t *testing.T) {
+		payload := newClient(ProviderOpenAI, false).(*openAIClient).buildPayload(request)
+		assertJSON(t, payload.Messages, `[
+			{"role": "system", "content": "Use the tools."},
+			{"role": "user", "content": "Weather in Paris and Rome?"},
+			{"role": "assistant", "content": null, "tool_calls": [
+				{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}},
+				{"id": "call_2", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Rome\"}"}}
+			]},
+			{"role": "tool", "content": "{\"temp_c\":18}", "tool_call_id": "call_1"},
+			{"role": "tool", "content": "sunny", "tool_call_id": "call_2"},
+			{"role": "assistant", "content": "Paris is 18°C, Rome is sunny."},
+			{"role": "user", "content": "Thanks"}
+		]`)
+	})
+
+	t.Run("responses", func(t *testing.T) {
+		payload := newClient(ProviderOpenAI, true).(*openAIClient).buildResponsesPayload(request)
+		assertJSON(t, payload["input"], `[
+			{"role": "system", "content": "Use the tools."},
+			{"role": "user", "content": "Weather in Paris and Rome?"},
+			{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{\"city\":\"Paris\"}"},
+			{"type": "function_call", "call_id": "call_2", "name": "get_weather", "arguments": "{\"city\":\"Rome\"}"},
+			{"type": "function_call_output", "call_id": "call_1", "output": "{\"temp_c\":18}"},
+			{"type": "function_call_output", "call_id": "call_2", "output": "sunny"},
+			{"role": "assistant", "content": "Paris is 18°C, Rome is sunny."},
+			{"role": "user", "content": "Thanks"}
+		]`)
+	})
+
+	t.Run("gemini", func(t *testing.T) {
+		payload, err := newClient(ProviderGemini, false).(*geminiClient).buildPayload(context.Background(), request)
+		if err != nil {
+			t.Fatalf("buildPayload failed: %v", err)
+		}
+		// Parallel calls and their results are grouped into one turn each
+		assertJSON(t, payload["contents"], `[
+			{"role": "user", "parts": [{"text": "Weather in Paris and Rome?"}]},
+			{"role": "model", "parts": [
+				{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}},
+				{"functionCall": {"name": "get_weather", "args": {"city": "Rome"}}}
+			]},
+			{"role": "user", "parts": [
+				{"functionResponse": {"name": "get_weather", "response": {"temp_c": 18}}},
+				{"functionResponse": {"name": "get_weather", "response": {"content": "sunny"}}}
+			]},
+			{"role": "model", "parts": [{"text": "Paris is 18°C, Rome is sunny."}]},
+			{"role": "user", "parts": [{"text": "Thanks"}]}
+		]`)
+	})
+
+	t.Run("cohere", func(t *testing.T) {
+		payload := newClient(ProviderCohere, false).(*cohereClient).buildPayload(request)
+		if payload["message"] != "Thanks" {
+			t.Errorf("Expected the last user message as message, got %v", payload["message"])
+		}
+		assertJSON(t, payload["chat_history"], `[
+			{"role": "USER", "message": "Weather in Paris and Rome?"},
+			{"role": "CHATBOT", "message": "", "tool_calls": [
+				{"name": "get_weather", "parameters": {"city": "Paris"}},
+				{"name": "get_weather", "parameters": {"city": "Rome"}}
+			]},
+			{"role": "TOOL", "tool_results": [
+				{"call": {"name": "get_weather", "parameters": {"city": "Paris"}}, "outputs": [{"temp_c": 18}]},
+				{"call": {"name": "get_weather", "parameters": {"city": "Rome"}}, "outputs": [{"content": "sunny"}]}
+			]},
+			{"role": "CHATBOT", "message": "Paris is 18°C, Rome is sunny."}
+		]`)
+
+		// Results that end the conversation answer this request
+		pending := Request{Messages: toolConversation()[:5]}
+		payload = newClient(ProviderCohere, false).(*cohereClient).buildPayload(pending)
+		if payload["message"] != "" {
+			t.Errorf("Expected an empty message, got %v", payload["message"])
+		}
+		assertJSON(t, payload["tool_results"], `[
+			{"call": {"name": "get_weather", "parameters": {"city": "Paris"}}, "outputs": [{"temp_c": 18}]},
+			{"call": {"name": "get_weather", "parameters": {"city": "Rome"}}, "outputs": [{"content": "sunny"}]}
+		]`)
+	})
+}
+
+func TestToolHistory(t *testing.T) {
+	history := ChatHistory{Messages: toolConversation()}
+	data, err := json.Marshal(history)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	var decoded ChatHistory
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Messages, history.Messages) {
+		t.Errorf("Expected the messages to survive a round trip, got %+v", decoded.Messages)
+	}
+
+	clone := history.Clone()
+	clone.Messages[2].ToolCalls[0].Arguments = "{}"
+	if history.Messages[2].ToolCalls[0].Arguments == "{}" {
+		t.Error("Expected Clone to copy tool calls")
+	}
+
+	// Dropping the question drops the calls and results answering it
+	trimmed := history.Clone()
+	trimmed.Limits = HistoryLimits{MaxMessages: 6}
+	trimmed.enforceLimits()
+	if got := roles(trimmed.Messages); !reflect.DeepEqual(got, []string{"system:Use the tools.", "user:Thanks"}) {
+		t.Errorf("Expected no orphaned tool messages, got %v", got)
+	}
+
+	truncated := history.Clone()
+	truncated.Truncate(4)
+	if got := roles(truncated.Messages); !reflect.DeepEqual(got, []string{"system:Use the tools.", "assistant:Paris is 18°C, Rome is sunny.", "user:Thanks"}) {
+		t.Errorf("Expected Truncate to drop results without their call, got %v", got)
+	}
+}
@@ -0,0 +1,280 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transcription response formats
+const (
+	TranscriptionFormatJSON        = "json"
+	TranscriptionFormatText        = "text"
+	TranscriptionFormatVerboseJSON = "verbose_json"
+	TranscriptionFormatSRT         = "srt"
+	TranscriptionFormatVTT         = "vtt"
+)
+
+// MaxTranscriptionBytes is the upload limit of the OpenAI transcription
+// endpoint; larger audio fails with an AudioTooLargeError before upload
+const MaxTranscriptionBytes = 25 << 20
+
+// ErrAudioTooLarge is matched by every AudioTooLargeError via errors.Is
+var ErrAudioTooLarge = errors.New("audio file too large")
+
+// AudioTooLargeError is returned when audio exceeds the provider's upload
+// limit, either before upload or from an HTTP 413 response
+type AudioTooLargeError struct {
+	// Size is the audio size in bytes (0 when the server rejected it)
+	Size  int64
+	Limit int64
+	// Err is the provider's APIError for a server-side rejection
+	Err error
+}
+
+func (e *AudioTooLargeError) Error() string {
+	if e.Size > 0 {
+		return fmt.Sprintf("audio file too large: %d bytes exceeds the %d byte limit", e.Size, e.Limit)
+	}
+	return fmt.Sprintf("audio file too large (limit %d bytes): %v", e.Limit, e.Err)
+}
+
+// Is makes errors.Is(err, ErrAudioTooLarge) match
+func (e *AudioTooLargeError) Is(target error) bool {
+	return target == ErrAudioTooLarge
+}
+
+// Unwrap returns the provider error, if any
+func (e *AudioTooLargeError) Unwrap() error {
+	return e.Err
+}
+
+// TranscriptionRequest asks a speech-to-text model to transcribe audio
+type TranscriptionRequest struct {
+	// Audio is read to the end and uploaded
+	Audio io.Reader `json:"-"`
+	// Filename tells the provider the audio format (e.g. "note.m4a")
+	Filename string `json:"filename"`
+
+	// Model override (optional, default whisper-1)
+	Model *string `json:"model,omitempty"`
+
+	// Language is the ISO-639-1 code of the audio (optional, improves accuracy)
+	Language string `json:"language,omitempty"`
+
+	// Prompt guides spelling and style, e.g. names and jargon (optional)
+	Prompt string `json:"prompt,omitempty"`
+
+	// ResponseFormat is one of the TranscriptionFormat constants (default
+	// json). verbose_json adds language, duration and segment timestamps;
+	// text, srt and vtt return Text verbatim.
+	ResponseFormat string `json:"response_format,omitempty"`
+
+	Temperature *float64 `json:"temperature,omitempty"`
+
+	// Extra HTTP headers for this request only. Applied after Config.Headers.
+	Headers map[string]string `json:"-"`
+}
+
+// TranscriptionSegment is a timed span of a verbose_json transcription
+type TranscriptionSegment struct {
+	ID    int           `json:"id"`
+	Start time.Duration `json:"start"`
+	End   time.Duration `json:"end"`
+	Text  string        `json:"text"`
+}
+
+// TranscriptionResponse holds the transcribed text
+type TranscriptionResponse struct {
+	Text string `json:"text"`
+
+	// Language, Duration and Segments are set for verbose_json
+	Language string                 `json:"language,omitempty"`
+	Duration time.Duration          `json:"duration,omitempty"`
+	Segments []TranscriptionSegment `json:"segments,omitempty"`
+
+	Model        string        `json:"model"`
+	ResponseTime time.Duration `json:"response_time"`
+
+	// RequestID is the X-Request-ID sent with the request (see WithRequestID)
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Transcriber is implemented by clients whose provider offers speech to
+// text; check Capabilities().Transcription or call Transcribe
+type Transcriber interface {
+	Transcribe(ctx context.Context, request TranscriptionRequest) (*TranscriptionResponse, error)
+}
+
+// Transcribe transcribes audio with client, failing with a CapabilityError
+// when its provider has no audio support
+func Transcribe(ctx context.Context, client Client, request TranscriptionRequest) (*TranscriptionResponse, error) {
+	transcriber, ok := client.(Transcriber)
+	if !ok || !client.Capabilities().Transcription {
+		return nil, &CapabilityError{Provider: client.GetConfig().Provider, Capability: CapabilityTranscription}
+	}
+	return transcriber.Transcribe(ctx, request)
+}
+
+// transcriptionFunc performs a single provider transcription call
+type transcriptionFunc func(ctx context.Context, request TranscriptionRequest) (*TranscriptionResponse, error)
+
+// instrumentTranscription runs a provider transcription call under the
+// client timeout and reports it like instrumentRerank does. Audio is billed
+// by duration, so no token usage is reported.
+func instrumentTranscription(ctx context.Context, config Config, model string, request TranscriptionRequest, call transcriptionFunc) (*TranscriptionResponse, error) {
+	if request.Audio == nil {
+		return nil, fmt.Errorf("transcription request has no audio")
+	}
+
+	startTime := time.Now()
+	response, err := withTimeout(ctx, config, "", 0, func(ctx context.Context) (*TranscriptionResponse, error) {
+		return call(ctx, request)
+	})
+	latency := time.Since(startTime)
+
+	observe(ctx, config, OperationTranscription, model, latency, Usage{}, err)
+	if config.Logger != nil && config.Logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("provider", string(config.Provider)),
+			slog.String("model", model),
+			slog.String("filename", request.Filename),
+			slog.Duration("latency", latency),
+		}
+		if response != nil {
+			attrs = append(attrs, slog.Duration("audio_duration", response.Duration))
+		}
+		logResult(ctx, config, "llm transcription", attrs, err)
+	}
+	return response, err
+}
+
+// newTranscriptionRequest builds the multipart upload of an OpenAI
+// /audio/transcriptions request. The audio is buffered so the request can
+// be dumped and its size checked against MaxTranscriptionBytes.
+func newTranscriptionRequest(ctx context.Context, url string, request TranscriptionRequest, model string) (*http.Request, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	filename := request.Filename
+	if filename == "" {
+		filename = "audio"
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(part, io.LimitReader(request.Audio, MaxTranscriptionBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audio: %w", err)
+	}
+	if size > MaxTranscriptionBytes {
+		// Count the rest only to report the size
+		rest, _ := io.Copy(io.Discard, request.Audio)
+		return nil, &AudioTooLargeError{Size: size + rest, Limit: MaxTranscriptionBytes}
+	}
+
+	fields := map[string]string{
+		"model":           model,
+		"language":        request.Language,
+		"prompt":          request.Prompt,
+		"response_format": request.ResponseFormat,
+	}
+	if request.Temperature != nil {
+		fields["temperature"] = strconv.FormatFloat(*request.Temperature, 'f', -1, 64)
+	}
+	if request.ResponseFormat == TranscriptionFormatVerboseJSON {
+		fields["timestamp_granularities[]"] = "segment"
+	}
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := writer.WriteField(name, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept-Encoding", "gzip")
+	return req, nil
+}
+
+// transcriptionError maps an unsuccessful transcription response, turning
+// 413 into an AudioTooLargeError
+func transcriptionError(config Config, prefix string, resp *http.Response, body []byte) error {
+	apiErr := newAPIError(config, prefix, resp, body)
+	if resp.StatusCode == http.StatusRequestEntityTooLarge {
+		return &AudioTooLargeError{Limit: MaxTranscriptionBytes, Err: apiErr}
+	}
+	return apiErr
+}
+
+// parseTranscription parses an /audio/transcriptions response in the
+// requested format
+func parseTranscription(body []byte, format string, model string) (*TranscriptionResponse, error) {
+	switch format {
+	case TranscriptionFormatText, TranscriptionFormatSRT, TranscriptionFormatVTT:
+		return &TranscriptionResponse{Text: strings.TrimSuffix(string(body), "\n"), Model: model}, nil
+	}
+
+	var apiResp struct {
+		Text     string  `json:"text"`
+		Language string  `json:"language"`
+		Duration float64 `json:"duration"`
+		Segments []struct {
+			ID    int     `json:"id"`
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+			Text  string  `json:"text"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal transcription response: %w", err)
+	}
+
+	response := &TranscriptionResponse{
+		Text:     apiResp.Text,
+		Language: apiResp.Language,
+		Duration: seconds(apiResp.Duration),
+		Model:    model,
+	}
+	for _, segment := range apiResp.Segments {
+		response.Segments = append(response.Segments, TranscriptionSegment{
+			ID:    segment.ID,
+			Start: seconds(segment.Start),
+			End:   seconds(segment.End),
+			Text:  segment.Text,
+		})
+	}
+	return response, nil
+}
+
+// seconds converts fractional seconds to a Duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// getTranscriptionModel returns the transcription model to use for the request
+func getTranscriptionModel(override *string) string {
+	if override != nil {
+		return *override
+	}
+	return "whisper-1"
+}
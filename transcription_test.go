@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscribe(t *testing.T) {
+	var fields map[string]string
+	var audio []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fields = map[string]string{}
+		for name, values := range r.MultipartForm.Value {
+			fields[name] = values[0]
+		}
+		file, header, _ := r.FormFile("file")
+		audio, _ = io.ReadAll(file)
+		fields["filename"] = header.Filename
+
+		switch r.FormValue("response_format") {
+		case "text":
+			w.Write([]byte("hello world\n"))
+		case "verbose_json":
+			w.Write([]byte(`{"text":"hello world","language":"english","duration":2.5,
+				"segments":[{"id":0,"start":0,"end":1.25,"text":"hello"},{"id":1,"start":1.25,"end":2.5,"text":" world"}]}`))
+		default:
+			w.Write([]byte(`{"text":"hello world"}`))
+		}
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+	resp, err := Transcribe(ctx, client, TranscriptionRequest{Audio: strings.NewReader("RIFF"), Filename: "note.wav", Language: "en"})
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+	if resp.Text != "hello world" || resp.Model != "whisper-1" {
+		t.Errorf("Unexpected response %+v", resp)
+	}
+	if string(audio) != "RIFF" || fields["filename"] != "note.wav" || fields["model"] != "whisper-1" || fields["language"] != "en" {
+		t.Errorf("Unexpected upload %q with fields %v", audio, fields)
+	}
+
+	resp, err = Transcribe(ctx, client, TranscriptionRequest{Audio: strings.NewReader("RIFF"), Filename: "note.wav", ResponseFormat: TranscriptionFormatVerboseJSON})
+	if err != nil {
+		t.Fatalf("Transcribe failed: %v", err)
+	}
+	if fields["timestamp_granularities[]"] != "segment" {
+		t.Errorf("Expected segment timestamps to be requested, got %v", fields)
+	}
+	if resp.Language != "english" || resp.Duration != 2500*time.Millisecond || len(resp.Segments) != 2 ||
+		resp.Segments[1].Start != 1250*time.Millisecond || resp.Segments[1].Text != " world" {
+		t.Errorf("Unexpected verbose response %+v", resp)
+	}
+
+	resp, err = Transcribe(ctx, client, TranscriptionRequest{Audio: strings.NewReader("RIFF"), ResponseFormat: TranscriptionFormatText})
+	if err != nil || resp.Text != "hello world" {
+		t.Errorf("Expected plain text transcription, got %+v, %v", resp, err)
+	}
+
+	cohere, _ := NewClient(Config{Provider: ProviderCohere, APIKey: "test-key", BaseURL: server.URL})
+	if _, err := Transcribe(ctx, cohere, TranscriptionRequest{Audio: strings.NewReader("RIFF")}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestTranscribeTooLarge(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		w.Write([]byte(`{"error":{"message":"Maximum content size limit exceeded"}}`))
+	}))
+	defer server.Close()
+	ctx := context.Background()
+	client, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL})
+
+	_, err := Transcribe(ctx, client, TranscriptionRequest{Audio: bytes.NewReader(make([]byte, MaxTranscriptionBytes+10))})
+	var tooLarge *AudioTooLargeError
+	if !errors.Is(err, ErrAudioTooLarge) || !errors.As(err, &tooLarge) || tooLarge.Size != MaxTranscriptionBytes+10 {
+		t.Errorf("Expected AudioTooLargeError with size, got %v", err)
+	}
+	if requests != 0 {
+		t.Error("Oversized audio should not be uploaded")
+	}
+
+	_, err = Transcribe(ctx, client, TranscriptionRequest{Audio: strings.NewReader("RIFF")})
+	var apiErr *APIError
+	if !errors.Is(err, ErrAudioTooLarge) || !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 mapped to AudioTooLargeError, got %v", err)
+	}
+	if ErrorClass(err) != StatusClientError {
+		t.Errorf("Expected class %s, got %s", StatusClientError, ErrorClass(err))
+	}
+}
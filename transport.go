@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// unixSocketHost is the placeholder host used in request URLs when the
+// connection itself goes to a unix socket.
+const unixSocketHost = "unix"
+
+// sharedTransport is used by every client that has no transport-specific
+// settings, so short-lived clients reuse the same keep-alive connection pool.
+var sharedTransport = newTransport()
+
+// newTransport returns a transport tuned for many concurrent requests to a
+// small number of provider hosts.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 256
+	transport.MaxIdleConnsPerHost = 64
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.TLSHandshakeTimeout = 10 * time.Second
+	transport.ForceAttemptHTTP2 = true
+	return transport
+}
+
+// newHTTPClient builds the http.Client used by a provider client from the
+// transport-related Config fields. Config.HTTPClient is used as-is when set.
+// Config.Timeout is applied per call through the context (see withTimeout),
+// so Request.Timeout can raise or lower it.
+func newHTTPClient(config Config) (*http.Client, error) {
+	if config.HTTPClient != nil {
+		return config.HTTPClient, nil
+	}
+
+	if !needsDedicatedTransport(config) {
+		return &http.Client{Transport: sharedTransport}, nil
+	}
+
+	transport := newTransport()
+
+	switch {
+	case config.DisableProxy:
+		// Ignore HTTP_PROXY/HTTPS_PROXY entirely
+		transport.Proxy = nil
+	case config.ProxyURL != "":
+		proxyURL, err := parseProxyURL(config.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		// Credentials in the URL are sent as Proxy-Authorization by the transport
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if socketPath, ok := unixSocketPath(config.BaseURL); ok {
+		if socketPath == "" {
+			return nil, fmt.Errorf("unix socket base URL must include a socket path: %s", config.BaseURL)
+		}
+		// Every connection goes to the socket regardless of the placeholder host
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "unix", socketPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to unix socket %s: %w", socketPath, err)
+			}
+			return conn, nil
+		}
+	}
+
+	if config.TLS != nil {
+		tlsConfig, err := buildTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
+
+// needsDedicatedTransport reports whether config changes how connections are
+// established, which rules out the shared connection pool.
+func needsDedicatedTransport(config Config) bool {
+	_, unix := unixSocketPath(config.BaseURL)
+	return config.ProxyURL != "" || config.DisableProxy || config.TLS != nil || unix
+}
+
+// unixSocketPath reports whether baseURL addresses a unix socket and returns its path
+func unixSocketPath(baseURL string) (string, bool) {
+	if !strings.HasPrefix(baseURL, "unix:") {
+		return "", false
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return "", true
+	}
+	return parsed.Host + parsed.Path, true
+}
+
+// endpointURL joins the configured base URL with an API path, mapping unix
+// socket base URLs onto the placeholder host.
+func endpointURL(config Config, path string) string {
+	if _, ok := unixSocketPath(config.BaseURL); ok {
+		return "http://" + unixSocketHost + strings.TrimSuffix(config.UnixSocketPathPrefix, "/") + path
+	}
+	return config.BaseURL + path
+}
+
+// normalizeBaseURL trims trailing slashes from Config.BaseURL and appends
+// version (such as "/v1", empty for providers without one) to a URL that
+// has no path, unless Config.DisableBaseURLVersion is set. It fails if the
+// URL does not parse. Unix socket URLs are left alone.
+func normalizeBaseURL(config Config, version string) (Config, error) {
+	if _, ok := unixSocketPath(config.BaseURL); ok {
+		return config, nil
+	}
+	baseURL := strings.TrimRight(config.BaseURL, "/")
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return config, fmt.Errorf("invalid base URL %q: %w", config.BaseURL, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return config, fmt.Errorf("invalid base URL %q: expected http(s)://host[/path]", config.BaseURL)
+	}
+	if parsed.Path == "" && !config.DisableBaseURLVersion {
+		baseURL += version
+	}
+	config.BaseURL = baseURL
+	return config, nil
+}
+
+// buildTLSConfig converts TLSConfig into a crypto/tls configuration
+func buildTLSConfig(cfg *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	caPEM := cfg.CACertPEM
+	if len(caPEM) == 0 && cfg.CACertFile != "" {
+		data, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		caPEM = data
+	}
+	if len(caPEM) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("CA bundle contains no valid PEM certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certPEM, keyPEM := cfg.ClientCertPEM, cfg.ClientKeyPEM
+	if len(certPEM) == 0 && cfg.ClientCertFile != "" {
+		data, err := os.ReadFile(cfg.ClientCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client certificate: %w", err)
+		}
+		certPEM = data
+	}
+	if len(keyPEM) == 0 && cfg.ClientKeyFile != "" {
+		data, err := os.ReadFile(cfg.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client key: %w", err)
+		}
+		keyPEM = data
+	}
+	if len(certPEM) > 0 || len(keyPEM) > 0 {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate/key pair: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// parseProxyURL validates a proxy URL without echoing credentials in errors
+func parseProxyURL(raw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	if proxyURL.Scheme == "" || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL: scheme and host are required")
+	}
+	return proxyURL, nil
+}
+
+// redactProxyURL returns the proxy URL with its password masked
+func redactProxyURL(raw string) string {
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid>"
+	}
+	return proxyURL.Redacted()
+}
+
+// sendRequest executes req and maps proxy and TLS failures to typed errors
+func sendRequest(httpClient *http.Client, config Config, req *http.Request) (*http.Response, error) {
+	dumpRequest(config, req)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, classifyTransportError(config, req, err)
+	}
+	if config.ProxyURL != "" && !config.DisableProxy {
+		if proxyErr := classifyProxyResponse(config, resp); proxyErr != nil {
+			resp.Body.Close()
+			return nil, proxyErr
+		}
+	}
+	return resp, nil
+}
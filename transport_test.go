@@ -0,0 +1,401 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProxyURL(t *testing.T) {
+	var proxied *http.Request
+	proxy := newChatServer(t, func(r *http.Request) {
+		proxied = r.Clone(context.Background())
+	})
+
+	client, err := NewClient(Config{
+		Provider: ProviderOpenAI,
+		APIKey:   "test-key",
+		BaseURL:  "http://llm.invalid/v1",
+		ProxyURL: strings.Replace(proxy.URL, "http://", "http://user:secret@", 1),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := GenerateSimple(context.Background(), client, "Hello"); err != nil {
+		t.Fatalf("Generate through proxy failed: %v", err)
+	}
+
+	if proxied.URL.Host != "llm.invalid" {
+		t.Errorf("Expected proxied request for llm.invalid, got %q", proxied.URL.Host)
+	}
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:secret"))
+	if proxied.Header.Get("Proxy-Authorization") != wantAuth {
+		t.Errorf("Expected proxy credentials, got %q", proxied.Header.Get("Proxy-Authorization"))
+	}
+}
+
+func TestProxyErrors(t *testing.T) {
+	t.Run("unreachable proxy", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := listener.Addr().String()
+		listener.Close()
+
+		client, err := NewClient(Config{
+			Provider: ProviderOpenAI,
+			APIKey:   "test-key",
+			BaseURL:  "https://llm.invalid/v1",
+			ProxyURL: "http://user:secret@" + addr,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		_, err = GenerateSimple(context.Background(), client, "Hello")
+		var proxyErr *ProxyError
+		if !errors.As(err, &proxyErr) {
+			t.Fatalf("Expected ProxyError, got %v", err)
+		}
+		if strings.Contains(err.Error(), "secret") {
+			t.Errorf("Proxy password leaked into error: %v", err)
+		}
+	})
+
+	t.Run("proxy auth required", func(t *testing.T) {
+		proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+		}))
+		defer proxy.Close()
+
+		client, err := NewClient(Config{
+			Provider: ProviderOpenAI,
+			APIKey:   "test-key",
+			BaseURL:  "http://llm.invalid/v1",
+			ProxyURL: proxy.URL,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+
+		_, err = GenerateSimple(context.Background(), client, "Hello")
+		var proxyErr *ProxyError
+		if !errors.As(err, &proxyErr) || proxyErr.StatusCode != http.StatusProxyAuthRequired {
+			t.Fatalf("Expected 407 ProxyError, got %v", err)
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			t.Error("Proxy failure must not be reported as APIError")
+		}
+	})
+
+	t.Run("invalid proxy URL", func(t *testing.T) {
+		_, err := NewClient(Config{
+			Provider: ProviderOpenAI,
+			APIKey:   "test-key",
+			ProxyURL: "proxy.internal:3128",
+		})
+		if err == nil {
+			t.Fatal("Expected error for proxy URL without scheme")
+		}
+	})
+}
+
+func TestDisableProxy(t *testing.T) {
+	httpClient, err := newHTTPClient(Config{DisableProxy: true, ProxyURL: "http://proxy.internal:3128"})
+	if err != nil {
+		t.Fatalf("Failed to build HTTP client: %v", err)
+	}
+	if httpClient.Transport.(*http.Transport).Proxy != nil {
+		t.Error("Expected proxy to be disabled")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"rate limited"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(Config{Provider: ProviderQwen, APIKey: "test-key", BaseURL: server.URL})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	_, err = GenerateSimple(context.Background(), client, "Hello")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Provider != ProviderQwen {
+		t.Errorf("Unexpected APIError fields: %+v", apiErr)
+	}
+	if err.Error() != `Qwen API error 429: {"error":"rate limited"}` {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	tests := []struct {
+		name    string
+		tls     *TLSConfig
+		wantErr bool
+	}{
+		{name: "untrusted certificate", tls: nil, wantErr: true},
+		{name: "custom CA bundle", tls: &TLSConfig{CACertPEM: caPEM}},
+		{name: "insecure skip verify", tls: &TLSConfig{InsecureSkipVerify: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(Config{
+				Provider:     ProviderOpenAI,
+				APIKey:       "test-key",
+				BaseURL:      server.URL,
+				DisableProxy: true,
+				TLS:          tt.tls,
+			})
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			_, err = GenerateSimple(context.Background(), client, "Hello")
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+
+			var tlsErr *TLSError
+			if !errors.As(err, &tlsErr) {
+				t.Fatalf("Expected TLSError, got %v", err)
+			}
+			if tlsErr.Endpoint != strings.TrimPrefix(server.URL, "https://") {
+				t.Errorf("Expected endpoint in error, got %q", tlsErr.Endpoint)
+			}
+			if !strings.Contains(err.Error(), "x509") {
+				t.Errorf("Expected x509 failure in error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		_, err := NewClient(Config{
+			Provider: ProviderOpenAI,
+			APIKey:   "test-key",
+			TLS:      &TLSConfig{CACertPEM: []byte("not a certificate")},
+		})
+		if err == nil {
+			t.Fatal("Expected error for invalid CA bundle")
+		}
+	})
+}
+
+func TestUnixSocketBaseURL(t *testing.T) {
+	dir, err := os.MkdirTemp("", "llm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "llm.sock")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	var gotPath string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client, err := NewClient(Config{
+		Provider:             ProviderOpenAI,
+		APIKey:               "test-key",
+		BaseURL:              "unix://" + socketPath,
+		UnixSocketPathPrefix: "/v1",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	response, err := GenerateSimple(context.Background(), client, "Hello")
+	if err != nil {
+		t.Fatalf("Generate over unix socket failed: %v", err)
+	}
+	if response.Content != "ok" {
+		t.Errorf("Unexpected content %q", response.Content)
+	}
+	if gotPath != "/v1/chat/completions" {
+		t.Errorf("Expected /v1/chat/completions, got %q", gotPath)
+	}
+
+	t.Run("missing socket", func(t *testing.T) {
+		missing := filepath.Join(dir, "missing.sock")
+		client, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: "unix://" + missing})
+		if err != nil {
+			t.Fatalf("Failed to create client: %v", err)
+		}
+		_, err = GenerateSimple(context.Background(), client, "Hello")
+		if err == nil || !strings.Contains(err.Error(), missing) {
+			t.Errorf("Expected error naming %s, got %v", missing, err)
+		}
+	})
+}
+
+func TestSharedTransport(t *testing.T) {
+	first, err := newHTTPClient(Config{Timeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := newHTTPClient(Config{Timeout: 2 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Transport != second.Transport {
+		t.Error("Expected clients without transport settings to share a transport")
+	}
+
+	dedicated, err := newHTTPClient(Config{DisableProxy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dedicated.Transport == first.Transport {
+		t.Error("Expected a dedicated transport when proxy settings are present")
+	}
+
+	custom := &http.Client{}
+	overridden, err := newHTTPClient(Config{HTTPClient: custom, DisableProxy: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overridden != custom {
+		t.Error("Expected Config.HTTPClient to be used as-is")
+	}
+}
+
+// BenchmarkShortLivedClients creates a new client per request, the pattern used
+// by per-tenant code, and reports how many TCP connections the server accepted.
+func BenchmarkShortLivedClients(b *testing.B) {
+	run := func(b *testing.B, httpClient func() *http.Client) {
+		var conns atomic.Int64
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}]}`))
+		}))
+		server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		server.Start()
+		defer server.Close()
+
+		ctx := context.Background()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			client, err := NewClient(Config{
+				Provider:   ProviderOpenAI,
+				APIKey:     "test-key",
+				BaseURL:    server.URL,
+				HTTPClient: httpClient(),
+			})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := GenerateSimple(ctx, client, "Hello"); err != nil {
+				b.Fatal(err)
+			}
+			client.Close()
+		}
+		b.ReportMetric(float64(conns.Load())/float64(b.N), "conns/op")
+	}
+
+	b.Run("shared", func(b *testing.B) {
+		run(b, func() *http.Client { return nil })
+	})
+	b.Run("per-client", func(b *testing.B) {
+		run(b, func() *http.Client {
+			return &http.Client{Transport: http.DefaultTransport.(*http.Transport).Clone()}
+		})
+	})
+}
+
+func TestBaseURLNormalization(t *testing.T) {
+	azure := "https://res.openai.azure.com/openai/deployments/gpt-4o/"
+	tests := []struct {
+		name    string
+		config  Config
+		want    string
+		wantErr bool
+	}{
+		{name: "trailing slash", config: Config{Provider: ProviderOpenAI, BaseURL: "https://api.openai.com/v1/"}, want: "https://api.openai.com/v1"},
+		{name: "missing version", config: Config{Provider: ProviderOpenAI, BaseURL: "https://api.openai.com"}, want: "https://api.openai.com/v1"},
+		{name: "version disabled", config: Config{Provider: ProviderOpenAI, BaseURL: "https://gateway.example.com/", DisableBaseURLVersion: true}, want: "https://gateway.example.com"},
+		{name: "gateway path kept", config: Config{Provider: ProviderOpenAI, BaseURL: "https://gateway.example.com/openai//"}, want: "https://gateway.example.com/openai"},
+		{name: "deepseek has no version", config: Config{Provider: ProviderDeepSeek, BaseURL: "https://api.deepseek.com/"}, want: "https://api.deepseek.com"},
+		{name: "deepseek default", config: Config{Provider: ProviderDeepSeek}, want: "https://api.deepseek.com"},
+		{name: "cohere", config: Config{Provider: ProviderCohere, BaseURL: "https://api.cohere.ai"}, want: "https://api.cohere.ai/v1"},
+		{name: "gemini", config: Config{Provider: ProviderGemini, BaseURL: "https://generativelanguage.googleapis.com/v1beta/"}, want: "https://generativelanguage.googleapis.com/v1beta"},
+		{name: "azure untouched", config: Config{Provider: ProviderAzure, BaseURL: azure}, want: azure},
+		{name: "unix socket untouched", config: Config{Provider: ProviderOpenAI, BaseURL: "unix:///var/run/llm.sock"}, want: "unix:///var/run/llm.sock"},
+		{name: "no scheme", config: Config{Provider: ProviderOpenAI, BaseURL: "api.openai.com/v1"}, wantErr: true},
+		{name: "unparsable", config: Config{Provider: ProviderOpenAI, BaseURL: "https://api.openai.com:port/v1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.APIKey = "test-key"
+			client, err := NewClient(tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got base URL %q", client.GetConfig().BaseURL)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			if got := client.GetConfig().BaseURL; got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+
+	var path string
+	server := newChatServer(t, func(r *http.Request) { path = r.URL.Path })
+	client, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL + "/v1/"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := GenerateSimple(context.Background(), client, "Hello"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if path != "/v1/chat/completions" {
+		t.Errorf("Expected /v1/chat/completions, got %s", path)
+	}
+}
@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// UsageEvent describes one successful call, as passed to Config.OnUsage
+type UsageEvent struct {
+	Provider Provider `json:"provider"`
+	// Model is the one reported by the provider when available
+	Model     string        `json:"model"`
+	Operation string        `json:"operation"`
+	Usage     Usage         `json:"usage"`
+	Latency   time.Duration `json:"latency"`
+	// RequestID is the X-Request-ID of the call (see WithRequestID)
+	RequestID string `json:"request_id,omitempty"`
+	// Shadow is set for calls mirrored by a ShadowClient
+	Shadow bool `json:"shadow,omitempty"`
+	// Variant is the ABClient variant of the call, if any
+	Variant string `json:"variant,omitempty"`
+}
+
+// reportUsage calls Config.OnUsage for a successful call
+func reportUsage(ctx context.Context, config Config, event UsageEvent) {
+	if config.OnUsage == nil {
+		return
+	}
+	event.Provider = config.Provider
+	event.Shadow = IsShadowCall(ctx)
+	event.Variant, _ = VariantFromContext(ctx)
+	config.OnUsage(ctx, event)
+}
+
+// UsageTotals are the accumulated counters of a UsageSnapshot
+type UsageTotals struct {
+	Requests         int64 `json:"requests"`
+	Errors           int64 `json:"errors"`
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	CachedTokens     int64 `json:"cached_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	// CostUSD is estimated with the accountant's Pricing; calls to models
+	// without a price are counted in UnpricedRequests instead
+	CostUSD          float64          `json:"cost_usd"`
+	UnpricedRequests int64            `json:"unpriced_requests,omitempty"`
+	ErrorsByClass    map[string]int64 `json:"errors_by_class,omitempty"`
+}
+
+// ModelUsage is the usage of one provider and model
+type ModelUsage struct {
+	Provider Provider `json:"provider"`
+	Model    string   `json:"model"`
+	// Shadow rows account the calls mirrored by a ShadowClient
+	Shadow bool `json:"shadow,omitempty"`
+	UsageTotals
+}
+
+// UsageSnapshot is a point-in-time copy of a UsageAccountant
+type UsageSnapshot struct {
+	Since  time.Time   `json:"since"`
+	Until  time.Time   `json:"until"`
+	Totals UsageTotals `json:"totals"`
+	// Shadow totals the calls mirrored by a ShadowClient, which are left
+	// out of Totals
+	Shadow UsageTotals  `json:"shadow"`
+	Models []ModelUsage `json:"models"`
+}
+
+// UsageAccountant accumulates requests, tokens, errors and estimated cost
+// per provider and model, with calls mirrored by a ShadowClient in rows of
+// their own. It is a MetricsRecorder: set it as Config.Metrics
+// of every client to account (wrapping clients included, each call is
+// attributed to the client that actually served it), or combine it with
+// another recorder using MultiRecorder.
+type UsageAccountant struct {
+	// Pricing prices calls (nil = the default table, see SetPricing)
+	Pricing Pricing
+
+	mu     sync.Mutex
+	since  time.Time
+	models map[usageKey]*UsageTotals
+}
+
+// usageKey identifies a row of UsageAccountant
+type usageKey struct {
+	provider Provider
+	model    string
+	shadow   bool
+}
+
+// NewUsageAccountant creates an empty UsageAccountant
+func NewUsageAccountant() *UsageAccountant {
+	return &UsageAccountant{
+		since:  now().UTC(),
+		models: make(map[usageKey]*UsageTotals),
+	}
+}
+
+// ObserveRequest accounts one call
+func (a *UsageAccountant) ObserveRequest(metrics RequestMetrics) {
+	pricing := a.Pricing
+	if pricing == nil {
+		pricing = DefaultPricing()
+	}
+	usd, priceErr := pricing.Cost(metrics.Provider, metrics.Model,
+		metrics.Usage.PromptTokens, metrics.Usage.CachedTokens, metrics.Usage.CompletionTokens)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := usageKey{provider: metrics.Provider, model: metrics.Model, shadow: metrics.Shadow}
+	totals, ok := a.models[key]
+	if !ok {
+		totals = &UsageTotals{}
+		a.models[key] = totals
+	}
+	totals.Requests++
+	if metrics.Status != StatusOK {
+		totals.Errors++
+		if totals.ErrorsByClass == nil {
+			totals.ErrorsByClass = make(map[string]int64)
+		}
+		totals.ErrorsByClass[metrics.Status]++
+	}
+	totals.PromptTokens += int64(metrics.Usage.PromptTokens)
+	totals.CompletionTokens += int64(metrics.Usage.CompletionTokens)
+	totals.CachedTokens += int64(metrics.Usage.CachedTokens)
+	totals.TotalTokens += int64(metrics.Usage.TotalTokens)
+	if priceErr != nil {
+		if metrics.Usage.TotalTokens > 0 {
+			totals.UnpricedRequests++
+		}
+	} else {
+		totals.CostUSD += usd
+	}
+}
+
+// UsageStats returns a snapshot of the accumulated usage, with models
+// sorted by provider and name and shadow rows last
+func (a *UsageAccountant) UsageStats() UsageSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.snapshot()
+}
+
+// ResetUsage clears all counters and returns the final snapshot of the
+// period it ends, so no call is lost between reading and resetting
+func (a *UsageAccountant) ResetUsage() UsageSnapshot {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	snapshot := a.snapshot()
+	a.since = snapshot.Until
+	a.models = make(map[usageKey]*UsageTotals)
+	return snapshot
+}
+
+// snapshot copies the counters; a.mu must be held
+func (a *UsageAccountant) snapshot() UsageSnapshot {
+	snapshot := UsageSnapshot{
+		Since:  a.since,
+		Until:  now().UTC(),
+		Models: make([]ModelUsage, 0, len(a.models)),
+	}
+	for key, totals := range a.models {
+		row := ModelUsage{Provider: key.provider, Model: key.model, Shadow: key.shadow, UsageTotals: *totals}
+		row.ErrorsByClass = copyCounts(totals.ErrorsByClass)
+		snapshot.Models = append(snapshot.Models, row)
+		if key.shadow {
+			snapshot.Shadow.add(totals)
+		} else {
+			snapshot.Totals.add(totals)
+		}
+	}
+	sort.Slice(snapshot.Models, func(i, j int) bool {
+		a, b := snapshot.Models[i], snapshot.Models[j]
+		if a.Shadow != b.Shadow {
+			return b.Shadow
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.Model < b.Model
+	})
+	return snapshot
+}
+
+// add accumulates other into t
+func (t *UsageTotals) add(other *UsageTotals) {
+	t.Requests += other.Requests
+	t.Errors += other.Errors
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.CachedTokens += other.CachedTokens
+	t.TotalTokens += other.TotalTokens
+	t.CostUSD += other.CostUSD
+	t.UnpricedRequests += other.UnpricedRequests
+	for class, n := range other.ErrorsByClass {
+		if t.ErrorsByClass == nil {
+			t.ErrorsByClass = make(map[string]int64)
+		}
+		t.ErrorsByClass[class] += n
+	}
+}
+
+// copyCounts copies a counter map
+func copyCounts(counts map[string]int64) map[string]int64 {
+	if counts == nil {
+		return nil
+	}
+	result := make(map[string]int64, len(counts))
+	for k, v := range counts {
+		result[k] = v
+	}
+	return result
+}
@@ -0,0 +1,125 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsageAccountant(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":1000,"completion_tokens":500,"total_tokens":1500}}`))
+	}))
+	defer server.Close()
+
+	accountant := NewUsageAccountant()
+	other := &recordingMetrics{}
+	client, err := NewClient(Config{
+		Provider:     ProviderOpenAI,
+		APIKey:       "test-key",
+		BaseURL:      server.URL,
+		DefaultModel: "gpt-4o-mini",
+		Metrics:      MultiRecorder(accountant, other),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := context.Background()
+	GenerateSimple(ctx, client, "Hello")
+	GenerateSimple(ctx, client, "Hello")
+	status = http.StatusTooManyRequests
+	GenerateSimple(ctx, client, "Hello")
+
+	if len(other.observations) != 3 {
+		t.Errorf("MultiRecorder forwarded %d observations, want 3", len(other.observations))
+	}
+
+	stats := accountant.UsageStats()
+	if len(stats.Models) != 1 {
+		t.Fatalf("Expected one model row, got %+v", stats.Models)
+	}
+	row := stats.Models[0]
+	if row.Provider != ProviderOpenAI || row.Model != "gpt-4o-mini" || row.Requests != 3 || row.Errors != 1 ||
+		row.ErrorsByClass[StatusRateLimited] != 1 || row.PromptTokens != 2000 || row.CompletionTokens != 1000 {
+		t.Errorf("Unexpected row %+v", row)
+	}
+	wantCost := 2 * (1000*0.15 + 500*0.60) / 1e6
+	if math.Abs(stats.Totals.CostUSD-wantCost) > 1e-12 || stats.Totals.Requests != 3 {
+		t.Errorf("Unexpected totals %+v", stats.Totals)
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		t.Fatalf("Snapshot does not marshal: %v", err)
+	}
+	var decoded UsageSnapshot
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.Models[0].TotalTokens != 3000 {
+		t.Errorf("Snapshot did not round-trip: %s", data)
+	}
+
+	final := accountant.ResetUsage()
+	if final.Totals.Requests != 3 {
+		t.Errorf("ResetUsage should return the final snapshot, got %+v", final.Totals)
+	}
+	if after := accountant.UsageStats(); after.Totals.Requests != 0 || len(after.Models) != 0 || !after.Since.Equal(final.Until) {
+		t.Errorf("Expected empty stats after reset, got %+v", after)
+	}
+}
+
+func TestOnUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/embeddings" {
+			w.Write([]byte(`{"data":[{"embedding":[0.1],"index":0}],"model":"text-embedding-3-small","usage":{"prompt_tokens":3,"total_tokens":3}}`))
+			return
+		}
+		if r.Header.Get("X-Fail") != "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"model":"gpt-4o-2024-08-06","choices":[{"message":{"role":"assistant","content":"ok"}}],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	var events []UsageEvent
+	client, err := NewClient(Config{
+		Provider:              ProviderOpenAI,
+		APIKey:                "test-key",
+		BaseURL:               server.URL,
+		DisableBaseURLVersion: true,
+		OnUsage: func(ctx context.Context, event UsageEvent) {
+			events = append(events, event)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-1")
+	if _, err := GenerateSimple(ctx, client, "Hello"); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	failing := BuildSimpleRequest("Hello")
+	failing.Headers = map[string]string{"X-Fail": "1"}
+	client.Generate(ctx, failing)
+	if _, err := client.CreateEmbedding(ctx, EmbeddingRequest{Input: []string{"hi"}}); err != nil {
+		t.Fatalf("CreateEmbedding failed: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events (failed call skipped), got %+v", events)
+	}
+	chat, embedding := events[0], events[1]
+	if chat.Provider != ProviderOpenAI || chat.Model != "gpt-4o-2024-08-06" || chat.Operation != OperationChat ||
+		chat.Usage.TotalTokens != 7 || chat.RequestID != "req-1" {
+		t.Errorf("Unexpected chat event %+v", chat)
+	}
+	if embedding.Operation != OperationEmbedding || embedding.Model != "text-embedding-3-small" || embedding.Usage.TotalTokens != 3 {
+		t.Errorf("Unexpected embedding event %+v", embedding)
+	}
+}
@@ -0,0 +1,62 @@
+package llm
+
+import "net/http"
+
+// Viewer is implemented by the provider clients. Views share their
+// parent's credentials, HTTP client and connection pool but have their own
+// defaults, so call sites that need another model do not have to set
+// Request.Model on every call. A view starts from the parent's current
+// configuration; later DefaultsSetter changes on either side stay on that
+// side. Closing a view only stops its own calls, while closing the parent
+// stops its views as well.
+type Viewer interface {
+	// WithModel returns a view whose default model is model (the parent's
+	// when empty)
+	WithModel(model string) Client
+
+	// WithDefaults returns a view whose defaults are taken from the model
+	// and sampling parameters opts set (WithModel, WithTemperature,
+	// WithMaxTokens, WithTopP and WithTopK), its system messages
+	// (DefaultSystemPrompt) and extra parameters (added to
+	// DefaultExtraParams); other fields set by opts are ignored
+	WithDefaults(opts ...RequestOption) Client
+}
+
+// applyDefaults sets the Default* fields of config from the model, sampling
+// parameters, system messages and extra parameters opts set on a request
+func applyDefaults(config Config, opts []RequestOption) Config {
+	request := NewRequest(opts...)
+	if system := systemText(requestMessages(request)); system != "" {
+		config.DefaultSystemPrompt = system
+	}
+	if len(request.ExtraParams) > 0 {
+		config.DefaultExtraParams = withConfigDefaults(config, request).ExtraParams
+	}
+	if request.Model != nil && *request.Model != "" {
+		config.DefaultModel = *request.Model
+	}
+	if request.Temperature != nil {
+		config.DefaultTemperature = clonePtr(request.Temperature)
+	}
+	if request.MaxTokens != nil {
+		config.DefaultMaxTokens = clonePtr(request.MaxTokens)
+	}
+	if request.TopP != nil {
+		config.DefaultTopP = clonePtr(request.TopP)
+	}
+	if request.TopK != nil {
+		config.DefaultTopK = clonePtr(request.TopK)
+	}
+	return config
+}
+
+// view returns a copy of httpClient sending its requests through a new
+// tracker on top of t, for a view of the client: closing the view's tracker
+// leaves t and its connections alone, and closing t rejects the view's
+// requests too
+func (t *requestTracker) view(httpClient *http.Client) (*http.Client, *requestTracker) {
+	tracker := &requestTracker{base: t, drained: make(chan struct{})}
+	tracked := *httpClient
+	tracked.Transport = tracker
+	return &tracked, tracker
+}
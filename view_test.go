@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestWithModel(t *testing.T) {
+	var model string
+	var temperature *float64
+	baseURL := payloadServer(t, func(m string, temp *float64) { model, temperature = m, temp })
+
+	initial := 0.5
+	parent, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: baseURL, DefaultModel: "gpt-4o", DefaultTemperature: &initial})
+	mini := parent.(Viewer).WithModel("gpt-4o-mini")
+	tuned := parent.(Viewer).WithDefaults(WithModel("gpt-4.1"), WithTemperature(0.1), WithUser("ignored"))
+
+	tests := []struct {
+		name        string
+		client      Client
+		model       string
+		temperature float64
+	}{
+		{name: "parent", client: parent, model: "gpt-4o", temperature: 0.5},
+		{name: "WithModel", client: mini, model: "gpt-4o-mini", temperature: 0.5},
+		{name: "WithDefaults", client: tuned, model: "gpt-4.1", temperature: 0.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.client.Generate(context.Background(), BuildSimpleRequest("Hi")); err != nil {
+				t.Fatalf("Generate failed: %v", err)
+			}
+			if model != tt.model || temperature == nil || *temperature != tt.temperature {
+				t.Errorf("Expected %s at %v, got %s at %v", tt.model, tt.temperature, model, temperature)
+			}
+			if got := tt.client.GetConfig().DefaultModel; got != tt.model {
+				t.Errorf("GetConfig().DefaultModel = %s, want %s", got, tt.model)
+			}
+		})
+	}
+
+	compliant := parent.(Viewer).WithDefaults(WithSystem("Follow the policy."), WithExtraParam("seed", 7))
+	if config := compliant.GetConfig(); config.DefaultSystemPrompt != "Follow the policy." || config.DefaultExtraParams["seed"] != 7 {
+		t.Errorf("Expected WithDefaults to set the default system prompt and extra params, got %q and %v", config.DefaultSystemPrompt, config.DefaultExtraParams)
+	}
+
+	view := mini.(*openAIClient)
+	if view.requests.base != parent.(*openAIClient).requests {
+		t.Error("Expected the view to send its requests through the parent's transport")
+	}
+}
+
+func TestViewClose(t *testing.T) {
+	baseURL := payloadServer(t, func(string, *float64) {})
+	parent, _ := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: baseURL})
+	first := parent.(Viewer).WithModel("gpt-4o-mini")
+	second := parent.(Viewer).WithModel("gpt-4o-mini")
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := first.Generate(context.Background(), BuildSimpleRequest("Hi")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected the closed view to fail with ErrClientClosed, got %v", err)
+	}
+	for _, client := range []Client{parent, second} {
+		if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hi")); err != nil {
+			t.Errorf("Expected closing a view to leave its parent and siblings open, got %v", err)
+		}
+	}
+
+	if err := parent.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := second.Generate(context.Background(), BuildSimpleRequest("Hi")); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Expected closing the parent to close its views, got %v", err)
+	}
+}
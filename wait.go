@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrWouldBlock is returned by TryGenerate when the call would have to wait
+// for a rate limiter or a queue
+var ErrWouldBlock = errors.New("call would block")
+
+// WaitEstimate is how long a call made now would wait before being sent
+type WaitEstimate struct {
+	// Wait is the estimated rate limiter wait, including the capacity
+	// reserved by the calls queued ahead
+	Wait time.Duration
+	// Queued is the number of calls queued ahead
+	Queued int
+	// Saturated is set when every concurrency slot is busy, so the call
+	// would also wait, for an unknown time, for one to free up
+	Saturated bool
+}
+
+// Blocks reports whether the call would wait at all
+func (e WaitEstimate) Blocks() bool {
+	return e.Wait > 0 || e.Queued > 0 || e.Saturated
+}
+
+// add combines the estimates of two stages a call goes through
+func (e WaitEstimate) add(other WaitEstimate) WaitEstimate {
+	return WaitEstimate{
+		Wait:      e.Wait + other.Wait,
+		Queued:    e.Queued + other.Queued,
+		Saturated: e.Saturated || other.Saturated,
+	}
+}
+
+// WaitEstimator is implemented by clients that may hold calls back, such as
+// SchedulerClient, and by wrappers that route calls to such clients; use
+// EstimateWait and TryGenerate to call it on any client
+type WaitEstimator interface {
+	// EstimateWait reports how long request would wait if sent now,
+	// without reserving anything
+	EstimateWait(ctx context.Context, request Request) WaitEstimate
+
+	// TryGenerate sends request only if it would not wait, and fails with
+	// ErrWouldBlock otherwise
+	TryGenerate(ctx context.Context, request Request) (*Response, error)
+}
+
+// EstimateWait reports how long request would wait if sent to client now.
+// Clients that never hold calls back report a zero estimate. The estimate
+// is a snapshot: other callers may take the capacity before this one uses it.
+func EstimateWait(ctx context.Context, client Client, request Request) WaitEstimate {
+	if estimator, ok := client.(WaitEstimator); ok {
+		return estimator.EstimateWait(ctx, request)
+	}
+	return WaitEstimate{}
+}
+
+// TryGenerate sends request to client only if it can be sent without
+// waiting, and fails with ErrWouldBlock otherwise, so that callers can serve
+// a cached or degraded answer instead. Clients that never hold calls back
+// just Generate.
+func TryGenerate(ctx context.Context, client Client, request Request) (*Response, error) {
+	if estimator, ok := client.(WaitEstimator); ok {
+		return estimator.TryGenerate(ctx, request)
+	}
+	return client.Generate(ctx, request)
+}
@@ -0,0 +1,80 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultWarmupConnections is the number of connections Warmup opens when
+// Config.WarmupConnections is 0
+const defaultWarmupConnections = 2
+
+// preconnectTimeout bounds the Warmup started by Config.PreconnectOnCreate
+const preconnectTimeout = 10 * time.Second
+
+// warmup resolves the provider host and opens connections to it by sending
+// HEAD requests to the base URL, which leaves them idle in the pool of
+// httpClient. The requests carry no credentials and bypass hooks, metrics
+// and usage reporting; any HTTP status counts as success since only the
+// connection matters. Clients that reach their provider over a unix socket
+// have nothing to warm up.
+func warmup(ctx context.Context, httpClient *http.Client, config Config) error {
+	if _, ok := unixSocketPath(config.BaseURL); ok {
+		return nil
+	}
+	if _, ok := ctx.Deadline(); !ok && config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.Timeout)
+		defer cancel()
+	}
+	connections := config.WarmupConnections
+	if connections <= 0 {
+		connections = defaultWarmupConnections
+	}
+
+	// Concurrent requests make the transport dial one connection each
+	// (HTTP/2 shares a single one)
+	errs := make([]error, connections)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, config.BaseURL, nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			resp, err := sendRequest(httpClient, config, req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return fmt.Errorf("warmup: %w", err)
+		}
+	}
+	return nil
+}
+
+// preconnect warms up a new client in the background for
+// Config.PreconnectOnCreate, logging a failure to Config.Logger
+func preconnect(client Client, config Config) {
+	ctx, cancel := context.WithTimeout(context.Background(), preconnectTimeout)
+	defer cancel()
+	if err := client.Warmup(ctx); err != nil && config.Logger != nil {
+		config.Logger.LogAttrs(ctx, slog.LevelWarn, "llm: preconnect failed",
+			slog.String("provider", string(config.Provider)), slog.Any("error", err))
+	}
+}
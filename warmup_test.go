@@ -0,0 +1,98 @@
+package llm
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	var dials, heads atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+			if r.Header.Get("Authorization") != "" {
+				t.Errorf("Expected no credentials on warmup requests")
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	server.Start()
+	t.Cleanup(server.Close)
+
+	metrics := &recordingMetrics{}
+	client, err := NewClient(Config{
+		Provider:              ProviderOpenAI,
+		APIKey:                "test-key",
+		BaseURL:               server.URL,
+		DisableBaseURLVersion: true,
+		HTTPClient:            &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: 8}},
+		WarmupConnections:     3,
+		Metrics:               metrics,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if err := client.Warmup(context.Background()); err != nil {
+		t.Fatalf("Warmup failed: %v", err)
+	}
+	if heads.Load() != 3 || dials.Load() != 3 {
+		t.Errorf("Expected 3 HEAD requests on 3 connections, got %d on %d", heads.Load(), dials.Load())
+	}
+	if len(metrics.observations) != 0 {
+		t.Errorf("Expected warmup to stay out of metrics, got %+v", metrics.observations)
+	}
+
+	if _, err := client.Generate(context.Background(), BuildSimpleRequest("Hello")); err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if dials.Load() != 3 {
+		t.Errorf("Expected Generate to reuse a warm connection, got %d connections", dials.Load())
+	}
+
+	client.Close()
+	if err := client.Warmup(context.Background()); err == nil {
+		t.Error("Expected Warmup to fail on a closed client")
+	}
+}
+
+func TestPreconnectOnCreate(t *testing.T) {
+	heads := make(chan struct{}, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads <- struct{}{}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	if _, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: server.URL, PreconnectOnCreate: true}); err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	select {
+	case <-heads:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the client to preconnect in the background")
+	}
+
+	// Unix socket clients have nothing to warm up
+	unix, err := NewClient(Config{Provider: ProviderOpenAI, APIKey: "test-key", BaseURL: "unix:///nonexistent.sock"})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := unix.Warmup(context.Background()); err != nil {
+		t.Errorf("Expected a no-op, got %v", err)
+	}
+}